@@ -0,0 +1,121 @@
+// Package badges declares the badges users can earn. Each badge is a rule:
+// a user earns it once a metric of their activity reaches a threshold. The
+// database measures the metrics and keeps the awards.
+package badges
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"regexp"
+)
+
+// Metrics a rule can be based on
+const (
+	MetricPosts           = "posts"            // published posts
+	MetricComments        = "comments"         // published comments and replies
+	MetricAcceptedAnswers = "accepted_answers" // accepted on other users' posts
+	MetricLikesReceived   = "likes_received"   // on posts and comments, from other users
+	MetricReputation      = "reputation"
+	MetricMemberDays      = "member_days" // since signing up
+)
+
+// Metrics lists every metric rules can use
+var Metrics = []string{MetricPosts, MetricComments, MetricAcceptedAnswers, MetricLikesReceived, MetricReputation, MetricMemberDays}
+
+// Rule is a badge and what earns it
+type Rule struct {
+	ID          string `json:"id"` // stored with awards; never reuse one for another badge
+	Name        string `json:"name"`
+	Description string `json:"description"`
+	Metric      string `json:"metric"`
+	Threshold   int    `json:"threshold"`
+}
+
+// Defaults are the badges used unless BADGES_FILE names others
+var Defaults = []Rule{
+	{ID: "first-post", Name: "First Post", Description: "Published a first post", Metric: MetricPosts, Threshold: 1},
+	{ID: "prolific", Name: "Prolific", Description: "Published 50 posts", Metric: MetricPosts, Threshold: 50},
+	{ID: "first-comment", Name: "Chatty", Description: "Wrote a first comment or reply", Metric: MetricComments, Threshold: 1},
+	{ID: "conversationalist", Name: "Conversationalist", Description: "Wrote 100 comments and replies", Metric: MetricComments, Threshold: 100},
+	{ID: "helpful", Name: "Helpful", Description: "Had an answer accepted", Metric: MetricAcceptedAnswers, Threshold: 1},
+	{ID: "expert", Name: "Expert", Description: "Had 10 answers accepted", Metric: MetricAcceptedAnswers, Threshold: 10},
+	{ID: "liked", Name: "Liked", Description: "Received 10 likes", Metric: MetricLikesReceived, Threshold: 10},
+	{ID: "popular", Name: "Popular", Description: "Received 100 likes", Metric: MetricLikesReceived, Threshold: 100},
+	{ID: "respected", Name: "Respected", Description: "Reached 1,000 reputation", Metric: MetricReputation, Threshold: 1000},
+	{ID: "one-year", Name: "One-Year Member", Description: "Member for a year", Metric: MetricMemberDays, Threshold: 365},
+}
+
+var ruleID = regexp.MustCompile(`^[a-z0-9][a-z0-9-]{0,49}$`)
+
+// Validate checks that rules have distinct IDs of lowercase letters,
+// digits and dashes, a name, a known metric and a positive threshold
+func Validate(rules []Rule) error {
+	seen := map[string]bool{}
+	for i, rule := range rules {
+		switch {
+		case !ruleID.MatchString(rule.ID):
+			return fmt.Errorf("badge %d: invalid id %q", i+1, rule.ID)
+		case seen[rule.ID]:
+			return fmt.Errorf("badge %q is declared twice", rule.ID)
+		case rule.Name == "":
+			return fmt.Errorf("badge %q has no name", rule.ID)
+		case !knownMetric(rule.Metric):
+			return fmt.Errorf("badge %q: unknown metric %q", rule.ID, rule.Metric)
+		case rule.Threshold < 1:
+			return fmt.Errorf("badge %q: threshold must be at least 1", rule.ID)
+		}
+		seen[rule.ID] = true
+	}
+	return nil
+}
+
+func knownMetric(metric string) bool {
+	for _, m := range Metrics {
+		if m == metric {
+			return true
+		}
+	}
+	return false
+}
+
+// Load reads rules from a JSON file holding an array of rules
+func Load(path string) ([]Rule, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var rules []Rule
+	if err := json.Unmarshal(data, &rules); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	if err := Validate(rules); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return rules, nil
+}
+
+// FromEnv returns the rules in the file BADGES_FILE names, or the
+// defaults. The defaults are returned with the error when the file can't
+// be used.
+func FromEnv() ([]Rule, error) {
+	path := os.Getenv("BADGES_FILE")
+	if path == "" {
+		return Defaults, nil
+	}
+	rules, err := Load(path)
+	if err != nil {
+		return Defaults, fmt.Errorf("BADGES_FILE: %w", err)
+	}
+	return rules, nil
+}
+
+// Find returns the rule with an ID
+func Find(rules []Rule, id string) (Rule, bool) {
+	for _, rule := range rules {
+		if rule.ID == id {
+			return rule, true
+		}
+	}
+	return Rule{}, false
+}
@@ -0,0 +1,52 @@
+package badges
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestValidate(t *testing.T) {
+	if err := Validate(Defaults); err != nil {
+		t.Fatalf("Expected the defaults to be valid, got %v", err)
+	}
+
+	valid := Rule{ID: "ok", Name: "OK", Metric: MetricPosts, Threshold: 1}
+	tests := []struct {
+		name  string
+		rules []Rule
+		want  string
+	}{
+		{"bad id", []Rule{{ID: "Bad ID", Name: "x", Metric: MetricPosts, Threshold: 1}}, "invalid id"},
+		{"duplicate", []Rule{valid, valid}, "declared twice"},
+		{"no name", []Rule{{ID: "x", Metric: MetricPosts, Threshold: 1}}, "no name"},
+		{"unknown metric", []Rule{{ID: "x", Name: "x", Metric: "karma", Threshold: 1}}, "unknown metric"},
+		{"zero threshold", []Rule{{ID: "x", Name: "x", Metric: MetricPosts}}, "threshold"},
+	}
+	for _, tt := range tests {
+		if err := Validate(tt.rules); err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("%s: expected an error about %q, got %v", tt.name, tt.want, err)
+		}
+	}
+}
+
+func TestFromEnv(t *testing.T) {
+	dir := t.TempDir()
+	good := filepath.Join(dir, "good.json")
+	os.WriteFile(good, []byte(`[{"id": "veteran", "name": "Veteran", "metric": "member_days", "threshold": 730}]`), 0o644)
+	bad := filepath.Join(dir, "bad.json")
+	os.WriteFile(bad, []byte(`[{"id": "veteran", "name": "Veteran", "metric": "age", "threshold": 730}]`), 0o644)
+
+	t.Setenv("BADGES_FILE", good)
+	rules, err := FromEnv()
+	if err != nil || len(rules) != 1 || rules[0].Threshold != 730 {
+		t.Errorf("Expected the file's rules, got %+v, %v", rules, err)
+	}
+
+	t.Setenv("BADGES_FILE", bad)
+	rules, err = FromEnv()
+	if err == nil || len(rules) != len(Defaults) {
+		t.Errorf("Expected the defaults and an error for an invalid file, got %d rules, %v", len(rules), err)
+	}
+}
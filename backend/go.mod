@@ -9,3 +9,5 @@ require (
 	github.com/mattn/go-sqlite3 v1.14.24
 	golang.org/x/crypto v0.35.0
 )
+
+require golang.org/x/sys v0.30.0 // indirect
@@ -0,0 +1,128 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+
+	"forum/models"
+	"forum/sqlite"
+	"forum/utils"
+)
+
+// AcceptAnswer marks a comment or reply as the accepted answer to a post.
+// Only the post's author and moderators may accept answers.
+func AcceptAnswer(db *sql.DB, w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var request struct {
+		PostID    int `json:"post_id"`
+		CommentID int `json:"comment_id"`
+		ReplyID   int `json:"reply_id"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil || request.PostID <= 0 ||
+		(request.CommentID > 0) == (request.ReplyID > 0) {
+		utils.SendJSONError(w, "Give a post_id and either a comment_id or a reply_id", http.StatusBadRequest)
+		return
+	}
+	contentType, contentID := models.ContentComment, request.CommentID
+	if request.ReplyID > 0 {
+		contentType, contentID = models.ContentReply, request.ReplyID
+	}
+
+	userID, post, ok := answerablePost(db, w, r, request.PostID)
+	if !ok {
+		return
+	}
+	if (contentType == models.ContentComment && post.AcceptedCommentID != nil && *post.AcceptedCommentID == contentID) ||
+		(contentType == models.ContentReply && post.AcceptedReplyID != nil && *post.AcceptedReplyID == contentID) {
+		utils.SendJSONResponse(w, post, http.StatusOK)
+		return
+	}
+
+	answererID, err := sqlite.AcceptAnswer(db, post.ID, contentType, contentID)
+	if err == sqlite.ErrNotAnAnswer {
+		utils.SendJSONError(w, "Only published comments and replies on this post can be accepted", http.StatusBadRequest)
+		return
+	}
+	if err != nil {
+		utils.SendJSONError(w, "Failed to accept answer", http.StatusInternalServerError)
+		return
+	}
+
+	checkBadges(db, answererID)
+	if answererID != userID {
+		link := fmt.Sprintf("/post/%d", post.ID)
+		if err := sqlite.CreateNotification(db, answererID, "answer", "Your answer was accepted.", link); err != nil {
+			log.Printf("Warning: Failed to notify user %s: %v", answererID, err)
+		}
+	}
+	sendAnsweredPost(db, w, userID, post.ID)
+}
+
+// ClearAcceptedAnswer unmarks the accepted answer of a post
+func ClearAcceptedAnswer(db *sql.DB, w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodDelete {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var request struct {
+		PostID int `json:"post_id"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil || request.PostID <= 0 {
+		http.Error(w, "Invalid request data", http.StatusBadRequest)
+		return
+	}
+
+	userID, post, ok := answerablePost(db, w, r, request.PostID)
+	if !ok {
+		return
+	}
+	if err := sqlite.ClearAcceptedAnswer(db, post.ID); err != nil {
+		utils.SendJSONError(w, "Failed to clear accepted answer", http.StatusInternalServerError)
+		return
+	}
+	sendAnsweredPost(db, w, userID, post.ID)
+}
+
+// answerablePost loads a post whose accepted answer the current user may
+// change; ok is false when a response has been sent
+func answerablePost(db *sql.DB, w http.ResponseWriter, r *http.Request, postID int) (userID string, post models.Post, ok bool) {
+	userID, err := utils.GetUserIDFromSession(db, r)
+	if err != nil || userID == "" {
+		utils.SendJSONError(w, "Unauthorized", http.StatusUnauthorized)
+		return "", post, false
+	}
+
+	viewer := viewerFor(db, userID)
+	post, err = sqlite.GetPost(db, viewer, postID)
+	if err == sql.ErrNoRows {
+		utils.SendJSONError(w, "Post not found", http.StatusNotFound)
+		return "", post, false
+	}
+	if err != nil {
+		utils.SendJSONError(w, "Failed to read post data", http.StatusInternalServerError)
+		return "", post, false
+	}
+	if post.UserID != userID && !viewer.Moderator {
+		utils.SendJSONError(w, "Only the author of the post can accept answers", http.StatusForbidden)
+		return "", post, false
+	}
+	return userID, post, true
+}
+
+// sendAnsweredPost responds with a post after its accepted answer changed
+func sendAnsweredPost(db *sql.DB, w http.ResponseWriter, userID string, postID int) {
+	post, err := sqlite.GetPost(db, viewerFor(db, userID), postID)
+	if err != nil {
+		utils.SendJSONError(w, "Failed to read post data", http.StatusInternalServerError)
+		return
+	}
+	utils.SendJSONResponse(w, post, http.StatusOK)
+}
@@ -0,0 +1,103 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"forum/models"
+	"forum/sqlite"
+)
+
+func TestAcceptAnswer(t *testing.T) {
+	db := setupPostTestDB(t)
+	defer db.Close()
+
+	users, sessions := createTestUsers(t, db, "alice", "bob", "carol", "dave")
+	db.Exec(`UPDATE users SET role = 'moderator' WHERE username = 'dave'`)
+
+	reputation := func(name string) int {
+		var points int
+		db.QueryRow(`SELECT reputation FROM users WHERE username = ?`, name).Scan(&points)
+		return points
+	}
+	comments := func() []models.Comment {
+		comments, _ := sqlite.GetPostComments(db, models.Viewer{}, 1)
+		return comments
+	}
+
+	question, _ := sqlite.CreatePost(db, users["alice"], []int{}, "How do I exit vim?", "Help", "", models.ContentPublished)
+	sqlite.CreatePost(db, users["alice"], []int{}, "Another question", "Help", "", models.ContentPublished)
+	first, _ := sqlite.CreateComment(db, users["carol"], question.ID, "Pull the plug", models.ContentPublished)
+	answer, _ := sqlite.CreateComment(db, users["bob"], question.ID, ":q!", models.ContentPublished)
+	held, _ := sqlite.CreateComment(db, users["bob"], question.ID, "Buy my course", models.ContentPending)
+	sqlite.CreateReplyComment(db, users["bob"], first.ID, "Ha", models.ContentPublished)
+	reply, _ := sqlite.CreateReplyComment(db, users["carol"], first.ID, "Or :wq", models.ContentPublished)
+	replyID := reply.ID
+
+	t.Run("refused", func(t *testing.T) {
+		tests := []struct {
+			name, user string
+			body       map[string]int
+			want       int
+		}{
+			{"not the author", "bob", map[string]int{"post_id": question.ID, "comment_id": answer.ID}, http.StatusForbidden},
+			{"comment and reply", "alice", map[string]int{"post_id": question.ID, "comment_id": answer.ID, "reply_id": replyID}, http.StatusBadRequest},
+			{"held comment", "alice", map[string]int{"post_id": question.ID, "comment_id": held.ID}, http.StatusBadRequest},
+			{"other post", "alice", map[string]int{"post_id": 2, "comment_id": answer.ID}, http.StatusBadRequest},
+			{"unknown post", "alice", map[string]int{"post_id": 99, "comment_id": answer.ID}, http.StatusNotFound},
+		}
+		for _, tt := range tests {
+			if w := sendAs(db, AcceptAnswer, "POST", "/", sessions[tt.user], tt.body); w.Code != tt.want {
+				t.Errorf("%s: expected %d, got %d: %s", tt.name, tt.want, w.Code, w.Body.String())
+			}
+		}
+	})
+
+	w := sendAs(db, AcceptAnswer, "POST", "/", sessions["alice"], map[string]int{"post_id": question.ID, "comment_id": answer.ID})
+	var post models.Post
+	json.Unmarshal(w.Body.Bytes(), &post)
+	if w.Code != http.StatusOK || !post.Answered || post.AcceptedCommentID == nil || *post.AcceptedCommentID != answer.ID {
+		t.Fatalf("Expected the answer accepted, got %d: %s", w.Code, w.Body.String())
+	}
+	if reputation("bob") != models.AcceptedAnswerPoints {
+		t.Errorf("Expected bob to gain %d reputation, got %d", models.AcceptedAnswerPoints, reputation("bob"))
+	}
+	notifications, _ := sqlite.GetNotifications(db, users["bob"], 1, 10)
+	if len(notifications) == 0 || notifications[0].Type != "answer" || notifications[0].Link != "/post/1" {
+		t.Errorf("Expected bob notified, got %+v", notifications)
+	}
+	if got := comments(); got[0].ID != answer.ID || !got[0].Accepted || got[1].Accepted {
+		t.Errorf("Expected the accepted answer first, got %+v", got)
+	}
+
+	posts, _ := sqlite.GetPosts(db, models.Viewer{}, models.PostFilter{Unanswered: true}, 1, 10)
+	if len(posts) != 1 || posts[0].ID == question.ID || posts[0].Answered {
+		t.Errorf("Expected only the unanswered post, got %+v", posts)
+	}
+
+	// Accepting a reply moves the reputation and puts its comment first
+	sendAs(db, AcceptAnswer, "POST", "/", sessions["alice"], map[string]int{"post_id": question.ID, "reply_id": replyID})
+	if reputation("bob") != 0 || reputation("carol") != models.AcceptedAnswerPoints {
+		t.Errorf("Expected the reputation moved to carol, got bob %d carol %d", reputation("bob"), reputation("carol"))
+	}
+	got := comments()
+	if got[0].ID != first.ID || got[0].Accepted || got[0].Replies[0].ID != replyID || !got[0].Replies[0].Accepted {
+		t.Errorf("Expected the accepted reply first, got %+v", got)
+	}
+
+	if w := sendAs(db, ClearAcceptedAnswer, "DELETE", "/", sessions["dave"], map[string]int{"post_id": question.ID}); w.Code != http.StatusOK {
+		t.Fatalf("Expected moderators to clear answers, got %d: %s", w.Code, w.Body.String())
+	}
+	if reputation("carol") != 0 {
+		t.Errorf("Expected carol's reputation taken back, got %d", reputation("carol"))
+	}
+
+	// Deleting an accepted answer takes its reputation along
+	sendAs(db, AcceptAnswer, "POST", "/", sessions["alice"], map[string]int{"post_id": question.ID, "reply_id": replyID})
+	sqlite.DeleteComment(db, first.ID)
+	stored, _ := sqlite.GetPost(db, models.Viewer{}, question.ID)
+	if reputation("carol") != 0 || stored.Answered {
+		t.Errorf("Expected the answer and its reputation gone, got %d %+v", reputation("carol"), stored)
+	}
+}
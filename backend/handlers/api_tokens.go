@@ -0,0 +1,182 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+
+	"forum/models"
+	"forum/sqlite"
+	"forum/utils"
+)
+
+const (
+	maxAPITokensPerUser   = 20
+	maxAPITokenNameLength = 50
+	defaultAPITokenDays   = 30
+	maxAPITokenDays       = 365
+)
+
+// GetAPITokens lists the current user's API tokens (without the secrets)
+func GetAPITokens(db *sql.DB, w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	userID, err := utils.GetUserIDFromSession(db, r)
+	if err != nil || userID == "" {
+		utils.SendJSONError(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	tokens, err := sqlite.GetAPITokens(db, userID)
+	if err != nil {
+		utils.SendJSONError(w, "Database error", http.StatusInternalServerError)
+		return
+	}
+	utils.SendJSONResponse(w, tokens, http.StatusOK)
+}
+
+// CreateAPIToken issues a named, scoped token. The token itself is only
+// returned in this response.
+func CreateAPIToken(db *sql.DB, w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var request struct {
+		Name          string   `json:"name"`
+		Scopes        []string `json:"scopes"`
+		ExpiresInDays int      `json:"expires_in_days"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		http.Error(w, "Invalid request data", http.StatusBadRequest)
+		return
+	}
+
+	userID, err := utils.GetUserIDFromSession(db, r)
+	if err != nil || userID == "" {
+		utils.SendJSONError(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	name := strings.TrimSpace(request.Name)
+	if name == "" || len([]rune(name)) > maxAPITokenNameLength {
+		utils.SendJSONError(w, fmt.Sprintf("Token name must be 1 to %d characters", maxAPITokenNameLength), http.StatusBadRequest)
+		return
+	}
+
+	if len(request.Scopes) == 0 {
+		utils.SendJSONError(w, "Choose at least one scope: "+strings.Join(models.APIScopes, ", "), http.StatusBadRequest)
+		return
+	}
+	for _, scope := range request.Scopes {
+		if !slices.Contains(models.APIScopes, scope) {
+			utils.SendJSONError(w, fmt.Sprintf("Unknown scope %q, expected one of: %s", scope, strings.Join(models.APIScopes, ", ")), http.StatusBadRequest)
+			return
+		}
+	}
+	// Store scopes in a canonical order without duplicates
+	var scopes []string
+	for _, scope := range models.APIScopes {
+		if slices.Contains(request.Scopes, scope) {
+			scopes = append(scopes, scope)
+		}
+	}
+
+	days := request.ExpiresInDays
+	if days == 0 {
+		days = defaultAPITokenDays
+	}
+	if days < 1 || days > maxAPITokenDays {
+		utils.SendJSONError(w, fmt.Sprintf("Tokens must expire within 1 to %d days", maxAPITokenDays), http.StatusBadRequest)
+		return
+	}
+
+	count, err := sqlite.CountAPITokens(db, userID)
+	if err != nil {
+		utils.SendJSONError(w, "Database error", http.StatusInternalServerError)
+		return
+	}
+	if count >= maxAPITokensPerUser {
+		utils.SendJSONError(w, fmt.Sprintf("You can have at most %d API tokens, revoke one first", maxAPITokensPerUser), http.StatusConflict)
+		return
+	}
+
+	token, created, err := sqlite.CreateAPIToken(db, userID, name, scopes, time.Now().AddDate(0, 0, days))
+	if err != nil {
+		utils.SendJSONError(w, "Failed to create API token", http.StatusInternalServerError)
+		return
+	}
+	message := fmt.Sprintf("An API token named \"%s\" was created with access to: %s.", name, strings.Join(scopes, ", "))
+	if err := sqlite.CreateNotification(db, userID, "security", message, ""); err != nil {
+		log.Printf("Warning: Failed to notify user %s: %v", userID, err)
+	}
+
+	w.Header().Set("Cache-Control", "no-store")
+	utils.SendJSONResponse(w, map[string]any{
+		"token":   token,
+		"details": created,
+	}, http.StatusCreated)
+}
+
+// RevokeAPIToken deletes one of the current user's API tokens
+func RevokeAPIToken(db *sql.DB, w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodDelete {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var request struct {
+		ID int64 `json:"id"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		http.Error(w, "Invalid request data", http.StatusBadRequest)
+		return
+	}
+
+	userID, err := utils.GetUserIDFromSession(db, r)
+	if err != nil || userID == "" {
+		utils.SendJSONError(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	err = sqlite.RevokeAPIToken(db, userID, request.ID)
+	if err == sql.ErrNoRows {
+		utils.SendJSONError(w, "API token not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		utils.SendJSONError(w, "Failed to revoke API token", http.StatusInternalServerError)
+		return
+	}
+	utils.SendJSONResponse(w, map[string]string{"message": "API token revoked"}, http.StatusOK)
+}
+
+// RevokeAllAPITokens deletes every API token of the current user
+func RevokeAllAPITokens(db *sql.DB, w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodDelete {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	userID, err := utils.GetUserIDFromSession(db, r)
+	if err != nil || userID == "" {
+		utils.SendJSONError(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	revoked, err := sqlite.RevokeAllAPITokens(db, userID)
+	if err != nil {
+		utils.SendJSONError(w, "Failed to revoke API tokens", http.StatusInternalServerError)
+		return
+	}
+	utils.SendJSONResponse(w, map[string]any{"message": "API tokens revoked", "revoked": revoked}, http.StatusOK)
+}
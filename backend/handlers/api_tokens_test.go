@@ -0,0 +1,107 @@
+package handlers
+
+import (
+	"bytes"
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"forum/sqlite"
+	"forum/utils"
+)
+
+func TestAPITokens(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	passwordHash, _ := utils.HashPassword("password123")
+	if err := sqlite.CreateUser(db, "scripter", "scripter@example.com", passwordHash, ""); err != nil {
+		t.Fatalf("Failed to create test user: %v", err)
+	}
+	user, _ := sqlite.GetUserByUsername(db, "scripter")
+	sessionID, _ := sqlite.CreateSession(db, user.ID)
+
+	call := func(handler func(*sql.DB, http.ResponseWriter, *http.Request), method string, body any) *httptest.ResponseRecorder {
+		jsonData, _ := json.Marshal(body)
+		req := httptest.NewRequest(method, "/", bytes.NewBuffer(jsonData))
+		req.AddCookie(&http.Cookie{Name: utils.SessionCookieName, Value: sessionID})
+		w := httptest.NewRecorder()
+		handler(db, w, req)
+		return w
+	}
+
+	t.Run("invalid requests", func(t *testing.T) {
+		tests := []struct {
+			name string
+			body map[string]any
+		}{
+			{"no name", map[string]any{"name": " ", "scopes": []string{"read"}}},
+			{"no scopes", map[string]any{"name": "bot", "scopes": []string{}}},
+			{"unknown scope", map[string]any{"name": "bot", "scopes": []string{"read", "admin"}}},
+			{"never expires", map[string]any{"name": "bot", "scopes": []string{"read"}, "expires_in_days": -1}},
+			{"too long", map[string]any{"name": "bot", "scopes": []string{"read"}, "expires_in_days": 1000}},
+		}
+		for _, tt := range tests {
+			if w := call(CreateAPIToken, "POST", tt.body); w.Code != http.StatusBadRequest {
+				t.Errorf("%s: expected 400, got %d", tt.name, w.Code)
+			}
+		}
+	})
+
+	var created struct {
+		Token   string `json:"token"`
+		Details struct {
+			ID     int64    `json:"id"`
+			Prefix string   `json:"prefix"`
+			Scopes []string `json:"scopes"`
+		} `json:"details"`
+	}
+
+	t.Run("create", func(t *testing.T) {
+		w := call(CreateAPIToken, "POST", map[string]any{"name": "release bot", "scopes": []string{"write:posts", "read", "read"}})
+		if w.Code != http.StatusCreated {
+			t.Fatalf("Expected 201, got %d: %s", w.Code, w.Body.String())
+		}
+		json.Unmarshal(w.Body.Bytes(), &created)
+		if !strings.HasPrefix(created.Token, sqlite.APITokenPrefix) || !strings.HasPrefix(created.Token, created.Details.Prefix) {
+			t.Errorf("Unexpected token %q with prefix %q", created.Token, created.Details.Prefix)
+		}
+		if strings.Join(created.Details.Scopes, " ") != "read write:posts" {
+			t.Errorf("Expected deduplicated scopes, got %v", created.Details.Scopes)
+		}
+
+		var stored int
+		db.QueryRow(`SELECT COUNT(*) FROM api_tokens WHERE token_hash = ?`, created.Token).Scan(&stored)
+		if stored != 0 {
+			t.Error("The plaintext token must not be stored")
+		}
+		if token, err := sqlite.GetAPIToken(db, created.Token); err != nil || token.UserID != user.ID {
+			t.Errorf("Expected token to resolve to the user, got %+v (%v)", token, err)
+		}
+	})
+
+	t.Run("list does not expose the token", func(t *testing.T) {
+		w := call(GetAPITokens, "GET", nil)
+		if w.Code != http.StatusOK {
+			t.Fatalf("Expected 200, got %d", w.Code)
+		}
+		if strings.Contains(w.Body.String(), created.Token) {
+			t.Error("Listing must not include the token")
+		}
+	})
+
+	t.Run("revoke", func(t *testing.T) {
+		if w := call(RevokeAPIToken, "DELETE", map[string]int64{"id": created.Details.ID}); w.Code != http.StatusOK {
+			t.Fatalf("Expected 200, got %d: %s", w.Code, w.Body.String())
+		}
+		if w := call(RevokeAPIToken, "DELETE", map[string]int64{"id": created.Details.ID}); w.Code != http.StatusNotFound {
+			t.Errorf("Expected 404 for a revoked token, got %d", w.Code)
+		}
+		if _, err := sqlite.GetAPIToken(db, created.Token); err != sql.ErrNoRows {
+			t.Errorf("Expected revoked token to be unknown, got %v", err)
+		}
+	})
+}
@@ -6,13 +6,17 @@ import (
 	"fmt"
 	"io"
 	"log"
+	"math"
 	"net/http"
-	"os"
 	"path/filepath"
+	"strconv"
+	"strings"
 	"time"
 
+	"forum/middleware"
 	"forum/models"
 	"forum/sqlite"
+	"forum/storage"
 	"forum/utils"
 )
 
@@ -29,6 +33,10 @@ func RegisterUser(db *sql.DB, w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if !checkCaptcha(w, r, captchaRegister) {
+		return
+	}
+
 	username := r.FormValue("username")
 	email := r.FormValue("email")
 	password := r.FormValue("password")
@@ -65,11 +73,22 @@ func RegisterUser(db *sql.DB, w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Validate password strength
-	if err := utils.ValidatePassword(password); err != nil {
-		utils.SendJSONError(w, err.Error(), http.StatusBadRequest)
+	if err := utils.ValidatePassword(password, sanitizedUsername, sanitizedEmail); err != nil {
+		utils.SendPasswordError(w, err)
 		return
 	}
 
+	// Enforce the registration mode before anything is uploaded
+	inviteCode := strings.TrimSpace(r.FormValue("invite_code"))
+	if registrationMode == registrationInvite && inviteCode == "" {
+		utils.SendJSONError(w, "An invite code is required to register", http.StatusForbidden)
+		return
+	}
+	status := models.StatusActive
+	if registrationMode == registrationApproval {
+		status = models.StatusPending
+	}
+
 	// Handle avatar upload
 	var avatarURL string
 
@@ -80,30 +99,7 @@ func RegisterUser(db *sql.DB, w http.ResponseWriter, r *http.Request) {
 	} else {
 		defer file.Close()
 
-		// Ensure the static directory exists
-		staticDir := "static"
-		if _, err := os.Stat(staticDir); os.IsNotExist(err) {
-			if err := os.MkdirAll(staticDir, 0o755); err != nil {
-				utils.SendJSONError(w, "Failed to create static directory", http.StatusInternalServerError)
-				return
-			}
-		}
-
-		// Sanitize and build a safe filename
-		safeFilename := filepath.Base(handler.Filename)
-		avatarFilename := fmt.Sprintf("avatar_%d_%s", time.Now().UnixNano(), safeFilename)
-		avatarPath := filepath.Join(staticDir, avatarFilename)
-
-		// Create destination file
-		dst, err := os.Create(avatarPath)
-		if err != nil {
-			log.Printf("Error creating file: %v\n", err)
-			utils.SendJSONError(w, "Failed to save avatar", http.StatusInternalServerError)
-			return
-		}
-		defer dst.Close()
-
-		// Optionally check MIME type (optional and basic)
+		// Check MIME type (basic)
 		buf := make([]byte, 512)
 		_, err = file.Read(buf)
 		if err != nil {
@@ -119,29 +115,37 @@ func RegisterUser(db *sql.DB, w http.ResponseWriter, r *http.Request) {
 		// Reset file pointer before copying
 		file.Seek(0, io.SeekStart)
 
-		// Save the file
-		_, err = io.Copy(dst, file)
-		if err != nil {
+		// Sanitize and build a safe filename
+		safeFilename := filepath.Base(handler.Filename)
+		avatarKey := fmt.Sprintf("avatar_%d_%s", time.Now().UnixNano(), safeFilename)
+
+		// Save the file to the configured storage backend
+		if err := storage.Uploads.Put(r.Context(), avatarKey, file, filetype); err != nil {
 			log.Printf("Error saving avatar: %v\n", err)
 			utils.SendJSONError(w, "Error saving avatar", http.StatusInternalServerError)
 			return
 		}
 
-		avatarURL = "/" + avatarPath
+		avatarURL = storage.Uploads.URL(avatarKey)
 		log.Printf("Avatar uploaded successfully: %s\n", avatarURL)
 	}
 
 	// Hash password
 	hashedPassword, err := utils.HashPassword(password)
 	if err != nil {
+		storage.DeleteURL(r.Context(), storage.Uploads, avatarURL)
 		utils.SendJSONError(w, "Error hashing password", http.StatusInternalServerError)
 		return
 	}
 
-	// Save user to DB
-	err = sqlite.CreateUser(db, sanitizedUsername, sanitizedEmail, hashedPassword, avatarURL)
+	// Save user to DB, redeeming the invite code if there is one
+	inviterID, err := sqlite.CreateUserWithInvite(db, sanitizedUsername, sanitizedEmail, hashedPassword, avatarURL, status, inviteCode)
 	if err != nil {
-		if sqlite.IsUniqueConstraintError(err) {
+		// Don't leave the uploaded avatar behind
+		storage.DeleteURL(r.Context(), storage.Uploads, avatarURL)
+		if err == sqlite.ErrInvalidInvite {
+			utils.SendJSONError(w, "Invite code is invalid, used up or expired", http.StatusForbidden)
+		} else if sqlite.IsUniqueConstraintError(err) {
 			utils.SendJSONError(w, "Username or email already exists", http.StatusConflict)
 		} else {
 			utils.SendJSONError(w, "Database error", http.StatusInternalServerError)
@@ -149,7 +153,23 @@ func RegisterUser(db *sql.DB, w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	utils.SendJSONResponse(w, map[string]string{"message": "User registered successfully"}, http.StatusCreated)
+	response := map[string]string{"message": "User registered successfully", "status": status}
+	if inviterID != "" {
+		if inviter, err := sqlite.GetUserByID(db, inviterID); err == nil {
+			response["invited_by"] = inviter.Username
+			log.Printf("👤 User %s registered, invited by %s", sanitizedUsername, inviter.Username)
+		}
+		message := fmt.Sprintf("%s joined with your invite.", sanitizedUsername)
+		if err := sqlite.CreateNotification(db, inviterID, "account", message, ""); err != nil {
+			log.Printf("Warning: Failed to notify user %s: %v", inviterID, err)
+		}
+	}
+	if status == models.StatusPending {
+		response["message"] = pendingApprovalNotice
+		notifyAdminsOfPendingUser(db, sanitizedUsername)
+	}
+
+	utils.SendJSONResponse(w, response, http.StatusCreated)
 }
 
 func LoginUser(db *sql.DB, w http.ResponseWriter, r *http.Request) {
@@ -181,6 +201,7 @@ func LoginUser(db *sql.DB, w http.ResponseWriter, r *http.Request) {
 	}
 
 	var user models.User
+	var login string
 
 	// Try to get user by email first, then by username
 	if credentials.Email != "" {
@@ -198,12 +219,9 @@ func LoginUser(db *sql.DB, w http.ResponseWriter, r *http.Request) {
 		}
 
 		// Get user from DB by email
+		login = sanitizedEmail
 		user, err = sqlite.GetUserByEmail(db, sanitizedEmail)
-		if err != nil {
-			if err == sql.ErrNoRows {
-				utils.SendJSONError(w, "Invalid credentials", http.StatusUnauthorized)
-				return
-			}
+		if err != nil && err != sql.ErrNoRows {
 			utils.SendJSONError(w, "Database error", http.StatusInternalServerError)
 			return
 		}
@@ -222,48 +240,145 @@ func LoginUser(db *sql.DB, w http.ResponseWriter, r *http.Request) {
 		}
 
 		// Get user from DB by username
+		login = sanitizedUsername
 		user, err = sqlite.GetUserByUsername(db, sanitizedUsername)
-		if err != nil {
-			if err == sql.ErrNoRows {
-				utils.SendJSONError(w, "Invalid credentials", http.StatusUnauthorized)
-				return
-			}
+		if err != nil && err != sql.ErrNoRows {
 			utils.SendJSONError(w, "Database error", http.StatusInternalServerError)
 			return
 		}
 	}
 
+	// Unknown users go through the same throttling and password check as
+	// real ones so responses can't be used to enumerate accounts
+	clientIP := middleware.ClientIP(r)
+	accountKey := utils.AccountLoginKey(user.ID, login)
+	ipKey := utils.IPLoginKey(clientIP)
+
+	retryAfter, err := utils.LoginRetryAfter(db, accountKey, ipKey)
+	if err != nil {
+		utils.SendJSONError(w, "Database error", http.StatusInternalServerError)
+		return
+	}
+	if retryAfter > 0 {
+		w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(retryAfter.Seconds()))))
+		utils.SendJSONError(w, "Too many failed login attempts, please try again later", http.StatusTooManyRequests)
+		return
+	}
+
 	// Validate password
-	if !utils.CheckPasswordHash(credentials.Password, user.PasswordHash) {
+	var validPassword, needsRehash bool
+	if user.ID != "" {
+		validPassword, needsRehash = utils.VerifyPassword(credentials.Password, user.PasswordHash)
+	} else {
+		validPassword = utils.CheckDummyPassword(credentials.Password)
+	}
+	if !validPassword {
+		locked, err := utils.RecordFailedLogin(db, accountKey, ipKey)
+		if err != nil {
+			log.Printf("Warning: Failed to record failed login for %s: %v", accountKey, err)
+		}
+		if locked && user.ID != "" {
+			notifyAccountLocked(db, user.ID, clientIP)
+		}
 		utils.SendJSONError(w, "Invalid credentials", http.StatusUnauthorized)
 		return
 	}
 
+	// Accounts waiting for approval can't log in, but only learn that once
+	// they proved they own them. startSession refuses them on every login
+	// path; checking here too spares them the second factor.
+	if user.Status != models.StatusActive {
+		utils.SendJSONError(w, "Your account is waiting for approval by an administrator", http.StatusForbidden)
+		return
+	}
+
+	// Upgrade hashes made with an older algorithm or cost while the
+	// plain password is at hand
+	if needsRehash {
+		if hash, err := utils.HashPassword(credentials.Password); err != nil {
+			log.Printf("Warning: Failed to rehash password for user %s: %v", user.ID, err)
+		} else if err := sqlite.UpdateUserPasswordHash(db, user.ID, hash); err != nil {
+			log.Printf("Warning: Failed to store rehashed password for user %s: %v", user.ID, err)
+		}
+	}
+
+	// Accounts with two-factor authentication get a short-lived pending
+	// token instead of a session; POST /api/login/2fa completes the login.
+	// Failed logins are only reset once the second factor is verified.
+	twoFactor, err := sqlite.IsTOTPEnabled(db, user.ID)
+	if err != nil {
+		utils.SendJSONError(w, "Database error", http.StatusInternalServerError)
+		return
+	}
+	if twoFactor {
+		pendingToken, err := sqlite.CreatePendingLogin(db, user.ID, twoFactorClock().Add(pendingLoginTTL))
+		if err != nil {
+			utils.SendJSONError(w, "Failed to start login", http.StatusInternalServerError)
+			return
+		}
+		utils.SendJSONResponse(w, map[string]any{
+			"message":             "Two-factor authentication required",
+			"two_factor_required": true,
+			"pending_token":       pendingToken,
+		}, http.StatusOK)
+		return
+	}
+
+	if err := utils.ClearFailedLogins(db, accountKey); err != nil {
+		log.Printf("Warning: Failed to reset failed logins for user %s: %v", user.ID, err)
+	}
+
+	if !startSession(db, w, user.ID) {
+		return
+	}
+	utils.SendJSONResponse(w, map[string]string{"message": "Logged in"}, http.StatusOK)
+}
+
+// startSession replaces the user's sessions with a new one and sets the
+// session cookie. Accounts that aren't active, such as those waiting for
+// approval, are refused whichever way they logged in. It writes an error
+// response and returns false on failure.
+func startSession(db *sql.DB, w http.ResponseWriter, userID string) bool {
+	user, err := sqlite.GetUserByID(db, userID)
+	if err != nil {
+		utils.SendJSONError(w, "Database error", http.StatusInternalServerError)
+		return false
+	}
+	if user.Status != models.StatusActive {
+		utils.SendJSONError(w, "Your account is waiting for approval by an administrator", http.StatusForbidden)
+		return false
+	}
+
 	// Delete all existing sessions for this user (single session policy)
 	// This ensures only the most recent login session persists
-	err = sqlite.DeleteAllUserSessions(db, user.ID)
+	err = sqlite.DeleteAllUserSessions(db, userID)
 	if err != nil {
-		log.Printf("Warning: Failed to delete existing sessions for user %s: %v", user.ID, err)
+		log.Printf("Warning: Failed to delete existing sessions for user %s: %v", userID, err)
 		// Continue anyway - this is not critical for login to succeed
 	}
 
 	// Create new session in database (this will be the only active session)
-	sessionID, err := sqlite.CreateSession(db, user.ID)
+	sessionID, err := sqlite.CreateSession(db, userID)
 	if err != nil {
 		utils.SendJSONError(w, "Failed to create session", http.StatusInternalServerError)
-		return
+		return false
 	}
 
 	// Set session cookie
-	http.SetCookie(w, &http.Cookie{
-		Name:     "session_id",
-		Value:    sessionID,
-		Path:     "/",
-		Expires:  time.Now().Add(24 * time.Hour),
-		HttpOnly: true,
-	})
+	utils.SetSessionCookie(w, sessionID)
+	return true
+}
 
-	utils.SendJSONResponse(w, map[string]string{"message": "Logged in"}, http.StatusOK)
+// notifyAccountLocked tells the account owner their account was locked after repeated failed logins
+func notifyAccountLocked(db *sql.DB, userID, clientIP string) {
+	message := fmt.Sprintf(
+		"Your account was temporarily locked for %d minutes after too many failed login attempts (last attempt from %s). If this wasn't you, consider changing your password.",
+		int(utils.AccountThrottle.LockDuration.Minutes()), clientIP,
+	)
+	if err := sqlite.CreateNotification(db, userID, "security", message, ""); err != nil {
+		log.Printf("Warning: Failed to notify user %s of account lockout: %v", userID, err)
+	}
+	log.Printf("🔒 Account %s locked after repeated failed logins (last from %s)", userID, clientIP)
 }
 
 func GetUser(db *sql.DB, w http.ResponseWriter, r *http.Request) {
@@ -294,6 +409,67 @@ func GetUser(db *sql.DB, w http.ResponseWriter, r *http.Request) {
 	utils.SendJSONResponse(w, user, http.StatusOK)
 }
 
+// ChangePassword replaces the current user's password after checking the
+// current one. Every other session is logged out.
+func ChangePassword(db *sql.DB, w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var request struct {
+		CurrentPassword string `json:"current_password"`
+		NewPassword     string `json:"new_password"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		http.Error(w, "Invalid request data", http.StatusBadRequest)
+		return
+	}
+
+	userID, err := utils.GetUserIDFromSession(db, r)
+	if err != nil || userID == "" {
+		utils.SendJSONError(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	user, err := sqlite.GetUserByID(db, userID)
+	if err != nil {
+		utils.SendJSONError(w, "User not found", http.StatusNotFound)
+		return
+	}
+	if !utils.CheckPasswordHash(request.CurrentPassword, user.PasswordHash) {
+		utils.SendJSONError(w, "Current password is incorrect", http.StatusForbidden)
+		return
+	}
+	if request.NewPassword == request.CurrentPassword {
+		utils.SendJSONError(w, "New password must be different from the current one", http.StatusBadRequest)
+		return
+	}
+	if err := utils.ValidatePassword(request.NewPassword, user.Username, user.Email); err != nil {
+		utils.SendPasswordError(w, err)
+		return
+	}
+
+	hash, err := utils.HashPassword(request.NewPassword)
+	if err != nil {
+		utils.SendJSONError(w, "Error hashing password", http.StatusInternalServerError)
+		return
+	}
+	if err := sqlite.UpdateUserPasswordHash(db, userID, hash); err != nil {
+		utils.SendJSONError(w, "Database error", http.StatusInternalServerError)
+		return
+	}
+	if err := sqlite.CreateNotification(db, userID, "security", "Your password was changed. Other devices were logged out.", ""); err != nil {
+		log.Printf("Warning: Failed to notify user %s: %v", userID, err)
+	}
+
+	// Replaces every existing session with a new one for this browser
+	if !startSession(db, w, userID) {
+		return
+	}
+	utils.SendJSONResponse(w, map[string]string{"message": "Password changed"}, http.StatusOK)
+}
+
 func LogoutUser(db *sql.DB, w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodPost {
 		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
@@ -301,7 +477,7 @@ func LogoutUser(db *sql.DB, w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Get session cookie - if no cookie, still return success (graceful logout)
-	sessionCookie, err := r.Cookie("session_id")
+	sessionCookie, err := r.Cookie(utils.SessionCookieName)
 	if err != nil {
 		// No session cookie, but still return success
 		utils.SendJSONResponse(w, map[string]string{"message": "Logged out"}, http.StatusOK)
@@ -316,16 +492,29 @@ func LogoutUser(db *sql.DB, w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Clear session cookie
-	http.SetCookie(w, &http.Cookie{
-		Name:   "session_id",
-		Value:  "",
-		Path:   "/",
-		MaxAge: -1,
-	})
+	utils.ClearSessionCookie(w)
 
 	utils.SendJSONResponse(w, map[string]string{"message": "Logged out"}, http.StatusOK)
 }
 
+// GetCSRFToken issues the CSRF token for the current session. The SPA sends
+// it back in the X-CSRF-Token header on POST/PUT/DELETE requests. Without a
+// session there is nothing to protect and the token is empty.
+func GetCSRFToken(db *sql.DB, w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	token := ""
+	if sessionCookie, err := r.Cookie(utils.SessionCookieName); err == nil && sessionCookie.Value != "" {
+		token = middleware.CSRFToken(sessionCookie.Value)
+	}
+
+	w.Header().Set("Cache-Control", "no-store")
+	utils.SendJSONResponse(w, map[string]string{"csrf_token": token}, http.StatusOK)
+}
+
 func RequireAuth(db *sql.DB, w http.ResponseWriter, r *http.Request) (string, bool) {
 	userID, err := utils.GetUserIDFromSession(db, r)
 	// log.Printf("checking more errors: %v\n", err)
@@ -11,10 +11,12 @@ import (
 	"strings"
 	"testing"
 
+	"forum/models"
 	"forum/sqlite"
 	"forum/utils"
 
 	_ "github.com/mattn/go-sqlite3"
+	"golang.org/x/crypto/bcrypt"
 )
 
 // setupTestDB creates an in-memory SQLite database for testing
@@ -38,6 +40,10 @@ func setupTestDB(t *testing.T) *sql.DB {
 		email TEXT UNIQUE NOT NULL,
 		password_hash TEXT NOT NULL,
 		avatar_url TEXT DEFAULT '/static/default-avatar.png',
+		role TEXT NOT NULL DEFAULT 'user',
+		status TEXT NOT NULL DEFAULT 'active',
+		invited_by TEXT,
+		invite_code TEXT,
 		created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
 		updated_at DATETIME DEFAULT CURRENT_TIMESTAMP
 	);
@@ -48,6 +54,110 @@ func setupTestDB(t *testing.T) *sql.DB {
 		created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
 		FOREIGN KEY (user_id) REFERENCES users(id)
 	);
+
+	CREATE TABLE login_failures (
+		subject TEXT PRIMARY KEY,
+		failures INTEGER NOT NULL DEFAULT 0,
+		last_failure_at DATETIME,
+		locked_until DATETIME
+	);
+
+	CREATE TABLE notifications (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		user_id TEXT NOT NULL,
+		type TEXT NOT NULL,
+		message TEXT NOT NULL,
+		link TEXT DEFAULT '',
+		is_read BOOLEAN NOT NULL DEFAULT 0,
+		created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+		FOREIGN KEY (user_id) REFERENCES users(id)
+	);
+
+	CREATE TABLE notification_preferences (
+		user_id TEXT NOT NULL,
+		type TEXT NOT NULL,
+		enabled BOOLEAN NOT NULL,
+		PRIMARY KEY (user_id, type),
+		FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+	);
+
+	CREATE TABLE user_totp (
+		user_id TEXT PRIMARY KEY,
+		secret TEXT NOT NULL,
+		enabled BOOLEAN NOT NULL DEFAULT 0,
+		last_used_step INTEGER NOT NULL DEFAULT 0,
+		created_at DATETIME DEFAULT CURRENT_TIMESTAMP
+	);
+
+	CREATE TABLE recovery_codes (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		user_id TEXT NOT NULL,
+		code_hash TEXT NOT NULL,
+		used_at DATETIME
+	);
+
+	CREATE TABLE pending_logins (
+		token TEXT PRIMARY KEY,
+		user_id TEXT NOT NULL,
+		attempts INTEGER NOT NULL DEFAULT 0,
+		expires_at DATETIME NOT NULL
+	);
+
+	CREATE TABLE webauthn_credentials (
+		id TEXT PRIMARY KEY,
+		user_id TEXT NOT NULL,
+		public_key BLOB NOT NULL,
+		sign_count INTEGER NOT NULL DEFAULT 0,
+		name TEXT NOT NULL DEFAULT '',
+		created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+		last_used_at DATETIME
+	);
+
+	CREATE TABLE user_identities (
+		provider TEXT NOT NULL,
+		subject TEXT NOT NULL,
+		user_id TEXT NOT NULL,
+		email TEXT NOT NULL DEFAULT '',
+		created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+		PRIMARY KEY (provider, subject)
+	);
+
+	CREATE TABLE oidc_logins (
+		state TEXT PRIMARY KEY,
+		provider TEXT NOT NULL,
+		nonce TEXT NOT NULL,
+		code_verifier TEXT NOT NULL,
+		expires_at DATETIME NOT NULL
+	);
+
+	CREATE TABLE webauthn_ceremonies (
+		token TEXT PRIMARY KEY,
+		user_id TEXT,
+		ceremony TEXT NOT NULL,
+		challenge TEXT NOT NULL,
+		expires_at DATETIME NOT NULL
+	);
+
+	CREATE TABLE api_tokens (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		user_id TEXT NOT NULL,
+		name TEXT NOT NULL,
+		token_hash TEXT NOT NULL UNIQUE,
+		prefix TEXT NOT NULL,
+		scopes TEXT NOT NULL,
+		expires_at DATETIME NOT NULL,
+		last_used_at DATETIME,
+		created_at DATETIME DEFAULT CURRENT_TIMESTAMP
+	);
+
+	CREATE TABLE invites (
+		code TEXT PRIMARY KEY,
+		created_by TEXT NOT NULL,
+		max_uses INTEGER NOT NULL DEFAULT 1,
+		uses INTEGER NOT NULL DEFAULT 0,
+		expires_at DATETIME NOT NULL,
+		created_at DATETIME DEFAULT CURRENT_TIMESTAMP
+	);
 	`
 
 	_, err = db.Exec(schema)
@@ -77,7 +187,7 @@ func TestRegisterUser(t *testing.T) {
 		// Add form fields
 		writer.WriteField("username", "testuser")
 		writer.WriteField("email", "test@example.com")
-		writer.WriteField("password", "password123")
+		writer.WriteField("password", "Tr4il-mix-sunset")
 
 		writer.Close()
 
@@ -145,7 +255,7 @@ func TestRegisterUser(t *testing.T) {
 		writer := multipart.NewWriter(&buf)
 		writer.WriteField("username", "ab") // Too short
 		writer.WriteField("email", "test2@example.com")
-		writer.WriteField("password", "password123")
+		writer.WriteField("password", "Tr4il-mix-sunset")
 		writer.Close()
 
 		req := httptest.NewRequest("POST", "/register", &buf)
@@ -164,7 +274,7 @@ func TestRegisterUser(t *testing.T) {
 		writer := multipart.NewWriter(&buf)
 		writer.WriteField("username", "testuser3")
 		writer.WriteField("email", "invalid-email") // Invalid format
-		writer.WriteField("password", "password123")
+		writer.WriteField("password", "Tr4il-mix-sunset")
 		writer.Close()
 
 		req := httptest.NewRequest("POST", "/register", &buf)
@@ -184,7 +294,7 @@ func TestRegisterUser(t *testing.T) {
 		writer1 := multipart.NewWriter(&buf1)
 		writer1.WriteField("username", "duplicate")
 		writer1.WriteField("email", "first@example.com")
-		writer1.WriteField("password", "password123")
+		writer1.WriteField("password", "Tr4il-mix-sunset")
 		writer1.Close()
 
 		req1 := httptest.NewRequest("POST", "/register", &buf1)
@@ -202,7 +312,7 @@ func TestRegisterUser(t *testing.T) {
 		writer2 := multipart.NewWriter(&buf2)
 		writer2.WriteField("username", "duplicate")
 		writer2.WriteField("email", "second@example.com")
-		writer2.WriteField("password", "password123")
+		writer2.WriteField("password", "Tr4il-mix-sunset")
 		writer2.Close()
 
 		req2 := httptest.NewRequest("POST", "/register", &buf2)
@@ -360,6 +470,152 @@ func TestLoginUser(t *testing.T) {
 	})
 }
 
+func TestLoginLockout(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	// Lock after three failures and skip backoff so the test doesn't wait
+	originalAccount, originalIP := utils.AccountThrottle, utils.IPThrottle
+	defer func() { utils.AccountThrottle, utils.IPThrottle = originalAccount, originalIP }()
+	utils.AccountThrottle.FreeAttempts = 10
+	utils.AccountThrottle.LockAfter = 3
+	utils.IPThrottle.FreeAttempts = 100
+	utils.IPThrottle.LockAfter = 100
+
+	passwordHash, _ := utils.HashPassword("password123")
+	if err := sqlite.CreateUser(db, "lockme", "lockme@example.com", passwordHash, ""); err != nil {
+		t.Fatalf("Failed to create test user: %v", err)
+	}
+	user, _ := sqlite.GetUserByUsername(db, "lockme")
+
+	login := func(username, password string) *httptest.ResponseRecorder {
+		jsonData, _ := json.Marshal(map[string]string{"username": username, "password": password})
+		req := httptest.NewRequest("POST", "/login", bytes.NewBuffer(jsonData))
+		w := httptest.NewRecorder()
+		LoginUser(db, w, req)
+		return w
+	}
+
+	for _, username := range []string{"lockme", "nosuchuser"} {
+		t.Run(username, func(t *testing.T) {
+			for i := 0; i < 3; i++ {
+				if w := login(username, "wrongpassword"); w.Code != http.StatusUnauthorized {
+					t.Fatalf("Attempt %d: expected %d, got %d", i+1, http.StatusUnauthorized, w.Code)
+				}
+			}
+
+			// Locked now, even with the right password
+			w := login(username, "password123")
+			if w.Code != http.StatusTooManyRequests {
+				t.Fatalf("Expected %d after lockout, got %d", http.StatusTooManyRequests, w.Code)
+			}
+			if w.Header().Get("Retry-After") == "" {
+				t.Error("Expected Retry-After header")
+			}
+		})
+	}
+
+	t.Run("owner is notified", func(t *testing.T) {
+		notifications, err := sqlite.GetNotifications(db, user.ID, 1, 10)
+		if err != nil {
+			t.Fatalf("Failed to get notifications: %v", err)
+		}
+		if len(notifications) != 1 || notifications[0].Type != "security" {
+			t.Fatalf("Expected one security notification, got %+v", notifications)
+		}
+	})
+
+	t.Run("lockout survives in the database", func(t *testing.T) {
+		failure, err := sqlite.GetLoginFailure(db, utils.AccountLoginKey(user.ID, ""))
+		if err != nil {
+			t.Fatalf("Failed to read lockout: %v", err)
+		}
+		if failure.LockedUntil.IsZero() {
+			t.Error("Expected locked_until to be stored")
+		}
+	})
+}
+
+func TestLoginRehashesPassword(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	// A bcrypt hash from before passwords were hashed with argon2id
+	legacy, _ := bcrypt.GenerateFromPassword([]byte("password123"), bcrypt.MinCost)
+	if err := sqlite.CreateUser(db, "legacy", "legacy@example.com", string(legacy), ""); err != nil {
+		t.Fatalf("Failed to create test user: %v", err)
+	}
+
+	jsonData, _ := json.Marshal(map[string]string{"username": "legacy", "password": "password123"})
+	w := httptest.NewRecorder()
+	LoginUser(db, w, httptest.NewRequest("POST", "/login", bytes.NewBuffer(jsonData)))
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected %d, got %d: %s", http.StatusOK, w.Code, w.Body.String())
+	}
+
+	user, _ := sqlite.GetUserByUsername(db, "legacy")
+	if !strings.HasPrefix(user.PasswordHash, "$"+utils.PasswordParams.Algorithm+"$") {
+		t.Fatalf("Expected the hash to be upgraded, got %q", user.PasswordHash)
+	}
+	if match, needsRehash := utils.VerifyPassword("password123", user.PasswordHash); !match || needsRehash {
+		t.Errorf("Expected the new hash to be current, got match=%v needsRehash=%v", match, needsRehash)
+	}
+}
+
+func TestChangePassword(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	passwordHash, _ := utils.HashPassword("Tr4il-mix-sunset")
+	if err := sqlite.CreateUser(db, "changer", "changer@example.com", passwordHash, ""); err != nil {
+		t.Fatalf("Failed to create test user: %v", err)
+	}
+	user, _ := sqlite.GetUserByUsername(db, "changer")
+	otherSession, _ := sqlite.CreateSession(db, user.ID)
+
+	change := func(current, next string) *httptest.ResponseRecorder {
+		sessionID, _ := sqlite.CreateSession(db, user.ID)
+		jsonData, _ := json.Marshal(map[string]string{"current_password": current, "new_password": next})
+		req := httptest.NewRequest("POST", "/api/user/password", bytes.NewBuffer(jsonData))
+		req.AddCookie(&http.Cookie{Name: utils.SessionCookieName, Value: sessionID})
+		w := httptest.NewRecorder()
+		ChangePassword(db, w, req)
+		return w
+	}
+
+	t.Run("wrong current password", func(t *testing.T) {
+		if w := change("nope-nope-1", "Quiet-river-42x"); w.Code != http.StatusForbidden {
+			t.Errorf("Expected %d, got %d", http.StatusForbidden, w.Code)
+		}
+	})
+
+	t.Run("weak new password", func(t *testing.T) {
+		w := change("Tr4il-mix-sunset", "changer123")
+		if w.Code != http.StatusBadRequest {
+			t.Fatalf("Expected %d, got %d", http.StatusBadRequest, w.Code)
+		}
+		var response map[string]any
+		json.Unmarshal(w.Body.Bytes(), &response)
+		if _, ok := response["password_strength"]; !ok {
+			t.Errorf("Expected a strength estimate, got %v", response)
+		}
+	})
+
+	t.Run("success", func(t *testing.T) {
+		w := change("Tr4il-mix-sunset", "Quiet-river-42x")
+		if w.Code != http.StatusOK {
+			t.Fatalf("Expected %d, got %d: %s", http.StatusOK, w.Code, w.Body.String())
+		}
+		updated, _ := sqlite.GetUserByUsername(db, "changer")
+		if !utils.CheckPasswordHash("Quiet-river-42x", updated.PasswordHash) {
+			t.Error("Expected the new password to be stored")
+		}
+		if userID, _ := sqlite.GetUserIDFromSession(db, otherSession); userID != "" {
+			t.Error("Expected other sessions to be logged out")
+		}
+	})
+}
+
 func TestLogoutUser(t *testing.T) {
 	db := setupTestDB(t)
 	defer db.Close()
@@ -560,7 +816,7 @@ func TestAuthHandlersIntegration(t *testing.T) {
 
 		username := "integrationuser"
 		email := "integration@example.com"
-		password := "password123"
+		password := "Tr4il-mix-sunset"
 
 		// 1. Register user
 		var regBuf bytes.Buffer
@@ -651,3 +907,28 @@ func TestAuthHandlersIntegration(t *testing.T) {
 		}
 	})
 }
+
+func TestStartSessionRefusesPendingUsers(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	sqlite.CreateUser(db, "waiting", "waiting@example.com", "hash", "")
+	user, _ := sqlite.GetUserByUsername(db, "waiting")
+	db.Exec(`UPDATE users SET status = ? WHERE id = ?`, models.StatusPending, user.ID)
+
+	// Passkey, OpenID Connect and two-factor logins all end here
+	w := httptest.NewRecorder()
+	if startSession(db, w, user.ID) || w.Code != http.StatusForbidden {
+		t.Errorf("Expected a pending user to be refused a session, got %d", w.Code)
+	}
+	var sessions int
+	db.QueryRow(`SELECT COUNT(*) FROM sessions WHERE user_id = ?`, user.ID).Scan(&sessions)
+	if sessions != 0 || len(w.Result().Cookies()) != 0 {
+		t.Errorf("Expected no session, got %d and cookies %v", sessions, w.Result().Cookies())
+	}
+
+	db.Exec(`UPDATE users SET status = ? WHERE id = ?`, models.StatusActive, user.ID)
+	if w := httptest.NewRecorder(); !startSession(db, w, user.ID) {
+		t.Errorf("Expected an active user to get a session, got %d", w.Code)
+	}
+}
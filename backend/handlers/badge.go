@@ -0,0 +1,140 @@
+package handlers
+
+import (
+	"database/sql"
+	"fmt"
+	"log"
+	"net/http"
+
+	"forum/badges"
+	"forum/models"
+	"forum/sqlite"
+	"forum/utils"
+)
+
+// badgeRules are the badges users can earn, from BADGES_FILE or the
+// defaults
+var badgeRules = loadBadgeRules()
+
+func loadBadgeRules() []badges.Rule {
+	rules, err := badges.FromEnv()
+	if err != nil {
+		log.Printf("❌ Badges not loaded, using the defaults: %v", err)
+	}
+	return rules
+}
+
+// checkBadges awards a user the badges they now meet the rules of after
+// something they did or received. Failures are logged, as the action
+// itself succeeded; the background job catches up later.
+func checkBadges(db *sql.DB, userID string) {
+	awarded, err := sqlite.AwardBadges(db, badgeRules, userID)
+	if err != nil {
+		log.Printf("Warning: Failed to award badges to user %s: %v", userID, err)
+		return
+	}
+	if awarded > 0 {
+		notifyBadges(db)
+	}
+}
+
+// AwardAllBadges checks every user against the badge rules, for the
+// background job, and returns how many badges were awarded
+func AwardAllBadges(db *sql.DB) (int, error) {
+	awarded, err := sqlite.AwardBadges(db, badgeRules, "")
+	if err != nil {
+		return 0, err
+	}
+	notifyBadges(db)
+	return awarded, nil
+}
+
+// notifyBadges tells users about badges they weren't told about yet
+func notifyBadges(db *sql.DB) {
+	awards, err := sqlite.TakeBadgeNotifications(db)
+	if err != nil {
+		log.Printf("Warning: Failed to read badge awards: %v", err)
+		return
+	}
+	for _, award := range awards {
+		rule, ok := badges.Find(badgeRules, award.BadgeID)
+		if !ok {
+			continue
+		}
+		message := fmt.Sprintf("You earned the %q badge.", rule.Name)
+		if err := sqlite.CreateNotification(db, award.UserID, "badge", message, "/user/"+award.Username); err != nil {
+			log.Printf("Warning: Failed to notify user %s: %v", award.UserID, err)
+		}
+	}
+}
+
+// GetBadges lists every badge with how many users hold it
+func GetBadges(db *sql.DB, w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	holders, err := sqlite.CountBadgeHolders(db)
+	if err != nil {
+		utils.SendJSONError(w, "Failed to fetch badges", http.StatusInternalServerError)
+		return
+	}
+	list := make([]models.Badge, 0, len(badgeRules))
+	for _, rule := range badgeRules {
+		badge := badgeFromRule(rule)
+		badge.Holders = holders[rule.ID]
+		list = append(list, badge)
+	}
+	utils.SendJSONResponse(w, list, http.StatusOK)
+}
+
+// GetUserBadges lists the badges ?username= earned, in the order badges
+// are declared. Awards of badges no longer declared are left out.
+func GetUserBadges(db *sql.DB, w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	profile, err := sqlite.GetPublicProfile(db, r.URL.Query().Get("username"))
+	if err == sql.ErrNoRows {
+		utils.SendJSONError(w, "User not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		utils.SendJSONError(w, "Database error", http.StatusInternalServerError)
+		return
+	}
+
+	awarded, err := sqlite.GetUserBadges(db, profile.ID)
+	if err != nil {
+		utils.SendJSONError(w, "Failed to fetch badges", http.StatusInternalServerError)
+		return
+	}
+	holders, err := sqlite.CountBadgeHolders(db)
+	if err != nil {
+		utils.SendJSONError(w, "Failed to fetch badges", http.StatusInternalServerError)
+		return
+	}
+	list := []models.Badge{}
+	for _, rule := range badgeRules {
+		if at, ok := awarded[rule.ID]; ok {
+			badge := badgeFromRule(rule)
+			badge.Holders = holders[rule.ID]
+			badge.AwardedAt = &at
+			list = append(list, badge)
+		}
+	}
+	utils.SendJSONResponse(w, list, http.StatusOK)
+}
+
+func badgeFromRule(rule badges.Rule) models.Badge {
+	return models.Badge{
+		ID:          rule.ID,
+		Name:        rule.Name,
+		Description: rule.Description,
+		Metric:      rule.Metric,
+		Threshold:   rule.Threshold,
+	}
+}
@@ -0,0 +1,86 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"forum/badges"
+	"forum/models"
+	"forum/sqlite"
+)
+
+func TestBadges(t *testing.T) {
+	db := setupPostTestDB(t)
+	defer db.Close()
+
+	saved := badgeRules
+	defer func() { badgeRules = saved }()
+	badgeRules = []badges.Rule{
+		{ID: "first-post", Name: "First Post", Metric: badges.MetricPosts, Threshold: 1},
+		{ID: "liked", Name: "Liked", Metric: badges.MetricLikesReceived, Threshold: 2},
+		{ID: "veteran", Name: "Veteran", Metric: badges.MetricMemberDays, Threshold: 30},
+	}
+
+	users, _ := createTestUsers(t, db, "alice", "bob", "carol")
+	userBadges := func(name string) []models.Badge {
+		req := httptest.NewRequest("GET", "/api/users/badges?username="+name, nil)
+		w := httptest.NewRecorder()
+		GetUserBadges(db, w, req)
+		var list []models.Badge
+		json.Unmarshal(w.Body.Bytes(), &list)
+		return list
+	}
+
+	post, _ := sqlite.CreatePost(db, users["alice"], []int{}, "Hello", "World", "", models.ContentPublished)
+	checkBadges(db, users["alice"])
+	checkBadges(db, users["alice"])
+	if got := userBadges("alice"); len(got) != 1 || got[0].ID != "first-post" || got[0].AwardedAt == nil {
+		t.Fatalf("Expected alice to earn first-post, got %+v", got)
+	}
+	notifications, _ := sqlite.GetNotifications(db, users["alice"], 1, 10)
+	if len(notifications) != 1 || notifications[0].Type != "badge" || notifications[0].Link != "/user/alice" {
+		t.Errorf("Expected one badge notification, got %+v", notifications)
+	}
+
+	// Likes from others count, one's own don't
+	for _, name := range []string{"alice", "bob"} {
+		sqlite.ToggleLike(db, users[name], &post.ID, nil, "like")
+	}
+	checkBadges(db, users["alice"])
+	if got := userBadges("alice"); len(got) != 1 {
+		t.Errorf("Expected self-likes not counted, got %+v", got)
+	}
+	sqlite.ToggleLike(db, users["carol"], &post.ID, nil, "like")
+	checkBadges(db, users["alice"])
+	if got := userBadges("alice"); len(got) != 2 || got[1].ID != "liked" {
+		t.Errorf("Expected alice to earn liked, got %+v", got)
+	}
+
+	// Badges stay once earned, and the job catches what events don't
+	sqlite.ToggleLike(db, users["carol"], &post.ID, nil, "like")
+	db.Exec(`UPDATE users SET created_at = datetime('now', '-40 days') WHERE username = 'bob'`)
+	if awarded, err := AwardAllBadges(db); err != nil || awarded != 1 {
+		t.Errorf("Expected the job to award bob veteran, got %d, %v", awarded, err)
+	}
+	if got := userBadges("alice"); len(got) != 2 {
+		t.Errorf("Expected alice to keep her badges, got %+v", got)
+	}
+
+	req := httptest.NewRequest("GET", "/api/badges", nil)
+	w := httptest.NewRecorder()
+	GetBadges(db, w, req)
+	var all []models.Badge
+	json.Unmarshal(w.Body.Bytes(), &all)
+	if w.Code != http.StatusOK || len(all) != 3 || all[0].Holders != 1 || all[1].Holders != 1 || all[2].Holders != 1 {
+		t.Errorf("Unexpected badges %d %+v", w.Code, all)
+	}
+
+	req = httptest.NewRequest("GET", "/api/users/badges?username=nobody", nil)
+	w = httptest.NewRecorder()
+	GetUserBadges(db, w, req)
+	if w.Code != http.StatusNotFound {
+		t.Errorf("Expected unknown users refused, got %d", w.Code)
+	}
+}
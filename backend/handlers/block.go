@@ -0,0 +1,217 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"net/http"
+
+	"forum/models"
+	"forum/sqlite"
+	"forum/utils"
+)
+
+// replyAllowed checks that the author of the post (contentType post) or
+// comment (contentType comment) being replied to didn't block the user,
+// writing the error response if they did
+func replyAllowed(db *sql.DB, w http.ResponseWriter, userID, contentType string, contentID int) bool {
+	blocked, err := sqlite.BlockedFromReplying(db, userID, contentType, contentID)
+	if err != nil {
+		utils.SendJSONError(w, "Database error", http.StatusInternalServerError)
+		return false
+	}
+	if blocked {
+		utils.SendJSONError(w, "You can't reply to this user", http.StatusForbidden)
+		return false
+	}
+	return true
+}
+
+// userAction reads the {"username"} a block or mute request is about and
+// the current user, writing the error response if either is missing
+func userAction(db *sql.DB, w http.ResponseWriter, r *http.Request, method string) (userID string, other models.User, ok bool) {
+	if r.Method != method {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return "", models.User{}, false
+	}
+
+	var request struct {
+		Username string `json:"username"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil || request.Username == "" {
+		http.Error(w, "Invalid request data", http.StatusBadRequest)
+		return "", models.User{}, false
+	}
+
+	userID, err := utils.GetUserIDFromSession(db, r)
+	if err != nil || userID == "" {
+		utils.SendJSONError(w, "Unauthorized", http.StatusUnauthorized)
+		return "", models.User{}, false
+	}
+
+	other, err = sqlite.GetUserByUsername(db, request.Username)
+	if err == sql.ErrNoRows {
+		utils.SendJSONError(w, "User not found", http.StatusNotFound)
+		return "", models.User{}, false
+	}
+	if err != nil {
+		utils.SendJSONError(w, "Database error", http.StatusInternalServerError)
+		return "", models.User{}, false
+	}
+	if other.ID == userID {
+		utils.SendJSONError(w, "You can't do this to yourself", http.StatusBadRequest)
+		return "", models.User{}, false
+	}
+	return userID, other, true
+}
+
+// categoryAction reads the {"category_id"} a mute request is about and the
+// current user, writing the error response if either is missing
+func categoryAction(db *sql.DB, w http.ResponseWriter, r *http.Request, method string) (userID string, categoryID int, ok bool) {
+	if r.Method != method {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return "", 0, false
+	}
+
+	var request struct {
+		CategoryID int `json:"category_id"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil || request.CategoryID <= 0 {
+		http.Error(w, "Invalid request data", http.StatusBadRequest)
+		return "", 0, false
+	}
+
+	userID, err := utils.GetUserIDFromSession(db, r)
+	if err != nil || userID == "" {
+		utils.SendJSONError(w, "Unauthorized", http.StatusUnauthorized)
+		return "", 0, false
+	}
+	return userID, request.CategoryID, true
+}
+
+// sendAdded answers a block or mute: 201 when it is new, 200 otherwise
+func sendAdded(w http.ResponseWriter, created bool, err error, notFound, failed, message string) {
+	if err == sql.ErrNoRows {
+		utils.SendJSONError(w, notFound, http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		utils.SendJSONError(w, failed, http.StatusInternalServerError)
+		return
+	}
+	status := http.StatusOK
+	if created {
+		status = http.StatusCreated
+	}
+	utils.SendJSONResponse(w, map[string]string{"message": message}, status)
+}
+
+// sendRemoved answers an unblock or unmute, 404 if there was nothing to lift
+func sendRemoved(w http.ResponseWriter, err error, notFound, failed, message string) {
+	if err == sql.ErrNoRows {
+		utils.SendJSONError(w, notFound, http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		utils.SendJSONError(w, failed, http.StatusInternalServerError)
+		return
+	}
+	utils.SendJSONResponse(w, map[string]string{"message": message}, http.StatusOK)
+}
+
+// BlockUser blocks a user for the current user: their content is hidden
+// from the current user, they can't reply to the current user's posts and
+// comments, and follows between them end
+func BlockUser(db *sql.DB, w http.ResponseWriter, r *http.Request) {
+	userID, other, ok := userAction(db, w, r, http.MethodPost)
+	if !ok {
+		return
+	}
+	created, err := sqlite.BlockUser(db, userID, other.ID)
+	sendAdded(w, created, err, "User not found", "Failed to block user", "Blocked "+other.Username)
+}
+
+// UnblockUser lifts the current user's block of a user
+func UnblockUser(db *sql.DB, w http.ResponseWriter, r *http.Request) {
+	userID, other, ok := userAction(db, w, r, http.MethodDelete)
+	if !ok {
+		return
+	}
+	err := sqlite.UnblockUser(db, userID, other.ID)
+	sendRemoved(w, err, "You haven't blocked this user", "Failed to unblock user", "Unblocked "+other.Username)
+}
+
+// MuteUser hides a user's content from the current user's lists and feeds
+func MuteUser(db *sql.DB, w http.ResponseWriter, r *http.Request) {
+	userID, other, ok := userAction(db, w, r, http.MethodPost)
+	if !ok {
+		return
+	}
+	created, err := sqlite.MuteUser(db, userID, other.ID)
+	sendAdded(w, created, err, "User not found", "Failed to mute user", "Muted "+other.Username)
+}
+
+// UnmuteUser lifts the current user's mute of a user
+func UnmuteUser(db *sql.DB, w http.ResponseWriter, r *http.Request) {
+	userID, other, ok := userAction(db, w, r, http.MethodDelete)
+	if !ok {
+		return
+	}
+	err := sqlite.UnmuteUser(db, userID, other.ID)
+	sendRemoved(w, err, "You haven't muted this user", "Failed to unmute user", "Unmuted "+other.Username)
+}
+
+// MuteCategory hides the posts in a category from the current user's
+// lists and feeds
+func MuteCategory(db *sql.DB, w http.ResponseWriter, r *http.Request) {
+	userID, categoryID, ok := categoryAction(db, w, r, http.MethodPost)
+	if !ok {
+		return
+	}
+	created, err := sqlite.MuteCategory(db, userID, categoryID)
+	sendAdded(w, created, err, "Category not found", "Failed to mute category", "Muted category")
+}
+
+// UnmuteCategory lifts the current user's mute of a category
+func UnmuteCategory(db *sql.DB, w http.ResponseWriter, r *http.Request) {
+	userID, categoryID, ok := categoryAction(db, w, r, http.MethodDelete)
+	if !ok {
+		return
+	}
+	err := sqlite.UnmuteCategory(db, userID, categoryID)
+	sendRemoved(w, err, "You haven't muted this category", "Failed to unmute category", "Unmuted category")
+}
+
+// GetBlockedUsers lists the users the current user blocked
+func GetBlockedUsers(db *sql.DB, w http.ResponseWriter, r *http.Request) {
+	listHidden(db, w, r, sqlite.GetBlockedUsers)
+}
+
+// GetMutedUsers lists the users the current user muted
+func GetMutedUsers(db *sql.DB, w http.ResponseWriter, r *http.Request) {
+	listHidden(db, w, r, sqlite.GetMutedUsers)
+}
+
+// GetMutedCategories lists the categories the current user muted
+func GetMutedCategories(db *sql.DB, w http.ResponseWriter, r *http.Request) {
+	listHidden(db, w, r, sqlite.GetMutedCategories)
+}
+
+func listHidden[T any](db *sql.DB, w http.ResponseWriter, r *http.Request, list func(*sql.DB, string) ([]T, error)) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	userID, err := utils.GetUserIDFromSession(db, r)
+	if err != nil || userID == "" {
+		utils.SendJSONError(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	items, err := list(db, userID)
+	if err != nil {
+		utils.SendJSONError(w, "Database error", http.StatusInternalServerError)
+		return
+	}
+	utils.SendJSONResponse(w, items, http.StatusOK)
+}
@@ -0,0 +1,124 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"forum/models"
+	"forum/sqlite"
+)
+
+func TestBlocksAndMutes(t *testing.T) {
+	db := setupPostTestDB(t)
+	defer db.Close()
+
+	users, sessions := createTestUsers(t, db, "alice", "bob", "carol")
+
+	alice := models.Viewer{UserID: users["alice"]}
+	listed := func() map[string]bool {
+		posts, err := sqlite.GetPosts(db, alice, models.PostFilter{}, 1, 20)
+		if err != nil {
+			t.Fatalf("GetPosts: %v", err)
+		}
+		titles := map[string]bool{}
+		for _, p := range posts {
+			titles[p.Title] = true
+		}
+		return titles
+	}
+
+	sqlite.CreateCategory(db, "Cooking")
+	var cooking int
+	db.QueryRow(`SELECT id FROM categories WHERE name = 'Cooking'`).Scan(&cooking)
+
+	byAlice, _ := sqlite.CreatePost(db, users["alice"], []int{}, "By Alice", "Hello", "", models.ContentPublished)
+	byBob, _ := sqlite.CreatePost(db, users["bob"], []int{}, "By Bob", "Hello", "", models.ContentPublished)
+	sqlite.CreatePost(db, users["carol"], []int{cooking}, "Pancakes", "Recipe", "", models.ContentPublished)
+	byCarol, _ := sqlite.CreatePost(db, users["carol"], []int{}, "By Carol", "Hello", "", models.ContentPublished)
+	aliceComment, _ := sqlite.CreateComment(db, users["alice"], byCarol.ID, "First", models.ContentPublished)
+	sqlite.CreateComment(db, users["bob"], byCarol.ID, "Second", models.ContentPublished)
+	sqlite.FollowUser(db, users["alice"], users["bob"])
+	sqlite.FollowUser(db, users["bob"], users["alice"])
+
+	tests := []struct {
+		name    string
+		handler func(*sql.DB, http.ResponseWriter, *http.Request)
+		method  string
+		body    map[string]any
+		want    int
+	}{
+		{"block", BlockUser, "POST", map[string]any{"username": "bob"}, http.StatusCreated},
+		{"block again", BlockUser, "POST", map[string]any{"username": "bob"}, http.StatusOK},
+		{"block self", BlockUser, "POST", map[string]any{"username": "alice"}, http.StatusBadRequest},
+		{"mute unknown", MuteUser, "POST", map[string]any{"username": "nobody"}, http.StatusNotFound},
+		{"follow blocked", FollowUser, "POST", map[string]any{"username": "bob"}, http.StatusForbidden},
+		{"unmute not muted", UnmuteUser, "DELETE", map[string]any{"username": "carol"}, http.StatusNotFound},
+		{"mute unknown category", MuteCategory, "POST", map[string]any{"category_id": 999}, http.StatusNotFound},
+	}
+	for _, tt := range tests {
+		if w := sendAs(db, tt.handler, tt.method, "/", sessions["alice"], tt.body); w.Code != tt.want {
+			t.Errorf("%s: expected %d, got %d: %s", tt.name, tt.want, w.Code, w.Body.String())
+		}
+	}
+
+	// Blocking ends follows both ways and hides Bob's content from Alice
+	var follows int
+	db.QueryRow(`SELECT COUNT(*) FROM user_follows`).Scan(&follows)
+	if follows != 0 {
+		t.Errorf("Expected blocking to end follows, %d left", follows)
+	}
+	if titles := listed(); titles["By Bob"] || !titles["By Carol"] {
+		t.Errorf("Expected Bob's post hidden, got %v", titles)
+	}
+	if _, err := sqlite.GetPost(db, alice, byBob.ID); err != sql.ErrNoRows {
+		t.Errorf("Expected a blocked author's post to be hidden, got %v", err)
+	}
+	comments, _ := sqlite.GetPostComments(db, alice, byCarol.ID)
+	if len(comments) != 1 || comments[0].ID != aliceComment.ID {
+		t.Errorf("Expected only Alice's comment, got %+v", comments)
+	}
+
+	// Bob can't reply to Alice, and his mentions don't reach her
+	if w := sendAs(db, CreateComment, "POST", "/", sessions["bob"], map[string]any{"post_id": byAlice.ID, "content": "Hi"}); w.Code != http.StatusForbidden {
+		t.Errorf("Expected 403 commenting on a blocker's post, got %d", w.Code)
+	}
+	if w := sendAs(db, CreateReplComment, "POST", "/", sessions["bob"], map[string]any{"parent_comment_id": aliceComment.ID, "content": "Hi"}); w.Code != http.StatusForbidden {
+		t.Errorf("Expected 403 replying to a blocker's comment, got %d", w.Code)
+	}
+	if w := sendAs(db, CreateComment, "POST", "/", sessions["bob"], map[string]any{"post_id": byCarol.ID, "content": "Hi @alice"}); w.Code != http.StatusCreated {
+		t.Errorf("Expected Bob to comment on Carol's post, got %d: %s", w.Code, w.Body.String())
+	}
+	var mentions int
+	db.QueryRow(`SELECT COUNT(*) FROM notifications WHERE user_id = ? AND type = 'mention'`, users["alice"]).Scan(&mentions)
+	if mentions != 0 {
+		t.Errorf("Expected no mention notification from a blocked user, got %d", mentions)
+	}
+
+	// Muting hides from lists, not from the post itself
+	sendAs(db, MuteUser, "POST", "/", sessions["alice"], map[string]any{"username": "carol"})
+	if titles := listed(); len(titles) != 1 || !titles["By Alice"] {
+		t.Errorf("Expected only Alice's post, got %v", titles)
+	}
+	if _, err := sqlite.GetPost(db, alice, byCarol.ID); err != nil {
+		t.Errorf("Expected a muted author's post to open, got %v", err)
+	}
+	sendAs(db, UnmuteUser, "DELETE", "/", sessions["alice"], map[string]any{"username": "carol"})
+	sendAs(db, MuteCategory, "POST", "/", sessions["alice"], map[string]any{"category_id": cooking})
+	if titles := listed(); titles["Pancakes"] || !titles["By Carol"] {
+		t.Errorf("Expected the muted category hidden, got %v", titles)
+	}
+
+	var blocked []models.HiddenUser
+	json.Unmarshal(sendAs(db, GetBlockedUsers, "GET", "/", sessions["alice"], nil).Body.Bytes(), &blocked)
+	if len(blocked) != 1 || blocked[0].Username != "bob" {
+		t.Errorf("Expected bob blocked, got %+v", blocked)
+	}
+	if w := sendAs(db, UnblockUser, "DELETE", "/", sessions["alice"], map[string]any{"username": "bob"}); w.Code != http.StatusOK {
+		t.Errorf("Expected 200 on unblock, got %d", w.Code)
+	}
+	if titles := listed(); !titles["By Bob"] {
+		t.Errorf("Expected Bob's post back after unblocking, got %v", titles)
+	}
+}
@@ -0,0 +1,177 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"forum/models"
+	"forum/sqlite"
+	"forum/utils"
+)
+
+const (
+	maxBookmarkFolder = 50
+	maxBookmarkNote   = 500
+)
+
+// bookmarkTarget is the post or comment a bookmark request is about
+type bookmarkTarget struct {
+	PostID    int `json:"post_id"`
+	CommentID int `json:"comment_id"`
+}
+
+// content returns the content type and ID of the target; ok is false
+// unless exactly one of post_id and comment_id was given
+func (t bookmarkTarget) content() (contentType string, contentID int, ok bool) {
+	switch {
+	case t.PostID > 0 && t.CommentID == 0:
+		return models.ContentPost, t.PostID, true
+	case t.CommentID > 0 && t.PostID == 0:
+		return models.ContentComment, t.CommentID, true
+	}
+	return "", 0, false
+}
+
+// AddBookmark bookmarks a post or comment for the current user, optionally
+// in a folder and with a note. Bookmarking it again moves it to the
+// folder and replaces the note.
+func AddBookmark(db *sql.DB, w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var request struct {
+		bookmarkTarget
+		Folder string `json:"folder"`
+		Note   string `json:"note"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		http.Error(w, "Invalid request data", http.StatusBadRequest)
+		return
+	}
+	contentType, contentID, ok := request.content()
+	if !ok {
+		utils.SendJSONError(w, "Give either a post_id or a comment_id", http.StatusBadRequest)
+		return
+	}
+	folder, note := strings.TrimSpace(request.Folder), strings.TrimSpace(request.Note)
+	if len(folder) > maxBookmarkFolder {
+		utils.SendJSONError(w, fmt.Sprintf("Folder names must be at most %d characters", maxBookmarkFolder), http.StatusBadRequest)
+		return
+	}
+	if len(note) > maxBookmarkNote {
+		utils.SendJSONError(w, fmt.Sprintf("Notes must be at most %d characters", maxBookmarkNote), http.StatusBadRequest)
+		return
+	}
+
+	userID, err := utils.GetUserIDFromSession(db, r)
+	if err != nil || userID == "" {
+		utils.SendJSONError(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	created, err := sqlite.SaveBookmark(db, viewerFor(db, userID), contentType, contentID, folder, note)
+	if err == sql.ErrNoRows {
+		utils.SendJSONError(w, "Content not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		utils.SendJSONError(w, "Failed to save bookmark", http.StatusInternalServerError)
+		return
+	}
+
+	status := http.StatusOK
+	if created {
+		status = http.StatusCreated
+	}
+	utils.SendJSONResponse(w, map[string]string{"message": "Bookmark saved"}, status)
+}
+
+// RemoveBookmark removes the current user's bookmark of a post or comment
+func RemoveBookmark(db *sql.DB, w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodDelete {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var request bookmarkTarget
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		http.Error(w, "Invalid request data", http.StatusBadRequest)
+		return
+	}
+	contentType, contentID, ok := request.content()
+	if !ok {
+		utils.SendJSONError(w, "Give either a post_id or a comment_id", http.StatusBadRequest)
+		return
+	}
+
+	userID, err := utils.GetUserIDFromSession(db, r)
+	if err != nil || userID == "" {
+		utils.SendJSONError(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	err = sqlite.DeleteBookmark(db, userID, contentType, contentID)
+	if err == sql.ErrNoRows {
+		utils.SendJSONError(w, "Bookmark not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		utils.SendJSONError(w, "Failed to remove bookmark", http.StatusInternalServerError)
+		return
+	}
+	utils.SendJSONResponse(w, map[string]string{"message": "Bookmark removed"}, http.StatusOK)
+}
+
+// GetBookmarks returns a page of the current user's bookmarks, newest
+// first, optionally only those in ?folder= (empty for those in none)
+func GetBookmarks(db *sql.DB, w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	userID, err := utils.GetUserIDFromSession(db, r)
+	if err != nil || userID == "" {
+		utils.SendJSONError(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	var folder *string
+	if query := r.URL.Query(); query.Has("folder") {
+		name := strings.TrimSpace(query.Get("folder"))
+		folder = &name
+	}
+	page, limit := utils.GetPaginationParams(r)
+	bookmarks, err := sqlite.GetBookmarks(db, viewerFor(db, userID), folder, page, limit)
+	if err != nil {
+		utils.SendJSONError(w, "Failed to fetch bookmarks", http.StatusInternalServerError)
+		return
+	}
+	utils.SendJSONResponse(w, bookmarks, http.StatusOK)
+}
+
+// GetBookmarkFolders lists the current user's bookmark folders
+func GetBookmarkFolders(db *sql.DB, w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	userID, err := utils.GetUserIDFromSession(db, r)
+	if err != nil || userID == "" {
+		utils.SendJSONError(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	folders, err := sqlite.GetBookmarkFolders(db, userID)
+	if err != nil {
+		utils.SendJSONError(w, "Failed to fetch bookmark folders", http.StatusInternalServerError)
+		return
+	}
+	utils.SendJSONResponse(w, folders, http.StatusOK)
+}
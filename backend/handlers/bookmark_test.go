@@ -0,0 +1,96 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+
+	"forum/models"
+	"forum/sqlite"
+)
+
+func TestBookmarks(t *testing.T) {
+	db := setupPostTestDB(t)
+	defer db.Close()
+
+	users, sessions := createTestUsers(t, db, "alice", "bob")
+
+	list := func(query string) []models.Bookmark {
+		w := sendAs(db, GetBookmarks, "GET", "/api/bookmarks"+query, sessions["alice"], nil)
+		var bookmarks []models.Bookmark
+		json.Unmarshal(w.Body.Bytes(), &bookmarks)
+		return bookmarks
+	}
+
+	post, _ := sqlite.CreatePost(db, users["bob"], []int{}, "Recipes", "Pancakes", "", models.ContentPublished)
+	other, _ := sqlite.CreatePost(db, users["bob"], []int{}, "Other", "Waffles", "", models.ContentPublished)
+	held, _ := sqlite.CreatePost(db, users["bob"], []int{}, "Held", "Spam", "", models.ContentPending)
+	comment, _ := sqlite.CreateComment(db, users["bob"], other.ID, "Add blueberries", models.ContentPublished)
+
+	tests := []struct {
+		name string
+		body map[string]any
+		want int
+	}{
+		{"post", map[string]any{"post_id": post.ID, "folder": "Cooking", "note": "Try on Sunday"}, http.StatusCreated},
+		{"comment", map[string]any{"comment_id": comment.ID}, http.StatusCreated},
+		{"again", map[string]any{"post_id": post.ID, "folder": "Breakfast"}, http.StatusOK},
+		{"both", map[string]any{"post_id": post.ID, "comment_id": comment.ID}, http.StatusBadRequest},
+		{"long note", map[string]any{"post_id": other.ID, "note": strings.Repeat("x", maxBookmarkNote+1)}, http.StatusBadRequest},
+		{"held post", map[string]any{"post_id": held.ID}, http.StatusNotFound},
+	}
+	for _, tt := range tests {
+		if w := sendAs(db, AddBookmark, "POST", "/", sessions["alice"], tt.body); w.Code != tt.want {
+			t.Errorf("%s: expected %d, got %d: %s", tt.name, tt.want, w.Code, w.Body.String())
+		}
+	}
+
+	got := list("")
+	if len(got) != 2 || got[0].ContentType != models.ContentComment || got[0].PostID != other.ID ||
+		got[0].Content != "Add blueberries" || got[1].Folder != "Breakfast" || got[1].Note != "" {
+		t.Errorf("Unexpected bookmarks %+v", got)
+	}
+	if got := list("?folder=Breakfast"); len(got) != 1 || got[0].PostTitle != "Recipes" {
+		t.Errorf("Expected the Breakfast folder, got %+v", got)
+	}
+	if got := list("?folder="); len(got) != 1 || got[0].CommentID == nil || *got[0].CommentID != comment.ID {
+		t.Errorf("Expected the unfiled bookmark, got %+v", got)
+	}
+	if got := list("?limit=1&page=2"); len(got) != 1 || got[0].PostID != post.ID {
+		t.Errorf("Expected paging, got %+v", got)
+	}
+
+	w := sendAs(db, GetBookmarkFolders, "GET", "/", sessions["alice"], nil)
+	var folders []models.BookmarkFolder
+	json.Unmarshal(w.Body.Bytes(), &folders)
+	if len(folders) != 1 || folders[0].Name != "Breakfast" || folders[0].Count != 1 {
+		t.Errorf("Unexpected folders %+v", folders)
+	}
+
+	// Payloads carry the flag for the viewer only
+	stored, _ := sqlite.GetPost(db, models.Viewer{UserID: users["alice"]}, post.ID)
+	posts, _ := sqlite.GetPosts(db, models.Viewer{UserID: users["bob"]}, models.PostFilter{}, 1, 10)
+	comments, _ := sqlite.GetPostComments(db, models.Viewer{UserID: users["alice"]}, other.ID)
+	if !stored.IsBookmarked || !comments[0].IsBookmarked {
+		t.Errorf("Expected alice's bookmarks flagged, got %v %v", stored.IsBookmarked, comments[0].IsBookmarked)
+	}
+	for _, p := range posts {
+		if p.IsBookmarked {
+			t.Errorf("Expected no bookmarks flagged for bob, got post %d", p.ID)
+		}
+	}
+
+	if w := sendAs(db, RemoveBookmark, "DELETE", "/", sessions["alice"], map[string]int{"post_id": post.ID}); w.Code != http.StatusOK {
+		t.Errorf("Expected the bookmark removed, got %d", w.Code)
+	}
+	if w := sendAs(db, RemoveBookmark, "DELETE", "/", sessions["alice"], map[string]int{"post_id": post.ID}); w.Code != http.StatusNotFound {
+		t.Errorf("Expected removing twice to fail, got %d", w.Code)
+	}
+
+	// Deleting the content removes its bookmarks
+	sqlite.DeleteComment(db, comment.ID)
+	if got := list(""); len(got) != 0 {
+		t.Errorf("Expected no bookmarks left, got %+v", got)
+	}
+}
@@ -0,0 +1,101 @@
+package handlers
+
+import (
+	"database/sql"
+	"errors"
+	"log"
+	"net/http"
+
+	"forum/middleware"
+	"forum/pow"
+	"forum/utils"
+)
+
+// Actions a proof-of-work challenge can be issued for
+const (
+	captchaRegister = "register"
+	captchaPost     = "post"
+)
+
+// honeypotField is a form field hidden from people; bots that fill in every
+// field give themselves away
+const honeypotField = "website"
+
+// captcha issues and checks the proof-of-work challenges; nil when
+// POW_DIFFICULTY=0. Replaced in tests.
+var captcha = loadCaptcha()
+
+func loadCaptcha() *pow.Issuer {
+	issuer, err := pow.FromEnv()
+	if err != nil {
+		log.Printf("❌ Invalid proof-of-work settings, using the defaults: %v", err)
+	}
+	if issuer == nil {
+		log.Println("⚠️  Proof-of-work challenges are disabled (POW_DIFFICULTY=0)")
+	}
+	return issuer
+}
+
+// GetCaptchaChallenge issues a proof-of-work challenge for
+// ?action=register or ?action=post. Its difficulty grows with the number of
+// challenges the client asked for recently.
+func GetCaptchaChallenge(db *sql.DB, w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	action := r.URL.Query().Get("action")
+	if action != captchaRegister && action != captchaPost {
+		utils.SendJSONError(w, "Unknown action", http.StatusBadRequest)
+		return
+	}
+	if captcha == nil {
+		utils.SendJSONResponse(w, map[string]any{"required": false}, http.StatusOK)
+		return
+	}
+
+	challenge, err := captcha.Issue(action, middleware.ClientIP(r))
+	if err != nil {
+		utils.SendJSONError(w, "Failed to create challenge", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Cache-Control", "no-store")
+	utils.SendJSONResponse(w, map[string]any{
+		"required":   true,
+		"token":      challenge.Token,
+		"difficulty": challenge.Difficulty,
+		"algorithm":  challenge.Algorithm,
+		"expires_at": challenge.ExpiresAt,
+	}, http.StatusOK)
+}
+
+// checkCaptcha verifies the honeypot and the solved challenge sent in the
+// captcha_token and captcha_nonce form fields. It writes an error response
+// and returns false when the request looks automated. Requests made with an
+// API token are exempt: tokens are already tied to an account.
+func checkCaptcha(w http.ResponseWriter, r *http.Request, action string) bool {
+	if _, ok := utils.APITokenFromRequest(r); ok {
+		return true
+	}
+
+	if r.FormValue(honeypotField) != "" {
+		log.Printf("🍯 Honeypot filled on %s from %s", action, middleware.ClientIP(r))
+		utils.SendJSONError(w, "Invalid request data", http.StatusBadRequest)
+		return false
+	}
+	if captcha == nil {
+		return true
+	}
+
+	err := captcha.Verify(r.FormValue("captcha_token"), r.FormValue("captcha_nonce"), action, middleware.ClientIP(r))
+	switch {
+	case err == nil:
+		return true
+	case errors.Is(err, pow.ErrExpired), errors.Is(err, pow.ErrReplayed):
+		utils.SendJSONError(w, "Challenge expired, please try again", http.StatusForbidden)
+	default:
+		utils.SendJSONError(w, "Please complete the anti-spam challenge", http.StatusForbidden)
+	}
+	return false
+}
@@ -0,0 +1,101 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+	"time"
+
+	"forum/pow"
+)
+
+// TestMain turns the proof-of-work check off for the handler tests that
+// aren't about it; TestCaptcha turns it back on.
+func TestMain(m *testing.M) {
+	captcha = nil
+	os.Exit(m.Run())
+}
+
+func TestCaptcha(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	captcha = pow.NewIssuer([]byte("secret"), 4, 8, time.Minute)
+	defer func() { captcha = nil }()
+
+	challenge := func(action string) map[string]any {
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest("GET", "/api/captcha?action="+action, nil)
+		req.RemoteAddr = "192.0.2.1:1234"
+		GetCaptchaChallenge(db, w, req)
+		var response map[string]any
+		json.Unmarshal(w.Body.Bytes(), &response)
+		return response
+	}
+
+	register := func(username string, fields map[string]string) *httptest.ResponseRecorder {
+		var buf bytes.Buffer
+		writer := multipart.NewWriter(&buf)
+		writer.WriteField("username", username)
+		writer.WriteField("email", username+"@example.com")
+		writer.WriteField("password", "Tr4il-mix-sunset")
+		for name, value := range fields {
+			writer.WriteField(name, value)
+		}
+		writer.Close()
+		req := httptest.NewRequest("POST", "/api/register", &buf)
+		req.Header.Set("Content-Type", writer.FormDataContentType())
+		req.RemoteAddr = "192.0.2.1:1234"
+		w := httptest.NewRecorder()
+		RegisterUser(db, w, req)
+		return w
+	}
+
+	solved := func(action string) map[string]string {
+		c := challenge(action)
+		token := c["token"].(string)
+		return map[string]string{"captcha_token": token, "captcha_nonce": pow.Solve(token, int(c["difficulty"].(float64)))}
+	}
+
+	t.Run("unknown action", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		GetCaptchaChallenge(db, w, httptest.NewRequest("GET", "/api/captcha?action=login", nil))
+		if w.Code != http.StatusBadRequest {
+			t.Errorf("Expected 400, got %d", w.Code)
+		}
+	})
+
+	t.Run("missing solution", func(t *testing.T) {
+		if w := register("nosolution", nil); w.Code != http.StatusForbidden {
+			t.Errorf("Expected 403, got %d", w.Code)
+		}
+	})
+
+	t.Run("solution for another action", func(t *testing.T) {
+		if w := register("wrongaction", solved(captchaPost)); w.Code != http.StatusForbidden {
+			t.Errorf("Expected 403, got %d", w.Code)
+		}
+	})
+
+	t.Run("honeypot", func(t *testing.T) {
+		fields := solved(captchaRegister)
+		fields[honeypotField] = "http://spam.example.com"
+		if w := register("honeybot", fields); w.Code != http.StatusBadRequest {
+			t.Errorf("Expected 400, got %d", w.Code)
+		}
+	})
+
+	t.Run("solved", func(t *testing.T) {
+		fields := solved(captchaRegister)
+		if w := register("human", fields); w.Code != http.StatusCreated {
+			t.Fatalf("Expected 201, got %d: %s", w.Code, w.Body.String())
+		}
+		if w := register("replayer", fields); w.Code != http.StatusForbidden {
+			t.Errorf("Expected a reused solution to be refused, got %d", w.Code)
+		}
+	})
+}
@@ -30,7 +30,7 @@ func CreateComment(db *sql.DB, w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	sanitizedContent, err := utils.ValidateAndSanitizeString(comment.Content, 2000, "comment")
+	sanitizedContent, err := utils.ValidateString(comment.Content, 2000, "comment")
 	if err != nil {
 		utils.SendJSONError(w, err.Error(), http.StatusBadRequest)
 		return
@@ -50,14 +50,36 @@ func CreateComment(db *sql.DB, w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// Posts waiting for review can't be commented on by others
+	if _, err := sqlite.GetPost(db, viewerFor(db, userID), comment.PostID); err == sql.ErrNoRows {
+		utils.SendJSONError(w, "Post not found", http.StatusNotFound)
+		return
+	} else if err != nil {
+		utils.SendJSONError(w, "Database error", http.StatusInternalServerError)
+		return
+	}
+	if !replyAllowed(db, w, userID, models.ContentPost, comment.PostID) {
+		return
+	}
+
+	verdict, ok := screenContent(db, w, userID, sanitizedContent, false)
+	if !ok {
+		return
+	}
+
 	// Create top-level comment
-	comm, err := sqlite.CreateComment(db, comment.UserID, comment.PostID, sanitizedContent)
+	comm, err := sqlite.CreateComment(db, comment.UserID, comment.PostID, sanitizedContent, contentStatus(verdict))
 	if err != nil {
 		utils.SendJSONError(w, "Failed to create comment", http.StatusInternalServerError)
 		return
 	}
 
-	utils.SendJSONResponse(w, comm, http.StatusCreated)
+	status := holdIfFlagged(db, verdict, models.ContentComment, comm.ID, userID, http.StatusCreated)
+	recordMentions(db, models.ContentComment, comm.ID, userID, comm.Content, comm.Status)
+	if comm.Status == models.ContentPublished {
+		checkBadges(db, userID)
+	}
+	utils.SendJSONResponse(w, comm, status)
 }
 
 func CreateReplComment(db *sql.DB, w http.ResponseWriter, r *http.Request) {
@@ -79,7 +101,7 @@ func CreateReplComment(db *sql.DB, w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	sanitizedReplyContent, err := utils.ValidateAndSanitizeString(reply.Content, 2000, "reply")
+	sanitizedReplyContent, err := utils.ValidateString(reply.Content, 2000, "reply")
 	if err != nil {
 		utils.SendJSONError(w, err.Error(), http.StatusBadRequest)
 		return
@@ -104,15 +126,28 @@ func CreateReplComment(db *sql.DB, w http.ResponseWriter, r *http.Request) {
 		http.Error(w, "post_id not allowed for replies", http.StatusBadRequest)
 		return
 	}
+	if !replyAllowed(db, w, userID, models.ContentComment, reply.ParentCommentID) {
+		return
+	}
+
+	verdict, ok := screenContent(db, w, userID, sanitizedReplyContent, false)
+	if !ok {
+		return
+	}
 
 	// Create the reply
-	createdReply, err := sqlite.CreateReplyComment(db, reply.UserID, reply.ParentCommentID, sanitizedReplyContent)
+	createdReply, err := sqlite.CreateReplyComment(db, reply.UserID, reply.ParentCommentID, sanitizedReplyContent, contentStatus(verdict))
 	if err != nil {
 		utils.SendJSONError(w, "Failed to create reply", http.StatusInternalServerError)
 		return
 	}
 
-	utils.SendJSONResponse(w, createdReply, http.StatusCreated)
+	status := holdIfFlagged(db, verdict, models.ContentReply, createdReply.ID, userID, http.StatusCreated)
+	recordMentions(db, models.ContentReply, createdReply.ID, userID, createdReply.Content, createdReply.Status)
+	if createdReply.Status == models.ContentPublished {
+		checkBadges(db, userID)
+	}
+	utils.SendJSONResponse(w, createdReply, status)
 }
 
 // GetComments fetches comments for a post
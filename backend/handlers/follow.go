@@ -0,0 +1,305 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+
+	"forum/models"
+	"forum/sqlite"
+	"forum/utils"
+)
+
+// followedUser looks up the active user a follow request names, writing
+// the error response if there is none
+func followedUser(db *sql.DB, w http.ResponseWriter, username string) (models.User, bool) {
+	user, err := sqlite.GetUserByUsername(db, username)
+	if err == sql.ErrNoRows || (err == nil && user.Status != models.StatusActive) {
+		utils.SendJSONError(w, "User not found", http.StatusNotFound)
+		return models.User{}, false
+	}
+	if err != nil {
+		utils.SendJSONError(w, "Database error", http.StatusInternalServerError)
+		return models.User{}, false
+	}
+	return user, true
+}
+
+// FollowUser makes the current user follow another, so their posts show up
+// in the current user's feed
+func FollowUser(db *sql.DB, w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var request struct {
+		Username string `json:"username"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil || request.Username == "" {
+		http.Error(w, "Invalid request data", http.StatusBadRequest)
+		return
+	}
+
+	userID, err := utils.GetUserIDFromSession(db, r)
+	if err != nil || userID == "" {
+		utils.SendJSONError(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	user, ok := followedUser(db, w, request.Username)
+	if !ok {
+		return
+	}
+	if user.ID == userID {
+		utils.SendJSONError(w, "You can't follow yourself", http.StatusBadRequest)
+		return
+	}
+	blocked, err := sqlite.BlockedBetween(db, userID, user.ID)
+	if err != nil {
+		utils.SendJSONError(w, "Database error", http.StatusInternalServerError)
+		return
+	}
+	if blocked {
+		utils.SendJSONError(w, "You can't follow this user", http.StatusForbidden)
+		return
+	}
+
+	created, err := sqlite.FollowUser(db, userID, user.ID)
+	if err != nil {
+		utils.SendJSONError(w, "Failed to follow user", http.StatusInternalServerError)
+		return
+	}
+
+	status := http.StatusOK
+	if created {
+		status = http.StatusCreated
+	}
+	utils.SendJSONResponse(w, map[string]string{"message": "Following " + user.Username}, status)
+}
+
+// UnfollowUser stops the current user following another
+func UnfollowUser(db *sql.DB, w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodDelete {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var request struct {
+		Username string `json:"username"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil || request.Username == "" {
+		http.Error(w, "Invalid request data", http.StatusBadRequest)
+		return
+	}
+
+	userID, err := utils.GetUserIDFromSession(db, r)
+	if err != nil || userID == "" {
+		utils.SendJSONError(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	user, err := sqlite.GetUserByUsername(db, request.Username)
+	if err == nil {
+		err = sqlite.UnfollowUser(db, userID, user.ID)
+	}
+	if err == sql.ErrNoRows {
+		utils.SendJSONError(w, "You don't follow this user", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		utils.SendJSONError(w, "Failed to unfollow user", http.StatusInternalServerError)
+		return
+	}
+	utils.SendJSONResponse(w, map[string]string{"message": "Unfollowed " + user.Username}, http.StatusOK)
+}
+
+// GetFollowers returns a page of the users following ?username=
+func GetFollowers(db *sql.DB, w http.ResponseWriter, r *http.Request) {
+	listFollows(db, w, r, sqlite.GetFollowers)
+}
+
+// GetFollowing returns a page of the users ?username= follows
+func GetFollowing(db *sql.DB, w http.ResponseWriter, r *http.Request) {
+	listFollows(db, w, r, sqlite.GetFollowing)
+}
+
+func listFollows(db *sql.DB, w http.ResponseWriter, r *http.Request,
+	list func(*sql.DB, string, int, int) ([]models.FollowUser, error)) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	user, ok := followedUser(db, w, r.URL.Query().Get("username"))
+	if !ok {
+		return
+	}
+
+	page, limit := utils.GetPaginationParams(r)
+	users, err := list(db, user.ID, page, limit)
+	if err != nil {
+		utils.SendJSONError(w, "Database error", http.StatusInternalServerError)
+		return
+	}
+	utils.SendJSONResponse(w, users, http.StatusOK)
+}
+
+// FollowCategory makes the current user follow a category, so its posts
+// show up in the current user's feed
+func FollowCategory(db *sql.DB, w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var request struct {
+		CategoryID int `json:"category_id"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil || request.CategoryID <= 0 {
+		http.Error(w, "Invalid request data", http.StatusBadRequest)
+		return
+	}
+
+	userID, err := utils.GetUserIDFromSession(db, r)
+	if err != nil || userID == "" {
+		utils.SendJSONError(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	created, err := sqlite.FollowCategory(db, userID, request.CategoryID)
+	if err == sql.ErrNoRows {
+		utils.SendJSONError(w, "Category not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		utils.SendJSONError(w, "Failed to follow category", http.StatusInternalServerError)
+		return
+	}
+
+	status := http.StatusOK
+	if created {
+		status = http.StatusCreated
+	}
+	utils.SendJSONResponse(w, map[string]string{"message": "Following category"}, status)
+}
+
+// UnfollowCategory stops the current user following a category
+func UnfollowCategory(db *sql.DB, w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodDelete {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var request struct {
+		CategoryID int `json:"category_id"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil || request.CategoryID <= 0 {
+		http.Error(w, "Invalid request data", http.StatusBadRequest)
+		return
+	}
+
+	userID, err := utils.GetUserIDFromSession(db, r)
+	if err != nil || userID == "" {
+		utils.SendJSONError(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	err = sqlite.UnfollowCategory(db, userID, request.CategoryID)
+	if err == sql.ErrNoRows {
+		utils.SendJSONError(w, "You don't follow this category", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		utils.SendJSONError(w, "Failed to unfollow category", http.StatusInternalServerError)
+		return
+	}
+	utils.SendJSONResponse(w, map[string]string{"message": "Unfollowed category"}, http.StatusOK)
+}
+
+// GetFollowedCategories lists the categories the current user follows
+func GetFollowedCategories(db *sql.DB, w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	userID, err := utils.GetUserIDFromSession(db, r)
+	if err != nil || userID == "" {
+		utils.SendJSONError(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	categories, err := sqlite.GetFollowedCategories(db, userID)
+	if err != nil {
+		utils.SendJSONError(w, "Failed to fetch categories", http.StatusInternalServerError)
+		return
+	}
+	utils.SendJSONResponse(w, categories, http.StatusOK)
+}
+
+// GetFeed returns the current user's feed: posts by the users and in the
+// categories they follow, newest first. Pages continue from ?cursor=, the
+// next_cursor of the previous page, which is empty after the last one.
+func GetFeed(db *sql.DB, w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	userID, err := utils.GetUserIDFromSession(db, r)
+	if err != nil || userID == "" {
+		utils.SendJSONError(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	filter := models.PostFilter{FollowedBy: userID, Cursor: true}
+	if cursor := r.URL.Query().Get("cursor"); cursor != "" {
+		filter.BeforeID, err = strconv.Atoi(cursor)
+		if err != nil || filter.BeforeID <= 0 {
+			utils.SendJSONError(w, "Invalid cursor", http.StatusBadRequest)
+			return
+		}
+	}
+
+	_, limit := utils.GetPaginationParams(r)
+	posts, err := sqlite.GetPosts(db, viewerFor(db, userID), filter, 1, limit)
+	if err != nil {
+		utils.SendJSONError(w, "Failed to fetch feed", http.StatusInternalServerError)
+		return
+	}
+
+	for i := range posts {
+		author, err := sqlite.GetUserByID(db, posts[i].UserID)
+		if err != nil {
+			utils.SendJSONError(w, "Failed to fetch post user information", http.StatusInternalServerError)
+			return
+		}
+		posts[i].ProfileAvatar = author.AvatarURL
+	}
+
+	nextCursor := ""
+	if len(posts) == limit {
+		nextCursor = strconv.Itoa(posts[len(posts)-1].ID)
+	}
+	utils.SendJSONResponse(w, map[string]any{
+		"posts":       posts,
+		"next_cursor": nextCursor,
+	}, http.StatusOK)
+}
+
+// notifyFollowers tells the users following an author about a post they
+// published. Failures are logged, as the post itself was published.
+func notifyFollowers(db *sql.DB, authorID string, postID int) {
+	author, err := sqlite.GetUserByID(db, authorID)
+	if err == nil {
+		message := fmt.Sprintf("%s published a new post.", author.Username)
+		_, err = sqlite.NotifyFollowers(db, authorID, message, fmt.Sprintf("/post/%d", postID))
+	}
+	if err != nil {
+		log.Printf("Warning: Failed to notify followers of user %s: %v", authorID, err)
+	}
+}
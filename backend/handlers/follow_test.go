@@ -0,0 +1,121 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"forum/models"
+	"forum/sqlite"
+)
+
+func TestFollows(t *testing.T) {
+	db := setupPostTestDB(t)
+	defer db.Close()
+
+	users, sessions := createTestUsers(t, db, "alice", "bob", "carol")
+
+	sqlite.CreateCategory(db, "Cooking")
+	var cooking int
+	db.QueryRow(`SELECT id FROM categories WHERE name = 'Cooking'`).Scan(&cooking)
+
+	tests := []struct {
+		name    string
+		handler func(*sql.DB, http.ResponseWriter, *http.Request)
+		body    map[string]any
+		want    int
+	}{
+		{"user", FollowUser, map[string]any{"username": "bob"}, http.StatusCreated},
+		{"user again", FollowUser, map[string]any{"username": "bob"}, http.StatusOK},
+		{"self", FollowUser, map[string]any{"username": "alice"}, http.StatusBadRequest},
+		{"unknown user", FollowUser, map[string]any{"username": "nobody"}, http.StatusNotFound},
+		{"category", FollowCategory, map[string]any{"category_id": cooking}, http.StatusCreated},
+		{"unknown category", FollowCategory, map[string]any{"category_id": 999}, http.StatusNotFound},
+	}
+	for _, tt := range tests {
+		if w := sendAs(db, tt.handler, "POST", "/", sessions["alice"], tt.body); w.Code != tt.want {
+			t.Errorf("%s: expected %d, got %d: %s", tt.name, tt.want, w.Code, w.Body.String())
+		}
+	}
+
+	// Bob's post and Carol's post in a followed category make the feed;
+	// Carol's other post doesn't.
+	byBob, _ := sqlite.CreatePost(db, users["bob"], []int{}, "By Bob", "Hello", "", models.ContentPublished)
+	inCooking, _ := sqlite.CreatePost(db, users["carol"], []int{cooking}, "Pancakes", "Recipe", "", models.ContentPublished)
+	sqlite.CreatePost(db, users["carol"], []int{}, "Elsewhere", "Unrelated", "", models.ContentPublished)
+	sqlite.CreatePost(db, users["bob"], []int{}, "Held", "Spam", "", models.ContentPending)
+	// A clock skew dating Bob's post later must not make the cursor skip
+	// a post, so the feed goes by ID.
+	if _, err := db.Exec("UPDATE posts SET created_at = datetime('now', '+1 hour') WHERE id = ?", byBob.ID); err != nil {
+		t.Fatalf("Failed to date post: %v", err)
+	}
+
+	feed := func(query string) (posts []models.Post, next string) {
+		w := sendAs(db, GetFeed, "GET", "/api/feed"+query, sessions["alice"], nil)
+		var response struct {
+			Posts      []models.Post `json:"posts"`
+			NextCursor string        `json:"next_cursor"`
+		}
+		if err := json.Unmarshal(w.Body.Bytes(), &response); err != nil {
+			t.Fatalf("Failed to read feed (%d): %s", w.Code, w.Body.String())
+		}
+		return response.Posts, response.NextCursor
+	}
+	first, next := feed("?limit=1")
+	if len(first) != 1 || first[0].ID != inCooking.ID || next == "" {
+		t.Fatalf("Expected the newest followed post and a cursor, got %+v %q", first, next)
+	}
+	second, next := feed("?limit=1&cursor=" + next)
+	if len(second) != 1 || second[0].ID != byBob.ID {
+		t.Fatalf("Expected the next followed post, got %+v", second)
+	}
+	if rest, next := feed("?limit=1&cursor=" + next); len(rest) != 0 || next != "" {
+		t.Errorf("Expected the end of the feed, got %+v %q", rest, next)
+	}
+	if w := sendAs(db, GetFeed, "GET", "/api/feed?cursor=abc", sessions["alice"], nil); w.Code != http.StatusBadRequest {
+		t.Errorf("Expected 400 for a bad cursor, got %d", w.Code)
+	}
+
+	var followers []models.FollowUser
+	w := sendAs(db, GetFollowers, "GET", "/api/users/followers?username=bob", "", nil)
+	json.Unmarshal(w.Body.Bytes(), &followers)
+	if len(followers) != 1 || followers[0].Username != "alice" {
+		t.Errorf("Expected alice to follow bob, got %+v", followers)
+	}
+
+	var profile models.PublicProfile
+	w = sendAs(db, GetUserProfile, "GET", "/api/users/profile?username=bob", sessions["alice"], nil)
+	json.Unmarshal(w.Body.Bytes(), &profile)
+	if profile.FollowerCount != 1 || profile.FollowingCount != 0 || !profile.IsFollowing {
+		t.Errorf("Unexpected profile %+v", profile)
+	}
+
+	// Publishing notifies followers, unless they turned that off
+	followNotices := func() int {
+		var n int
+		db.QueryRow(`SELECT COUNT(*) FROM notifications WHERE user_id = ? AND type = 'follow'`, users["alice"]).Scan(&n)
+		return n
+	}
+	notifyFollowers(db, users["bob"], byBob.ID)
+	if n := followNotices(); n != 1 {
+		t.Errorf("Expected a follow notification, got %d", n)
+	}
+	if w := sendAs(db, UpdateNotificationPreferences, "PUT", "/", sessions["alice"], map[string]bool{"security": false}); w.Code != http.StatusBadRequest {
+		t.Errorf("Expected 400 for a mandatory type, got %d", w.Code)
+	}
+	if w := sendAs(db, UpdateNotificationPreferences, "PUT", "/", sessions["alice"], map[string]bool{"follow": false}); w.Code != http.StatusOK {
+		t.Fatalf("Expected 200, got %d: %s", w.Code, w.Body.String())
+	}
+	notifyFollowers(db, users["bob"], byBob.ID)
+	if n := followNotices(); n != 1 {
+		t.Errorf("Expected no notification once turned off, got %d", n)
+	}
+
+	if w := sendAs(db, UnfollowUser, "DELETE", "/", sessions["alice"], map[string]any{"username": "bob"}); w.Code != http.StatusOK {
+		t.Errorf("Expected 200 on unfollow, got %d", w.Code)
+	}
+	if w := sendAs(db, UnfollowUser, "DELETE", "/", sessions["alice"], map[string]any{"username": "bob"}); w.Code != http.StatusNotFound {
+		t.Errorf("Expected 404 when not following, got %d", w.Code)
+	}
+}
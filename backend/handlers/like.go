@@ -6,6 +6,7 @@ import (
 	"fmt"
 	"net/http"
 
+	"forum/models"
 	"forum/sqlite"
 	"forum/utils"
 )
@@ -62,11 +63,24 @@ func ToggleLike(db *sql.DB, w http.ResponseWriter, r *http.Request) {
 
 	// Call the updated toggle function with type
 	err := sqlite.ToggleLike(db, userID, request.PostID, request.CommentID, request.Type)
+	if err == sql.ErrNoRows {
+		utils.SendJSONError(w, "Content not found", http.StatusNotFound)
+		return
+	}
 	if err != nil {
 		utils.SendJSONError(w, fmt.Sprintf("Database error: %v", err), http.StatusInternalServerError)
 		return
 	}
 
+	// Likes and the reputation they bring can earn the author badges
+	contentType, contentID := models.ContentPost, request.PostID
+	if request.CommentID != nil {
+		contentType, contentID = models.ContentComment, request.CommentID
+	}
+	if authorID, err := sqlite.ContentAuthor(db, contentType, *contentID); err == nil && authorID != userID {
+		checkBadges(db, authorID)
+	}
+
 	utils.SendJSONResponse(w, map[string]string{"message": "Reaction toggled successfully"}, http.StatusOK)
 }
 
@@ -0,0 +1,101 @@
+package handlers
+
+import (
+	"database/sql"
+	"fmt"
+	"log"
+	"net/http"
+	"regexp"
+	"strings"
+
+	"forum/markdown"
+	"forum/models"
+	"forum/sqlite"
+	"forum/utils"
+)
+
+const maxSuggestions = 10
+
+// suggestionQuery is a name, or the start of one, with an optional "@"
+var suggestionQuery = regexp.MustCompile(`^@?([A-Za-z0-9_-]{1,30})$`)
+
+// recordMentions stores who new or edited content mentions and, if the
+// content is published, notifies them. Content held for review notifies
+// once a moderator approves it. Failures are logged, as the content itself
+// was saved.
+func recordMentions(db *sql.DB, contentType string, contentID int, authorID, content, status string) {
+	if _, err := sqlite.SaveMentions(db, contentType, contentID, authorID, markdown.Mentions(content)); err != nil {
+		log.Printf("Warning: Failed to save mentions in %s %d: %v", contentType, contentID, err)
+		return
+	}
+	if status == models.ContentPublished {
+		notifyMentions(db, contentType, contentID)
+	}
+}
+
+// notifyMentions tells the users mentioned in content who weren't told yet
+func notifyMentions(db *sql.DB, contentType string, contentID int) {
+	mentions, err := sqlite.TakeMentionNotifications(db, contentType, contentID)
+	if err != nil {
+		log.Printf("Warning: Failed to read mentions in %s %d: %v", contentType, contentID, err)
+		return
+	}
+	for _, m := range mentions {
+		message := fmt.Sprintf("%s mentioned you in a %s.", m.AuthorName, m.ContentType)
+		if err := sqlite.CreateNotification(db, m.UserID, "mention", message, fmt.Sprintf("/post/%d", m.PostID)); err != nil {
+			log.Printf("Warning: Failed to notify user %s: %v", m.UserID, err)
+		}
+	}
+}
+
+// AutocompleteUsers suggests users to mention for ?q=, the start of a name
+func AutocompleteUsers(db *sql.DB, w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	userID, err := utils.GetUserIDFromSession(db, r)
+	if err != nil || userID == "" {
+		utils.SendJSONError(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	m := suggestionQuery.FindStringSubmatch(strings.TrimSpace(r.URL.Query().Get("q")))
+	if m == nil {
+		utils.SendJSONResponse(w, []models.UserSuggestion{}, http.StatusOK)
+		return
+	}
+
+	suggestions, err := sqlite.AutocompleteUsers(db, userID, m[1], maxSuggestions)
+	if err != nil {
+		utils.SendJSONError(w, "Database error", http.StatusInternalServerError)
+		return
+	}
+	utils.SendJSONResponse(w, suggestions, http.StatusOK)
+}
+
+// GetUserProfile returns the public profile of ?username=
+func GetUserProfile(db *sql.DB, w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	profile, err := sqlite.GetPublicProfile(db, r.URL.Query().Get("username"))
+	if err == sql.ErrNoRows {
+		utils.SendJSONError(w, "User not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		utils.SendJSONError(w, "Database error", http.StatusInternalServerError)
+		return
+	}
+	if userID, err := utils.GetUserIDFromSession(db, r); err == nil && userID != "" && userID != profile.ID {
+		profile.IsFollowing, profile.IsBlocked, profile.IsMuted, err = sqlite.GetUserRelation(db, userID, profile.ID)
+		if err != nil {
+			log.Printf("Warning: Failed to read how %s relates to %s: %v", userID, profile.ID, err)
+		}
+	}
+	utils.SendJSONResponse(w, profile, http.StatusOK)
+}
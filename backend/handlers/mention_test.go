@@ -0,0 +1,118 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+
+	"forum/markdown"
+	"forum/models"
+	"forum/sqlite"
+)
+
+func TestMentions(t *testing.T) {
+	db := setupPostTestDB(t)
+	defer db.Close()
+
+	originalTrust := trustThreshold
+	defer func() { trustThreshold = originalTrust }()
+	trustThreshold = trustPolicy{}
+	markdown.SetMentionResolver(sqlite.MentionResolver(db))
+	defer markdown.SetMentionResolver(nil)
+
+	users, sessions := createTestUsers(t, db, "alice", "bob", "bobby", "carol", "robert")
+	mentionsOf := func(name string) []models.Notification {
+		notifications, _ := sqlite.GetNotifications(db, users[name], 1, 50)
+		mentions := []models.Notification{}
+		for _, n := range notifications {
+			if n.Type == "mention" {
+				mentions = append(mentions, n)
+			}
+		}
+		return mentions
+	}
+
+	post, _ := sqlite.CreatePost(db, users["alice"], []int{}, "Trip", "draft", "", models.ContentPublished)
+	update := func(content string) {
+		w := sendAs(db, UpdatePost, "PUT", "/", sessions["alice"],
+			map[string]any{"id": post.ID, "title": "Trip", "content": content})
+		if w.Code != http.StatusOK {
+			t.Fatalf("Expected 200, got %d: %s", w.Code, w.Body.String())
+		}
+	}
+
+	update("Thanks @BOB and @bob, @alice, @nobody and `@carol`")
+	if got := mentionsOf("bob"); len(got) != 1 || got[0].Message != "alice mentioned you in a post." || got[0].Link != "/post/1" {
+		t.Errorf("Expected one mention notification for bob, got %+v", got)
+	}
+	if got := len(mentionsOf("alice")) + len(mentionsOf("carol")); got != 0 {
+		t.Errorf("Expected no notifications for self-mentions or code, got %d", got)
+	}
+
+	// Editing keeps bob's mention without notifying him again
+	update("Thanks @bob and @carol")
+	if len(mentionsOf("bob")) != 1 || len(mentionsOf("carol")) != 1 {
+		t.Errorf("Expected each mention notified once, got %d and %d", len(mentionsOf("bob")), len(mentionsOf("carol")))
+	}
+	stored, _ := sqlite.GetPost(db, models.Viewer{}, post.ID)
+	if !strings.Contains(stored.ContentHTML, `<a href="/user/bob" class="mention" rel="nofollow ugc">@bob</a>`) {
+		t.Errorf("Expected mentions rendered as profile links, got %q", stored.ContentHTML)
+	}
+
+	w := sendAs(db, CreateComment, "POST", "/", sessions["alice"],
+		map[string]any{"post_id": post.ID, "content": "@robert what do you think?"})
+	if w.Code != http.StatusCreated {
+		t.Fatalf("Expected 201, got %d: %s", w.Code, w.Body.String())
+	}
+	if got := mentionsOf("robert"); len(got) != 1 || got[0].Message != "alice mentioned you in a comment." {
+		t.Errorf("Expected a comment mention for robert, got %+v", got)
+	}
+
+	t.Run("autocomplete", func(t *testing.T) {
+		suggest := func(q string) string {
+			w := sendAs(db, AutocompleteUsers, "GET", "/api/users/autocomplete?q="+q, sessions["alice"], nil)
+			if w.Code != http.StatusOK {
+				t.Fatalf("Expected 200, got %d: %s", w.Code, w.Body.String())
+			}
+			var suggestions []models.UserSuggestion
+			json.Unmarshal(w.Body.Bytes(), &suggestions)
+			names := []string{}
+			for _, s := range suggestions {
+				names = append(names, s.Username)
+			}
+			return strings.Join(names, ",")
+		}
+
+		// Prefix matches first, then whom alice mentioned most recently
+		if got := suggest("b"); got != "bob,bobby,robert" {
+			t.Errorf("Expected bob, bobby, then robert, got %q", got)
+		}
+		if got := suggest("@ro"); got != "robert,carol" {
+			t.Errorf("Expected robert, then carol, got %q", got)
+		}
+		if got := suggest("al"); got != "" {
+			t.Errorf("Expected no suggestion of oneself, got %q", got)
+		}
+		if got := suggest("%25"); got != "" {
+			t.Errorf("Expected no suggestions for an invalid name, got %q", got)
+		}
+	})
+
+	t.Run("profile", func(t *testing.T) {
+		w := sendAs(db, GetUserProfile, "GET", "/api/users/profile?username=alice", sessions["alice"], nil)
+		var profile models.PublicProfile
+		json.Unmarshal(w.Body.Bytes(), &profile)
+		if w.Code != http.StatusOK || profile.Username != "alice" || profile.PostCount != 1 || profile.CommentCount != 1 {
+			t.Errorf("Unexpected profile %d %+v", w.Code, profile)
+		}
+		if strings.Contains(w.Body.String(), "alice@example.com") {
+			t.Error("Expected the profile to leave out the email address")
+		}
+
+		w = sendAs(db, GetUserProfile, "GET", "/api/users/profile?username=nobody", sessions["alice"], nil)
+		if w.Code != http.StatusNotFound {
+			t.Errorf("Expected 404, got %d", w.Code)
+		}
+	})
+}
@@ -0,0 +1,310 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"forum/models"
+	"forum/spamfilter"
+	"forum/sqlite"
+	"forum/storage"
+	"forum/utils"
+)
+
+const (
+	defaultTrustedPosts     = 1
+	defaultTrustedAfterDays = 3
+	maxModerationReason     = 500
+)
+
+// contentFilter screens new posts, comments and replies; replaced in tests
+var contentFilter = loadContentFilter()
+
+// trustThreshold is read once at startup; replaced in tests
+var trustThreshold = loadTrustThreshold()
+
+// trustPolicy decides when a user's content stops waiting for review: once
+// they have ApprovedPosts published posts or their account is AccountAge
+// old. Moderators are always trusted.
+type trustPolicy struct {
+	ApprovedPosts int
+	AccountAge    time.Duration
+}
+
+func loadContentFilter() spamfilter.Filter {
+	filter, err := spamfilter.FromEnv()
+	if err != nil {
+		log.Printf("❌ Content filter not fully loaded: %v", err)
+	}
+	return filter
+}
+
+// loadTrustThreshold reads TRUST_APPROVED_POSTS (0 trusts everyone) and
+// TRUST_ACCOUNT_DAYS
+func loadTrustThreshold() trustPolicy {
+	policy := trustPolicy{ApprovedPosts: defaultTrustedPosts, AccountAge: defaultTrustedAfterDays * 24 * time.Hour}
+	if value := os.Getenv("TRUST_APPROVED_POSTS"); value != "" {
+		if n, err := strconv.Atoi(value); err == nil && n >= 0 {
+			policy.ApprovedPosts = n
+		} else {
+			log.Printf("⚠️  Invalid TRUST_APPROVED_POSTS %q, using %d", value, policy.ApprovedPosts)
+		}
+	}
+	if value := os.Getenv("TRUST_ACCOUNT_DAYS"); value != "" {
+		if days, err := strconv.ParseFloat(value, 64); err == nil && days >= 0 {
+			policy.AccountAge = time.Duration(days * float64(24*time.Hour))
+		} else {
+			log.Printf("⚠️  Invalid TRUST_ACCOUNT_DAYS %q, using %s", value, policy.AccountAge)
+		}
+	}
+	return policy
+}
+
+// untrustedReason explains why a user's content still waits for review, or
+// is "" once they are trusted
+func untrustedReason(db *sql.DB, user *models.User) (string, error) {
+	if trustThreshold.ApprovedPosts == 0 || models.CanModerate(user.Role) || time.Since(user.CreatedAt) >= trustThreshold.AccountAge {
+		return "", nil
+	}
+	approved, err := sqlite.CountPublishedPosts(db, user.ID)
+	if err != nil || approved >= trustThreshold.ApprovedPosts {
+		return "", err
+	}
+	return fmt.Sprintf("new account with %d of %d approved posts", approved, trustThreshold.ApprovedPosts), nil
+}
+
+// requestViewer is who a request reads content for: the owner of an API
+// token with the read scope, as the auth middleware accepts it, or of the
+// session. Anonymous without either, as public routes don't require them.
+func requestViewer(db *sql.DB, r *http.Request) models.Viewer {
+	if bearer, ok := utils.BearerToken(r); ok {
+		token, err := sqlite.GetAPIToken(db, bearer)
+		if err != nil || !token.HasScope(models.ScopeRead) {
+			if err != nil && err != sql.ErrNoRows {
+				log.Printf("❌ API token lookup failed: %v", err)
+			}
+			return models.Viewer{}
+		}
+		return viewerFor(db, token.UserID)
+	}
+	userID, err := utils.GetUserIDFromSession(db, r)
+	if err != nil || userID == "" {
+		return models.Viewer{}
+	}
+	return viewerFor(db, userID)
+}
+
+// viewerFor is the viewer of a signed-in user
+func viewerFor(db *sql.DB, userID string) models.Viewer {
+	role, err := sqlite.GetUserRole(db, userID)
+	if err != nil {
+		log.Printf("Warning: Role lookup for user %s failed: %v", userID, err)
+	}
+	return models.Viewer{UserID: userID, Moderator: models.CanModerate(role)}
+}
+
+// screenContent runs text by the content filter and remembers it for
+// duplicate detection. Content from users below the trust threshold is
+// flagged too. Edits don't count the author's own repeats, as earlier
+// saves of the same post recorded them. Blocked text is refused with 400;
+// ok is false when a response has been sent.
+func screenContent(db *sql.DB, w http.ResponseWriter, userID, text string, edit bool) (verdict spamfilter.Verdict, ok bool) {
+	user, err := sqlite.GetUserByID(db, userID)
+	if err != nil {
+		utils.SendJSONError(w, "Database error", http.StatusInternalServerError)
+		return verdict, false
+	}
+	input := spamfilter.Input{Text: text, AccountAge: time.Since(user.CreatedAt)}
+
+	fingerprint := spamfilter.Fingerprint(text)
+	if fingerprint != "" {
+		input.Repeats, input.Copies, err = sqlite.CountContentFingerprints(db, userID, fingerprint, time.Now().Add(-spamfilter.DuplicateWindow))
+		if err != nil {
+			utils.SendJSONError(w, "Database error", http.StatusInternalServerError)
+			return verdict, false
+		}
+		if edit {
+			input.Repeats = 0
+		}
+	}
+	if input.Model, err = sqlite.GetSpamModel(db, spamfilter.Tokens(text)); err != nil {
+		utils.SendJSONError(w, "Database error", http.StatusInternalServerError)
+		return verdict, false
+	}
+
+	verdict = contentFilter.Check(input)
+	if verdict.Action == spamfilter.Block {
+		log.Printf("🚫 Content from user %s blocked: %v", userID, verdict.Reasons)
+		utils.SendJSONError(w, "Your text contains words that are not allowed here", http.StatusBadRequest)
+		return verdict, false
+	}
+
+	if verdict.Action == spamfilter.Allow {
+		reason, err := untrustedReason(db, user)
+		if err != nil {
+			utils.SendJSONError(w, "Database error", http.StatusInternalServerError)
+			return verdict, false
+		}
+		if reason != "" {
+			verdict.Action = spamfilter.Flag
+			verdict.Reasons = append(verdict.Reasons, reason)
+		}
+	}
+
+	if fingerprint != "" {
+		if err := sqlite.RecordContentFingerprint(db, userID, fingerprint); err != nil {
+			log.Printf("Warning: Failed to record content fingerprint: %v", err)
+		}
+	}
+	return verdict, true
+}
+
+// contentStatus is the status new content is created with
+func contentStatus(verdict spamfilter.Verdict) string {
+	if verdict.Action == spamfilter.Flag {
+		return models.ContentPending
+	}
+	return models.ContentPublished
+}
+
+// holdIfFlagged queues flagged content for a moderator and returns the
+// status code to respond with: 202 while the content waits for review
+func holdIfFlagged(db *sql.DB, verdict spamfilter.Verdict, contentType string, contentID int, userID string, published int) int {
+	if verdict.Action != spamfilter.Flag {
+		return published
+	}
+	var spamScore *float64
+	if verdict.SpamScore >= 0 {
+		spamScore = &verdict.SpamScore
+	}
+	if err := sqlite.HoldForModeration(db, contentType, contentID, userID, verdict.Reasons, spamScore); err != nil {
+		log.Printf("❌ Failed to queue %s %d for moderation: %v", contentType, contentID, err)
+	} else {
+		log.Printf("🛡️  %s %d from user %s held for moderation: %v", contentType, contentID, userID, verdict.Reasons)
+	}
+	return http.StatusAccepted
+}
+
+// moderationText is the text of queued content the classifier learns from
+func moderationText(item models.ModerationItem) string {
+	if item.ContentType == models.ContentPost {
+		return item.PostTitle + "\n" + item.Content
+	}
+	return item.Content
+}
+
+// GetModerationQueue lists content waiting for review (moderators only)
+func GetModerationQueue(db *sql.DB, w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	items, err := sqlite.GetModerationQueue(db)
+	if err != nil {
+		utils.SendJSONError(w, "Database error", http.StatusInternalServerError)
+		return
+	}
+	utils.SendJSONResponse(w, items, http.StatusOK)
+}
+
+// ApproveContent publishes queued content, trains the classifier that it is
+// not spam and notifies the author (moderators only)
+func ApproveContent(db *sql.DB, w http.ResponseWriter, r *http.Request) {
+	decideContent(db, w, r, false)
+}
+
+// RejectContent deletes queued content, trains the classifier that it is
+// spam and tells the author why (moderators only)
+func RejectContent(db *sql.DB, w http.ResponseWriter, r *http.Request) {
+	decideContent(db, w, r, true)
+}
+
+func decideContent(db *sql.DB, w http.ResponseWriter, r *http.Request, spam bool) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var request struct {
+		ID     int64  `json:"id"`
+		Reason string `json:"reason"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil || request.ID == 0 {
+		http.Error(w, "Invalid request data", http.StatusBadRequest)
+		return
+	}
+	reason := strings.TrimSpace(request.Reason)
+	if spam && reason == "" {
+		utils.SendJSONError(w, "A reason is required to reject content", http.StatusBadRequest)
+		return
+	}
+	if len(reason) > maxModerationReason {
+		utils.SendJSONError(w, fmt.Sprintf("Reason must be at most %d characters", maxModerationReason), http.StatusBadRequest)
+		return
+	}
+
+	item, err := sqlite.GetModerationItem(db, request.ID)
+	if err == nil {
+		if spam {
+			err = sqlite.RejectModerationItem(db, item)
+		} else {
+			err = sqlite.PublishModerationItem(db, item)
+		}
+	}
+	if err == sql.ErrNoRows {
+		utils.SendJSONError(w, "No queued content with this ID", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		utils.SendJSONError(w, "Database error", http.StatusInternalServerError)
+		return
+	}
+
+	if spam && item.ImageURL != nil {
+		storage.DeleteURL(r.Context(), storage.Uploads, *item.ImageURL)
+	}
+	if err := sqlite.TrainSpamFilter(db, spamfilter.Tokens(moderationText(item)), spam); err != nil {
+		log.Printf("Warning: Failed to train spam filter: %v", err)
+	}
+
+	notice := fmt.Sprintf("Your %s was approved and is now visible.", describeContent(item))
+	if spam {
+		notice = fmt.Sprintf("Your %s was removed by a moderator.", describeContent(item))
+	}
+	if reason != "" {
+		notice += " Reason: " + reason
+	}
+	if err := sqlite.CreateNotification(db, item.UserID, "moderation", notice, ""); err != nil {
+		log.Printf("Warning: Failed to notify user %s: %v", item.UserID, err)
+	}
+	if !spam {
+		notifyMentions(db, item.ContentType, item.ContentID)
+		checkBadges(db, item.UserID)
+		if item.ContentType == models.ContentPost {
+			notifyFollowers(db, item.UserID, item.ContentID)
+		}
+	}
+
+	message := "Content approved"
+	if spam {
+		message = "Content rejected"
+	}
+	log.Printf("🛡️  %s %d: %s", item.ContentType, item.ContentID, message)
+	utils.SendJSONResponse(w, map[string]string{"message": message}, http.StatusOK)
+}
+
+// describeContent names queued content for its author, e.g. `comment on "Title"`
+func describeContent(item models.ModerationItem) string {
+	if item.ContentType == models.ContentPost {
+		return fmt.Sprintf("post %q", item.PostTitle)
+	}
+	return fmt.Sprintf("%s on %q", item.ContentType, item.PostTitle)
+}
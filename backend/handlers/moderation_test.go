@@ -0,0 +1,303 @@
+package handlers
+
+import (
+	"bytes"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"forum/models"
+	"forum/spamfilter"
+	"forum/sqlite"
+)
+
+func TestContentModeration(t *testing.T) {
+	db := setupPostTestDB(t)
+	defer db.Close()
+
+	originalFilter, originalTrust := contentFilter, trustThreshold
+	defer func() { contentFilter, trustThreshold = originalFilter, originalTrust }()
+	trustThreshold = trustPolicy{}
+	contentFilter = spamfilter.Filter{
+		Blocked:       []string{"scamcoin"},
+		Flagged:       []string{"casino*"},
+		NewAccountAge: 72 * time.Hour,
+		MaxLinks:      2,
+		SpamThreshold: 0.9,
+	}
+
+	sqlite.CreateUser(db, "alice", "alice@example.com", "hash", "")
+	alice, _ := sqlite.GetUserByUsername(db, "alice")
+	session, _ := sqlite.CreateSession(db, alice.ID)
+	post, _ := sqlite.CreatePost(db, alice.ID, []int{}, "Weekend plans", "Anyone up for a hike?", "", models.ContentPublished)
+
+	send := func(handler func(*httptest.ResponseRecorder, *http.Request), method string, body any) *httptest.ResponseRecorder {
+		payload, _ := json.Marshal(body)
+		req := httptest.NewRequest(method, "/", bytes.NewReader(payload))
+		req.AddCookie(&http.Cookie{Name: "session_id", Value: session})
+		w := httptest.NewRecorder()
+		handler(w, req)
+		return w
+	}
+	comment := func(content string) *httptest.ResponseRecorder {
+		return send(func(w *httptest.ResponseRecorder, r *http.Request) { CreateComment(db, w, r) }, "POST", map[string]any{"post_id": post.ID, "content": content})
+	}
+	published := func() int {
+		comments, err := sqlite.GetPostComments(db, models.Viewer{}, post.ID)
+		if err != nil {
+			t.Fatalf("GetPostComments failed: %v", err)
+		}
+		return len(comments)
+	}
+	queue := func() []models.ModerationItem {
+		items, err := sqlite.GetModerationQueue(db)
+		if err != nil {
+			t.Fatalf("GetModerationQueue failed: %v", err)
+		}
+		return items
+	}
+
+	const cleanText = "Count me in, I will bring sandwiches for everyone"
+	if w := comment(cleanText); w.Code != http.StatusCreated {
+		t.Fatalf("Expected a clean comment to be published, got %d: %s", w.Code, w.Body.String())
+	}
+
+	t.Run("blocked word", func(t *testing.T) {
+		if w := comment("Get rich with $CAMC0IN today"); w.Code != http.StatusBadRequest {
+			t.Errorf("Expected 400, got %d", w.Code)
+		}
+	})
+
+	t.Run("flagged content is held", func(t *testing.T) {
+		tests := []struct {
+			name, content, reason string
+		}{
+			{"flagged word", "Best c а s i n o bonuses in town", "flagged word: casino*"},
+			{"links from a new account", "see http://a.example http://b.example http://c.example", "3 links from an account younger than 3 days"},
+			{"repeated text", cleanText, "the author posted the same text recently"},
+		}
+		for i, tt := range tests {
+			w := comment(tt.content)
+			if w.Code != http.StatusAccepted {
+				t.Fatalf("%s: expected 202, got %d: %s", tt.name, w.Code, w.Body.String())
+			}
+			var held models.Comment
+			json.Unmarshal(w.Body.Bytes(), &held)
+			if held.Status != models.ContentPending {
+				t.Errorf("%s: expected status pending, got %q", tt.name, held.Status)
+			}
+			items := queue()
+			if len(items) != i+1 || fmt.Sprint(items[i].Reasons) != fmt.Sprint([]string{tt.reason}) {
+				t.Errorf("%s: expected reason %q in the queue, got %+v", tt.name, tt.reason, items)
+			}
+		}
+		if published() != 1 {
+			t.Errorf("Expected held comments to stay hidden, got %d published", published())
+		}
+	})
+
+	decide := func(handler func(*sql.DB, http.ResponseWriter, *http.Request), id int64) int {
+		return send(func(w *httptest.ResponseRecorder, r *http.Request) { handler(db, w, r) }, "POST", map[string]any{"id": id, "reason": "spam"}).Code
+	}
+
+	t.Run("approve publishes and trains ham", func(t *testing.T) {
+		if code := decide(ApproveContent, queue()[0].ID); code != http.StatusOK {
+			t.Fatalf("Expected 200, got %d", code)
+		}
+		if published() != 2 {
+			t.Errorf("Expected the approved comment to be published, got %d", published())
+		}
+		model, _ := sqlite.GetSpamModel(db, []string{"bonuses"})
+		if model.HamDocuments != 1 || model.Tokens["bonuses"].Ham != 1 {
+			t.Errorf("Expected one ham document with its tokens, got %+v", model)
+		}
+	})
+
+	t.Run("reject deletes and trains spam", func(t *testing.T) {
+		item := queue()[0]
+		if code := decide(RejectContent, item.ID); code != http.StatusOK {
+			t.Fatalf("Expected 200, got %d", code)
+		}
+		if len(queue()) != 1 || published() != 2 {
+			t.Errorf("Expected the rejected comment to be gone, queue %d, published %d", len(queue()), published())
+		}
+		model, _ := sqlite.GetSpamModel(db, nil)
+		if model.SpamDocuments != 1 {
+			t.Errorf("Expected one spam document, got %+v", model)
+		}
+		if code := decide(RejectContent, item.ID); code != http.StatusNotFound {
+			t.Errorf("Expected a decided item to be gone, got %d", code)
+		}
+	})
+
+	t.Run("edits don't repeat the post", func(t *testing.T) {
+		for _, content := range []string{"Anyone up for a long hike?", "Anyone up for a long hike?", "Anyone up for a bike ride?", "Anyone up for a long hike?"} {
+			w := send(func(w *httptest.ResponseRecorder, r *http.Request) { UpdatePost(db, w, r) }, "PUT",
+				map[string]any{"id": post.ID, "title": "Weekend plans", "content": content})
+			var saved models.Post
+			json.Unmarshal(w.Body.Bytes(), &saved)
+			if w.Code != http.StatusOK || saved.Status != models.ContentPublished {
+				t.Fatalf("Expected %q to keep the post published, got %d: %s", content, w.Code, w.Body.String())
+			}
+		}
+	})
+
+	t.Run("flagged edit hides the post", func(t *testing.T) {
+		w := send(func(w *httptest.ResponseRecorder, r *http.Request) { UpdatePost(db, w, r) }, "PUT",
+			map[string]any{"id": post.ID, "title": "Weekend plans", "content": "Free casino chips for hikers"})
+		if w.Code != http.StatusAccepted {
+			t.Fatalf("Expected 202, got %d: %s", w.Code, w.Body.String())
+		}
+		posts, _ := sqlite.GetPosts(db, models.Viewer{}, models.PostFilter{}, 1, 10)
+		if len(posts) != 0 {
+			t.Errorf("Expected the held post to be hidden, got %d posts", len(posts))
+		}
+	})
+}
+
+func TestTrustThreshold(t *testing.T) {
+	db := setupPostTestDB(t)
+	defer db.Close()
+
+	originalFilter, originalTrust := contentFilter, trustThreshold
+	defer func() { contentFilter, trustThreshold = originalFilter, originalTrust }()
+	contentFilter = spamfilter.Filter{SpamThreshold: 0.9}
+	trustThreshold = trustPolicy{ApprovedPosts: 1, AccountAge: 72 * time.Hour}
+
+	ids, sessions := createTestUsers(t, db, "mod", "newbie", "veteran", "other")
+	users := map[string]models.Viewer{}
+	for name, id := range ids {
+		users[name] = models.Viewer{UserID: id}
+	}
+	sqlite.SetUserRole(db, "mod", models.RoleModerator)
+	users["mod"] = models.Viewer{UserID: users["mod"].UserID, Moderator: true}
+	db.Exec(`UPDATE users SET created_at = ? WHERE username = 'veteran'`, time.Now().Add(-10*24*time.Hour))
+	post, _ := sqlite.CreatePost(db, users["mod"].UserID, []int{}, "Introductions", "Say hello!", "", models.ContentPublished)
+
+	send := func(as string, handler func(*sql.DB, http.ResponseWriter, *http.Request), body any) *httptest.ResponseRecorder {
+		return sendAs(db, handler, "POST", "/", sessions[as], body)
+	}
+	comment := func(as, content string) *httptest.ResponseRecorder {
+		return send(as, CreateComment, map[string]any{"post_id": post.ID, "content": content})
+	}
+	visible := func(viewer models.Viewer) int {
+		comments, err := sqlite.GetPostComments(db, viewer, post.ID)
+		if err != nil {
+			t.Fatalf("GetPostComments failed: %v", err)
+		}
+		return len(comments)
+	}
+	notifications := func(name string) []models.Notification {
+		all, _ := sqlite.GetNotifications(db, users[name].UserID, 1, 10)
+		list := []models.Notification{}
+		for _, n := range all {
+			if n.Type == "moderation" {
+				list = append(list, n)
+			}
+		}
+		return list
+	}
+
+	if w := comment("veteran", "Hello from an old account"); w.Code != http.StatusCreated {
+		t.Errorf("Expected an old account to be trusted, got %d", w.Code)
+	}
+
+	w := comment("newbie", "Hello, I just joined")
+	if w.Code != http.StatusAccepted {
+		t.Fatalf("Expected a new account's comment to be held, got %d: %s", w.Code, w.Body.String())
+	}
+	var held models.Comment
+	json.Unmarshal(w.Body.Bytes(), &held)
+
+	t.Run("pending content is visible to its author and moderators only", func(t *testing.T) {
+		for name, expected := range map[string]int{"newbie": 2, "mod": 2, "other": 1} {
+			if n := visible(users[name]); n != expected {
+				t.Errorf("Expected %s to see %d comments, got %d", name, expected, n)
+			}
+		}
+		if n := visible(models.Viewer{}); n != 1 {
+			t.Errorf("Expected visitors to see 1 comment, got %d", n)
+		}
+		if w := send("other", ToggleLike, map[string]any{"comment_id": held.ID, "type": "like"}); w.Code != http.StatusNotFound {
+			t.Errorf("Expected reacting to a hidden comment to fail, got %d", w.Code)
+		}
+	})
+
+	queued, _ := sqlite.GetModerationQueue(db)
+	if len(queued) != 1 || queued[0].Reasons[0] != "new account with 0 of 1 approved posts" {
+		t.Fatalf("Expected the comment to be queued as from a new account, got %+v", queued)
+	}
+
+	t.Run("reject needs a reason and notifies the author", func(t *testing.T) {
+		if w := send("mod", RejectContent, map[string]any{"id": queued[0].ID}); w.Code != http.StatusBadRequest {
+			t.Errorf("Expected 400 without a reason, got %d", w.Code)
+		}
+		if w := send("mod", RejectContent, map[string]any{"id": queued[0].ID, "reason": "Please introduce yourself in English"}); w.Code != http.StatusOK {
+			t.Fatalf("Expected 200, got %d: %s", w.Code, w.Body.String())
+		}
+		list := notifications("newbie")
+		if len(list) != 1 || !strings.Contains(list[0].Message, "removed") || !strings.Contains(list[0].Message, "introduce yourself") {
+			t.Errorf("Expected a removal notice with the reason, got %+v", list)
+		}
+	})
+
+	t.Run("approve publishes and notifies the author", func(t *testing.T) {
+		comment("newbie", "Hello, I am Nina and I like hiking")
+		queued, _ := sqlite.GetModerationQueue(db)
+		if w := send("mod", ApproveContent, map[string]any{"id": queued[0].ID}); w.Code != http.StatusOK {
+			t.Fatalf("Expected 200, got %d: %s", w.Code, w.Body.String())
+		}
+		if n := visible(models.Viewer{}); n != 2 {
+			t.Errorf("Expected the approved comment to be public, got %d comments", n)
+		}
+		if list := notifications("newbie"); len(list) != 2 || !strings.Contains(list[0].Message, "approved") {
+			t.Errorf("Expected an approval notice, got %+v", list)
+		}
+	})
+
+	t.Run("approved posts earn trust", func(t *testing.T) {
+		sqlite.CreatePost(db, users["newbie"].UserID, []int{}, "My trip", "Photos from the hike", "", models.ContentPublished)
+		if w := comment("newbie", "Thanks for the warm welcome"); w.Code != http.StatusCreated {
+			t.Errorf("Expected a user with an approved post to be trusted, got %d", w.Code)
+		}
+	})
+}
+
+func TestRequestViewerAcceptsAPITokens(t *testing.T) {
+	db := setupPostTestDB(t)
+	defer db.Close()
+
+	sqlite.CreateUser(db, "scripter", "scripter@example.com", "hash", "")
+	user, _ := sqlite.GetUserByUsername(db, "scripter")
+	sqlite.CreatePost(db, user.ID, []int{}, "Held", "Waiting for review", "", models.ContentPending)
+	expires := time.Now().Add(time.Hour)
+	readToken, _, _ := sqlite.CreateAPIToken(db, user.ID, "reader", []string{models.ScopeRead}, expires)
+	writeToken, _, _ := sqlite.CreateAPIToken(db, user.ID, "writer", []string{models.ScopeWritePosts}, expires)
+
+	tests := []struct {
+		name     string
+		token    string
+		wantHeld bool
+	}{
+		{"read scope", readToken, true},
+		{"no read scope", writeToken, false},
+		{"unknown token", "forum_unknown", false},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest("GET", "/api/posts", nil)
+		req.Header.Set("Authorization", "Bearer "+tt.token)
+		w := httptest.NewRecorder()
+		GetPosts(db, w, req)
+
+		var posts []models.Post
+		json.Unmarshal(w.Body.Bytes(), &posts)
+		if held := len(posts) == 1; w.Code != http.StatusOK || held != tt.wantHeld {
+			t.Errorf("%s: expected held post shown %v, got %d %+v", tt.name, tt.wantHeld, w.Code, posts)
+		}
+	}
+}
@@ -0,0 +1,133 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"forum/models"
+	"forum/sqlite"
+	"forum/utils"
+)
+
+// GetNotifications returns the current user's notifications and unread count
+func GetNotifications(db *sql.DB, w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	userID, err := utils.GetUserIDFromSession(db, r)
+	if err != nil || userID == "" {
+		utils.SendJSONError(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	page, limit := utils.GetPaginationParams(r)
+	notifications, err := sqlite.GetNotifications(db, userID, page, limit)
+	if err != nil {
+		utils.SendJSONError(w, "Failed to fetch notifications", http.StatusInternalServerError)
+		return
+	}
+
+	unread, err := sqlite.CountUnreadNotifications(db, userID)
+	if err != nil {
+		utils.SendJSONError(w, "Failed to fetch notifications", http.StatusInternalServerError)
+		return
+	}
+
+	utils.SendJSONResponse(w, map[string]any{
+		"notifications": notifications,
+		"unread":        unread,
+	}, http.StatusOK)
+}
+
+// MarkNotificationsRead marks the given notifications as read, or all of them if no IDs are sent
+func MarkNotificationsRead(db *sql.DB, w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var request struct {
+		IDs []int `json:"ids"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		http.Error(w, "Invalid request data", http.StatusBadRequest)
+		return
+	}
+
+	userID, err := utils.GetUserIDFromSession(db, r)
+	if err != nil || userID == "" {
+		utils.SendJSONError(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	if err := sqlite.MarkNotificationsRead(db, userID, request.IDs); err != nil {
+		utils.SendJSONError(w, "Failed to update notifications", http.StatusInternalServerError)
+		return
+	}
+
+	utils.SendJSONResponse(w, map[string]string{"message": "Notifications marked as read"}, http.StatusOK)
+}
+
+// GetNotificationPreferences returns which optional notifications the
+// current user gets
+func GetNotificationPreferences(db *sql.DB, w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	userID, err := utils.GetUserIDFromSession(db, r)
+	if err != nil || userID == "" {
+		utils.SendJSONError(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	preferences, err := sqlite.GetNotificationPreferences(db, userID)
+	if err != nil {
+		utils.SendJSONError(w, "Failed to fetch notification preferences", http.StatusInternalServerError)
+		return
+	}
+	utils.SendJSONResponse(w, preferences, http.StatusOK)
+}
+
+// UpdateNotificationPreferences turns optional notifications on or off for
+// the current user, from a map of notification type to enabled
+func UpdateNotificationPreferences(db *sql.DB, w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPut {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var request map[string]bool
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil || len(request) == 0 {
+		http.Error(w, "Invalid request data", http.StatusBadRequest)
+		return
+	}
+	for notificationType := range request {
+		if !models.IsOptionalNotification(notificationType) {
+			utils.SendJSONError(w, fmt.Sprintf("%q notifications can't be turned off", notificationType), http.StatusBadRequest)
+			return
+		}
+	}
+
+	userID, err := utils.GetUserIDFromSession(db, r)
+	if err != nil || userID == "" {
+		utils.SendJSONError(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	if err := sqlite.SetNotificationPreferences(db, userID, request); err != nil {
+		utils.SendJSONError(w, "Failed to save notification preferences", http.StatusInternalServerError)
+		return
+	}
+	preferences, err := sqlite.GetNotificationPreferences(db, userID)
+	if err != nil {
+		utils.SendJSONError(w, "Failed to fetch notification preferences", http.StatusInternalServerError)
+		return
+	}
+	utils.SendJSONResponse(w, preferences, http.StatusOK)
+}
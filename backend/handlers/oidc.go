@@ -0,0 +1,361 @@
+package handlers
+
+import (
+	"crypto/subtle"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"forum/models"
+	"forum/oidc"
+	"forum/sqlite"
+	"forum/utils"
+)
+
+// oidcLoginTTL is how long the user has to complete the login at the provider
+const oidcLoginTTL = 10 * time.Minute
+
+// oidcProviders are the configured login providers by name; replaced in tests
+var oidcProviders = loadOIDCProviders()
+
+var (
+	errOIDCNoEmail         = errors.New("the provider did not share an email address")
+	errOIDCEmailUnverified = errors.New("an account with this email already exists")
+	errOIDCInviteOnly      = errors.New("registration requires an invite")
+)
+
+func loadOIDCProviders() map[string]*oidc.Provider {
+	providers, err := oidc.FromEnv()
+	if err != nil {
+		log.Printf("❌ OpenID Connect login disabled: %v", err)
+		return map[string]*oidc.Provider{}
+	}
+	return providers
+}
+
+// oidcPostLoginURL is the frontend page the browser returns to after a provider login
+func oidcPostLoginURL() string {
+	if u := os.Getenv("OIDC_POST_LOGIN_URL"); u != "" {
+		return u
+	}
+	origin := os.Getenv("FRONTEND_ORIGIN")
+	if origin == "" {
+		origin = "http://localhost:8000" // fallback default
+	}
+	return strings.TrimRight(origin, "/") + "/"
+}
+
+// redirectAfterOIDC sends the browser back to the frontend, with query
+// parameters such as login_error for it to show
+func redirectAfterOIDC(w http.ResponseWriter, r *http.Request, params url.Values) {
+	target := oidcPostLoginURL()
+	if len(params) > 0 {
+		separator := "?"
+		if strings.Contains(target, "?") {
+			separator = "&"
+		}
+		target += separator + params.Encode()
+	}
+	http.Redirect(w, r, target, http.StatusFound)
+}
+
+// GetOIDCProviders lists the providers shown as "Sign in with ..." buttons
+func GetOIDCProviders(db *sql.DB, w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	type providerInfo struct {
+		Name        string `json:"name"`
+		DisplayName string `json:"display_name"`
+		LoginURL    string `json:"login_url"`
+	}
+	providers := []providerInfo{}
+	for _, p := range oidcProviders {
+		providers = append(providers, providerInfo{p.Name, p.DisplayName, "/api/oidc/login/" + p.Name})
+	}
+	sort.Slice(providers, func(i, j int) bool { return providers[i].Name < providers[j].Name })
+
+	utils.SendJSONResponse(w, providers, http.StatusOK)
+}
+
+// BeginOIDCLogin redirects the browser to the provider named in the path
+func BeginOIDCLogin(db *sql.DB, w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	provider, ok := oidcProviders[strings.TrimPrefix(r.URL.Path, "/api/oidc/login/")]
+	if !ok {
+		utils.SendJSONError(w, "Unknown login provider", http.StatusNotFound)
+		return
+	}
+
+	login := models.OIDCLogin{Provider: provider.Name, ExpiresAt: time.Now().Add(oidcLoginTTL)}
+	var err error
+	for _, value := range []*string{&login.State, &login.Nonce, &login.CodeVerifier} {
+		if *value, err = oidc.RandomString(); err != nil {
+			utils.SendJSONError(w, "Failed to start login", http.StatusInternalServerError)
+			return
+		}
+	}
+
+	authURL, err := provider.AuthCodeURL(r.Context(), login.State, login.Nonce, login.CodeVerifier)
+	if err != nil {
+		log.Printf("❌ OpenID Connect provider %s unavailable: %v", provider.Name, err)
+		utils.SendJSONError(w, "Login provider unavailable", http.StatusBadGateway)
+		return
+	}
+	if err := sqlite.CreateOIDCLogin(db, login); err != nil {
+		utils.SendJSONError(w, "Database error", http.StatusInternalServerError)
+		return
+	}
+
+	utils.SetOIDCStateCookie(w, login.State, oidcLoginTTL)
+	http.Redirect(w, r, authURL, http.StatusFound)
+}
+
+// OIDCCallback completes a provider login: it checks the state, exchanges the
+// code, verifies the ID token and logs in the linked (or a new) user
+func OIDCCallback(db *sql.DB, w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	fail := func(message string) {
+		redirectAfterOIDC(w, r, url.Values{"login_error": {message}})
+	}
+
+	name := strings.TrimPrefix(r.URL.Path, "/api/oidc/callback/")
+	provider, ok := oidcProviders[name]
+	if !ok {
+		utils.SendJSONError(w, "Unknown login provider", http.StatusNotFound)
+		return
+	}
+
+	query := r.URL.Query()
+	state := query.Get("state")
+	cookie, err := r.Cookie(utils.OIDCStateCookieName)
+	utils.ClearOIDCStateCookie(w)
+	if err != nil || state == "" || subtle.ConstantTimeCompare([]byte(cookie.Value), []byte(state)) != 1 {
+		fail("Login session expired, please try again")
+		return
+	}
+
+	login, err := sqlite.TakeOIDCLogin(db, state)
+	if err != nil || login.Provider != provider.Name {
+		fail("Login session expired, please try again")
+		return
+	}
+	if query.Get("error") != "" {
+		fail("Login was cancelled")
+		return
+	}
+
+	tokens, err := provider.Exchange(r.Context(), query.Get("code"), login.CodeVerifier)
+	if err != nil {
+		log.Printf("❌ OpenID Connect login with %s failed: %v", provider.Name, err)
+		fail("Login failed, please try again")
+		return
+	}
+	claims, err := provider.VerifyIDToken(r.Context(), tokens.IDToken, login.Nonce)
+	if err != nil {
+		log.Printf("❌ OpenID Connect login with %s failed: %v", provider.Name, err)
+		fail("Login failed, please try again")
+		return
+	}
+
+	// Some providers only put the email in the userinfo response
+	if claims.Email == "" && tokens.AccessToken != "" {
+		if info, err := provider.UserInfo(r.Context(), tokens.AccessToken); err == nil && info.Subject == claims.Subject {
+			claims.Email, claims.EmailVerified = info.Email, info.EmailVerified
+			if claims.PreferredUsername == "" {
+				claims.PreferredUsername = info.PreferredUsername
+			}
+			if claims.Name == "" {
+				claims.Name = info.Name
+			}
+		}
+	}
+
+	userID, err := resolveOIDCUser(db, provider, claims)
+	switch {
+	case errors.Is(err, errOIDCNoEmail):
+		fail(fmt.Sprintf("Your %s account has no email address to sign up with", provider.DisplayName))
+		return
+	case errors.Is(err, errOIDCEmailUnverified):
+		fail(fmt.Sprintf("An account with this email already exists. Verify your email with %s or sign in with your password.", provider.DisplayName))
+		return
+	case errors.Is(err, errOIDCInviteOnly):
+		fail("Registration is invite-only. Sign up with an invite code first, then link your account.")
+		return
+	case err != nil:
+		log.Printf("❌ OpenID Connect login with %s failed: %v", provider.Name, err)
+		fail("Login failed, please try again")
+		return
+	}
+
+	user, err := sqlite.GetUserByID(db, userID)
+	if err != nil {
+		fail("Login failed, please try again")
+		return
+	}
+	// startSession refuses these too, but the callback answers with a redirect
+	if user.Status != models.StatusActive {
+		fail("Your account is waiting for approval by an administrator")
+		return
+	}
+
+	// The provider replaces the password, not the second factor
+	enabled, err := sqlite.IsTOTPEnabled(db, userID)
+	if err != nil {
+		fail("Login failed, please try again")
+		return
+	}
+	if enabled {
+		token, err := sqlite.CreatePendingLogin(db, userID, twoFactorClock().Add(pendingLoginTTL))
+		if err != nil {
+			fail("Login failed, please try again")
+			return
+		}
+		redirectAfterOIDC(w, r, url.Values{"two_factor": {token}})
+		return
+	}
+
+	if !startSession(db, w, userID) {
+		return
+	}
+	redirectAfterOIDC(w, r, nil)
+}
+
+// resolveOIDCUser returns the user a provider account logs in as. Unknown
+// accounts are linked to an existing user with the same email only if the
+// provider verified the email; otherwise a new user is created, unless
+// registration is invite-only. In approval mode the new user is pending.
+func resolveOIDCUser(db *sql.DB, provider *oidc.Provider, claims oidc.Claims) (string, error) {
+	identity, err := sqlite.GetUserIdentity(db, provider.Name, claims.Subject)
+	if err == nil {
+		return identity.UserID, nil
+	}
+	if err != sql.ErrNoRows {
+		return "", err
+	}
+
+	if claims.Email == "" || utils.ValidateEmail(claims.Email) != nil {
+		return "", errOIDCNoEmail
+	}
+
+	existing, err := sqlite.GetUserByEmailFold(db, claims.Email)
+	if err == nil {
+		if !claims.EmailVerified {
+			return "", errOIDCEmailUnverified
+		}
+		if err := sqlite.LinkUserIdentity(db, existing.ID, provider.Name, claims.Subject, claims.Email); err != nil {
+			return "", err
+		}
+		message := fmt.Sprintf("Your account was linked to your %s account (%s).", provider.DisplayName, claims.Email)
+		if err := sqlite.CreateNotification(db, existing.ID, "security", message, ""); err != nil {
+			log.Printf("Warning: Failed to notify user %s: %v", existing.ID, err)
+		}
+		return existing.ID, nil
+	}
+	if err != sql.ErrNoRows {
+		return "", err
+	}
+
+	// Invite codes can't travel through the provider redirect
+	if registrationMode == registrationInvite {
+		return "", errOIDCInviteOnly
+	}
+	status := models.StatusActive
+	if registrationMode == registrationApproval {
+		status = models.StatusPending
+	}
+
+	username, err := uniqueUsername(db, claims)
+	if err != nil {
+		return "", err
+	}
+
+	// Provider accounts have no usable password until the user sets one
+	randomPassword, err := oidc.RandomString()
+	if err != nil {
+		return "", err
+	}
+	passwordHash, err := utils.HashPassword(randomPassword)
+	if err != nil {
+		return "", err
+	}
+
+	if _, err := sqlite.CreateUserWithInvite(db, username, claims.Email, passwordHash, "/static/profiles/default.png", status, ""); err != nil {
+		return "", err
+	}
+	user, err := sqlite.GetUserByUsername(db, username)
+	if err != nil {
+		return "", err
+	}
+	if err := sqlite.LinkUserIdentity(db, user.ID, provider.Name, claims.Subject, claims.Email); err != nil {
+		return "", err
+	}
+	log.Printf("👤 Created user %s from %s login", username, provider.Name)
+	if status == models.StatusPending {
+		notifyAdminsOfPendingUser(db, username)
+	}
+	return user.ID, nil
+}
+
+var usernameInvalidChars = regexp.MustCompile(`[^a-zA-Z0-9_-]+`)
+
+// uniqueUsername derives a free username that passes ValidateUsername from
+// the provider's preferred username, name or email
+func uniqueUsername(db *sql.DB, claims oidc.Claims) (string, error) {
+	localPart, _, _ := strings.Cut(claims.Email, "@")
+
+	base := ""
+	for _, candidate := range []string{claims.PreferredUsername, claims.Name, localPart} {
+		candidate = usernameInvalidChars.ReplaceAllString(strings.ReplaceAll(candidate, " ", "_"), "")
+		candidate = strings.Trim(candidate, "_-")
+		if len(candidate) >= 3 {
+			base = candidate
+			break
+		}
+	}
+	if base == "" {
+		base = "user"
+	}
+	if len(base) > 24 {
+		base = base[:24]
+	}
+
+	for i := 1; i <= 1000; i++ {
+		username := base
+		if i > 1 {
+			username = fmt.Sprintf("%s%d", base, i)
+		}
+		if len(username) < 3 {
+			username += strings.Repeat("_", 3-len(username))
+		}
+		if utils.ValidateUsername(username) != nil {
+			continue
+		}
+		taken, err := sqlite.UsernameExists(db, username)
+		if err != nil {
+			return "", err
+		}
+		if !taken {
+			return username, nil
+		}
+	}
+	return "", fmt.Errorf("no free username for %q", base)
+}
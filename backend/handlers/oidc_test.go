@@ -0,0 +1,189 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"forum/oidc"
+	"forum/oidc/oidctest"
+	"forum/sqlite"
+	"forum/utils"
+)
+
+func TestOIDCLogin(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	issuer := oidctest.NewIssuer("forum", "s3cret")
+	defer issuer.Close()
+
+	originalProviders := oidcProviders
+	defer func() { oidcProviders = originalProviders }()
+	oidcProviders = map[string]*oidc.Provider{"mock": {
+		Name:         "mock",
+		DisplayName:  "Mock",
+		Issuer:       issuer.Issuer(),
+		ClientID:     "forum",
+		ClientSecret: "s3cret",
+		Scopes:       []string{"openid", "email", "profile"},
+		RedirectURL:  "http://localhost:8080/api/oidc/callback/mock",
+	}}
+	t.Setenv("OIDC_POST_LOGIN_URL", "http://localhost:8000/")
+
+	noRedirects := &http.Client{CheckRedirect: func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse }}
+
+	// login runs the whole redirect dance and returns the callback response.
+	// tamperState replaces the state cookie to simulate a forged callback.
+	login := func(t *testing.T, user oidctest.User, tamperState bool) (*httptest.ResponseRecorder, url.Values) {
+		t.Helper()
+		issuer.User = user
+
+		w := httptest.NewRecorder()
+		BeginOIDCLogin(db, w, httptest.NewRequest("GET", "/api/oidc/login/mock", nil))
+		if w.Code != http.StatusFound {
+			t.Fatalf("Expected redirect to provider, got %d: %s", w.Code, w.Body.String())
+		}
+		var stateCookie *http.Cookie
+		for _, c := range w.Result().Cookies() {
+			if c.Name == utils.OIDCStateCookieName {
+				stateCookie = c
+			}
+		}
+		if stateCookie == nil {
+			t.Fatal("Expected a state cookie")
+		}
+		if tamperState {
+			stateCookie.Value = "forged"
+		}
+
+		resp, err := noRedirects.Get(w.Header().Get("Location"))
+		if err != nil {
+			t.Fatalf("Authorize request failed: %v", err)
+		}
+		resp.Body.Close()
+		callback, _ := url.Parse(resp.Header.Get("Location"))
+
+		req := httptest.NewRequest("GET", callback.RequestURI(), nil)
+		req.AddCookie(stateCookie)
+		w = httptest.NewRecorder()
+		OIDCCallback(db, w, req)
+		if w.Code != http.StatusFound {
+			t.Fatalf("Expected redirect to the frontend, got %d: %s", w.Code, w.Body.String())
+		}
+		target, _ := url.Parse(w.Header().Get("Location"))
+		return w, target.Query()
+	}
+
+	sessionUser := func(w *httptest.ResponseRecorder) string {
+		for _, c := range w.Result().Cookies() {
+			if c.Name == utils.SessionCookieName && c.Value != "" {
+				req := httptest.NewRequest("GET", "/", nil)
+				req.AddCookie(c)
+				userID, _ := utils.GetUserIDFromSession(db, req)
+				return userID
+			}
+		}
+		return ""
+	}
+
+	var aliceID string
+
+	t.Run("new user", func(t *testing.T) {
+		w, params := login(t, oidctest.User{Subject: "a-1", Email: "alice@example.com", EmailVerified: true, PreferredUsername: "alice smith!"}, false)
+		if params.Get("login_error") != "" {
+			t.Fatalf("Unexpected error: %s", params.Get("login_error"))
+		}
+		user, err := sqlite.GetUserByUsername(db, "alice_smith")
+		if err != nil {
+			t.Fatalf("Expected user alice_smith to be created: %v", err)
+		}
+		if sessionUser(w) != user.ID {
+			t.Error("Expected a session for the new user")
+		}
+		aliceID = user.ID
+	})
+
+	t.Run("returning user", func(t *testing.T) {
+		w, _ := login(t, oidctest.User{Subject: "a-1", Email: "alice@other.example.com", EmailVerified: true}, false)
+		if sessionUser(w) != aliceID {
+			t.Error("Expected the linked user to be logged in")
+		}
+	})
+
+	passwordHash, _ := utils.HashPassword("password123")
+	sqlite.CreateUser(db, "bob", "bob@example.com", passwordHash, "")
+	bob, _ := sqlite.GetUserByUsername(db, "bob")
+
+	t.Run("unverified email is not linked", func(t *testing.T) {
+		w, params := login(t, oidctest.User{Subject: "b-1", Email: "bob@example.com", EmailVerified: false}, false)
+		if params.Get("login_error") == "" || sessionUser(w) != "" {
+			t.Error("Expected login to be refused")
+		}
+	})
+
+	t.Run("verified email is linked", func(t *testing.T) {
+		w, _ := login(t, oidctest.User{Subject: "b-1", Email: "BOB@example.com", EmailVerified: true}, false)
+		if sessionUser(w) != bob.ID {
+			t.Fatal("Expected the existing account to be logged in")
+		}
+		if _, err := sqlite.GetUserIdentity(db, "mock", "b-1"); err != nil {
+			t.Errorf("Expected identity to be linked: %v", err)
+		}
+		notifications, _ := sqlite.GetNotifications(db, bob.ID, 1, 10)
+		if len(notifications) != 1 {
+			t.Errorf("Expected the owner to be notified, got %d notifications", len(notifications))
+		}
+	})
+
+	t.Run("username collision", func(t *testing.T) {
+		login(t, oidctest.User{Subject: "c-1", Email: "bob2@example.com", EmailVerified: true, PreferredUsername: "Bob"}, false)
+		if _, err := sqlite.GetUserByUsername(db, "Bob2"); err != nil {
+			t.Errorf("Expected a suffixed username: %v", err)
+		}
+	})
+
+	t.Run("forged state", func(t *testing.T) {
+		w, params := login(t, oidctest.User{Subject: "a-1", Email: "alice@example.com", EmailVerified: true}, true)
+		if params.Get("login_error") == "" || sessionUser(w) != "" {
+			t.Error("Expected a callback without the matching state cookie to be refused")
+		}
+	})
+
+	t.Run("two-factor users need a code", func(t *testing.T) {
+		sqlite.SaveTOTPSecret(db, aliceID, "JBSWY3DPEHPK3PXP")
+		sqlite.EnableTOTP(db, aliceID, 0, nil)
+		w, params := login(t, oidctest.User{Subject: "a-1", Email: "alice@example.com", EmailVerified: true}, false)
+		if params.Get("two_factor") == "" || sessionUser(w) != "" {
+			t.Error("Expected a pending login instead of a session")
+		}
+	})
+}
+
+func TestUniqueUsername(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	tests := []struct {
+		claims   oidc.Claims
+		expected string
+	}{
+		{oidc.Claims{PreferredUsername: "jo", Name: "Jo Lee"}, "Jo_Lee"},
+		{oidc.Claims{Name: "Zoë", Email: "z@example.com"}, "user"},
+		{oidc.Claims{Email: "very.long.address.that.goes.on.and.on@example.com"}, "verylongaddressthatgoeso"},
+		{oidc.Claims{PreferredUsername: "--__--"}, "user"},
+	}
+	for _, tt := range tests {
+		username, err := uniqueUsername(db, tt.claims)
+		if err != nil {
+			t.Fatalf("uniqueUsername failed: %v", err)
+		}
+		if username != tt.expected {
+			t.Errorf("Expected %q, got %q", tt.expected, username)
+		}
+		if err := utils.ValidateUsername(username); err != nil {
+			t.Errorf("Generated username %q is invalid: %v", username, err)
+		}
+	}
+}
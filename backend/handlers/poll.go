@@ -0,0 +1,157 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"forum/models"
+	"forum/sqlite"
+	"forum/utils"
+)
+
+// parsePoll reads the optional poll of a new post, sent as a JSON form
+// value, and validates it. The question and options are plain text and
+// stored HTML escaped like titles. nil means the post has no poll.
+func parsePoll(value string) (*models.NewPoll, error) {
+	if strings.TrimSpace(value) == "" {
+		return nil, nil
+	}
+	var poll models.NewPoll
+	if err := json.Unmarshal([]byte(value), &poll); err != nil {
+		return nil, fmt.Errorf("invalid poll")
+	}
+
+	var err error
+	if poll.Question, err = utils.ValidateAndSanitizeString(poll.Question, models.MaxPollQuestion, "poll question"); err != nil {
+		return nil, err
+	}
+	if len(poll.Options) < models.MinPollOptions || len(poll.Options) > models.MaxPollOptions {
+		return nil, fmt.Errorf("a poll needs %d to %d options", models.MinPollOptions, models.MaxPollOptions)
+	}
+	seen := map[string]bool{}
+	for i, option := range poll.Options {
+		if poll.Options[i], err = utils.ValidateAndSanitizeString(option, models.MaxPollOption, "poll option"); err != nil {
+			return nil, err
+		}
+		key := strings.ToLower(poll.Options[i])
+		if seen[key] {
+			return nil, fmt.Errorf("poll options must be different")
+		}
+		seen[key] = true
+	}
+
+	open := time.Until(poll.ClosesAt)
+	if open < models.MinPollDuration || open > models.MaxPollDuration {
+		return nil, fmt.Errorf("a poll must close between %s and %d days from now", models.MinPollDuration, int(models.MaxPollDuration.Hours()/24))
+	}
+	return &poll, nil
+}
+
+// pollText is the text of a poll the content filter screens
+func pollText(poll *models.NewPoll) string {
+	if poll == nil {
+		return ""
+	}
+	return "\n" + poll.Question + "\n" + strings.Join(poll.Options, "\n")
+}
+
+// VotePoll casts the current user's vote in a poll
+func VotePoll(db *sql.DB, w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	castVote(db, w, r, false)
+}
+
+// ChangePollVote replaces the current user's vote in a poll
+func ChangePollVote(db *sql.DB, w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPut {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	castVote(db, w, r, true)
+}
+
+func castVote(db *sql.DB, w http.ResponseWriter, r *http.Request, replace bool) {
+	var request struct {
+		PollID    int   `json:"poll_id"`
+		OptionIDs []int `json:"option_ids"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil || request.PollID <= 0 {
+		http.Error(w, "Invalid request data", http.StatusBadRequest)
+		return
+	}
+
+	userID, ok := RequireAuth(db, w, r)
+	if !ok || userID == "" {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	// The poll's post must be one the user can see
+	postID, err := sqlite.GetPollPostID(db, request.PollID)
+	if err == sql.ErrNoRows {
+		utils.SendJSONError(w, "Poll not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		utils.SendJSONError(w, "Database error", http.StatusInternalServerError)
+		return
+	}
+	viewer := viewerFor(db, userID)
+	post, err := sqlite.GetPost(db, viewer, postID)
+	if err == sql.ErrNoRows {
+		utils.SendJSONError(w, "Poll not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		utils.SendJSONError(w, "Database error", http.StatusInternalServerError)
+		return
+	}
+	poll := post.Poll
+
+	if poll.Closed {
+		utils.SendJSONError(w, "This poll is closed", http.StatusConflict)
+		return
+	}
+	if len(request.OptionIDs) == 0 || (!poll.Multiple && len(request.OptionIDs) > 1) {
+		utils.SendJSONError(w, "Choose one option", http.StatusBadRequest)
+		return
+	}
+	chosen := map[int]bool{}
+	for _, id := range request.OptionIDs {
+		if chosen[id] {
+			utils.SendJSONError(w, "Options may only be chosen once", http.StatusBadRequest)
+			return
+		}
+		chosen[id] = true
+	}
+
+	err = sqlite.CastVote(db, poll.ID, userID, request.OptionIDs, replace)
+	switch err {
+	case nil:
+	case sqlite.ErrInvalidOption:
+		utils.SendJSONError(w, "Unknown option", http.StatusBadRequest)
+		return
+	case sqlite.ErrAlreadyVoted:
+		utils.SendJSONError(w, "You already voted in this poll", http.StatusConflict)
+		return
+	case sqlite.ErrNotVoted:
+		utils.SendJSONError(w, "You haven't voted in this poll yet", http.StatusConflict)
+		return
+	default:
+		utils.SendJSONError(w, "Database error", http.StatusInternalServerError)
+		return
+	}
+
+	if err := sqlite.AttachPoll(db, viewer, &post); err != nil {
+		utils.SendJSONError(w, "Database error", http.StatusInternalServerError)
+		return
+	}
+	utils.SendJSONResponse(w, post.Poll, http.StatusOK)
+}
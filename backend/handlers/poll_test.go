@@ -0,0 +1,127 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"forum/models"
+	"forum/sqlite"
+)
+
+func TestPolls(t *testing.T) {
+	db := setupPostTestDB(t)
+	defer db.Close()
+
+	originalTrust := trustThreshold
+	defer func() { trustThreshold = originalTrust }()
+	trustThreshold = trustPolicy{}
+
+	_, sessions := createTestUsers(t, db, "alice", "bob", "carol")
+
+	createPost := func(poll any) *httptest.ResponseRecorder {
+		var body bytes.Buffer
+		form := multipart.NewWriter(&body)
+		form.WriteField("title", "Where to?")
+		form.WriteField("content", "Team outing")
+		pollJSON, _ := json.Marshal(poll)
+		form.WriteField("poll", string(pollJSON))
+		form.Close()
+		req := httptest.NewRequest("POST", "/api/posts/create", &body)
+		req.Header.Set("Content-Type", form.FormDataContentType())
+		req.AddCookie(&http.Cookie{Name: "session_id", Value: sessions["alice"]})
+		w := httptest.NewRecorder()
+		CreatePost(db, w, req)
+		return w
+	}
+	vote := func(user string, change bool, pollID int, options ...int) *httptest.ResponseRecorder {
+		method, handler := "POST", VotePoll
+		if change {
+			method, handler = "PUT", ChangePollVote
+		}
+		return sendAs(db, handler, method, "/", sessions[user], map[string]any{"poll_id": pollID, "option_ids": options})
+	}
+	closesAt := time.Now().Add(24 * time.Hour)
+
+	t.Run("invalid polls", func(t *testing.T) {
+		tests := map[string]map[string]any{
+			"one option":       {"question": "Q?", "options": []string{"A"}, "closes_at": closesAt},
+			"duplicate option": {"question": "Q?", "options": []string{"A", "a"}, "closes_at": closesAt},
+			"no question":      {"question": " ", "options": []string{"A", "B"}, "closes_at": closesAt},
+			"closed already":   {"question": "Q?", "options": []string{"A", "B"}, "closes_at": time.Now().Add(-time.Hour)},
+		}
+		for name, poll := range tests {
+			if w := createPost(poll); w.Code != http.StatusBadRequest {
+				t.Errorf("%s: expected 400, got %d: %s", name, w.Code, w.Body.String())
+			}
+		}
+	})
+
+	w := createPost(map[string]any{"question": "Where <to>?", "options": []string{"Lake", "Hills", "Museum"}, "closes_at": closesAt})
+	if w.Code != http.StatusCreated {
+		t.Fatalf("Expected 201, got %d: %s", w.Code, w.Body.String())
+	}
+	var post models.Post
+	json.Unmarshal(w.Body.Bytes(), &post)
+	if post.Poll == nil || post.Poll.Question != "Where &lt;to&gt;?" || len(post.Poll.Options) != 3 || post.Poll.Options[0].Label != "Lake" {
+		t.Fatalf("Expected the poll in the post payload, got %+v", post.Poll)
+	}
+	poll := post.Poll
+	lake, hills, museum := poll.Options[0].ID, poll.Options[1].ID, poll.Options[2].ID
+
+	if w := vote("bob", false, poll.ID, lake, hills); w.Code != http.StatusBadRequest {
+		t.Errorf("Expected one option only in a single choice poll, got %d", w.Code)
+	}
+	if w := vote("bob", true, poll.ID, lake); w.Code != http.StatusConflict {
+		t.Errorf("Expected changing a missing vote to fail, got %d", w.Code)
+	}
+	if w := vote("bob", false, poll.ID, 999); w.Code != http.StatusBadRequest {
+		t.Errorf("Expected an unknown option to be refused, got %d", w.Code)
+	}
+	vote("bob", false, poll.ID, lake)
+	vote("carol", false, poll.ID, hills)
+	if w := vote("bob", false, poll.ID, hills); w.Code != http.StatusConflict {
+		t.Errorf("Expected a second vote to be refused, got %d", w.Code)
+	}
+	w = vote("bob", true, poll.ID, museum)
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected the vote changed, got %d: %s", w.Code, w.Body.String())
+	}
+	json.Unmarshal(w.Body.Bytes(), &poll)
+	if poll.Voters != 2 || *poll.Options[0].Votes != 0 || *poll.Options[1].Votes != 1 || *poll.Options[2].Percent != 50 ||
+		len(poll.MyVotes) != 1 || poll.MyVotes[0] != museum {
+		t.Errorf("Unexpected results %+v", poll)
+	}
+
+	t.Run("hidden results", func(t *testing.T) {
+		w := createPost(map[string]any{"question": "Pizza?", "options": []string{"Yes", "No", "Maybe"}, "multiple": true,
+			"hide_results": true, "closes_at": closesAt})
+		json.Unmarshal(w.Body.Bytes(), &post)
+		options := post.Poll.Options
+
+		w = vote("bob", false, post.Poll.ID, options[0].ID, options[2].ID)
+		var hidden models.Poll
+		json.Unmarshal(w.Body.Bytes(), &hidden)
+		if w.Code != http.StatusOK || !hidden.ResultsHidden || hidden.Options[0].Votes != nil || hidden.Voters != 1 || len(hidden.MyVotes) != 2 {
+			t.Errorf("Expected counts hidden until the poll closes, got %d %+v", w.Code, hidden)
+		}
+
+		db.Exec(`UPDATE polls SET closes_at = ? WHERE id = ?`, time.Now().Add(-time.Minute).UTC(), post.Poll.ID)
+		if w := vote("carol", false, post.Poll.ID, options[1].ID); w.Code != http.StatusConflict {
+			t.Errorf("Expected a closed poll to refuse votes, got %d", w.Code)
+		}
+		closed, _ := sqlite.GetPost(db, models.Viewer{}, post.ID)
+		if !closed.Poll.Closed || closed.Poll.ResultsHidden || *closed.Poll.Options[0].Votes != 1 || *closed.Poll.Options[2].Percent != 100 {
+			t.Errorf("Expected results shown once closed, got %+v", closed.Poll)
+		}
+	})
+
+	posts, _ := sqlite.GetPosts(db, models.Viewer{}, models.PostFilter{}, 1, 10)
+	if len(posts) != 2 || posts[0].Poll == nil || posts[1].Poll == nil {
+		t.Errorf("Expected polls in the post list, got %+v", posts)
+	}
+}
@@ -7,14 +7,16 @@ import (
 	"io"
 	"log"
 	"net/http"
-	"os"
 	"path/filepath"
 	"strconv"
 	"time"
 	"bytes"
 	
+	"forum/markdown"
 	"forum/models"
+	"forum/spamfilter"
 	"forum/sqlite"
+	"forum/storage"
 	"forum/utils"
 )
 const maxImageSize = 20 << 20 // 20 MB limit for images
@@ -41,14 +43,15 @@ func CreatePost(db *sql.DB, w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Sanitize title and content
+	// Sanitize the title; the Markdown content is kept as written and
+	// sanitized when rendered
 	sanitizedTitle, err := utils.ValidateAndSanitizeString(title, 200, "title")
 	if err != nil {
 		utils.SendJSONError(w, err.Error(), http.StatusBadRequest)
 		return
 	}
 
-	sanitizedContent, err := utils.ValidateAndSanitizeString(content, 10000, "content")
+	sanitizedContent, err := utils.ValidateString(content, 10000, "content")
 	if err != nil {
 		utils.SendJSONError(w, err.Error(), http.StatusBadRequest)
 		return
@@ -75,6 +78,13 @@ func CreatePost(db *sql.DB, w http.ResponseWriter, r *http.Request) {
 
 	log.Printf("DEBUG: Final categoryNames = %v", categoryNames)
 
+	// Optional poll, sent as JSON
+	poll, err := parsePoll(r.FormValue("poll"))
+	if err != nil {
+		utils.SendJSONError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
 	// Validate user session
 	userID, ok := RequireAuth(db, w, r)
 	if !ok || userID == "" {
@@ -82,6 +92,15 @@ func CreatePost(db *sql.DB, w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if !checkCaptcha(w, r, captchaPost) {
+		return
+	}
+
+	verdict, ok := screenContent(db, w, userID, sanitizedTitle+"\n"+sanitizedContent+pollText(poll), false)
+	if !ok {
+		return
+	}
+
 	// Handle optional image upload
 	var imageURL string
 	file, header, err := r.FormFile("image")
@@ -99,41 +118,56 @@ func CreatePost(db *sql.DB, w http.ResponseWriter, r *http.Request) {
 			return
 		}	
 		ext := filepath.Ext(header.Filename)
-		filename := fmt.Sprintf("post_%s_%d%s", userID, time.Now().UnixNano(), ext)
-		dstPath := filepath.Join("static/pictures", filename)
+		imageKey := fmt.Sprintf("pictures/post_%s_%d%s", userID, time.Now().UnixNano(), ext)
 
-		dst, err := os.Create(dstPath)
-		if err != nil {
+		if err := storage.Uploads.Put(r.Context(), imageKey, &buf, http.DetectContentType(buf.Bytes())); err != nil {
+			log.Println("Error saving post image:", err)
 			http.Error(w, "Unable to save image", http.StatusInternalServerError)
 			return
 		}
-		defer dst.Close()
 
-		if _, err := io.Copy(dst, file); err != nil {
-			http.Error(w, "Failed to write image", http.StatusInternalServerError)
-			return
-		}
-
-		imageURL = "/" + dstPath
+		imageURL = storage.Uploads.URL(imageKey)
 	}
 
 	// Get category IDs by resolving category names
 	categoryIDs, err := sqlite.GetOrCreateCategoryIDs(db, categoryNames)
 	if err != nil {
+		storage.DeleteURL(r.Context(), storage.Uploads, imageURL)
 		http.Error(w, "Failed to resolve categories", http.StatusInternalServerError)
 		return
 	}
 
 	// Create the post with categories
-	post, err := sqlite.CreatePost(db, userID, categoryIDs, sanitizedTitle, sanitizedContent, imageURL)
+	post, err := sqlite.CreatePost(db, userID, categoryIDs, sanitizedTitle, sanitizedContent, imageURL, contentStatus(verdict))
 	if err != nil {
 		log.Println("Error creating post:", err)
+		storage.DeleteURL(r.Context(), storage.Uploads, imageURL)
 		utils.SendJSONError(w, "Failed to create post", http.StatusInternalServerError)
 		return
 	}
 
+	if poll != nil {
+		if err := sqlite.CreatePoll(db, post.ID, *poll); err != nil {
+			log.Println("Error creating poll:", err)
+			sqlite.DeletePost(db, post.ID)
+			storage.DeleteURL(r.Context(), storage.Uploads, imageURL)
+			utils.SendJSONError(w, "Failed to create post", http.StatusInternalServerError)
+			return
+		}
+		if err := sqlite.AttachPoll(db, viewerFor(db, userID), &post); err != nil {
+			log.Printf("Warning: Failed to read poll of post %d: %v", post.ID, err)
+		}
+	}
+
+	status := holdIfFlagged(db, verdict, models.ContentPost, post.ID, userID, http.StatusCreated)
+	recordMentions(db, models.ContentPost, post.ID, userID, post.Content, post.Status)
+	if post.Status == models.ContentPublished {
+		checkBadges(db, userID)
+		notifyFollowers(db, userID, post.ID)
+	}
+
 	// Send response
-	utils.SendJSONResponse(w, post, http.StatusCreated)
+	utils.SendJSONResponse(w, post, status)
 }
 
 // GetPosts fetches posts (with optional filters)
@@ -146,8 +180,10 @@ func GetPosts(db *sql.DB, w http.ResponseWriter, r *http.Request) {
 	// Extract pagination parameters from the URL query
 	page, limit := utils.GetPaginationParams(r)
 
+	filter := models.PostFilter{Unanswered: r.URL.Query().Get("unanswered") == "true"}
+
 	// Fetch posts with pagination
-	posts, err := sqlite.GetPosts(db, page, limit)
+	posts, err := sqlite.GetPosts(db, requestViewer(db, r), filter, page, limit)
 	if err != nil {
 		fmt.Println("THE ERROR IS HERE")
 		utils.SendJSONError(w, "Failed to fetch posts", http.StatusInternalServerError)
@@ -187,7 +223,7 @@ func GetLikedPosts(db *sql.DB, w http.ResponseWriter, r *http.Request) {
 	page, limit := utils.GetPaginationParams(r)
 
 	// Fetch posts liked by the user
-	posts, err := sqlite.GetPostsLikedByUser(db, userID, page, limit)
+	posts, err := sqlite.GetPostsLikedByUser(db, viewerFor(db, userID), page, limit)
 	if err != nil {
 		utils.SendJSONError(w, "Failed to fetch liked posts", http.StatusInternalServerError)
 		return
@@ -230,7 +266,11 @@ func UpdatePost(db *sql.DB, w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Ensure the post belongs to the user
-	existingPostData, err := sqlite.GetPost(db, post.ID)
+	existingPostData, err := sqlite.GetPost(db, viewerFor(db, userID), post.ID)
+	if err == sql.ErrNoRows {
+		utils.SendJSONError(w, "Post not found", http.StatusNotFound)
+		return
+	}
 	if err != nil {
 		utils.SendJSONError(w, "Failed to read post data", http.StatusInternalServerError)
 		return
@@ -241,13 +281,45 @@ func UpdatePost(db *sql.DB, w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// Sanitize the title and validate the content as on creation
+	post.Title, err = utils.ValidateAndSanitizeString(post.Title, 200, "title")
+	if err != nil {
+		utils.SendJSONError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	post.Content, err = utils.ValidateString(post.Content, 10000, "content")
+	if err != nil {
+		utils.SendJSONError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	// Saving without changes leaves the post as it is
+	if post.Title == existingPostData.Title && post.Content == existingPostData.Content {
+		utils.SendJSONResponse(w, existingPostData, http.StatusOK)
+		return
+	}
+
+	verdict, ok := screenContent(db, w, userID, post.Title+"\n"+post.Content, true)
+	if !ok {
+		return
+	}
+
 	err = sqlite.UpdatePost(db, post.ID, post.Title, post.Content)
 	if err != nil {
 		utils.SendJSONError(w, "Failed to update post", http.StatusInternalServerError)
 		return
 	}
 
-	utils.SendJSONResponse(w, post, http.StatusOK)
+	post.ContentHTML = markdown.RenderCached(post.Content)
+
+	// An edit can't publish a post that is still waiting for review
+	post.Status = existingPostData.Status
+	if verdict.Action == spamfilter.Flag {
+		post.Status = models.ContentPending
+	}
+	status := holdIfFlagged(db, verdict, models.ContentPost, post.ID, userID, http.StatusOK)
+	recordMentions(db, models.ContentPost, post.ID, userID, post.Content, post.Status)
+	utils.SendJSONResponse(w, post, status)
 }
 
 func DeletePost(db *sql.DB, w http.ResponseWriter, r *http.Request) {
@@ -272,7 +344,11 @@ func DeletePost(db *sql.DB, w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Ensure the post belongs to the user
-	existingPostData, err := sqlite.GetPost(db, request.PostID)
+	existingPostData, err := sqlite.GetPost(db, viewerFor(db, userID), request.PostID)
+	if err == sql.ErrNoRows {
+		utils.SendJSONError(w, "Post not found", http.StatusNotFound)
+		return
+	}
 	if err != nil {
 		utils.SendJSONError(w, "Failed to read post data", http.StatusInternalServerError)
 		return
@@ -289,6 +365,13 @@ func DeletePost(db *sql.DB, w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// Remove the post image; anything missed here is picked up by the upload GC
+	if existingPostData.ImageURL != nil {
+		if err := storage.DeleteURL(r.Context(), storage.Uploads, *existingPostData.ImageURL); err != nil {
+			log.Printf("Warning: Failed to delete image for post %d: %v", request.PostID, err)
+		}
+	}
+
 	utils.SendJSONResponse(w, map[string]string{"message": "Post deleted"}, http.StatusOK)
 }
 
@@ -308,7 +391,7 @@ func GetPostComments(db *sql.DB, w http.ResponseWriter, r *http.Request) {
 		http.Error(w, "Invalid post_id parameter", http.StatusBadRequest)
 		return
 	}
-	comments, err := sqlite.GetPostComments(db, postID)
+	comments, err := sqlite.GetPostComments(db, requestViewer(db, r), postID)
 	if err != nil {
 		utils.SendJSONError(w, "Failed to fetch comments", http.StatusInternalServerError)
 		return
@@ -1,10 +1,12 @@
 package handlers
 
 import (
+	"bytes"
 	"database/sql"
 	"encoding/json"
 	"net/http"
 	"net/http/httptest"
+	"strings"
 	"testing"
 
 	"forum/models"
@@ -34,6 +36,11 @@ func setupPostTestDB(t *testing.T) *sql.DB {
 		email TEXT UNIQUE NOT NULL,
 		password_hash TEXT NOT NULL,
 		avatar_url TEXT DEFAULT '/static/default-avatar.png',
+		role TEXT NOT NULL DEFAULT 'user',
+		status TEXT NOT NULL DEFAULT 'active',
+		invited_by TEXT,
+		invite_code TEXT,
+		reputation INTEGER NOT NULL DEFAULT 0,
 		created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
 		updated_at DATETIME DEFAULT CURRENT_TIMESTAMP
 	);
@@ -49,6 +56,9 @@ func setupPostTestDB(t *testing.T) *sql.DB {
 		title TEXT NOT NULL,
 		content TEXT NOT NULL,
 		image_url TEXT,
+		status TEXT NOT NULL DEFAULT 'published',
+		accepted_comment_id INTEGER REFERENCES comments(id) ON DELETE SET NULL,
+		accepted_reply_id INTEGER REFERENCES replycomments(id) ON DELETE SET NULL,
 		created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
 		updated_at DATETIME DEFAULT CURRENT_TIMESTAMP,
 		FOREIGN KEY (user_id) REFERENCES users(id)
@@ -79,6 +89,244 @@ func setupPostTestDB(t *testing.T) *sql.DB {
 		created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
 		FOREIGN KEY (user_id) REFERENCES users(id)
 	);
+
+	CREATE TABLE api_tokens (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		user_id TEXT NOT NULL,
+		name TEXT NOT NULL,
+		token_hash TEXT NOT NULL UNIQUE,
+		prefix TEXT NOT NULL,
+		scopes TEXT NOT NULL,
+		expires_at DATETIME NOT NULL,
+		last_used_at DATETIME,
+		created_at DATETIME DEFAULT CURRENT_TIMESTAMP
+	);
+
+	CREATE TABLE comments (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		user_id TEXT NOT NULL,
+		post_id INTEGER NOT NULL,
+		content TEXT NOT NULL,
+		status TEXT NOT NULL DEFAULT 'published',
+		created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+		updated_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+		FOREIGN KEY (user_id) REFERENCES users(id),
+		FOREIGN KEY (post_id) REFERENCES posts(id) ON DELETE CASCADE
+	);
+
+	CREATE TABLE replycomments (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		user_id TEXT NOT NULL,
+		parent_comment_id INTEGER NOT NULL,
+		content TEXT NOT NULL,
+		status TEXT NOT NULL DEFAULT 'published',
+		created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+		updated_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+		FOREIGN KEY (user_id) REFERENCES users(id),
+		FOREIGN KEY (parent_comment_id) REFERENCES comments(id) ON DELETE CASCADE
+	);
+
+	CREATE TABLE notifications (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		user_id TEXT NOT NULL,
+		type TEXT NOT NULL,
+		message TEXT NOT NULL,
+		link TEXT DEFAULT '',
+		is_read BOOLEAN NOT NULL DEFAULT 0,
+		created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+		FOREIGN KEY (user_id) REFERENCES users(id)
+	);
+
+	CREATE TABLE moderation_queue (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		content_type TEXT NOT NULL,
+		content_id INTEGER NOT NULL,
+		user_id TEXT NOT NULL,
+		reasons TEXT NOT NULL,
+		spam_score REAL,
+		created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+		UNIQUE (content_type, content_id)
+	);
+
+	CREATE TABLE spam_tokens (
+		token TEXT PRIMARY KEY,
+		spam INTEGER NOT NULL DEFAULT 0,
+		ham INTEGER NOT NULL DEFAULT 0
+	);
+
+	CREATE TABLE spam_corpus (
+		label TEXT PRIMARY KEY,
+		documents INTEGER NOT NULL DEFAULT 0
+	);
+
+	CREATE TABLE content_fingerprints (
+		fingerprint TEXT NOT NULL,
+		user_id TEXT NOT NULL,
+		created_at DATETIME DEFAULT CURRENT_TIMESTAMP
+	);
+
+	CREATE TABLE mentions (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		content_type TEXT NOT NULL,
+		content_id INTEGER NOT NULL,
+		user_id TEXT NOT NULL,
+		author_id TEXT NOT NULL,
+		notified BOOLEAN NOT NULL DEFAULT 0,
+		created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+		UNIQUE (content_type, content_id, user_id)
+	);
+
+	CREATE TABLE polls (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		post_id INTEGER NOT NULL UNIQUE,
+		question TEXT NOT NULL,
+		multiple BOOLEAN NOT NULL DEFAULT 0,
+		hide_results BOOLEAN NOT NULL DEFAULT 0,
+		closes_at DATETIME NOT NULL,
+		created_at DATETIME DEFAULT CURRENT_TIMESTAMP
+	);
+
+	CREATE TABLE poll_options (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		poll_id INTEGER NOT NULL,
+		position INTEGER NOT NULL,
+		label TEXT NOT NULL,
+		UNIQUE (poll_id, id)
+	);
+
+	CREATE TABLE poll_ballots (
+		poll_id INTEGER NOT NULL,
+		user_id TEXT NOT NULL,
+		created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+		PRIMARY KEY (poll_id, user_id)
+	);
+
+	CREATE TABLE poll_votes (
+		poll_id INTEGER NOT NULL,
+		user_id TEXT NOT NULL,
+		option_id INTEGER NOT NULL,
+		PRIMARY KEY (poll_id, user_id, option_id),
+		FOREIGN KEY (poll_id, user_id) REFERENCES poll_ballots(poll_id, user_id),
+		FOREIGN KEY (poll_id, option_id) REFERENCES poll_options(poll_id, id)
+	);
+
+	CREATE TRIGGER poll_votes_single_choice
+	BEFORE INSERT ON poll_votes
+	FOR EACH ROW
+	WHEN (SELECT multiple FROM polls WHERE id = NEW.poll_id) = 0
+		AND EXISTS (SELECT 1 FROM poll_votes WHERE poll_id = NEW.poll_id AND user_id = NEW.user_id)
+	BEGIN
+		SELECT RAISE(ABORT, 'single choice poll');
+	END;
+
+	CREATE TABLE reputation_events (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		user_id TEXT NOT NULL,
+		reason TEXT NOT NULL,
+		content_type TEXT NOT NULL,
+		content_id INTEGER NOT NULL,
+		actor_id TEXT NOT NULL,
+		points INTEGER NOT NULL,
+		created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+		UNIQUE (reason, content_type, content_id, actor_id),
+		FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE,
+		FOREIGN KEY (actor_id) REFERENCES users(id) ON DELETE CASCADE
+	);
+
+	CREATE TRIGGER reputation_events_award
+	AFTER INSERT ON reputation_events
+	FOR EACH ROW
+	BEGIN
+		UPDATE users SET reputation = reputation + NEW.points WHERE id = NEW.user_id;
+	END;
+
+	CREATE TRIGGER reputation_events_revoke
+	AFTER DELETE ON reputation_events
+	FOR EACH ROW
+	BEGIN
+		UPDATE users SET reputation = reputation - OLD.points WHERE id = OLD.user_id;
+	END;
+
+	CREATE TABLE badge_awards (
+		user_id TEXT NOT NULL,
+		badge_id TEXT NOT NULL,
+		notified BOOLEAN NOT NULL DEFAULT 0,
+		awarded_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+		PRIMARY KEY (user_id, badge_id),
+		FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+	);
+
+	CREATE TABLE bookmarks (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		user_id TEXT NOT NULL,
+		post_id INTEGER,
+		comment_id INTEGER,
+		folder TEXT NOT NULL DEFAULT '',
+		note TEXT NOT NULL DEFAULT '',
+		created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+		CHECK ((post_id IS NULL) != (comment_id IS NULL)),
+		UNIQUE (user_id, post_id),
+		UNIQUE (user_id, comment_id),
+		FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE,
+		FOREIGN KEY (post_id) REFERENCES posts(id) ON DELETE CASCADE,
+		FOREIGN KEY (comment_id) REFERENCES comments(id) ON DELETE CASCADE
+	);
+
+	CREATE TABLE notification_preferences (
+		user_id TEXT NOT NULL,
+		type TEXT NOT NULL,
+		enabled BOOLEAN NOT NULL,
+		PRIMARY KEY (user_id, type),
+		FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+	);
+
+	CREATE TABLE user_follows (
+		follower_id TEXT NOT NULL,
+		followed_id TEXT NOT NULL,
+		created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+		PRIMARY KEY (follower_id, followed_id),
+		CHECK (follower_id != followed_id),
+		FOREIGN KEY (follower_id) REFERENCES users(id) ON DELETE CASCADE,
+		FOREIGN KEY (followed_id) REFERENCES users(id) ON DELETE CASCADE
+	);
+
+	CREATE TABLE category_follows (
+		user_id TEXT NOT NULL,
+		category_id INTEGER NOT NULL,
+		created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+		PRIMARY KEY (user_id, category_id),
+		FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE,
+		FOREIGN KEY (category_id) REFERENCES categories(id) ON DELETE CASCADE
+	);
+
+	CREATE TABLE user_blocks (
+		blocker_id TEXT NOT NULL,
+		blocked_id TEXT NOT NULL,
+		created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+		PRIMARY KEY (blocker_id, blocked_id),
+		CHECK (blocker_id != blocked_id),
+		FOREIGN KEY (blocker_id) REFERENCES users(id) ON DELETE CASCADE,
+		FOREIGN KEY (blocked_id) REFERENCES users(id) ON DELETE CASCADE
+	);
+
+	CREATE TABLE user_mutes (
+		user_id TEXT NOT NULL,
+		muted_id TEXT NOT NULL,
+		created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+		PRIMARY KEY (user_id, muted_id),
+		CHECK (user_id != muted_id),
+		FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE,
+		FOREIGN KEY (muted_id) REFERENCES users(id) ON DELETE CASCADE
+	);
+
+	CREATE TABLE category_mutes (
+		user_id TEXT NOT NULL,
+		category_id INTEGER NOT NULL,
+		created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+		PRIMARY KEY (user_id, category_id),
+		FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE,
+		FOREIGN KEY (category_id) REFERENCES categories(id) ON DELETE CASCADE
+	);
 	`
 
 	_, err = db.Exec(schema)
@@ -89,6 +337,40 @@ func setupPostTestDB(t *testing.T) *sql.DB {
 	return db
 }
 
+// createTestUsers creates signed in users and returns their IDs and
+// session IDs by username
+func createTestUsers(t *testing.T, db *sql.DB, names ...string) (ids, sessions map[string]string) {
+	t.Helper()
+	ids, sessions = map[string]string{}, map[string]string{}
+	for _, name := range names {
+		if err := sqlite.CreateUser(db, name, name+"@example.com", "hash", ""); err != nil {
+			t.Fatalf("Failed to create user %s: %v", name, err)
+		}
+		user, err := sqlite.GetUserByUsername(db, name)
+		if err != nil {
+			t.Fatalf("Failed to get user %s: %v", name, err)
+		}
+		ids[name] = user.ID
+		if sessions[name], err = sqlite.CreateSession(db, user.ID); err != nil {
+			t.Fatalf("Failed to create session for %s: %v", name, err)
+		}
+	}
+	return ids, sessions
+}
+
+// sendAs calls a handler with a JSON body, signed in with a session
+// unless it is empty
+func sendAs(db *sql.DB, handler func(*sql.DB, http.ResponseWriter, *http.Request), method, target, session string, body any) *httptest.ResponseRecorder {
+	payload, _ := json.Marshal(body)
+	req := httptest.NewRequest(method, target, bytes.NewReader(payload))
+	if session != "" {
+		req.AddCookie(&http.Cookie{Name: "session_id", Value: session})
+	}
+	w := httptest.NewRecorder()
+	handler(db, w, req)
+	return w
+}
+
 func TestGetLikedPosts(t *testing.T) {
 	db := setupPostTestDB(t)
 	defer db.Close()
@@ -107,7 +389,7 @@ func TestGetLikedPosts(t *testing.T) {
 	userID := user.ID
 
 	// Create a test post
-	post, err := sqlite.CreatePost(db, userID, []int{}, "Test Post", "This is a test post", "")
+	post, err := sqlite.CreatePost(db, userID, []int{}, "Test Post", "This is a test post", "", models.ContentPublished)
 	if err != nil {
 		t.Fatalf("Failed to create test post: %v", err)
 	}
@@ -192,3 +474,56 @@ func TestGetLikedPosts(t *testing.T) {
 		}
 	})
 }
+
+func TestMarkdownContent(t *testing.T) {
+	db := setupPostTestDB(t)
+	defer db.Close()
+
+	originalTrust := trustThreshold
+	defer func() { trustThreshold = originalTrust }()
+	trustThreshold = trustPolicy{}
+
+	sqlite.CreateUser(db, "alice", "alice@example.com", "hash", "")
+	alice, _ := sqlite.GetUserByUsername(db, "alice")
+	session, _ := sqlite.CreateSession(db, alice.ID)
+	post, _ := sqlite.CreatePost(db, alice.ID, []int{}, "Generics", "draft", "", models.ContentPublished)
+
+	send := func(handler func(http.ResponseWriter, *http.Request), method string, body any) *httptest.ResponseRecorder {
+		payload, _ := json.Marshal(body)
+		req := httptest.NewRequest(method, "/", bytes.NewReader(payload))
+		req.AddCookie(&http.Cookie{Name: "session_id", Value: session})
+		w := httptest.NewRecorder()
+		handler(w, req)
+		return w
+	}
+
+	content := "Compare with `a < b`:\n\n```go\nif a < b && ok {\n}\n```\n\n<script>alert(1)</script>"
+	w := send(func(w http.ResponseWriter, r *http.Request) { UpdatePost(db, w, r) }, "PUT",
+		map[string]any{"id": post.ID, "title": "Generics <T>", "content": content})
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected 200, got %d: %s", w.Code, w.Body.String())
+	}
+
+	stored, _ := sqlite.GetPost(db, models.Viewer{}, post.ID)
+	if stored.Content != content {
+		t.Errorf("Expected the Markdown stored as written, got %q", stored.Content)
+	}
+	if stored.Title != "Generics &lt;T&gt;" {
+		t.Errorf("Expected the title escaped, got %q", stored.Title)
+	}
+	if !strings.Contains(stored.ContentHTML, `<code class="language-go"><span class="hl-keyword">if</span> a &lt; b &amp;&amp; ok {`) ||
+		strings.Contains(stored.ContentHTML, "<script>") {
+		t.Errorf("Unexpected rendered content %q", stored.ContentHTML)
+	}
+
+	w = send(func(w http.ResponseWriter, r *http.Request) { CreateComment(db, w, r) }, "POST",
+		map[string]any{"post_id": post.ID, "content": "**Nice** & <em>clear</em>"})
+	if w.Code != http.StatusCreated {
+		t.Fatalf("Expected 201, got %d: %s", w.Code, w.Body.String())
+	}
+	var comment models.Comment
+	json.Unmarshal(w.Body.Bytes(), &comment)
+	if comment.Content != "**Nice** & <em>clear</em>" || comment.ContentHTML != "<p><strong>Nice</strong> &amp; <em>clear</em></p>\n" {
+		t.Errorf("Expected raw and rendered content, got %q and %q", comment.Content, comment.ContentHTML)
+	}
+}
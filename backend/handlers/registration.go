@@ -0,0 +1,322 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"forum/models"
+	"forum/sqlite"
+	"forum/storage"
+	"forum/utils"
+)
+
+// Registration modes, set with REGISTRATION_MODE
+const (
+	registrationOpen     = "open"     // anyone can sign up
+	registrationInvite   = "invite"   // a valid invite code is required
+	registrationApproval = "approval" // new accounts wait for an administrator
+)
+
+const (
+	inviteQuotaWindow     = 30 * 24 * time.Hour
+	defaultInviteDays     = 7
+	maxInviteDays         = 90
+	maxInviteUses         = 100
+	defaultInviteQuota    = 5
+	pendingApprovalNotice = "Your account was created and is waiting for approval by an administrator"
+)
+
+// registrationMode and inviteQuota are read once at startup; replaced in tests
+var (
+	registrationMode = loadRegistrationMode()
+	inviteQuota      = loadInviteQuota()
+)
+
+func loadRegistrationMode() string {
+	switch mode := strings.ToLower(strings.TrimSpace(os.Getenv("REGISTRATION_MODE"))); mode {
+	case "", registrationOpen:
+		return registrationOpen // fallback default
+	case registrationInvite, "invite-only":
+		return registrationInvite
+	case registrationApproval, "admin-approval":
+		return registrationApproval
+	default:
+		// Fail closed rather than opening sign-ups by accident
+		log.Printf("⚠️  Unknown REGISTRATION_MODE %q, registration is invite-only", mode)
+		return registrationInvite
+	}
+}
+
+// loadInviteQuota reads how many invites a regular user may create per
+// 30 days; 0 leaves invites to administrators
+func loadInviteQuota() int {
+	value := os.Getenv("INVITE_QUOTA")
+	if value == "" {
+		return defaultInviteQuota // fallback default
+	}
+	n, err := strconv.Atoi(value)
+	if err != nil || n < 0 {
+		log.Printf("⚠️  Invalid INVITE_QUOTA %q, using %d", value, defaultInviteQuota)
+		return defaultInviteQuota
+	}
+	return n
+}
+
+// GetRegistrationMode tells the sign-up form whether an invite code is needed
+func GetRegistrationMode(db *sql.DB, w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	utils.SendJSONResponse(w, map[string]any{
+		"mode":            registrationMode,
+		"invite_required": registrationMode == registrationInvite,
+	}, http.StatusOK)
+}
+
+// CreateInvite issues an invite code. Administrators are unlimited, other
+// users get inviteQuota invites per 30 days.
+func CreateInvite(db *sql.DB, w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var request struct {
+		MaxUses       int `json:"max_uses"`
+		ExpiresInDays int `json:"expires_in_days"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		http.Error(w, "Invalid request data", http.StatusBadRequest)
+		return
+	}
+
+	userID, err := utils.GetUserIDFromSession(db, r)
+	if err != nil || userID == "" {
+		utils.SendJSONError(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	if request.MaxUses == 0 {
+		request.MaxUses = 1
+	}
+	if request.MaxUses < 1 || request.MaxUses > maxInviteUses {
+		utils.SendJSONError(w, fmt.Sprintf("An invite can be used 1 to %d times", maxInviteUses), http.StatusBadRequest)
+		return
+	}
+	if request.ExpiresInDays == 0 {
+		request.ExpiresInDays = defaultInviteDays
+	}
+	if request.ExpiresInDays < 1 || request.ExpiresInDays > maxInviteDays {
+		utils.SendJSONError(w, fmt.Sprintf("Invites expire after 1 to %d days", maxInviteDays), http.StatusBadRequest)
+		return
+	}
+
+	role, err := sqlite.GetUserRole(db, userID)
+	if err != nil {
+		utils.SendJSONError(w, "Database error", http.StatusInternalServerError)
+		return
+	}
+	if role != models.RoleAdmin {
+		if inviteQuota == 0 {
+			utils.SendJSONError(w, "Only administrators can create invites", http.StatusForbidden)
+			return
+		}
+		count, err := sqlite.CountInvitesSince(db, userID, time.Now().Add(-inviteQuotaWindow))
+		if err != nil {
+			utils.SendJSONError(w, "Database error", http.StatusInternalServerError)
+			return
+		}
+		if count >= inviteQuota {
+			utils.SendJSONError(w, fmt.Sprintf("You can create %d invites per 30 days", inviteQuota), http.StatusTooManyRequests)
+			return
+		}
+	}
+
+	expiresAt := time.Now().Add(time.Duration(request.ExpiresInDays) * 24 * time.Hour)
+	invite, err := sqlite.CreateInvite(db, userID, request.MaxUses, expiresAt)
+	if err != nil {
+		utils.SendJSONError(w, "Failed to create invite", http.StatusInternalServerError)
+		return
+	}
+	utils.SendJSONResponse(w, invite, http.StatusCreated)
+}
+
+// GetInvites lists the current user's invites and who used them.
+// Administrators can pass ?all=true to see every invite.
+func GetInvites(db *sql.DB, w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	userID, err := utils.GetUserIDFromSession(db, r)
+	if err != nil || userID == "" {
+		utils.SendJSONError(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	createdBy := userID
+	if r.URL.Query().Get("all") == "true" {
+		role, err := sqlite.GetUserRole(db, userID)
+		if err != nil {
+			utils.SendJSONError(w, "Database error", http.StatusInternalServerError)
+			return
+		}
+		if role != models.RoleAdmin {
+			utils.SendJSONError(w, "Administrator access required", http.StatusForbidden)
+			return
+		}
+		createdBy = ""
+	}
+
+	invites, err := sqlite.GetInvites(db, createdBy)
+	if err != nil {
+		utils.SendJSONError(w, "Database error", http.StatusInternalServerError)
+		return
+	}
+	utils.SendJSONResponse(w, invites, http.StatusOK)
+}
+
+// RevokeInvite deletes an invite so it can't be used any more. Users can
+// revoke their own invites, administrators any invite.
+func RevokeInvite(db *sql.DB, w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodDelete {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var request struct {
+		Code string `json:"code"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil || request.Code == "" {
+		http.Error(w, "Invalid request data", http.StatusBadRequest)
+		return
+	}
+
+	userID, err := utils.GetUserIDFromSession(db, r)
+	if err != nil || userID == "" {
+		utils.SendJSONError(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	role, err := sqlite.GetUserRole(db, userID)
+	if err != nil {
+		utils.SendJSONError(w, "Database error", http.StatusInternalServerError)
+		return
+	}
+	createdBy := userID
+	if role == models.RoleAdmin {
+		createdBy = ""
+	}
+
+	err = sqlite.DeleteInvite(db, request.Code, createdBy)
+	if err == sql.ErrNoRows {
+		utils.SendJSONError(w, "Invite not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		utils.SendJSONError(w, "Database error", http.StatusInternalServerError)
+		return
+	}
+	utils.SendJSONResponse(w, map[string]string{"message": "Invite revoked"}, http.StatusOK)
+}
+
+// GetPendingUsers lists the accounts waiting for approval (admin only)
+func GetPendingUsers(db *sql.DB, w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	users, err := sqlite.GetPendingUsers(db)
+	if err != nil {
+		utils.SendJSONError(w, "Database error", http.StatusInternalServerError)
+		return
+	}
+	utils.SendJSONResponse(w, users, http.StatusOK)
+}
+
+// ApprovePendingUser activates a pending account (admin only)
+func ApprovePendingUser(db *sql.DB, w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var request struct {
+		UserID string `json:"user_id"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil || request.UserID == "" {
+		http.Error(w, "Invalid request data", http.StatusBadRequest)
+		return
+	}
+
+	err := sqlite.ApproveUser(db, request.UserID)
+	if err == sql.ErrNoRows {
+		utils.SendJSONError(w, "No pending user with this ID", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		utils.SendJSONError(w, "Database error", http.StatusInternalServerError)
+		return
+	}
+
+	if err := sqlite.CreateNotification(db, request.UserID, "account", "Your account was approved, welcome!", ""); err != nil {
+		log.Printf("Warning: Failed to notify user %s: %v", request.UserID, err)
+	}
+	log.Printf("👤 User %s approved", request.UserID)
+	utils.SendJSONResponse(w, map[string]string{"message": "User approved"}, http.StatusOK)
+}
+
+// RejectPendingUser deletes a pending account and its avatar (admin only)
+func RejectPendingUser(db *sql.DB, w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var request struct {
+		UserID string `json:"user_id"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil || request.UserID == "" {
+		http.Error(w, "Invalid request data", http.StatusBadRequest)
+		return
+	}
+
+	avatarURL, err := sqlite.RejectUser(db, request.UserID)
+	if err == sql.ErrNoRows {
+		utils.SendJSONError(w, "No pending user with this ID", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		utils.SendJSONError(w, "Database error", http.StatusInternalServerError)
+		return
+	}
+
+	storage.DeleteURL(r.Context(), storage.Uploads, avatarURL)
+	log.Printf("👤 User %s rejected", request.UserID)
+	utils.SendJSONResponse(w, map[string]string{"message": "User rejected"}, http.StatusOK)
+}
+
+// notifyAdminsOfPendingUser tells every administrator a new account waits for approval
+func notifyAdminsOfPendingUser(db *sql.DB, username string) {
+	adminIDs, err := sqlite.GetAdminIDs(db)
+	if err != nil {
+		log.Printf("Warning: Failed to look up administrators: %v", err)
+		return
+	}
+	message := fmt.Sprintf("%s signed up and is waiting for approval.", username)
+	for _, adminID := range adminIDs {
+		if err := sqlite.CreateNotification(db, adminID, "account", message, ""); err != nil {
+			log.Printf("Warning: Failed to notify user %s: %v", adminID, err)
+		}
+	}
+}
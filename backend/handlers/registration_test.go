@@ -0,0 +1,215 @@
+package handlers
+
+import (
+	"bytes"
+	"database/sql"
+	"encoding/json"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"forum/models"
+	"forum/sqlite"
+	"forum/utils"
+)
+
+func TestRegistrationModes(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	originalMode, originalQuota := registrationMode, inviteQuota
+	defer func() { registrationMode, inviteQuota = originalMode, originalQuota }()
+	inviteQuota = 2
+
+	passwordHash, _ := utils.HashPassword("password123")
+	for _, name := range []string{"admin", "member"} {
+		if err := sqlite.CreateUser(db, name, name+"@example.com", passwordHash, ""); err != nil {
+			t.Fatalf("Failed to create test user: %v", err)
+		}
+	}
+	if err := sqlite.SetUserRole(db, "admin", models.RoleAdmin); err != nil {
+		t.Fatalf("SetUserRole failed: %v", err)
+	}
+	admin, _ := sqlite.GetUserByUsername(db, "admin")
+	member, _ := sqlite.GetUserByUsername(db, "member")
+	adminSession, _ := sqlite.CreateSession(db, admin.ID)
+	memberSession, _ := sqlite.CreateSession(db, member.ID)
+
+	call := func(handler func(*sql.DB, http.ResponseWriter, *http.Request), method, target, sessionID string, body any) *httptest.ResponseRecorder {
+		jsonData, _ := json.Marshal(body)
+		req := httptest.NewRequest(method, target, bytes.NewBuffer(jsonData))
+		req.AddCookie(&http.Cookie{Name: utils.SessionCookieName, Value: sessionID})
+		w := httptest.NewRecorder()
+		handler(db, w, req)
+		return w
+	}
+
+	register := func(username, inviteCode string) (*httptest.ResponseRecorder, map[string]string) {
+		var buf bytes.Buffer
+		writer := multipart.NewWriter(&buf)
+		writer.WriteField("username", username)
+		writer.WriteField("email", username+"@example.com")
+		writer.WriteField("password", "Tr4il-mix-sunset")
+		if inviteCode != "" {
+			writer.WriteField("invite_code", inviteCode)
+		}
+		writer.Close()
+		req := httptest.NewRequest("POST", "/api/register", &buf)
+		req.Header.Set("Content-Type", writer.FormDataContentType())
+		w := httptest.NewRecorder()
+		RegisterUser(db, w, req)
+		var response map[string]string
+		json.Unmarshal(w.Body.Bytes(), &response)
+		return w, response
+	}
+
+	login := func(username string) int {
+		jsonData, _ := json.Marshal(map[string]string{"username": username, "password": "Tr4il-mix-sunset"})
+		w := httptest.NewRecorder()
+		LoginUser(db, w, httptest.NewRequest("POST", "/api/login", bytes.NewBuffer(jsonData)))
+		return w.Code
+	}
+
+	createInvite := func(sessionID string, body map[string]any) (*httptest.ResponseRecorder, models.Invite) {
+		w := call(CreateInvite, "POST", "/api/invites/create", sessionID, body)
+		var invite models.Invite
+		json.Unmarshal(w.Body.Bytes(), &invite)
+		return w, invite
+	}
+
+	var memberInvite models.Invite
+
+	t.Run("invite-only requires a valid code", func(t *testing.T) {
+		registrationMode = registrationInvite
+
+		if w, _ := register("nocode", ""); w.Code != http.StatusForbidden {
+			t.Errorf("Expected 403 without a code, got %d", w.Code)
+		}
+		if w, _ := register("badcode", "NOTACODE"); w.Code != http.StatusForbidden {
+			t.Errorf("Expected 403 for an unknown code, got %d", w.Code)
+		}
+
+		var w *httptest.ResponseRecorder
+		w, memberInvite = createInvite(memberSession, map[string]any{})
+		if w.Code != http.StatusCreated || memberInvite.MaxUses != 1 {
+			t.Fatalf("Expected a single-use invite, got %d: %s", w.Code, w.Body.String())
+		}
+
+		w, response := register("invitee", memberInvite.Code)
+		if w.Code != http.StatusCreated || response["invited_by"] != "member" {
+			t.Fatalf("Expected registration invited by member, got %d: %s", w.Code, w.Body.String())
+		}
+		if w, _ := register("second", memberInvite.Code); w.Code != http.StatusForbidden {
+			t.Errorf("Expected a used up invite to be refused, got %d", w.Code)
+		}
+		if login("invitee") != http.StatusOK {
+			t.Error("Expected the invited user to log in")
+		}
+		notifications, _ := sqlite.GetNotifications(db, member.ID, 1, 10)
+		if len(notifications) != 1 {
+			t.Errorf("Expected the inviter to be notified, got %d notifications", len(notifications))
+		}
+	})
+
+	t.Run("multi-use invite", func(t *testing.T) {
+		w, invite := createInvite(adminSession, map[string]any{"max_uses": 2, "expires_in_days": 1})
+		if w.Code != http.StatusCreated {
+			t.Fatalf("Expected 201, got %d", w.Code)
+		}
+		for _, name := range []string{"multi1", "multi2"} {
+			if w, _ := register(name, invite.Code); w.Code != http.StatusCreated {
+				t.Errorf("Expected %s to register, got %d", name, w.Code)
+			}
+		}
+		if w, _ := register("multi3", invite.Code); w.Code != http.StatusForbidden {
+			t.Errorf("Expected the third use to be refused, got %d", w.Code)
+		}
+	})
+
+	t.Run("quota and limits", func(t *testing.T) {
+		if w, _ := createInvite(memberSession, map[string]any{"max_uses": 101}); w.Code != http.StatusBadRequest {
+			t.Errorf("Expected 400 for too many uses, got %d", w.Code)
+		}
+		if w, _ := createInvite(memberSession, map[string]any{}); w.Code != http.StatusCreated {
+			t.Errorf("Expected the second invite within quota, got %d", w.Code)
+		}
+		if w, _ := createInvite(memberSession, map[string]any{}); w.Code != http.StatusTooManyRequests {
+			t.Errorf("Expected the quota to be enforced, got %d", w.Code)
+		}
+		if w, _ := createInvite(adminSession, map[string]any{}); w.Code != http.StatusCreated {
+			t.Errorf("Expected administrators to be unlimited, got %d", w.Code)
+		}
+	})
+
+	t.Run("listing and revoking", func(t *testing.T) {
+		var invites []models.Invite
+		json.Unmarshal(call(GetInvites, "GET", "/api/invites", memberSession, nil).Body.Bytes(), &invites)
+		if len(invites) != 2 {
+			t.Fatalf("Expected member's 2 invites, got %d", len(invites))
+		}
+		if used := invites[1]; used.Code != memberInvite.Code || len(used.Invitees) != 1 || used.Invitees[0] != "invitee" {
+			t.Errorf("Expected the first invite to list its invitee, got %+v", used)
+		}
+		if w := call(GetInvites, "GET", "/api/invites?all=true", memberSession, nil); w.Code != http.StatusForbidden {
+			t.Errorf("Expected only administrators to list every invite, got %d", w.Code)
+		}
+		json.Unmarshal(call(GetInvites, "GET", "/api/invites?all=true", adminSession, nil).Body.Bytes(), &invites)
+		if len(invites) != 4 {
+			t.Errorf("Expected 4 invites in total, got %d", len(invites))
+		}
+
+		adminInvite := invites[0]
+		if w := call(RevokeInvite, "DELETE", "/", memberSession, map[string]string{"code": adminInvite.Code}); w.Code != http.StatusNotFound {
+			t.Errorf("Expected members not to revoke others' invites, got %d", w.Code)
+		}
+		if w := call(RevokeInvite, "DELETE", "/", memberSession, map[string]string{"code": invites[1].Code}); w.Code != http.StatusOK {
+			t.Errorf("Expected members to revoke their own invites, got %d", w.Code)
+		}
+		if w := call(RevokeInvite, "DELETE", "/", adminSession, map[string]string{"code": adminInvite.Code}); w.Code != http.StatusOK {
+			t.Errorf("Expected administrators to revoke any invite, got %d", w.Code)
+		}
+	})
+
+	t.Run("admin approval", func(t *testing.T) {
+		registrationMode = registrationApproval
+
+		for _, name := range []string{"waiting", "spammer"} {
+			w, response := register(name, "")
+			if w.Code != http.StatusCreated || response["status"] != models.StatusPending {
+				t.Fatalf("Expected a pending registration, got %d: %s", w.Code, w.Body.String())
+			}
+		}
+		if code := login("waiting"); code != http.StatusForbidden {
+			t.Errorf("Expected pending users not to log in, got %d", code)
+		}
+		// Two on top of the notices for multi1 and multi2 using the admin's invite
+		notifications, _ := sqlite.GetNotifications(db, admin.ID, 1, 10)
+		if len(notifications) != 4 {
+			t.Errorf("Expected administrators to be notified, got %d notifications", len(notifications))
+		}
+
+		var pending []models.PendingUser
+		json.Unmarshal(call(GetPendingUsers, "GET", "/", adminSession, nil).Body.Bytes(), &pending)
+		if len(pending) != 2 || pending[0].Username != "waiting" {
+			t.Fatalf("Expected 2 pending users, got %+v", pending)
+		}
+
+		if w := call(ApprovePendingUser, "POST", "/", adminSession, map[string]string{"user_id": pending[0].ID}); w.Code != http.StatusOK {
+			t.Errorf("Expected approval, got %d", w.Code)
+		}
+		if w := call(ApprovePendingUser, "POST", "/", adminSession, map[string]string{"user_id": pending[0].ID}); w.Code != http.StatusNotFound {
+			t.Errorf("Expected approving twice to fail, got %d", w.Code)
+		}
+		if code := login("waiting"); code != http.StatusOK {
+			t.Errorf("Expected approved users to log in, got %d", code)
+		}
+
+		if w := call(RejectPendingUser, "POST", "/", adminSession, map[string]string{"user_id": pending[1].ID}); w.Code != http.StatusOK {
+			t.Errorf("Expected rejection, got %d", w.Code)
+		}
+		if _, err := sqlite.GetUserByUsername(db, "spammer"); err != sql.ErrNoRows {
+			t.Errorf("Expected the rejected user to be deleted, got %v", err)
+		}
+	})
+}
@@ -0,0 +1,87 @@
+package handlers
+
+import (
+	"database/sql"
+	"net/http"
+	"strconv"
+	"time"
+
+	"forum/sqlite"
+	"forum/utils"
+)
+
+const maxLeaderboard = 100
+
+// leaderboardPeriods are how far back ?period= counts reputation; "all"
+// ranks users by their reputation
+var leaderboardPeriods = map[string]time.Duration{
+	"all":   0,
+	"month": 30 * 24 * time.Hour,
+	"week":  7 * 24 * time.Hour,
+	"day":   24 * time.Hour,
+}
+
+// GetReputationHistory returns a page of the reputation a user gained and
+// lost, for ?username=
+func GetReputationHistory(db *sql.DB, w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	profile, err := sqlite.GetPublicProfile(db, r.URL.Query().Get("username"))
+	if err == sql.ErrNoRows {
+		utils.SendJSONError(w, "User not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		utils.SendJSONError(w, "Database error", http.StatusInternalServerError)
+		return
+	}
+
+	page, limit := utils.GetPaginationParams(r)
+	history, err := sqlite.GetReputationHistory(db, profile.ID, page, min(limit, 50))
+	if err != nil {
+		utils.SendJSONError(w, "Failed to fetch reputation history", http.StatusInternalServerError)
+		return
+	}
+	utils.SendJSONResponse(w, map[string]any{
+		"reputation": profile.Reputation,
+		"history":    history,
+	}, http.StatusOK)
+}
+
+// GetLeaderboard ranks users by reputation, over all time or the
+// reputation gained in the last ?period=month, week or day
+func GetLeaderboard(db *sql.DB, w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	period := r.URL.Query().Get("period")
+	if period == "" {
+		period = "all"
+	}
+	window, ok := leaderboardPeriods[period]
+	if !ok {
+		utils.SendJSONError(w, "Unknown period, use all, month, week or day", http.StatusBadRequest)
+		return
+	}
+	var since time.Time
+	if window > 0 {
+		since = time.Now().Add(-window)
+	}
+
+	limit, err := strconv.Atoi(r.URL.Query().Get("limit"))
+	if err != nil || limit < 1 {
+		limit = 20
+	}
+
+	leaders, err := sqlite.GetReputationLeaderboard(db, since, min(limit, maxLeaderboard))
+	if err != nil {
+		utils.SendJSONError(w, "Failed to fetch leaderboard", http.StatusInternalServerError)
+		return
+	}
+	utils.SendJSONResponse(w, leaders, http.StatusOK)
+}
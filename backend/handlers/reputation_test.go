@@ -0,0 +1,114 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"forum/models"
+	"forum/sqlite"
+)
+
+func TestReputation(t *testing.T) {
+	db := setupPostTestDB(t)
+	defer db.Close()
+
+	users, _ := createTestUsers(t, db, "alice", "bob", "carol")
+	reputation := func(name string) int {
+		profile, _ := sqlite.GetPublicProfile(db, name)
+		return profile.Reputation
+	}
+	react := func(name string, postID, commentID *int, reaction string) {
+		if err := sqlite.ToggleLike(db, users[name], postID, commentID, reaction); err != nil {
+			t.Fatalf("ToggleLike failed: %v", err)
+		}
+	}
+
+	post, _ := sqlite.CreatePost(db, users["alice"], []int{}, "Hello", "World", "", models.ContentPublished)
+	comment, _ := sqlite.CreateComment(db, users["alice"], post.ID, "Hi", models.ContentPublished)
+
+	react("bob", &post.ID, nil, "like")
+	react("carol", nil, &comment.ID, "like")
+	react("alice", &post.ID, nil, "like")
+	if got := reputation("alice"); got != models.PostLikePoints+models.CommentLikePoints {
+		t.Errorf("Expected likes from others counted, got %d", got)
+	}
+
+	// Switching a like to a dislike reverses it, toggling off takes it back
+	react("bob", &post.ID, nil, "dislike")
+	if got := reputation("alice"); got != models.PostDislikePoints+models.CommentLikePoints {
+		t.Errorf("Expected the like reversed, got %d", got)
+	}
+	react("bob", &post.ID, nil, "dislike")
+	react("carol", nil, &comment.ID, "like")
+	if got := reputation("alice"); got != 0 {
+		t.Errorf("Expected removed reactions taken back, got %d", got)
+	}
+
+	t.Run("daily cap", func(t *testing.T) {
+		for i := 0; i < models.DailyReactionReputation/models.PostLikePoints+3; i++ {
+			name := fmt.Sprintf("fan%d", i)
+			sqlite.CreateUser(db, name, name+"@example.com", "hash", "")
+			user, _ := sqlite.GetUserByUsername(db, name)
+			users[name] = user.ID
+			react(name, &post.ID, nil, "like")
+		}
+		if got := reputation("alice"); got != models.DailyReactionReputation {
+			t.Errorf("Expected likes capped at %d a day, got %d", models.DailyReactionReputation, got)
+		}
+		react("bob", &post.ID, nil, "dislike")
+		if got := reputation("alice"); got != models.DailyReactionReputation+models.PostDislikePoints {
+			t.Errorf("Expected dislikes counted past the cap, got %d", got)
+		}
+
+		// Recounting from the likes alone gives the same result
+		db.Exec(`DELETE FROM reputation_events`)
+		db.Exec(`UPDATE users SET reputation = 0`)
+		if counted, err := sqlite.RecountReputation(db); err != nil || counted != models.DailyReactionReputation/models.PostLikePoints+4 {
+			t.Errorf("Expected every reaction recounted, got %d, %v", counted, err)
+		}
+		if got := reputation("alice"); got != models.DailyReactionReputation+models.PostDislikePoints {
+			t.Errorf("Expected the recount to match, got %d", got)
+		}
+	})
+
+	t.Run("history", func(t *testing.T) {
+		req := httptest.NewRequest("GET", "/api/users/reputation?username=alice&limit=2", nil)
+		w := httptest.NewRecorder()
+		GetReputationHistory(db, w, req)
+		var response struct {
+			Reputation int                      `json:"reputation"`
+			History    []models.ReputationEvent `json:"history"`
+		}
+		json.Unmarshal(w.Body.Bytes(), &response)
+		if w.Code != http.StatusOK || response.Reputation != reputation("alice") || len(response.History) != 2 ||
+			response.History[0].PostID != post.ID {
+			t.Errorf("Unexpected history %d %+v", w.Code, response)
+		}
+	})
+
+	t.Run("leaderboard", func(t *testing.T) {
+		sqlite.CreateComment(db, users["bob"], post.ID, "Answer", models.ContentPublished)
+		sqlite.AcceptAnswer(db, post.ID, models.ContentComment, 2)
+		for _, period := range []string{"", "week"} {
+			req := httptest.NewRequest("GET", "/api/users/leaderboard?period="+period, nil)
+			w := httptest.NewRecorder()
+			GetLeaderboard(db, w, req)
+			var leaders []models.LeaderboardEntry
+			json.Unmarshal(w.Body.Bytes(), &leaders)
+			if w.Code != http.StatusOK || len(leaders) != 2 || leaders[0].Username != "alice" || leaders[1].Username != "bob" ||
+				leaders[1].Reputation != models.AcceptedAnswerPoints || leaders[1].Rank != 2 {
+				t.Errorf("period %q: unexpected leaderboard %d %+v", period, w.Code, leaders)
+			}
+		}
+
+		req := httptest.NewRequest("GET", "/api/users/leaderboard?period=decade", nil)
+		w := httptest.NewRecorder()
+		GetLeaderboard(db, w, req)
+		if w.Code != http.StatusBadRequest {
+			t.Errorf("Expected unknown periods refused, got %d", w.Code)
+		}
+	})
+}
@@ -0,0 +1,346 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"log"
+	"math"
+	"net/http"
+	"os"
+	"strconv"
+	"time"
+
+	"forum/middleware"
+	"forum/sqlite"
+	"forum/totp"
+	"forum/utils"
+)
+
+const (
+	pendingLoginTTL         = 5 * time.Minute // How long the second step of a 2FA login may take
+	maxPendingLoginAttempts = 5               // Wrong codes allowed per pending login
+	recoveryCodeCount       = 10
+)
+
+// twoFactorClock is the time TOTP codes and pending logins are checked
+// against; replaced in tests
+var twoFactorClock = time.Now
+
+// totpIssuer is the name authenticator apps show next to the account
+func totpIssuer() string {
+	if issuer := os.Getenv("TOTP_ISSUER"); issuer != "" {
+		return issuer
+	}
+	return "Forum"
+}
+
+// verifySecondFactor checks a TOTP code or, if no code is given, a recovery code
+func verifySecondFactor(db *sql.DB, userID, code, recoveryCode string) (bool, error) {
+	if code != "" {
+		t, err := sqlite.GetTOTP(db, userID)
+		if err != nil {
+			return false, err
+		}
+		step, ok := totp.Validate(t.Secret, code, twoFactorClock(), t.LastUsedStep)
+		if !ok {
+			return false, nil
+		}
+		return sqlite.SetTOTPLastUsedStep(db, userID, step)
+	}
+	if recoveryCode != "" {
+		return sqlite.UseRecoveryCode(db, userID, totp.HashRecoveryCode(recoveryCode))
+	}
+	return false, nil
+}
+
+// GetTwoFactorStatus reports whether the current user has two-factor authentication enabled
+func GetTwoFactorStatus(db *sql.DB, w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	userID, err := utils.GetUserIDFromSession(db, r)
+	if err != nil || userID == "" {
+		utils.SendJSONError(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	enabled, err := sqlite.IsTOTPEnabled(db, userID)
+	if err != nil {
+		utils.SendJSONError(w, "Database error", http.StatusInternalServerError)
+		return
+	}
+	remaining := 0
+	if enabled {
+		if remaining, err = sqlite.CountUnusedRecoveryCodes(db, userID); err != nil {
+			utils.SendJSONError(w, "Database error", http.StatusInternalServerError)
+			return
+		}
+	}
+
+	utils.SendJSONResponse(w, map[string]any{
+		"enabled":                  enabled,
+		"recovery_codes_remaining": remaining,
+	}, http.StatusOK)
+}
+
+// EnrollTOTP generates a new TOTP secret for the current user. It is not
+// active until confirmed with a code from the authenticator app.
+func EnrollTOTP(db *sql.DB, w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	userID, err := utils.GetUserIDFromSession(db, r)
+	if err != nil || userID == "" {
+		utils.SendJSONError(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	user, err := sqlite.GetUserByID(db, userID)
+	if err != nil {
+		utils.SendJSONError(w, "User not found", http.StatusNotFound)
+		return
+	}
+
+	enabled, err := sqlite.IsTOTPEnabled(db, userID)
+	if err != nil {
+		utils.SendJSONError(w, "Database error", http.StatusInternalServerError)
+		return
+	}
+	if enabled {
+		utils.SendJSONError(w, "Two-factor authentication is already enabled", http.StatusConflict)
+		return
+	}
+
+	secret, err := totp.GenerateSecret()
+	if err != nil {
+		utils.SendJSONError(w, "Failed to generate secret", http.StatusInternalServerError)
+		return
+	}
+	if err := sqlite.SaveTOTPSecret(db, userID, secret); err != nil {
+		utils.SendJSONError(w, "Failed to save secret", http.StatusInternalServerError)
+		return
+	}
+
+	utils.SendJSONResponse(w, map[string]string{
+		"secret":      secret,
+		"otpauth_uri": totp.URI(totpIssuer(), user.Username, secret),
+	}, http.StatusOK)
+}
+
+// ConfirmTOTP enables two-factor authentication once the user proves their
+// authenticator app works, and returns the one-time recovery codes
+func ConfirmTOTP(db *sql.DB, w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var request struct {
+		Code string `json:"code"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		http.Error(w, "Invalid request data", http.StatusBadRequest)
+		return
+	}
+
+	userID, err := utils.GetUserIDFromSession(db, r)
+	if err != nil || userID == "" {
+		utils.SendJSONError(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	t, err := sqlite.GetTOTP(db, userID)
+	if err == sql.ErrNoRows {
+		utils.SendJSONError(w, "Start enrollment first", http.StatusBadRequest)
+		return
+	}
+	if err != nil {
+		utils.SendJSONError(w, "Database error", http.StatusInternalServerError)
+		return
+	}
+	if t.Enabled {
+		utils.SendJSONError(w, "Two-factor authentication is already enabled", http.StatusConflict)
+		return
+	}
+
+	step, ok := totp.Validate(t.Secret, request.Code, twoFactorClock(), 0)
+	if !ok {
+		utils.SendJSONError(w, "Invalid authentication code", http.StatusBadRequest)
+		return
+	}
+
+	codes, err := totp.GenerateRecoveryCodes(recoveryCodeCount)
+	if err != nil {
+		utils.SendJSONError(w, "Failed to generate recovery codes", http.StatusInternalServerError)
+		return
+	}
+	hashes := make([]string, len(codes))
+	for i, code := range codes {
+		hashes[i] = totp.HashRecoveryCode(code)
+	}
+
+	if err := sqlite.EnableTOTP(db, userID, step, hashes); err != nil {
+		utils.SendJSONError(w, "Failed to enable two-factor authentication", http.StatusInternalServerError)
+		return
+	}
+
+	utils.SendJSONResponse(w, map[string]any{
+		"message":        "Two-factor authentication enabled",
+		"recovery_codes": codes,
+	}, http.StatusOK)
+}
+
+// DisableTOTP turns off two-factor authentication. It requires the password
+// and a current code or recovery code.
+func DisableTOTP(db *sql.DB, w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var request struct {
+		Password     string `json:"password"`
+		Code         string `json:"code"`
+		RecoveryCode string `json:"recovery_code"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		http.Error(w, "Invalid request data", http.StatusBadRequest)
+		return
+	}
+
+	userID, err := utils.GetUserIDFromSession(db, r)
+	if err != nil || userID == "" {
+		utils.SendJSONError(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	user, err := sqlite.GetUserByID(db, userID)
+	if err != nil {
+		utils.SendJSONError(w, "User not found", http.StatusNotFound)
+		return
+	}
+	if !utils.CheckPasswordHash(request.Password, user.PasswordHash) {
+		utils.SendJSONError(w, "Invalid credentials", http.StatusUnauthorized)
+		return
+	}
+
+	enabled, err := sqlite.IsTOTPEnabled(db, userID)
+	if err != nil {
+		utils.SendJSONError(w, "Database error", http.StatusInternalServerError)
+		return
+	}
+	if !enabled {
+		utils.SendJSONError(w, "Two-factor authentication is not enabled", http.StatusBadRequest)
+		return
+	}
+
+	ok, err := verifySecondFactor(db, userID, request.Code, request.RecoveryCode)
+	if err != nil {
+		utils.SendJSONError(w, "Database error", http.StatusInternalServerError)
+		return
+	}
+	if !ok {
+		utils.SendJSONError(w, "Invalid authentication code", http.StatusUnauthorized)
+		return
+	}
+
+	if err := sqlite.DeleteTOTP(db, userID); err != nil {
+		utils.SendJSONError(w, "Failed to disable two-factor authentication", http.StatusInternalServerError)
+		return
+	}
+	if err := sqlite.CreateNotification(db, userID, "security", "Two-factor authentication was disabled on your account.", ""); err != nil {
+		log.Printf("Warning: Failed to notify user %s: %v", userID, err)
+	}
+
+	utils.SendJSONResponse(w, map[string]string{"message": "Two-factor authentication disabled"}, http.StatusOK)
+}
+
+// LoginTwoFactor completes a login started by LoginUser for an account with
+// two-factor authentication, using the pending token and a TOTP or recovery code
+func LoginTwoFactor(db *sql.DB, w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var request struct {
+		PendingToken string `json:"pending_token"`
+		Code         string `json:"code"`
+		RecoveryCode string `json:"recovery_code"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		http.Error(w, "Invalid request data", http.StatusBadRequest)
+		return
+	}
+	if request.PendingToken == "" || (request.Code == "" && request.RecoveryCode == "") {
+		utils.SendJSONError(w, "Pending token and code are required", http.StatusBadRequest)
+		return
+	}
+
+	pending, err := sqlite.GetPendingLogin(db, request.PendingToken)
+	if err != nil && err != sql.ErrNoRows {
+		utils.SendJSONError(w, "Database error", http.StatusInternalServerError)
+		return
+	}
+	if err == sql.ErrNoRows || !twoFactorClock().Before(pending.ExpiresAt) || pending.Attempts >= maxPendingLoginAttempts {
+		sqlite.DeletePendingLogin(db, request.PendingToken)
+		utils.SendJSONError(w, "Login expired, please sign in again", http.StatusUnauthorized)
+		return
+	}
+
+	// Wrong codes count towards the same backoff and lockout as wrong passwords
+	clientIP := middleware.ClientIP(r)
+	accountKey := utils.AccountLoginKey(pending.UserID, "")
+	ipKey := utils.IPLoginKey(clientIP)
+
+	retryAfter, err := utils.LoginRetryAfter(db, accountKey, ipKey)
+	if err != nil {
+		utils.SendJSONError(w, "Database error", http.StatusInternalServerError)
+		return
+	}
+	if retryAfter > 0 {
+		w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(retryAfter.Seconds()))))
+		utils.SendJSONError(w, "Too many failed login attempts, please try again later", http.StatusTooManyRequests)
+		return
+	}
+
+	ok, err := verifySecondFactor(db, pending.UserID, request.Code, request.RecoveryCode)
+	if err != nil {
+		utils.SendJSONError(w, "Database error", http.StatusInternalServerError)
+		return
+	}
+	if !ok {
+		sqlite.IncrementPendingLoginAttempts(db, request.PendingToken)
+		locked, err := utils.RecordFailedLogin(db, accountKey, ipKey)
+		if err != nil {
+			log.Printf("Warning: Failed to record failed login for %s: %v", accountKey, err)
+		}
+		if locked {
+			notifyAccountLocked(db, pending.UserID, clientIP)
+		}
+		utils.SendJSONError(w, "Invalid authentication code", http.StatusUnauthorized)
+		return
+	}
+
+	if err := sqlite.DeletePendingLogin(db, request.PendingToken); err != nil {
+		log.Printf("Warning: Failed to delete pending login: %v", err)
+	}
+	if err := utils.ClearFailedLogins(db, accountKey); err != nil {
+		log.Printf("Warning: Failed to reset failed logins for user %s: %v", pending.UserID, err)
+	}
+
+	if !startSession(db, w, pending.UserID) {
+		return
+	}
+
+	response := map[string]any{"message": "Logged in"}
+	if request.Code == "" {
+		remaining, _ := sqlite.CountUnusedRecoveryCodes(db, pending.UserID)
+		response["recovery_codes_remaining"] = remaining
+	}
+	utils.SendJSONResponse(w, response, http.StatusOK)
+}
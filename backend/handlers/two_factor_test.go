@@ -0,0 +1,178 @@
+package handlers
+
+import (
+	"bytes"
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"forum/sqlite"
+	"forum/totp"
+	"forum/utils"
+)
+
+func TestTwoFactorFlow(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	now := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+	originalClock := twoFactorClock
+	defer func() { twoFactorClock = originalClock }()
+	twoFactorClock = func() time.Time { return now }
+
+	// Keep login backoff out of the way; lockout is covered by TestLoginLockout
+	originalAccount, originalIP := utils.AccountThrottle, utils.IPThrottle
+	defer func() { utils.AccountThrottle, utils.IPThrottle = originalAccount, originalIP }()
+	utils.AccountThrottle.FreeAttempts, utils.AccountThrottle.LockAfter = 100, 100
+	utils.IPThrottle.FreeAttempts, utils.IPThrottle.LockAfter = 100, 100
+
+	passwordHash, _ := utils.HashPassword("password123")
+	if err := sqlite.CreateUser(db, "secure", "secure@example.com", passwordHash, ""); err != nil {
+		t.Fatalf("Failed to create test user: %v", err)
+	}
+	user, _ := sqlite.GetUserByUsername(db, "secure")
+	sessionID, _ := sqlite.CreateSession(db, user.ID)
+
+	call := func(handler func(db *sql.DB, w http.ResponseWriter, r *http.Request), body any, withSession bool) (*httptest.ResponseRecorder, map[string]any) {
+		jsonData, _ := json.Marshal(body)
+		req := httptest.NewRequest("POST", "/", bytes.NewBuffer(jsonData))
+		if withSession {
+			req.AddCookie(&http.Cookie{Name: "session_id", Value: sessionID})
+		}
+		w := httptest.NewRecorder()
+		handler(db, w, req)
+		var response map[string]any
+		json.Unmarshal(w.Body.Bytes(), &response)
+		return w, response
+	}
+
+	var secret string
+	var recoveryCodes []any
+
+	t.Run("enroll and confirm", func(t *testing.T) {
+		w, response := call(EnrollTOTP, nil, true)
+		if w.Code != http.StatusOK {
+			t.Fatalf("Expected %d, got %d: %s", http.StatusOK, w.Code, w.Body.String())
+		}
+		secret, _ = response["secret"].(string)
+
+		if w, _ := call(ConfirmTOTP, map[string]string{"code": "000000"}, true); w.Code != http.StatusBadRequest {
+			t.Errorf("Expected wrong code to be rejected, got %d", w.Code)
+		}
+
+		code, _ := totp.Code(secret, now)
+		w, response = call(ConfirmTOTP, map[string]string{"code": code}, true)
+		if w.Code != http.StatusOK {
+			t.Fatalf("Expected %d, got %d: %s", http.StatusOK, w.Code, w.Body.String())
+		}
+		recoveryCodes, _ = response["recovery_codes"].([]any)
+		if len(recoveryCodes) != recoveryCodeCount {
+			t.Fatalf("Expected %d recovery codes, got %d", recoveryCodeCount, len(recoveryCodes))
+		}
+
+		if w, _ := call(EnrollTOTP, nil, true); w.Code != http.StatusConflict {
+			t.Errorf("Expected re-enrolling to conflict, got %d", w.Code)
+		}
+	})
+
+	login := func(t *testing.T) string {
+		w, response := call(LoginUser, map[string]string{"username": "secure", "password": "password123"}, false)
+		if w.Code != http.StatusOK || response["two_factor_required"] != true {
+			t.Fatalf("Expected a second factor to be required, got %d: %s", w.Code, w.Body.String())
+		}
+		for _, c := range w.Result().Cookies() {
+			if c.Name == "session_id" && c.Value != "" {
+				t.Fatal("No session should be created before the second factor")
+			}
+		}
+		token, _ := response["pending_token"].(string)
+		return token
+	}
+
+	t.Run("login with code", func(t *testing.T) {
+		token := login(t)
+
+		// The code used to confirm enrollment can't be replayed
+		code, _ := totp.Code(secret, now)
+		if w, _ := call(LoginTwoFactor, map[string]string{"pending_token": token, "code": code}, false); w.Code != http.StatusUnauthorized {
+			t.Fatalf("Expected replayed code to be rejected, got %d", w.Code)
+		}
+
+		now = now.Add(totp.Period * time.Second)
+		code, _ = totp.Code(secret, now)
+		w, _ := call(LoginTwoFactor, map[string]string{"pending_token": token, "code": code}, false)
+		if w.Code != http.StatusOK {
+			t.Fatalf("Expected %d, got %d: %s", http.StatusOK, w.Code, w.Body.String())
+		}
+		if len(w.Result().Cookies()) == 0 {
+			t.Error("Expected a session cookie")
+		}
+
+		// A concurrent login that checked the same code can't record its step too
+		if claimed, err := sqlite.SetTOTPLastUsedStep(db, user.ID, totp.Step(now)); err != nil || claimed {
+			t.Errorf("Expected a used step not to be claimed again, got %v, %v", claimed, err)
+		}
+
+		if w, _ := call(LoginTwoFactor, map[string]string{"pending_token": token, "code": code}, false); w.Code != http.StatusUnauthorized {
+			t.Errorf("Expected pending token to be single use, got %d", w.Code)
+		}
+	})
+
+	t.Run("recovery code is single use", func(t *testing.T) {
+		recovery := recoveryCodes[0].(string)
+
+		w, response := call(LoginTwoFactor, map[string]string{"pending_token": login(t), "recovery_code": recovery}, false)
+		if w.Code != http.StatusOK {
+			t.Fatalf("Expected %d, got %d: %s", http.StatusOK, w.Code, w.Body.String())
+		}
+		if response["recovery_codes_remaining"] != float64(recoveryCodeCount-1) {
+			t.Errorf("Expected %d codes remaining, got %v", recoveryCodeCount-1, response["recovery_codes_remaining"])
+		}
+
+		if w, _ := call(LoginTwoFactor, map[string]string{"pending_token": login(t), "recovery_code": recovery}, false); w.Code != http.StatusUnauthorized {
+			t.Errorf("Expected used recovery code to be rejected, got %d", w.Code)
+		}
+	})
+
+	t.Run("pending login allows limited attempts", func(t *testing.T) {
+		token := login(t)
+		for i := 0; i < maxPendingLoginAttempts; i++ {
+			call(LoginTwoFactor, map[string]string{"pending_token": token, "code": "000000"}, false)
+		}
+		now = now.Add(totp.Period * time.Second)
+		code, _ := totp.Code(secret, now)
+		if w, _ := call(LoginTwoFactor, map[string]string{"pending_token": token, "code": code}, false); w.Code != http.StatusUnauthorized {
+			t.Errorf("Expected pending login to be discarded, got %d", w.Code)
+		}
+	})
+
+	t.Run("pending login expires", func(t *testing.T) {
+		token := login(t)
+		now = now.Add(pendingLoginTTL + totp.Period*time.Second)
+		code, _ := totp.Code(secret, now)
+		if w, _ := call(LoginTwoFactor, map[string]string{"pending_token": token, "code": code}, false); w.Code != http.StatusUnauthorized {
+			t.Errorf("Expected an expired pending login to be rejected, got %d", w.Code)
+		}
+	})
+
+	t.Run("disable", func(t *testing.T) {
+		sessionID, _ = sqlite.CreateSession(db, user.ID)
+		now = now.Add(totp.Period * time.Second)
+		code, _ := totp.Code(secret, now)
+
+		if w, _ := call(DisableTOTP, map[string]string{"password": "wrong", "code": code}, true); w.Code != http.StatusUnauthorized {
+			t.Errorf("Expected wrong password to be rejected, got %d", w.Code)
+		}
+		if w, _ := call(DisableTOTP, map[string]string{"password": "password123", "code": code}, true); w.Code != http.StatusOK {
+			t.Fatalf("Expected %d, got %d: %s", http.StatusOK, w.Code, w.Body.String())
+		}
+
+		w, response := call(LoginUser, map[string]string{"username": "secure", "password": "password123"}, false)
+		if w.Code != http.StatusOK || response["two_factor_required"] == true {
+			t.Errorf("Expected a normal login after disabling, got %d: %s", w.Code, w.Body.String())
+		}
+	})
+}
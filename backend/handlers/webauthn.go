@@ -0,0 +1,298 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"forum/models"
+	"forum/sqlite"
+	"forum/utils"
+	"forum/webauthn"
+)
+
+const (
+	webauthnCeremonyTTL  = 5 * time.Minute // Matches webauthn.Timeout
+	maxPasskeyNameLength = 64
+)
+
+// passkeyConfig identifies the forum to authenticators; replaced in tests
+var passkeyConfig = webauthn.FromEnv()
+
+// takeCeremony reads the pre-session cookie and consumes its challenge
+func takeCeremony(db *sql.DB, w http.ResponseWriter, r *http.Request, ceremony string) (models.WebAuthnCeremony, bool) {
+	cookie, err := r.Cookie(utils.WebAuthnCookieName)
+	if err != nil || cookie.Value == "" {
+		utils.SendJSONError(w, "No passkey challenge in progress", http.StatusBadRequest)
+		return models.WebAuthnCeremony{}, false
+	}
+	utils.ClearWebAuthnCookie(w)
+
+	c, err := sqlite.TakeWebAuthnCeremony(db, cookie.Value, ceremony)
+	if err == sql.ErrNoRows {
+		utils.SendJSONError(w, "Passkey challenge expired, please try again", http.StatusBadRequest)
+		return c, false
+	}
+	if err != nil {
+		utils.SendJSONError(w, "Database error", http.StatusInternalServerError)
+		return c, false
+	}
+	return c, true
+}
+
+// BeginPasskeyRegistration issues a challenge for adding a passkey to the current account
+func BeginPasskeyRegistration(db *sql.DB, w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	userID, err := utils.GetUserIDFromSession(db, r)
+	if err != nil || userID == "" {
+		utils.SendJSONError(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	user, err := sqlite.GetUserByID(db, userID)
+	if err != nil {
+		utils.SendJSONError(w, "User not found", http.StatusNotFound)
+		return
+	}
+
+	// Stop the browser from registering a second passkey on the same authenticator
+	existing, err := sqlite.GetWebAuthnCredentials(db, userID)
+	if err != nil {
+		utils.SendJSONError(w, "Database error", http.StatusInternalServerError)
+		return
+	}
+	var exclude [][]byte
+	for _, cred := range existing {
+		if id, err := webauthn.DecodeID(cred.ID); err == nil {
+			exclude = append(exclude, id)
+		}
+	}
+
+	challenge, err := webauthn.NewChallenge()
+	if err != nil {
+		utils.SendJSONError(w, "Failed to create challenge", http.StatusInternalServerError)
+		return
+	}
+	token, err := sqlite.CreateWebAuthnCeremony(db, userID, "register", challenge, webauthnCeremonyTTL)
+	if err != nil {
+		utils.SendJSONError(w, "Database error", http.StatusInternalServerError)
+		return
+	}
+	utils.SetWebAuthnCookie(w, token, webauthnCeremonyTTL)
+
+	options := passkeyConfig.NewCreationOptions(challenge, webauthn.User{
+		ID:          []byte(user.ID),
+		Name:        user.Email,
+		DisplayName: user.Username,
+	}, exclude)
+	utils.SendJSONResponse(w, map[string]any{"publicKey": options}, http.StatusOK)
+}
+
+// FinishPasskeyRegistration verifies the browser's response and saves the new passkey
+func FinishPasskeyRegistration(db *sql.DB, w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var request struct {
+		webauthn.RegistrationResponse
+		Name string `json:"name"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		http.Error(w, "Invalid request data", http.StatusBadRequest)
+		return
+	}
+
+	userID, err := utils.GetUserIDFromSession(db, r)
+	if err != nil || userID == "" {
+		utils.SendJSONError(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	ceremony, ok := takeCeremony(db, w, r, "register")
+	if !ok {
+		return
+	}
+	if ceremony.UserID != userID {
+		utils.SendJSONError(w, "Passkey challenge belongs to another session", http.StatusBadRequest)
+		return
+	}
+
+	cred, err := passkeyConfig.VerifyRegistration(ceremony.Challenge, request.RegistrationResponse)
+	if err != nil {
+		log.Printf("Passkey registration failed for user %s: %v", userID, err)
+		utils.SendJSONError(w, "Passkey verification failed", http.StatusBadRequest)
+		return
+	}
+
+	name := strings.TrimSpace(request.Name)
+	if name == "" {
+		name = "Passkey"
+	}
+	if len([]rune(name)) > maxPasskeyNameLength {
+		name = string([]rune(name)[:maxPasskeyNameLength])
+	}
+
+	stored := models.WebAuthnCredential{
+		ID:        webauthn.EncodeID(cred.ID),
+		UserID:    userID,
+		PublicKey: cred.PublicKey,
+		SignCount: cred.SignCount,
+		Name:      name,
+	}
+	if err := sqlite.CreateWebAuthnCredential(db, stored); err != nil {
+		if sqlite.IsUniqueConstraintError(err) {
+			utils.SendJSONError(w, "Passkey is already registered", http.StatusConflict)
+			return
+		}
+		utils.SendJSONError(w, "Failed to save passkey", http.StatusInternalServerError)
+		return
+	}
+	if err := sqlite.CreateNotification(db, userID, "security", "A passkey named \""+name+"\" was added to your account.", ""); err != nil {
+		log.Printf("Warning: Failed to notify user %s: %v", userID, err)
+	}
+
+	saved, err := sqlite.GetWebAuthnCredential(db, stored.ID)
+	if err != nil {
+		saved = stored
+	}
+	utils.SendJSONResponse(w, saved, http.StatusCreated)
+}
+
+// BeginPasskeyLogin issues a challenge for logging in with any passkey the browser holds
+func BeginPasskeyLogin(db *sql.DB, w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	challenge, err := webauthn.NewChallenge()
+	if err != nil {
+		utils.SendJSONError(w, "Failed to create challenge", http.StatusInternalServerError)
+		return
+	}
+	token, err := sqlite.CreateWebAuthnCeremony(db, "", "login", challenge, webauthnCeremonyTTL)
+	if err != nil {
+		utils.SendJSONError(w, "Database error", http.StatusInternalServerError)
+		return
+	}
+	utils.SetWebAuthnCookie(w, token, webauthnCeremonyTTL)
+
+	utils.SendJSONResponse(w, map[string]any{"publicKey": passkeyConfig.NewRequestOptions(challenge)}, http.StatusOK)
+}
+
+// FinishPasskeyLogin verifies a passkey assertion and starts a session. A
+// passkey replaces both the password and the second factor.
+func FinishPasskeyLogin(db *sql.DB, w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var request webauthn.AssertionResponse
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		http.Error(w, "Invalid request data", http.StatusBadRequest)
+		return
+	}
+
+	ceremony, ok := takeCeremony(db, w, r, "login")
+	if !ok {
+		return
+	}
+
+	stored, err := sqlite.GetWebAuthnCredential(db, webauthn.EncodeID(request.RawID))
+	if err == sql.ErrNoRows {
+		utils.SendJSONError(w, "Passkey not recognised", http.StatusUnauthorized)
+		return
+	}
+	if err != nil {
+		utils.SendJSONError(w, "Database error", http.StatusInternalServerError)
+		return
+	}
+	if len(request.Response.UserHandle) > 0 && string(request.Response.UserHandle) != stored.UserID {
+		utils.SendJSONError(w, "Passkey not recognised", http.StatusUnauthorized)
+		return
+	}
+
+	signCount, err := passkeyConfig.VerifyAssertion(ceremony.Challenge, webauthn.Credential{
+		ID:        request.RawID,
+		PublicKey: stored.PublicKey,
+		SignCount: stored.SignCount,
+	}, request)
+	if err != nil {
+		log.Printf("Passkey login failed for user %s: %v", stored.UserID, err)
+		utils.SendJSONError(w, "Passkey verification failed", http.StatusUnauthorized)
+		return
+	}
+
+	if err := sqlite.UpdateWebAuthnCredentialUse(db, stored.ID, signCount); err != nil {
+		log.Printf("Warning: Failed to update passkey %s: %v", stored.ID, err)
+	}
+
+	if !startSession(db, w, stored.UserID) {
+		return
+	}
+	utils.SendJSONResponse(w, map[string]string{"message": "Logged in"}, http.StatusOK)
+}
+
+// GetPasskeys lists the current user's passkeys
+func GetPasskeys(db *sql.DB, w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	userID, err := utils.GetUserIDFromSession(db, r)
+	if err != nil || userID == "" {
+		utils.SendJSONError(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	credentials, err := sqlite.GetWebAuthnCredentials(db, userID)
+	if err != nil {
+		utils.SendJSONError(w, "Database error", http.StatusInternalServerError)
+		return
+	}
+	utils.SendJSONResponse(w, credentials, http.StatusOK)
+}
+
+// DeletePasskey removes one of the current user's passkeys
+func DeletePasskey(db *sql.DB, w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodDelete {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var request struct {
+		ID string `json:"id"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		http.Error(w, "Invalid request data", http.StatusBadRequest)
+		return
+	}
+
+	userID, err := utils.GetUserIDFromSession(db, r)
+	if err != nil || userID == "" {
+		utils.SendJSONError(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	err = sqlite.DeleteWebAuthnCredential(db, userID, request.ID)
+	if err == sql.ErrNoRows {
+		utils.SendJSONError(w, "Passkey not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		utils.SendJSONError(w, "Failed to delete passkey", http.StatusInternalServerError)
+		return
+	}
+	utils.SendJSONResponse(w, map[string]string{"message": "Passkey deleted"}, http.StatusOK)
+}
@@ -0,0 +1,174 @@
+package handlers
+
+import (
+	"bytes"
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"forum/sqlite"
+	"forum/utils"
+	"forum/webauthn"
+	"forum/webauthn/webauthntest"
+)
+
+func TestPasskeyFlow(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	originalConfig := passkeyConfig
+	defer func() { passkeyConfig = originalConfig }()
+	passkeyConfig = webauthn.Config{
+		RPID:             "localhost",
+		RPName:           "Forum",
+		Origins:          []string{"http://localhost:8000"},
+		UserVerification: "preferred",
+	}
+	authenticator := webauthntest.New("localhost", "http://localhost:8000")
+
+	passwordHash, _ := utils.HashPassword("password123")
+	if err := sqlite.CreateUser(db, "passkey", "passkey@example.com", passwordHash, ""); err != nil {
+		t.Fatalf("Failed to create test user: %v", err)
+	}
+	user, _ := sqlite.GetUserByUsername(db, "passkey")
+	sessionID, _ := sqlite.CreateSession(db, user.ID)
+
+	// call runs a handler with the given session and pre-session cookies
+	call := func(handler func(*sql.DB, http.ResponseWriter, *http.Request), body any, session, ceremony string) *httptest.ResponseRecorder {
+		jsonData, _ := json.Marshal(body)
+		req := httptest.NewRequest("POST", "/", bytes.NewBuffer(jsonData))
+		if session != "" {
+			req.AddCookie(&http.Cookie{Name: utils.SessionCookieName, Value: session})
+		}
+		if ceremony != "" {
+			req.AddCookie(&http.Cookie{Name: utils.WebAuthnCookieName, Value: ceremony})
+		}
+		w := httptest.NewRecorder()
+		handler(db, w, req)
+		return w
+	}
+
+	// begin starts a ceremony and returns its challenge and pre-session token
+	begin := func(t *testing.T, handler func(*sql.DB, http.ResponseWriter, *http.Request), session string) (string, string) {
+		w := call(handler, nil, session, "")
+		if w.Code != http.StatusOK {
+			t.Fatalf("Expected %d, got %d: %s", http.StatusOK, w.Code, w.Body.String())
+		}
+		var response struct {
+			PublicKey struct {
+				Challenge string `json:"challenge"`
+			} `json:"publicKey"`
+		}
+		json.Unmarshal(w.Body.Bytes(), &response)
+		for _, c := range w.Result().Cookies() {
+			if c.Name == utils.WebAuthnCookieName {
+				return response.PublicKey.Challenge, c.Value
+			}
+		}
+		t.Fatal("Expected a pre-session cookie")
+		return "", ""
+	}
+
+	t.Run("register", func(t *testing.T) {
+		if w := call(BeginPasskeyRegistration, nil, "", ""); w.Code != http.StatusUnauthorized {
+			t.Errorf("Expected anonymous registration to be rejected, got %d", w.Code)
+		}
+
+		challenge, token := begin(t, BeginPasskeyRegistration, sessionID)
+		credential, err := authenticator.Create(challenge, []byte(user.ID))
+		if err != nil {
+			t.Fatalf("Create failed: %v", err)
+		}
+		credential["name"] = "Laptop"
+
+		w := call(FinishPasskeyRegistration, credential, sessionID, token)
+		if w.Code != http.StatusCreated {
+			t.Fatalf("Expected %d, got %d: %s", http.StatusCreated, w.Code, w.Body.String())
+		}
+
+		// The challenge can only be answered once
+		if w := call(FinishPasskeyRegistration, credential, sessionID, token); w.Code != http.StatusBadRequest {
+			t.Errorf("Expected reused challenge to be rejected, got %d", w.Code)
+		}
+
+		credentials, _ := sqlite.GetWebAuthnCredentials(db, user.ID)
+		if len(credentials) != 1 || credentials[0].Name != "Laptop" {
+			t.Fatalf("Expected one stored passkey, got %+v", credentials)
+		}
+	})
+
+	t.Run("login", func(t *testing.T) {
+		challenge, token := begin(t, BeginPasskeyLogin, "")
+		assertion, _ := authenticator.Get(challenge)
+
+		if w := call(FinishPasskeyLogin, assertion, "", ""); w.Code != http.StatusBadRequest {
+			t.Errorf("Expected login without the pre-session cookie to be rejected, got %d", w.Code)
+		}
+
+		challenge, token = begin(t, BeginPasskeyLogin, "")
+		assertion, _ = authenticator.Get(challenge)
+		w := call(FinishPasskeyLogin, assertion, "", token)
+		if w.Code != http.StatusOK {
+			t.Fatalf("Expected %d, got %d: %s", http.StatusOK, w.Code, w.Body.String())
+		}
+
+		var newSession string
+		for _, c := range w.Result().Cookies() {
+			if c.Name == utils.SessionCookieName {
+				newSession = c.Value
+			}
+		}
+		req := httptest.NewRequest("GET", "/", nil)
+		req.AddCookie(&http.Cookie{Name: utils.SessionCookieName, Value: newSession})
+		if id, err := utils.GetUserIDFromSession(db, req); err != nil || id != user.ID {
+			t.Errorf("Expected a session for %s, got %q (%v)", user.ID, id, err)
+		}
+
+		stored, _ := sqlite.GetWebAuthnCredentials(db, user.ID)
+		if stored[0].SignCount != 2 || stored[0].LastUsedAt == nil {
+			t.Errorf("Expected counter and last use to be updated, got %+v", stored[0])
+		}
+	})
+
+	t.Run("cloned authenticator is rejected", func(t *testing.T) {
+		clone := authenticator.Clone()
+		challenge, token := begin(t, BeginPasskeyLogin, "")
+		assertion, _ := authenticator.Get(challenge)
+		if w := call(FinishPasskeyLogin, assertion, "", token); w.Code != http.StatusOK {
+			t.Fatalf("Expected %d, got %d", http.StatusOK, w.Code)
+		}
+
+		challenge, token = begin(t, BeginPasskeyLogin, "")
+		assertion, _ = clone.Get(challenge)
+		if w := call(FinishPasskeyLogin, assertion, "", token); w.Code != http.StatusUnauthorized {
+			t.Errorf("Expected a stale counter to be rejected, got %d", w.Code)
+		}
+	})
+
+	t.Run("unknown passkey", func(t *testing.T) {
+		stranger := webauthntest.New("localhost", "http://localhost:8000")
+		stranger.Create("unused", []byte("someone-else"))
+		challenge, token := begin(t, BeginPasskeyLogin, "")
+		assertion, _ := stranger.Get(challenge)
+		if w := call(FinishPasskeyLogin, assertion, "", token); w.Code != http.StatusUnauthorized {
+			t.Errorf("Expected %d, got %d", http.StatusUnauthorized, w.Code)
+		}
+	})
+
+	t.Run("delete", func(t *testing.T) {
+		sessionID, _ = sqlite.CreateSession(db, user.ID)
+		credentials, _ := sqlite.GetWebAuthnCredentials(db, user.ID)
+		req := httptest.NewRequest("DELETE", "/", bytes.NewBufferString(`{"id": "`+credentials[0].ID+`"}`))
+		req.AddCookie(&http.Cookie{Name: utils.SessionCookieName, Value: sessionID})
+		w := httptest.NewRecorder()
+		DeletePasskey(db, w, req)
+		if w.Code != http.StatusOK {
+			t.Fatalf("Expected %d, got %d: %s", http.StatusOK, w.Code, w.Body.String())
+		}
+		if credentials, _ := sqlite.GetWebAuthnCredentials(db, user.ID); len(credentials) != 0 {
+			t.Errorf("Expected passkey to be deleted, got %+v", credentials)
+		}
+	})
+}
@@ -0,0 +1,132 @@
+// Package highlight marks up source code for syntax highlighting. Tokens
+// are wrapped in <span class="hl-..."> elements and everything else is
+// HTML escaped text, so the colours come from CSS alone and the text
+// content of the result is the code as written.
+package highlight
+
+import (
+	"html"
+	"regexp"
+	"strings"
+)
+
+// Token classes, used as hl-<class> in the output
+const (
+	Keyword  = "keyword"
+	Type     = "type"
+	Builtin  = "builtin"
+	String   = "string"
+	Comment  = "comment"
+	Number   = "number"
+	Variable = "variable"
+	Prompt   = "prompt"
+)
+
+// rule matches a token at the start of the remaining input. Words are
+// classified by the language's word lists instead.
+type rule struct {
+	pattern *regexp.Regexp
+	class   string
+	// lineStart rules only match at the start of a line, afterSpace rules
+	// also after whitespace
+	lineStart, afterSpace bool
+}
+
+// language is a set of rules tried in order at every position
+type language struct {
+	name          string
+	rules         []rule
+	word          *regexp.Regexp
+	words         map[string]string // word to class
+	caseSensitive bool
+}
+
+// aliases maps fence info strings to languages
+var aliases = map[string]*language{}
+
+func register(lang *language, names ...string) {
+	for _, name := range append(names, lang.name) {
+		aliases[name] = lang
+	}
+}
+
+// Language returns the name of the language a fence info string such as
+// "golang" or "Bash" refers to, or false if it isn't highlighted
+func Language(info string) (string, bool) {
+	lang, ok := aliases[strings.ToLower(strings.TrimSpace(info))]
+	if !ok {
+		return "", false
+	}
+	return lang.name, true
+}
+
+// Code highlights code written in the language named by info and returns
+// it as HTML. ok is false, and nothing is done, for unknown languages.
+func Code(info, code string) (out string, ok bool) {
+	lang, ok := aliases[strings.ToLower(strings.TrimSpace(info))]
+	if !ok {
+		return "", false
+	}
+
+	var b strings.Builder
+	var plain strings.Builder
+	flush := func() {
+		b.WriteString(html.EscapeString(plain.String()))
+		plain.Reset()
+	}
+	emit := func(class, text string) {
+		flush()
+		b.WriteString(`<span class="hl-` + class + `">` + html.EscapeString(text) + "</span>")
+	}
+
+	for pos := 0; pos < len(code); {
+		rest := code[pos:]
+		lineStart := pos == 0 || code[pos-1] == '\n'
+		afterSpace := lineStart || code[pos-1] == ' ' || code[pos-1] == '\t'
+		matched := false
+		for _, r := range lang.rules {
+			if (r.lineStart && !lineStart) || (r.afterSpace && !afterSpace) {
+				continue
+			}
+			if m := r.pattern.FindString(rest); m != "" {
+				emit(r.class, m)
+				pos += len(m)
+				matched = true
+				break
+			}
+		}
+		if matched {
+			continue
+		}
+
+		// Words only count at a word boundary, so the "if" in "gift" isn't one
+		if m := lang.word.FindString(rest); m != "" && (pos == 0 || !isWordByte(code[pos-1])) {
+			key := m
+			if !lang.caseSensitive {
+				key = strings.ToLower(m)
+			}
+			if class, ok := lang.words[key]; ok {
+				emit(class, m)
+			} else {
+				plain.WriteString(m)
+			}
+			pos += len(m)
+			continue
+		}
+		plain.WriteByte(code[pos])
+		pos++
+	}
+	flush()
+	return b.String(), true
+}
+
+func isWordByte(c byte) bool {
+	return c == '_' || ('0' <= c && c <= '9') || ('a' <= c && c <= 'z') || ('A' <= c && c <= 'Z')
+}
+
+// wordList maps each space separated word to class
+func wordList(words map[string]string, class, list string) {
+	for _, w := range strings.Fields(list) {
+		words[w] = class
+	}
+}
@@ -0,0 +1,78 @@
+package highlight
+
+import (
+	"html"
+	"regexp"
+	"testing"
+)
+
+func TestLanguage(t *testing.T) {
+	tests := []struct {
+		info, expected string
+		ok             bool
+	}{
+		{"go", "go", true},
+		{"Golang", "go", true},
+		{" bash ", "shell", true},
+		{"console", "shell", true},
+		{"postgresql", "sql", true},
+		{"json", "json", true},
+		{"brainfuck", "", false},
+		{"", "", false},
+	}
+	for _, tt := range tests {
+		if got, ok := Language(tt.info); got != tt.expected || ok != tt.ok {
+			t.Errorf("Language(%q) = %q, %v, expected %q, %v", tt.info, got, ok, tt.expected, tt.ok)
+		}
+	}
+}
+
+func TestCode(t *testing.T) {
+	tests := []struct {
+		name, info, code, expected string
+	}{
+		{"go", "go", "func f() error { return nil } // done",
+			`<span class="hl-keyword">func</span> f() <span class="hl-type">error</span> { <span class="hl-keyword">return</span> <span class="hl-builtin">nil</span> } <span class="hl-comment">// done</span>`},
+		{"go strings and numbers", "go", "x := `raw` + \"a\\\"<b>\" + 'c' + 0x1F",
+			`x := <span class="hl-string">` + "`raw`" + `</span> + <span class="hl-string">&#34;a\&#34;&lt;b&gt;&#34;</span> + <span class="hl-string">&#39;c&#39;</span> + <span class="hl-number">0x1F</span>`},
+		{"keywords only as words", "go", "gift iffy", "gift iffy"},
+		{"sql", "sql", "SELECT id FROM posts WHERE status = 'it''s' -- note",
+			`<span class="hl-keyword">SELECT</span> id <span class="hl-keyword">FROM</span> posts <span class="hl-keyword">WHERE</span> status = <span class="hl-string">&#39;it&#39;&#39;s&#39;</span> <span class="hl-comment">-- note</span>`},
+		{"sql parameters", "sqlite", "LIMIT ? OFFSET 10",
+			`<span class="hl-keyword">LIMIT</span> <span class="hl-variable">?</span> <span class="hl-keyword">OFFSET</span> <span class="hl-number">10</span>`},
+		{"shell", "console", "$ export DB_PATH=\"$HOME/forum.db\" # here\nok#not-a-comment",
+			`<span class="hl-prompt">$ </span><span class="hl-builtin">export</span> DB_PATH=<span class="hl-string">&#34;$HOME/forum.db&#34;</span> <span class="hl-comment"># here</span>` + "\n" + `ok#not-a-comment`},
+		{"json", "json", `{"a": [1, true, null]}`,
+			`{<span class="hl-string">&#34;a&#34;</span>: [<span class="hl-number">1</span>, <span class="hl-builtin">true</span>, <span class="hl-builtin">null</span>]}`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := Code(tt.info, tt.code)
+			if !ok || got != tt.expected {
+				t.Errorf("Code(%q, %q)\n got: %q\nwant: %q", tt.info, tt.code, got, tt.expected)
+			}
+		})
+	}
+
+	if _, ok := Code("cobol", "DISPLAY 'HI'."); ok {
+		t.Error("Expected unknown languages not to be highlighted")
+	}
+}
+
+// The text of highlighted code must be the code itself, so copying it
+// from the page gives back what was written
+func TestCodeKeepsText(t *testing.T) {
+	tags := regexp.MustCompile(`<[^>]*>`)
+	sources := map[string]string{
+		"go":    "package main\n\nimport \"fmt\"\n\nfunc main() {\n\tfmt.Println(\"<&>\", 1.5e3, 'x')\n}\n/* unterminated",
+		"sql":   "CREATE TABLE t (id INTEGER PRIMARY KEY, name TEXT DEFAULT 'a''b');\n/* x */ SELECT count(*) FROM t;",
+		"shell": "for f in *.go; do\n  echo \"${f%.go}\" 2>&1 | grep -v '#' # why\ndone\n$(unterminated",
+		"json":  `{"nested": {"list": [1.5, -2e10, "x\"y"]}, "unterminated": "`,
+	}
+	for lang, source := range sources {
+		got, _ := Code(lang, source)
+		if text := html.UnescapeString(tags.ReplaceAllString(got, "")); text != source {
+			t.Errorf("Code(%q) text = %q, expected %q", lang, text, source)
+		}
+	}
+}
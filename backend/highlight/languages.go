@@ -0,0 +1,107 @@
+package highlight
+
+import "regexp"
+
+var (
+	cNumber    = regexp.MustCompile(`^(?:0[xX][0-9a-fA-F_]+|0[bB][01_]+|0[oO][0-7_]+|(?:[0-9][0-9_]*(?:\.[0-9_]*)?|\.[0-9][0-9_]*)(?:[eE][+-]?[0-9_]+)?i?)`)
+	blockNote  = regexp.MustCompile(`^/\*[\s\S]*?(?:\*/|$)`)
+	identifier = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*`)
+)
+
+func init() {
+	register(golang(), "golang")
+	register(sql(), "sqlite", "sqlite3", "postgres", "postgresql", "psql", "mysql", "plsql")
+	register(shell(), "sh", "bash", "zsh", "console", "shell-session", "terminal")
+	register(json())
+}
+
+func golang() *language {
+	words := map[string]string{}
+	wordList(words, Keyword, `break case chan const continue default defer else fallthrough for func go goto
+		if import interface map package range return select struct switch type var`)
+	wordList(words, Type, `any bool byte comparable complex64 complex128 error float32 float64
+		int int8 int16 int32 int64 rune string uint uint8 uint16 uint32 uint64 uintptr`)
+	wordList(words, Builtin, `append cap clear close complex copy delete imag len make max min new panic
+		print println real recover true false iota nil`)
+	return &language{
+		name: "go",
+		rules: []rule{
+			{pattern: regexp.MustCompile(`^//[^\n]*`), class: Comment},
+			{pattern: blockNote, class: Comment},
+			{pattern: regexp.MustCompile(`^"(?:[^"\\\n]|\\.)*"?`), class: String},
+			{pattern: regexp.MustCompile("^`[^`]*`?"), class: String},
+			{pattern: regexp.MustCompile(`^'(?:[^'\\\n]|\\.)+'`), class: String},
+			{pattern: cNumber, class: Number},
+		},
+		word:          identifier,
+		words:         words,
+		caseSensitive: true,
+	}
+}
+
+func sql() *language {
+	words := map[string]string{}
+	wordList(words, Keyword, `add all alter and as asc autoincrement begin between by cascade case check
+		collate column commit conflict constraint create cross default delete desc distinct do drop each
+		else end escape except exists explain foreign from full glob group having if in index inner insert
+		intersect into is join key left like limit match natural not nothing null of offset on or order outer
+		over partition pragma primary references replace returning right rollback row rows savepoint select
+		set table temp temporary then to transaction trigger union unique update using vacuum values view
+		when where window with without after before for instead`)
+	wordList(words, Type, `integer int bigint smallint tinyint real double float numeric decimal boolean bool
+		text varchar char blob date datetime timestamp time uuid json jsonb serial`)
+	wordList(words, Builtin, `abs avg coalesce count current_date current_time current_timestamp date
+		datetime group_concat ifnull instr julianday length lower ltrim max min now nullif random
+		round rtrim strftime substr substring sum total trim upper true false`)
+	return &language{
+		name: "sql",
+		rules: []rule{
+			{pattern: regexp.MustCompile(`^--[^\n]*`), class: Comment},
+			{pattern: blockNote, class: Comment},
+			{pattern: regexp.MustCompile(`^'(?:[^']|'')*'?`), class: String},
+			{pattern: regexp.MustCompile(`^(?:[?$][0-9]*|[:@$][A-Za-z_][A-Za-z0-9_]*)`), class: Variable},
+			{pattern: cNumber, class: Number},
+		},
+		word:  identifier,
+		words: words,
+	}
+}
+
+func shell() *language {
+	words := map[string]string{}
+	wordList(words, Keyword, `if then else elif fi for while until do done case esac in function select
+		return break continue time`)
+	wordList(words, Builtin, `alias bg cd command declare echo eval exec exit export false fg getopts hash
+		jobs kill let local printf pwd read readonly set shift source test trap true type ulimit umask
+		unalias unset wait`)
+	return &language{
+		name: "shell",
+		rules: []rule{
+			// A "$ " or "# " prompt in console transcripts
+			{pattern: regexp.MustCompile(`^[$#] `), class: Prompt, lineStart: true},
+			{pattern: regexp.MustCompile(`^#[^\n]*`), class: Comment, afterSpace: true},
+			{pattern: regexp.MustCompile(`^'[^']*'?`), class: String},
+			{pattern: regexp.MustCompile(`^"(?:[^"\\]|\\.)*"?`), class: String},
+			{pattern: regexp.MustCompile(`^\$(?:\{[^}\n]*\}?|\(\(?|[A-Za-z_][A-Za-z0-9_]*|[0-9@#?$!*-])`), class: Variable},
+			{pattern: regexp.MustCompile(`^[0-9]+\b`), class: Number, afterSpace: true},
+		},
+		word:          regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*`),
+		words:         words,
+		caseSensitive: true,
+	}
+}
+
+func json() *language {
+	words := map[string]string{}
+	wordList(words, Builtin, `true false null`)
+	return &language{
+		name: "json",
+		rules: []rule{
+			{pattern: regexp.MustCompile(`^"(?:[^"\\\n]|\\.)*"?`), class: String},
+			{pattern: regexp.MustCompile(`^-?(?:0|[1-9][0-9]*)(?:\.[0-9]+)?(?:[eE][+-]?[0-9]+)?`), class: Number},
+		},
+		word:          identifier,
+		words:         words,
+		caseSensitive: true,
+	}
+}
@@ -1,6 +1,9 @@
 package main
 
 import (
+	"context"
+	"database/sql"
+	"flag"
 	"fmt"
 	"log"
 	"net/http"
@@ -8,12 +11,29 @@ import (
 	"strconv"
 	"time"
 
+	"forum/handlers"
+	"forum/markdown"
 	"forum/middleware"
+	"forum/models"
 	"forum/routes"
+	"forum/spamfilter"
 	"forum/sqlite"
+	"forum/storage"
 )
 
 func main() {
+	// Maintenance commands: go run . <command> [flags]
+	if len(os.Args) > 1 {
+		if command, ok := commands[os.Args[1]]; ok {
+			initDatabase()
+			defer sqlite.CloseDatabase()
+			if err := command(os.Args[2:]); err != nil {
+				log.Fatalf("%s failed: %v", os.Args[1], err)
+			}
+			return
+		}
+	}
+
 	// Validate CLI args
 	if len(os.Args) > 2 {
 		fmt.Println("Usage:\n\n$ go run .\n\nor\n\n$ go run . 'port no'\n\nwhere port no; is a four digit integer greater than 1023 and not equal to 3306/3389")
@@ -30,28 +50,254 @@ func main() {
 	}
 
 	// Initialize the database
-	dbPath := os.Getenv("DB_PATH")
-	if dbPath == "" {
-		dbPath = "forum.db" // fallback default
-	}
-	err := sqlite.InitializeDatabase(dbPath)
+	initDatabase()
+	defer sqlite.CloseDatabase()
+
+	// Select the upload storage backend
+	uploads, err := storage.FromEnv()
 	if err != nil {
-		log.Fatalf("Failed to initialize database: %v", err)
+		log.Fatalf("Failed to configure storage: %v", err)
 	}
-	defer sqlite.CloseDatabase()
+	storage.Uploads = uploads
+
+	// Render @mentions of users as links to their profiles
+	markdown.SetMentionResolver(sqlite.MentionResolver(sqlite.DB))
 
-	// Set up routes and CORS
+	// Set up routes, CSRF protection and CORS
 	mux := routes.SetupRoutes(sqlite.DB)
-	handler := middleware.CORS(mux)
+	handler := middleware.CORS(middleware.CSRF(mux))
 
 	// Start daily session cleanup in background
 	go scheduleDailyCleanup()
 
+	// Start orphaned upload collection in background
+	go scheduleUploadGC(uploadGCInterval(), uploadGCGrace())
+
+	// Award badges missed by event checks, e.g. for membership age
+	go scheduleBadgeAwards(durationFromEnv("BADGE_INTERVAL", time.Hour))
+
 	// Start server
 	fmt.Printf("🚀 [%s] Server is running at http://localhost%s\n", time.Now().Format(time.RFC3339), port)
 	log.Fatal(http.ListenAndServe(port, handler))
 }
 
+// commands maps maintenance subcommands to their implementation
+var commands = map[string]func(args []string) error{
+	"migrate-storage":    migrateStorage,
+	"gc-uploads":         gcUploads,
+	"set-role":           setRole,
+	"unescape-content":   unescapeContent,
+	"recount-reputation": recountReputation,
+	"award-badges":       awardBadges,
+}
+
+// initDatabase opens the database at DB_PATH and applies the schema
+func initDatabase() {
+	dbPath := os.Getenv("DB_PATH")
+	if dbPath == "" {
+		dbPath = "forum.db" // fallback default
+	}
+	if err := sqlite.InitializeDatabase(dbPath); err != nil {
+		log.Fatalf("Failed to initialize database: %v", err)
+	}
+}
+
+// migrateStorage copies uploads between storage backends and rewrites the
+// stored avatar and image URLs, e.g. go run . migrate-storage -from local -to s3
+func migrateStorage(args []string) error {
+	flags := flag.NewFlagSet("migrate-storage", flag.ExitOnError)
+	from := flags.String("from", "local", "source storage backend (local or s3)")
+	to := flags.String("to", "s3", "destination storage backend (local or s3)")
+	deleteSource := flags.Bool("delete-source", false, "remove files from the source after copying")
+	flags.Parse(args)
+
+	if *from == *to {
+		return fmt.Errorf("source and destination backends are both %q", *from)
+	}
+	src, err := storage.FromName(*from)
+	if err != nil {
+		return err
+	}
+	dst, err := storage.FromName(*to)
+	if err != nil {
+		return err
+	}
+
+	report, err := storage.Migrate(context.Background(), src, dst, *deleteSource, func(oldURL, newURL string) error {
+		return sqlite.ReplaceUploadURL(sqlite.DB, oldURL, newURL)
+	})
+	if err != nil {
+		return err
+	}
+	fmt.Printf("✅ Storage migration finished: %d copied, %d skipped, %d failed\n", report.Copied, report.Skipped, report.Failed)
+	if report.Failed > 0 {
+		return fmt.Errorf("%d files could not be migrated", report.Failed)
+	}
+	return nil
+}
+
+// setRole makes a user an administrator, a moderator or a regular user again,
+// e.g. go run . set-role -user alice -role admin
+func setRole(args []string) error {
+	flags := flag.NewFlagSet("set-role", flag.ExitOnError)
+	username := flags.String("user", "", "username to change")
+	role := flags.String("role", models.RoleAdmin, "new role (admin, moderator or user)")
+	flags.Parse(args)
+
+	if *username == "" {
+		return fmt.Errorf("-user is required")
+	}
+	if *role != models.RoleAdmin && *role != models.RoleModerator && *role != models.RoleUser {
+		return fmt.Errorf("unknown role %q", *role)
+	}
+	if err := sqlite.SetUserRole(sqlite.DB, *username, *role); err == sql.ErrNoRows {
+		return fmt.Errorf("no user named %q", *username)
+	} else if err != nil {
+		return err
+	}
+	fmt.Printf("✅ %s is now %s\n", *username, *role)
+	return nil
+}
+
+// unescapeContent converts post, comment and reply content stored HTML
+// escaped by older versions back to the Markdown that was written, once,
+// e.g. go run . unescape-content
+func unescapeContent(args []string) error {
+	flags := flag.NewFlagSet("unescape-content", flag.ExitOnError)
+	flags.Parse(args)
+
+	changed, err := sqlite.UnescapeContent(sqlite.DB)
+	if err == sqlite.ErrMigrationApplied {
+		fmt.Println("✅ Content was already unescaped, nothing to do")
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	fmt.Printf("✅ Unescaped %d posts, %d comments and %d replies\n", changed["posts"], changed["comments"], changed["replycomments"])
+	return nil
+}
+
+// recountReputation rebuilds the reputation users earned with likes and
+// dislikes, e.g. after upgrading from a version without reputation:
+// go run . recount-reputation
+func recountReputation(args []string) error {
+	flags := flag.NewFlagSet("recount-reputation", flag.ExitOnError)
+	flags.Parse(args)
+
+	counted, err := sqlite.RecountReputation(sqlite.DB)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("✅ Recounted reputation from %d reactions\n", counted)
+	return nil
+}
+
+// awardBadges checks every user against the badge rules right away:
+// go run . award-badges
+func awardBadges(args []string) error {
+	flags := flag.NewFlagSet("award-badges", flag.ExitOnError)
+	flags.Parse(args)
+
+	awarded, err := handlers.AwardAllBadges(sqlite.DB)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("🏅 Awarded %d badges\n", awarded)
+	return nil
+}
+
+// gcUploads deletes uploads no longer referenced by any user or post,
+// e.g. go run . gc-uploads -grace 48h -dry-run
+func gcUploads(args []string) error {
+	flags := flag.NewFlagSet("gc-uploads", flag.ExitOnError)
+	grace := flags.Duration("grace", uploadGCGrace(), "only delete files older than this")
+	dryRun := flags.Bool("dry-run", false, "report orphaned files without deleting them")
+	flags.Parse(args)
+
+	uploads, err := storage.FromEnv()
+	if err != nil {
+		return err
+	}
+	report, err := collectOrphanedUploads(uploads, *grace, *dryRun)
+	if err != nil {
+		return err
+	}
+
+	for _, obj := range report.Orphaned {
+		fmt.Printf("  %s  %8d bytes  %s\n", obj.ModTime.Format(time.RFC3339), obj.Size, obj.Key)
+	}
+	action := "deleted"
+	if *dryRun {
+		action = "would be deleted (dry run)"
+	}
+	fmt.Printf("🧹 Scanned %d uploads: %d referenced, %d within grace period, %d orphaned (%d bytes) %s\n",
+		report.Scanned, report.Referenced, report.TooRecent, len(report.Orphaned), report.OrphanedBytes(), action)
+	if report.Failed > 0 {
+		return fmt.Errorf("%d orphaned files could not be deleted", report.Failed)
+	}
+	return nil
+}
+
+// collectOrphanedUploads reconciles the storage backend against the avatar and image URLs in the database
+func collectOrphanedUploads(uploads storage.Storage, grace time.Duration, dryRun bool) (storage.GCReport, error) {
+	referenced, err := sqlite.GetReferencedUploadURLs(sqlite.DB)
+	if err != nil {
+		return storage.GCReport{}, fmt.Errorf("failed to load upload references: %w", err)
+	}
+	return storage.CollectGarbage(context.Background(), uploads, referenced, grace, dryRun)
+}
+
+// scheduleUploadGC periodically deletes orphaned uploads
+func scheduleUploadGC(interval, grace time.Duration) {
+	for {
+		time.Sleep(interval)
+		report, err := collectOrphanedUploads(storage.Uploads, grace, false)
+		if err != nil {
+			fmt.Printf("❌ [%s] Upload cleanup failed: %v\n", time.Now().Format(time.RFC3339), err)
+			continue
+		}
+		if report.Deleted > 0 || report.Failed > 0 {
+			fmt.Printf("🧹 [%s] Deleted %d orphaned uploads (%d failed)\n", time.Now().Format(time.RFC3339), report.Deleted, report.Failed)
+		}
+	}
+}
+
+// scheduleBadgeAwards periodically awards the badges users earned
+func scheduleBadgeAwards(interval time.Duration) {
+	for {
+		time.Sleep(interval)
+		awarded, err := handlers.AwardAllBadges(sqlite.DB)
+		if err != nil {
+			fmt.Printf("❌ [%s] Awarding badges failed: %v\n", time.Now().Format(time.RFC3339), err)
+			continue
+		}
+		if awarded > 0 {
+			fmt.Printf("🏅 [%s] Awarded %d badges\n", time.Now().Format(time.RFC3339), awarded)
+		}
+	}
+}
+
+// uploadGCInterval reads UPLOAD_GC_INTERVAL (default 6h)
+func uploadGCInterval() time.Duration {
+	return durationFromEnv("UPLOAD_GC_INTERVAL", 6*time.Hour)
+}
+
+// uploadGCGrace reads UPLOAD_GC_GRACE (default 24h)
+func uploadGCGrace() time.Duration {
+	return durationFromEnv("UPLOAD_GC_GRACE", 24*time.Hour)
+}
+
+func durationFromEnv(name string, fallback time.Duration) time.Duration {
+	if v := os.Getenv(name); v != "" {
+		if d, err := time.ParseDuration(v); err == nil && d > 0 {
+			return d
+		}
+		log.Printf("Warning: invalid %s %q, using %s", name, v, fallback)
+	}
+	return fallback
+}
+
 // scheduleDailyCleanup runs session cleanup at midnight every day
 func scheduleDailyCleanup() {
 	for {
@@ -73,5 +319,26 @@ func scheduleDailyCleanup() {
 		} else {
 			fmt.Println("✅ Expired sessions cleaned up successfully at midnight.")
 		}
+		if err := sqlite.CleanupPendingLogins(sqlite.DB); err != nil {
+			fmt.Printf("❌ [%s] Pending login cleanup failed: %v\n", time.Now().Format(time.RFC3339), err)
+		}
+		if err := sqlite.CleanupWebAuthnCeremonies(sqlite.DB); err != nil {
+			fmt.Printf("❌ [%s] Passkey challenge cleanup failed: %v\n", time.Now().Format(time.RFC3339), err)
+		}
+		if err := sqlite.CleanupOIDCLogins(sqlite.DB); err != nil {
+			fmt.Printf("❌ [%s] OpenID Connect login cleanup failed: %v\n", time.Now().Format(time.RFC3339), err)
+		}
+		if err := sqlite.CleanupAPITokens(sqlite.DB); err != nil {
+			fmt.Printf("❌ [%s] Expired API token cleanup failed: %v\n", time.Now().Format(time.RFC3339), err)
+		}
+		if err := sqlite.CleanupContentFingerprints(sqlite.DB, time.Now().Add(-spamfilter.DuplicateWindow)); err != nil {
+			fmt.Printf("❌ [%s] Content fingerprint cleanup failed: %v\n", time.Now().Format(time.RFC3339), err)
+		}
+		if err := sqlite.CleanupModerationQueue(sqlite.DB); err != nil {
+			fmt.Printf("❌ [%s] Moderation queue cleanup failed: %v\n", time.Now().Format(time.RFC3339), err)
+		}
+		if err := sqlite.CleanupMentions(sqlite.DB); err != nil {
+			fmt.Printf("❌ [%s] Mention cleanup failed: %v\n", time.Now().Format(time.RFC3339), err)
+		}
 	}
 }
@@ -0,0 +1,83 @@
+package markdown
+
+import (
+	"container/list"
+	"crypto/sha256"
+	"sync"
+)
+
+// cacheBytes bounds the rendered HTML kept by RenderCached
+const cacheBytes = 32 << 20
+
+type cacheEntry struct {
+	key  [sha256.Size]byte
+	html string
+}
+
+// Cache keeps rendered HTML keyed by a hash of the Markdown source, so each
+// revision of a post or comment is rendered and highlighted once. The
+// least recently used entries are evicted once maxBytes of HTML is held.
+type Cache struct {
+	maxBytes int
+
+	mu      sync.Mutex
+	bytes   int
+	entries map[[sha256.Size]byte]*list.Element
+	order   *list.List // front = most recently used
+}
+
+// NewCache creates a cache holding up to maxBytes of rendered HTML
+func NewCache(maxBytes int) *Cache {
+	return &Cache{
+		maxBytes: maxBytes,
+		entries:  make(map[[sha256.Size]byte]*list.Element),
+		order:    list.New(),
+	}
+}
+
+// Render returns the cached rendering of source, rendering it on a miss
+func (c *Cache) Render(source string) string {
+	key := sha256.Sum256([]byte(source))
+
+	c.mu.Lock()
+	if el, ok := c.entries[key]; ok {
+		c.order.MoveToFront(el)
+		c.mu.Unlock()
+		return el.Value.(*cacheEntry).html
+	}
+	c.mu.Unlock()
+
+	// Rendered outside the lock; two requests for a new revision may both
+	// render it, which is harmless
+	rendered := Render(source)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if _, ok := c.entries[key]; ok || len(rendered) > c.maxBytes {
+		return rendered
+	}
+	c.entries[key] = c.order.PushFront(&cacheEntry{key: key, html: rendered})
+	c.bytes += len(rendered)
+	for c.bytes > c.maxBytes {
+		oldest := c.order.Back()
+		entry := oldest.Value.(*cacheEntry)
+		c.order.Remove(oldest)
+		delete(c.entries, entry.key)
+		c.bytes -= len(entry.html)
+	}
+	return rendered
+}
+
+// Len returns the number of cached renderings
+func (c *Cache) Len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return len(c.entries)
+}
+
+var rendered = NewCache(cacheBytes)
+
+// RenderCached is Render through a process-wide Cache
+func RenderCached(source string) string {
+	return rendered.Render(source)
+}
@@ -0,0 +1,651 @@
+package markdown
+
+import (
+	"html"
+	"regexp"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// piece is a run of rendered inline HTML. Emphasis delimiters and link
+// openers stay pieces of their own until they are matched or given up on.
+type piece struct {
+	html string
+
+	// Emphasis delimiter runs
+	delim     byte // '*' or '_'
+	count     int  // characters not yet used up by a match
+	length    int  // characters in the original run
+	canOpen   bool
+	canClose  bool
+	openTags  string // emphasis opened right after the remaining characters
+	closeTags string // emphasis closed right before them
+
+	// @mentions, which stay plain text inside link text
+	mention string
+	plain   bool
+}
+
+func (pc *piece) String() string {
+	if pc.plain {
+		return "@" + pc.mention
+	}
+	if pc.delim == 0 {
+		return pc.html
+	}
+	return pc.closeTags + strings.Repeat(string(pc.delim), pc.count) + pc.openTags
+}
+
+// bracket is a "[" or "![" that may start a link or an image
+type bracket struct {
+	piece  int // index of its piece
+	delims int // size of the delimiter stack when it was seen
+	source int // offset of the link text in the source
+	image  bool
+	active bool
+}
+
+type inlineParser struct {
+	*parser
+	src      string
+	pos      int
+	pieces   []*piece
+	delims   []int // indexes of the emphasis pieces that may still match
+	brackets []bracket
+	text     strings.Builder // literal text not yet flushed to a piece
+}
+
+// inline renders the inline content of a paragraph, heading or table cell
+func (p *parser) inline(src string) string {
+	ip := &inlineParser{parser: p, src: src}
+	ip.parse()
+
+	var out strings.Builder
+	for _, pc := range ip.pieces {
+		out.WriteString(pc.String())
+	}
+	return out.String()
+}
+
+func (ip *inlineParser) parse() {
+	for ip.pos < len(ip.src) {
+		c := ip.src[ip.pos]
+		switch {
+		case c == '\\':
+			ip.backslash()
+		case c == '`':
+			ip.codeSpan()
+		case c == '*' || c == '_':
+			ip.delimiterRun(c)
+		case c == '[':
+			ip.openBracket(false, 1)
+		case c == '!' && strings.HasPrefix(ip.src[ip.pos:], "!["):
+			ip.openBracket(true, 2)
+		case c == ']':
+			ip.closeBracket()
+		case c == '<':
+			ip.angle()
+		case c == '&':
+			ip.entity()
+		case c == '\n':
+			ip.lineBreak()
+		case ip.autolinkBoundary() && ip.extendedAutolink():
+		case c == '@' && ip.mention():
+		default:
+			_, size := utf8.DecodeRuneInString(ip.src[ip.pos:])
+			ip.text.WriteString(html.EscapeString(ip.src[ip.pos : ip.pos+size]))
+			ip.pos += size
+		}
+	}
+	ip.processEmphasis(0)
+	ip.flush()
+}
+
+// flush moves pending text into a piece
+func (ip *inlineParser) flush() {
+	if ip.text.Len() > 0 {
+		ip.pieces = append(ip.pieces, &piece{html: ip.text.String()})
+		ip.text.Reset()
+	}
+}
+
+func (ip *inlineParser) add(pc *piece) int {
+	ip.flush()
+	ip.pieces = append(ip.pieces, pc)
+	return len(ip.pieces) - 1
+}
+
+func isASCIIPunct(c byte) bool {
+	return c < utf8.RuneSelf && strings.IndexByte("!\"#$%&'()*+,-./:;<=>?@[\\]^_`{|}~", c) >= 0
+}
+
+func (ip *inlineParser) backslash() {
+	ip.pos++
+	switch {
+	case ip.pos < len(ip.src) && ip.src[ip.pos] == '\n':
+		ip.text.WriteString("<br />\n")
+		ip.pos++
+	case ip.pos < len(ip.src) && isASCIIPunct(ip.src[ip.pos]):
+		ip.text.WriteString(html.EscapeString(ip.src[ip.pos : ip.pos+1]))
+		ip.pos++
+	default:
+		ip.text.WriteByte('\\')
+	}
+}
+
+func backtickRun(s string, i int) int {
+	n := 0
+	for i+n < len(s) && s[i+n] == '`' {
+		n++
+	}
+	return n
+}
+
+func (ip *inlineParser) codeSpan() {
+	n := backtickRun(ip.src, ip.pos)
+	for end := ip.pos + n; end < len(ip.src); {
+		next := strings.IndexByte(ip.src[end:], '`')
+		if next < 0 {
+			break
+		}
+		end += next
+		closing := backtickRun(ip.src, end)
+		if closing != n {
+			end += closing
+			continue
+		}
+		code := strings.ReplaceAll(ip.src[ip.pos+n:end], "\n", " ")
+		if len(code) > 2 && code[0] == ' ' && code[len(code)-1] == ' ' && strings.Trim(code, " ") != "" {
+			code = code[1 : len(code)-1]
+		}
+		ip.text.WriteString("<code>" + html.EscapeString(code) + "</code>")
+		ip.pos = end + n
+		return
+	}
+	// No closing run: the backticks are literal
+	ip.text.WriteString(ip.src[ip.pos : ip.pos+n])
+	ip.pos += n
+}
+
+// Emphasis
+
+func (ip *inlineParser) delimiterRun(c byte) {
+	start := ip.pos
+	for ip.pos < len(ip.src) && ip.src[ip.pos] == c {
+		ip.pos++
+	}
+	before, _ := utf8.DecodeLastRuneInString(ip.src[:start])
+	after, _ := utf8.DecodeRuneInString(ip.src[ip.pos:])
+	if start == 0 {
+		before = '\n'
+	}
+	if ip.pos == len(ip.src) {
+		after = '\n'
+	}
+
+	beforeSpace, afterSpace := unicode.IsSpace(before), unicode.IsSpace(after)
+	beforePunct, afterPunct := isPunct(before), isPunct(after)
+	left := !afterSpace && (!afterPunct || beforeSpace || beforePunct)
+	right := !beforeSpace && (!beforePunct || afterSpace || afterPunct)
+
+	pc := &piece{delim: c, count: ip.pos - start, length: ip.pos - start}
+	if c == '*' {
+		pc.canOpen, pc.canClose = left, right
+	} else {
+		// Underscores don't emphasise inside words
+		pc.canOpen = left && (!right || beforePunct)
+		pc.canClose = right && (!left || afterPunct)
+	}
+	ip.delims = append(ip.delims, ip.add(pc))
+}
+
+func isPunct(r rune) bool {
+	return unicode.IsPunct(r) || unicode.IsSymbol(r)
+}
+
+// processEmphasis matches the delimiters above position bottom of the stack
+// into <em> and <strong>, and drops them from the stack
+func (ip *inlineParser) processEmphasis(bottom int) {
+	// openersBottom[key] is the stack position below which no opener for
+	// a closer of that kind exists, so the search isn't repeated
+	openersBottom := map[[3]int]int{}
+
+	for closer := bottom; closer < len(ip.delims); {
+		cl := ip.pieces[ip.delims[closer]]
+		if !cl.canClose {
+			closer++
+			continue
+		}
+		key := [3]int{int(cl.delim), boolInt(cl.canOpen), cl.length % 3}
+		floor := max(bottom, openersBottom[key])
+
+		opener := -1
+		for i := closer - 1; i >= floor; i-- {
+			op := ip.pieces[ip.delims[i]]
+			if op.delim != cl.delim || !op.canOpen {
+				continue
+			}
+			// The rule of 3: a run that can both open and close doesn't
+			// match one whose combined length is a multiple of 3
+			if (op.canClose || cl.canOpen) && (op.length+cl.length)%3 == 0 && (op.length%3 != 0 || cl.length%3 != 0) {
+				continue
+			}
+			opener = i
+			break
+		}
+
+		if opener < 0 {
+			openersBottom[key] = closer
+			if !cl.canOpen {
+				ip.delims = append(ip.delims[:closer], ip.delims[closer+1:]...)
+			} else {
+				closer++
+			}
+			continue
+		}
+
+		op := ip.pieces[ip.delims[opener]]
+		n, tag := 1, "em"
+		if op.count >= 2 && cl.count >= 2 {
+			n, tag = 2, "strong"
+		}
+		op.count -= n
+		cl.count -= n
+		op.openTags = "<" + tag + ">" + op.openTags
+		cl.closeTags += "</" + tag + ">"
+
+		// Delimiters between the two can no longer match anything
+		ip.delims = append(ip.delims[:opener+1], ip.delims[closer:]...)
+		closer = opener + 1
+		if op.count == 0 {
+			ip.delims = append(ip.delims[:opener], ip.delims[opener+1:]...)
+			closer--
+		}
+		if cl.count == 0 {
+			ip.delims = append(ip.delims[:closer], ip.delims[closer+1:]...)
+		}
+	}
+	ip.delims = ip.delims[:bottom]
+}
+
+func boolInt(b bool) int {
+	if b {
+		return 1
+	}
+	return 0
+}
+
+// Links and images
+
+func (ip *inlineParser) openBracket(image bool, width int) {
+	index := ip.add(&piece{html: ip.src[ip.pos : ip.pos+width]})
+	ip.pos += width
+	ip.brackets = append(ip.brackets, bracket{piece: index, delims: len(ip.delims), source: ip.pos, image: image, active: true})
+}
+
+func (ip *inlineParser) closeBracket() {
+	if len(ip.brackets) == 0 {
+		ip.text.WriteByte(']')
+		ip.pos++
+		return
+	}
+	open := ip.brackets[len(ip.brackets)-1]
+	ip.brackets = ip.brackets[:len(ip.brackets)-1]
+	if !open.active {
+		ip.text.WriteByte(']')
+		ip.pos++
+		return
+	}
+
+	label := ip.src[open.source:ip.pos]
+	dest, title, end, ok := ip.linkTarget(ip.pos+1, label)
+	if !ok {
+		ip.text.WriteByte(']')
+		ip.pos++
+		return
+	}
+	ip.pos = end
+
+	ip.flush()
+	ip.processEmphasis(open.delims)
+	if open.image {
+		var alt strings.Builder
+		for _, pc := range ip.pieces[open.piece+1:] {
+			alt.WriteString(pc.String())
+		}
+		ip.pieces = ip.pieces[:open.piece]
+		// The pieces are HTML already; the alt gets their plain text
+		img := `<img src="` + html.EscapeString(normalizeURL(dest)) + `" alt="` + html.EscapeString(html.UnescapeString(stripTags(alt.String()))) + `"`
+		if title != "" {
+			img += ` title="` + html.EscapeString(title) + `"`
+		}
+		ip.add(&piece{html: img + " />"})
+		return
+	}
+
+	a := `<a href="` + html.EscapeString(normalizeURL(dest)) + `"`
+	if title != "" {
+		a += ` title="` + html.EscapeString(title) + `"`
+	}
+	ip.pieces[open.piece] = &piece{html: a + ">"}
+	for _, pc := range ip.pieces[open.piece+1:] {
+		if pc.mention != "" {
+			pc.plain = true
+		}
+	}
+	ip.add(&piece{html: "</a>"})
+
+	// Links may not contain other links
+	for i := range ip.brackets {
+		if !ip.brackets[i].image {
+			ip.brackets[i].active = false
+		}
+	}
+}
+
+// linkTarget parses what follows the "]" of a link at position i: an inline
+// destination and title, or a reference label, or nothing if label itself
+// names a reference
+func (ip *inlineParser) linkTarget(i int, label string) (dest, title string, end int, ok bool) {
+	if i < len(ip.src) && ip.src[i] == '(' {
+		if dest, title, end, ok := parseInlineTarget(ip.src, i+1); ok {
+			return dest, title, end, true
+		}
+	}
+
+	end = i
+	if i < len(ip.src) && ip.src[i] == '[' {
+		if close := labelEnd(ip.src, i+1); close >= 0 {
+			if ref := ip.src[i+1 : close]; strings.TrimSpace(ref) != "" {
+				label = ref
+			}
+			end = close + 1
+		}
+	}
+	ref, found := ip.refs[normalizeLabel(label)]
+	if !found {
+		return "", "", 0, false
+	}
+	return ref.dest, ref.title, end, true
+}
+
+// labelEnd finds the "]" closing a link label starting at i
+func labelEnd(s string, i int) int {
+	for j := i; j < len(s) && j-i <= 999; j++ {
+		switch s[j] {
+		case '\\':
+			j++
+		case '[':
+			return -1
+		case ']':
+			return j
+		}
+	}
+	return -1
+}
+
+// parseInlineTarget parses `dest "title")` starting after the "("
+func parseInlineTarget(s string, i int) (dest, title string, end int, ok bool) {
+	i = skipSpace(s, i)
+	if i < len(s) && s[i] == '<' {
+		close := strings.IndexAny(s[i+1:], "<>\n")
+		if close < 0 || s[i+1+close] != '>' {
+			return "", "", 0, false
+		}
+		dest = s[i+1 : i+1+close]
+		i += close + 2
+	} else {
+		start, depth := i, 0
+		for ; i < len(s); i++ {
+			c := s[i]
+			if c == '\\' && i+1 < len(s) && isASCIIPunct(s[i+1]) {
+				i++
+				continue
+			}
+			if c <= ' ' || (c == ')' && depth == 0) {
+				break
+			}
+			if c == '(' {
+				depth++
+			} else if c == ')' {
+				depth--
+			}
+		}
+		if depth != 0 {
+			return "", "", 0, false
+		}
+		dest = s[start:i]
+	}
+
+	afterDest := i
+	i = skipSpace(s, i)
+	if i < len(s) && i > afterDest && strings.IndexByte(`"'(`, s[i]) >= 0 {
+		closing := s[i]
+		if closing == '(' {
+			closing = ')'
+		}
+		j := i + 1
+		for ; j < len(s) && s[j] != closing; j++ {
+			if s[j] == '\\' {
+				j++
+			}
+		}
+		if j >= len(s) {
+			return "", "", 0, false
+		}
+		title = unescapeText(s[i+1 : j])
+		i = skipSpace(s, j+1)
+	}
+	if i >= len(s) || s[i] != ')' {
+		return "", "", 0, false
+	}
+	return unescapeText(dest), title, i + 1, true
+}
+
+// skipSpace skips spaces, tabs and at most one line ending
+func skipSpace(s string, i int) int {
+	newline := false
+	for ; i < len(s); i++ {
+		switch s[i] {
+		case ' ', '\t':
+		case '\n':
+			if newline {
+				return i
+			}
+			newline = true
+		default:
+			return i
+		}
+	}
+	return i
+}
+
+var tagPattern = regexp.MustCompile(`<[^>]*>`)
+
+func stripTags(s string) string {
+	return tagPattern.ReplaceAllString(s, "")
+}
+
+// Autolinks, raw HTML and entities
+
+var (
+	uriAutolink   = regexp.MustCompile(`^<([A-Za-z][A-Za-z0-9+.-]{1,31}:[^\x00-\x20<>]*)>`)
+	emailAutolink = regexp.MustCompile(`^<([A-Za-z0-9.!#$%&'*+/=?^_{|}~-]+@[A-Za-z0-9](?:[A-Za-z0-9-]{0,61}[A-Za-z0-9])?(?:\.[A-Za-z0-9](?:[A-Za-z0-9-]{0,61}[A-Za-z0-9])?)*)>`)
+	rawHTML       = regexp.MustCompile(`^(?:<[A-Za-z][A-Za-z0-9-]*(?:\s+[A-Za-z_:][A-Za-z0-9_.:-]*(?:\s*=\s*(?:[^\s"'=<>` + "`" + `]+|'[^']*'|"[^"]*"))?)*\s*/?>|</[A-Za-z][A-Za-z0-9-]*\s*>|<!--(?:-?[^>-])(?:-?[^-])*-->)`)
+	entityPattern = regexp.MustCompile(`^&(?:#[0-9]{1,7}|#[xX][0-9a-fA-F]{1,6}|[A-Za-z][A-Za-z0-9]{1,31});`)
+)
+
+func (ip *inlineParser) angle() {
+	rest := ip.src[ip.pos:]
+	if m := uriAutolink.FindStringSubmatch(rest); m != nil {
+		ip.text.WriteString(autolink(m[1], m[1]))
+		ip.pos += len(m[0])
+		return
+	}
+	if m := emailAutolink.FindStringSubmatch(rest); m != nil {
+		ip.text.WriteString(autolink("mailto:"+m[1], m[1]))
+		ip.pos += len(m[0])
+		return
+	}
+	// Raw HTML is filtered here, as authors may not write the markup only
+	// the renderer produces, and left to Sanitize to allow or escape
+	if m := rawHTML.FindString(rest); m != "" {
+		ip.text.WriteString(sanitizeRawTag(m))
+		ip.pos += len(m)
+		return
+	}
+	ip.text.WriteString("&lt;")
+	ip.pos++
+}
+
+func autolink(dest, text string) string {
+	return `<a href="` + html.EscapeString(normalizeURL(dest)) + `">` + html.EscapeString(text) + "</a>"
+}
+
+func (ip *inlineParser) entity() {
+	if m := entityPattern.FindString(ip.src[ip.pos:]); m != "" {
+		if decoded := html.UnescapeString(m); decoded != m {
+			ip.text.WriteString(html.EscapeString(decoded))
+			ip.pos += len(m)
+			return
+		}
+	}
+	ip.text.WriteString("&amp;")
+	ip.pos++
+}
+
+// lineBreak renders a line ending: hard after two spaces, soft otherwise
+func (ip *inlineParser) lineBreak() {
+	pending := ip.text.String()
+	trimmed := strings.TrimRight(pending, " ")
+	hard := len(pending)-len(trimmed) >= 2
+	ip.text.Reset()
+	ip.text.WriteString(trimmed)
+	if hard {
+		ip.text.WriteString("<br />")
+	}
+	ip.text.WriteByte('\n')
+	ip.pos++
+	for ip.pos < len(ip.src) && ip.src[ip.pos] == ' ' {
+		ip.pos++
+	}
+}
+
+// unescapeText resolves backslash escapes and entities in link
+// destinations, titles and code info strings
+func unescapeText(s string) string {
+	if !strings.ContainsAny(s, `\&`) {
+		return s
+	}
+	var out strings.Builder
+	for i := 0; i < len(s); i++ {
+		switch {
+		case s[i] == '\\' && i+1 < len(s) && isASCIIPunct(s[i+1]):
+			out.WriteByte(s[i+1])
+			i++
+		case s[i] == '&':
+			if m := entityPattern.FindString(s[i:]); m != "" {
+				out.WriteString(html.UnescapeString(m))
+				i += len(m) - 1
+				continue
+			}
+			out.WriteByte('&')
+		default:
+			out.WriteByte(s[i])
+		}
+	}
+	return out.String()
+}
+
+// normalizeURL percent-encodes the characters a URL can't hold as they are,
+// leaving existing escapes alone
+func normalizeURL(s string) string {
+	const hex = "0123456789ABCDEF"
+	var out strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case c == '%' && i+2 < len(s) && isHex(s[i+1]) && isHex(s[i+2]):
+			out.WriteByte(c)
+		case c <= ' ' || c >= 0x7f || strings.IndexByte("\"<>\\^`{|}", c) >= 0:
+			out.WriteByte('%')
+			out.WriteByte(hex[c>>4])
+			out.WriteByte(hex[c&15])
+		default:
+			out.WriteByte(c)
+		}
+	}
+	return out.String()
+}
+
+func isHex(c byte) bool {
+	return ('0' <= c && c <= '9') || ('a' <= c && c <= 'f') || ('A' <= c && c <= 'F')
+}
+
+// Extended autolinks: bare www., http:// and https:// links and email
+// addresses, as on GitHub
+
+var (
+	wwwAutolink   = regexp.MustCompile(`^(?:https?://|www\.)[A-Za-z0-9_-]+(?:\.[A-Za-z0-9_-]+)*[^\s<]*`)
+	bareEmailLink = regexp.MustCompile(`^[A-Za-z0-9._+-]+@[A-Za-z0-9_-]+(?:\.[A-Za-z0-9_-]+)+`)
+)
+
+// autolinkBoundary reports whether an extended autolink may start here:
+// at the start of a line, after whitespace or after one of *_~(
+func (ip *inlineParser) autolinkBoundary() bool {
+	c := ip.src[ip.pos]
+	if !(c == 'w' || c == 'h' || ('A' <= c && c <= 'Z') || ('a' <= c && c <= 'z') || ('0' <= c && c <= '9')) {
+		return false
+	}
+	if ip.pos == 0 {
+		return true
+	}
+	prev := ip.src[ip.pos-1]
+	return prev == ' ' || prev == '\t' || prev == '\n' || strings.IndexByte("*_~(", prev) >= 0
+}
+
+func (ip *inlineParser) extendedAutolink() bool {
+	rest := ip.src[ip.pos:]
+	if m := wwwAutolink.FindString(rest); m != "" {
+		m = trimAutolink(m)
+		dest := m
+		if strings.HasPrefix(m, "www.") {
+			dest = "http://" + m
+		}
+		ip.text.WriteString(autolink(dest, m))
+		ip.pos += len(m)
+		return true
+	}
+	if m := bareEmailLink.FindString(rest); m != "" {
+		// The address may not end in - or _, but a trailing . is punctuation
+		m = strings.TrimSuffix(m, ".")
+		if last := m[len(m)-1]; last == '-' || last == '_' {
+			return false
+		}
+		ip.text.WriteString(autolink("mailto:"+m, m))
+		ip.pos += len(m)
+		return true
+	}
+	return false
+}
+
+var trailingEntity = regexp.MustCompile(`&[A-Za-z0-9]+;$`)
+
+// trimAutolink drops trailing punctuation, unbalanced closing parentheses
+// and entity references from an extended autolink
+func trimAutolink(link string) string {
+	for {
+		trimmed := strings.TrimRight(link, "?!.,:*_~'\"")
+		if m := trailingEntity.FindString(trimmed); m != "" {
+			trimmed = trimmed[:len(trimmed)-len(m)]
+		}
+		if strings.HasSuffix(trimmed, ")") && strings.Count(trimmed, ")") > strings.Count(trimmed, "(") {
+			trimmed = trimmed[:len(trimmed)-1]
+		}
+		if trimmed == link {
+			return link
+		}
+		link = trimmed
+	}
+}
@@ -0,0 +1,618 @@
+// Package markdown renders the Markdown users write in posts and comments
+// to safe HTML: CommonMark blocks and inlines plus the GitHub extensions
+// fenced code, tables and autolinks. Code in languages the highlight
+// package knows is highlighted. Raw HTML is allowed through but the
+// output is always passed through Sanitize, an allowlist of tags and
+// attributes.
+package markdown
+
+import (
+	"html"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"forum/highlight"
+)
+
+// Render converts Markdown source to sanitized HTML
+func Render(source string) string {
+	p, blocks := parse(source, mentionResolver())
+	var out strings.Builder
+	for _, b := range blocks {
+		p.renderBlock(&out, b, false)
+	}
+	return Sanitize(out.String())
+}
+
+type blockKind int
+
+const (
+	paragraphBlock blockKind = iota
+	headingBlock
+	codeBlock
+	quoteBlock
+	listBlock
+	itemBlock
+	ruleBlock
+	tableBlock
+)
+
+type block struct {
+	kind     blockKind
+	text     string   // inline source of paragraphs and headings, code
+	level    int      // heading level
+	info     string   // language of fenced code
+	children []*block // blocks in a quote or list item, items of a list
+	ordered  bool
+	start    int
+	tight    bool
+	align    []string   // table column alignment
+	rows     [][]string // table cells, header first
+}
+
+type linkRef struct {
+	dest, title string
+}
+
+type parser struct {
+	refs     map[string]linkRef
+	resolve  MentionResolver // nil leaves mentions as text
+	mentions []*piece
+}
+
+func parse(source string, resolve MentionResolver) (*parser, []*block) {
+	p := &parser{refs: map[string]linkRef{}, resolve: resolve}
+	source = strings.ReplaceAll(strings.ReplaceAll(source, "\r\n", "\n"), "\r", "\n")
+	source = strings.ReplaceAll(source, "\x00", "�")
+	blocks, _ := p.parseBlocks(strings.Split(source, "\n"))
+	return p, blocks
+}
+
+// parseBlocks parses lines into blocks. loose reports whether a blank line
+// separated two of them, which makes the list item they are in loose.
+func (p *parser) parseBlocks(lines []string) (blocks []*block, loose bool) {
+	blank := false
+	for i := 0; i < len(lines); {
+		line := lines[i]
+		if isBlank(line) {
+			blank = len(blocks) > 0
+			i++
+			continue
+		}
+		if blank {
+			loose = true
+			blank = false
+		}
+
+		var b *block
+		switch indent := leadingIndent(line); {
+		case indent >= 4:
+			b, i = parseIndentedCode(lines, i)
+		case isThematicBreak(line):
+			b, i = &block{kind: ruleBlock}, i+1
+		default:
+			if level, text, ok := atxHeading(line); ok {
+				b, i = &block{kind: headingBlock, level: level, text: text}, i+1
+			} else if _, _, _, ok := fenceStart(line); ok {
+				b, i = parseFencedCode(lines, i)
+			} else if _, ok := stripQuoteMarker(line); ok {
+				b, i = p.parseQuote(lines, i)
+			} else if _, ok := listMarker(line); ok {
+				b, i = p.parseList(lines, i)
+			} else if isTableStart(lines, i) {
+				b, i = parseTable(lines, i)
+			} else {
+				b, i = p.parseParagraph(lines, i)
+			}
+		}
+		if b != nil {
+			blocks = append(blocks, b)
+		}
+	}
+	return blocks, loose
+}
+
+func parseIndentedCode(lines []string, i int) (*block, int) {
+	var code []string
+	for ; i < len(lines) && (isBlank(lines[i]) || leadingIndent(lines[i]) >= 4); i++ {
+		code = append(code, removeIndent(lines[i], 4))
+	}
+	// Trailing blank lines belong to whatever follows
+	for len(code) > 0 && isBlank(code[len(code)-1]) {
+		code = code[:len(code)-1]
+		i--
+	}
+	return &block{kind: codeBlock, text: strings.Join(code, "\n") + "\n"}, i
+}
+
+func parseFencedCode(lines []string, i int) (*block, int) {
+	indent, fence, info, _ := fenceStart(lines[i])
+	var code []string
+	for i++; i < len(lines); i++ {
+		if isFenceEnd(lines[i], fence) {
+			i++
+			break
+		}
+		code = append(code, removeIndent(lines[i], indent))
+	}
+	text := strings.Join(code, "\n")
+	if len(code) > 0 {
+		text += "\n"
+	}
+	language, _, _ := strings.Cut(info, " ")
+	return &block{kind: codeBlock, text: text, info: unescapeText(language)}, i
+}
+
+func (p *parser) parseQuote(lines []string, i int) (*block, int) {
+	var inner []string
+	for ; i < len(lines); i++ {
+		if rest, ok := stripQuoteMarker(lines[i]); ok {
+			inner = append(inner, rest)
+			continue
+		}
+		// A paragraph in the quote may continue on lines without a marker
+		if isBlank(lines[i]) || len(inner) == 0 || isBlank(inner[len(inner)-1]) || interruptsParagraph(lines[i]) {
+			break
+		}
+		inner = append(inner, lines[i])
+	}
+	children, _ := p.parseBlocks(inner)
+	return &block{kind: quoteBlock, children: children}, i
+}
+
+func (p *parser) parseList(lines []string, i int) (*block, int) {
+	first, _ := listMarker(lines[i])
+	list := &block{kind: listBlock, ordered: first.ordered, start: first.start}
+	loose := false
+
+	for i < len(lines) {
+		m, ok := listMarker(lines[i])
+		if !ok || m.ordered != first.ordered || m.delimiter != first.delimiter || isThematicBreak(lines[i]) {
+			break
+		}
+
+		item := []string{m.content}
+		last := i // last non-blank line of the item
+		for i++; i < len(lines); i++ {
+			line := lines[i]
+			if isBlank(line) {
+				// An item can start with at most one blank line
+				if m.content == "" && last == i-1 && len(item) == 1 {
+					break
+				}
+				item = append(item, "")
+				continue
+			}
+			if leadingIndent(line) >= m.width {
+				item = append(item, removeIndent(line, m.width))
+				last = i
+				continue
+			}
+			if _, isItem := listMarker(line); !isItem && last == i-1 && !interruptsParagraph(line) {
+				item = append(item, line) // lazy paragraph continuation
+				last = i
+				continue
+			}
+			break
+		}
+
+		blankAfter := last < i-1
+		item = item[:len(item)-(i-1-last)]
+		i = last + 1
+		children, itemLoose := p.parseBlocks(item)
+		list.children = append(list.children, &block{kind: itemBlock, children: children})
+		loose = loose || itemLoose
+
+		if next, ok := nextNonBlank(lines, i); ok && blankAfter {
+			if m, isItem := listMarker(lines[next]); isItem && m.ordered == first.ordered && m.delimiter == first.delimiter && !isThematicBreak(lines[next]) {
+				loose = true
+				i = next
+			}
+		}
+	}
+	list.tight = !loose
+	return list, i
+}
+
+func nextNonBlank(lines []string, i int) (int, bool) {
+	for ; i < len(lines); i++ {
+		if !isBlank(lines[i]) {
+			return i, true
+		}
+	}
+	return 0, false
+}
+
+func (p *parser) parseParagraph(lines []string, i int) (*block, int) {
+	text := []string{strings.TrimLeft(lines[i], " \t")}
+	level := 0
+	for i++; i < len(lines); i++ {
+		line := lines[i]
+		if isBlank(line) {
+			break
+		}
+		if level = setextLevel(line); level > 0 {
+			i++
+			break
+		}
+		if interruptsParagraph(line) || isTableStart(lines, i) {
+			break
+		}
+		text = append(text, strings.TrimLeft(line, " \t"))
+	}
+
+	text = p.takeLinkRefs(text)
+	if len(text) == 0 {
+		return nil, i
+	}
+	b := &block{kind: paragraphBlock, text: strings.TrimRight(strings.Join(text, "\n"), " \t")}
+	if level > 0 {
+		b.kind, b.level = headingBlock, level
+	}
+	return b, i
+}
+
+var linkRefPattern = regexp.MustCompile(`^\[((?:[^\\\[\]]|\\.){1,999})\]:[ \t]*(<[^<>\n]*>|\S+)(?:[ \t]+("(?:[^"\\]|\\.)*"|'(?:[^'\\]|\\.)*'|\((?:[^()\\]|\\.)*\)))?[ \t]*$`)
+
+// takeLinkRefs records the link reference definitions a paragraph starts
+// with and returns the lines left
+func (p *parser) takeLinkRefs(lines []string) []string {
+	for len(lines) > 0 {
+		m := linkRefPattern.FindStringSubmatch(lines[0])
+		if m == nil {
+			break
+		}
+		label := normalizeLabel(m[1])
+		if label == "" {
+			break
+		}
+		if _, exists := p.refs[label]; !exists {
+			dest := strings.TrimSuffix(strings.TrimPrefix(m[2], "<"), ">")
+			title := ""
+			if len(m[3]) >= 2 {
+				title = m[3][1 : len(m[3])-1]
+			}
+			p.refs[label] = linkRef{dest: unescapeText(dest), title: unescapeText(title)}
+		}
+		lines = lines[1:]
+	}
+	return lines
+}
+
+func normalizeLabel(label string) string {
+	return strings.ToLower(strings.Join(strings.Fields(label), " "))
+}
+
+// Tables
+
+var delimiterCell = regexp.MustCompile(`^:?-+:?$`)
+
+func isTableStart(lines []string, i int) bool {
+	if i+1 >= len(lines) || leadingIndent(lines[i]) >= 4 || !strings.Contains(lines[i], "|") {
+		return false
+	}
+	align, ok := delimiterRow(lines[i+1])
+	return ok && len(splitRow(lines[i])) == len(align)
+}
+
+func delimiterRow(line string) ([]string, bool) {
+	if leadingIndent(line) >= 4 || !strings.ContainsAny(line, "|-") {
+		return nil, false
+	}
+	cells := splitRow(line)
+	align := make([]string, len(cells))
+	for n, cell := range cells {
+		if !delimiterCell.MatchString(cell) {
+			return nil, false
+		}
+		switch {
+		case strings.HasPrefix(cell, ":") && strings.HasSuffix(cell, ":"):
+			align[n] = "center"
+		case strings.HasPrefix(cell, ":"):
+			align[n] = "left"
+		case strings.HasSuffix(cell, ":"):
+			align[n] = "right"
+		}
+	}
+	// A lone "---" is a setext underline or a rule, not a table
+	return align, len(cells) > 1 || strings.Contains(line, "|")
+}
+
+// splitRow splits a table row on pipes that aren't escaped
+func splitRow(line string) []string {
+	line = strings.TrimSpace(line)
+	line = strings.TrimPrefix(line, "|")
+	if strings.HasSuffix(line, "|") && !strings.HasSuffix(line, `\|`) {
+		line = line[:len(line)-1]
+	}
+	var cells []string
+	var cell strings.Builder
+	for i := 0; i < len(line); i++ {
+		switch {
+		case line[i] == '\\' && i+1 < len(line) && line[i+1] == '|':
+			cell.WriteByte('|')
+			i++
+		case line[i] == '|':
+			cells = append(cells, strings.TrimSpace(cell.String()))
+			cell.Reset()
+		default:
+			cell.WriteByte(line[i])
+		}
+	}
+	return append(cells, strings.TrimSpace(cell.String()))
+}
+
+func parseTable(lines []string, i int) (*block, int) {
+	align, _ := delimiterRow(lines[i+1])
+	table := &block{kind: tableBlock, align: align, rows: [][]string{splitRow(lines[i])}}
+	for i += 2; i < len(lines); i++ {
+		if isBlank(lines[i]) || interruptsParagraph(lines[i]) {
+			break
+		}
+		row := splitRow(lines[i])
+		// Rows are cut or padded to the header's width
+		row = append(row, make([]string, max(0, len(align)-len(row)))...)
+		table.rows = append(table.rows, row[:len(align)])
+	}
+	return table, i
+}
+
+// Line classification
+
+func isBlank(line string) bool {
+	return strings.TrimLeft(line, " \t") == ""
+}
+
+// leadingIndent counts the columns of leading whitespace, with tab stops of 4
+func leadingIndent(line string) int {
+	col := 0
+	for _, c := range line {
+		switch c {
+		case ' ':
+			col++
+		case '\t':
+			col += 4 - col%4
+		default:
+			return col
+		}
+	}
+	return col
+}
+
+// removeIndent removes up to n columns of leading whitespace, splitting a
+// tab that straddles the limit into spaces
+func removeIndent(line string, n int) string {
+	col := 0
+	for i, c := range line {
+		if col >= n {
+			return line[i:]
+		}
+		switch c {
+		case ' ':
+			col++
+		case '\t':
+			width := 4 - col%4
+			if col+width > n {
+				return strings.Repeat(" ", col+width-n) + line[i+1:]
+			}
+			col += width
+		default:
+			return line[i:]
+		}
+	}
+	return ""
+}
+
+var (
+	thematicBreak = regexp.MustCompile(`^ {0,3}(?:(?:\*[ \t]*){3,}|(?:-[ \t]*){3,}|(?:_[ \t]*){3,})$`)
+	atxPattern    = regexp.MustCompile(`^ {0,3}(#{1,6})(?:[ \t]+(.*?))?(?:[ \t]+#+)?[ \t]*$`)
+	fencePattern  = regexp.MustCompile("^( {0,3})(`{3,}|~{3,})(.*)$")
+	setextPattern = regexp.MustCompile(`^ {0,3}(=+|-+)[ \t]*$`)
+)
+
+func isThematicBreak(line string) bool {
+	return thematicBreak.MatchString(line)
+}
+
+func atxHeading(line string) (level int, text string, ok bool) {
+	m := atxPattern.FindStringSubmatch(line)
+	if m == nil {
+		return 0, "", false
+	}
+	text = m[2]
+	// "# #" is an empty heading, "#5 bolt" is not a heading at all
+	if strings.Trim(text, "#") == "" {
+		text = ""
+	}
+	return len(m[1]), strings.TrimSpace(text), true
+}
+
+func fenceStart(line string) (indent int, fence, info string, ok bool) {
+	m := fencePattern.FindStringSubmatch(line)
+	if m == nil || (m[2][0] == '`' && strings.Contains(m[3], "`")) {
+		return 0, "", "", false
+	}
+	return len(m[1]), m[2], strings.TrimSpace(m[3]), true
+}
+
+func isFenceEnd(line, fence string) bool {
+	if leadingIndent(line) >= 4 {
+		return false
+	}
+	trimmed := strings.TrimSpace(line)
+	return len(trimmed) >= len(fence) && strings.Trim(trimmed, fence[:1]) == ""
+}
+
+func setextLevel(line string) int {
+	m := setextPattern.FindStringSubmatch(line)
+	switch {
+	case m == nil:
+		return 0
+	case m[1][0] == '=':
+		return 1
+	default:
+		return 2
+	}
+}
+
+// stripQuoteMarker removes a blockquote marker and the optional space after it
+func stripQuoteMarker(line string) (string, bool) {
+	if leadingIndent(line) >= 4 {
+		return "", false
+	}
+	rest := strings.TrimLeft(line, " ")
+	if !strings.HasPrefix(rest, ">") {
+		return "", false
+	}
+	rest = rest[1:]
+	if strings.HasPrefix(rest, "\t") {
+		return removeIndent(rest, 2), true // the tab after ">" starts at column 2
+	}
+	return strings.TrimPrefix(rest, " "), true
+}
+
+type marker struct {
+	ordered   bool
+	delimiter byte // bullet character, or . or ) after the number
+	start     int
+	width     int // columns from the line start to the item content
+	content   string
+}
+
+var listPattern = regexp.MustCompile(`^( {0,3})([-+*]|[0-9]{1,9}[.)])([ \t]+|$)(.*)$`)
+
+func listMarker(line string) (marker, bool) {
+	m := listPattern.FindStringSubmatch(line)
+	if m == nil {
+		return marker{}, false
+	}
+	mk := marker{delimiter: m[2][len(m[2])-1]}
+	if n, err := strconv.Atoi(m[2][:len(m[2])-1]); err == nil {
+		mk.ordered, mk.start = true, n
+	}
+
+	markerEnd := len(m[1]) + len(m[2])
+	spaces := leadingIndent(strings.Repeat(" ", markerEnd)+m[3]) - markerEnd
+	switch {
+	case m[4] == "":
+		mk.width = markerEnd + 1
+	case spaces > 4:
+		// The content is indented code, one space belongs to the marker
+		mk.width = markerEnd + 1
+		mk.content = removeIndent(strings.Repeat(" ", spaces-1)+m[4], 0)
+	default:
+		mk.width = markerEnd + spaces
+		mk.content = m[4]
+	}
+	return mk, true
+}
+
+// interruptsParagraph reports whether a line starts a block that ends a
+// paragraph without a blank line in between
+func interruptsParagraph(line string) bool {
+	if leadingIndent(line) >= 4 {
+		return false
+	}
+	if isThematicBreak(line) {
+		return true
+	}
+	if _, _, ok := atxHeading(line); ok {
+		return true
+	}
+	if _, _, _, ok := fenceStart(line); ok {
+		return true
+	}
+	if _, ok := stripQuoteMarker(line); ok {
+		return true
+	}
+	// Only non-empty bullets and lists starting at 1 interrupt a paragraph
+	m, ok := listMarker(line)
+	return ok && !isBlank(m.content) && (!m.ordered || m.start == 1)
+}
+
+// Rendering
+
+func (p *parser) renderBlock(out *strings.Builder, b *block, tight bool) {
+	switch b.kind {
+	case paragraphBlock:
+		if tight {
+			out.WriteString(p.inline(b.text))
+			return
+		}
+		out.WriteString("<p>" + p.inline(b.text) + "</p>\n")
+	case headingBlock:
+		tag := "h" + strconv.Itoa(b.level)
+		out.WriteString("<" + tag + ">" + p.inline(b.text) + "</" + tag + ">\n")
+	case codeBlock:
+		// Highlighted code comes with the code as written for copy buttons
+		if code, ok := highlight.Code(b.info, b.text); ok {
+			lang, _ := highlight.Language(b.info)
+			out.WriteString(`<div class="code-block" data-lang="` + lang + `">` + "\n")
+			out.WriteString(`<pre class="highlight"><code class="language-` + lang + `">` + code + "</code></pre>\n")
+			out.WriteString(`<pre class="code-raw" hidden>` + html.EscapeString(b.text) + "</pre>\n</div>\n")
+			return
+		}
+		out.WriteString("<pre><code")
+		if b.info != "" {
+			out.WriteString(` class="language-` + html.EscapeString(b.info) + `"`)
+		}
+		out.WriteString(">" + html.EscapeString(b.text) + "</code></pre>\n")
+	case ruleBlock:
+		out.WriteString("<hr />\n")
+	case quoteBlock:
+		out.WriteString("<blockquote>\n")
+		for _, child := range b.children {
+			p.renderBlock(out, child, false)
+		}
+		out.WriteString("</blockquote>\n")
+	case listBlock:
+		tag := "ul"
+		if b.ordered {
+			tag = "ol"
+		}
+		out.WriteString("<" + tag)
+		if b.ordered && b.start != 1 {
+			out.WriteString(` start="` + strconv.Itoa(b.start) + `"`)
+		}
+		out.WriteString(">\n")
+		for _, item := range b.children {
+			out.WriteString("<li>")
+			for n, child := range item.children {
+				if n == 0 && !(b.tight && child.kind == paragraphBlock) {
+					out.WriteString("\n")
+				}
+				p.renderBlock(out, child, b.tight)
+				if b.tight && child.kind == paragraphBlock && n < len(item.children)-1 {
+					out.WriteString("\n")
+				}
+			}
+			out.WriteString("</li>\n")
+		}
+		out.WriteString("</" + tag + ">\n")
+	case tableBlock:
+		out.WriteString("<table>\n<thead>\n")
+		p.renderRow(out, b.rows[0], b.align, "th")
+		out.WriteString("</thead>\n")
+		if len(b.rows) > 1 {
+			out.WriteString("<tbody>\n")
+			for _, row := range b.rows[1:] {
+				p.renderRow(out, row, b.align, "td")
+			}
+			out.WriteString("</tbody>\n")
+		}
+		out.WriteString("</table>\n")
+	}
+}
+
+func (p *parser) renderRow(out *strings.Builder, cells, align []string, tag string) {
+	out.WriteString("<tr>\n")
+	for n, cell := range cells {
+		out.WriteString("<" + tag)
+		if align[n] != "" {
+			out.WriteString(` align="` + align[n] + `"`)
+		}
+		out.WriteString(">" + p.inline(cell) + "</" + tag + ">\n")
+	}
+	out.WriteString("</tr>\n")
+}
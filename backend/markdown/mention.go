@@ -0,0 +1,93 @@
+package markdown
+
+import (
+	"html"
+	"net/url"
+	"regexp"
+	"strings"
+	"sync"
+)
+
+// mentionPattern matches an @mention; names follow the username rules of
+// utils.ValidateUsername
+var mentionPattern = regexp.MustCompile(`^@([A-Za-z0-9_-]{3,30})`)
+
+// MentionResolver returns the username a mentioned name belongs to, as
+// registered, or false if there is no such user
+type MentionResolver func(name string) (string, bool)
+
+var (
+	resolverMu sync.RWMutex
+	resolver   MentionResolver
+)
+
+// SetMentionResolver sets how Render resolves @mentions into links to user
+// profiles. Without one, mentions are left as text. Renderings already in
+// the cache are kept, so a mention of someone who registers later only
+// becomes a link once the revision is rendered again.
+func SetMentionResolver(resolve MentionResolver) {
+	resolverMu.Lock()
+	defer resolverMu.Unlock()
+	resolver = resolve
+}
+
+func mentionResolver() MentionResolver {
+	resolverMu.RLock()
+	defer resolverMu.RUnlock()
+	return resolver
+}
+
+// Mentions returns the distinct names @mentioned in source, in the order
+// they first appear and compared without regard to case. Mentions in code
+// and in link text don't count.
+func Mentions(source string) []string {
+	p, blocks := parse(source, nil)
+	var out strings.Builder
+	for _, b := range blocks {
+		p.renderBlock(&out, b, false)
+	}
+
+	names := []string{}
+	seen := map[string]bool{}
+	for _, pc := range p.mentions {
+		if key := strings.ToLower(pc.mention); !pc.plain && !seen[key] {
+			seen[key] = true
+			names = append(names, pc.mention)
+		}
+	}
+	return names
+}
+
+// mention parses an @mention. It has to follow the start of the text or a
+// character that can't be part of a name or an email address.
+func (ip *inlineParser) mention() bool {
+	if ip.pos > 0 {
+		prev := ip.src[ip.pos-1]
+		if isWordByte(prev) || strings.IndexByte("-.+@/", prev) >= 0 {
+			return false
+		}
+	}
+	m := mentionPattern.FindStringSubmatch(ip.src[ip.pos:])
+	if m == nil {
+		return false
+	}
+	// A longer run of name characters is no mention at all
+	if end := ip.pos + len(m[0]); end < len(ip.src) && isWordByte(ip.src[end]) {
+		return false
+	}
+
+	pc := &piece{html: "@" + m[1], mention: m[1]}
+	if ip.resolve != nil {
+		if username, ok := ip.resolve(m[1]); ok {
+			pc.html = `<a href="/user/` + url.PathEscape(username) + `" class="mention">@` + html.EscapeString(username) + "</a>"
+		}
+	}
+	ip.add(pc)
+	ip.mentions = append(ip.mentions, pc)
+	ip.pos += len(m[0])
+	return true
+}
+
+func isWordByte(c byte) bool {
+	return c == '_' || c == '-' || ('0' <= c && c <= '9') || ('a' <= c && c <= 'z') || ('A' <= c && c <= 'Z')
+}
@@ -0,0 +1,257 @@
+package markdown
+
+import (
+	"html"
+	"regexp"
+	"strings"
+)
+
+// allowedTags maps each tag Sanitize keeps to the attributes it may carry
+var allowedTags = map[string][]string{
+	"a":          {"href", "title", "class"},
+	"b":          nil,
+	"blockquote": nil,
+	"br":         nil,
+	"code":       {"class"},
+	"del":        nil,
+	"div":        {"class", "data-lang"},
+	"em":         nil,
+	"h1":         nil,
+	"h2":         nil,
+	"h3":         nil,
+	"h4":         nil,
+	"h5":         nil,
+	"h6":         nil,
+	"hr":         nil,
+	"i":          nil,
+	"img":        {"src", "alt", "title"},
+	"kbd":        nil,
+	"li":         nil,
+	"ol":         {"start"},
+	"p":          nil,
+	"pre":        {"class", "hidden"},
+	"s":          nil,
+	"span":       {"class"},
+	"strong":     nil,
+	"sub":        nil,
+	"sup":        nil,
+	"table":      nil,
+	"tbody":      nil,
+	"td":         {"align"},
+	"th":         {"align"},
+	"thead":      nil,
+	"tr":         nil,
+	"ul":         nil,
+}
+
+// voidTags have no content or end tag
+var voidTags = map[string]bool{"br": true, "hr": true, "img": true}
+
+// attributeValues restricts the values of attributes that aren't free text
+var attributeValues = map[string]*regexp.Regexp{
+	"align":     regexp.MustCompile(`^(?:left|center|right)$`),
+	"class":     regexp.MustCompile(`^(?:language-[A-Za-z0-9_+#.-]{1,32}|hl-[a-z]+|code-block|highlight|code-raw|mention)$`),
+	"data-lang": regexp.MustCompile(`^[A-Za-z0-9_+#.-]{1,32}$`),
+	"hidden":    regexp.MustCompile(`^(?:hidden)?$`),
+	"start":     regexp.MustCompile(`^[0-9]{1,9}$`),
+}
+
+// urlSchemes are the schemes links and images may use; relative URLs are
+// always allowed
+var urlSchemes = map[string][]string{
+	"href": {"http", "https", "mailto"},
+	"src":  {"http", "https"},
+}
+
+// rendererOnly are the tags and attributes of the highlighted code block
+// structure, which raw HTML in content may not use: copy buttons trust it
+var rendererOnly = map[string]bool{"div": true, "hidden": true, "data-lang": true}
+
+// rawClass restricts the classes raw HTML in content may use
+var rawClass = regexp.MustCompile(`^language-[A-Za-z0-9_+#.-]{1,32}$`)
+
+var (
+	tagStart    = regexp.MustCompile(`^<(/?)([A-Za-z][A-Za-z0-9-]*)`)
+	attribute   = regexp.MustCompile(`^\s+([^\s"'>/=]+)(?:\s*=\s*(?:"([^"]*)"|'([^']*)'|([^\s"'=<>` + "`" + `]+)))?`)
+	tagEnd      = regexp.MustCompile(`^\s*(/?)>`)
+	commentText = regexp.MustCompile(`^<!--[\s\S]*?-->`)
+)
+
+// Sanitize keeps the tags and attributes in the allowlist and escapes any
+// other markup as text. Links get rel="nofollow ugc", URLs with a scheme
+// other than http, https or mailto are dropped, and unclosed tags are
+// closed at the end.
+func Sanitize(input string) string {
+	var out strings.Builder
+	var open []string
+
+	for i := 0; i < len(input); {
+		lt := strings.IndexByte(input[i:], '<')
+		if lt < 0 {
+			out.WriteString(escapeText(input[i:]))
+			break
+		}
+		out.WriteString(escapeText(input[i : i+lt]))
+		i += lt
+
+		if m := commentText.FindString(input[i:]); m != "" {
+			i += len(m)
+			continue
+		}
+		tag, closing, attrs, length, ok := parseTag(input[i:])
+		if !ok {
+			out.WriteString("&lt;")
+			i++
+			continue
+		}
+		allowed, known := allowedTags[tag]
+		if !known {
+			out.WriteString(escapeText(input[i : i+length]))
+			i += length
+			continue
+		}
+		i += length
+
+		if closing {
+			// Close the element and any left open inside it; a stray end
+			// tag is dropped
+			for n := len(open) - 1; n >= 0; n-- {
+				if open[n] == tag {
+					for _, t := range reverse(open[n:]) {
+						out.WriteString("</" + t + ">")
+					}
+					open = open[:n]
+					break
+				}
+			}
+			continue
+		}
+
+		out.WriteString("<" + tag)
+		for _, name := range allowed {
+			value, ok := attrs[name]
+			if !ok || !allowedValue(name, value) {
+				continue
+			}
+			out.WriteString(" " + name + `="` + html.EscapeString(value) + `"`)
+		}
+		if tag == "a" {
+			out.WriteString(` rel="nofollow ugc"`)
+		}
+		if voidTags[tag] {
+			out.WriteString(" />")
+			continue
+		}
+		out.WriteString(">")
+		open = append(open, tag)
+	}
+
+	for _, t := range reverse(open) {
+		out.WriteString("</" + t + ">")
+	}
+	return out.String()
+}
+
+// sanitizeRawTag filters one tag of raw HTML written in content. Tags and
+// attributes only the renderer may produce are escaped or dropped, and
+// classes are limited to code languages, so content can't forge a
+// highlighted code block. Comments are left for Sanitize to drop.
+func sanitizeRawTag(raw string) string {
+	if commentText.MatchString(raw) {
+		return raw
+	}
+	tag, closing, attrs, length, ok := parseTag(raw)
+	allowed, known := allowedTags[tag]
+	if !ok || length != len(raw) || !known || rendererOnly[tag] {
+		return escapeText(raw)
+	}
+	if closing {
+		return "</" + tag + ">"
+	}
+
+	var out strings.Builder
+	out.WriteString("<" + tag)
+	for _, name := range allowed {
+		value, ok := attrs[name]
+		if !ok || rendererOnly[name] || !allowedValue(name, value) || (name == "class" && !rawClass.MatchString(value)) {
+			continue
+		}
+		out.WriteString(" " + name + `="` + html.EscapeString(value) + `"`)
+	}
+	if voidTags[tag] {
+		out.WriteString(" />")
+	} else {
+		out.WriteString(">")
+	}
+	return out.String()
+}
+
+// parseTag reads a start or end tag at the start of s, returning the
+// lowercased name, its attributes with entities decoded, and its length
+func parseTag(s string) (tag string, closing bool, attrs map[string]string, length int, ok bool) {
+	m := tagStart.FindStringSubmatch(s)
+	if m == nil {
+		return "", false, nil, 0, false
+	}
+	closing, tag, length = m[1] == "/", strings.ToLower(m[2]), len(m[0])
+	attrs = map[string]string{}
+	for {
+		if end := tagEnd.FindString(s[length:]); end != "" {
+			return tag, closing, attrs, length + len(end), true
+		}
+		a := attribute.FindStringSubmatch(s[length:])
+		if a == nil {
+			return "", false, nil, 0, false
+		}
+		name := strings.ToLower(a[1])
+		if _, seen := attrs[name]; !seen {
+			attrs[name] = html.UnescapeString(a[2] + a[3] + a[4])
+		}
+		length += len(a[0])
+	}
+}
+
+func allowedValue(name, value string) bool {
+	if pattern, ok := attributeValues[name]; ok {
+		return pattern.MatchString(value)
+	}
+	if schemes, ok := urlSchemes[name]; ok {
+		return allowedURL(value, schemes)
+	}
+	return true
+}
+
+// allowedURL reports whether a URL is relative or uses one of schemes
+func allowedURL(url string, schemes []string) bool {
+	// Browsers ignore control characters and whitespace in schemes
+	url = strings.Map(func(r rune) rune {
+		if r <= ' ' || r == 0x7f {
+			return -1
+		}
+		return r
+	}, url)
+	colon := strings.IndexByte(url, ':')
+	if colon < 0 || strings.ContainsAny(url[:colon], "/?#") {
+		return true
+	}
+	scheme := strings.ToLower(url[:colon])
+	for _, s := range schemes {
+		if scheme == s {
+			return true
+		}
+	}
+	return false
+}
+
+// escapeText re-escapes text so no stray < or > survives, keeping entities
+func escapeText(s string) string {
+	return html.EscapeString(html.UnescapeString(s))
+}
+
+func reverse(tags []string) []string {
+	reversed := make([]string, len(tags))
+	for i, t := range tags {
+		reversed[len(tags)-1-i] = t
+	}
+	return reversed
+}
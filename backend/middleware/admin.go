@@ -0,0 +1,45 @@
+package middleware
+
+import (
+	"database/sql"
+	"log"
+	"net/http"
+	"slices"
+
+	"forum/models"
+	"forum/sqlite"
+	"forum/utils"
+)
+
+// AdminMiddleware lets only administrators through. It must run inside one
+// of the auth middlewares, which put the user in the request context.
+func AdminMiddleware(db *sql.DB, next http.Handler) http.Handler {
+	return requireRole(db, "Administrator access required", next, models.RoleAdmin)
+}
+
+// ModeratorMiddleware lets moderators and administrators through, like
+// AdminMiddleware
+func ModeratorMiddleware(db *sql.DB, next http.Handler) http.Handler {
+	return requireRole(db, "Moderator access required", next, models.RoleAdmin, models.RoleModerator)
+}
+
+func requireRole(db *sql.DB, message string, next http.Handler, roles ...string) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		userID, ok := GetUserID(r)
+		if !ok {
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+		role, err := sqlite.GetUserRole(db, userID)
+		if err != nil && err != sql.ErrNoRows {
+			log.Printf("❌ Role lookup failed: %v", err)
+			utils.SendJSONError(w, "Database error", http.StatusInternalServerError)
+			return
+		}
+		if !slices.Contains(roles, role) {
+			utils.SendJSONError(w, message, http.StatusForbidden)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
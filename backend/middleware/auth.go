@@ -3,8 +3,12 @@ package middleware
 import (
 	"context"
 	"database/sql"
+	"fmt"
+	"log"
 	"net/http"
 
+	"forum/models"
+	"forum/sqlite"
 	"forum/utils"
 )
 
@@ -12,9 +16,60 @@ type contextKey string
 
 const userIDKey contextKey = "userID"
 
-// AuthMiddleware checks if a user is logged in
+// AuthMiddleware checks if a user is logged in. API tokens are accepted for
+// GET requests when they hold the read scope.
 func AuthMiddleware(db *sql.DB, next http.Handler) http.Handler {
+	return authenticate(db, "", true, next)
+}
+
+// ScopedAuthMiddleware is AuthMiddleware for routes scripts may also change
+// data through, with an API token holding scope
+func ScopedAuthMiddleware(db *sql.DB, scope string, next http.Handler) http.Handler {
+	return authenticate(db, scope, true, next)
+}
+
+// SessionAuthMiddleware is AuthMiddleware for account settings, which API
+// tokens must never reach
+func SessionAuthMiddleware(db *sql.DB, next http.Handler) http.Handler {
+	return authenticate(db, "", false, next)
+}
+
+func authenticate(db *sql.DB, scope string, allowTokens bool, next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if bearer, ok := utils.BearerToken(r); ok {
+			token, err := sqlite.GetAPIToken(db, bearer)
+			if err != nil {
+				if err != sql.ErrNoRows {
+					log.Printf("❌ API token lookup failed: %v", err)
+				}
+				w.Header().Set("WWW-Authenticate", `Bearer error="invalid_token"`)
+				utils.SendJSONError(w, "Invalid or expired API token", http.StatusUnauthorized)
+				return
+			}
+
+			required := scope
+			if required == "" && (r.Method == http.MethodGet || r.Method == http.MethodHead) {
+				required = models.ScopeRead
+			}
+			if !allowTokens || required == "" {
+				utils.SendJSONError(w, "API tokens cannot be used for this endpoint", http.StatusForbidden)
+				return
+			}
+			if !token.HasScope(required) {
+				w.Header().Set("WWW-Authenticate", fmt.Sprintf(`Bearer error="insufficient_scope", scope=%q`, required))
+				utils.SendJSONError(w, "API token is missing the "+required+" scope", http.StatusForbidden)
+				return
+			}
+
+			if err := sqlite.TouchAPIToken(db, token.ID); err != nil {
+				log.Printf("⚠️  Failed to record API token use: %v", err)
+			}
+			r = utils.WithAPIToken(r, token)
+			ctx := context.WithValue(r.Context(), userIDKey, token.UserID)
+			next.ServeHTTP(w, r.WithContext(ctx))
+			return
+		}
+
 		userID, err := utils.GetUserIDFromSession(db, r)
 		if err != nil || userID == "" {
 			http.Error(w, "Unauthorized", http.StatusUnauthorized)
@@ -0,0 +1,121 @@
+package middleware
+
+import (
+	"database/sql"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"forum/models"
+	"forum/sqlite"
+	"forum/utils"
+
+	_ "github.com/mattn/go-sqlite3"
+)
+
+func TestAuthMiddlewareAPITokens(t *testing.T) {
+	db, err := sql.Open("sqlite3", ":memory:")
+	if err != nil {
+		t.Fatalf("Failed to create test database: %v", err)
+	}
+	defer db.Close()
+	db.Exec(`CREATE TABLE sessions (id TEXT PRIMARY KEY, user_id TEXT NOT NULL, created_at DATETIME DEFAULT CURRENT_TIMESTAMP)`)
+	db.Exec(`INSERT INTO sessions (id, user_id) VALUES ('sess-1', 'user-1')`)
+	_, err = db.Exec(`CREATE TABLE api_tokens (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		user_id TEXT NOT NULL,
+		name TEXT NOT NULL,
+		token_hash TEXT NOT NULL UNIQUE,
+		prefix TEXT NOT NULL,
+		scopes TEXT NOT NULL,
+		expires_at DATETIME NOT NULL,
+		last_used_at DATETIME,
+		created_at DATETIME DEFAULT CURRENT_TIMESTAMP
+	)`)
+	if err != nil {
+		t.Fatalf("Failed to create api_tokens table: %v", err)
+	}
+
+	readOnly, _, _ := sqlite.CreateAPIToken(db, "user-1", "reader", []string{models.ScopeRead}, time.Now().Add(time.Hour))
+	poster, stored, _ := sqlite.CreateAPIToken(db, "user-1", "bot", []string{models.ScopeWritePosts}, time.Now().Add(time.Hour))
+	expired, _, _ := sqlite.CreateAPIToken(db, "user-1", "old", models.APIScopes, time.Now().Add(-time.Minute))
+
+	var gotUser string
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotUser, _ = utils.GetUserIDFromSession(db, r)
+		w.WriteHeader(http.StatusOK)
+	})
+	handlers := map[string]http.Handler{
+		"default": AuthMiddleware(db, next),
+		"posts":   ScopedAuthMiddleware(db, models.ScopeWritePosts, next),
+		"session": SessionAuthMiddleware(db, next),
+	}
+
+	tests := []struct {
+		name     string
+		handler  string
+		method   string
+		token    string
+		session  string
+		expected int
+	}{
+		{"session cookie", "default", http.MethodPost, "", "sess-1", http.StatusOK},
+		{"no credentials", "default", http.MethodGet, "", "", http.StatusUnauthorized},
+		{"read token on GET", "default", http.MethodGet, readOnly, "", http.StatusOK},
+		{"token without read scope on GET", "default", http.MethodGet, poster, "", http.StatusForbidden},
+		{"token on unscoped POST", "default", http.MethodPost, poster, "", http.StatusForbidden},
+		{"token with the route scope", "posts", http.MethodPost, poster, "", http.StatusOK},
+		{"token without the route scope", "posts", http.MethodPost, readOnly, "", http.StatusForbidden},
+		{"token on session-only route", "session", http.MethodGet, readOnly, "", http.StatusForbidden},
+		{"expired token", "default", http.MethodGet, expired, "", http.StatusUnauthorized},
+		{"unknown token", "default", http.MethodGet, sqlite.APITokenPrefix + "nope", "", http.StatusUnauthorized},
+		{"bad token does not fall back to the cookie", "default", http.MethodGet, "nope", "sess-1", http.StatusUnauthorized},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			gotUser = ""
+			r := httptest.NewRequest(tt.method, "/api/test", nil)
+			if tt.token != "" {
+				r.Header.Set("Authorization", "Bearer "+tt.token)
+			}
+			if tt.session != "" {
+				r.AddCookie(&http.Cookie{Name: utils.SessionCookieName, Value: tt.session})
+			}
+			w := httptest.NewRecorder()
+			handlers[tt.handler].ServeHTTP(w, r)
+			if w.Code != tt.expected {
+				t.Fatalf("Expected status %d, got %d: %s", tt.expected, w.Code, w.Body.String())
+			}
+			if tt.expected == http.StatusOK && gotUser != "user-1" {
+				t.Errorf("Expected handler to see user-1, got %q", gotUser)
+			}
+		})
+	}
+
+	t.Run("last use is recorded", func(t *testing.T) {
+		tokens, err := sqlite.GetAPITokens(db, "user-1")
+		if err != nil {
+			t.Fatalf("GetAPITokens failed: %v", err)
+		}
+		for _, token := range tokens {
+			if token.ID == stored.ID && token.LastUsedAt == nil {
+				t.Error("Expected last_used_at to be set")
+			}
+		}
+	})
+
+	t.Run("revoked token is rejected", func(t *testing.T) {
+		if err := sqlite.RevokeAPIToken(db, "user-1", stored.ID); err != nil {
+			t.Fatalf("RevokeAPIToken failed: %v", err)
+		}
+		r := httptest.NewRequest(http.MethodPost, "/api/posts/create", nil)
+		r.Header.Set("Authorization", "Bearer "+poster)
+		w := httptest.NewRecorder()
+		handlers["posts"].ServeHTTP(w, r)
+		if w.Code != http.StatusUnauthorized {
+			t.Errorf("Expected 401, got %d", w.Code)
+		}
+	})
+}
@@ -5,33 +5,39 @@ import (
 	"os"
 )
 
-// CORS Middleware
-func CORS(next http.Handler) http.Handler {
+// frontendOrigin is the origin the SPA is served from
+func frontendOrigin() string {
 	allowedOrigin := os.Getenv("FRONTEND_ORIGIN")
 	if allowedOrigin == "" {
 		allowedOrigin = "http://localhost:8000" // fallback default
 	}
+	return allowedOrigin
+}
+
+// isAllowedOrigin reports whether origin may make credentialed requests
+func isAllowedOrigin(origin string) bool {
+	// For development, allow localhost variations
+	return origin == frontendOrigin() || origin == "http://localhost:8000" || origin == "http://127.0.0.1:8000"
+}
+
+// CORS Middleware
+func CORS(next http.Handler) http.Handler {
+	allowedOrigin := frontendOrigin()
 
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		// For Docker deployment, requests come through nginx proxy
 		// Check if request is from internal Docker network
 		origin := r.Header.Get("Origin")
-		if origin == "" {
-			// No origin header means same-origin request (likely from nginx proxy)
-			w.Header().Set("Access-Control-Allow-Origin", allowedOrigin)
-		} else if origin == allowedOrigin {
+		if origin != "" && isAllowedOrigin(origin) {
 			w.Header().Set("Access-Control-Allow-Origin", origin)
 		} else {
-			// For development, allow localhost variations
-			if origin == "http://localhost:8000" || origin == "http://127.0.0.1:8000" {
-				w.Header().Set("Access-Control-Allow-Origin", origin)
-			} else {
-				w.Header().Set("Access-Control-Allow-Origin", allowedOrigin)
-			}
+			// No origin header means same-origin request (likely from nginx proxy)
+			w.Header().Set("Access-Control-Allow-Origin", allowedOrigin)
 		}
+		w.Header().Add("Vary", "Origin")
 
 		w.Header().Set("Access-Control-Allow-Methods", "GET, POST, PUT, DELETE, OPTIONS")
-		w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization")
+		w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization, "+CSRFHeaderName)
 		w.Header().Set("Access-Control-Allow-Credentials", "true")
 
 		if r.Method == "OPTIONS" {
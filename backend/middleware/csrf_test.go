@@ -0,0 +1,60 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestCSRF(t *testing.T) {
+	handler := CSRF(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	sessionID := "session-123"
+	validToken := CSRFToken(sessionID)
+
+	tests := []struct {
+		name     string
+		method   string
+		origin   string
+		referer  string
+		session  string
+		token    string
+		expected int
+	}{
+		{"GET is never checked", http.MethodGet, "https://evil.example", "", sessionID, "", http.StatusOK},
+		{"valid token from frontend", http.MethodPost, "http://localhost:8000", "", sessionID, validToken, http.StatusOK},
+		{"same host origin", http.MethodDelete, "http://backend.test", "", sessionID, validToken, http.StatusOK},
+		{"missing token", http.MethodPost, "http://localhost:8000", "", sessionID, "", http.StatusForbidden},
+		{"token for another session", http.MethodPut, "http://localhost:8000", "", sessionID, CSRFToken("other"), http.StatusForbidden},
+		{"cross-site origin", http.MethodPost, "https://evil.example", "", sessionID, validToken, http.StatusForbidden},
+		{"cross-site referer", http.MethodPost, "", "https://evil.example/page", "", "", http.StatusForbidden},
+		{"anonymous login from frontend", http.MethodPost, "http://localhost:8000", "", "", "", http.StatusOK},
+		{"non-browser client without session", http.MethodPost, "", "", "", "", http.StatusOK},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(tt.method, "http://backend.test/api/posts/delete", nil)
+			if tt.origin != "" {
+				r.Header.Set("Origin", tt.origin)
+			}
+			if tt.referer != "" {
+				r.Header.Set("Referer", tt.referer)
+			}
+			if tt.session != "" {
+				r.AddCookie(&http.Cookie{Name: "session_id", Value: tt.session})
+			}
+			if tt.token != "" {
+				r.Header.Set(CSRFHeaderName, tt.token)
+			}
+
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, r)
+			if w.Code != tt.expected {
+				t.Errorf("Expected status %d, got %d", tt.expected, w.Code)
+			}
+		})
+	}
+}
@@ -0,0 +1,137 @@
+package middleware
+
+import (
+	"container/list"
+	"database/sql"
+	"math"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"forum/sqlite"
+	"forum/utils"
+)
+
+// RateLimit describes a token bucket: Burst requests at once, refilled at PerMinute
+type RateLimit struct {
+	PerMinute float64
+	Burst     int
+}
+
+// RateLimits holds the limits for every rate limited route group
+var RateLimits = map[string]RateLimit{
+	"login":     {PerMinute: 5, Burst: 5},
+	"register":  {PerMinute: 2, Burst: 3},
+	"posts":     {PerMinute: 6, Burst: 5},
+	"comments":  {PerMinute: 20, Burst: 10},
+	"reactions": {PerMinute: 60, Burst: 30},
+}
+
+// maxTrackedClients bounds the number of buckets kept per route group;
+// the least recently seen client is evicted first
+const maxTrackedClients = 10000
+
+type bucket struct {
+	key    string
+	tokens float64
+	last   time.Time
+}
+
+// Limiter is a set of token buckets keyed by client, with LRU eviction
+type Limiter struct {
+	limit      RateLimit
+	maxClients int
+	now        func() time.Time
+
+	mu      sync.Mutex
+	buckets map[string]*list.Element
+	order   *list.List // front = most recently used
+}
+
+// NewLimiter creates a limiter that tracks at most maxClients buckets
+func NewLimiter(limit RateLimit, maxClients int) *Limiter {
+	return &Limiter{
+		limit:      limit,
+		maxClients: maxClients,
+		now:        time.Now,
+		buckets:    make(map[string]*list.Element),
+		order:      list.New(),
+	}
+}
+
+// Allow takes a token from key's bucket. When the bucket is empty it
+// returns false and how long until the next token is available.
+func (l *Limiter) Allow(key string) (bool, time.Duration) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := l.now()
+	perSecond := l.limit.PerMinute / 60
+
+	var b *bucket
+	if el, ok := l.buckets[key]; ok {
+		l.order.MoveToFront(el)
+		b = el.Value.(*bucket)
+		elapsed := now.Sub(b.last).Seconds()
+		b.tokens = math.Min(float64(l.limit.Burst), b.tokens+elapsed*perSecond)
+		b.last = now
+	} else {
+		b = &bucket{key: key, tokens: float64(l.limit.Burst), last: now}
+		l.buckets[key] = l.order.PushFront(b)
+		if l.order.Len() > l.maxClients {
+			oldest := l.order.Back()
+			l.order.Remove(oldest)
+			delete(l.buckets, oldest.Value.(*bucket).key)
+		}
+	}
+
+	if b.tokens >= 1 {
+		b.tokens--
+		return true, 0
+	}
+	wait := time.Duration((1 - b.tokens) / perSecond * float64(time.Second))
+	return false, wait
+}
+
+// Len returns the number of clients currently tracked
+func (l *Limiter) Len() int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.order.Len()
+}
+
+// RateLimitMiddleware limits requests to next using the named group from RateLimits.
+// Clients are identified by user ID when logged in (or using an API token)
+// and by IP otherwise.
+func RateLimitMiddleware(db *sql.DB, group string, next http.Handler) http.Handler {
+	limit, ok := RateLimits[group]
+	if !ok {
+		panic("middleware: unknown rate limit group " + group)
+	}
+	limiter := NewLimiter(limit, maxTrackedClients)
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodOptions {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		key := "ip:" + ClientIP(r)
+		if bearer, ok := utils.BearerToken(r); ok {
+			if token, err := sqlite.GetAPIToken(db, bearer); err == nil {
+				key = "user:" + token.UserID
+			}
+		} else if userID, err := utils.GetUserIDFromSession(db, r); err == nil && userID != "" {
+			key = "user:" + userID
+		}
+
+		allowed, wait := limiter.Allow(key)
+		if !allowed {
+			w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(wait.Seconds()))))
+			utils.SendJSONError(w, "Too many requests, please slow down", http.StatusTooManyRequests)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,148 @@
+package middleware
+
+import (
+	"database/sql"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	_ "github.com/mattn/go-sqlite3"
+)
+
+func TestLimiter(t *testing.T) {
+	now := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	limiter := NewLimiter(RateLimit{PerMinute: 60, Burst: 2}, 100)
+	limiter.now = func() time.Time { return now }
+
+	t.Run("burst then reject", func(t *testing.T) {
+		for i := 0; i < 2; i++ {
+			if ok, _ := limiter.Allow("a"); !ok {
+				t.Fatalf("Request %d should be allowed", i+1)
+			}
+		}
+		ok, wait := limiter.Allow("a")
+		if ok {
+			t.Fatal("Third request should be rejected")
+		}
+		if wait != time.Second {
+			t.Errorf("Expected 1s wait, got %v", wait)
+		}
+	})
+
+	t.Run("keys are independent", func(t *testing.T) {
+		if ok, _ := limiter.Allow("b"); !ok {
+			t.Error("Another client should not be limited")
+		}
+	})
+
+	t.Run("refills over time", func(t *testing.T) {
+		now = now.Add(1500 * time.Millisecond)
+		if ok, _ := limiter.Allow("a"); !ok {
+			t.Error("Expected a token after refill")
+		}
+		if ok, _ := limiter.Allow("a"); ok {
+			t.Error("Only one token should have been refilled")
+		}
+	})
+}
+
+func TestLimiterBoundedMemory(t *testing.T) {
+	limiter := NewLimiter(RateLimit{PerMinute: 1, Burst: 1}, 3)
+
+	limiter.Allow("a")
+	limiter.Allow("b")
+	limiter.Allow("c")
+	limiter.Allow("a") // a becomes most recently used
+	limiter.Allow("d") // evicts b
+
+	if limiter.Len() != 3 {
+		t.Fatalf("Expected 3 tracked clients, got %d", limiter.Len())
+	}
+	if ok, _ := limiter.Allow("b"); !ok {
+		t.Error("Evicted client should start with a full bucket")
+	}
+	if ok, _ := limiter.Allow("a"); ok {
+		t.Error("Recently used client should not have been evicted")
+	}
+}
+
+func TestClientIP(t *testing.T) {
+	original := trustedProxies
+	defer func() { trustedProxies = original }()
+	trustedProxies = parseTrustedProxies("172.16.0.0/12, 10.0.0.1")
+
+	tests := []struct {
+		name       string
+		remoteAddr string
+		forwarded  string
+		expected   string
+	}{
+		{"direct client", "203.0.113.5:1234", "", "203.0.113.5"},
+		{"untrusted peer cannot spoof", "203.0.113.5:1234", "1.2.3.4", "203.0.113.5"},
+		{"trusted proxy", "172.18.0.3:5555", "198.51.100.7", "198.51.100.7"},
+		{"spoofed prefix is ignored", "172.18.0.3:5555", "1.2.3.4, 198.51.100.7", "198.51.100.7"},
+		{"chain of trusted proxies", "172.18.0.3:5555", "198.51.100.7, 10.0.0.1", "198.51.100.7"},
+		{"trusted proxy without header", "172.18.0.3:5555", "", "172.18.0.3"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, "/", nil)
+			r.RemoteAddr = tt.remoteAddr
+			if tt.forwarded != "" {
+				r.Header.Set("X-Forwarded-For", tt.forwarded)
+			}
+			if got := ClientIP(r); got != tt.expected {
+				t.Errorf("Expected %s, got %s", tt.expected, got)
+			}
+		})
+	}
+}
+
+func TestRateLimitMiddleware(t *testing.T) {
+	db, err := sql.Open("sqlite3", ":memory:")
+	if err != nil {
+		t.Fatalf("Failed to create test database: %v", err)
+	}
+	defer db.Close()
+	db.Exec(`CREATE TABLE sessions (id TEXT PRIMARY KEY, user_id TEXT NOT NULL, created_at DATETIME DEFAULT CURRENT_TIMESTAMP)`)
+	db.Exec(`INSERT INTO sessions (id, user_id) VALUES ('sess-1', 'user-1')`)
+
+	RateLimits["test"] = RateLimit{PerMinute: 1, Burst: 1}
+	defer delete(RateLimits, "test")
+
+	handler := RateLimitMiddleware(db, "test", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	request := func(remoteAddr, session string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(http.MethodPost, "/api/login", nil)
+		r.RemoteAddr = remoteAddr
+		if session != "" {
+			r.AddCookie(&http.Cookie{Name: "session_id", Value: session})
+		}
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, r)
+		return w
+	}
+
+	if w := request("203.0.113.5:1", ""); w.Code != http.StatusOK {
+		t.Fatalf("First request should pass, got %d", w.Code)
+	}
+	w := request("203.0.113.5:2", "")
+	if w.Code != http.StatusTooManyRequests {
+		t.Fatalf("Expected 429, got %d", w.Code)
+	}
+	if w.Header().Get("Retry-After") != "60" {
+		t.Errorf("Expected Retry-After 60, got %q", w.Header().Get("Retry-After"))
+	}
+
+	// Logged in users get their own bucket, independent of their IP
+	if w := request("203.0.113.5:3", "sess-1"); w.Code != http.StatusOK {
+		t.Errorf("Authenticated request should use the user bucket, got %d", w.Code)
+	}
+	if w := request("198.51.100.1:1", "sess-1"); w.Code != http.StatusTooManyRequests {
+		t.Errorf("User bucket should follow the user across IPs, got %d", w.Code)
+	}
+}
@@ -0,0 +1,35 @@
+package models
+
+import "time"
+
+// API token scopes
+const (
+	ScopeRead          = "read"
+	ScopeWritePosts    = "write:posts"
+	ScopeWriteComments = "write:comments"
+)
+
+// APIScopes lists every scope a token can be granted
+var APIScopes = []string{ScopeRead, ScopeWritePosts, ScopeWriteComments}
+
+// APIToken is a personal access token used by scripts instead of a session
+type APIToken struct {
+	ID         int64      `json:"id"`
+	UserID     string     `json:"-"`
+	Name       string     `json:"name"`
+	Prefix     string     `json:"prefix"`
+	Scopes     []string   `json:"scopes"`
+	ExpiresAt  time.Time  `json:"expires_at"`
+	LastUsedAt *time.Time `json:"last_used_at"`
+	CreatedAt  time.Time  `json:"created_at"`
+}
+
+// HasScope reports whether the token was granted scope
+func (t APIToken) HasScope(scope string) bool {
+	for _, s := range t.Scopes {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,23 @@
+package models
+
+import "time"
+
+// Badge is a badge users can earn, with how many hold it or, in a user's
+// badges, when they earned it
+type Badge struct {
+	ID          string     `json:"id"`
+	Name        string     `json:"name"`
+	Description string     `json:"description"`
+	Metric      string     `json:"metric"`
+	Threshold   int        `json:"threshold"`
+	Holders     int        `json:"holders"`
+	AwardedAt   *time.Time `json:"awarded_at,omitempty"`
+}
+
+// BadgeAward is a badge a user earned
+type BadgeAward struct {
+	UserID    string    `json:"user_id"`
+	Username  string    `json:"username"`
+	BadgeID   string    `json:"badge_id"`
+	AwardedAt time.Time `json:"awarded_at"`
+}
@@ -0,0 +1,25 @@
+package models
+
+import "time"
+
+// Bookmark is a post or comment a user saved for later, with what it
+// bookmarks as it is now
+type Bookmark struct {
+	ID          int       `json:"id"`
+	ContentType string    `json:"content_type"` // post or comment
+	PostID      int       `json:"post_id"`      // the post itself, or the one commented on
+	CommentID   *int      `json:"comment_id,omitempty"`
+	Folder      string    `json:"folder"` // "" when not in a folder
+	Note        string    `json:"note"`
+	CreatedAt   time.Time `json:"created_at"`
+
+	PostTitle string `json:"post_title"`
+	Username  string `json:"username"` // the content's author
+	Content   string `json:"content"`  // Markdown as written
+}
+
+// BookmarkFolder is a folder a user filed bookmarks in
+type BookmarkFolder struct {
+	Name  string `json:"name"`
+	Count int    `json:"count"`
+}
@@ -8,10 +8,14 @@ type Comment struct {
 	UserName      string         `json:"username"`
 	ProfileAvatar string         `json:"avatar_url"`
 	PostID        int            `json:"post_id,omitempty"`
-	Content       string         `json:"content" validate:"required" gorm:"not null"`
+	Content       string         `json:"content" validate:"required" gorm:"not null"` // Markdown as written
+	ContentHTML   string         `json:"content_html" gorm:"-"`                       // Content rendered and sanitized
 	CreatedAt     time.Time      `json:"created_at" gorm:"autoCreateTime"`
 	UpdatedAt     time.Time      `json:"updated_at" gorm:"autoUpdateTime"`
 	Replies       []ReplyComment `json:"replies,omitempty" gorm:"-"`
+	Status        string         `json:"status,omitempty"`
+	Accepted      bool           `json:"accepted,omitempty" gorm:"-"` // the post's accepted answer
+	IsBookmarked  bool           `json:"is_bookmarked" gorm:"-"`      // by the viewer
 }
 
 type ReplyComment struct {
@@ -20,7 +24,10 @@ type ReplyComment struct {
 	UserName        string    `json:"username"`
 	ProfileAvatar   string    `json:"avatar_url"`
 	ParentCommentID int       `json:"parent_comment_id,omitempty"`
-	Content         string    `json:"content" validate:"required" gorm:"not null"`
+	Content         string    `json:"content" validate:"required" gorm:"not null"` // Markdown as written
+	ContentHTML     string    `json:"content_html" gorm:"-"`                       // Content rendered and sanitized
 	CreatedAt       time.Time `json:"created_at" gorm:"autoCreateTime"`
 	UpdatedAt       time.Time `json:"updated_at" gorm:"autoUpdateTime"`
+	Status          string    `json:"status,omitempty"`
+	Accepted        bool      `json:"accepted,omitempty" gorm:"-"` // the post's accepted answer
 }
@@ -0,0 +1,17 @@
+package models
+
+import "time"
+
+// FollowUser is a user in a list of followers or followed users
+type FollowUser struct {
+	Username   string    `json:"username"`
+	AvatarURL  string    `json:"avatar_url"`
+	FollowedAt time.Time `json:"followed_at"`
+}
+
+// HiddenUser is a user in a list of blocked or muted users
+type HiddenUser struct {
+	Username  string    `json:"username"`
+	AvatarURL string    `json:"avatar_url"`
+	CreatedAt time.Time `json:"created_at"`
+}
@@ -0,0 +1,24 @@
+package models
+
+import "time"
+
+// Invite is a registration invite code
+type Invite struct {
+	Code      string    `json:"code"`
+	CreatedBy string    `json:"created_by"`
+	Creator   string    `json:"creator"` // username of CreatedBy
+	MaxUses   int       `json:"max_uses"`
+	Uses      int       `json:"uses"`
+	ExpiresAt time.Time `json:"expires_at"`
+	CreatedAt time.Time `json:"created_at"`
+	Invitees  []string  `json:"invitees"` // usernames of the users who signed up with it
+}
+
+// PendingUser is a registration waiting for an administrator's approval
+type PendingUser struct {
+	ID        string    `json:"id"`
+	Username  string    `json:"username"`
+	Email     string    `json:"email"`
+	InvitedBy string    `json:"invited_by"` // inviter's username, if any
+	CreatedAt time.Time `json:"created_at"`
+}
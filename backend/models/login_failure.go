@@ -0,0 +1,11 @@
+package models
+
+import "time"
+
+// LoginFailure tracks failed login attempts for an account or client IP
+type LoginFailure struct {
+	Subject       string
+	Failures      int
+	LastFailureAt time.Time
+	LockedUntil   time.Time
+}
@@ -0,0 +1,18 @@
+package models
+
+// Mention is a user @mentioned in a post, comment or reply
+type Mention struct {
+	ContentType string `json:"content_type"`
+	ContentID   int    `json:"content_id"`
+	PostID      int    `json:"post_id"` // the post itself, or the one commented on
+	UserID      string `json:"user_id"` // who was mentioned
+	AuthorID    string `json:"author_id"`
+	AuthorName  string `json:"author_name"`
+}
+
+// UserSuggestion is a user offered while writing a mention
+type UserSuggestion struct {
+	ID        string `json:"id"`
+	Username  string `json:"username"`
+	AvatarURL string `json:"avatar_url"`
+}
@@ -0,0 +1,36 @@
+package models
+
+import "time"
+
+// Kinds of user content and their publication states
+const (
+	ContentPost      = "post"
+	ContentComment   = "comment"
+	ContentReply     = "reply"
+	ContentPublished = "published"
+	ContentPending   = "pending"
+)
+
+// Viewer is who content is read for. Pending content is visible only to its
+// author and to moderators; the zero Viewer is an anonymous visitor.
+type Viewer struct {
+	UserID    string
+	Moderator bool
+}
+
+// ModerationItem is a post, comment or reply held back for a moderator
+type ModerationItem struct {
+	ID          int64     `json:"id"`
+	ContentType string    `json:"content_type"`
+	ContentID   int       `json:"content_id"`
+	PostID      int       `json:"post_id"`    // the post itself, or the one commented on
+	PostTitle   string    `json:"post_title"` // for context
+	UserID      string    `json:"user_id"`
+	Username    string    `json:"username"`
+	Content     string    `json:"content"`
+	ContentHTML string    `json:"content_html"`
+	ImageURL    *string   `json:"image_url,omitempty"`
+	Reasons     []string  `json:"reasons"`
+	SpamScore   *float64  `json:"spam_score"`
+	CreatedAt   time.Time `json:"created_at"`
+}
@@ -0,0 +1,41 @@
+package models
+
+import "time"
+
+type Notification struct {
+	ID        int       `json:"id"`
+	UserID    string    `json:"user_id"`
+	Type      string    `json:"type"`
+	Message   string    `json:"message"`
+	Link      string    `json:"link,omitempty"`
+	IsRead    bool      `json:"is_read"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// NotificationPreference is whether a user gets a type of notification
+type NotificationPreference struct {
+	Type        string `json:"type"`
+	Description string `json:"description"`
+	Enabled     bool   `json:"enabled"`
+}
+
+// OptionalNotifications are the notification types users can turn off, as
+// they are by default. Security, account and moderation notices always
+// arrive.
+var OptionalNotifications = []NotificationPreference{
+	{Type: "mention", Description: "Someone mentioned you", Enabled: true},
+	{Type: "answer", Description: "Your answer was accepted", Enabled: true},
+	{Type: "badge", Description: "You earned a badge", Enabled: true},
+	{Type: "follow", Description: "Someone you follow published a post", Enabled: true},
+}
+
+// IsOptionalNotification reports whether users can turn a notification
+// type off
+func IsOptionalNotification(notificationType string) bool {
+	for _, p := range OptionalNotifications {
+		if p.Type == notificationType {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,21 @@
+package models
+
+import "time"
+
+// UserIdentity links an account at an OpenID Connect provider to a user
+type UserIdentity struct {
+	Provider  string    `json:"provider"`
+	Subject   string    `json:"-"`
+	UserID    string    `json:"-"`
+	Email     string    `json:"email"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// OIDCLogin is a login redirected to a provider and waiting for its callback
+type OIDCLogin struct {
+	State        string
+	Provider     string
+	Nonce        string
+	CodeVerifier string
+	ExpiresAt    time.Time
+}
@@ -0,0 +1,46 @@
+package models
+
+import "time"
+
+// Poll limits
+const (
+	MinPollOptions  = 2
+	MaxPollOptions  = 10
+	MaxPollQuestion = 200
+	MaxPollOption   = 100
+	MaxPollDuration = 365 * 24 * time.Hour
+	MinPollDuration = 5 * time.Minute
+)
+
+// Poll is a question attached to a post. Votes and Percent are left out of
+// its options while the results are hidden.
+type Poll struct {
+	ID            int          `json:"id"`
+	PostID        int          `json:"post_id"`
+	Question      string       `json:"question"`
+	Multiple      bool         `json:"multiple"`     // several options may be chosen
+	HideResults   bool         `json:"hide_results"` // until the poll closes
+	ClosesAt      time.Time    `json:"closes_at"`
+	Closed        bool         `json:"closed"`
+	ResultsHidden bool         `json:"results_hidden"`
+	Voters        int          `json:"voters"`
+	Options       []PollOption `json:"options"`
+	MyVotes       []int        `json:"my_votes"` // option IDs the viewer chose
+}
+
+// PollOption is one answer of a poll
+type PollOption struct {
+	ID      int      `json:"id"`
+	Label   string   `json:"label"`
+	Votes   *int     `json:"votes"`
+	Percent *float64 `json:"percent"` // of the voters
+}
+
+// NewPoll is a poll sent with a new post
+type NewPoll struct {
+	Question    string    `json:"question"`
+	Options     []string  `json:"options"`
+	Multiple    bool      `json:"multiple"`
+	HideResults bool      `json:"hide_results"`
+	ClosesAt    time.Time `json:"closes_at"`
+}
@@ -6,7 +6,8 @@ type Post struct {
 	ID            int       `json:"id" gorm:"primaryKey"`
 	ProfileAvatar string    `json:"avatar_url"`
 	Title         string    `json:"title" validate:"required" gorm:"not null"`
-	Content       string    `json:"content" validate:"required" gorm:"not null"`
+	Content       string    `json:"content" validate:"required" gorm:"not null"` // Markdown as written
+	ContentHTML   string    `json:"content_html" gorm:"-"`                       // Content rendered and sanitized
 	Username      string    `json:"username" gorm:"-"`
 	UserID        string    `json:"user_id" gorm:"not null"`
 	CategoryIDs   []int     `json:"category_ids" gorm:"-"`   // For multiple categories
@@ -14,4 +15,21 @@ type Post struct {
 	ImageURL      *string   `json:"image_url,omitempty"`
 	CreatedAt     time.Time `json:"created_at" gorm:"autoCreateTime"`
 	UpdatedAt     time.Time `json:"updated_at" gorm:"autoUpdateTime"`
+	Status        string    `json:"status,omitempty"` // published, or pending moderation
+	Poll          *Poll     `json:"poll,omitempty" gorm:"-"`
+
+	// The comment or reply accepted as the answer, if any
+	AcceptedCommentID *int `json:"accepted_comment_id,omitempty"`
+	AcceptedReplyID   *int `json:"accepted_reply_id,omitempty"`
+	Answered          bool `json:"answered" gorm:"-"`
+
+	IsBookmarked bool `json:"is_bookmarked" gorm:"-"` // by the viewer
+}
+
+// PostFilter narrows down a list of posts
+type PostFilter struct {
+	Unanswered bool   // only posts without an accepted answer
+	FollowedBy string // only posts by users or in categories this user follows
+	Cursor     bool   // sort by ID, newest first, so pages continue from BeforeID
+	BeforeID   int    // only posts with a lower ID, the last of the previous page
 }
@@ -0,0 +1,58 @@
+package models
+
+import "time"
+
+// Reasons users gain or lose reputation
+const (
+	ReputationAcceptedAnswer = "accepted_answer"
+	ReputationLike           = "like"
+	ReputationDislike        = "dislike"
+)
+
+// Points each reason is worth. Reactions to replies aren't possible, so
+// only posts and comments earn reaction reputation.
+const (
+	AcceptedAnswerPoints = 15
+	PostLikePoints       = 5
+	PostDislikePoints    = -2
+	CommentLikePoints    = 2
+	CommentDislikePoints = -1
+)
+
+// DailyReactionReputation caps the reputation a user can gain from likes in
+// a day (UTC). Losses and accepted answers aren't capped.
+const DailyReactionReputation = 100
+
+// ReactionPoints is what a like or dislike of a post or comment is worth to
+// its author
+func ReactionPoints(contentType, reaction string) int {
+	switch {
+	case contentType == ContentPost && reaction == ReputationLike:
+		return PostLikePoints
+	case contentType == ContentPost:
+		return PostDislikePoints
+	case reaction == ReputationLike:
+		return CommentLikePoints
+	default:
+		return CommentDislikePoints
+	}
+}
+
+// ReputationEvent is an entry of a user's reputation history. Points can be
+// below what the reason is worth when the daily cap was reached.
+type ReputationEvent struct {
+	Reason      string    `json:"reason"`
+	ContentType string    `json:"content_type"`
+	ContentID   int       `json:"content_id"`
+	PostID      int       `json:"post_id"` // the post itself, or the one commented on
+	Points      int       `json:"points"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+// LeaderboardEntry is a user's place on the reputation leaderboard
+type LeaderboardEntry struct {
+	Rank       int    `json:"rank"`
+	Username   string `json:"username"`
+	AvatarURL  string `json:"avatar_url"`
+	Reputation int    `json:"reputation"` // over the leaderboard's period
+}
@@ -0,0 +1,19 @@
+package models
+
+import "time"
+
+// TOTP holds a user's authenticator app enrollment
+type TOTP struct {
+	UserID       string
+	Secret       string
+	Enabled      bool
+	LastUsedStep int64
+}
+
+// PendingLogin is a login that passed the password check and still needs a second factor
+type PendingLogin struct {
+	Token     string
+	UserID    string
+	Attempts  int
+	ExpiresAt time.Time
+}
@@ -8,6 +8,40 @@ type User struct {
 	Email        string    `json:"email" gorm:"unique;not null"`
 	PasswordHash string    `json:"-" gorm:"not null"`
 	AvatarURL    string    `json:"avatar_url" gorm:"default:'/static/default-avatar.png'"` // ✅ New field
+	Role         string    `json:"role"`
+	Status       string    `json:"status"`
 	CreatedAt    time.Time `json:"created_at" gorm:"autoCreateTime"`
 	UpdatedAt    time.Time `json:"updated_at" gorm:"autoUpdateTime"`
 }
+
+// User roles and account statuses
+const (
+	RoleUser      = "user"
+	RoleModerator = "moderator"
+	RoleAdmin     = "admin"
+	StatusActive  = "active"
+	StatusPending = "pending"
+)
+
+// CanModerate reports whether a role may review held content
+func CanModerate(role string) bool {
+	return role == RoleModerator || role == RoleAdmin
+}
+
+// PublicProfile is what anyone may see about a user
+type PublicProfile struct {
+	ID           string    `json:"id"`
+	Username     string    `json:"username"`
+	AvatarURL    string    `json:"avatar_url"`
+	Role         string    `json:"role"`
+	CreatedAt    time.Time `json:"created_at"`
+	PostCount    int       `json:"post_count"`
+	CommentCount int       `json:"comment_count"` // comments and replies
+	Reputation   int       `json:"reputation"`
+
+	FollowerCount  int  `json:"follower_count"`
+	FollowingCount int  `json:"following_count"`
+	IsFollowing    bool `json:"is_following"` // whether the current user follows them
+	IsBlocked      bool `json:"is_blocked"`   // whether the current user blocked them
+	IsMuted        bool `json:"is_muted"`     // whether the current user muted them
+}
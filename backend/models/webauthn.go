@@ -0,0 +1,23 @@
+package models
+
+import "time"
+
+// WebAuthnCredential is a passkey registered to a user
+type WebAuthnCredential struct {
+	ID         string     `json:"id"`
+	UserID     string     `json:"-"`
+	PublicKey  []byte     `json:"-"`
+	SignCount  uint32     `json:"-"`
+	Name       string     `json:"name"`
+	CreatedAt  time.Time  `json:"created_at"`
+	LastUsedAt *time.Time `json:"last_used_at"`
+}
+
+// WebAuthnCeremony is a challenge waiting for a passkey registration or login response
+type WebAuthnCeremony struct {
+	Token     string
+	UserID    string
+	Ceremony  string
+	Challenge string
+	ExpiresAt time.Time
+}
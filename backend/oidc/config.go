@@ -0,0 +1,66 @@
+package oidc
+
+import (
+	"fmt"
+	"os"
+	"regexp"
+	"slices"
+	"strings"
+)
+
+var providerNameRegex = regexp.MustCompile(`^[a-z0-9-]+$`)
+
+// FromEnv reads the configured providers. OIDC_PROVIDERS lists provider names
+// and each provider is configured with variables prefixed by its upper-cased
+// name, e.g. for "gitlab":
+//
+//	OIDC_GITLAB_ISSUER         issuer URL (required)
+//	OIDC_GITLAB_CLIENT_ID      client ID (required)
+//	OIDC_GITLAB_CLIENT_SECRET  client secret
+//	OIDC_GITLAB_SCOPES         space separated scopes (default "openid email profile")
+//	OIDC_GITLAB_DISPLAY_NAME   button label (default the provider name)
+//
+// Redirect URLs are OIDC_REDIRECT_BASE_URL (default http://localhost:8080)
+// followed by /api/oidc/callback/<name>.
+func FromEnv() (map[string]*Provider, error) {
+	providers := map[string]*Provider{}
+
+	baseURL := strings.TrimRight(os.Getenv("OIDC_REDIRECT_BASE_URL"), "/")
+	if baseURL == "" {
+		baseURL = "http://localhost:8080" // fallback default
+	}
+
+	for _, name := range strings.Split(os.Getenv("OIDC_PROVIDERS"), ",") {
+		name = strings.ToLower(strings.TrimSpace(name))
+		if name == "" {
+			continue
+		}
+		if !providerNameRegex.MatchString(name) {
+			return nil, fmt.Errorf("invalid OIDC provider name %q", name)
+		}
+
+		prefix := "OIDC_" + strings.ToUpper(strings.ReplaceAll(name, "-", "_")) + "_"
+		p := &Provider{
+			Name:         name,
+			DisplayName:  os.Getenv(prefix + "DISPLAY_NAME"),
+			Issuer:       os.Getenv(prefix + "ISSUER"),
+			ClientID:     os.Getenv(prefix + "CLIENT_ID"),
+			ClientSecret: os.Getenv(prefix + "CLIENT_SECRET"),
+			Scopes:       strings.Fields(os.Getenv(prefix + "SCOPES")),
+			RedirectURL:  baseURL + "/api/oidc/callback/" + name,
+		}
+		if p.Issuer == "" || p.ClientID == "" {
+			return nil, fmt.Errorf("OIDC provider %q needs %sISSUER and %sCLIENT_ID", name, prefix, prefix)
+		}
+		if p.DisplayName == "" {
+			p.DisplayName = name
+		}
+		if len(p.Scopes) == 0 {
+			p.Scopes = []string{"openid", "email", "profile"}
+		} else if !slices.Contains(p.Scopes, "openid") {
+			p.Scopes = append([]string{"openid"}, p.Scopes...)
+		}
+		providers[name] = p
+	}
+	return providers, nil
+}
@@ -0,0 +1,212 @@
+// Package oidc implements the client side of OpenID Connect login with the
+// authorization code flow and PKCE.
+package oidc
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Provider is an OpenID Connect issuer the forum accepts logins from
+type Provider struct {
+	Name         string // Used in URLs, e.g. /api/oidc/login/<name>
+	DisplayName  string // Shown on the "Sign in with ..." button
+	Issuer       string
+	ClientID     string
+	ClientSecret string
+	Scopes       []string
+	RedirectURL  string
+	Client       *http.Client
+
+	now func() time.Time // replaced in tests
+
+	mu          sync.Mutex
+	metadata    *Metadata
+	keys        map[string]any
+	keysFetched time.Time
+}
+
+// Metadata is the subset of the discovery document the forum uses
+type Metadata struct {
+	Issuer                string `json:"issuer"`
+	AuthorizationEndpoint string `json:"authorization_endpoint"`
+	TokenEndpoint         string `json:"token_endpoint"`
+	JWKSURI               string `json:"jwks_uri"`
+	UserinfoEndpoint      string `json:"userinfo_endpoint"`
+}
+
+// Tokens is the token endpoint response
+type Tokens struct {
+	IDToken     string `json:"id_token"`
+	AccessToken string `json:"access_token"`
+	TokenType   string `json:"token_type"`
+}
+
+func (p *Provider) client() *http.Client {
+	if p.Client != nil {
+		return p.Client
+	}
+	return http.DefaultClient
+}
+
+func (p *Provider) clock() time.Time {
+	if p.now != nil {
+		return p.now()
+	}
+	return time.Now()
+}
+
+// getJSON fetches a URL and decodes the JSON body into v
+func (p *Provider) getJSON(ctx context.Context, rawURL, bearer string, v any) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, rawURL, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/json")
+	if bearer != "" {
+		req.Header.Set("Authorization", "Bearer "+bearer)
+	}
+	resp, err := p.client().Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("oidc: GET %s: %s", rawURL, resp.Status)
+	}
+	return json.NewDecoder(io.LimitReader(resp.Body, 1<<20)).Decode(v)
+}
+
+// Discover fetches and caches the provider's discovery document
+func (p *Provider) Discover(ctx context.Context) (Metadata, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.metadata != nil {
+		return *p.metadata, nil
+	}
+
+	var m Metadata
+	if err := p.getJSON(ctx, strings.TrimRight(p.Issuer, "/")+"/.well-known/openid-configuration", "", &m); err != nil {
+		return m, err
+	}
+	if m.Issuer != p.Issuer {
+		return m, fmt.Errorf("oidc: discovery issuer %q does not match %q", m.Issuer, p.Issuer)
+	}
+	if m.AuthorizationEndpoint == "" || m.TokenEndpoint == "" || m.JWKSURI == "" {
+		return m, errors.New("oidc: incomplete discovery document")
+	}
+	p.metadata = &m
+	return m, nil
+}
+
+// RandomString returns a random base64url string for states, nonces and PKCE verifiers
+func RandomString() (string, error) {
+	raw := make([]byte, 32)
+	if _, err := rand.Read(raw); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(raw), nil
+}
+
+// CodeChallenge derives the S256 PKCE challenge from a verifier
+func CodeChallenge(verifier string) string {
+	sum := sha256.Sum256([]byte(verifier))
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
+
+// AuthCodeURL returns the URL to send the browser to for login
+func (p *Provider) AuthCodeURL(ctx context.Context, state, nonce, verifier string) (string, error) {
+	m, err := p.Discover(ctx)
+	if err != nil {
+		return "", err
+	}
+	query := url.Values{
+		"response_type":         {"code"},
+		"client_id":             {p.ClientID},
+		"redirect_uri":          {p.RedirectURL},
+		"scope":                 {strings.Join(p.Scopes, " ")},
+		"state":                 {state},
+		"nonce":                 {nonce},
+		"code_challenge":        {CodeChallenge(verifier)},
+		"code_challenge_method": {"S256"},
+	}
+	separator := "?"
+	if strings.Contains(m.AuthorizationEndpoint, "?") {
+		separator = "&"
+	}
+	return m.AuthorizationEndpoint + separator + query.Encode(), nil
+}
+
+// Exchange trades an authorization code for tokens
+func (p *Provider) Exchange(ctx context.Context, code, verifier string) (Tokens, error) {
+	var tokens Tokens
+	m, err := p.Discover(ctx)
+	if err != nil {
+		return tokens, err
+	}
+
+	form := url.Values{
+		"grant_type":    {"authorization_code"},
+		"code":          {code},
+		"redirect_uri":  {p.RedirectURL},
+		"code_verifier": {verifier},
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, m.TokenEndpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return tokens, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+	req.SetBasicAuth(url.QueryEscape(p.ClientID), url.QueryEscape(p.ClientSecret))
+
+	resp, err := p.client().Do(req)
+	if err != nil {
+		return tokens, err
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return tokens, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		var e struct {
+			Error       string `json:"error"`
+			Description string `json:"error_description"`
+		}
+		json.Unmarshal(body, &e)
+		return tokens, fmt.Errorf("oidc: token exchange failed: %s %s %s", resp.Status, e.Error, e.Description)
+	}
+	if err := json.Unmarshal(body, &tokens); err != nil {
+		return tokens, fmt.Errorf("oidc: invalid token response: %w", err)
+	}
+	if tokens.IDToken == "" {
+		return tokens, errors.New("oidc: token response has no id_token")
+	}
+	return tokens, nil
+}
+
+// UserInfo fetches claims from the userinfo endpoint, for providers that
+// leave the email out of the ID token
+func (p *Provider) UserInfo(ctx context.Context, accessToken string) (Claims, error) {
+	var claims Claims
+	m, err := p.Discover(ctx)
+	if err != nil {
+		return claims, err
+	}
+	if m.UserinfoEndpoint == "" {
+		return claims, errors.New("oidc: provider has no userinfo endpoint")
+	}
+	err = p.getJSON(ctx, m.UserinfoEndpoint, accessToken, &claims)
+	return claims, err
+}
@@ -0,0 +1,172 @@
+package oidc
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"net/http"
+	"net/url"
+	"testing"
+	"time"
+
+	"forum/oidc/oidctest"
+)
+
+func newTestProvider(issuer *oidctest.Issuer) *Provider {
+	return &Provider{
+		Name:         "mock",
+		Issuer:       issuer.Issuer(),
+		ClientID:     issuer.ClientID,
+		ClientSecret: issuer.ClientSecret,
+		Scopes:       []string{"openid", "email"},
+		RedirectURL:  "http://localhost:8080/api/oidc/callback/mock",
+	}
+}
+
+func TestAuthorizationCodeFlow(t *testing.T) {
+	issuer := oidctest.NewIssuer("forum", "s3cret")
+	defer issuer.Close()
+	issuer.User = oidctest.User{Subject: "42", Email: "alice@example.com", EmailVerified: true}
+	p := newTestProvider(issuer)
+	ctx := context.Background()
+
+	state, _ := RandomString()
+	nonce, _ := RandomString()
+	verifier, _ := RandomString()
+	authURL, err := p.AuthCodeURL(ctx, state, nonce, verifier)
+	if err != nil {
+		t.Fatalf("AuthCodeURL failed: %v", err)
+	}
+
+	client := &http.Client{CheckRedirect: func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse }}
+	resp, err := client.Get(authURL)
+	if err != nil {
+		t.Fatalf("Authorize request failed: %v", err)
+	}
+	resp.Body.Close()
+	callback, _ := url.Parse(resp.Header.Get("Location"))
+	if callback.Query().Get("state") != state {
+		t.Fatalf("Expected state to be returned, got %s", callback)
+	}
+	code := callback.Query().Get("code")
+
+	if _, err := p.Exchange(ctx, code, "wrong-verifier"); err == nil {
+		t.Fatal("Expected exchange with the wrong PKCE verifier to fail")
+	}
+
+	// The failed attempt consumed the code, so authorize again
+	resp, _ = client.Get(authURL)
+	resp.Body.Close()
+	callback, _ = url.Parse(resp.Header.Get("Location"))
+	tokens, err := p.Exchange(ctx, callback.Query().Get("code"), verifier)
+	if err != nil {
+		t.Fatalf("Exchange failed: %v", err)
+	}
+
+	claims, err := p.VerifyIDToken(ctx, tokens.IDToken, nonce)
+	if err != nil {
+		t.Fatalf("VerifyIDToken failed: %v", err)
+	}
+	if claims.Subject != "42" || claims.Email != "alice@example.com" || !claims.EmailVerified {
+		t.Errorf("Unexpected claims: %+v", claims)
+	}
+
+	if _, err := p.VerifyIDToken(ctx, tokens.IDToken, "other-nonce"); !errors.Is(err, ErrNonce) {
+		t.Errorf("Expected %v, got %v", ErrNonce, err)
+	}
+
+	info, err := p.UserInfo(ctx, tokens.AccessToken)
+	if err != nil || info.Subject != "42" {
+		t.Errorf("Expected userinfo for subject 42, got %+v (%v)", info, err)
+	}
+}
+
+func TestVerifyIDTokenRejects(t *testing.T) {
+	issuer := oidctest.NewIssuer("forum", "s3cret")
+	defer issuer.Close()
+	p := newTestProvider(issuer)
+	ctx := context.Background()
+
+	valid := func() map[string]any {
+		now := time.Now()
+		return map[string]any{
+			"iss":   issuer.Issuer(),
+			"sub":   "42",
+			"aud":   "forum",
+			"exp":   now.Add(time.Hour).Unix(),
+			"iat":   now.Unix(),
+			"nonce": "n",
+		}
+	}
+	if _, err := p.VerifyIDToken(ctx, issuer.Sign(valid()), "n"); err != nil {
+		t.Fatalf("Expected valid token to verify, got %v", err)
+	}
+
+	tests := []struct {
+		name   string
+		change func(map[string]any)
+	}{
+		{"wrong issuer", func(c map[string]any) { c["iss"] = "https://evil.example.com" }},
+		{"wrong audience", func(c map[string]any) { c["aud"] = "other-client" }},
+		{"multiple audiences without azp", func(c map[string]any) { c["aud"] = []string{"forum", "other"} }},
+		{"expired", func(c map[string]any) { c["exp"] = time.Now().Add(-time.Hour).Unix() }},
+		{"issued in the future", func(c map[string]any) { c["iat"] = time.Now().Add(time.Hour).Unix() }},
+		{"no subject", func(c map[string]any) { delete(c, "sub") }},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			claims := valid()
+			tt.change(claims)
+			if _, err := p.VerifyIDToken(ctx, issuer.Sign(claims), "n"); !errors.Is(err, ErrInvalidToken) {
+				t.Errorf("Expected %v, got %v", ErrInvalidToken, err)
+			}
+		})
+	}
+
+	t.Run("signed by another key", func(t *testing.T) {
+		other := oidctest.NewIssuer("forum", "s3cret")
+		defer other.Close()
+		claims := valid()
+		if _, err := p.VerifyIDToken(ctx, other.Sign(claims), "n"); !errors.Is(err, ErrInvalidToken) {
+			t.Errorf("Expected %v, got %v", ErrInvalidToken, err)
+		}
+	})
+
+	t.Run("unsigned", func(t *testing.T) {
+		b64 := base64.RawURLEncoding.EncodeToString
+		token := b64([]byte(`{"alg":"none"}`)) + "." + b64([]byte(`{"iss":"`+issuer.Issuer()+`","sub":"42","aud":"forum","exp":9999999999,"nonce":"n"}`)) + "."
+		if _, err := p.VerifyIDToken(ctx, token, "n"); !errors.Is(err, ErrInvalidToken) {
+			t.Errorf("Expected %v, got %v", ErrInvalidToken, err)
+		}
+	})
+}
+
+func TestFromEnv(t *testing.T) {
+	t.Setenv("OIDC_PROVIDERS", "gitlab, my-idp")
+	t.Setenv("OIDC_GITLAB_ISSUER", "https://gitlab.com")
+	t.Setenv("OIDC_GITLAB_CLIENT_ID", "id")
+	t.Setenv("OIDC_GITLAB_DISPLAY_NAME", "GitLab")
+	t.Setenv("OIDC_MY_IDP_ISSUER", "https://idp.example.com")
+	t.Setenv("OIDC_MY_IDP_CLIENT_ID", "id2")
+	t.Setenv("OIDC_MY_IDP_SCOPES", "email")
+	t.Setenv("OIDC_REDIRECT_BASE_URL", "https://forum.example.com/")
+
+	providers, err := FromEnv()
+	if err != nil {
+		t.Fatalf("FromEnv failed: %v", err)
+	}
+	if len(providers) != 2 {
+		t.Fatalf("Expected 2 providers, got %d", len(providers))
+	}
+	if p := providers["gitlab"]; p.DisplayName != "GitLab" || p.RedirectURL != "https://forum.example.com/api/oidc/callback/gitlab" {
+		t.Errorf("Unexpected gitlab provider: %+v", p)
+	}
+	if p := providers["my-idp"]; len(p.Scopes) != 2 || p.Scopes[0] != "openid" {
+		t.Errorf("Expected openid scope to be added, got %v", p.Scopes)
+	}
+
+	t.Setenv("OIDC_MY_IDP_CLIENT_ID", "")
+	if _, err := FromEnv(); err == nil {
+		t.Error("Expected an error for a provider without a client ID")
+	}
+}
@@ -0,0 +1,273 @@
+package oidc
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"strings"
+	"time"
+)
+
+// clockSkew is how far the provider's clock may be off from ours
+const clockSkew = time.Minute
+
+// jwksRefreshInterval limits how often an unknown key ID triggers a JWKS refetch
+const jwksRefreshInterval = time.Minute
+
+var (
+	ErrInvalidToken = errors.New("oidc: invalid ID token")
+	ErrNonce        = errors.New("oidc: nonce mismatch")
+)
+
+// Claims are the ID token and userinfo claims the forum uses
+type Claims struct {
+	Issuer            string   `json:"iss"`
+	Subject           string   `json:"sub"`
+	Audience          audience `json:"aud"`
+	AuthorizedParty   string   `json:"azp"`
+	Expiry            int64    `json:"exp"`
+	IssuedAt          int64    `json:"iat"`
+	Nonce             string   `json:"nonce"`
+	Email             string   `json:"email"`
+	EmailVerified     boolish  `json:"email_verified"`
+	Name              string   `json:"name"`
+	PreferredUsername string   `json:"preferred_username"`
+}
+
+// audience accepts both a single audience and a list
+type audience []string
+
+func (a *audience) UnmarshalJSON(data []byte) error {
+	var single string
+	if err := json.Unmarshal(data, &single); err == nil {
+		*a = audience{single}
+		return nil
+	}
+	var list []string
+	if err := json.Unmarshal(data, &list); err != nil {
+		return err
+	}
+	*a = list
+	return nil
+}
+
+// boolish accepts true and "true", as some providers send email_verified as a string
+type boolish bool
+
+func (b *boolish) UnmarshalJSON(data []byte) error {
+	switch strings.Trim(string(data), `"`) {
+	case "true":
+		*b = true
+	default:
+		*b = false
+	}
+	return nil
+}
+
+// VerifyIDToken checks an ID token's signature and claims and returns the claims
+func (p *Provider) VerifyIDToken(ctx context.Context, raw, nonce string) (Claims, error) {
+	var claims Claims
+
+	parts := strings.Split(raw, ".")
+	if len(parts) != 3 {
+		return claims, fmt.Errorf("%w: malformed", ErrInvalidToken)
+	}
+	var header struct {
+		Alg string `json:"alg"`
+		Kid string `json:"kid"`
+	}
+	if err := decodeSegment(parts[0], &header); err != nil {
+		return claims, err
+	}
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return claims, fmt.Errorf("%w: bad signature encoding", ErrInvalidToken)
+	}
+
+	key, err := p.signingKey(ctx, header.Kid)
+	if err != nil {
+		return claims, err
+	}
+	if !verifySignature(header.Alg, key, []byte(parts[0]+"."+parts[1]), signature) {
+		return claims, fmt.Errorf("%w: signature", ErrInvalidToken)
+	}
+
+	if err := decodeSegment(parts[1], &claims); err != nil {
+		return claims, err
+	}
+
+	now := p.clock()
+	switch {
+	case claims.Issuer != p.Issuer:
+		return claims, fmt.Errorf("%w: issuer %q", ErrInvalidToken, claims.Issuer)
+	case !claims.Audience.contains(p.ClientID):
+		return claims, fmt.Errorf("%w: audience", ErrInvalidToken)
+	case len(claims.Audience) > 1 && claims.AuthorizedParty != p.ClientID:
+		return claims, fmt.Errorf("%w: authorized party", ErrInvalidToken)
+	case claims.Expiry == 0 || now.Add(-clockSkew).Unix() >= claims.Expiry:
+		return claims, fmt.Errorf("%w: expired", ErrInvalidToken)
+	case claims.IssuedAt > now.Add(clockSkew).Unix():
+		return claims, fmt.Errorf("%w: issued in the future", ErrInvalidToken)
+	case claims.Subject == "":
+		return claims, fmt.Errorf("%w: no subject", ErrInvalidToken)
+	case nonce == "" || subtle.ConstantTimeCompare([]byte(claims.Nonce), []byte(nonce)) != 1:
+		return claims, ErrNonce
+	}
+	return claims, nil
+}
+
+func (a audience) contains(clientID string) bool {
+	for _, aud := range a {
+		if aud == clientID {
+			return true
+		}
+	}
+	return false
+}
+
+func decodeSegment(segment string, v any) error {
+	data, err := base64.RawURLEncoding.DecodeString(segment)
+	if err != nil {
+		return fmt.Errorf("%w: bad encoding", ErrInvalidToken)
+	}
+	if err := json.Unmarshal(data, v); err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidToken, err)
+	}
+	return nil
+}
+
+// verifySignature checks a JWS signature. Only asymmetric algorithms are
+// accepted, so "none" and HMAC tokens signed with a public key fail here.
+func verifySignature(alg string, key any, signed, signature []byte) bool {
+	sum := sha256.Sum256(signed)
+	switch alg {
+	case "RS256":
+		rsaKey, ok := key.(*rsa.PublicKey)
+		return ok && rsa.VerifyPKCS1v15(rsaKey, crypto.SHA256, sum[:], signature) == nil
+	case "ES256":
+		ecKey, ok := key.(*ecdsa.PublicKey)
+		if !ok || len(signature) != 64 {
+			return false
+		}
+		r := new(big.Int).SetBytes(signature[:32])
+		s := new(big.Int).SetBytes(signature[32:])
+		return ecdsa.Verify(ecKey, sum[:], r, s)
+	}
+	return false
+}
+
+// signingKey returns the provider key with the given ID, refetching the JWKS
+// when the key is unknown so provider key rotation is picked up
+func (p *Provider) signingKey(ctx context.Context, kid string) (any, error) {
+	m, err := p.Discover(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if key, ok := p.lookupKey(kid); ok {
+		return key, nil
+	}
+	if !p.keysFetched.IsZero() && p.clock().Sub(p.keysFetched) < jwksRefreshInterval {
+		return nil, fmt.Errorf("%w: unknown key %q", ErrInvalidToken, kid)
+	}
+
+	var set struct {
+		Keys []jwk `json:"keys"`
+	}
+	if err := p.getJSON(ctx, m.JWKSURI, "", &set); err != nil {
+		return nil, err
+	}
+	p.keys = map[string]any{}
+	for _, k := range set.Keys {
+		if key, err := k.publicKey(); err == nil {
+			p.keys[k.Kid] = key
+		}
+	}
+	p.keysFetched = p.clock()
+
+	if key, ok := p.lookupKey(kid); ok {
+		return key, nil
+	}
+	return nil, fmt.Errorf("%w: unknown key %q", ErrInvalidToken, kid)
+}
+
+// lookupKey finds a cached key. Tokens without a key ID are accepted only
+// when the provider publishes a single key.
+func (p *Provider) lookupKey(kid string) (any, bool) {
+	if kid == "" && len(p.keys) == 1 {
+		for _, key := range p.keys {
+			return key, true
+		}
+	}
+	key, ok := p.keys[kid]
+	return key, ok
+}
+
+// jwk is a JSON Web Key (RFC 7517)
+type jwk struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+	Crv string `json:"crv"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+}
+
+func (k jwk) publicKey() (any, error) {
+	if k.Use != "" && k.Use != "sig" {
+		return nil, errors.New("oidc: not a signing key")
+	}
+	decode := func(s string) (*big.Int, error) {
+		b, err := base64.RawURLEncoding.DecodeString(s)
+		if err != nil || len(b) == 0 {
+			return nil, errors.New("oidc: invalid key parameter")
+		}
+		return new(big.Int).SetBytes(b), nil
+	}
+
+	switch k.Kty {
+	case "RSA":
+		n, err := decode(k.N)
+		if err != nil {
+			return nil, err
+		}
+		e, err := decode(k.E)
+		if err != nil || !e.IsInt64() {
+			return nil, errors.New("oidc: invalid RSA exponent")
+		}
+		if n.BitLen() < 2048 {
+			return nil, errors.New("oidc: RSA key too small")
+		}
+		return &rsa.PublicKey{N: n, E: int(e.Int64())}, nil
+	case "EC":
+		if k.Crv != "P-256" {
+			return nil, errors.New("oidc: unsupported curve")
+		}
+		x, err := decode(k.X)
+		if err != nil {
+			return nil, err
+		}
+		y, err := decode(k.Y)
+		if err != nil {
+			return nil, err
+		}
+		if !elliptic.P256().IsOnCurve(x, y) {
+			return nil, errors.New("oidc: EC point is not on the curve")
+		}
+		return &ecdsa.PublicKey{Curve: elliptic.P256(), X: x, Y: y}, nil
+	}
+	return nil, fmt.Errorf("oidc: unsupported key type %q", k.Kty)
+}
@@ -0,0 +1,50 @@
+package passhash
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// FromEnv reads the parameters for new hashes from environment variables,
+// keeping the defaults for unset ones:
+//
+//	PASSWORD_HASH_ALGORITHM  argon2id (default) or bcrypt
+//	PASSWORD_BCRYPT_COST     bcrypt work factor (default 12)
+//	PASSWORD_ARGON2_TIME     argon2id passes (default 2)
+//	PASSWORD_ARGON2_MEMORY   argon2id memory in KiB (default 19456)
+//	PASSWORD_ARGON2_THREADS  argon2id parallelism (default 1)
+func FromEnv() (Params, error) {
+	p := DefaultParams
+	if algorithm := os.Getenv("PASSWORD_HASH_ALGORITHM"); algorithm != "" {
+		p.Algorithm = strings.ToLower(algorithm)
+	}
+
+	numbers := []struct {
+		name string
+		set  func(uint64)
+		bits int
+	}{
+		{"PASSWORD_BCRYPT_COST", func(v uint64) { p.BcryptCost = int(v) }, 8},
+		{"PASSWORD_ARGON2_TIME", func(v uint64) { p.Argon2Time = uint32(v) }, 32},
+		{"PASSWORD_ARGON2_MEMORY", func(v uint64) { p.Argon2Memory = uint32(v) }, 32},
+		{"PASSWORD_ARGON2_THREADS", func(v uint64) { p.Argon2Threads = uint8(v) }, 8},
+	}
+	for _, n := range numbers {
+		value := os.Getenv(n.name)
+		if value == "" {
+			continue
+		}
+		v, err := strconv.ParseUint(value, 10, n.bits)
+		if err != nil {
+			return DefaultParams, fmt.Errorf("%s: %q is not a valid number", n.name, value)
+		}
+		n.set(v)
+	}
+
+	if err := p.Validate(); err != nil {
+		return DefaultParams, err
+	}
+	return p, nil
+}
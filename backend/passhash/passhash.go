@@ -0,0 +1,198 @@
+// Package passhash hashes passwords with argon2id or bcrypt. Hashes record
+// their algorithm and parameters, so Verify can tell when a stored hash was
+// made with older settings and should be replaced.
+package passhash
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+
+	"golang.org/x/crypto/argon2"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// Algorithms
+const (
+	Argon2id = "argon2id"
+	Bcrypt   = "bcrypt"
+)
+
+const (
+	argon2SaltLength = 16
+	argon2KeyLength  = 32
+
+	// bcryptMaxPassword is the longest input bcrypt uses; x/crypto rejects longer ones
+	bcryptMaxPassword = 72
+
+	// bcryptSHA256Prefix marks bcrypt hashes of a SHA-256 pre-hash of the password
+	bcryptSHA256Prefix = "$bcrypt-sha256$"
+
+	// Upper bounds for parameters read from a stored hash
+	maxArgon2Memory = 4 * 1024 * 1024 // KiB
+	maxArgon2Time   = 100
+)
+
+// ErrUnknownFormat is returned for a stored hash no algorithm recognises
+var ErrUnknownFormat = errors.New("passhash: unknown hash format")
+
+// Params selects the algorithm and cost of new hashes
+type Params struct {
+	Algorithm string
+
+	// BcryptCost is the bcrypt work factor (4 to 31)
+	BcryptCost int
+
+	// Argon2Time is the number of passes, Argon2Memory the memory in KiB
+	// and Argon2Threads the parallelism
+	Argon2Time    uint32
+	Argon2Memory  uint32
+	Argon2Threads uint8
+}
+
+// DefaultParams follow the OWASP password storage recommendations
+var DefaultParams = Params{
+	Algorithm:     Argon2id,
+	BcryptCost:    12,
+	Argon2Time:    2,
+	Argon2Memory:  19 * 1024,
+	Argon2Threads: 1,
+}
+
+// Validate checks that the parameters can be used to hash
+func (p Params) Validate() error {
+	switch p.Algorithm {
+	case Argon2id:
+		if p.Argon2Time < 1 || p.Argon2Time > maxArgon2Time {
+			return fmt.Errorf("passhash: argon2 time must be between 1 and %d", maxArgon2Time)
+		}
+		if p.Argon2Memory < 8*uint32(p.Argon2Threads) || p.Argon2Memory > maxArgon2Memory {
+			return fmt.Errorf("passhash: argon2 memory must be between 8 KiB per thread and %d KiB", maxArgon2Memory)
+		}
+		if p.Argon2Threads < 1 {
+			return errors.New("passhash: argon2 needs at least one thread")
+		}
+	case Bcrypt:
+		if p.BcryptCost < bcrypt.MinCost || p.BcryptCost > bcrypt.MaxCost {
+			return fmt.Errorf("passhash: bcrypt cost must be between %d and %d", bcrypt.MinCost, bcrypt.MaxCost)
+		}
+	default:
+		return fmt.Errorf("passhash: unknown algorithm %q", p.Algorithm)
+	}
+	return nil
+}
+
+// Hash hashes a password with the configured algorithm
+func (p Params) Hash(password string) (string, error) {
+	if err := p.Validate(); err != nil {
+		return "", err
+	}
+
+	if p.Algorithm == Bcrypt {
+		hash, err := bcrypt.GenerateFromPassword(prehash(password), p.BcryptCost)
+		if err != nil {
+			return "", err
+		}
+		return bcryptSHA256Prefix + string(hash), nil
+	}
+
+	salt := make([]byte, argon2SaltLength)
+	if _, err := rand.Read(salt); err != nil {
+		return "", err
+	}
+	key := argon2.IDKey([]byte(password), salt, p.Argon2Time, p.Argon2Memory, p.Argon2Threads, argon2KeyLength)
+	return fmt.Sprintf("$argon2id$v=%d$m=%d,t=%d,p=%d$%s$%s",
+		argon2.Version, p.Argon2Memory, p.Argon2Time, p.Argon2Threads,
+		b64.EncodeToString(salt), b64.EncodeToString(key)), nil
+}
+
+// Verify checks a password against a stored hash. needsRehash is true when
+// the password matched but the hash does not use the current parameters.
+func (p Params) Verify(password, encoded string) (match, needsRehash bool, err error) {
+	switch {
+	case strings.HasPrefix(encoded, "$argon2id$"):
+		stored, salt, key, err := parseArgon2(encoded)
+		if err != nil {
+			return false, false, err
+		}
+		candidate := argon2.IDKey([]byte(password), salt, stored.Argon2Time, stored.Argon2Memory, stored.Argon2Threads, uint32(len(key)))
+		if subtle.ConstantTimeCompare(candidate, key) != 1 {
+			return false, false, nil
+		}
+		outdated := p.Algorithm != Argon2id ||
+			stored.Argon2Time != p.Argon2Time ||
+			stored.Argon2Memory != p.Argon2Memory ||
+			stored.Argon2Threads != p.Argon2Threads ||
+			len(salt) != argon2SaltLength || len(key) != argon2KeyLength
+		return true, outdated, nil
+
+	case strings.HasPrefix(encoded, bcryptSHA256Prefix):
+		hash := []byte(strings.TrimPrefix(encoded, bcryptSHA256Prefix))
+		if !compareBcrypt(hash, prehash(password)) {
+			return false, false, nil
+		}
+		cost, err := bcrypt.Cost(hash)
+		return true, err != nil || p.Algorithm != Bcrypt || cost != p.BcryptCost, nil
+
+	case strings.HasPrefix(encoded, "$2"):
+		// Plain bcrypt hashes from before passwords were pre-hashed. They
+		// can only come from passwords of at most 72 bytes, and longer
+		// inputs would be silently truncated, so those never match.
+		if len(password) > bcryptMaxPassword {
+			return false, false, nil
+		}
+		if !compareBcrypt([]byte(encoded), []byte(password)) {
+			return false, false, nil
+		}
+		return true, true, nil
+	}
+	return false, false, ErrUnknownFormat
+}
+
+var b64 = base64.RawStdEncoding
+
+// prehash lets bcrypt use the whole password: the SHA-256 digest is base64
+// encoded so it fits in 72 bytes and contains no NUL bytes
+func prehash(password string) []byte {
+	sum := sha256.Sum256([]byte(password))
+	return []byte(base64.StdEncoding.EncodeToString(sum[:]))
+}
+
+func compareBcrypt(hash, password []byte) bool {
+	return bcrypt.CompareHashAndPassword(hash, password) == nil
+}
+
+// parseArgon2 decodes $argon2id$v=19$m=...,t=...,p=...$salt$key
+func parseArgon2(encoded string) (Params, []byte, []byte, error) {
+	parts := strings.Split(encoded, "$")
+	if len(parts) != 6 {
+		return Params{}, nil, nil, ErrUnknownFormat
+	}
+
+	var version int
+	if _, err := fmt.Sscanf(parts[2], "v=%d", &version); err != nil || version != argon2.Version {
+		return Params{}, nil, nil, fmt.Errorf("passhash: unsupported argon2 version %q", parts[2])
+	}
+
+	p := Params{Algorithm: Argon2id}
+	if _, err := fmt.Sscanf(parts[3], "m=%d,t=%d,p=%d", &p.Argon2Memory, &p.Argon2Time, &p.Argon2Threads); err != nil {
+		return Params{}, nil, nil, ErrUnknownFormat
+	}
+	if err := p.Validate(); err != nil {
+		return Params{}, nil, nil, err
+	}
+
+	salt, err := b64.DecodeString(parts[4])
+	if err != nil || len(salt) < 8 {
+		return Params{}, nil, nil, ErrUnknownFormat
+	}
+	key, err := b64.DecodeString(parts[5])
+	if err != nil || len(key) < 16 {
+		return Params{}, nil, nil, ErrUnknownFormat
+	}
+	return p, salt, key, nil
+}
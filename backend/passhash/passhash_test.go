@@ -0,0 +1,134 @@
+package passhash
+
+import (
+	"strings"
+	"testing"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+// fast keeps the tests quick; production uses DefaultParams
+var fast = Params{Algorithm: Argon2id, BcryptCost: bcrypt.MinCost, Argon2Time: 1, Argon2Memory: 64, Argon2Threads: 1}
+
+func TestHashAndVerify(t *testing.T) {
+	for _, algorithm := range []string{Argon2id, Bcrypt} {
+		t.Run(algorithm, func(t *testing.T) {
+			p := fast
+			p.Algorithm = algorithm
+			hash, err := p.Hash("correct horse 1")
+			if err != nil {
+				t.Fatalf("Hash failed: %v", err)
+			}
+			if match, rehash, err := p.Verify("correct horse 1", hash); !match || rehash || err != nil {
+				t.Errorf("Expected match without rehash, got %v %v %v", match, rehash, err)
+			}
+			if match, _, _ := p.Verify("correct horse 2", hash); match {
+				t.Error("Expected wrong password to fail")
+			}
+
+			other, _ := p.Hash("correct horse 1")
+			if other == hash {
+				t.Error("Expected a random salt per hash")
+			}
+		})
+	}
+}
+
+func TestLongPasswords(t *testing.T) {
+	long := strings.Repeat("a", 72)
+	for _, algorithm := range []string{Argon2id, Bcrypt} {
+		t.Run(algorithm, func(t *testing.T) {
+			p := fast
+			p.Algorithm = algorithm
+			hash, err := p.Hash(long + "1")
+			if err != nil {
+				t.Fatalf("Expected passwords over 72 bytes to hash, got %v", err)
+			}
+			if match, _, _ := p.Verify(long+"1", hash); !match {
+				t.Error("Expected long password to match")
+			}
+			if match, _, _ := p.Verify(long+"2", hash); match {
+				t.Error("Expected every byte of a long password to count")
+			}
+		})
+	}
+
+	t.Run("legacy bcrypt", func(t *testing.T) {
+		legacy, _ := bcrypt.GenerateFromPassword([]byte(long), bcrypt.MinCost)
+		if match, _, _ := fast.Verify(long+"extra", string(legacy)); match {
+			t.Error("Expected a truncated match against a legacy hash to fail")
+		}
+	})
+}
+
+func TestNeedsRehash(t *testing.T) {
+	legacy, _ := bcrypt.GenerateFromPassword([]byte("password123"), bcrypt.MinCost)
+	bcryptParams := fast
+	bcryptParams.Algorithm = Bcrypt
+	bcryptHash, _ := bcryptParams.Hash("password123")
+	argonHash, _ := fast.Hash("password123")
+
+	stronger := fast
+	stronger.Argon2Time = 2
+	strongerBcrypt := bcryptParams
+	strongerBcrypt.BcryptCost++
+
+	tests := []struct {
+		name     string
+		params   Params
+		hash     string
+		expected bool
+	}{
+		{"legacy bcrypt", bcryptParams, string(legacy), true},
+		{"bcrypt to argon2id", fast, bcryptHash, true},
+		{"bcrypt cost raised", strongerBcrypt, bcryptHash, true},
+		{"argon2id to bcrypt", bcryptParams, argonHash, true},
+		{"argon2id time raised", stronger, argonHash, true},
+		{"current argon2id", fast, argonHash, false},
+		{"current bcrypt", bcryptParams, bcryptHash, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			match, rehash, err := tt.params.Verify("password123", tt.hash)
+			if !match || err != nil {
+				t.Fatalf("Expected match, got %v (%v)", match, err)
+			}
+			if rehash != tt.expected {
+				t.Errorf("Expected needsRehash %v, got %v", tt.expected, rehash)
+			}
+		})
+	}
+}
+
+func TestVerifyRejectsMalformedHashes(t *testing.T) {
+	for _, hash := range []string{
+		"",
+		"plaintext",
+		"$argon2id$v=19$m=64,t=1,p=1$c2FsdA",
+		"$argon2id$v=18$m=64,t=1,p=1$c2FsdHNhbHQ$a2V5a2V5a2V5a2V5a2V5a2V5",
+		"$argon2id$v=19$m=99999999,t=1,p=1$c2FsdHNhbHQ$a2V5a2V5a2V5a2V5a2V5a2V5",
+	} {
+		if match, _, err := fast.Verify("password123", hash); match || err == nil {
+			t.Errorf("Expected %q to be rejected with an error, got %v %v", hash, match, err)
+		}
+	}
+}
+
+func TestFromEnv(t *testing.T) {
+	t.Setenv("PASSWORD_HASH_ALGORITHM", "BCRYPT")
+	t.Setenv("PASSWORD_BCRYPT_COST", "13")
+	p, err := FromEnv()
+	if err != nil || p.Algorithm != Bcrypt || p.BcryptCost != 13 {
+		t.Errorf("Unexpected params %+v (%v)", p, err)
+	}
+
+	t.Setenv("PASSWORD_BCRYPT_COST", "2")
+	if _, err := FromEnv(); err == nil {
+		t.Error("Expected a bcrypt cost below the minimum to be rejected")
+	}
+
+	t.Setenv("PASSWORD_HASH_ALGORITHM", "md5")
+	if p, err := FromEnv(); err == nil || p != DefaultParams {
+		t.Error("Expected an unknown algorithm to fall back to the defaults with an error")
+	}
+}
@@ -0,0 +1,59 @@
+package pow
+
+import (
+	"crypto/rand"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"time"
+)
+
+// Defaults for FromEnv
+const (
+	DefaultDifficulty    = 16
+	DefaultMaxDifficulty = 22
+	DefaultTTL           = 10 * time.Minute
+)
+
+// FromEnv creates the issuer described by environment variables:
+//
+//	POW_DIFFICULTY      leading zero bits to find (default 16, 0 disables)
+//	POW_MAX_DIFFICULTY  cap for addresses asking for many challenges (default 22)
+//	POW_SECRET          signing key, shared by every backend instance
+//
+// It returns nil when proof-of-work is disabled. On an invalid setting it
+// returns an issuer with the defaults along with the error.
+func FromEnv() (*Issuer, error) {
+	base, maxDifficulty := DefaultDifficulty, DefaultMaxDifficulty
+	var err error
+	for _, n := range []struct {
+		name  string
+		value *int
+	}{{"POW_DIFFICULTY", &base}, {"POW_MAX_DIFFICULTY", &maxDifficulty}} {
+		value := os.Getenv(n.name)
+		if value == "" {
+			continue
+		}
+		v, convErr := strconv.Atoi(value)
+		if convErr != nil || v < 0 || v > 32 {
+			err = fmt.Errorf("%s: %q is not a number from 0 to 32", n.name, value)
+			base, maxDifficulty = DefaultDifficulty, DefaultMaxDifficulty
+			break
+		}
+		*n.value = v
+	}
+	if err == nil && base == 0 {
+		return nil, nil
+	}
+
+	secret := []byte(os.Getenv("POW_SECRET"))
+	if len(secret) == 0 {
+		secret = make([]byte, 32)
+		if _, randErr := rand.Read(secret); randErr != nil {
+			panic("failed to generate proof-of-work secret: " + randErr.Error())
+		}
+		log.Println("⚠️  POW_SECRET not set, using a random secret (challenges reset on restart)")
+	}
+	return NewIssuer(secret, base, maxDifficulty, DefaultTTL), err
+}
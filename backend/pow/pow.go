@@ -0,0 +1,208 @@
+// Package pow implements hashcash-style proof-of-work challenges. A challenge
+// is a signed token, so issuing one needs no storage: the client finds a
+// nonce such that SHA-256(token ":" nonce) starts with Difficulty zero bits,
+// and the server checks the signature, the expiry and the hash.
+package pow
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"math"
+	"math/bits"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+var (
+	ErrInvalid     = errors.New("invalid challenge")
+	ErrExpired     = errors.New("challenge expired")
+	ErrUnsolved    = errors.New("challenge not solved")
+	ErrReplayed    = errors.New("challenge already used")
+	ErrWrongAction = errors.New("challenge issued for another action")
+)
+
+const (
+	// rateHalfLife is how fast an address's recent challenge count decays
+	rateHalfLife = 10 * time.Minute
+	// maxTrackedClients bounds the number of addresses rates are kept for
+	maxTrackedClients = 10000
+)
+
+// Challenge is a token to solve for one action
+type Challenge struct {
+	Token      string    `json:"token"`
+	Difficulty int       `json:"difficulty"`
+	Algorithm  string    `json:"algorithm"`
+	ExpiresAt  time.Time `json:"expires_at"`
+}
+
+// Issuer signs and verifies challenges. The difficulty starts at
+// BaseDifficulty bits and grows by a bit every time an address doubles the
+// number of challenges it asked for recently, up to MaxDifficulty.
+type Issuer struct {
+	BaseDifficulty int
+	MaxDifficulty  int
+	TTL            time.Duration
+
+	secret []byte
+	now    func() time.Time
+
+	mu    sync.Mutex
+	rates map[string]*rate
+	used  map[string]time.Time // solved tokens until they expire
+}
+
+type rate struct {
+	score float64
+	last  time.Time
+}
+
+// NewIssuer creates an issuer signing with secret
+func NewIssuer(secret []byte, baseDifficulty, maxDifficulty int, ttl time.Duration) *Issuer {
+	return &Issuer{
+		BaseDifficulty: baseDifficulty,
+		MaxDifficulty:  max(baseDifficulty, maxDifficulty),
+		TTL:            ttl,
+		secret:         secret,
+		now:            time.Now,
+		rates:          make(map[string]*rate),
+		used:           make(map[string]time.Time),
+	}
+}
+
+// Issue returns a challenge for action, bound to the client address
+func (i *Issuer) Issue(action, clientIP string) (Challenge, error) {
+	salt := make([]byte, 12)
+	if _, err := rand.Read(salt); err != nil {
+		return Challenge{}, err
+	}
+	difficulty := i.difficulty(clientIP)
+	expiresAt := i.now().Add(i.TTL).Truncate(time.Second)
+
+	payload := strings.Join([]string{
+		"v1",
+		action,
+		strconv.Itoa(difficulty),
+		strconv.FormatInt(expiresAt.Unix(), 10),
+		base64.RawURLEncoding.EncodeToString(salt),
+	}, ".")
+	token := payload + "." + i.sign(payload, clientIP)
+	return Challenge{Token: token, Difficulty: difficulty, Algorithm: "sha256", ExpiresAt: expiresAt}, nil
+}
+
+// Verify checks a solved challenge for action from the same address it was
+// issued to. Each token is accepted once.
+func (i *Issuer) Verify(token, nonce, action, clientIP string) error {
+	parts := strings.Split(token, ".")
+	if len(parts) != 6 || parts[0] != "v1" || nonce == "" || len(nonce) > 64 {
+		return ErrInvalid
+	}
+	payload := strings.Join(parts[:5], ".")
+	if !hmac.Equal([]byte(parts[5]), []byte(i.sign(payload, clientIP))) {
+		return ErrInvalid
+	}
+	if parts[1] != action {
+		return ErrWrongAction
+	}
+	difficulty, err := strconv.Atoi(parts[2])
+	if err != nil {
+		return ErrInvalid
+	}
+	expires, err := strconv.ParseInt(parts[3], 10, 64)
+	if err != nil {
+		return ErrInvalid
+	}
+	expiresAt := time.Unix(expires, 0)
+	if !i.now().Before(expiresAt) {
+		return ErrExpired
+	}
+	if LeadingZeroBits(token, nonce) < difficulty {
+		return ErrUnsolved
+	}
+
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	now := i.now()
+	for used, until := range i.used {
+		if !now.Before(until) {
+			delete(i.used, used)
+		}
+	}
+	if _, ok := i.used[token]; ok {
+		return ErrReplayed
+	}
+	i.used[token] = expiresAt
+	return nil
+}
+
+func (i *Issuer) sign(payload, clientIP string) string {
+	mac := hmac.New(sha256.New, i.secret)
+	mac.Write([]byte(payload))
+	mac.Write([]byte{0})
+	mac.Write([]byte(clientIP))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// difficulty records a challenge for clientIP and returns the difficulty
+// for it: the base for the first few, then a bit more per doubling
+func (i *Issuer) difficulty(clientIP string) int {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	now := i.now()
+	r, ok := i.rates[clientIP]
+	if !ok {
+		if len(i.rates) >= maxTrackedClients {
+			i.pruneRates(now)
+		}
+		r = &rate{last: now}
+		i.rates[clientIP] = r
+	}
+	r.score = r.score*math.Pow(0.5, now.Sub(r.last).Seconds()/rateHalfLife.Seconds()) + 1
+	r.last = now
+
+	extra := int(math.Log2(r.score)) - 1
+	return min(i.BaseDifficulty+max(extra, 0), i.MaxDifficulty)
+}
+
+// pruneRates forgets addresses that have been quiet for a while, or every
+// address if that doesn't free any room
+func (i *Issuer) pruneRates(now time.Time) {
+	for ip, r := range i.rates {
+		if now.Sub(r.last) > 4*rateHalfLife {
+			delete(i.rates, ip)
+		}
+	}
+	if len(i.rates) >= maxTrackedClients {
+		clear(i.rates)
+	}
+}
+
+// LeadingZeroBits counts the zero bits SHA-256(token ":" nonce) starts with
+func LeadingZeroBits(token, nonce string) int {
+	sum := sha256.Sum256([]byte(token + ":" + nonce))
+	n := 0
+	for _, b := range sum {
+		if b != 0 {
+			return n + bits.LeadingZeros8(b)
+		}
+		n += 8
+	}
+	return n
+}
+
+// Solve finds a nonce for a challenge, as a client would
+func Solve(token string, difficulty int) string {
+	for n := 0; ; n++ {
+		nonce := fmt.Sprint(n)
+		if LeadingZeroBits(token, nonce) >= difficulty {
+			return nonce
+		}
+	}
+}
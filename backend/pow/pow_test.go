@@ -0,0 +1,113 @@
+package pow
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func newTestIssuer(base, max int) (*Issuer, *time.Time) {
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	issuer := NewIssuer([]byte("secret"), base, max, 10*time.Minute)
+	issuer.now = func() time.Time { return now }
+	return issuer, &now
+}
+
+func TestIssueAndVerify(t *testing.T) {
+	issuer, now := newTestIssuer(8, 8)
+
+	challenge, err := issuer.Issue("register", "192.0.2.1")
+	if err != nil {
+		t.Fatalf("Issue failed: %v", err)
+	}
+	if challenge.Difficulty != 8 {
+		t.Fatalf("Expected difficulty 8, got %d", challenge.Difficulty)
+	}
+	nonce := Solve(challenge.Token, challenge.Difficulty)
+
+	tests := []struct {
+		name                 string
+		token, nonce, action string
+		ip                   string
+		expected             error
+	}{
+		{"other address", challenge.Token, nonce, "register", "192.0.2.2", ErrInvalid},
+		{"other action", challenge.Token, nonce, "post", "192.0.2.1", ErrWrongAction},
+		{"tampered difficulty", "v1.register.0" + challenge.Token[len("v1.register.8"):], nonce, "register", "192.0.2.1", ErrInvalid},
+		{"garbage", "not-a-token", nonce, "register", "192.0.2.1", ErrInvalid},
+		{"no nonce", challenge.Token, "", "register", "192.0.2.1", ErrInvalid},
+	}
+	for _, tt := range tests {
+		if err := issuer.Verify(tt.token, tt.nonce, tt.action, tt.ip); !errors.Is(err, tt.expected) {
+			t.Errorf("%s: expected %v, got %v", tt.name, tt.expected, err)
+		}
+	}
+
+	// Find a nonce that doesn't solve it
+	wrong := 0
+	for LeadingZeroBits(challenge.Token, string(rune('a'+wrong))) >= 8 {
+		wrong++
+	}
+	if err := issuer.Verify(challenge.Token, string(rune('a'+wrong)), "register", "192.0.2.1"); !errors.Is(err, ErrUnsolved) {
+		t.Errorf("Expected %v, got %v", ErrUnsolved, err)
+	}
+
+	if err := issuer.Verify(challenge.Token, nonce, "register", "192.0.2.1"); err != nil {
+		t.Fatalf("Expected solved challenge to verify, got %v", err)
+	}
+	if err := issuer.Verify(challenge.Token, nonce, "register", "192.0.2.1"); !errors.Is(err, ErrReplayed) {
+		t.Errorf("Expected %v, got %v", ErrReplayed, err)
+	}
+
+	late, _ := issuer.Issue("register", "192.0.2.1")
+	*now = now.Add(11 * time.Minute)
+	if err := issuer.Verify(late.Token, Solve(late.Token, late.Difficulty), "register", "192.0.2.1"); !errors.Is(err, ErrExpired) {
+		t.Errorf("Expected %v, got %v", ErrExpired, err)
+	}
+}
+
+func TestDifficultyScalesWithRate(t *testing.T) {
+	issuer, now := newTestIssuer(10, 14)
+
+	var difficulties []int
+	for range 20 {
+		challenge, _ := issuer.Issue("post", "198.51.100.7")
+		difficulties = append(difficulties, challenge.Difficulty)
+	}
+	if difficulties[0] != 10 || difficulties[2] != 10 {
+		t.Errorf("Expected the first challenges at the base difficulty, got %v", difficulties)
+	}
+	if difficulties[19] != 13 {
+		t.Errorf("Expected 20 quick challenges to reach 13 bits, got %v", difficulties)
+	}
+
+	other, _ := issuer.Issue("post", "198.51.100.8")
+	if other.Difficulty != 10 {
+		t.Errorf("Expected other addresses to be unaffected, got %d", other.Difficulty)
+	}
+
+	for range 100 {
+		issuer.Issue("post", "198.51.100.7")
+	}
+	if c, _ := issuer.Issue("post", "198.51.100.7"); c.Difficulty != 14 {
+		t.Errorf("Expected the difficulty to be capped at 14, got %d", c.Difficulty)
+	}
+
+	*now = now.Add(2 * time.Hour)
+	if c, _ := issuer.Issue("post", "198.51.100.7"); c.Difficulty != 10 {
+		t.Errorf("Expected the difficulty to decay back to the base, got %d", c.Difficulty)
+	}
+}
+
+func TestLeadingZeroBits(t *testing.T) {
+	tests := map[string]int{
+		"1": 0, // bf...
+		"2": 3, // 16...
+		"3": 4, // 0f...
+	}
+	for nonce, expected := range tests {
+		if n := LeadingZeroBits("abc", nonce); n != expected {
+			t.Errorf("abc:%s: expected %d leading zero bits, got %d", nonce, expected, n)
+		}
+	}
+}
@@ -2,11 +2,12 @@ package routes
 
 import (
 	"database/sql"
-	"log"
 	"net/http"
 
 	"forum/handlers"
 	"forum/middleware"
+	"forum/models"
+	"forum/storage"
 )
 
 // HandlerWrapper wraps handlers to include the database connection
@@ -21,43 +22,129 @@ func SetupRoutes(db *sql.DB) http.Handler {
 	// Fetch user data
 	mux.Handle("/api/user", middleware.AuthMiddleware(db, HandlerWrapper(db, handlers.GetUser)))
 
-	// Authentication routes
-	mux.HandleFunc("/api/register", HandlerWrapper(db, handlers.RegisterUser))
-	mux.HandleFunc("/api/login", HandlerWrapper(db, handlers.LoginUser))
+	// Authentication routes (rate limited, see middleware.RateLimits)
+	mux.Handle("/api/register", middleware.RateLimitMiddleware(db, "register", HandlerWrapper(db, handlers.RegisterUser)))
+	mux.Handle("/api/login", middleware.RateLimitMiddleware(db, "login", HandlerWrapper(db, handlers.LoginUser)))
 	mux.HandleFunc("/api/logout", HandlerWrapper(db, handlers.LogoutUser))
+	mux.HandleFunc("/api/csrf-token", HandlerWrapper(db, handlers.GetCSRFToken))
+	mux.HandleFunc("/api/captcha", HandlerWrapper(db, handlers.GetCaptchaChallenge))
+	mux.Handle("/api/user/password", middleware.RateLimitMiddleware(db, "login", middleware.SessionAuthMiddleware(db, HandlerWrapper(db, handlers.ChangePassword))))
 
-	// Post routes (protected by auth middleware)
-	mux.Handle("/api/posts/create", middleware.AuthMiddleware(db, HandlerWrapper(db, handlers.CreatePost)))
+	// Two-factor authentication routes
+	mux.Handle("/api/login/2fa", middleware.RateLimitMiddleware(db, "login", HandlerWrapper(db, handlers.LoginTwoFactor)))
+	mux.Handle("/api/2fa/status", middleware.AuthMiddleware(db, HandlerWrapper(db, handlers.GetTwoFactorStatus)))
+	mux.Handle("/api/2fa/enroll", middleware.AuthMiddleware(db, HandlerWrapper(db, handlers.EnrollTOTP)))
+	mux.Handle("/api/2fa/confirm", middleware.AuthMiddleware(db, HandlerWrapper(db, handlers.ConfirmTOTP)))
+	mux.Handle("/api/2fa/disable", middleware.AuthMiddleware(db, HandlerWrapper(db, handlers.DisableTOTP)))
+
+	// Passkey (WebAuthn) routes
+	mux.Handle("/api/webauthn/login/begin", middleware.RateLimitMiddleware(db, "login", HandlerWrapper(db, handlers.BeginPasskeyLogin)))
+	mux.Handle("/api/webauthn/login/finish", middleware.RateLimitMiddleware(db, "login", HandlerWrapper(db, handlers.FinishPasskeyLogin)))
+	mux.Handle("/api/webauthn/register/begin", middleware.AuthMiddleware(db, HandlerWrapper(db, handlers.BeginPasskeyRegistration)))
+	mux.Handle("/api/webauthn/register/finish", middleware.AuthMiddleware(db, HandlerWrapper(db, handlers.FinishPasskeyRegistration)))
+	mux.Handle("/api/webauthn/credentials", middleware.AuthMiddleware(db, HandlerWrapper(db, handlers.GetPasskeys)))
+	mux.Handle("/api/webauthn/credentials/delete", middleware.AuthMiddleware(db, HandlerWrapper(db, handlers.DeletePasskey)))
+
+	// OpenID Connect login routes (the provider name follows the prefix)
+	mux.HandleFunc("/api/oidc/providers", HandlerWrapper(db, handlers.GetOIDCProviders))
+	mux.Handle("/api/oidc/login/", middleware.RateLimitMiddleware(db, "login", HandlerWrapper(db, handlers.BeginOIDCLogin)))
+	mux.HandleFunc("/api/oidc/callback/", HandlerWrapper(db, handlers.OIDCCallback))
+
+	// Personal API token management (session only, tokens cannot manage tokens)
+	mux.Handle("/api/tokens", middleware.SessionAuthMiddleware(db, HandlerWrapper(db, handlers.GetAPITokens)))
+	mux.Handle("/api/tokens/create", middleware.SessionAuthMiddleware(db, HandlerWrapper(db, handlers.CreateAPIToken)))
+	mux.Handle("/api/tokens/revoke", middleware.SessionAuthMiddleware(db, HandlerWrapper(db, handlers.RevokeAPIToken)))
+	mux.Handle("/api/tokens/revoke-all", middleware.SessionAuthMiddleware(db, HandlerWrapper(db, handlers.RevokeAllAPITokens)))
+
+	// Registration mode and invites (session only)
+	mux.HandleFunc("/api/registration", HandlerWrapper(db, handlers.GetRegistrationMode))
+	mux.Handle("/api/invites", middleware.SessionAuthMiddleware(db, HandlerWrapper(db, handlers.GetInvites)))
+	mux.Handle("/api/invites/create", middleware.SessionAuthMiddleware(db, HandlerWrapper(db, handlers.CreateInvite)))
+	mux.Handle("/api/invites/revoke", middleware.SessionAuthMiddleware(db, HandlerWrapper(db, handlers.RevokeInvite)))
+
+	// Pending account queue (administrators only)
+	mux.Handle("/api/admin/users/pending", middleware.SessionAuthMiddleware(db, middleware.AdminMiddleware(db, HandlerWrapper(db, handlers.GetPendingUsers))))
+	mux.Handle("/api/admin/users/approve", middleware.SessionAuthMiddleware(db, middleware.AdminMiddleware(db, HandlerWrapper(db, handlers.ApprovePendingUser))))
+	mux.Handle("/api/admin/users/reject", middleware.SessionAuthMiddleware(db, middleware.AdminMiddleware(db, HandlerWrapper(db, handlers.RejectPendingUser))))
+
+	// Moderation queue for held posts and comments (moderators and administrators)
+	mux.Handle("/api/moderation/queue", middleware.SessionAuthMiddleware(db, middleware.ModeratorMiddleware(db, HandlerWrapper(db, handlers.GetModerationQueue))))
+	mux.Handle("/api/moderation/approve", middleware.SessionAuthMiddleware(db, middleware.ModeratorMiddleware(db, HandlerWrapper(db, handlers.ApproveContent))))
+	mux.Handle("/api/moderation/reject", middleware.SessionAuthMiddleware(db, middleware.ModeratorMiddleware(db, HandlerWrapper(db, handlers.RejectContent))))
+
+	// Post routes (protected by auth middleware; API tokens need write:posts)
+	mux.Handle("/api/posts/create", middleware.RateLimitMiddleware(db, "posts", middleware.ScopedAuthMiddleware(db, models.ScopeWritePosts, HandlerWrapper(db, handlers.CreatePost))))
 	mux.HandleFunc("/api/posts", HandlerWrapper(db, handlers.GetPosts))                                       // Allow public access
 	mux.Handle("/api/posts/liked", middleware.AuthMiddleware(db, HandlerWrapper(db, handlers.GetLikedPosts))) // Protected
-	mux.Handle("/api/posts/update", middleware.AuthMiddleware(db, HandlerWrapper(db, handlers.UpdatePost)))
-	mux.Handle("/api/posts/delete", middleware.AuthMiddleware(db, HandlerWrapper(db, handlers.DeletePost)))
+	mux.Handle("/api/posts/update", middleware.ScopedAuthMiddleware(db, models.ScopeWritePosts, HandlerWrapper(db, handlers.UpdatePost)))
+	mux.Handle("/api/posts/delete", middleware.ScopedAuthMiddleware(db, models.ScopeWritePosts, HandlerWrapper(db, handlers.DeletePost)))
+	mux.Handle("/api/posts/answer/accept", middleware.ScopedAuthMiddleware(db, models.ScopeWritePosts, HandlerWrapper(db, handlers.AcceptAnswer)))
+	mux.Handle("/api/posts/answer/clear", middleware.ScopedAuthMiddleware(db, models.ScopeWritePosts, HandlerWrapper(db, handlers.ClearAcceptedAnswer)))
 
-	// Comment routes (protected by auth middleware)
-	mux.Handle("/api/comments/delete", middleware.AuthMiddleware(db, HandlerWrapper(db, handlers.DeleteComment)))
-	mux.Handle("/api/comment/reply/create", middleware.AuthMiddleware(db, HandlerWrapper(db, handlers.CreateReplComment)))
-	mux.Handle("/api/comments/create", middleware.AuthMiddleware(db, HandlerWrapper(db, handlers.CreateComment)))
+	// Comment routes (protected by auth middleware; API tokens need write:comments)
+	mux.Handle("/api/comments/delete", middleware.ScopedAuthMiddleware(db, models.ScopeWriteComments, HandlerWrapper(db, handlers.DeleteComment)))
+	mux.Handle("/api/comment/reply/create", middleware.RateLimitMiddleware(db, "comments", middleware.ScopedAuthMiddleware(db, models.ScopeWriteComments, HandlerWrapper(db, handlers.CreateReplComment))))
+	mux.Handle("/api/comments/create", middleware.RateLimitMiddleware(db, "comments", middleware.ScopedAuthMiddleware(db, models.ScopeWriteComments, HandlerWrapper(db, handlers.CreateComment))))
 	mux.HandleFunc("/api/comments/get", HandlerWrapper(db, handlers.GetPostComments)) // Public access
 
 	// Category routes (protected by auth middleware)
 	mux.Handle("/api/categories/create", middleware.AuthMiddleware(db, HandlerWrapper(db, handlers.CreateCategory)))
 	mux.HandleFunc("/api/categories", HandlerWrapper(db, handlers.GetCategories))
-	// Like routes
-	mux.Handle("/api/likes/toggle", middleware.AuthMiddleware(db, HandlerWrapper(db, handlers.ToggleLike))) // Protected
-	mux.HandleFunc("/api/likes/reactions", HandlerWrapper(db, handlers.GetReactions))                       // Public
+	// Like routes (toggle is protected, reactions are public)
+	mux.Handle("/api/likes/toggle", middleware.RateLimitMiddleware(db, "reactions", middleware.AuthMiddleware(db, HandlerWrapper(db, handlers.ToggleLike))))
+	mux.HandleFunc("/api/likes/reactions", HandlerWrapper(db, handlers.GetReactions))
+
+	// Poll routes (protected)
+	mux.Handle("/api/polls/vote", middleware.RateLimitMiddleware(db, "reactions", middleware.AuthMiddleware(db, HandlerWrapper(db, handlers.VotePoll))))
+	mux.Handle("/api/polls/vote/change", middleware.RateLimitMiddleware(db, "reactions", middleware.AuthMiddleware(db, HandlerWrapper(db, handlers.ChangePollVote))))
+
+	// Bookmark routes (protected)
+	mux.Handle("/api/bookmarks", middleware.AuthMiddleware(db, HandlerWrapper(db, handlers.GetBookmarks)))
+	mux.Handle("/api/bookmarks/folders", middleware.AuthMiddleware(db, HandlerWrapper(db, handlers.GetBookmarkFolders)))
+	mux.Handle("/api/bookmarks/add", middleware.AuthMiddleware(db, HandlerWrapper(db, handlers.AddBookmark)))
+	mux.Handle("/api/bookmarks/remove", middleware.AuthMiddleware(db, HandlerWrapper(db, handlers.RemoveBookmark)))
+
+	// Notification routes (protected)
+	mux.Handle("/api/notifications", middleware.AuthMiddleware(db, HandlerWrapper(db, handlers.GetNotifications)))
+	mux.Handle("/api/notifications/read", middleware.AuthMiddleware(db, HandlerWrapper(db, handlers.MarkNotificationsRead)))
+	mux.Handle("/api/notifications/preferences", middleware.AuthMiddleware(db, HandlerWrapper(db, handlers.GetNotificationPreferences)))
+	mux.Handle("/api/notifications/preferences/update", middleware.AuthMiddleware(db, HandlerWrapper(db, handlers.UpdateNotificationPreferences)))
+
+	// Follow routes and the personalised feed (follower lists are public)
+	mux.Handle("/api/feed", middleware.AuthMiddleware(db, HandlerWrapper(db, handlers.GetFeed)))
+	mux.Handle("/api/users/follow", middleware.AuthMiddleware(db, HandlerWrapper(db, handlers.FollowUser)))
+	mux.Handle("/api/users/unfollow", middleware.AuthMiddleware(db, HandlerWrapper(db, handlers.UnfollowUser)))
+	mux.HandleFunc("/api/users/followers", HandlerWrapper(db, handlers.GetFollowers))
+	mux.HandleFunc("/api/users/following", HandlerWrapper(db, handlers.GetFollowing))
+	mux.Handle("/api/categories/follow", middleware.AuthMiddleware(db, HandlerWrapper(db, handlers.FollowCategory)))
+	mux.Handle("/api/categories/unfollow", middleware.AuthMiddleware(db, HandlerWrapper(db, handlers.UnfollowCategory)))
+	mux.Handle("/api/categories/following", middleware.AuthMiddleware(db, HandlerWrapper(db, handlers.GetFollowedCategories)))
+
+	// Block and mute routes (protected)
+	mux.Handle("/api/users/block", middleware.AuthMiddleware(db, HandlerWrapper(db, handlers.BlockUser)))
+	mux.Handle("/api/users/unblock", middleware.AuthMiddleware(db, HandlerWrapper(db, handlers.UnblockUser)))
+	mux.Handle("/api/users/blocked", middleware.AuthMiddleware(db, HandlerWrapper(db, handlers.GetBlockedUsers)))
+	mux.Handle("/api/users/mute", middleware.AuthMiddleware(db, HandlerWrapper(db, handlers.MuteUser)))
+	mux.Handle("/api/users/unmute", middleware.AuthMiddleware(db, HandlerWrapper(db, handlers.UnmuteUser)))
+	mux.Handle("/api/users/muted", middleware.AuthMiddleware(db, HandlerWrapper(db, handlers.GetMutedUsers)))
+	mux.Handle("/api/categories/mute", middleware.AuthMiddleware(db, HandlerWrapper(db, handlers.MuteCategory)))
+	mux.Handle("/api/categories/unmute", middleware.AuthMiddleware(db, HandlerWrapper(db, handlers.UnmuteCategory)))
+	mux.Handle("/api/categories/muted", middleware.AuthMiddleware(db, HandlerWrapper(db, handlers.GetMutedCategories)))
+
+	// User lookups: public profiles, and mention suggestions for the composer
+	mux.HandleFunc("/api/users/profile", HandlerWrapper(db, handlers.GetUserProfile))
+	mux.Handle("/api/users/autocomplete", middleware.AuthMiddleware(db, HandlerWrapper(db, handlers.AutocompleteUsers)))
+
+	// Reputation and badge routes (public)
+	mux.HandleFunc("/api/users/reputation", HandlerWrapper(db, handlers.GetReputationHistory))
+	mux.HandleFunc("/api/users/leaderboard", HandlerWrapper(db, handlers.GetLeaderboard))
+	mux.HandleFunc("/api/users/badges", HandlerWrapper(db, handlers.GetUserBadges))
+	mux.HandleFunc("/api/badges", HandlerWrapper(db, handlers.GetBadges))
 
 	// comment, post and likes owner
 	mux.Handle("/api/owner", HandlerWrapper(db, handlers.GetOwner))
 
-	// Serve static files securely (prevent directory listing)
-	fs := http.FileServer(http.Dir("./static"))
-	mux.Handle("/static/", http.StripPrefix("/static/", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		if r.URL.Path == "/" || r.URL.Path == "" || r.URL.Path[len(r.URL.Path)-1] == '/' {
-			log.Printf("⚠️  Directory listing blocked: /static/%s", r.URL.Path)
-			http.NotFound(w, r)
-			return
-		}
-		fs.ServeHTTP(w, r)
-	})))
+	// Serve uploads from the configured storage backend (prevents directory listing)
+	mux.Handle("/static/", http.StripPrefix("/static/", storage.Handler(storage.Uploads, http.Dir("./static"))))
 	return mux
 }
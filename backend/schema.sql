@@ -6,6 +6,11 @@ CREATE TABLE IF NOT EXISTS users (
     email TEXT UNIQUE NOT NULL,
     password_hash TEXT NOT NULL,
     avatar_url TEXT DEFAULT '',
+    role TEXT NOT NULL DEFAULT 'user', -- 'user', 'moderator' or 'admin'
+    status TEXT NOT NULL DEFAULT 'active', -- 'active' or 'pending' (awaiting approval)
+    invited_by TEXT REFERENCES users(id) ON DELETE SET NULL,
+    invite_code TEXT,
+    reputation INTEGER NOT NULL DEFAULT 0, -- sum of reputation_events
     created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
     updated_at DATETIME DEFAULT CURRENT_TIMESTAMP
 );
@@ -29,6 +34,9 @@ CREATE TABLE IF NOT EXISTS posts (
     title TEXT NOT NULL,
     content TEXT NOT NULL,
     image_url TEXT,
+    status TEXT NOT NULL DEFAULT 'published', -- 'published' or 'pending' (held for moderation)
+    accepted_comment_id INTEGER REFERENCES comments(id) ON DELETE SET NULL, -- the accepted answer, a comment
+    accepted_reply_id INTEGER REFERENCES replycomments(id) ON DELETE SET NULL, -- or a reply
     created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
     updated_at DATETIME DEFAULT CURRENT_TIMESTAMP,
     FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
@@ -51,6 +59,7 @@ CREATE TABLE IF NOT EXISTS comments (
     user_id TEXT NOT NULL,
     post_id INTEGER NOT NULL,
     content TEXT NOT NULL,
+    status TEXT NOT NULL DEFAULT 'published',
     created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
     updated_at DATETIME DEFAULT CURRENT_TIMESTAMP,
     FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE,
@@ -63,6 +72,7 @@ CREATE TABLE IF NOT EXISTS replycomments (
     user_id TEXT NOT NULL,
     parent_comment_id INTEGER NOT NULL,
     content TEXT NOT NULL,
+    status TEXT NOT NULL DEFAULT 'published',
     created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
     updated_at DATETIME DEFAULT CURRENT_TIMESTAMP,
     FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE,
@@ -83,6 +93,349 @@ CREATE TABLE IF NOT EXISTS likes (
     FOREIGN KEY (comment_id) REFERENCES comments(id) ON DELETE CASCADE
 );
 
+-- Failed login tracking (per account and per client IP) for backoff and lockout
+CREATE TABLE IF NOT EXISTS login_failures (
+    subject TEXT PRIMARY KEY, -- 'account:<user id or login name>' or 'ip:<address>'
+    failures INTEGER NOT NULL DEFAULT 0,
+    last_failure_at DATETIME,
+    locked_until DATETIME
+);
+
+-- Notifications shown to users (security alerts, replies, ...)
+CREATE TABLE IF NOT EXISTS notifications (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    user_id TEXT NOT NULL,
+    type TEXT NOT NULL,
+    message TEXT NOT NULL,
+    link TEXT DEFAULT '',
+    is_read BOOLEAN NOT NULL DEFAULT 0,
+    created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS idx_notifications_user ON notifications(user_id, is_read);
+-- Notification types a user turned off or back on; types without a row are on
+CREATE TABLE IF NOT EXISTS notification_preferences (
+    user_id TEXT NOT NULL,
+    type TEXT NOT NULL,
+    enabled BOOLEAN NOT NULL,
+    PRIMARY KEY (user_id, type),
+    FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+);
+
+-- TOTP two-factor authentication (one row per enrolled user)
+CREATE TABLE IF NOT EXISTS user_totp (
+    user_id TEXT PRIMARY KEY,
+    secret TEXT NOT NULL,
+    enabled BOOLEAN NOT NULL DEFAULT 0,
+    last_used_step INTEGER NOT NULL DEFAULT 0, -- prevents replaying a code
+    created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+);
+
+-- One-time 2FA recovery codes (SHA-256 hashes)
+CREATE TABLE IF NOT EXISTS recovery_codes (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    user_id TEXT NOT NULL,
+    code_hash TEXT NOT NULL,
+    used_at DATETIME,
+    FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS idx_recovery_codes_user ON recovery_codes(user_id);
+
+-- Logins that passed the password check and are waiting for a second factor
+CREATE TABLE IF NOT EXISTS pending_logins (
+    token TEXT PRIMARY KEY,
+    user_id TEXT NOT NULL,
+    attempts INTEGER NOT NULL DEFAULT 0,
+    expires_at DATETIME NOT NULL,
+    FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+);
+
+-- WebAuthn passkeys (credential IDs are base64url, public keys COSE encoded)
+CREATE TABLE IF NOT EXISTS webauthn_credentials (
+    id TEXT PRIMARY KEY,
+    user_id TEXT NOT NULL,
+    public_key BLOB NOT NULL,
+    sign_count INTEGER NOT NULL DEFAULT 0,
+    name TEXT NOT NULL DEFAULT '',
+    created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    last_used_at DATETIME,
+    FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS idx_webauthn_credentials_user ON webauthn_credentials(user_id);
+
+-- Outstanding WebAuthn challenges, keyed by a short-lived pre-session cookie
+CREATE TABLE IF NOT EXISTS webauthn_ceremonies (
+    token TEXT PRIMARY KEY,
+    user_id TEXT, -- set for registration, NULL for login
+    ceremony TEXT NOT NULL, -- 'register' or 'login'
+    challenge TEXT NOT NULL,
+    expires_at DATETIME NOT NULL
+);
+
+-- Accounts at OpenID Connect providers linked to local users
+CREATE TABLE IF NOT EXISTS user_identities (
+    provider TEXT NOT NULL,
+    subject TEXT NOT NULL, -- the provider's stable user ID (sub claim)
+    user_id TEXT NOT NULL,
+    email TEXT NOT NULL DEFAULT '',
+    created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (provider, subject),
+    FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS idx_user_identities_user ON user_identities(user_id);
+
+-- OpenID Connect logins in progress (state, nonce and PKCE verifier)
+CREATE TABLE IF NOT EXISTS oidc_logins (
+    state TEXT PRIMARY KEY,
+    provider TEXT NOT NULL,
+    nonce TEXT NOT NULL,
+    code_verifier TEXT NOT NULL,
+    expires_at DATETIME NOT NULL
+);
+
+-- Personal API tokens for scripts and bots (only a SHA-256 hash of the token is stored)
+CREATE TABLE IF NOT EXISTS api_tokens (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    user_id TEXT NOT NULL,
+    name TEXT NOT NULL,
+    token_hash TEXT NOT NULL UNIQUE,
+    prefix TEXT NOT NULL, -- first characters of the token, shown to tell tokens apart
+    scopes TEXT NOT NULL, -- space separated, e.g. 'read write:posts'
+    expires_at DATETIME NOT NULL,
+    last_used_at DATETIME,
+    created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS idx_api_tokens_user ON api_tokens(user_id);
+
+-- Registration invite codes, single or multi use
+CREATE TABLE IF NOT EXISTS invites (
+    code TEXT PRIMARY KEY,
+    created_by TEXT NOT NULL,
+    max_uses INTEGER NOT NULL DEFAULT 1,
+    uses INTEGER NOT NULL DEFAULT 0,
+    expires_at DATETIME NOT NULL,
+    created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY (created_by) REFERENCES users(id) ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS idx_invites_created_by ON invites(created_by);
+CREATE INDEX IF NOT EXISTS idx_users_status ON users(status);
+
+-- Posts, comments and replies the spam filter held back for a moderator
+CREATE TABLE IF NOT EXISTS moderation_queue (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    content_type TEXT NOT NULL CHECK(content_type IN ('post', 'comment', 'reply')),
+    content_id INTEGER NOT NULL,
+    user_id TEXT NOT NULL,
+    reasons TEXT NOT NULL, -- one per line
+    spam_score REAL, -- NULL until the classifier is trained
+    created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE (content_type, content_id),
+    FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+);
+
+-- Naive Bayes spam classifier: documents each token appeared in, per label
+CREATE TABLE IF NOT EXISTS spam_tokens (
+    token TEXT PRIMARY KEY,
+    spam INTEGER NOT NULL DEFAULT 0,
+    ham INTEGER NOT NULL DEFAULT 0
+);
+
+-- Number of spam and ham documents the classifier was trained on
+CREATE TABLE IF NOT EXISTS spam_corpus (
+    label TEXT PRIMARY KEY CHECK(label IN ('spam', 'ham')),
+    documents INTEGER NOT NULL DEFAULT 0
+);
+
+-- Hashes of recent normalised content, to spot the same text posted again
+CREATE TABLE IF NOT EXISTS content_fingerprints (
+    fingerprint TEXT NOT NULL,
+    user_id TEXT NOT NULL,
+    created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS idx_content_fingerprints ON content_fingerprints(fingerprint, created_at);
+
+-- One-off data migrations that have run, e.g. unescape-content
+CREATE TABLE IF NOT EXISTS data_migrations (
+    name TEXT PRIMARY KEY,
+    applied_at DATETIME DEFAULT CURRENT_TIMESTAMP
+);
+-- @username mentions in posts, comments and replies
+CREATE TABLE IF NOT EXISTS mentions (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    content_type TEXT NOT NULL CHECK(content_type IN ('post', 'comment', 'reply')),
+    content_id INTEGER NOT NULL,
+    user_id TEXT NOT NULL, -- the mentioned user
+    author_id TEXT NOT NULL,
+    notified BOOLEAN NOT NULL DEFAULT 0, -- set once the mentioned user was told
+    created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE (content_type, content_id, user_id),
+    FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE,
+    FOREIGN KEY (author_id) REFERENCES users(id) ON DELETE CASCADE
+);
+CREATE INDEX IF NOT EXISTS idx_mentions_user ON mentions(user_id);
+CREATE INDEX IF NOT EXISTS idx_mentions_author ON mentions(author_id);
+-- Polls attached to posts
+CREATE TABLE IF NOT EXISTS polls (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    post_id INTEGER NOT NULL UNIQUE,
+    question TEXT NOT NULL,
+    multiple BOOLEAN NOT NULL DEFAULT 0, -- several options may be chosen
+    hide_results BOOLEAN NOT NULL DEFAULT 0, -- until closes_at
+    closes_at DATETIME NOT NULL,
+    created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY (post_id) REFERENCES posts(id) ON DELETE CASCADE
+);
+CREATE TABLE IF NOT EXISTS poll_options (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    poll_id INTEGER NOT NULL,
+    position INTEGER NOT NULL,
+    label TEXT NOT NULL,
+    UNIQUE (poll_id, id),
+    FOREIGN KEY (poll_id) REFERENCES polls(id) ON DELETE CASCADE
+);
+-- One ballot per user and poll, holding one or (in multiple choice polls) more votes
+CREATE TABLE IF NOT EXISTS poll_ballots (
+    poll_id INTEGER NOT NULL,
+    user_id TEXT NOT NULL,
+    created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (poll_id, user_id),
+    FOREIGN KEY (poll_id) REFERENCES polls(id) ON DELETE CASCADE,
+    FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+);
+CREATE TABLE IF NOT EXISTS poll_votes (
+    poll_id INTEGER NOT NULL,
+    user_id TEXT NOT NULL,
+    option_id INTEGER NOT NULL,
+    PRIMARY KEY (poll_id, user_id, option_id),
+    FOREIGN KEY (poll_id, user_id) REFERENCES poll_ballots(poll_id, user_id) ON DELETE CASCADE,
+    FOREIGN KEY (poll_id, option_id) REFERENCES poll_options(poll_id, id) ON DELETE CASCADE
+);
+CREATE INDEX IF NOT EXISTS idx_poll_votes_option ON poll_votes(option_id);
+-- Single choice polls take one vote per ballot
+CREATE TRIGGER IF NOT EXISTS poll_votes_single_choice
+BEFORE INSERT ON poll_votes
+FOR EACH ROW
+WHEN (SELECT multiple FROM polls WHERE id = NEW.poll_id) = 0
+    AND EXISTS (SELECT 1 FROM poll_votes WHERE poll_id = NEW.poll_id AND user_id = NEW.user_id)
+BEGIN
+    SELECT RAISE(ABORT, 'single choice poll');
+END;
+-- Reputation users gained from others; users.reputation is kept at the sum
+-- of their events
+CREATE TABLE IF NOT EXISTS reputation_events (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    user_id TEXT NOT NULL, -- who gained or lost reputation
+    reason TEXT NOT NULL, -- e.g. 'accepted_answer'
+    content_type TEXT NOT NULL CHECK(content_type IN ('post', 'comment', 'reply')),
+    content_id INTEGER NOT NULL, -- the content it was earned with
+    actor_id TEXT NOT NULL, -- whose reaction or question earned it
+    points INTEGER NOT NULL,
+    created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE (reason, content_type, content_id, actor_id),
+    FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE,
+    FOREIGN KEY (actor_id) REFERENCES users(id) ON DELETE CASCADE
+);
+CREATE INDEX IF NOT EXISTS idx_reputation_events_user ON reputation_events(user_id, created_at);
+CREATE INDEX IF NOT EXISTS idx_reputation_events_content ON reputation_events(content_type, content_id);
+CREATE TRIGGER IF NOT EXISTS reputation_events_award
+AFTER INSERT ON reputation_events
+FOR EACH ROW
+BEGIN
+    UPDATE users SET reputation = reputation + NEW.points WHERE id = NEW.user_id;
+END;
+CREATE TRIGGER IF NOT EXISTS reputation_events_revoke
+AFTER DELETE ON reputation_events
+FOR EACH ROW
+BEGIN
+    UPDATE users SET reputation = reputation - OLD.points WHERE id = OLD.user_id;
+END;
+-- Badges users earned; badge_id is the id of a rule in the badges package
+-- or BADGES_FILE
+CREATE TABLE IF NOT EXISTS badge_awards (
+    user_id TEXT NOT NULL,
+    badge_id TEXT NOT NULL,
+    notified BOOLEAN NOT NULL DEFAULT 0, -- set once the user was told
+    awarded_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (user_id, badge_id),
+    FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+);
+CREATE INDEX IF NOT EXISTS idx_badge_awards_badge ON badge_awards(badge_id);
+-- Private bookmarks of posts and comments
+CREATE TABLE IF NOT EXISTS bookmarks (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    user_id TEXT NOT NULL,
+    post_id INTEGER,
+    comment_id INTEGER,
+    folder TEXT NOT NULL DEFAULT '', -- '' when not filed in a folder
+    note TEXT NOT NULL DEFAULT '',
+    created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    CHECK ((post_id IS NULL) != (comment_id IS NULL)),
+    UNIQUE (user_id, post_id),
+    UNIQUE (user_id, comment_id),
+    FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE,
+    FOREIGN KEY (post_id) REFERENCES posts(id) ON DELETE CASCADE,
+    FOREIGN KEY (comment_id) REFERENCES comments(id) ON DELETE CASCADE
+);
+CREATE INDEX IF NOT EXISTS idx_bookmarks_user ON bookmarks(user_id, created_at);
+-- Users following other users, and categories, for their feed
+CREATE TABLE IF NOT EXISTS user_follows (
+    follower_id TEXT NOT NULL,
+    followed_id TEXT NOT NULL,
+    created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (follower_id, followed_id),
+    CHECK (follower_id != followed_id),
+    FOREIGN KEY (follower_id) REFERENCES users(id) ON DELETE CASCADE,
+    FOREIGN KEY (followed_id) REFERENCES users(id) ON DELETE CASCADE
+);
+CREATE INDEX IF NOT EXISTS idx_user_follows_followed ON user_follows(followed_id, created_at);
+CREATE TABLE IF NOT EXISTS category_follows (
+    user_id TEXT NOT NULL,
+    category_id INTEGER NOT NULL,
+    created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (user_id, category_id),
+    FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE,
+    FOREIGN KEY (category_id) REFERENCES categories(id) ON DELETE CASCADE
+);
+-- Users blocking or muting other users, and muting categories. Their content
+-- is hidden from them; blocked users can't reply to them either.
+CREATE TABLE IF NOT EXISTS user_blocks (
+    blocker_id TEXT NOT NULL,
+    blocked_id TEXT NOT NULL,
+    created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (blocker_id, blocked_id),
+    CHECK (blocker_id != blocked_id),
+    FOREIGN KEY (blocker_id) REFERENCES users(id) ON DELETE CASCADE,
+    FOREIGN KEY (blocked_id) REFERENCES users(id) ON DELETE CASCADE
+);
+CREATE TABLE IF NOT EXISTS user_mutes (
+    user_id TEXT NOT NULL,
+    muted_id TEXT NOT NULL,
+    created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (user_id, muted_id),
+    CHECK (user_id != muted_id),
+    FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE,
+    FOREIGN KEY (muted_id) REFERENCES users(id) ON DELETE CASCADE
+);
+CREATE TABLE IF NOT EXISTS category_mutes (
+    user_id TEXT NOT NULL,
+    category_id INTEGER NOT NULL,
+    created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (user_id, category_id),
+    FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE,
+    FOREIGN KEY (category_id) REFERENCES categories(id) ON DELETE CASCADE
+);
+CREATE INDEX IF NOT EXISTS idx_posts_status ON posts(status);
+
 -- Ensure the old trigger is removed before creating a new one
 DROP TRIGGER IF EXISTS update_user_timestamp;
 DROP TRIGGER IF EXISTS update_post_timestamp;
@@ -0,0 +1,56 @@
+package spamfilter
+
+import (
+	"math"
+	"sort"
+)
+
+const (
+	// MinTrainingDocuments is how many spam and how many ham decisions the
+	// classifier needs before its score is used
+	MinTrainingDocuments = 10
+	// interestingTokens is how many of the most telling tokens are combined,
+	// so long texts don't pile up overconfident evidence
+	interestingTokens = 15
+)
+
+// TokenCount is how many spam and ham documents a token appeared in
+type TokenCount struct {
+	Spam, Ham int
+}
+
+// Model is the part of the trained classifier needed to score a text: the
+// document totals and the counts of the text's tokens
+type Model struct {
+	SpamDocuments, HamDocuments int
+	Tokens                      map[string]TokenCount
+}
+
+// SpamProbability scores tokens with naive Bayes over token presence, using
+// Laplace smoothing. ok is false until the model has enough training.
+func (m Model) SpamProbability(tokens []string) (p float64, ok bool) {
+	if m.SpamDocuments < MinTrainingDocuments || m.HamDocuments < MinTrainingDocuments {
+		return 0, false
+	}
+
+	var evidence []float64
+	for _, token := range tokens {
+		count, known := m.Tokens[token]
+		if !known || count.Spam+count.Ham == 0 {
+			continue
+		}
+		pSpam := (float64(count.Spam) + 1) / (float64(m.SpamDocuments) + 2)
+		pHam := (float64(count.Ham) + 1) / (float64(m.HamDocuments) + 2)
+		evidence = append(evidence, math.Log(pSpam/pHam))
+	}
+	sort.Slice(evidence, func(i, j int) bool { return math.Abs(evidence[i]) > math.Abs(evidence[j]) })
+	if len(evidence) > interestingTokens {
+		evidence = evidence[:interestingTokens]
+	}
+
+	logOdds := math.Log(float64(m.SpamDocuments) / float64(m.HamDocuments))
+	for _, e := range evidence {
+		logOdds += e
+	}
+	return 1 / (1 + math.Exp(-logOdds)), true
+}
@@ -0,0 +1,73 @@
+package spamfilter
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// FromEnv builds the filter from the built-in flagged words and environment
+// variables:
+//
+//	CONTENT_BLOCKED_WORDS_FILE     words and phrases that are refused outright
+//	CONTENT_FLAGGED_WORDS_FILE     more words that send content to moderation
+//	CONTENT_NEW_ACCOUNT_DAYS       how long an account counts as new (default 3)
+//	CONTENT_NEW_ACCOUNT_MAX_LINKS  links a new account may post at once (default 2)
+//	CONTENT_SPAM_THRESHOLD         classifier score that flags content (default 0.9)
+//
+// On an error the filter is returned with what could be loaded.
+func FromEnv() (Filter, error) {
+	f := Filter{NewAccountAge: 3 * 24 * time.Hour, MaxLinks: 2, SpamThreshold: 0.9}
+	var errs []string
+
+	f.Flagged, _ = ParseWordList(strings.NewReader(defaultFlaggedWords))
+	for _, list := range []struct {
+		env    string
+		phrase *[]string
+	}{{"CONTENT_BLOCKED_WORDS_FILE", &f.Blocked}, {"CONTENT_FLAGGED_WORDS_FILE", &f.Flagged}} {
+		path := os.Getenv(list.env)
+		if path == "" {
+			continue
+		}
+		file, err := os.Open(path)
+		if err != nil {
+			errs = append(errs, fmt.Sprintf("%s: %v", list.env, err))
+			continue
+		}
+		phrases, err := ParseWordList(file)
+		file.Close()
+		if err != nil {
+			errs = append(errs, fmt.Sprintf("%s: %v", list.env, err))
+		}
+		*list.phrase = append(*list.phrase, phrases...)
+	}
+
+	if value := os.Getenv("CONTENT_NEW_ACCOUNT_DAYS"); value != "" {
+		if days, err := strconv.ParseFloat(value, 64); err == nil && days >= 0 {
+			f.NewAccountAge = time.Duration(days * float64(24*time.Hour))
+		} else {
+			errs = append(errs, fmt.Sprintf("CONTENT_NEW_ACCOUNT_DAYS: %q is not a number of days", value))
+		}
+	}
+	if value := os.Getenv("CONTENT_NEW_ACCOUNT_MAX_LINKS"); value != "" {
+		if n, err := strconv.Atoi(value); err == nil && n >= 0 {
+			f.MaxLinks = n
+		} else {
+			errs = append(errs, fmt.Sprintf("CONTENT_NEW_ACCOUNT_MAX_LINKS: %q is not a number", value))
+		}
+	}
+	if value := os.Getenv("CONTENT_SPAM_THRESHOLD"); value != "" {
+		if p, err := strconv.ParseFloat(value, 64); err == nil && p > 0 && p <= 1 {
+			f.SpamThreshold = p
+		} else {
+			errs = append(errs, fmt.Sprintf("CONTENT_SPAM_THRESHOLD: %q is not a probability", value))
+		}
+	}
+
+	if len(errs) > 0 {
+		return f, fmt.Errorf("%s", strings.Join(errs, "; "))
+	}
+	return f, nil
+}
@@ -0,0 +1,156 @@
+// Package spamfilter screens posts and comments for spam and abuse: blocked
+// and flagged word lists matched after normalisation, a link limit for new
+// accounts, repeated content and a naive Bayes classifier trained from
+// moderator decisions.
+package spamfilter
+
+import (
+	"bufio"
+	_ "embed"
+	"fmt"
+	"html"
+	"io"
+	"math"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+)
+
+//go:embed flagged_words.txt
+var defaultFlaggedWords string
+
+// Action is what should happen to a piece of content
+type Action int
+
+const (
+	// Allow publishes the content
+	Allow Action = iota
+	// Flag holds the content in the moderation queue
+	Flag
+	// Block refuses the content
+	Block
+)
+
+// Verdict is the outcome of Check, with the reasons for flagging or blocking
+type Verdict struct {
+	Action    Action
+	Reasons   []string
+	SpamScore float64 // -1 until the classifier has been trained
+}
+
+// Filter holds the word lists and thresholds content is checked against
+type Filter struct {
+	Blocked       []string // normalised phrases, a trailing * matches a prefix
+	Flagged       []string
+	NewAccountAge time.Duration // accounts younger than this are "new"
+	MaxLinks      int           // links a new account may post at once
+	SpamThreshold float64       // spam probability that flags content
+}
+
+// DuplicateWindow is how long posted text is remembered for Input.Repeats
+// and Input.Copies
+const DuplicateWindow = 24 * time.Hour
+
+// Input is a piece of content with what is known about its author
+type Input struct {
+	Text       string
+	AccountAge time.Duration
+	Repeats    int // times the author posted the same text recently
+	Copies     int // other authors who posted the same text recently
+	Model      Model
+}
+
+var linkPattern = regexp.MustCompile(`(?i)\b(?:https?://|www\.)[^\s<>"']+`)
+
+// CountLinks counts the URLs in text
+func CountLinks(text string) int {
+	return len(linkPattern.FindAllString(html.UnescapeString(text), -1))
+}
+
+// Check decides whether content is published, held for moderation or refused
+func (f Filter) Check(in Input) Verdict {
+	verdict := Verdict{Action: Allow, SpamScore: -1}
+	flag := func(reason string) {
+		verdict.Action = max(verdict.Action, Flag)
+		verdict.Reasons = append(verdict.Reasons, reason)
+	}
+
+	normalized := " " + Normalize(in.Text) + " "
+	for _, phrase := range f.Blocked {
+		if containsPhrase(normalized, phrase) {
+			verdict.Action = Block
+			verdict.Reasons = append(verdict.Reasons, "blocked word: "+phrase)
+		}
+	}
+	for _, phrase := range f.Flagged {
+		if containsPhrase(normalized, phrase) {
+			flag("flagged word: " + phrase)
+		}
+	}
+
+	if in.AccountAge < f.NewAccountAge {
+		if links := CountLinks(in.Text); links > f.MaxLinks {
+			flag(fmt.Sprintf("%d links from an account younger than %s", links, formatAge(f.NewAccountAge)))
+		}
+	}
+
+	if in.Repeats > 0 {
+		flag("the author posted the same text recently")
+	}
+	if in.Copies >= 2 {
+		flag(fmt.Sprintf("the same text was posted by %d other users", in.Copies))
+	}
+
+	if p, ok := in.Model.SpamProbability(Tokens(in.Text)); ok {
+		verdict.SpamScore = p
+		if p >= f.SpamThreshold {
+			flag(fmt.Sprintf("spam probability %.2f", p))
+		}
+	}
+	return verdict
+}
+
+// containsPhrase matches a normalised phrase on word boundaries in
+// normalized, which is padded with spaces
+func containsPhrase(normalized, phrase string) bool {
+	if prefix, ok := strings.CutSuffix(phrase, "*"); ok {
+		return strings.Contains(normalized, " "+prefix)
+	}
+	return strings.Contains(normalized, " "+phrase+" ")
+}
+
+func formatAge(d time.Duration) string {
+	if days := d.Hours() / 24; days >= 1 {
+		return fmt.Sprintf("%g days", math.Round(days*10)/10)
+	}
+	return d.String()
+}
+
+// ParseWordList reads one word or phrase per line, skipping blanks and #
+// comments, and normalises each the way content is
+func ParseWordList(r io.Reader) ([]string, error) {
+	var phrases []string
+	seen := map[string]bool{}
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		prefix, wildcard := strings.CutSuffix(line, "*")
+		phrase := Normalize(prefix)
+		if phrase == "" {
+			continue
+		}
+		if wildcard {
+			phrase += "*"
+		}
+		if !seen[phrase] {
+			seen[phrase] = true
+			phrases = append(phrases, phrase)
+		}
+	}
+	sort.Strings(phrases)
+	return phrases, scanner.Err()
+}
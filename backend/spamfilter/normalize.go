@@ -0,0 +1,142 @@
+package spamfilter
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"html"
+	"strings"
+	"unicode"
+)
+
+// confusables maps characters that look like Latin letters, as used to dodge
+// word filters ("vіagra" with a Cyrillic і), to the letter they imitate
+var confusables = map[rune]rune{
+	// Cyrillic
+	'а': 'a', 'в': 'b', 'с': 'c', 'е': 'e', 'ё': 'e', 'н': 'h', 'і': 'i', 'ї': 'i', 'ј': 'j',
+	'к': 'k', 'м': 'm', 'о': 'o', 'р': 'p', 'ѕ': 's', 'т': 't', 'у': 'y', 'х': 'x', 'ԁ': 'd',
+	'ɡ': 'g', 'һ': 'h', 'ԛ': 'q', 'ԝ': 'w',
+	// Greek
+	'α': 'a', 'β': 'b', 'ε': 'e', 'η': 'n', 'ι': 'i', 'κ': 'k', 'ν': 'v', 'ο': 'o', 'ρ': 'p',
+	'τ': 't', 'υ': 'u', 'χ': 'x', 'ω': 'w',
+	// Latin letters with diacritics
+	'à': 'a', 'á': 'a', 'â': 'a', 'ã': 'a', 'ä': 'a', 'å': 'a', 'ā': 'a', 'ç': 'c', 'è': 'e',
+	'é': 'e', 'ê': 'e', 'ë': 'e', 'ē': 'e', 'ì': 'i', 'í': 'i', 'î': 'i', 'ï': 'i', 'ı': 'i',
+	'ñ': 'n', 'ò': 'o', 'ó': 'o', 'ô': 'o', 'õ': 'o', 'ö': 'o', 'ø': 'o', 'ù': 'u', 'ú': 'u',
+	'û': 'u', 'ü': 'u', 'ý': 'y', 'ÿ': 'y', 'ß': 's',
+}
+
+// leet maps digits and symbols standing in for letters; it is only applied
+// to words that also contain letters, so plain numbers stay numbers
+var leet = map[rune]rune{'0': 'o', '1': 'i', '3': 'e', '4': 'a', '5': 's', '7': 't', '8': 'b', '@': 'a', '$': 's'}
+
+// fold lowercases r and undoes fullwidth forms and confusables
+func fold(r rune) rune {
+	if r >= '！' && r <= '～' { // fullwidth ASCII
+		r -= 0xFEE0
+	}
+	r = unicode.ToLower(r)
+	if c, ok := confusables[r]; ok {
+		return c
+	}
+	return r
+}
+
+// Words splits text into normalised words: HTML entities decoded, invisible
+// characters dropped, case, confusables and leetspeak folded, and letters
+// spelled out one at a time ("v.i.a.g.r.a") joined back together
+func Words(text string) []string {
+	text = html.UnescapeString(text)
+
+	var words []string
+	var word []rune
+	flush := func() {
+		if len(word) == 0 {
+			return
+		}
+		hasLetter := false
+		for _, r := range word {
+			hasLetter = hasLetter || unicode.IsLetter(r)
+		}
+		out := make([]rune, 0, len(word))
+		for _, r := range word {
+			if hasLetter {
+				if l, ok := leet[r]; ok {
+					r = l
+				}
+			}
+			if unicode.IsLetter(r) || unicode.IsDigit(r) {
+				out = append(out, r)
+			}
+		}
+		if len(out) > 0 {
+			words = append(words, string(out))
+		}
+		word = word[:0]
+	}
+
+	for _, r := range text {
+		if unicode.Is(unicode.Cf, r) || unicode.Is(unicode.Mn, r) {
+			continue // zero-width characters and combining accents
+		}
+		r = fold(r)
+		if unicode.IsLetter(r) || unicode.IsDigit(r) || r == '@' || r == '$' {
+			word = append(word, r)
+			continue
+		}
+		flush()
+	}
+	flush()
+	return joinSpelledOut(words)
+}
+
+// joinSpelledOut merges runs of three or more single-letter words
+func joinSpelledOut(words []string) []string {
+	joined := make([]string, 0, len(words))
+	for i := 0; i < len(words); {
+		j := i
+		for j < len(words) && len([]rune(words[j])) == 1 {
+			j++
+		}
+		if j-i >= 3 {
+			joined = append(joined, strings.Join(words[i:j], ""))
+			i = j
+			continue
+		}
+		joined = append(joined, words[i])
+		i++
+	}
+	return joined
+}
+
+// Normalize returns the normalised words of text separated by single spaces
+func Normalize(text string) string {
+	return strings.Join(Words(text), " ")
+}
+
+// Tokens returns the distinct words the classifier learns from
+func Tokens(text string) []string {
+	seen := map[string]bool{}
+	var tokens []string
+	for _, w := range Words(text) {
+		if n := len([]rune(w)); n < 2 || n > 30 || seen[w] {
+			continue
+		}
+		seen[w] = true
+		tokens = append(tokens, w)
+	}
+	return tokens
+}
+
+// Fingerprint identifies text regardless of case, spacing and punctuation,
+// for spotting the same message posted again. It is empty for short texts,
+// which are repeated innocently ("thanks!").
+func Fingerprint(text string) string {
+	normalized := Normalize(text)
+	if len(normalized) < minFingerprintLength {
+		return ""
+	}
+	sum := sha256.Sum256([]byte(normalized))
+	return hex.EncodeToString(sum[:])
+}
+
+const minFingerprintLength = 20
@@ -0,0 +1,95 @@
+package spamfilter
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestNormalize(t *testing.T) {
+	tests := []struct {
+		input, expected string
+	}{
+		{"Buy VIAGRA now!", "buy viagra now"},
+		{"v1agr@", "viagra"},
+		{"vіagra", "viagra"},  // Cyrillic і
+		{"ＶＩＡＧＲＡ", "viagra"},  // fullwidth
+		{"via​gra", "viagra"}, // zero-width space
+		{"v.i.a.g.r.a or v i a g r a", "viagra or viagra"},
+		{"café crème", "cafe creme"},
+		{"Call 555-0100 &amp; ask", "call 555 0100 ask"},
+		{"I am 3 years old", "i am 3 years old"}, // numbers on their own stay
+	}
+	for _, tt := range tests {
+		if got := Normalize(tt.input); got != tt.expected {
+			t.Errorf("Normalize(%q) = %q, expected %q", tt.input, got, tt.expected)
+		}
+	}
+}
+
+func TestFingerprint(t *testing.T) {
+	a := Fingerprint("Check out my GREAT new website today")
+	b := Fingerprint("check out my great new website, today!!")
+	if a == "" || a != b {
+		t.Errorf("Expected equal fingerprints, got %q and %q", a, b)
+	}
+	if Fingerprint("thanks!") != "" {
+		t.Error("Expected no fingerprint for short texts")
+	}
+}
+
+func TestCheck(t *testing.T) {
+	blocked, _ := ParseWordList(strings.NewReader("# slurs and such\nbadword\n"))
+	flagged, _ := ParseWordList(strings.NewReader("casino*\nfree money\n"))
+	f := Filter{Blocked: blocked, Flagged: flagged, NewAccountAge: 72 * time.Hour, MaxLinks: 1, SpamThreshold: 0.9}
+	old := 30 * 24 * time.Hour
+
+	tests := []struct {
+		name     string
+		input    Input
+		expected Action
+	}{
+		{"clean", Input{Text: "How do I configure nginx?", AccountAge: old}, Allow},
+		{"blocked", Input{Text: "you B4DW0RD", AccountAge: old}, Block},
+		{"flagged prefix", Input{Text: "Best C@SINOS online", AccountAge: old}, Flag},
+		{"flagged phrase", Input{Text: "get free   money here", AccountAge: old}, Flag},
+		{"no partial words", Input{Text: "freedom money", AccountAge: old}, Allow},
+		{"links from new account", Input{Text: "see https://a.example and www.b.example", AccountAge: time.Hour}, Flag},
+		{"links from old account", Input{Text: "see https://a.example and www.b.example", AccountAge: old}, Allow},
+		{"repeated", Input{Text: "same thing again", AccountAge: old, Repeats: 1}, Flag},
+		{"copied by others", Input{Text: "same thing again", AccountAge: old, Copies: 2}, Flag},
+	}
+	for _, tt := range tests {
+		verdict := f.Check(tt.input)
+		if verdict.Action != tt.expected {
+			t.Errorf("%s: expected action %d, got %d (%v)", tt.name, tt.expected, verdict.Action, verdict.Reasons)
+		}
+		if tt.expected != Allow && len(verdict.Reasons) == 0 {
+			t.Errorf("%s: expected reasons", tt.name)
+		}
+	}
+}
+
+func TestSpamProbability(t *testing.T) {
+	model := Model{SpamDocuments: 20, HamDocuments: 20, Tokens: map[string]TokenCount{
+		"pills":  {Spam: 18, Ham: 0},
+		"cheap":  {Spam: 15, Ham: 2},
+		"golang": {Spam: 0, Ham: 12},
+		"help":   {Spam: 3, Ham: 9},
+	}}
+
+	if p, ok := model.SpamProbability([]string{"cheap", "pills"}); !ok || p < 0.99 {
+		t.Errorf("Expected spam, got %.3f", p)
+	}
+	if p, _ := model.SpamProbability([]string{"golang", "help"}); p > 0.05 {
+		t.Errorf("Expected ham, got %.3f", p)
+	}
+	if p, _ := model.SpamProbability([]string{"unknown"}); p != 0.5 {
+		t.Errorf("Expected unknown tokens to be neutral, got %.3f", p)
+	}
+
+	untrained := Model{SpamDocuments: 2, HamDocuments: 50}
+	if _, ok := untrained.SpamProbability([]string{"pills"}); ok {
+		t.Error("Expected no score before enough training")
+	}
+}
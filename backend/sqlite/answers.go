@@ -0,0 +1,103 @@
+package sqlite
+
+import (
+	"database/sql"
+	"errors"
+	"time"
+
+	"forum/models"
+)
+
+// ErrNotAnAnswer is returned when accepting content that isn't a published
+// comment or reply on the post
+var ErrNotAnAnswer = errors.New("not a published comment or reply on this post")
+
+// AcceptAnswer marks a comment or reply on a post as its accepted answer,
+// replacing any answer accepted before. The answer's author gains
+// reputation unless they asked the question. It returns the answer's
+// author.
+func AcceptAnswer(db *sql.DB, postID int, contentType string, contentID int) (string, error) {
+	tx, err := db.Begin()
+	if err != nil {
+		return "", err
+	}
+	defer tx.Rollback()
+
+	query := `
+		SELECT c.user_id FROM comments c
+		WHERE c.id = ? AND c.post_id = ? AND c.status = 'published'
+	`
+	if contentType == models.ContentReply {
+		query = `
+			SELECT r.user_id FROM replycomments r
+			JOIN comments c ON c.id = r.parent_comment_id
+			WHERE r.id = ? AND c.post_id = ? AND r.status = 'published' AND c.status = 'published'
+		`
+	}
+	var answererID string
+	err = tx.QueryRow(query, contentID, postID).Scan(&answererID)
+	if err == sql.ErrNoRows {
+		return "", ErrNotAnAnswer
+	}
+	if err != nil {
+		return "", err
+	}
+
+	if err := clearAcceptedAnswer(tx, postID); err != nil {
+		return "", err
+	}
+	var commentID, replyID *int
+	if contentType == models.ContentReply {
+		replyID = &contentID
+	} else {
+		commentID = &contentID
+	}
+	if _, err := tx.Exec(`
+		UPDATE posts SET accepted_comment_id = ?, accepted_reply_id = ? WHERE id = ?
+	`, commentID, replyID, postID); err != nil {
+		return "", err
+	}
+
+	var askerID string
+	if err := tx.QueryRow(`SELECT user_id FROM posts WHERE id = ?`, postID).Scan(&askerID); err != nil {
+		return "", err
+	}
+	if answererID != askerID {
+		err := awardReputation(tx, answererID, models.ReputationAcceptedAnswer, contentType, contentID, askerID, models.AcceptedAnswerPoints, time.Now())
+		if err != nil {
+			return "", err
+		}
+	}
+	return answererID, tx.Commit()
+}
+
+// ClearAcceptedAnswer leaves a post without an accepted answer
+func ClearAcceptedAnswer(db *sql.DB, postID int) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := clearAcceptedAnswer(tx, postID); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// clearAcceptedAnswer unmarks a post's accepted answer and takes back the
+// reputation it earned
+func clearAcceptedAnswer(tx *sql.Tx, postID int) error {
+	_, err := tx.Exec(`
+		DELETE FROM reputation_events
+		WHERE reason = ? AND (
+			(content_type = 'comment' AND content_id = (SELECT accepted_comment_id FROM posts WHERE id = ?))
+			OR (content_type = 'reply' AND content_id = (SELECT accepted_reply_id FROM posts WHERE id = ?))
+		)
+	`, models.ReputationAcceptedAnswer, postID, postID)
+	if err != nil {
+		return err
+	}
+	_, err = tx.Exec(`UPDATE posts SET accepted_comment_id = NULL, accepted_reply_id = NULL WHERE id = ?`, postID)
+	return err
+}
@@ -0,0 +1,149 @@
+package sqlite
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/base64"
+	"encoding/hex"
+	"strings"
+	"time"
+
+	"forum/models"
+)
+
+// APITokenPrefix starts every API token so leaked tokens are easy to recognise
+const APITokenPrefix = "forum_"
+
+// apiTokenDisplayLength is how much of a token is kept to tell tokens apart
+const apiTokenDisplayLength = len(APITokenPrefix) + 6
+
+// apiTokenTouchInterval limits how often last_used_at is written for a busy token
+const apiTokenTouchInterval = time.Minute
+
+// hashAPIToken returns the stored form of a token. Tokens are random, so an
+// unsalted hash is enough to make a leaked database useless.
+func hashAPIToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// CreateAPIToken generates a token for a user and returns its plaintext,
+// which is never stored and cannot be retrieved again
+func CreateAPIToken(db *sql.DB, userID, name string, scopes []string, expiresAt time.Time) (string, models.APIToken, error) {
+	raw := make([]byte, 32)
+	if _, err := rand.Read(raw); err != nil {
+		return "", models.APIToken{}, err
+	}
+	token := APITokenPrefix + base64.RawURLEncoding.EncodeToString(raw)
+
+	created, err := scanAPIToken(db.QueryRow(`
+		INSERT INTO api_tokens (user_id, name, token_hash, prefix, scopes, expires_at)
+		VALUES (?, ?, ?, ?, ?, ?)
+		RETURNING id, user_id, name, prefix, scopes, expires_at, last_used_at, created_at
+	`, userID, name, hashAPIToken(token), token[:apiTokenDisplayLength], strings.Join(scopes, " "), expiresAt.UTC()).Scan)
+	if err != nil {
+		return "", created, err
+	}
+	return token, created, nil
+}
+
+func scanAPIToken(scan func(dest ...any) error) (models.APIToken, error) {
+	var t models.APIToken
+	var scopes string
+	var lastUsed sql.NullTime
+	err := scan(&t.ID, &t.UserID, &t.Name, &t.Prefix, &scopes, &t.ExpiresAt, &lastUsed, &t.CreatedAt)
+	t.Scopes = strings.Fields(scopes)
+	if lastUsed.Valid {
+		t.LastUsedAt = &lastUsed.Time
+	}
+	return t, err
+}
+
+// GetAPIToken looks up a token by its plaintext. Unknown and expired tokens
+// are returned as sql.ErrNoRows.
+func GetAPIToken(db *sql.DB, token string) (models.APIToken, error) {
+	if !strings.HasPrefix(token, APITokenPrefix) {
+		return models.APIToken{}, sql.ErrNoRows
+	}
+	t, err := scanAPIToken(db.QueryRow(`
+		SELECT id, user_id, name, prefix, scopes, expires_at, last_used_at, created_at
+		FROM api_tokens WHERE token_hash = ?
+	`, hashAPIToken(token)).Scan)
+	if err != nil {
+		return t, err
+	}
+	if !time.Now().Before(t.ExpiresAt) {
+		return t, sql.ErrNoRows
+	}
+	return t, nil
+}
+
+// GetAPITokens lists a user's tokens, newest first
+func GetAPITokens(db *sql.DB, userID string) ([]models.APIToken, error) {
+	rows, err := db.Query(`
+		SELECT id, user_id, name, prefix, scopes, expires_at, last_used_at, created_at
+		FROM api_tokens WHERE user_id = ?
+		ORDER BY created_at DESC, id DESC
+	`, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	tokens := []models.APIToken{}
+	for rows.Next() {
+		t, err := scanAPIToken(rows.Scan)
+		if err != nil {
+			return nil, err
+		}
+		tokens = append(tokens, t)
+	}
+	return tokens, rows.Err()
+}
+
+// CountAPITokens returns how many tokens a user has
+func CountAPITokens(db *sql.DB, userID string) (int, error) {
+	var count int
+	err := db.QueryRow(`SELECT COUNT(*) FROM api_tokens WHERE user_id = ?`, userID).Scan(&count)
+	return count, err
+}
+
+// TouchAPIToken records that a token was used. Writes are skipped if it was
+// already recorded within the last minute.
+func TouchAPIToken(db *sql.DB, id int64) error {
+	now := time.Now().UTC()
+	_, err := db.Exec(`
+		UPDATE api_tokens SET last_used_at = ?
+		WHERE id = ? AND (last_used_at IS NULL OR last_used_at < ?)
+	`, now, id, now.Add(-apiTokenTouchInterval))
+	return err
+}
+
+// RevokeAPIToken deletes one of a user's tokens. sql.ErrNoRows means the
+// user has no token with that ID.
+func RevokeAPIToken(db *sql.DB, userID string, id int64) error {
+	result, err := db.Exec(`DELETE FROM api_tokens WHERE id = ? AND user_id = ?`, id, userID)
+	if err != nil {
+		return err
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+// RevokeAllAPITokens deletes every token of a user and returns how many there were
+func RevokeAllAPITokens(db *sql.DB, userID string) (int64, error) {
+	result, err := db.Exec(`DELETE FROM api_tokens WHERE user_id = ?`, userID)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+// CleanupAPITokens removes expired tokens
+func CleanupAPITokens(db *sql.DB) error {
+	_, err := db.Exec(`DELETE FROM api_tokens WHERE expires_at <= ?`, time.Now().UTC())
+	return err
+}
@@ -0,0 +1,179 @@
+package sqlite
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+
+	"forum/badges"
+	"forum/models"
+)
+
+// badgeMetrics select each user's value of a badge metric as (user_id,
+// value). Users without any activity may be missing.
+var badgeMetrics = map[string]string{
+	badges.MetricPosts: `
+		SELECT user_id, COUNT(*) AS value FROM posts
+		WHERE status = 'published' GROUP BY user_id`,
+	badges.MetricComments: `
+		SELECT user_id, COUNT(*) AS value FROM (
+			SELECT user_id FROM comments WHERE status = 'published'
+			UNION ALL
+			SELECT user_id FROM replycomments WHERE status = 'published'
+		) GROUP BY user_id`,
+	badges.MetricAcceptedAnswers: `
+		SELECT user_id, COUNT(*) AS value FROM (
+			SELECT c.user_id FROM posts p JOIN comments c ON c.id = p.accepted_comment_id
+			WHERE c.user_id != p.user_id
+			UNION ALL
+			SELECT r.user_id FROM posts p JOIN replycomments r ON r.id = p.accepted_reply_id
+			WHERE r.user_id != p.user_id
+		) GROUP BY user_id`,
+	badges.MetricLikesReceived: `
+		SELECT author_id AS user_id, COUNT(*) AS value FROM (
+			SELECT p.user_id AS author_id FROM likes l JOIN posts p ON p.id = l.post_id
+			WHERE l.type = 'like' AND l.user_id != p.user_id
+			UNION ALL
+			SELECT c.user_id FROM likes l JOIN comments c ON c.id = l.comment_id
+			WHERE l.type = 'like' AND l.user_id != c.user_id
+		) GROUP BY author_id`,
+	badges.MetricReputation: `
+		SELECT id AS user_id, reputation AS value FROM users`,
+	badges.MetricMemberDays: `
+		SELECT id AS user_id, CAST(julianday('now') - julianday(created_at) AS INTEGER) AS value FROM users`,
+}
+
+// AwardBadges awards the badges whose rules active users meet and returns
+// how many were awarded. With a userID only that user is checked. Badges
+// already held are kept, even once the user no longer meets the rule.
+func AwardBadges(db *sql.DB, rules []badges.Rule, userID string) (int, error) {
+	tx, err := db.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	awarded := 0
+	for _, rule := range rules {
+		metric, ok := badgeMetrics[rule.Metric]
+		if !ok {
+			return 0, fmt.Errorf("badge %q: unknown metric %q", rule.ID, rule.Metric)
+		}
+		query := fmt.Sprintf(`
+			INSERT OR IGNORE INTO badge_awards (user_id, badge_id)
+			SELECT m.user_id, ? FROM (%s) m
+			JOIN users u ON u.id = m.user_id
+			WHERE u.status = 'active' AND m.value >= ?
+		`, metric)
+		args := []any{rule.ID, rule.Threshold}
+		if userID != "" {
+			query += ` AND m.user_id = ?`
+			args = append(args, userID)
+		}
+		result, err := tx.Exec(query, args...)
+		if err != nil {
+			return 0, err
+		}
+		n, err := result.RowsAffected()
+		if err != nil {
+			return 0, err
+		}
+		awarded += int(n)
+	}
+	return awarded, tx.Commit()
+}
+
+// TakeBadgeNotifications returns the badges awarded that nobody was
+// notified of yet and marks them notified
+func TakeBadgeNotifications(db *sql.DB) ([]models.BadgeAward, error) {
+	tx, err := db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	rows, err := tx.Query(`
+		SELECT b.user_id, u.username, b.badge_id, b.awarded_at
+		FROM badge_awards b
+		JOIN users u ON u.id = b.user_id
+		WHERE b.notified = 0
+		ORDER BY b.awarded_at, b.badge_id
+	`)
+	if err != nil {
+		return nil, err
+	}
+	awards := []models.BadgeAward{}
+	for rows.Next() {
+		var a models.BadgeAward
+		if err := rows.Scan(&a.UserID, &a.Username, &a.BadgeID, &a.AwardedAt); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		awards = append(awards, a)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	if _, err := tx.Exec(`UPDATE badge_awards SET notified = 1 WHERE notified = 0`); err != nil {
+		return nil, err
+	}
+	return awards, tx.Commit()
+}
+
+// GetUserBadges returns when a user earned each of their badges, by badge
+// ID
+func GetUserBadges(db *sql.DB, userID string) (map[string]time.Time, error) {
+	rows, err := db.Query(`SELECT badge_id, awarded_at FROM badge_awards WHERE user_id = ?`, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	awarded := map[string]time.Time{}
+	for rows.Next() {
+		var badgeID string
+		var at time.Time
+		if err := rows.Scan(&badgeID, &at); err != nil {
+			return nil, err
+		}
+		awarded[badgeID] = at
+	}
+	return awarded, rows.Err()
+}
+
+// CountBadgeHolders returns how many users hold each badge, by badge ID
+func CountBadgeHolders(db *sql.DB) (map[string]int, error) {
+	rows, err := db.Query(`SELECT badge_id, COUNT(*) FROM badge_awards GROUP BY badge_id`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	holders := map[string]int{}
+	for rows.Next() {
+		var badgeID string
+		var count int
+		if err := rows.Scan(&badgeID, &count); err != nil {
+			return nil, err
+		}
+		holders[badgeID] = count
+	}
+	return holders, rows.Err()
+}
+
+// ContentAuthor returns the author of a post, comment or reply
+func ContentAuthor(db *sql.DB, contentType string, contentID int) (string, error) {
+	table := map[string]string{
+		models.ContentPost:    "posts",
+		models.ContentComment: "comments",
+		models.ContentReply:   "replycomments",
+	}[contentType]
+	if table == "" {
+		return "", fmt.Errorf("unknown content type %q", contentType)
+	}
+	var authorID string
+	err := db.QueryRow(fmt.Sprintf(`SELECT user_id FROM %s WHERE id = ?`, table), contentID).Scan(&authorID)
+	return authorID, err
+}
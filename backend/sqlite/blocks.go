@@ -0,0 +1,210 @@
+package sqlite
+
+import (
+	"database/sql"
+	"fmt"
+
+	"forum/models"
+)
+
+// notBlockedBy returns a condition on the aliased posts, comments or
+// replycomments table, and its arguments, that drops content by users the
+// viewer blocked
+func notBlockedBy(alias string, viewer models.Viewer) (string, []any) {
+	if viewer.UserID == "" {
+		return "1 = 1", nil
+	}
+	return fmt.Sprintf("%s.user_id NOT IN (SELECT blocked_id FROM user_blocks WHERE blocker_id = ?)", alias),
+		[]any{viewer.UserID}
+}
+
+// notMutedBy is like notBlockedBy, and also drops content by users the
+// viewer muted, for lists and feeds
+func notMutedBy(alias string, viewer models.Viewer) (string, []any) {
+	if viewer.UserID == "" {
+		return "1 = 1", nil
+	}
+	return fmt.Sprintf(`%s.user_id NOT IN (
+			SELECT blocked_id FROM user_blocks WHERE blocker_id = ?
+			UNION SELECT muted_id FROM user_mutes WHERE user_id = ?
+		)`, alias), []any{viewer.UserID, viewer.UserID}
+}
+
+// notInMutedCategories returns a condition on the aliased posts table, and
+// its arguments, that drops posts in categories the viewer muted
+func notInMutedCategories(alias string, viewer models.Viewer) (string, []any) {
+	if viewer.UserID == "" {
+		return "1 = 1", nil
+	}
+	return fmt.Sprintf(`NOT EXISTS (
+			SELECT 1 FROM post_categories pc
+			JOIN category_mutes cm ON cm.category_id = pc.category_id
+			WHERE pc.post_id = %s.id AND cm.user_id = ?
+		)`, alias), []any{viewer.UserID}
+}
+
+// BlockUser makes a user block another. Follows between them in either
+// direction are removed. created reports whether the block is new.
+func BlockUser(db *sql.DB, blockerID, blockedID string) (created bool, err error) {
+	tx, err := db.Begin()
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback()
+
+	result, err := tx.Exec(`INSERT OR IGNORE INTO user_blocks (blocker_id, blocked_id) VALUES (?, ?)`, blockerID, blockedID)
+	if err != nil {
+		return false, err
+	}
+	n, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	_, err = tx.Exec(`
+		DELETE FROM user_follows
+		WHERE (follower_id = ? AND followed_id = ?) OR (follower_id = ? AND followed_id = ?)
+	`, blockerID, blockedID, blockedID, blockerID)
+	if err != nil {
+		return false, err
+	}
+	return n > 0, tx.Commit()
+}
+
+// UnblockUser lifts a block. Not blocking the user is sql.ErrNoRows.
+func UnblockUser(db *sql.DB, blockerID, blockedID string) error {
+	result, err := db.Exec(`DELETE FROM user_blocks WHERE blocker_id = ? AND blocked_id = ?`, blockerID, blockedID)
+	return expectDeleted(result, err)
+}
+
+// BlockedBetween reports whether either of two users blocked the other
+func BlockedBetween(db *sql.DB, userID, otherID string) (bool, error) {
+	var blocked bool
+	err := db.QueryRow(`
+		SELECT EXISTS(
+			SELECT 1 FROM user_blocks
+			WHERE (blocker_id = ? AND blocked_id = ?) OR (blocker_id = ? AND blocked_id = ?)
+		)
+	`, userID, otherID, otherID, userID).Scan(&blocked)
+	return blocked, err
+}
+
+// MuteUser hides a user's content from another's lists and feeds
+func MuteUser(db *sql.DB, userID, mutedID string) (created bool, err error) {
+	result, err := db.Exec(`INSERT OR IGNORE INTO user_mutes (user_id, muted_id) VALUES (?, ?)`, userID, mutedID)
+	if err != nil {
+		return false, err
+	}
+	n, err := result.RowsAffected()
+	return n > 0, err
+}
+
+// UnmuteUser lifts a mute. Not muting the user is sql.ErrNoRows.
+func UnmuteUser(db *sql.DB, userID, mutedID string) error {
+	result, err := db.Exec(`DELETE FROM user_mutes WHERE user_id = ? AND muted_id = ?`, userID, mutedID)
+	return expectDeleted(result, err)
+}
+
+// MuteCategory hides the posts in a category from a user's lists and
+// feeds. An unknown category is sql.ErrNoRows.
+func MuteCategory(db *sql.DB, userID string, categoryID int) (created bool, err error) {
+	if err := categoryExists(db, categoryID); err != nil {
+		return false, err
+	}
+	result, err := db.Exec(`INSERT OR IGNORE INTO category_mutes (user_id, category_id) VALUES (?, ?)`, userID, categoryID)
+	if err != nil {
+		return false, err
+	}
+	n, err := result.RowsAffected()
+	return n > 0, err
+}
+
+// UnmuteCategory lifts a category mute. Not muting it is sql.ErrNoRows.
+func UnmuteCategory(db *sql.DB, userID string, categoryID int) error {
+	result, err := db.Exec(`DELETE FROM category_mutes WHERE user_id = ? AND category_id = ?`, userID, categoryID)
+	return expectDeleted(result, err)
+}
+
+// GetUserRelation returns whether a user follows, blocked and muted another
+func GetUserRelation(db *sql.DB, userID, otherID string) (following, blocked, muted bool, err error) {
+	err = db.QueryRow(`
+		SELECT
+			EXISTS(SELECT 1 FROM user_follows WHERE follower_id = ? AND followed_id = ?),
+			EXISTS(SELECT 1 FROM user_blocks WHERE blocker_id = ? AND blocked_id = ?),
+			EXISTS(SELECT 1 FROM user_mutes WHERE user_id = ? AND muted_id = ?)
+	`, userID, otherID, userID, otherID, userID, otherID).Scan(&following, &blocked, &muted)
+	return
+}
+
+// GetBlockedUsers returns the users a user blocked, most recent first
+func GetBlockedUsers(db *sql.DB, userID string) ([]models.HiddenUser, error) {
+	return queryHiddenUsers(db, `
+		SELECT u.username, COALESCE(u.avatar_url, ''), b.created_at
+		FROM user_blocks b
+		JOIN users u ON u.id = b.blocked_id
+		WHERE b.blocker_id = ?
+		ORDER BY b.created_at DESC, u.username
+	`, userID)
+}
+
+// GetMutedUsers returns the users a user muted, most recent first
+func GetMutedUsers(db *sql.DB, userID string) ([]models.HiddenUser, error) {
+	return queryHiddenUsers(db, `
+		SELECT u.username, COALESCE(u.avatar_url, ''), m.created_at
+		FROM user_mutes m
+		JOIN users u ON u.id = m.muted_id
+		WHERE m.user_id = ?
+		ORDER BY m.created_at DESC, u.username
+	`, userID)
+}
+
+func queryHiddenUsers(db *sql.DB, query string, args ...any) ([]models.HiddenUser, error) {
+	rows, err := db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	users := []models.HiddenUser{}
+	for rows.Next() {
+		var u models.HiddenUser
+		if err := rows.Scan(&u.Username, &u.AvatarURL, &u.CreatedAt); err != nil {
+			return nil, err
+		}
+		users = append(users, u)
+	}
+	return users, rows.Err()
+}
+
+// GetMutedCategories returns the categories a user muted, by name
+func GetMutedCategories(db *sql.DB, userID string) ([]models.Category, error) {
+	return queryCategories(db, `
+		SELECT c.id, c.name FROM category_mutes m
+		JOIN categories c ON c.id = m.category_id
+		WHERE m.user_id = ?
+		ORDER BY c.name
+	`, userID)
+}
+
+// BlockedFromReplying reports whether a user was blocked by the author of
+// the post they want to comment on (contentType post) or by the author of
+// the comment they want to reply to or of its post (contentType comment)
+func BlockedFromReplying(db *sql.DB, userID, contentType string, contentID int) (bool, error) {
+	query := `
+		SELECT EXISTS(
+			SELECT 1 FROM posts p
+			JOIN user_blocks b ON b.blocker_id = p.user_id
+			WHERE p.id = ? AND b.blocked_id = ?
+		)`
+	if contentType == models.ContentComment {
+		query = `
+		SELECT EXISTS(
+			SELECT 1 FROM comments c
+			JOIN posts p ON p.id = c.post_id
+			JOIN user_blocks b ON b.blocker_id IN (c.user_id, p.user_id)
+			WHERE c.id = ? AND b.blocked_id = ?
+		)`
+	}
+	var blocked bool
+	err := db.QueryRow(query, contentID, userID).Scan(&blocked)
+	return blocked, err
+}
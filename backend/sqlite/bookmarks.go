@@ -0,0 +1,157 @@
+package sqlite
+
+import (
+	"database/sql"
+
+	"forum/models"
+)
+
+// SaveBookmark bookmarks a post or comment the viewer may see, or files an
+// existing bookmark in another folder with another note. created reports
+// whether the bookmark is new. Content the viewer may not see is
+// sql.ErrNoRows.
+func SaveBookmark(db *sql.DB, viewer models.Viewer, contentType string, contentID int, folder, note string) (created bool, err error) {
+	tx, err := db.Begin()
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback()
+
+	column := "post_id"
+	if contentType == models.ContentComment {
+		column = "comment_id"
+	}
+	visible, err := bookmarkable(tx, viewer, contentType, contentID)
+	if err != nil {
+		return false, err
+	}
+	if !visible {
+		return false, sql.ErrNoRows
+	}
+
+	result, err := tx.Exec(`UPDATE bookmarks SET folder = ?, note = ? WHERE user_id = ? AND `+column+` = ?`,
+		folder, note, viewer.UserID, contentID)
+	if err != nil {
+		return false, err
+	}
+	updated, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	if updated > 0 {
+		return false, tx.Commit()
+	}
+	_, err = tx.Exec(`INSERT INTO bookmarks (user_id, `+column+`, folder, note) VALUES (?, ?, ?, ?)`,
+		viewer.UserID, contentID, folder, note)
+	if err != nil {
+		return false, err
+	}
+	return true, tx.Commit()
+}
+
+// bookmarkable reports whether the viewer may see a post, or a comment and
+// the post it is on
+func bookmarkable(tx *sql.Tx, viewer models.Viewer, contentType string, contentID int) (bool, error) {
+	postVisible, postArgs := visibleTo("p", viewer)
+	query, args := `SELECT EXISTS(SELECT 1 FROM posts p WHERE p.id = ? AND `+postVisible+`)`, append([]any{contentID}, postArgs...)
+	if contentType == models.ContentComment {
+		commentVisible, commentArgs := visibleTo("c", viewer)
+		query = `SELECT EXISTS(
+			SELECT 1 FROM comments c JOIN posts p ON p.id = c.post_id
+			WHERE c.id = ? AND ` + postVisible + ` AND ` + commentVisible + `
+		)`
+		args = append(append([]any{contentID}, postArgs...), commentArgs...)
+	}
+	var visible bool
+	err := tx.QueryRow(query, args...).Scan(&visible)
+	return visible, err
+}
+
+// DeleteBookmark removes a user's bookmark of a post or comment. A missing
+// bookmark is sql.ErrNoRows.
+func DeleteBookmark(db *sql.DB, userID, contentType string, contentID int) error {
+	column := "post_id"
+	if contentType == models.ContentComment {
+		column = "comment_id"
+	}
+	result, err := db.Exec(`DELETE FROM bookmarks WHERE user_id = ? AND `+column+` = ?`, userID, contentID)
+	if err != nil {
+		return err
+	}
+	deleted, err := result.RowsAffected()
+	if err == nil && deleted == 0 {
+		return sql.ErrNoRows
+	}
+	return err
+}
+
+// GetBookmarks returns a page of the viewer's bookmarks, newest first. With
+// a folder only the bookmarks in it are returned ("" for those in none).
+// Bookmarked content the viewer may no longer see is left out.
+func GetBookmarks(db *sql.DB, viewer models.Viewer, folder *string, page, limit int) ([]models.Bookmark, error) {
+	postVisible, postArgs := visibleTo("p", viewer)
+	commentVisible, commentArgs := visibleTo("c", viewer)
+	filter, filterArgs := "", []any{}
+	if folder != nil {
+		filter, filterArgs = " AND b.folder = ?", []any{*folder}
+	}
+
+	args := append([]any{viewer.UserID}, filterArgs...)
+	args = append(append(args, postArgs...), commentArgs...)
+	rows, err := db.Query(`
+		SELECT b.id, b.post_id IS NULL, p.id, b.comment_id, b.folder, b.note, b.created_at,
+			p.title, u.username, COALESCE(c.content, p.content)
+		FROM bookmarks b
+		LEFT JOIN comments c ON c.id = b.comment_id
+		JOIN posts p ON p.id = COALESCE(b.post_id, c.post_id)
+		JOIN users u ON u.id = COALESCE(c.user_id, p.user_id)
+		WHERE b.user_id = ?`+filter+` AND `+postVisible+` AND (c.id IS NULL OR `+commentVisible+`)
+		ORDER BY b.created_at DESC, b.id DESC
+		LIMIT ? OFFSET ?
+	`, append(args, limit, (page-1)*limit)...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	bookmarks := []models.Bookmark{}
+	for rows.Next() {
+		var b models.Bookmark
+		var isComment bool
+		if err := rows.Scan(&b.ID, &isComment, &b.PostID, &b.CommentID, &b.Folder, &b.Note, &b.CreatedAt,
+			&b.PostTitle, &b.Username, &b.Content); err != nil {
+			return nil, err
+		}
+		b.ContentType = models.ContentPost
+		if isComment {
+			b.ContentType = models.ContentComment
+		}
+		bookmarks = append(bookmarks, b)
+	}
+	return bookmarks, rows.Err()
+}
+
+// GetBookmarkFolders returns the folders a user filed bookmarks in, by
+// name, with how many bookmarks each holds
+func GetBookmarkFolders(db *sql.DB, userID string) ([]models.BookmarkFolder, error) {
+	rows, err := db.Query(`
+		SELECT folder, COUNT(*) FROM bookmarks
+		WHERE user_id = ? AND folder != ''
+		GROUP BY folder
+		ORDER BY folder COLLATE NOCASE
+	`, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	folders := []models.BookmarkFolder{}
+	for rows.Next() {
+		var f models.BookmarkFolder
+		if err := rows.Scan(&f.Name, &f.Count); err != nil {
+			return nil, err
+		}
+		folders = append(folders, f)
+	}
+	return folders, rows.Err()
+}
@@ -0,0 +1,80 @@
+package sqlite
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"html"
+)
+
+// ErrMigrationApplied is returned when a one-off data migration already ran
+var ErrMigrationApplied = errors.New("migration already applied")
+
+// unescapeContentMigration names UnescapeContent in data_migrations
+const unescapeContentMigration = "unescape-content"
+
+// markdownTables hold user content written in Markdown
+var markdownTables = []string{"posts", "comments", "replycomments"}
+
+// UnescapeContent undoes the HTML escaping post, comment and reply content
+// was stored with before it was kept as Markdown, and returns how many rows
+// of each table changed. It runs once; later calls return
+// ErrMigrationApplied, as unescaping twice would turn escapes users typed
+// on purpose into markup.
+func UnescapeContent(db *sql.DB) (map[string]int, error) {
+	tx, err := db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	var applied int
+	if err := tx.QueryRow(`SELECT COUNT(*) FROM data_migrations WHERE name = ?`, unescapeContentMigration).Scan(&applied); err != nil {
+		return nil, err
+	}
+	if applied > 0 {
+		return nil, ErrMigrationApplied
+	}
+
+	changed := map[string]int{}
+	for _, table := range markdownTables {
+		escaped, err := escapedContent(tx, table)
+		if err != nil {
+			return nil, fmt.Errorf("reading %s: %w", table, err)
+		}
+		for id, content := range escaped {
+			if _, err := tx.Exec(fmt.Sprintf(`UPDATE %s SET content = ? WHERE id = ?`, table), content, id); err != nil {
+				return nil, fmt.Errorf("updating %s %d: %w", table, id, err)
+			}
+		}
+		changed[table] = len(escaped)
+	}
+
+	if _, err := tx.Exec(`INSERT INTO data_migrations (name) VALUES (?)`, unescapeContentMigration); err != nil {
+		return nil, err
+	}
+	return changed, tx.Commit()
+}
+
+// escapedContent returns the unescaped content of the rows of a table that
+// contain HTML entities, by ID
+func escapedContent(tx *sql.Tx, table string) (map[int]string, error) {
+	rows, err := tx.Query(fmt.Sprintf(`SELECT id, content FROM %s WHERE content LIKE '%%&%%;%%'`, table))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	escaped := map[int]string{}
+	for rows.Next() {
+		var id int
+		var content string
+		if err := rows.Scan(&id, &content); err != nil {
+			return nil, err
+		}
+		if unescaped := html.UnescapeString(content); unescaped != content {
+			escaped[id] = unescaped
+		}
+	}
+	return escaped, rows.Err()
+}
@@ -25,6 +25,12 @@ func InitializeDatabase(dbPath string) error {
 		return fmt.Errorf("failed to enable foreign key constraints: %w", err)
 	}
 	
+	// Add columns introduced since existing tables were created; runs first
+	// so schema.sql can index them
+	if err := applyColumnMigrations(); err != nil {
+		return fmt.Errorf("failed to migrate columns: %w", err)
+	}
+
 	// Apply schema from schema.sql file
 	if err := applySchemaFromFile("schema.sql"); err != nil {
 		return fmt.Errorf("failed to apply schema: %w", err)
@@ -32,6 +38,61 @@ func InitializeDatabase(dbPath string) error {
 	return nil
 }
 
+// columnMigrations are columns added to tables after their first release.
+// CREATE TABLE IF NOT EXISTS leaves existing tables alone, so they are added
+// with ALTER TABLE; tables that don't exist yet are created by schema.sql.
+var columnMigrations = []struct {
+	table, column, definition string
+}{
+	{"users", "role", "TEXT NOT NULL DEFAULT 'user'"},
+	{"users", "status", "TEXT NOT NULL DEFAULT 'active'"},
+	{"users", "invited_by", "TEXT REFERENCES users(id) ON DELETE SET NULL"},
+	{"users", "invite_code", "TEXT"},
+	{"users", "reputation", "INTEGER NOT NULL DEFAULT 0"},
+	{"posts", "status", "TEXT NOT NULL DEFAULT 'published'"},
+	{"comments", "status", "TEXT NOT NULL DEFAULT 'published'"},
+	{"replycomments", "status", "TEXT NOT NULL DEFAULT 'published'"},
+	{"posts", "accepted_comment_id", "INTEGER REFERENCES comments(id) ON DELETE SET NULL"},
+	{"posts", "accepted_reply_id", "INTEGER REFERENCES replycomments(id) ON DELETE SET NULL"},
+}
+
+// applyColumnMigrations adds the missing columnMigrations to existing tables
+func applyColumnMigrations() error {
+	for _, m := range columnMigrations {
+		columns, err := tableColumns(DB, m.table)
+		if err != nil {
+			return err
+		}
+		if len(columns) == 0 || columns[m.column] {
+			continue
+		}
+		if _, err := DB.Exec(fmt.Sprintf("ALTER TABLE %s ADD COLUMN %s %s", m.table, m.column, m.definition)); err != nil {
+			return fmt.Errorf("adding %s.%s: %w", m.table, m.column, err)
+		}
+		fmt.Printf("🛠️  Added column %s.%s\n", m.table, m.column)
+	}
+	return nil
+}
+
+// tableColumns returns the column names of a table, or none if it doesn't exist
+func tableColumns(db *sql.DB, table string) (map[string]bool, error) {
+	rows, err := db.Query(`SELECT name FROM pragma_table_info(?)`, table)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	columns := map[string]bool{}
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			return nil, err
+		}
+		columns[name] = true
+	}
+	return columns, rows.Err()
+}
+
 // applySchemaFromFile reads and executes schema.sql
 func applySchemaFromFile(filename string) error {
 	file, err := os.Open(filename)
@@ -0,0 +1,161 @@
+package sqlite
+
+import (
+	"database/sql"
+
+	"forum/models"
+)
+
+// FollowUser makes a user follow another. Following them again changes
+// nothing; created reports whether the follow is new.
+func FollowUser(db *sql.DB, followerID, followedID string) (created bool, err error) {
+	result, err := db.Exec(`INSERT OR IGNORE INTO user_follows (follower_id, followed_id) VALUES (?, ?)`, followerID, followedID)
+	if err != nil {
+		return false, err
+	}
+	n, err := result.RowsAffected()
+	return n > 0, err
+}
+
+// UnfollowUser stops a user following another. Not following them is
+// sql.ErrNoRows.
+func UnfollowUser(db *sql.DB, followerID, followedID string) error {
+	result, err := db.Exec(`DELETE FROM user_follows WHERE follower_id = ? AND followed_id = ?`, followerID, followedID)
+	return expectDeleted(result, err)
+}
+
+// FollowCategory makes a user follow a category. An unknown category is
+// sql.ErrNoRows.
+func FollowCategory(db *sql.DB, userID string, categoryID int) (created bool, err error) {
+	if err := categoryExists(db, categoryID); err != nil {
+		return false, err
+	}
+	result, err := db.Exec(`INSERT OR IGNORE INTO category_follows (user_id, category_id) VALUES (?, ?)`, userID, categoryID)
+	if err != nil {
+		return false, err
+	}
+	n, err := result.RowsAffected()
+	return n > 0, err
+}
+
+// categoryExists returns sql.ErrNoRows for unknown categories
+func categoryExists(db *sql.DB, categoryID int) error {
+	var exists bool
+	if err := db.QueryRow(`SELECT EXISTS(SELECT 1 FROM categories WHERE id = ?)`, categoryID).Scan(&exists); err != nil {
+		return err
+	}
+	if !exists {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+// UnfollowCategory stops a user following a category. Not following it is
+// sql.ErrNoRows.
+func UnfollowCategory(db *sql.DB, userID string, categoryID int) error {
+	result, err := db.Exec(`DELETE FROM category_follows WHERE user_id = ? AND category_id = ?`, userID, categoryID)
+	return expectDeleted(result, err)
+}
+
+// expectDeleted turns a delete that removed nothing into sql.ErrNoRows
+func expectDeleted(result sql.Result, err error) error {
+	if err != nil {
+		return err
+	}
+	deleted, err := result.RowsAffected()
+	if err == nil && deleted == 0 {
+		return sql.ErrNoRows
+	}
+	return err
+}
+
+// GetFollowers returns a page of the active users following a user, most
+// recent first
+func GetFollowers(db *sql.DB, userID string, page, limit int) ([]models.FollowUser, error) {
+	return queryFollowUsers(db, `
+		SELECT u.username, COALESCE(u.avatar_url, ''), f.created_at
+		FROM user_follows f
+		JOIN users u ON u.id = f.follower_id
+		WHERE f.followed_id = ? AND u.status = 'active'
+		ORDER BY f.created_at DESC, u.username
+		LIMIT ? OFFSET ?
+	`, userID, limit, (page-1)*limit)
+}
+
+// GetFollowing returns a page of the active users a user follows, most
+// recently followed first
+func GetFollowing(db *sql.DB, userID string, page, limit int) ([]models.FollowUser, error) {
+	return queryFollowUsers(db, `
+		SELECT u.username, COALESCE(u.avatar_url, ''), f.created_at
+		FROM user_follows f
+		JOIN users u ON u.id = f.followed_id
+		WHERE f.follower_id = ? AND u.status = 'active'
+		ORDER BY f.created_at DESC, u.username
+		LIMIT ? OFFSET ?
+	`, userID, limit, (page-1)*limit)
+}
+
+func queryFollowUsers(db *sql.DB, query string, args ...any) ([]models.FollowUser, error) {
+	rows, err := db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	users := []models.FollowUser{}
+	for rows.Next() {
+		var u models.FollowUser
+		if err := rows.Scan(&u.Username, &u.AvatarURL, &u.FollowedAt); err != nil {
+			return nil, err
+		}
+		users = append(users, u)
+	}
+	return users, rows.Err()
+}
+
+// GetFollowedCategories returns the categories a user follows, by name
+func GetFollowedCategories(db *sql.DB, userID string) ([]models.Category, error) {
+	return queryCategories(db, `
+		SELECT c.id, c.name FROM category_follows f
+		JOIN categories c ON c.id = f.category_id
+		WHERE f.user_id = ?
+		ORDER BY c.name
+	`, userID)
+}
+
+func queryCategories(db *sql.DB, query string, args ...any) ([]models.Category, error) {
+	rows, err := db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	categories := []models.Category{}
+	for rows.Next() {
+		var c models.Category
+		if err := rows.Scan(&c.ID, &c.Name); err != nil {
+			return nil, err
+		}
+		categories = append(categories, c)
+	}
+	return categories, rows.Err()
+}
+
+// NotifyFollowers sends a "follow" notification to the users following an
+// author, except those who turned them off, and returns how many were sent
+func NotifyFollowers(db *sql.DB, authorID, message, link string) (int, error) {
+	result, err := db.Exec(`
+		INSERT INTO notifications (user_id, type, message, link)
+		SELECT f.follower_id, 'follow', ?, ?
+		FROM user_follows f
+		WHERE f.followed_id = ? AND NOT EXISTS (
+			SELECT 1 FROM notification_preferences p
+			WHERE p.user_id = f.follower_id AND p.type = 'follow' AND p.enabled = 0
+		)
+	`, message, link, authorID)
+	if err != nil {
+		return 0, err
+	}
+	n, err := result.RowsAffected()
+	return int(n), err
+}
@@ -0,0 +1,215 @@
+package sqlite
+
+import (
+	"crypto/rand"
+	"database/sql"
+	"encoding/base32"
+	"errors"
+	"strings"
+	"time"
+
+	"forum/models"
+
+	"github.com/google/uuid"
+)
+
+// ErrInvalidInvite is returned for an unknown, used up or expired invite code
+var ErrInvalidInvite = errors.New("invalid invite code")
+
+// CreateInvite generates an invite code usable maxUses times until expiresAt
+func CreateInvite(db *sql.DB, createdBy string, maxUses int, expiresAt time.Time) (models.Invite, error) {
+	raw := make([]byte, 10)
+	if _, err := rand.Read(raw); err != nil {
+		return models.Invite{}, err
+	}
+	invite := models.Invite{
+		Code:      base32.StdEncoding.EncodeToString(raw),
+		CreatedBy: createdBy,
+		MaxUses:   maxUses,
+		ExpiresAt: expiresAt.UTC(),
+		Invitees:  []string{},
+	}
+	err := db.QueryRow(`
+		INSERT INTO invites (code, created_by, max_uses, expires_at)
+		VALUES (?, ?, ?, ?)
+		RETURNING created_at
+	`, invite.Code, createdBy, maxUses, invite.ExpiresAt).Scan(&invite.CreatedAt)
+	return invite, err
+}
+
+// CountInvitesSince returns how many invites a user created since a moment
+func CountInvitesSince(db *sql.DB, userID string, since time.Time) (int, error) {
+	var count int
+	err := db.QueryRow(`SELECT COUNT(*) FROM invites WHERE created_by = ? AND created_at >= ?`, userID, since.UTC()).Scan(&count)
+	return count, err
+}
+
+// GetInvites lists the invites a user created, or every invite when
+// createdBy is empty, newest first, with the users who signed up with them
+func GetInvites(db *sql.DB, createdBy string) ([]models.Invite, error) {
+	rows, err := db.Query(`
+		SELECT i.code, i.created_by, u.username, i.max_uses, i.uses, i.expires_at, i.created_at,
+		       COALESCE((SELECT GROUP_CONCAT(invitee.username, ',') FROM users invitee WHERE invitee.invite_code = i.code), '')
+		FROM invites i
+		JOIN users u ON u.id = i.created_by
+		WHERE ? = '' OR i.created_by = ?
+		ORDER BY i.created_at DESC, i.rowid DESC
+	`, createdBy, createdBy)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	invites := []models.Invite{}
+	for rows.Next() {
+		var invite models.Invite
+		var invitees string
+		if err := rows.Scan(&invite.Code, &invite.CreatedBy, &invite.Creator, &invite.MaxUses, &invite.Uses, &invite.ExpiresAt, &invite.CreatedAt, &invitees); err != nil {
+			return nil, err
+		}
+		invite.Invitees = []string{}
+		if invitees != "" {
+			invite.Invitees = strings.Split(invitees, ",")
+		}
+		invites = append(invites, invite)
+	}
+	return invites, rows.Err()
+}
+
+// DeleteInvite revokes an invite created by createdBy, or any invite when
+// createdBy is empty. sql.ErrNoRows means there was no such invite.
+func DeleteInvite(db *sql.DB, code, createdBy string) error {
+	result, err := db.Exec(`DELETE FROM invites WHERE code = ? AND (? = '' OR created_by = ?)`, code, createdBy, createdBy)
+	if err != nil {
+		return err
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+// CreateUserWithInvite inserts a user with the given status. A non-empty
+// invite code is redeemed in the same transaction, and the inviter's ID is
+// returned; ErrInvalidInvite means the code can't be used.
+func CreateUserWithInvite(db *sql.DB, username, email, passwordHash, avatarURL, status, inviteCode string) (string, error) {
+	tx, err := db.Begin()
+	if err != nil {
+		return "", err
+	}
+	defer tx.Rollback()
+
+	var inviter sql.NullString
+	var code sql.NullString
+	if inviteCode != "" {
+		err := tx.QueryRow(`
+			UPDATE invites SET uses = uses + 1
+			WHERE code = ? AND uses < max_uses AND expires_at > ?
+			RETURNING created_by
+		`, inviteCode, time.Now().UTC()).Scan(&inviter)
+		if err == sql.ErrNoRows {
+			return "", ErrInvalidInvite
+		}
+		if err != nil {
+			return "", err
+		}
+		code = sql.NullString{String: inviteCode, Valid: true}
+	}
+
+	_, err = tx.Exec(`
+		INSERT INTO users (id, username, email, password_hash, avatar_url, status, invited_by, invite_code)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?)
+	`, uuid.New().String(), username, email, passwordHash, avatarURL, status, inviter, code)
+	if err != nil {
+		return "", err
+	}
+	return inviter.String, tx.Commit()
+}
+
+// GetPendingUsers lists registrations waiting for approval, oldest first
+func GetPendingUsers(db *sql.DB) ([]models.PendingUser, error) {
+	rows, err := db.Query(`
+		SELECT u.id, u.username, u.email, COALESCE(inviter.username, ''), u.created_at
+		FROM users u
+		LEFT JOIN users inviter ON inviter.id = u.invited_by
+		WHERE u.status = ?
+		ORDER BY u.created_at ASC
+	`, models.StatusPending)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	users := []models.PendingUser{}
+	for rows.Next() {
+		var u models.PendingUser
+		if err := rows.Scan(&u.ID, &u.Username, &u.Email, &u.InvitedBy, &u.CreatedAt); err != nil {
+			return nil, err
+		}
+		users = append(users, u)
+	}
+	return users, rows.Err()
+}
+
+// ApproveUser activates a pending user. sql.ErrNoRows means the user is
+// not pending.
+func ApproveUser(db *sql.DB, userID string) error {
+	result, err := db.Exec(`UPDATE users SET status = ? WHERE id = ? AND status = ?`, models.StatusActive, userID, models.StatusPending)
+	if err != nil {
+		return err
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+// RejectUser deletes a pending user and returns their avatar URL so the
+// upload can be removed. sql.ErrNoRows means the user is not pending.
+func RejectUser(db *sql.DB, userID string) (string, error) {
+	var avatarURL sql.NullString
+	err := db.QueryRow(`
+		DELETE FROM users WHERE id = ? AND status = ?
+		RETURNING avatar_url
+	`, userID, models.StatusPending).Scan(&avatarURL)
+	return avatarURL.String, err
+}
+
+// GetUserRole returns a user's role
+func GetUserRole(db *sql.DB, userID string) (string, error) {
+	var role string
+	err := db.QueryRow(`SELECT role FROM users WHERE id = ?`, userID).Scan(&role)
+	return role, err
+}
+
+// SetUserRole changes the role of the user with a username. sql.ErrNoRows
+// means there is no such user.
+func SetUserRole(db *sql.DB, username, role string) error {
+	result, err := db.Exec(`UPDATE users SET role = ? WHERE username = ?`, role, username)
+	if err != nil {
+		return err
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+// GetAdminIDs returns the IDs of every administrator
+func GetAdminIDs(db *sql.DB) ([]string, error) {
+	rows, err := db.Query(`SELECT id FROM users WHERE role = ? AND status = ?`, models.RoleAdmin, models.StatusActive)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	ids := []string{}
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	return ids, rows.Err()
+}
@@ -0,0 +1,52 @@
+package sqlite
+
+import (
+	"database/sql"
+
+	"forum/models"
+)
+
+// GetLoginFailure retrieves the failed login record for a subject.
+// A subject without failures returns an empty record.
+func GetLoginFailure(db *sql.DB, subject string) (models.LoginFailure, error) {
+	failure := models.LoginFailure{Subject: subject}
+	var lastFailureAt, lockedUntil sql.NullTime
+
+	err := db.QueryRow(`
+		SELECT failures, last_failure_at, locked_until
+		FROM login_failures WHERE subject = ?
+	`, subject).Scan(&failure.Failures, &lastFailureAt, &lockedUntil)
+	if err == sql.ErrNoRows {
+		return failure, nil
+	}
+	if err != nil {
+		return failure, err
+	}
+
+	failure.LastFailureAt = lastFailureAt.Time
+	failure.LockedUntil = lockedUntil.Time
+	return failure, nil
+}
+
+// SaveLoginFailure inserts or updates a failed login record
+func SaveLoginFailure(db *sql.DB, failure models.LoginFailure) error {
+	var lockedUntil any
+	if !failure.LockedUntil.IsZero() {
+		lockedUntil = failure.LockedUntil.UTC()
+	}
+	_, err := db.Exec(`
+		INSERT INTO login_failures (subject, failures, last_failure_at, locked_until)
+		VALUES (?, ?, ?, ?)
+		ON CONFLICT(subject) DO UPDATE SET
+			failures = excluded.failures,
+			last_failure_at = excluded.last_failure_at,
+			locked_until = excluded.locked_until
+	`, failure.Subject, failure.Failures, failure.LastFailureAt.UTC(), lockedUntil)
+	return err
+}
+
+// ClearLoginFailures removes the failed login record for a subject
+func ClearLoginFailures(db *sql.DB, subject string) error {
+	_, err := db.Exec(`DELETE FROM login_failures WHERE subject = ?`, subject)
+	return err
+}
@@ -0,0 +1,201 @@
+package sqlite
+
+import (
+	"database/sql"
+	"log"
+	"strings"
+
+	"forum/markdown"
+	"forum/models"
+)
+
+// mentionedUser looks up the active user a mentioned name belongs to.
+// Names are matched without regard to case, preferring the exact spelling.
+func mentionedUser(db *sql.DB, name string) (id, username string, err error) {
+	err = db.QueryRow(`
+		SELECT id, username FROM users
+		WHERE username = ? COLLATE NOCASE AND status = ?
+		ORDER BY username = ? DESC
+		LIMIT 1
+	`, name, models.StatusActive, name).Scan(&id, &username)
+	return id, username, err
+}
+
+// MentionResolver resolves @mentions for markdown.Render against users
+func MentionResolver(db *sql.DB) markdown.MentionResolver {
+	return func(name string) (string, bool) {
+		_, username, err := mentionedUser(db, name)
+		if err != nil && err != sql.ErrNoRows {
+			log.Printf("Warning: Resolving mention @%s failed: %v", name, err)
+		}
+		return username, err == nil
+	}
+}
+
+// SaveMentions records the users a post, comment or reply mentions, given
+// the names from markdown.Mentions. Names nobody has and the author
+// mentioning themselves are left out. Mentions an edit removed are
+// forgotten; ones kept keep their notified state, so an edit doesn't
+// notify anyone twice. It returns how many users are mentioned.
+func SaveMentions(db *sql.DB, contentType string, contentID int, authorID string, names []string) (int, error) {
+	userIDs := []any{}
+	seen := map[string]bool{}
+	for _, name := range names {
+		id, _, err := mentionedUser(db, name)
+		if err == sql.ErrNoRows || id == authorID || seen[id] {
+			continue
+		}
+		if err != nil {
+			return 0, err
+		}
+		seen[id] = true
+		userIDs = append(userIDs, id)
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	query := `DELETE FROM mentions WHERE content_type = ? AND content_id = ?`
+	args := []any{contentType, contentID}
+	if len(userIDs) > 0 {
+		query += ` AND user_id NOT IN (?` + strings.Repeat(",?", len(userIDs)-1) + `)`
+		args = append(args, userIDs...)
+	}
+	if _, err := tx.Exec(query, args...); err != nil {
+		return 0, err
+	}
+	for _, userID := range userIDs {
+		if _, err := tx.Exec(`
+			INSERT OR IGNORE INTO mentions (content_type, content_id, user_id, author_id)
+			VALUES (?, ?, ?, ?)
+		`, contentType, contentID, userID, authorID); err != nil {
+			return 0, err
+		}
+	}
+	return len(userIDs), tx.Commit()
+}
+
+// TakeMentionNotifications returns the mentions in a post, comment or reply
+// that nobody was notified of yet and marks them notified. Mentions by
+// users the mentioned user blocked are marked without being returned.
+func TakeMentionNotifications(db *sql.DB, contentType string, contentID int) ([]models.Mention, error) {
+	tx, err := db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	rows, err := tx.Query(`
+		SELECT m.content_type, m.content_id, m.user_id, m.author_id, u.username,
+			CASE m.content_type
+				WHEN 'post' THEN m.content_id
+				WHEN 'comment' THEN (SELECT post_id FROM comments WHERE id = m.content_id)
+				ELSE (SELECT c.post_id FROM replycomments r JOIN comments c ON c.id = r.parent_comment_id WHERE r.id = m.content_id)
+			END
+		FROM mentions m
+		JOIN users u ON u.id = m.author_id
+		WHERE m.content_type = ? AND m.content_id = ? AND m.notified = 0 AND NOT EXISTS (
+			SELECT 1 FROM user_blocks b WHERE b.blocker_id = m.user_id AND b.blocked_id = m.author_id
+		)
+		ORDER BY m.id
+	`, contentType, contentID)
+	if err != nil {
+		return nil, err
+	}
+	mentions := []models.Mention{}
+	for rows.Next() {
+		var m models.Mention
+		var postID sql.NullInt64
+		if err := rows.Scan(&m.ContentType, &m.ContentID, &m.UserID, &m.AuthorID, &m.AuthorName, &postID); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		m.PostID = int(postID.Int64)
+		mentions = append(mentions, m)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	if _, err := tx.Exec(`UPDATE mentions SET notified = 1 WHERE content_type = ? AND content_id = ?`, contentType, contentID); err != nil {
+		return nil, err
+	}
+	return mentions, tx.Commit()
+}
+
+// CleanupMentions removes mentions whose content was deleted
+func CleanupMentions(db *sql.DB) error {
+	_, err := db.Exec(`
+		DELETE FROM mentions WHERE
+			(content_type = 'post' AND content_id NOT IN (SELECT id FROM posts)) OR
+			(content_type = 'comment' AND content_id NOT IN (SELECT id FROM comments)) OR
+			(content_type = 'reply' AND content_id NOT IN (SELECT id FROM replycomments))
+	`)
+	return err
+}
+
+// AutocompleteUsers suggests active users whose name contains query for
+// someone writing a mention. Names starting with query come first, then
+// the people the user most recently interacted with: mentioned or was
+// mentioned by, replied to or was replied to by, or reacted to.
+func AutocompleteUsers(db *sql.DB, userID, query string, limit int) ([]models.UserSuggestion, error) {
+	rows, err := db.Query(`
+		WITH interactions (user_id, at) AS (
+			SELECT user_id, created_at FROM mentions WHERE author_id = ?1
+			UNION ALL SELECT author_id, created_at FROM mentions WHERE user_id = ?1
+			UNION ALL SELECT p.user_id, c.created_at FROM comments c JOIN posts p ON p.id = c.post_id WHERE c.user_id = ?1
+			UNION ALL SELECT c.user_id, c.created_at FROM comments c JOIN posts p ON p.id = c.post_id WHERE p.user_id = ?1
+			UNION ALL SELECT c.user_id, r.created_at FROM replycomments r JOIN comments c ON c.id = r.parent_comment_id WHERE r.user_id = ?1
+			UNION ALL SELECT r.user_id, r.created_at FROM replycomments r JOIN comments c ON c.id = r.parent_comment_id WHERE c.user_id = ?1
+			UNION ALL SELECT p.user_id, l.created_at FROM likes l JOIN posts p ON p.id = l.post_id WHERE l.user_id = ?1
+			UNION ALL SELECT c.user_id, l.created_at FROM likes l JOIN comments c ON c.id = l.comment_id WHERE l.user_id = ?1
+		)
+		SELECT id, username, avatar_url FROM (
+			SELECT u.id, u.username, COALESCE(u.avatar_url, '') AS avatar_url,
+				substr(lower(u.username), 1, length(?2)) = lower(?2) AS prefix,
+				(SELECT MAX(at) FROM interactions i WHERE i.user_id = u.id) AS last_interaction
+			FROM users u
+			WHERE u.status = ?3 AND u.id != ?1 AND instr(lower(u.username), lower(?2)) > 0
+		)
+		ORDER BY prefix DESC, last_interaction IS NULL, last_interaction DESC, length(username), username
+		LIMIT ?4
+	`, userID, query, models.StatusActive, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	suggestions := []models.UserSuggestion{}
+	for rows.Next() {
+		var s models.UserSuggestion
+		if err := rows.Scan(&s.ID, &s.Username, &s.AvatarURL); err != nil {
+			return nil, err
+		}
+		suggestions = append(suggestions, s)
+	}
+	return suggestions, rows.Err()
+}
+
+// GetPublicProfile retrieves what anyone may see about an active user.
+// sql.ErrNoRows means there is no such user.
+func GetPublicProfile(db *sql.DB, username string) (models.PublicProfile, error) {
+	var profile models.PublicProfile
+	err := db.QueryRow(`
+		SELECT u.id, u.username, COALESCE(u.avatar_url, ''), u.role, u.created_at, u.reputation,
+			(SELECT COUNT(*) FROM posts p WHERE p.user_id = u.id AND p.status = 'published'),
+			(SELECT COUNT(*) FROM comments c WHERE c.user_id = u.id AND c.status = 'published') +
+			(SELECT COUNT(*) FROM replycomments r WHERE r.user_id = u.id AND r.status = 'published'),
+			(SELECT COUNT(*) FROM user_follows f JOIN users fu ON fu.id = f.follower_id
+				WHERE f.followed_id = u.id AND fu.status = 'active'),
+			(SELECT COUNT(*) FROM user_follows f JOIN users fu ON fu.id = f.followed_id
+				WHERE f.follower_id = u.id AND fu.status = 'active')
+		FROM users u
+		WHERE u.username = ? AND u.status = ?
+	`, username, models.StatusActive).Scan(&profile.ID, &profile.Username, &profile.AvatarURL, &profile.Role, &profile.CreatedAt,
+		&profile.Reputation, &profile.PostCount, &profile.CommentCount, &profile.FollowerCount, &profile.FollowingCount)
+	return profile, err
+}
@@ -0,0 +1,263 @@
+package sqlite
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+
+	"forum/markdown"
+	"forum/models"
+	"forum/spamfilter"
+)
+
+// contentTables maps content types to the table holding them
+var contentTables = map[string]string{
+	models.ContentPost:    "posts",
+	models.ContentComment: "comments",
+	models.ContentReply:   "replycomments",
+}
+
+// visibleTo returns a condition on the aliased posts, comments or
+// replycomments table, and its arguments, that matches the rows the viewer
+// may see: published content, their own and, for moderators, everything
+func visibleTo(alias string, viewer models.Viewer) (string, []any) {
+	if viewer.Moderator {
+		return "1 = 1", nil
+	}
+	return fmt.Sprintf("(%[1]s.status = 'published' OR %[1]s.user_id = ?)", alias), []any{viewer.UserID}
+}
+
+// CountPublishedPosts counts a user's posts that passed moderation
+func CountPublishedPosts(db *sql.DB, userID string) (int, error) {
+	var count int
+	err := db.QueryRow(`SELECT COUNT(*) FROM posts WHERE user_id = ? AND status = ?`, userID, models.ContentPublished).Scan(&count)
+	return count, err
+}
+
+// HoldForModeration marks content pending and puts it in the moderation
+// queue, replacing the reasons if it is queued already
+func HoldForModeration(db *sql.DB, contentType string, contentID int, userID string, reasons []string, spamScore *float64) error {
+	table, ok := contentTables[contentType]
+	if !ok {
+		return fmt.Errorf("unknown content type %q", contentType)
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(fmt.Sprintf(`UPDATE %s SET status = ? WHERE id = ?`, table), models.ContentPending, contentID); err != nil {
+		return err
+	}
+	_, err = tx.Exec(`
+		INSERT INTO moderation_queue (content_type, content_id, user_id, reasons, spam_score)
+		VALUES (?, ?, ?, ?, ?)
+		ON CONFLICT (content_type, content_id) DO UPDATE SET
+			reasons = excluded.reasons, spam_score = excluded.spam_score, created_at = CURRENT_TIMESTAMP
+	`, contentType, contentID, userID, strings.Join(reasons, "\n"), spamScore)
+	if err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// moderationItems selects queued content of every type with the post it
+// belongs to; entries whose content was deleted drop out of the joins
+const moderationItems = `
+	SELECT q.id AS id, q.content_type, q.content_id, p.id AS post_id, p.title, q.user_id, u.username,
+	       p.content, p.image_url, q.reasons, q.spam_score, q.created_at AS created_at
+	FROM moderation_queue q
+	JOIN posts p ON q.content_type = 'post' AND p.id = q.content_id
+	JOIN users u ON u.id = q.user_id
+	UNION ALL
+	SELECT q.id, q.content_type, q.content_id, p.id, p.title, q.user_id, u.username,
+	       c.content, NULL, q.reasons, q.spam_score, q.created_at
+	FROM moderation_queue q
+	JOIN comments c ON q.content_type = 'comment' AND c.id = q.content_id
+	JOIN posts p ON p.id = c.post_id
+	JOIN users u ON u.id = q.user_id
+	UNION ALL
+	SELECT q.id, q.content_type, q.content_id, p.id, p.title, q.user_id, u.username,
+	       r.content, NULL, q.reasons, q.spam_score, q.created_at
+	FROM moderation_queue q
+	JOIN replycomments r ON q.content_type = 'reply' AND r.id = q.content_id
+	JOIN comments c ON c.id = r.parent_comment_id
+	JOIN posts p ON p.id = c.post_id
+	JOIN users u ON u.id = q.user_id
+`
+
+func scanModerationItem(scan func(dest ...any) error) (models.ModerationItem, error) {
+	var item models.ModerationItem
+	var reasons string
+	var spamScore sql.NullFloat64
+	err := scan(&item.ID, &item.ContentType, &item.ContentID, &item.PostID, &item.PostTitle, &item.UserID, &item.Username,
+		&item.Content, &item.ImageURL, &reasons, &spamScore, &item.CreatedAt)
+	item.ContentHTML = markdown.RenderCached(item.Content)
+	item.Reasons = strings.Split(reasons, "\n")
+	if spamScore.Valid {
+		item.SpamScore = &spamScore.Float64
+	}
+	return item, err
+}
+
+// GetModerationQueue lists the held content, oldest first
+func GetModerationQueue(db *sql.DB) ([]models.ModerationItem, error) {
+	rows, err := db.Query(`SELECT * FROM (` + moderationItems + `) ORDER BY created_at, id`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	items := []models.ModerationItem{}
+	for rows.Next() {
+		item, err := scanModerationItem(rows.Scan)
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, item)
+	}
+	return items, rows.Err()
+}
+
+// GetModerationItem retrieves one queue entry
+func GetModerationItem(db *sql.DB, id int64) (models.ModerationItem, error) {
+	return scanModerationItem(db.QueryRow(`SELECT * FROM (`+moderationItems+`) WHERE id = ?`, id).Scan)
+}
+
+// PublishModerationItem publishes held content and removes it from the queue
+func PublishModerationItem(db *sql.DB, item models.ModerationItem) error {
+	return resolveModerationItem(db, item, fmt.Sprintf(`UPDATE %s SET status = '%s' WHERE id = ?`, contentTables[item.ContentType], models.ContentPublished))
+}
+
+// RejectModerationItem deletes held content and removes it from the queue
+func RejectModerationItem(db *sql.DB, item models.ModerationItem) error {
+	return resolveModerationItem(db, item, fmt.Sprintf(`DELETE FROM %s WHERE id = ?`, contentTables[item.ContentType]))
+}
+
+func resolveModerationItem(db *sql.DB, item models.ModerationItem, contentQuery string) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	result, err := tx.Exec(`DELETE FROM moderation_queue WHERE id = ?`, item.ID)
+	if err != nil {
+		return err
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		return sql.ErrNoRows // decided by another moderator meanwhile
+	}
+	if _, err := tx.Exec(contentQuery, item.ContentID); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// CleanupModerationQueue removes entries whose content was deleted
+func CleanupModerationQueue(db *sql.DB) error {
+	_, err := db.Exec(`
+		DELETE FROM moderation_queue WHERE
+			(content_type = 'post' AND content_id NOT IN (SELECT id FROM posts)) OR
+			(content_type = 'comment' AND content_id NOT IN (SELECT id FROM comments)) OR
+			(content_type = 'reply' AND content_id NOT IN (SELECT id FROM replycomments))
+	`)
+	return err
+}
+
+// TrainSpamFilter records a moderator's decision on a text's tokens
+func TrainSpamFilter(db *sql.DB, tokens []string, spam bool) error {
+	label, column := "ham", "ham"
+	if spam {
+		label, column = "spam", "spam"
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	_, err = tx.Exec(`
+		INSERT INTO spam_corpus (label, documents) VALUES (?, 1)
+		ON CONFLICT (label) DO UPDATE SET documents = documents + 1
+	`, label)
+	if err != nil {
+		return err
+	}
+	stmt, err := tx.Prepare(fmt.Sprintf(`
+		INSERT INTO spam_tokens (token, %[1]s) VALUES (?, 1)
+		ON CONFLICT (token) DO UPDATE SET %[1]s = %[1]s + 1
+	`, column))
+	if err != nil {
+		return err
+	}
+	defer stmt.Close()
+	for _, token := range tokens {
+		if _, err := stmt.Exec(token); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// GetSpamModel loads the classifier totals and the counts of tokens
+func GetSpamModel(db *sql.DB, tokens []string) (spamfilter.Model, error) {
+	model := spamfilter.Model{Tokens: map[string]spamfilter.TokenCount{}}
+	err := db.QueryRow(`
+		SELECT COALESCE(SUM(CASE WHEN label = 'spam' THEN documents END), 0),
+		       COALESCE(SUM(CASE WHEN label = 'ham' THEN documents END), 0)
+		FROM spam_corpus
+	`).Scan(&model.SpamDocuments, &model.HamDocuments)
+	if err != nil || len(tokens) == 0 {
+		return model, err
+	}
+
+	placeholders := make([]string, len(tokens))
+	args := make([]any, len(tokens))
+	for i, token := range tokens {
+		placeholders[i] = "?"
+		args[i] = token
+	}
+	rows, err := db.Query(`SELECT token, spam, ham FROM spam_tokens WHERE token IN (`+strings.Join(placeholders, ",")+`)`, args...)
+	if err != nil {
+		return model, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var token string
+		var count spamfilter.TokenCount
+		if err := rows.Scan(&token, &count.Spam, &count.Ham); err != nil {
+			return model, err
+		}
+		model.Tokens[token] = count
+	}
+	return model, rows.Err()
+}
+
+// RecordContentFingerprint remembers that a user posted a text
+func RecordContentFingerprint(db *sql.DB, userID, fingerprint string) error {
+	_, err := db.Exec(`INSERT INTO content_fingerprints (fingerprint, user_id, created_at) VALUES (?, ?, ?)`, fingerprint, userID, time.Now().UTC())
+	return err
+}
+
+// CountContentFingerprints returns how often a user posted a text since a
+// moment, and how many other users posted it
+func CountContentFingerprints(db *sql.DB, userID, fingerprint string, since time.Time) (repeats, copies int, err error) {
+	err = db.QueryRow(`
+		SELECT COUNT(CASE WHEN user_id = ? THEN 1 END),
+		       COUNT(DISTINCT CASE WHEN user_id != ? THEN user_id END)
+		FROM content_fingerprints
+		WHERE fingerprint = ? AND created_at >= ?
+	`, userID, userID, fingerprint, since.UTC()).Scan(&repeats, &copies)
+	return repeats, copies, err
+}
+
+// CleanupContentFingerprints forgets texts posted before a moment
+func CleanupContentFingerprints(db *sql.DB, before time.Time) error {
+	_, err := db.Exec(`DELETE FROM content_fingerprints WHERE created_at < ?`, before.UTC())
+	return err
+}
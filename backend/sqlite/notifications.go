@@ -0,0 +1,127 @@
+package sqlite
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+
+	"forum/models"
+)
+
+// CreateNotification stores a notification for a user, unless they turned
+// this type of notification off
+func CreateNotification(db *sql.DB, userID, notificationType, message, link string) error {
+	_, err := db.Exec(`
+		INSERT INTO notifications (user_id, type, message, link)
+		SELECT ?, ?, ?, ?
+		WHERE NOT EXISTS (
+			SELECT 1 FROM notification_preferences WHERE user_id = ? AND type = ? AND enabled = 0
+		)
+	`, userID, notificationType, message, link, userID, notificationType)
+	return err
+}
+
+// GetNotificationPreferences returns whether a user gets each optional
+// type of notification
+func GetNotificationPreferences(db *sql.DB, userID string) ([]models.NotificationPreference, error) {
+	rows, err := db.Query(`SELECT type, enabled FROM notification_preferences WHERE user_id = ?`, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	enabled := map[string]bool{}
+	for rows.Next() {
+		var notificationType string
+		var on bool
+		if err := rows.Scan(&notificationType, &on); err != nil {
+			return nil, err
+		}
+		enabled[notificationType] = on
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	preferences := make([]models.NotificationPreference, 0, len(models.OptionalNotifications))
+	for _, p := range models.OptionalNotifications {
+		if on, ok := enabled[p.Type]; ok {
+			p.Enabled = on
+		}
+		preferences = append(preferences, p)
+	}
+	return preferences, nil
+}
+
+// SetNotificationPreferences turns types of notification on or off for a
+// user. Only optional types should be passed.
+func SetNotificationPreferences(db *sql.DB, userID string, enabled map[string]bool) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	for notificationType, on := range enabled {
+		_, err := tx.Exec(`
+			INSERT INTO notification_preferences (user_id, type, enabled) VALUES (?, ?, ?)
+			ON CONFLICT (user_id, type) DO UPDATE SET enabled = excluded.enabled
+		`, userID, notificationType, on)
+		if err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// GetNotifications retrieves a user's notifications, newest first
+func GetNotifications(db *sql.DB, userID string, page, limit int) ([]models.Notification, error) {
+	offset := (page - 1) * limit
+
+	rows, err := db.Query(`
+		SELECT id, user_id, type, message, link, is_read, created_at
+		FROM notifications
+		WHERE user_id = ?
+		ORDER BY created_at DESC, id DESC
+		LIMIT ? OFFSET ?
+	`, userID, limit, offset)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	notifications := []models.Notification{}
+	for rows.Next() {
+		var n models.Notification
+		if err := rows.Scan(&n.ID, &n.UserID, &n.Type, &n.Message, &n.Link, &n.IsRead, &n.CreatedAt); err != nil {
+			return nil, err
+		}
+		notifications = append(notifications, n)
+	}
+	return notifications, rows.Err()
+}
+
+// CountUnreadNotifications returns how many unread notifications a user has
+func CountUnreadNotifications(db *sql.DB, userID string) (int, error) {
+	var count int
+	err := db.QueryRow(`SELECT COUNT(*) FROM notifications WHERE user_id = ? AND is_read = 0`, userID).Scan(&count)
+	return count, err
+}
+
+// MarkNotificationsRead marks the given notifications (or all of them when ids is empty) as read
+func MarkNotificationsRead(db *sql.DB, userID string, ids []int) error {
+	if len(ids) == 0 {
+		_, err := db.Exec(`UPDATE notifications SET is_read = 1 WHERE user_id = ?`, userID)
+		return err
+	}
+
+	placeholders := make([]string, len(ids))
+	args := []any{userID}
+	for i, id := range ids {
+		placeholders[i] = "?"
+		args = append(args, id)
+	}
+	query := fmt.Sprintf(`UPDATE notifications SET is_read = 1 WHERE user_id = ? AND id IN (%s)`, strings.Join(placeholders, ","))
+	_, err := db.Exec(query, args...)
+	return err
+}
@@ -0,0 +1,89 @@
+package sqlite
+
+import (
+	"database/sql"
+	"time"
+
+	"forum/models"
+)
+
+// CreateOIDCLogin stores the state, nonce and PKCE verifier of a login redirected to a provider
+func CreateOIDCLogin(db *sql.DB, login models.OIDCLogin) error {
+	_, err := db.Exec(`
+		INSERT INTO oidc_logins (state, provider, nonce, code_verifier, expires_at)
+		VALUES (?, ?, ?, ?, ?)
+	`, login.State, login.Provider, login.Nonce, login.CodeVerifier, login.ExpiresAt.UTC())
+	return err
+}
+
+// TakeOIDCLogin retrieves and deletes a login so each state is used at most
+// once. Expired logins are returned as sql.ErrNoRows.
+func TakeOIDCLogin(db *sql.DB, state string) (models.OIDCLogin, error) {
+	var login models.OIDCLogin
+	err := db.QueryRow(`
+		DELETE FROM oidc_logins WHERE state = ?
+		RETURNING state, provider, nonce, code_verifier, expires_at
+	`, state).Scan(&login.State, &login.Provider, &login.Nonce, &login.CodeVerifier, &login.ExpiresAt)
+	if err != nil {
+		return login, err
+	}
+	if !time.Now().Before(login.ExpiresAt) {
+		return login, sql.ErrNoRows
+	}
+	return login, nil
+}
+
+// CleanupOIDCLogins removes logins that were never completed
+func CleanupOIDCLogins(db *sql.DB) error {
+	_, err := db.Exec(`DELETE FROM oidc_logins WHERE expires_at <= ?`, time.Now().UTC())
+	return err
+}
+
+// GetUserIdentity finds the user linked to a provider account
+func GetUserIdentity(db *sql.DB, provider, subject string) (models.UserIdentity, error) {
+	var identity models.UserIdentity
+	err := db.QueryRow(`
+		SELECT provider, subject, user_id, email, created_at
+		FROM user_identities WHERE provider = ? AND subject = ?
+	`, provider, subject).Scan(&identity.Provider, &identity.Subject, &identity.UserID, &identity.Email, &identity.CreatedAt)
+	return identity, err
+}
+
+// LinkUserIdentity links a provider account to a user
+func LinkUserIdentity(db *sql.DB, userID, provider, subject, email string) error {
+	_, err := db.Exec(`
+		INSERT INTO user_identities (provider, subject, user_id, email)
+		VALUES (?, ?, ?, ?)
+	`, provider, subject, userID, email)
+	return err
+}
+
+// GetUserByEmailFold retrieves a user by email, ignoring case
+func GetUserByEmailFold(db *sql.DB, email string) (models.User, error) {
+	var user models.User
+	err := db.QueryRow(`
+		SELECT id, username, email, password_hash, avatar_url, role, status, created_at, updated_at
+		FROM users
+		WHERE email = ? COLLATE NOCASE
+		ORDER BY email = ? DESC
+		LIMIT 1
+	`, email, email).Scan(
+		&user.ID,
+		&user.Username,
+		&user.Email,
+		&user.PasswordHash,
+		&user.AvatarURL,
+		&user.Role,
+		&user.Status,
+		&user.CreatedAt,
+		&user.UpdatedAt,
+	)
+	return user, err
+}
+
+// UsernameExists reports whether a username is taken, ignoring case
+func UsernameExists(db *sql.DB, username string) (bool, error) {
+	var exists bool
+	err := db.QueryRow(`SELECT EXISTS(SELECT 1 FROM users WHERE username = ? COLLATE NOCASE)`, username).Scan(&exists)
+	return exists, err
+}
@@ -0,0 +1,209 @@
+package sqlite
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"math"
+	"strings"
+	"time"
+
+	"forum/models"
+)
+
+// Errors casting a vote
+var (
+	ErrAlreadyVoted  = errors.New("already voted in this poll")
+	ErrNotVoted      = errors.New("no vote to change in this poll")
+	ErrInvalidOption = errors.New("option does not belong to this poll")
+)
+
+// CreatePoll attaches a validated poll to a post
+func CreatePoll(db *sql.DB, postID int, poll models.NewPoll) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	result, err := tx.Exec(`
+		INSERT INTO polls (post_id, question, multiple, hide_results, closes_at)
+		VALUES (?, ?, ?, ?, ?)
+	`, postID, poll.Question, poll.Multiple, poll.HideResults, poll.ClosesAt.UTC())
+	if err != nil {
+		return err
+	}
+	pollID, err := result.LastInsertId()
+	if err != nil {
+		return err
+	}
+	for i, label := range poll.Options {
+		if _, err := tx.Exec(`INSERT INTO poll_options (poll_id, position, label) VALUES (?, ?, ?)`, pollID, i, label); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// GetPollPostID returns the post a poll is attached to
+func GetPollPostID(db *sql.DB, pollID int) (int, error) {
+	var postID int
+	err := db.QueryRow(`SELECT post_id FROM polls WHERE id = ?`, pollID).Scan(&postID)
+	return postID, err
+}
+
+// CastVote records a user's ballot in a poll. With replace, the user's
+// earlier ballot is replaced and ErrNotVoted returned if there is none;
+// otherwise a second ballot is refused with ErrAlreadyVoted. Single choice
+// polls take exactly one option, which the database enforces too.
+func CastVote(db *sql.DB, pollID int, userID string, optionIDs []int, replace bool) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if replace {
+		if _, err := tx.Exec(`DELETE FROM poll_votes WHERE poll_id = ? AND user_id = ?`, pollID, userID); err != nil {
+			return err
+		}
+		result, err := tx.Exec(`DELETE FROM poll_ballots WHERE poll_id = ? AND user_id = ?`, pollID, userID)
+		if err != nil {
+			return err
+		}
+		if n, _ := result.RowsAffected(); n == 0 {
+			return ErrNotVoted
+		}
+	}
+
+	result, err := tx.Exec(`INSERT OR IGNORE INTO poll_ballots (poll_id, user_id) VALUES (?, ?)`, pollID, userID)
+	if err != nil {
+		return err
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		return ErrAlreadyVoted
+	}
+
+	for _, optionID := range optionIDs {
+		result, err := tx.Exec(`
+			INSERT INTO poll_votes (poll_id, user_id, option_id)
+			SELECT poll_id, ?, id FROM poll_options WHERE poll_id = ? AND id = ?
+		`, userID, pollID, optionID)
+		if err != nil {
+			return err
+		}
+		if n, _ := result.RowsAffected(); n == 0 {
+			return ErrInvalidOption
+		}
+	}
+	return tx.Commit()
+}
+
+// attachPolls loads the polls of posts, with results as the viewer may see
+// them
+func attachPolls(db *sql.DB, viewer models.Viewer, posts []*models.Post) error {
+	if len(posts) == 0 {
+		return nil
+	}
+	byPost := map[int]*models.Post{}
+	placeholders := make([]string, len(posts))
+	args := make([]any, len(posts))
+	for i, post := range posts {
+		byPost[post.ID] = post
+		placeholders[i] = "?"
+		args[i] = post.ID
+	}
+
+	rows, err := db.Query(fmt.Sprintf(`
+		SELECT id, post_id, question, multiple, hide_results, closes_at,
+			(SELECT COUNT(*) FROM poll_ballots b WHERE b.poll_id = polls.id)
+		FROM polls WHERE post_id IN (%s)
+	`, strings.Join(placeholders, ",")), args...)
+	if err != nil {
+		return err
+	}
+	polls := map[int]*models.Poll{}
+	var pollIDs []any
+	for rows.Next() {
+		poll := &models.Poll{Options: []models.PollOption{}, MyVotes: []int{}}
+		if err := rows.Scan(&poll.ID, &poll.PostID, &poll.Question, &poll.Multiple, &poll.HideResults, &poll.ClosesAt, &poll.Voters); err != nil {
+			rows.Close()
+			return err
+		}
+		poll.Closed = !time.Now().Before(poll.ClosesAt)
+		poll.ResultsHidden = poll.HideResults && !poll.Closed
+		polls[poll.ID] = poll
+		pollIDs = append(pollIDs, poll.ID)
+		byPost[poll.PostID].Poll = poll
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil || len(pollIDs) == 0 {
+		return err
+	}
+
+	in := "?" + strings.Repeat(",?", len(pollIDs)-1)
+	rows, err = db.Query(`
+		SELECT o.poll_id, o.id, o.label, COUNT(v.user_id)
+		FROM poll_options o
+		LEFT JOIN poll_votes v ON v.option_id = o.id
+		WHERE o.poll_id IN (`+in+`)
+		GROUP BY o.id
+		ORDER BY o.poll_id, o.position
+	`, pollIDs...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var pollID, votes int
+		var option models.PollOption
+		if err := rows.Scan(&pollID, &option.ID, &option.Label, &votes); err != nil {
+			return err
+		}
+		poll := polls[pollID]
+		if !poll.ResultsHidden {
+			percent := 0.0
+			if poll.Voters > 0 {
+				percent = math.Round(float64(votes)*1000/float64(poll.Voters)) / 10
+			}
+			option.Votes, option.Percent = &votes, &percent
+		}
+		poll.Options = append(poll.Options, option)
+	}
+	if err := rows.Err(); err != nil || viewer.UserID == "" {
+		return err
+	}
+
+	mine, err := db.Query(`
+		SELECT v.poll_id, v.option_id FROM poll_votes v
+		JOIN poll_options o ON o.id = v.option_id
+		WHERE v.user_id = ? AND v.poll_id IN (`+in+`)
+		ORDER BY o.position
+	`, append([]any{viewer.UserID}, pollIDs...)...)
+	if err != nil {
+		return err
+	}
+	defer mine.Close()
+	for mine.Next() {
+		var pollID, optionID int
+		if err := mine.Scan(&pollID, &optionID); err != nil {
+			return err
+		}
+		polls[pollID].MyVotes = append(polls[pollID].MyVotes, optionID)
+	}
+	return mine.Err()
+}
+
+// AttachPoll loads the poll of a post, if it has one
+func AttachPoll(db *sql.DB, viewer models.Viewer, post *models.Post) error {
+	return attachPolls(db, viewer, []*models.Post{post})
+}
+
+// postList returns the posts of a map built while reading a page of posts
+func postList(posts map[int]*models.Post) []*models.Post {
+	list := make([]*models.Post, 0, len(posts))
+	for _, post := range posts {
+		list = append(list, post)
+	}
+	return list
+}
@@ -7,6 +7,7 @@ import (
 	"strings"
 	"time"
 
+	"forum/markdown"
 	"forum/models"
 
 	"github.com/google/uuid"
@@ -24,7 +25,7 @@ func min(a, b int) int {
 func GetUserByUsername(db *sql.DB, username string) (models.User, error) {
 	var user models.User
 	err := db.QueryRow(`
-		SELECT id, username, email, password_hash, avatar_url, created_at, updated_at
+		SELECT id, username, email, password_hash, avatar_url, role, status, created_at, updated_at
 		FROM users WHERE username = ?
 	`, username).Scan(
 		&user.ID,
@@ -32,6 +33,8 @@ func GetUserByUsername(db *sql.DB, username string) (models.User, error) {
 		&user.Email,
 		&user.PasswordHash,
 		&user.AvatarURL,
+		&user.Role,
+		&user.Status,
 		&user.CreatedAt,
 		&user.UpdatedAt,
 	)
@@ -53,27 +56,35 @@ func CreateUser(db *sql.DB, username, email, passwordHash, avatarURL string) err
 	return err
 }
 
+// UpdateUserPasswordHash replaces a user's stored password hash
+func UpdateUserPasswordHash(db *sql.DB, userID, passwordHash string) error {
+	_, err := db.Exec(`UPDATE users SET password_hash = ? WHERE id = ?`, passwordHash, userID)
+	return err
+}
+
 // CreatePost inserts a new post and its category associations
-func CreatePost(db *sql.DB, userID string, categoryIDs []int, title, content, imageURL string) (models.Post, error) {
+func CreatePost(db *sql.DB, userID string, categoryIDs []int, title, content, imageURL, status string) (models.Post, error) {
 	var post models.Post
 
 	// Insert into posts table
 	query := `
-		INSERT INTO posts (user_id, title, content, image_url)
-		VALUES (?, ?, ?, ?)
-		RETURNING id, user_id, title, content, image_url, created_at
+		INSERT INTO posts (user_id, title, content, image_url, status)
+		VALUES (?, ?, ?, ?, ?)
+		RETURNING id, user_id, title, content, image_url, created_at, status
 	`
-	err := db.QueryRow(query, userID, title, content, imageURL).Scan(
+	err := db.QueryRow(query, userID, title, content, imageURL, status).Scan(
 		&post.ID,
 		&post.UserID,
 		&post.Title,
 		&post.Content,
 		&post.ImageURL,
 		&post.CreatedAt,
+		&post.Status,
 	)
 	if err != nil {
 		return post, err
 	}
+	post.ContentHTML = markdown.RenderCached(post.Content)
 
 	// Insert into post_categories table
 	for _, catID := range categoryIDs {
@@ -87,15 +98,21 @@ func CreatePost(db *sql.DB, userID string, categoryIDs []int, title, content, im
 	return post, nil
 }
 
-// GetPost retrieves a single post by ID with its category IDs
-func GetPost(db *sql.DB, postID int) (models.Post, error) {
+// GetPost retrieves a single post by ID with its category IDs. Posts the
+// viewer may not see, or by users they blocked, are returned as
+// sql.ErrNoRows.
+func GetPost(db *sql.DB, viewer models.Viewer, postID int) (models.Post, error) {
 	var post models.Post
 
 	// Fetch main post data
+	visible, args := visibleTo("posts", viewer)
+	notBlocked, blockArgs := notBlockedBy("posts", viewer)
 	err := db.QueryRow(`
-        SELECT id, user_id, title, content, image_url, created_at, updated_at
-        FROM posts WHERE id = ?
-    `, postID).Scan(
+        SELECT id, user_id, title, content, image_url, created_at, updated_at, status,
+            accepted_comment_id, accepted_reply_id,
+            EXISTS(SELECT 1 FROM bookmarks WHERE post_id = posts.id AND user_id = ?)
+        FROM posts WHERE id = ? AND `+visible+` AND `+notBlocked+`
+    `, append(append([]any{viewer.UserID, postID}, args...), blockArgs...)...).Scan(
 		&post.ID,
 		&post.UserID,
 		&post.Title,
@@ -103,10 +120,16 @@ func GetPost(db *sql.DB, postID int) (models.Post, error) {
 		&post.ImageURL,
 		&post.CreatedAt,
 		&post.UpdatedAt,
+		&post.Status,
+		&post.AcceptedCommentID,
+		&post.AcceptedReplyID,
+		&post.IsBookmarked,
 	)
 	if err != nil {
 		return post, err
 	}
+	post.ContentHTML = markdown.RenderCached(post.Content)
+	post.Answered = post.AcceptedCommentID != nil || post.AcceptedReplyID != nil
 
 	// Fetch category IDs from join table
 	rows, err := db.Query(`SELECT category_id FROM post_categories WHERE post_id = ?`, postID)
@@ -132,13 +155,46 @@ func GetPost(db *sql.DB, postID int) (models.Post, error) {
 	}
 	post.CategoryNames = categoryNames
 
+	if err := AttachPoll(db, viewer, &post); err != nil {
+		return post, err
+	}
 	return post, nil
 }
 
-func GetPosts(db *sql.DB, page, limit int) ([]models.Post, error) {
+// GetPosts returns a page of the posts the viewer may see, newest first,
+// without those by users they blocked or muted or in categories they
+// muted. Cursor filters sort by ID, the key BeforeID continues from.
+func GetPosts(db *sql.DB, viewer models.Viewer, filter models.PostFilter, page, limit int) ([]models.Post, error) {
 	offset := (page - 1) * limit
 
 	// Query basic post data
+	visible, args := visibleTo("posts", viewer)
+	notMuted, muteArgs := notMutedBy("posts", viewer)
+	notInMuted, categoryArgs := notInMutedCategories("posts", viewer)
+	visible += " AND " + notMuted + " AND " + notInMuted
+	args = append(append(args, muteArgs...), categoryArgs...)
+	if filter.Unanswered {
+		visible += " AND posts.accepted_comment_id IS NULL AND posts.accepted_reply_id IS NULL"
+	}
+	if filter.FollowedBy != "" {
+		visible += ` AND (
+			posts.user_id IN (SELECT followed_id FROM user_follows WHERE follower_id = ?)
+			OR posts.id IN (
+				SELECT pc.post_id FROM post_categories pc
+				JOIN category_follows cf ON cf.category_id = pc.category_id
+				WHERE cf.user_id = ?
+			)
+		)`
+		args = append(args, filter.FollowedBy, filter.FollowedBy)
+	}
+	if filter.BeforeID > 0 {
+		visible += " AND posts.id < ?"
+		args = append(args, filter.BeforeID)
+	}
+	order := "posts.created_at DESC, posts.id DESC"
+	if filter.Cursor {
+		order = "posts.id DESC"
+	}
 	rows, err := db.Query(`
 		SELECT 
 			posts.id, 
@@ -148,12 +204,17 @@ func GetPosts(db *sql.DB, page, limit int) ([]models.Post, error) {
 			posts.content, 
 			posts.image_url,
 			posts.created_at, 
-			posts.updated_at
+			posts.updated_at,
+			posts.status,
+			posts.accepted_comment_id,
+			posts.accepted_reply_id,
+			EXISTS(SELECT 1 FROM bookmarks WHERE post_id = posts.id AND user_id = ?)
 		FROM posts
 		JOIN users ON posts.user_id = users.id
-		ORDER BY posts.created_at DESC
+		WHERE `+visible+`
+		ORDER BY `+order+`
 		LIMIT ? OFFSET ?
-	`, limit, offset)
+	`, append(append([]any{viewer.UserID}, args...), limit, offset)...)
 	if err != nil {
 		return nil, err
 	}
@@ -173,11 +234,17 @@ func GetPosts(db *sql.DB, page, limit int) ([]models.Post, error) {
 			&post.ImageURL,
 			&post.CreatedAt,
 			&post.UpdatedAt,
+			&post.Status,
+			&post.AcceptedCommentID,
+			&post.AcceptedReplyID,
+			&post.IsBookmarked,
 		)
 		if err != nil {
 			fmt.Println(err)
 			return nil, err
 		}
+		post.ContentHTML = markdown.RenderCached(post.Content)
+		post.Answered = post.AcceptedCommentID != nil || post.AcceptedReplyID != nil
 		post.CategoryIDs = []int{}
 		postMap[post.ID] = &post
 		postIDs = append(postIDs, post.ID)
@@ -215,6 +282,10 @@ func GetPosts(db *sql.DB, page, limit int) ([]models.Post, error) {
 		}
 	}
 
+	if err := attachPolls(db, viewer, postList(postMap)); err != nil {
+		return nil, err
+	}
+
 	// Build final slice from postMap in the original order and fetch category names
 	posts := make([]models.Post, 0, len(postMap))
 
@@ -239,8 +310,7 @@ func GetPosts(db *sql.DB, page, limit int) ([]models.Post, error) {
 
 // DeletePost removes a post by ID
 func DeletePost(db *sql.DB, postID int) error {
-	_, err := db.Exec(`DELETE FROM posts WHERE id = ?`, postID)
-	return err
+	return deleteContent(db, models.ContentPost, postID, `DELETE FROM posts WHERE id = ?`)
 }
 
 // GetOrCreateCategoryIDs resolves category names to IDs, creating new ones if needed.
@@ -266,7 +336,8 @@ func GetOrCreateCategoryIDs(db *sql.DB, names []string) ([]int, error) {
 	return ids, nil
 }
 
-// ToggleLike toggles a like for a post or comment
+// ToggleLike toggles a like for a post or comment, and updates the
+// reputation its author has from the user's reaction
 func ToggleLike(db *sql.DB, userID string, postID *int, commentID *int, reactionType string) error {
 	if reactionType != "like" && reactionType != "dislike" {
 		return errors.New("invalid reaction type")
@@ -275,6 +346,28 @@ func ToggleLike(db *sql.DB, userID string, postID *int, commentID *int, reaction
 		return errors.New("must provide either postID or commentID, but not both")
 	}
 
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	// Content waiting for review can only be reacted to by its author
+	table, contentType, contentID := "posts", models.ContentPost, postID
+	if commentID != nil {
+		table, contentType, contentID = "comments", models.ContentComment, commentID
+	}
+	var visible bool
+	err = tx.QueryRow(fmt.Sprintf(`
+		SELECT EXISTS(SELECT 1 FROM %s WHERE id = ? AND (status = 'published' OR user_id = ?))
+	`, table), *contentID, userID).Scan(&visible)
+	if err != nil {
+		return err
+	}
+	if !visible {
+		return sql.ErrNoRows
+	}
+
 	var existingType string
 	var query string
 	var args []any
@@ -287,35 +380,43 @@ func ToggleLike(db *sql.DB, userID string, postID *int, commentID *int, reaction
 		args = []any{userID, *commentID}
 	}
 
-	err := db.QueryRow(query, args...).Scan(&existingType)
+	err = tx.QueryRow(query, args...).Scan(&existingType)
 
+	newType := reactionType
 	switch {
 	case err == sql.ErrNoRows:
 		// No existing reaction — insert
 		if postID != nil {
-			_, err = db.Exec(`INSERT INTO likes (user_id, post_id, type) VALUES (?, ?, ?)`, userID, *postID, reactionType)
+			_, err = tx.Exec(`INSERT INTO likes (user_id, post_id, type) VALUES (?, ?, ?)`, userID, *postID, reactionType)
 		} else {
-			_, err = db.Exec(`INSERT INTO likes (user_id, comment_id, type) VALUES (?, ?, ?)`, userID, *commentID, reactionType)
+			_, err = tx.Exec(`INSERT INTO likes (user_id, comment_id, type) VALUES (?, ?, ?)`, userID, *commentID, reactionType)
 		}
 	case err == nil && existingType == reactionType:
 		// Same reaction exists — toggle off (delete)
+		newType = ""
 		if postID != nil {
-			_, err = db.Exec(`DELETE FROM likes WHERE user_id = ? AND post_id = ?`, userID, *postID)
+			_, err = tx.Exec(`DELETE FROM likes WHERE user_id = ? AND post_id = ?`, userID, *postID)
 		} else {
-			_, err = db.Exec(`DELETE FROM likes WHERE user_id = ? AND comment_id = ?`, userID, *commentID)
+			_, err = tx.Exec(`DELETE FROM likes WHERE user_id = ? AND comment_id = ?`, userID, *commentID)
 		}
 	case err == nil:
 		// Different reaction — update
 		if postID != nil {
-			_, err = db.Exec(`UPDATE likes SET type = ? WHERE user_id = ? AND post_id = ?`, reactionType, userID, *postID)
+			_, err = tx.Exec(`UPDATE likes SET type = ? WHERE user_id = ? AND post_id = ?`, reactionType, userID, *postID)
 		} else {
-			_, err = db.Exec(`UPDATE likes SET type = ? WHERE user_id = ? AND comment_id = ?`, reactionType, userID, *commentID)
+			_, err = tx.Exec(`UPDATE likes SET type = ? WHERE user_id = ? AND comment_id = ?`, reactionType, userID, *commentID)
 		}
 	default:
 		return err
 	}
+	if err != nil {
+		return err
+	}
 
-	return err
+	if err := reactionReputation(tx, contentType, *contentID, userID, existingType, newType); err != nil {
+		return err
+	}
+	return tx.Commit()
 }
 
 func CountLikesAndDislikes(db *sql.DB, postID *int, commentID *int) (likes int, dislikes int, err error) {
@@ -349,11 +450,17 @@ func CountLikesAndDislikes(db *sql.DB, postID *int, commentID *int) (likes int,
 	return
 }
 
-// GetPostsLikedByUser retrieves posts that a specific user has liked
-func GetPostsLikedByUser(db *sql.DB, userID string, page, limit int) ([]models.Post, error) {
+// GetPostsLikedByUser retrieves posts that the viewer has liked, without
+// those by users they blocked or muted or in categories they muted
+func GetPostsLikedByUser(db *sql.DB, viewer models.Viewer, page, limit int) ([]models.Post, error) {
 	offset := (page - 1) * limit
 
 	// Query posts that the user has liked
+	visible, args := visibleTo("posts", viewer)
+	notMuted, muteArgs := notMutedBy("posts", viewer)
+	notInMuted, categoryArgs := notInMutedCategories("posts", viewer)
+	visible += " AND " + notMuted + " AND " + notInMuted
+	args = append(append(args, muteArgs...), categoryArgs...)
 	rows, err := db.Query(`
 		SELECT
 			posts.id,
@@ -363,14 +470,18 @@ func GetPostsLikedByUser(db *sql.DB, userID string, page, limit int) ([]models.P
 			posts.content,
 			posts.image_url,
 			posts.created_at,
-			posts.updated_at
+			posts.updated_at,
+			posts.status,
+			posts.accepted_comment_id,
+			posts.accepted_reply_id,
+			EXISTS(SELECT 1 FROM bookmarks WHERE post_id = posts.id AND user_id = likes.user_id)
 		FROM posts
 		JOIN users ON posts.user_id = users.id
 		JOIN likes ON posts.id = likes.post_id
-		WHERE likes.user_id = ? AND likes.type = 'like'
+		WHERE likes.user_id = ? AND likes.type = 'like' AND `+visible+`
 		ORDER BY likes.created_at DESC
 		LIMIT ? OFFSET ?
-	`, userID, limit, offset)
+	`, append(append([]any{viewer.UserID}, args...), limit, offset)...)
 	if err != nil {
 		return nil, err
 	}
@@ -390,10 +501,16 @@ func GetPostsLikedByUser(db *sql.DB, userID string, page, limit int) ([]models.P
 			&post.ImageURL,
 			&post.CreatedAt,
 			&post.UpdatedAt,
+			&post.Status,
+			&post.AcceptedCommentID,
+			&post.AcceptedReplyID,
+			&post.IsBookmarked,
 		)
 		if err != nil {
 			return nil, err
 		}
+		post.ContentHTML = markdown.RenderCached(post.Content)
+		post.Answered = post.AcceptedCommentID != nil || post.AcceptedReplyID != nil
 		post.CategoryIDs = []int{}
 		postMap[post.ID] = &post
 		postIDs = append(postIDs, post.ID)
@@ -445,6 +562,10 @@ func GetPostsLikedByUser(db *sql.DB, userID string, page, limit int) ([]models.P
 		}
 	}
 
+	if err := attachPolls(db, viewer, postList(postMap)); err != nil {
+		return nil, err
+	}
+
 	// Convert map to slice, maintaining order
 	var posts []models.Post
 	for _, postID := range postIDs {
@@ -489,63 +610,83 @@ func IsUniqueConstraintError(err error) bool {
 }
 
 // CreateComment inserts a new comment
-func CreateComment(db *sql.DB, userID string, postID int, content string) (models.Comment, error) {
+func CreateComment(db *sql.DB, userID string, postID int, content, status string) (models.Comment, error) {
 	var comment models.Comment
 
 	query := `
-		INSERT INTO comments (user_id, post_id, content)
-		VALUES (?, ?, ?)
-		RETURNING id, user_id, post_id, content, created_at, updated_at
+		INSERT INTO comments (user_id, post_id, content, status)
+		VALUES (?, ?, ?, ?)
+		RETURNING id, user_id, post_id, content, created_at, updated_at, status
 	`
 
-	err := db.QueryRow(query, userID, postID, content).Scan(
+	err := db.QueryRow(query, userID, postID, content, status).Scan(
 		&comment.ID,
 		&comment.UserID,
 		&comment.PostID,
 		&comment.Content,
 		&comment.CreatedAt,
 		&comment.UpdatedAt,
+		&comment.Status,
 	)
 	if err != nil {
 		return comment, fmt.Errorf("failed to create comment: %w", err)
 	}
+	comment.ContentHTML = markdown.RenderCached(comment.Content)
 
 	return comment, err
 }
 
-func CreateReplyComment(db *sql.DB, userID string, parentCommentID int, content string) (models.ReplyComment, error) {
+func CreateReplyComment(db *sql.DB, userID string, parentCommentID int, content, status string) (models.ReplyComment, error) {
 	var reply models.ReplyComment
 
 	query := `
-		INSERT INTO replycomments (user_id, parent_comment_id, content)
-		VALUES (?, ?, ?)
-		RETURNING id, user_id, parent_comment_id, content, created_at, updated_at
+		INSERT INTO replycomments (user_id, parent_comment_id, content, status)
+		VALUES (?, ?, ?, ?)
+		RETURNING id, user_id, parent_comment_id, content, created_at, updated_at, status
 	`
 
-	err := db.QueryRow(query, userID, parentCommentID, content).Scan(
+	err := db.QueryRow(query, userID, parentCommentID, content, status).Scan(
 		&reply.ID,
 		&reply.UserID,
 		&reply.ParentCommentID,
 		&reply.Content,
 		&reply.CreatedAt,
 		&reply.UpdatedAt,
+		&reply.Status,
 	)
+	reply.ContentHTML = markdown.RenderCached(reply.Content)
 
 	return reply, err
 }
 
-// GetPostComments retrieves comments for a specific post
-func GetPostComments(db *sql.DB, postID int) ([]models.Comment, error) {
+// GetPostComments retrieves the comments on a post the viewer may see, and
+// their replies, without those by users they blocked or muted
+func GetPostComments(db *sql.DB, viewer models.Viewer, postID int) ([]models.Comment, error) {
+	postVisible, postArgs := visibleTo("p", viewer)
+	commentVisible, commentArgs := visibleTo("c", viewer)
+	replyVisible, replyArgs := visibleTo("r", viewer)
+	postNotBlocked, postBlockArgs := notBlockedBy("p", viewer)
+	commentNotMuted, commentMuteArgs := notMutedBy("c", viewer)
+	replyNotMuted, replyMuteArgs := notMutedBy("r", viewer)
+	postVisible += " AND " + postNotBlocked
+	postArgs = append(postArgs, postBlockArgs...)
+	commentVisible += " AND " + commentNotMuted
+	commentArgs = append(commentArgs, commentMuteArgs...)
+	replyVisible += " AND " + replyNotMuted
+	replyArgs = append(replyArgs, replyMuteArgs...)
+
 	// Step 1: Fetch top-level comments
 	commentRows, err := db.Query(`
 		SELECT
 			c.id, c.user_id, c.post_id, c.content,
-			c.created_at, c.updated_at, u.username, u.avatar_url
+			c.created_at, c.updated_at, u.username, u.avatar_url, c.status,
+			EXISTS(SELECT 1 FROM bookmarks WHERE comment_id = c.id AND user_id = ?)
 		FROM comments c
 		JOIN users u ON u.id = c.user_id
-		WHERE c.post_id = ?
+		JOIN posts p ON p.id = c.post_id
+		WHERE c.post_id = ? AND `+postVisible+` AND `+commentVisible+`
 		ORDER BY c.created_at ASC
-	`, postID)
+	`, append(append([]any{viewer.UserID, postID}, postArgs...), commentArgs...)...)
 	if err != nil {
 		return nil, err
 	}
@@ -565,26 +706,29 @@ func GetPostComments(db *sql.DB, postID int) ([]models.Comment, error) {
 			&c.UpdatedAt,
 			&c.UserName,
 			&c.ProfileAvatar,
+			&c.Status,
+			&c.IsBookmarked,
 		)
 		if err != nil {
 			return nil, err
 		}
+		c.ContentHTML = markdown.RenderCached(c.Content)
 		comments = append(comments, c)
 		commentsMap[c.ID] = len(comments) - 1 // store index instead of pointer
 	}
 
-	// Step 2: Fetch replies
+	// Step 2: Fetch replies; those under hidden comments are dropped below
 	replyRows, err := db.Query(`
 		SELECT
 			r.id, r.user_id, r.parent_comment_id, r.content,
-			r.created_at, r.updated_at, u.username, u.avatar_url
+			r.created_at, r.updated_at, u.username, u.avatar_url, r.status
 		FROM replycomments r
 		JOIN users u ON u.id = r.user_id
-		WHERE r.parent_comment_id IN (
+		WHERE `+replyVisible+` AND r.parent_comment_id IN (
 			SELECT id FROM comments WHERE post_id = ?
 		)
 		ORDER BY r.created_at ASC
-	`, postID)
+	`, append(replyArgs, postID)...)
 	if err != nil {
 		return nil, err
 	}
@@ -601,17 +745,63 @@ func GetPostComments(db *sql.DB, postID int) ([]models.Comment, error) {
 			&r.UpdatedAt,
 			&r.UserName,
 			&r.ProfileAvatar,
+			&r.Status,
 		)
 		if err != nil {
 			return nil, err
 		}
 
+		r.ContentHTML = markdown.RenderCached(r.Content)
+
 		if parentIndex, ok := commentsMap[r.ParentCommentID]; ok {
 			comments[parentIndex].Replies = append(comments[parentIndex].Replies, r)
 		}
 	}
+	if err := replyRows.Err(); err != nil {
+		return nil, err
+	}
 
-	return comments, nil
+	return comments, markAcceptedAnswer(db, postID, comments)
+}
+
+// markAcceptedAnswer flags a post's accepted answer among its comments and
+// moves it to the front: the comment itself, or for a reply its comment
+// and the reply within its replies
+func markAcceptedAnswer(db *sql.DB, postID int, comments []models.Comment) error {
+	var commentID, replyID sql.NullInt64
+	err := db.QueryRow(`SELECT accepted_comment_id, accepted_reply_id FROM posts WHERE id = ?`, postID).Scan(&commentID, &replyID)
+	if err == sql.ErrNoRows || (!commentID.Valid && !replyID.Valid) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	for i := range comments {
+		c := &comments[i]
+		found := commentID.Valid && int64(c.ID) == commentID.Int64
+		c.Accepted = found
+		for j := range c.Replies {
+			if replyID.Valid && int64(c.Replies[j].ID) == replyID.Int64 {
+				c.Replies[j].Accepted = true
+				moveToFront(c.Replies, j)
+				found = true
+			}
+		}
+		if found {
+			moveToFront(comments, i)
+			return nil
+		}
+	}
+	return nil
+}
+
+// moveToFront moves the i-th element of a slice to its start, keeping the
+// order of the others
+func moveToFront[T any](items []T, i int) {
+	item := items[i]
+	copy(items[1:i+1], items[:i])
+	items[0] = item
 }
 
 // CreateCategory inserts a new category
@@ -687,17 +877,32 @@ func UpdatePost(db *sql.DB, postID int, title, content string) error {
 
 // DeleteComment removes a comment from the database by its ID
 func DeleteComment(db *sql.DB, commentID int) error {
-	_, err := db.Exec(`
-		DELETE FROM comments WHERE id = ?
-	`, commentID)
-	return err
+	return deleteContent(db, models.ContentComment, commentID, `DELETE FROM comments WHERE id = ?`)
+}
+
+// deleteContent deletes a post or comment, and what is under it, together
+// with the reputation it earned
+func deleteContent(db *sql.DB, contentType string, contentID int, query string) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := revokeContentReputation(tx, contentType, contentID); err != nil {
+		return err
+	}
+	if _, err := tx.Exec(query, contentID); err != nil {
+		return err
+	}
+	return tx.Commit()
 }
 
 // GetUserByEmail retrieves a user by email
 func GetUserByEmail(db *sql.DB, email string) (models.User, error) {
 	var user models.User
 	err := db.QueryRow(`
-		SELECT id, username, email, password_hash, avatar_url, created_at, updated_at
+		SELECT id, username, email, password_hash, avatar_url, role, status, created_at, updated_at
 		FROM users
 		WHERE email = ?
 	`, email).Scan(
@@ -706,6 +911,8 @@ func GetUserByEmail(db *sql.DB, email string) (models.User, error) {
 		&user.Email,
 		&user.PasswordHash,
 		&user.AvatarURL,
+		&user.Role,
+		&user.Status,
 		&user.CreatedAt,
 		&user.UpdatedAt,
 	)
@@ -748,7 +955,7 @@ func GetUserByID(db *sql.DB, userID string) (*models.User, error) {
 	var user models.User
 
 	query := `
-		SELECT id, username, email, password_hash, avatar_url, created_at, updated_at
+		SELECT id, username, email, password_hash, avatar_url, role, status, created_at, updated_at
 		FROM users
 		WHERE id = ?
 	`
@@ -758,6 +965,8 @@ func GetUserByID(db *sql.DB, userID string) (*models.User, error) {
 		&user.Email,
 		&user.PasswordHash,
 		&user.AvatarURL,
+		&user.Role,
+		&user.Status,
 		&user.CreatedAt,
 		&user.UpdatedAt,
 	)
@@ -5,6 +5,8 @@ import (
 	"testing"
 	"time"
 
+	"forum/models"
+
 	_ "github.com/mattn/go-sqlite3"
 )
 
@@ -29,6 +31,11 @@ func setupTestDB(t *testing.T) *sql.DB {
 		email TEXT UNIQUE NOT NULL,
 		password_hash TEXT NOT NULL,
 		avatar_url TEXT DEFAULT '/static/default-avatar.png',
+		role TEXT NOT NULL DEFAULT 'user',
+		status TEXT NOT NULL DEFAULT 'active',
+		invited_by TEXT,
+		invite_code TEXT,
+		reputation INTEGER NOT NULL DEFAULT 0,
 		created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
 		updated_at DATETIME DEFAULT CURRENT_TIMESTAMP
 	);
@@ -46,6 +53,9 @@ func setupTestDB(t *testing.T) *sql.DB {
 		title TEXT NOT NULL,
 		content TEXT NOT NULL,
 		image_url TEXT,
+		status TEXT NOT NULL DEFAULT 'published',
+		accepted_comment_id INTEGER REFERENCES comments(id) ON DELETE SET NULL,
+		accepted_reply_id INTEGER REFERENCES replycomments(id) ON DELETE SET NULL,
 		created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
 		updated_at DATETIME DEFAULT CURRENT_TIMESTAMP,
 		FOREIGN KEY (user_id) REFERENCES users(id)
@@ -64,12 +74,193 @@ func setupTestDB(t *testing.T) *sql.DB {
 		user_id TEXT NOT NULL,
 		post_id INTEGER NOT NULL,
 		content TEXT NOT NULL,
+		status TEXT NOT NULL DEFAULT 'published',
 		created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
 		updated_at DATETIME DEFAULT CURRENT_TIMESTAMP,
 		FOREIGN KEY (user_id) REFERENCES users(id),
 		FOREIGN KEY (post_id) REFERENCES posts(id)
 	);
 
+	CREATE TABLE replycomments (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		user_id TEXT NOT NULL,
+		parent_comment_id INTEGER NOT NULL,
+		content TEXT NOT NULL,
+		status TEXT NOT NULL DEFAULT 'published',
+		created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+		updated_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+		FOREIGN KEY (user_id) REFERENCES users(id),
+		FOREIGN KEY (parent_comment_id) REFERENCES comments(id)
+	);
+
+	CREATE TABLE data_migrations (
+		name TEXT PRIMARY KEY,
+		applied_at DATETIME DEFAULT CURRENT_TIMESTAMP
+	);
+
+	CREATE TABLE mentions (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		content_type TEXT NOT NULL,
+		content_id INTEGER NOT NULL,
+		user_id TEXT NOT NULL,
+		author_id TEXT NOT NULL,
+		notified BOOLEAN NOT NULL DEFAULT 0,
+		created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+		UNIQUE (content_type, content_id, user_id)
+	);
+
+	CREATE TABLE polls (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		post_id INTEGER NOT NULL UNIQUE,
+		question TEXT NOT NULL,
+		multiple BOOLEAN NOT NULL DEFAULT 0,
+		hide_results BOOLEAN NOT NULL DEFAULT 0,
+		closes_at DATETIME NOT NULL,
+		created_at DATETIME DEFAULT CURRENT_TIMESTAMP
+	);
+
+	CREATE TABLE poll_options (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		poll_id INTEGER NOT NULL,
+		position INTEGER NOT NULL,
+		label TEXT NOT NULL,
+		UNIQUE (poll_id, id)
+	);
+
+	CREATE TABLE poll_ballots (
+		poll_id INTEGER NOT NULL,
+		user_id TEXT NOT NULL,
+		created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+		PRIMARY KEY (poll_id, user_id)
+	);
+
+	CREATE TABLE poll_votes (
+		poll_id INTEGER NOT NULL,
+		user_id TEXT NOT NULL,
+		option_id INTEGER NOT NULL,
+		PRIMARY KEY (poll_id, user_id, option_id),
+		FOREIGN KEY (poll_id, user_id) REFERENCES poll_ballots(poll_id, user_id),
+		FOREIGN KEY (poll_id, option_id) REFERENCES poll_options(poll_id, id)
+	);
+
+	CREATE TRIGGER poll_votes_single_choice
+	BEFORE INSERT ON poll_votes
+	FOR EACH ROW
+	WHEN (SELECT multiple FROM polls WHERE id = NEW.poll_id) = 0
+		AND EXISTS (SELECT 1 FROM poll_votes WHERE poll_id = NEW.poll_id AND user_id = NEW.user_id)
+	BEGIN
+		SELECT RAISE(ABORT, 'single choice poll');
+	END;
+
+	CREATE TABLE reputation_events (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		user_id TEXT NOT NULL,
+		reason TEXT NOT NULL,
+		content_type TEXT NOT NULL,
+		content_id INTEGER NOT NULL,
+		actor_id TEXT NOT NULL,
+		points INTEGER NOT NULL,
+		created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+		UNIQUE (reason, content_type, content_id, actor_id),
+		FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE,
+		FOREIGN KEY (actor_id) REFERENCES users(id) ON DELETE CASCADE
+	);
+
+	CREATE TRIGGER reputation_events_award
+	AFTER INSERT ON reputation_events
+	FOR EACH ROW
+	BEGIN
+		UPDATE users SET reputation = reputation + NEW.points WHERE id = NEW.user_id;
+	END;
+
+	CREATE TRIGGER reputation_events_revoke
+	AFTER DELETE ON reputation_events
+	FOR EACH ROW
+	BEGIN
+		UPDATE users SET reputation = reputation - OLD.points WHERE id = OLD.user_id;
+	END;
+
+	CREATE TABLE badge_awards (
+		user_id TEXT NOT NULL,
+		badge_id TEXT NOT NULL,
+		notified BOOLEAN NOT NULL DEFAULT 0,
+		awarded_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+		PRIMARY KEY (user_id, badge_id),
+		FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+	);
+
+	CREATE TABLE bookmarks (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		user_id TEXT NOT NULL,
+		post_id INTEGER,
+		comment_id INTEGER,
+		folder TEXT NOT NULL DEFAULT '',
+		note TEXT NOT NULL DEFAULT '',
+		created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+		CHECK ((post_id IS NULL) != (comment_id IS NULL)),
+		UNIQUE (user_id, post_id),
+		UNIQUE (user_id, comment_id),
+		FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE,
+		FOREIGN KEY (post_id) REFERENCES posts(id) ON DELETE CASCADE,
+		FOREIGN KEY (comment_id) REFERENCES comments(id) ON DELETE CASCADE
+	);
+
+	CREATE TABLE notification_preferences (
+		user_id TEXT NOT NULL,
+		type TEXT NOT NULL,
+		enabled BOOLEAN NOT NULL,
+		PRIMARY KEY (user_id, type),
+		FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+	);
+
+	CREATE TABLE user_follows (
+		follower_id TEXT NOT NULL,
+		followed_id TEXT NOT NULL,
+		created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+		PRIMARY KEY (follower_id, followed_id),
+		CHECK (follower_id != followed_id),
+		FOREIGN KEY (follower_id) REFERENCES users(id) ON DELETE CASCADE,
+		FOREIGN KEY (followed_id) REFERENCES users(id) ON DELETE CASCADE
+	);
+
+	CREATE TABLE category_follows (
+		user_id TEXT NOT NULL,
+		category_id INTEGER NOT NULL,
+		created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+		PRIMARY KEY (user_id, category_id),
+		FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE,
+		FOREIGN KEY (category_id) REFERENCES categories(id) ON DELETE CASCADE
+	);
+
+	CREATE TABLE user_blocks (
+		blocker_id TEXT NOT NULL,
+		blocked_id TEXT NOT NULL,
+		created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+		PRIMARY KEY (blocker_id, blocked_id),
+		CHECK (blocker_id != blocked_id),
+		FOREIGN KEY (blocker_id) REFERENCES users(id) ON DELETE CASCADE,
+		FOREIGN KEY (blocked_id) REFERENCES users(id) ON DELETE CASCADE
+	);
+
+	CREATE TABLE user_mutes (
+		user_id TEXT NOT NULL,
+		muted_id TEXT NOT NULL,
+		created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+		PRIMARY KEY (user_id, muted_id),
+		CHECK (user_id != muted_id),
+		FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE,
+		FOREIGN KEY (muted_id) REFERENCES users(id) ON DELETE CASCADE
+	);
+
+	CREATE TABLE category_mutes (
+		user_id TEXT NOT NULL,
+		category_id INTEGER NOT NULL,
+		created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+		PRIMARY KEY (user_id, category_id),
+		FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE,
+		FOREIGN KEY (category_id) REFERENCES categories(id) ON DELETE CASCADE
+	);
+
 	CREATE TABLE sessions (
 		id TEXT PRIMARY KEY,
 		user_id TEXT NOT NULL,
@@ -78,12 +269,12 @@ func setupTestDB(t *testing.T) *sql.DB {
 	);
 
 	CREATE TABLE likes (
-		id INTEGER PRIMARY KEY AUTOINCREMENT,
 		user_id TEXT NOT NULL,
 		post_id INTEGER,
 		comment_id INTEGER,
-		is_like BOOLEAN NOT NULL DEFAULT 1,
+		type TEXT NOT NULL CHECK(type IN ('like', 'dislike')),
 		created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+		PRIMARY KEY (user_id, post_id, comment_id),
 		FOREIGN KEY (user_id) REFERENCES users(id),
 		FOREIGN KEY (post_id) REFERENCES posts(id),
 		FOREIGN KEY (comment_id) REFERENCES comments(id)
@@ -227,7 +418,7 @@ func TestCreatePost(t *testing.T) {
 		content := "This is test post content"
 		imageURL := "/static/post-image.jpg"
 
-		post, err := CreatePost(db, userID, categoryIDs, title, content, imageURL)
+		post, err := CreatePost(db, userID, categoryIDs, title, content, imageURL, models.ContentPublished)
 		if err != nil {
 			t.Fatalf("CreatePost failed: %v", err)
 		}
@@ -252,7 +443,7 @@ func TestCreatePost(t *testing.T) {
 		content := "Content"
 		imageURL := ""
 
-		_, err := CreatePost(db, "invalid-user-id", categoryIDs, title, content, imageURL)
+		_, err := CreatePost(db, "invalid-user-id", categoryIDs, title, content, imageURL, models.ContentPublished)
 		if err == nil {
 			t.Fatal("Expected error for invalid user ID")
 		}
@@ -286,13 +477,13 @@ func TestGetPost(t *testing.T) {
 	content := "Test content"
 	imageURL := "/static/image.jpg"
 
-	createdPost, err := CreatePost(db, userID, categoryIDs, title, content, imageURL)
+	createdPost, err := CreatePost(db, userID, categoryIDs, title, content, imageURL, models.ContentPublished)
 	if err != nil {
 		t.Fatalf("Failed to create test post: %v", err)
 	}
 
 	t.Run("existing post", func(t *testing.T) {
-		post, err := GetPost(db, createdPost.ID)
+		post, err := GetPost(db, models.Viewer{}, createdPost.ID)
 		if err != nil {
 			t.Fatalf("GetPost failed: %v", err)
 		}
@@ -306,13 +497,122 @@ func TestGetPost(t *testing.T) {
 	})
 
 	t.Run("non-existing post", func(t *testing.T) {
-		_, err := GetPost(db, 99999)
+		_, err := GetPost(db, models.Viewer{}, 99999)
 		if err == nil {
 			t.Fatal("Expected error for non-existing post")
 		}
 	})
 }
 
+// A liked post drops out of the liked list once its category is muted,
+// as it does from the other post lists.
+func TestGetPostsLikedByUserMutedCategory(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	if err := CreateUser(db, "testuser", "test@example.com", "password", "/static/avatar.png"); err != nil {
+		t.Fatalf("Failed to create test user: %v", err)
+	}
+	user, err := GetUserByUsername(db, "testuser")
+	if err != nil {
+		t.Fatalf("Failed to get created user: %v", err)
+	}
+	if _, err := db.Exec("INSERT INTO categories (name) VALUES (?)", "Technology"); err != nil {
+		t.Fatalf("Failed to create test category: %v", err)
+	}
+	post, err := CreatePost(db, user.ID, []int{1}, "Test Post", "Test content", "", models.ContentPublished)
+	if err != nil {
+		t.Fatalf("Failed to create test post: %v", err)
+	}
+	if err := ToggleLike(db, user.ID, &post.ID, nil, "like"); err != nil {
+		t.Fatalf("Failed to like post: %v", err)
+	}
+
+	viewer := models.Viewer{UserID: user.ID}
+	liked, err := GetPostsLikedByUser(db, viewer, 1, 10)
+	if err != nil || len(liked) != 1 || liked[0].ID != post.ID {
+		t.Fatalf("Expected the liked post, got %+v (%v)", liked, err)
+	}
+
+	if _, err := MuteCategory(db, user.ID, 1); err != nil {
+		t.Fatalf("Failed to mute category: %v", err)
+	}
+	liked, err = GetPostsLikedByUser(db, viewer, 1, 10)
+	if err != nil || len(liked) != 0 {
+		t.Fatalf("Expected no posts from the muted category, got %+v (%v)", liked, err)
+	}
+}
+
+// One ballot per user and one option per single choice ballot hold even
+// for writes that skip CastVote
+func TestPollVoteConstraints(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	CreateUser(db, "testuser", "test@example.com", "password", "")
+	user, _ := GetUserByUsername(db, "testuser")
+	post, _ := CreatePost(db, user.ID, []int{}, "Poll", "Pick one", "", models.ContentPublished)
+	if err := CreatePoll(db, post.ID, models.NewPoll{Question: "Q?", Options: []string{"A", "B"}, ClosesAt: time.Now().Add(time.Hour)}); err != nil {
+		t.Fatalf("CreatePoll failed: %v", err)
+	}
+	other, _ := CreatePost(db, user.ID, []int{}, "Other", "Poll", "", models.ContentPublished)
+	CreatePoll(db, other.ID, models.NewPoll{Question: "R?", Options: []string{"C", "D"}, ClosesAt: time.Now().Add(time.Hour)})
+
+	if err := CastVote(db, 1, user.ID, []int{1}, false); err != nil {
+		t.Fatalf("CastVote failed: %v", err)
+	}
+	if err := CastVote(db, 1, user.ID, []int{2}, false); err != ErrAlreadyVoted {
+		t.Errorf("Expected ErrAlreadyVoted, got %v", err)
+	}
+	if err := CastVote(db, 1, user.ID, []int{3}, true); err != ErrInvalidOption {
+		t.Errorf("Expected an option of another poll refused, got %v", err)
+	}
+	if _, err := db.Exec(`INSERT INTO poll_ballots (poll_id, user_id) VALUES (1, ?)`, user.ID); err == nil {
+		t.Error("Expected a second ballot to violate the primary key")
+	}
+	if _, err := db.Exec(`INSERT INTO poll_votes (poll_id, user_id, option_id) VALUES (1, ?, 2)`, user.ID); err == nil {
+		t.Error("Expected a second option in a single choice poll to be refused")
+	}
+}
+
+func TestUnescapeContent(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	CreateUser(db, "testuser", "test@example.com", "password", "")
+	user, _ := GetUserByUsername(db, "testuser")
+
+	// Content as stored by older versions, HTML escaped
+	post, _ := CreatePost(db, user.ID, []int{}, "Generics", "Use `func Map[T any](s []T) &lt;-chan T` &amp; see", "", models.ContentPublished)
+	comment, _ := CreateComment(db, user.ID, post.ID, "I&#39;d say &quot;yes&quot;", models.ContentPublished)
+	reply, _ := CreateReplyComment(db, user.ID, comment.ID, "plain reply", models.ContentPublished)
+
+	changed, err := UnescapeContent(db)
+	if err != nil {
+		t.Fatalf("UnescapeContent failed: %v", err)
+	}
+	if changed["posts"] != 1 || changed["comments"] != 1 || changed["replycomments"] != 0 {
+		t.Errorf("Expected one post and one comment to change, got %v", changed)
+	}
+
+	got, _ := GetPost(db, models.Viewer{}, post.ID)
+	if got.Content != "Use `func Map[T any](s []T) <-chan T` & see" {
+		t.Errorf("Expected unescaped post content, got %q", got.Content)
+	}
+	if got.ContentHTML != "<p>Use <code>func Map[T any](s []T) &lt;-chan T</code> &amp; see</p>\n" {
+		t.Errorf("Unexpected rendered content %q", got.ContentHTML)
+	}
+	comments, _ := GetPostComments(db, models.Viewer{}, post.ID)
+	if len(comments) != 1 || comments[0].Content != `I'd say "yes"` || comments[0].Replies[0].ID != reply.ID {
+		t.Errorf("Expected the comment unescaped and the reply untouched, got %+v", comments)
+	}
+
+	// Escapes typed after the migration are content, not leftovers
+	if _, err := UnescapeContent(db); err != ErrMigrationApplied {
+		t.Errorf("Expected ErrMigrationApplied on the second run, got %v", err)
+	}
+}
+
 func TestCreateSession(t *testing.T) {
 	db := setupTestDB(t)
 	defer db.Close()
@@ -544,13 +844,13 @@ func TestDatabaseIntegration(t *testing.T) {
 			t.Fatalf("Failed to create category: %v", err)
 		}
 
-		post, err := CreatePost(db, user.ID, []int{1}, "Integration Post", "Test content", "")
+		post, err := CreatePost(db, user.ID, []int{1}, "Integration Post", "Test content", "", models.ContentPublished)
 		if err != nil {
 			t.Fatalf("Failed to create post: %v", err)
 		}
 
 		// Retrieve post
-		retrievedPost, err := GetPost(db, post.ID)
+		retrievedPost, err := GetPost(db, models.Viewer{}, post.ID)
 		if err != nil {
 			t.Fatalf("Failed to get post: %v", err)
 		}
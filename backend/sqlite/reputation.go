@@ -0,0 +1,237 @@
+package sqlite
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+
+	"forum/models"
+)
+
+// timestampLayout is how SQLite's CURRENT_TIMESTAMP writes times, in UTC
+const timestampLayout = "2006-01-02 15:04:05"
+
+// awardReputation records reputation a user gained or lost through their
+// content at a time. An award already recorded for the same reason,
+// content and actor is left alone. Triggers keep users.reputation up to
+// date.
+func awardReputation(tx *sql.Tx, userID, reason, contentType string, contentID int, actorID string, points int, at time.Time) error {
+	_, err := tx.Exec(`
+		INSERT OR IGNORE INTO reputation_events (user_id, reason, content_type, content_id, actor_id, points, created_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?)
+	`, userID, reason, contentType, contentID, actorID, points, at.UTC().Format(timestampLayout))
+	return err
+}
+
+// reactionReputation updates the reputation the author of a post or
+// comment has from a user's reaction to it: what the old reaction earned
+// is taken back and the new one, if any, is awarded. Reactions to one's
+// own content earn nothing.
+func reactionReputation(tx *sql.Tx, contentType string, contentID int, actorID, oldReaction, newReaction string) error {
+	table := "posts"
+	if contentType == models.ContentComment {
+		table = "comments"
+	}
+	var authorID string
+	if err := tx.QueryRow(fmt.Sprintf(`SELECT user_id FROM %s WHERE id = ?`, table), contentID).Scan(&authorID); err != nil {
+		return err
+	}
+	if authorID == actorID {
+		return nil
+	}
+
+	if oldReaction != "" {
+		_, err := tx.Exec(`
+			DELETE FROM reputation_events
+			WHERE reason = ? AND content_type = ? AND content_id = ? AND actor_id = ?
+		`, oldReaction, contentType, contentID, actorID)
+		if err != nil {
+			return err
+		}
+	}
+	if newReaction == "" {
+		return nil
+	}
+
+	now := time.Now()
+	points := models.ReactionPoints(contentType, newReaction)
+	if points > 0 {
+		var gained int
+		err := tx.QueryRow(`
+			SELECT COALESCE(SUM(points), 0) FROM reputation_events
+			WHERE user_id = ? AND reason = ? AND date(created_at) = ?
+		`, authorID, models.ReputationLike, now.UTC().Format("2006-01-02")).Scan(&gained)
+		if err != nil {
+			return err
+		}
+		points = cappedPoints(points, gained)
+	}
+	return awardReputation(tx, authorID, newReaction, contentType, contentID, actorID, points, now)
+}
+
+// cappedPoints is what is left of points gained from likes once a user
+// already gained some that day
+func cappedPoints(points, gained int) int {
+	return max(0, min(points, models.DailyReactionReputation-gained))
+}
+
+// RecountReputation rebuilds the reputation users earned with reactions
+// from the likes and dislikes in the database, as if each had been
+// awarded when it was given, and returns how many reactions counted. Other
+// reputation is left alone.
+func RecountReputation(db *sql.DB) (int, error) {
+	tx, err := db.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(`DELETE FROM reputation_events WHERE reason IN (?, ?)`, models.ReputationLike, models.ReputationDislike); err != nil {
+		return 0, err
+	}
+
+	rows, err := tx.Query(`
+		SELECT l.user_id, l.type, 'post', l.post_id, p.user_id, l.created_at
+		FROM likes l JOIN posts p ON p.id = l.post_id
+		WHERE l.post_id IS NOT NULL AND l.user_id != p.user_id
+		UNION ALL
+		SELECT l.user_id, l.type, 'comment', l.comment_id, c.user_id, l.created_at
+		FROM likes l JOIN comments c ON c.id = l.comment_id
+		WHERE l.comment_id IS NOT NULL AND l.user_id != c.user_id
+		ORDER BY 6
+	`)
+	if err != nil {
+		return 0, err
+	}
+	type reaction struct {
+		actorID, reaction, contentType, authorID string
+		contentID                                int
+		at                                       time.Time
+	}
+	var reactions []reaction
+	for rows.Next() {
+		var r reaction
+		if err := rows.Scan(&r.actorID, &r.reaction, &r.contentType, &r.contentID, &r.authorID, &r.at); err != nil {
+			rows.Close()
+			return 0, err
+		}
+		reactions = append(reactions, r)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, err
+	}
+
+	// Likes gained per user and day, for the daily cap
+	gained := map[string]int{}
+	for _, r := range reactions {
+		points := models.ReactionPoints(r.contentType, r.reaction)
+		if points > 0 {
+			day := r.authorID + " " + r.at.UTC().Format("2006-01-02")
+			points = cappedPoints(points, gained[day])
+			gained[day] += points
+		}
+		if err := awardReputation(tx, r.authorID, r.reaction, r.contentType, r.contentID, r.actorID, points, r.at); err != nil {
+			return 0, err
+		}
+	}
+	return len(reactions), tx.Commit()
+}
+
+// revokeContentReputation takes back the reputation earned with content
+// that is about to be deleted: a post with its comments and replies, or a
+// comment with its replies
+func revokeContentReputation(tx *sql.Tx, contentType string, contentID int) error {
+	comments := `SELECT ?`
+	if contentType == models.ContentPost {
+		comments = `SELECT id FROM comments WHERE post_id = ?`
+	}
+	_, err := tx.Exec(`
+		DELETE FROM reputation_events
+		WHERE (content_type = ? AND content_id = ?)
+			OR (content_type = 'comment' AND content_id IN (`+comments+`))
+			OR (content_type = 'reply' AND content_id IN (
+				SELECT id FROM replycomments WHERE parent_comment_id IN (`+comments+`)
+			))
+	`, contentType, contentID, contentID, contentID)
+	return err
+}
+
+// GetReputationHistory returns a page of the reputation a user gained and
+// lost, newest first
+func GetReputationHistory(db *sql.DB, userID string, page, limit int) ([]models.ReputationEvent, error) {
+	rows, err := db.Query(`
+		SELECT e.reason, e.content_type, e.content_id,
+			COALESCE(CASE e.content_type
+				WHEN 'post' THEN e.content_id
+				WHEN 'comment' THEN (SELECT post_id FROM comments WHERE id = e.content_id)
+				ELSE (SELECT c.post_id FROM replycomments r JOIN comments c ON c.id = r.parent_comment_id WHERE r.id = e.content_id)
+			END, 0),
+			e.points, e.created_at
+		FROM reputation_events e
+		WHERE e.user_id = ?
+		ORDER BY e.created_at DESC, e.id DESC
+		LIMIT ? OFFSET ?
+	`, userID, limit, (page-1)*limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	history := []models.ReputationEvent{}
+	for rows.Next() {
+		var e models.ReputationEvent
+		if err := rows.Scan(&e.Reason, &e.ContentType, &e.ContentID, &e.PostID, &e.Points, &e.CreatedAt); err != nil {
+			return nil, err
+		}
+		history = append(history, e)
+	}
+	return history, rows.Err()
+}
+
+// GetReputationLeaderboard ranks active users by the reputation they
+// gained since a time, or by their reputation when since is zero. Users
+// with equal reputation share a rank.
+func GetReputationLeaderboard(db *sql.DB, since time.Time, limit int) ([]models.LeaderboardEntry, error) {
+	query := `
+		SELECT username, COALESCE(avatar_url, ''), reputation AS score
+		FROM users
+		WHERE status = ? AND reputation > 0
+		ORDER BY score DESC, username
+		LIMIT ?
+	`
+	args := []any{models.StatusActive, limit}
+	if !since.IsZero() {
+		query = `
+			SELECT u.username, COALESCE(u.avatar_url, ''), SUM(e.points) AS score
+			FROM reputation_events e
+			JOIN users u ON u.id = e.user_id
+			WHERE u.status = ? AND e.created_at >= ?
+			GROUP BY u.id
+			HAVING score > 0
+			ORDER BY score DESC, u.username
+			LIMIT ?
+		`
+		args = []any{models.StatusActive, since.UTC().Format(timestampLayout), limit}
+	}
+
+	rows, err := db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	leaders := []models.LeaderboardEntry{}
+	for rows.Next() {
+		var entry models.LeaderboardEntry
+		if err := rows.Scan(&entry.Username, &entry.AvatarURL, &entry.Reputation); err != nil {
+			return nil, err
+		}
+		entry.Rank = len(leaders) + 1
+		if previous := len(leaders) - 1; previous >= 0 && leaders[previous].Reputation == entry.Reputation {
+			entry.Rank = leaders[previous].Rank
+		}
+		leaders = append(leaders, entry)
+	}
+	return leaders, rows.Err()
+}
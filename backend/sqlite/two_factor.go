@@ -0,0 +1,160 @@
+package sqlite
+
+import (
+	"crypto/rand"
+	"database/sql"
+	"encoding/base64"
+	"time"
+
+	"forum/models"
+)
+
+// GetTOTP retrieves a user's TOTP enrollment. sql.ErrNoRows means the user never enrolled.
+func GetTOTP(db *sql.DB, userID string) (models.TOTP, error) {
+	var t models.TOTP
+	err := db.QueryRow(`
+		SELECT user_id, secret, enabled, last_used_step
+		FROM user_totp WHERE user_id = ?
+	`, userID).Scan(&t.UserID, &t.Secret, &t.Enabled, &t.LastUsedStep)
+	return t, err
+}
+
+// IsTOTPEnabled reports whether a user has confirmed TOTP enrollment
+func IsTOTPEnabled(db *sql.DB, userID string) (bool, error) {
+	t, err := GetTOTP(db, userID)
+	if err == sql.ErrNoRows {
+		return false, nil
+	}
+	return t.Enabled, err
+}
+
+// SaveTOTPSecret stores a new, not yet confirmed, secret for a user
+func SaveTOTPSecret(db *sql.DB, userID, secret string) error {
+	_, err := db.Exec(`
+		INSERT INTO user_totp (user_id, secret, enabled, last_used_step)
+		VALUES (?, ?, 0, 0)
+		ON CONFLICT(user_id) DO UPDATE SET secret = excluded.secret, enabled = 0, last_used_step = 0
+	`, userID, secret)
+	return err
+}
+
+// EnableTOTP confirms enrollment and replaces the user's recovery codes
+func EnableTOTP(db *sql.DB, userID string, step int64, recoveryCodeHashes []string) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(`UPDATE user_totp SET enabled = 1, last_used_step = ? WHERE user_id = ?`, step, userID); err != nil {
+		return err
+	}
+	if _, err := tx.Exec(`DELETE FROM recovery_codes WHERE user_id = ?`, userID); err != nil {
+		return err
+	}
+	for _, hash := range recoveryCodeHashes {
+		if _, err := tx.Exec(`INSERT INTO recovery_codes (user_id, code_hash) VALUES (?, ?)`, userID, hash); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// SetTOTPLastUsedStep records the last accepted time step so codes can't be
+// replayed. It reports false when the step or a later one was already used,
+// for example by a concurrent login with the same code.
+func SetTOTPLastUsedStep(db *sql.DB, userID string, step int64) (bool, error) {
+	result, err := db.Exec(`
+		UPDATE user_totp SET last_used_step = ? WHERE user_id = ? AND last_used_step < ?
+	`, step, userID, step)
+	if err != nil {
+		return false, err
+	}
+	n, err := result.RowsAffected()
+	return n == 1, err
+}
+
+// DeleteTOTP disables two-factor authentication and removes the recovery codes
+func DeleteTOTP(db *sql.DB, userID string) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(`DELETE FROM user_totp WHERE user_id = ?`, userID); err != nil {
+		return err
+	}
+	if _, err := tx.Exec(`DELETE FROM recovery_codes WHERE user_id = ?`, userID); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// UseRecoveryCode marks an unused recovery code as used. It reports false if no such code exists.
+func UseRecoveryCode(db *sql.DB, userID, codeHash string) (bool, error) {
+	result, err := db.Exec(`
+		UPDATE recovery_codes SET used_at = ?
+		WHERE id = (
+			SELECT id FROM recovery_codes
+			WHERE user_id = ? AND code_hash = ? AND used_at IS NULL
+			LIMIT 1
+		)
+	`, time.Now().UTC(), userID, codeHash)
+	if err != nil {
+		return false, err
+	}
+	n, err := result.RowsAffected()
+	return n == 1, err
+}
+
+// CountUnusedRecoveryCodes returns how many recovery codes a user has left
+func CountUnusedRecoveryCodes(db *sql.DB, userID string) (int, error) {
+	var count int
+	err := db.QueryRow(`SELECT COUNT(*) FROM recovery_codes WHERE user_id = ? AND used_at IS NULL`, userID).Scan(&count)
+	return count, err
+}
+
+// CreatePendingLogin starts a login that is waiting for a second factor and returns its token
+func CreatePendingLogin(db *sql.DB, userID string, expiresAt time.Time) (string, error) {
+	raw := make([]byte, 32)
+	if _, err := rand.Read(raw); err != nil {
+		return "", err
+	}
+	token := base64.RawURLEncoding.EncodeToString(raw)
+
+	_, err := db.Exec(`
+		INSERT INTO pending_logins (token, user_id, expires_at) VALUES (?, ?, ?)
+	`, token, userID, expiresAt.UTC())
+	if err != nil {
+		return "", err
+	}
+	return token, nil
+}
+
+// GetPendingLogin retrieves a pending login by token
+func GetPendingLogin(db *sql.DB, token string) (models.PendingLogin, error) {
+	var p models.PendingLogin
+	err := db.QueryRow(`
+		SELECT token, user_id, attempts, expires_at FROM pending_logins WHERE token = ?
+	`, token).Scan(&p.Token, &p.UserID, &p.Attempts, &p.ExpiresAt)
+	return p, err
+}
+
+// IncrementPendingLoginAttempts counts a wrong second factor against a pending login
+func IncrementPendingLoginAttempts(db *sql.DB, token string) error {
+	_, err := db.Exec(`UPDATE pending_logins SET attempts = attempts + 1 WHERE token = ?`, token)
+	return err
+}
+
+// DeletePendingLogin removes a pending login once it is used or abandoned
+func DeletePendingLogin(db *sql.DB, token string) error {
+	_, err := db.Exec(`DELETE FROM pending_logins WHERE token = ?`, token)
+	return err
+}
+
+// CleanupPendingLogins removes expired pending logins
+func CleanupPendingLogins(db *sql.DB) error {
+	_, err := db.Exec(`DELETE FROM pending_logins WHERE expires_at <= ?`, time.Now().UTC())
+	return err
+}
@@ -0,0 +1,38 @@
+package sqlite
+
+import "database/sql"
+
+// ReplaceUploadURL rewrites every avatar and post image reference from oldURL to newURL
+func ReplaceUploadURL(db *sql.DB, oldURL, newURL string) error {
+	if oldURL == newURL {
+		return nil
+	}
+	if _, err := db.Exec(`UPDATE users SET avatar_url = ? WHERE avatar_url = ?`, newURL, oldURL); err != nil {
+		return err
+	}
+	_, err := db.Exec(`UPDATE posts SET image_url = ? WHERE image_url = ?`, newURL, oldURL)
+	return err
+}
+
+// GetReferencedUploadURLs returns every avatar and post image URL stored in the database
+func GetReferencedUploadURLs(db *sql.DB) (map[string]bool, error) {
+	rows, err := db.Query(`
+		SELECT avatar_url FROM users WHERE avatar_url IS NOT NULL AND avatar_url != ''
+		UNION
+		SELECT image_url FROM posts WHERE image_url IS NOT NULL AND image_url != ''
+	`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	urls := make(map[string]bool)
+	for rows.Next() {
+		var url string
+		if err := rows.Scan(&url); err != nil {
+			return nil, err
+		}
+		urls[url] = true
+	}
+	return urls, rows.Err()
+}
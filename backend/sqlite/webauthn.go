@@ -0,0 +1,129 @@
+package sqlite
+
+import (
+	"crypto/rand"
+	"database/sql"
+	"encoding/base64"
+	"time"
+
+	"forum/models"
+)
+
+// CreateWebAuthnCredential stores a newly registered passkey
+func CreateWebAuthnCredential(db *sql.DB, cred models.WebAuthnCredential) error {
+	_, err := db.Exec(`
+		INSERT INTO webauthn_credentials (id, user_id, public_key, sign_count, name)
+		VALUES (?, ?, ?, ?, ?)
+	`, cred.ID, cred.UserID, cred.PublicKey, cred.SignCount, cred.Name)
+	return err
+}
+
+func scanWebAuthnCredential(scan func(dest ...any) error) (models.WebAuthnCredential, error) {
+	var cred models.WebAuthnCredential
+	var lastUsed sql.NullTime
+	err := scan(&cred.ID, &cred.UserID, &cred.PublicKey, &cred.SignCount, &cred.Name, &cred.CreatedAt, &lastUsed)
+	if lastUsed.Valid {
+		cred.LastUsedAt = &lastUsed.Time
+	}
+	return cred, err
+}
+
+// GetWebAuthnCredential retrieves a passkey by its base64url credential ID
+func GetWebAuthnCredential(db *sql.DB, id string) (models.WebAuthnCredential, error) {
+	return scanWebAuthnCredential(db.QueryRow(`
+		SELECT id, user_id, public_key, sign_count, name, created_at, last_used_at
+		FROM webauthn_credentials WHERE id = ?
+	`, id).Scan)
+}
+
+// GetWebAuthnCredentials lists a user's passkeys, oldest first
+func GetWebAuthnCredentials(db *sql.DB, userID string) ([]models.WebAuthnCredential, error) {
+	rows, err := db.Query(`
+		SELECT id, user_id, public_key, sign_count, name, created_at, last_used_at
+		FROM webauthn_credentials WHERE user_id = ?
+		ORDER BY created_at ASC
+	`, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	credentials := []models.WebAuthnCredential{}
+	for rows.Next() {
+		cred, err := scanWebAuthnCredential(rows.Scan)
+		if err != nil {
+			return nil, err
+		}
+		credentials = append(credentials, cred)
+	}
+	return credentials, rows.Err()
+}
+
+// UpdateWebAuthnCredentialUse records a successful login with a passkey
+func UpdateWebAuthnCredentialUse(db *sql.DB, id string, signCount uint32) error {
+	_, err := db.Exec(`
+		UPDATE webauthn_credentials SET sign_count = ?, last_used_at = ? WHERE id = ?
+	`, signCount, time.Now().UTC(), id)
+	return err
+}
+
+// DeleteWebAuthnCredential removes one of a user's passkeys. sql.ErrNoRows
+// means the user has no passkey with that ID.
+func DeleteWebAuthnCredential(db *sql.DB, userID, id string) error {
+	result, err := db.Exec(`DELETE FROM webauthn_credentials WHERE id = ? AND user_id = ?`, id, userID)
+	if err != nil {
+		return err
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+// CreateWebAuthnCeremony stores a challenge and returns the token for the pre-session cookie.
+// userID is empty for logins.
+func CreateWebAuthnCeremony(db *sql.DB, userID, ceremony, challenge string, ttl time.Duration) (string, error) {
+	raw := make([]byte, 32)
+	if _, err := rand.Read(raw); err != nil {
+		return "", err
+	}
+	token := base64.RawURLEncoding.EncodeToString(raw)
+
+	var user sql.NullString
+	if userID != "" {
+		user = sql.NullString{String: userID, Valid: true}
+	}
+	_, err := db.Exec(`
+		INSERT INTO webauthn_ceremonies (token, user_id, ceremony, challenge, expires_at)
+		VALUES (?, ?, ?, ?, ?)
+	`, token, user, ceremony, challenge, time.Now().Add(ttl).UTC())
+	if err != nil {
+		return "", err
+	}
+	return token, nil
+}
+
+// TakeWebAuthnCeremony retrieves and deletes a ceremony so each challenge is
+// answered at most once. Expired ceremonies are returned as sql.ErrNoRows.
+func TakeWebAuthnCeremony(db *sql.DB, token, ceremony string) (models.WebAuthnCeremony, error) {
+	var c models.WebAuthnCeremony
+	var user sql.NullString
+	err := db.QueryRow(`
+		DELETE FROM webauthn_ceremonies WHERE token = ? AND ceremony = ?
+		RETURNING token, user_id, ceremony, challenge, expires_at
+	`, token, ceremony).Scan(&c.Token, &user, &c.Ceremony, &c.Challenge, &c.ExpiresAt)
+	if err != nil {
+		return c, err
+	}
+	c.UserID = user.String
+	if !time.Now().Before(c.ExpiresAt) {
+		return c, sql.ErrNoRows
+	}
+	return c, nil
+}
+
+// CleanupWebAuthnCeremonies removes expired challenges
+func CleanupWebAuthnCeremonies(db *sql.DB) error {
+	_, err := db.Exec(`DELETE FROM webauthn_ceremonies WHERE expires_at <= ?`, time.Now().UTC())
+	return err
+}
@@ -0,0 +1,47 @@
+package storage
+
+import (
+	"fmt"
+	"os"
+	"strings"
+)
+
+// FromEnv builds the storage backend selected by STORAGE_BACKEND ("local" or "s3")
+//
+// local: STORAGE_LOCAL_DIR (default "static")
+// s3:    S3_ENDPOINT, S3_REGION, S3_BUCKET, S3_ACCESS_KEY_ID, S3_SECRET_ACCESS_KEY,
+//
+//	S3_PATH_STYLE (default true), S3_PUBLIC_URL (optional)
+func FromEnv() (Storage, error) {
+	return FromName(os.Getenv("STORAGE_BACKEND"))
+}
+
+// FromName builds a storage backend by name using the environment for its settings
+func FromName(name string) (Storage, error) {
+	switch strings.ToLower(name) {
+	case "", "local":
+		dir := os.Getenv("STORAGE_LOCAL_DIR")
+		if dir == "" {
+			dir = "static" // fallback default
+		}
+		return NewLocal(dir, URLPrefix), nil
+	case "s3":
+		endpoint := os.Getenv("S3_ENDPOINT")
+		bucket := os.Getenv("S3_BUCKET")
+		if endpoint == "" || bucket == "" {
+			return nil, fmt.Errorf("S3_ENDPOINT and S3_BUCKET are required for the s3 storage backend")
+		}
+		region := os.Getenv("S3_REGION")
+		if region == "" {
+			region = "us-east-1"
+		}
+		s := NewS3(endpoint, region, bucket, os.Getenv("S3_ACCESS_KEY_ID"), os.Getenv("S3_SECRET_ACCESS_KEY"))
+		if v := os.Getenv("S3_PATH_STYLE"); v == "false" || v == "0" {
+			s.PathStyle = false
+		}
+		s.PublicURL = os.Getenv("S3_PUBLIC_URL")
+		return s, nil
+	default:
+		return nil, fmt.Errorf("unknown storage backend %q (use local or s3)", name)
+	}
+}
@@ -0,0 +1,78 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+)
+
+// GCReport summarises an orphaned upload collection run
+type GCReport struct {
+	Scanned    int
+	Referenced int
+	TooRecent  int      // Unreferenced but still inside the grace period
+	Orphaned   []Object // Unreferenced and older than the grace period
+	Deleted    int
+	Failed     int
+}
+
+// isUploadKey reports whether key is a user upload: avatars live at the
+// storage root and post images under pictures/
+func isUploadKey(key string) bool {
+	if BundledAssets[key] {
+		return false
+	}
+	return !strings.Contains(key, "/") || strings.HasPrefix(key, "pictures/")
+}
+
+// CollectGarbage finds uploads in s whose URL is not in referenced and that
+// are older than grace, and deletes them unless dryRun is set. The grace
+// period protects files whose user or post row has not been written yet.
+func CollectGarbage(ctx context.Context, s Storage, referenced map[string]bool, grace time.Duration, dryRun bool) (GCReport, error) {
+	var report GCReport
+
+	objects, err := s.List(ctx, "")
+	if err != nil {
+		return report, fmt.Errorf("failed to list uploads: %w", err)
+	}
+
+	cutoff := time.Now().Add(-grace)
+	for _, obj := range objects {
+		if !isUploadKey(obj.Key) {
+			continue
+		}
+		report.Scanned++
+
+		if referenced[s.URL(obj.Key)] {
+			report.Referenced++
+			continue
+		}
+		if obj.ModTime.After(cutoff) {
+			report.TooRecent++
+			continue
+		}
+
+		report.Orphaned = append(report.Orphaned, obj)
+		if dryRun {
+			continue
+		}
+		if err := s.Delete(ctx, obj.Key); err != nil {
+			log.Printf("❌ Failed to delete orphaned upload %s: %v", obj.Key, err)
+			report.Failed++
+			continue
+		}
+		report.Deleted++
+	}
+	return report, nil
+}
+
+// OrphanedBytes returns the total size of the orphaned uploads
+func (r GCReport) OrphanedBytes() int64 {
+	var total int64
+	for _, obj := range r.Orphaned {
+		total += obj.Size
+	}
+	return total
+}
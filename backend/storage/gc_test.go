@@ -0,0 +1,93 @@
+package storage
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCollectGarbage(t *testing.T) {
+	ctx := context.Background()
+	root := t.TempDir()
+	s := NewLocal(root, URLPrefix)
+
+	old := time.Now().Add(-48 * time.Hour)
+	files := map[string]time.Time{
+		"avatar_1_me.jpg":       old,        // referenced by a user
+		"avatar_2_me.jpg":       old,        // orphaned duplicate
+		"pictures/post_a_1.png": old,        // referenced by a post
+		"pictures/post_a_2.png": old,        // orphaned
+		"pictures/post_a_3.png": time.Now(), // orphaned but inside the grace period
+		"profiles/default.png":  old,        // bundled asset
+		"profiles/other.png":    old,        // outside the upload directories
+	}
+	for key, modTime := range files {
+		if err := s.Put(ctx, key, strings.NewReader("x"), ""); err != nil {
+			t.Fatalf("Put %s failed: %v", key, err)
+		}
+		os.Chtimes(filepath.Join(root, filepath.FromSlash(key)), modTime, modTime)
+	}
+
+	referenced := map[string]bool{
+		"/static/avatar_1_me.jpg":       true,
+		"/static/pictures/post_a_1.png": true,
+		"/static/profiles/default.png":  true,
+	}
+
+	t.Run("dry run reports without deleting", func(t *testing.T) {
+		report, err := CollectGarbage(ctx, s, referenced, 24*time.Hour, true)
+		if err != nil {
+			t.Fatalf("CollectGarbage failed: %v", err)
+		}
+		if report.Scanned != 5 || report.Referenced != 2 || report.TooRecent != 1 || len(report.Orphaned) != 2 {
+			t.Errorf("Unexpected report: %+v", report)
+		}
+		if report.Deleted != 0 {
+			t.Errorf("Dry run must not delete, deleted %d", report.Deleted)
+		}
+		if _, err := os.Stat(filepath.Join(root, "avatar_2_me.jpg")); err != nil {
+			t.Errorf("Dry run removed a file: %v", err)
+		}
+	})
+
+	t.Run("deletes orphaned files", func(t *testing.T) {
+		report, err := CollectGarbage(ctx, s, referenced, 24*time.Hour, false)
+		if err != nil {
+			t.Fatalf("CollectGarbage failed: %v", err)
+		}
+		if report.Deleted != 2 {
+			t.Errorf("Expected 2 deletions, got %d", report.Deleted)
+		}
+
+		for key := range files {
+			_, err := os.Stat(filepath.Join(root, filepath.FromSlash(key)))
+			gone := os.IsNotExist(err)
+			wantGone := key == "avatar_2_me.jpg" || key == "pictures/post_a_2.png"
+			if gone != wantGone {
+				t.Errorf("%s: expected removed=%v, got %v", key, wantGone, gone)
+			}
+		}
+	})
+}
+
+func TestDeleteURL(t *testing.T) {
+	ctx := context.Background()
+	root := t.TempDir()
+	s := NewLocal(root, URLPrefix)
+	s.Put(ctx, "avatar_1.png", strings.NewReader("x"), "")
+	s.Put(ctx, "profiles/default.png", strings.NewReader("x"), "")
+
+	DeleteURL(ctx, s, "/static/avatar_1.png")
+	DeleteURL(ctx, s, "/static/profiles/default.png")
+	DeleteURL(ctx, s, "")
+
+	if _, err := os.Stat(filepath.Join(root, "avatar_1.png")); !os.IsNotExist(err) {
+		t.Error("Expected upload to be deleted")
+	}
+	if _, err := os.Stat(filepath.Join(root, "profiles", "default.png")); err != nil {
+		t.Error("Bundled asset must not be deleted")
+	}
+}
@@ -0,0 +1,153 @@
+package storage
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestLocalStorage(t *testing.T) {
+	ctx := context.Background()
+	root := t.TempDir()
+	s := NewLocal(root, URLPrefix)
+
+	t.Run("put creates directories", func(t *testing.T) {
+		if err := s.Put(ctx, "pictures/post_1.png", strings.NewReader("png"), "image/png"); err != nil {
+			t.Fatalf("Put failed: %v", err)
+		}
+		data, err := os.ReadFile(filepath.Join(root, "pictures", "post_1.png"))
+		if err != nil {
+			t.Fatalf("Expected file on disk: %v", err)
+		}
+		if string(data) != "png" {
+			t.Errorf("Expected 'png', got %q", data)
+		}
+	})
+
+	t.Run("get", func(t *testing.T) {
+		r, err := s.Get(ctx, "pictures/post_1.png")
+		if err != nil {
+			t.Fatalf("Get failed: %v", err)
+		}
+		defer r.Close()
+		data, _ := io.ReadAll(r)
+		if string(data) != "png" {
+			t.Errorf("Expected 'png', got %q", data)
+		}
+
+		if _, err := s.Get(ctx, "missing.png"); err != ErrNotFound {
+			t.Errorf("Expected ErrNotFound, got %v", err)
+		}
+	})
+
+	t.Run("keys cannot escape the root", func(t *testing.T) {
+		if err := s.Put(ctx, "../../etc/evil", strings.NewReader("x"), ""); err != nil {
+			t.Fatalf("Put failed: %v", err)
+		}
+		if _, err := os.Stat(filepath.Join(root, "etc", "evil")); err != nil {
+			t.Errorf("Expected traversal key to be confined to the root: %v", err)
+		}
+	})
+
+	t.Run("list and delete", func(t *testing.T) {
+		objects, err := s.List(ctx, "pictures/")
+		if err != nil {
+			t.Fatalf("List failed: %v", err)
+		}
+		if len(objects) != 1 || objects[0].Key != "pictures/post_1.png" {
+			t.Fatalf("Unexpected listing: %+v", objects)
+		}
+
+		if err := s.Delete(ctx, "pictures/post_1.png"); err != nil {
+			t.Fatalf("Delete failed: %v", err)
+		}
+		if err := s.Delete(ctx, "pictures/post_1.png"); err != nil {
+			t.Errorf("Deleting a missing file should succeed, got %v", err)
+		}
+	})
+
+	t.Run("url", func(t *testing.T) {
+		if got := s.URL("avatar_1.png"); got != "/static/avatar_1.png" {
+			t.Errorf("Expected /static/avatar_1.png, got %q", got)
+		}
+		key, ok := KeyFromURL(s, "/static/pictures/post_1.png")
+		if !ok || key != "pictures/post_1.png" {
+			t.Errorf("Expected pictures/post_1.png, got %q (%v)", key, ok)
+		}
+		if _, ok := KeyFromURL(s, "https://elsewhere/x.png"); ok {
+			t.Error("Expected foreign URL to be rejected")
+		}
+	})
+}
+
+func TestMigrate(t *testing.T) {
+	ctx := context.Background()
+	src := NewLocal(t.TempDir(), URLPrefix)
+	dst, _ := newTestS3(t)
+	dst.PublicURL = "https://cdn.example.com"
+
+	src.Put(ctx, "avatar_1.png", strings.NewReader("a"), "image/png")
+	src.Put(ctx, "pictures/post_1.png", strings.NewReader("b"), "image/png")
+	src.Put(ctx, "profiles/default.png", strings.NewReader("default"), "image/png")
+
+	moved := map[string]string{}
+	report, err := Migrate(ctx, src, dst, true, func(oldURL, newURL string) error {
+		moved[oldURL] = newURL
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Migrate failed: %v", err)
+	}
+	if report.Copied != 2 || report.Skipped != 1 || report.Failed != 0 {
+		t.Errorf("Unexpected report: %+v", report)
+	}
+	if moved["/static/pictures/post_1.png"] != "https://cdn.example.com/pictures/post_1.png" {
+		t.Errorf("Unexpected URL rewrite: %v", moved)
+	}
+
+	// Source files are removed, bundled assets are left alone
+	if _, err := src.Get(ctx, "avatar_1.png"); err != ErrNotFound {
+		t.Errorf("Expected source file to be deleted, got %v", err)
+	}
+	if r, err := src.Get(ctx, "profiles/default.png"); err != nil {
+		t.Errorf("Expected bundled asset to remain: %v", err)
+	} else {
+		r.Close()
+	}
+}
+
+func TestHandler(t *testing.T) {
+	ctx := context.Background()
+	uploads := NewLocal(t.TempDir(), URLPrefix)
+	bundled := t.TempDir()
+	uploads.Put(ctx, "avatar_1.png", strings.NewReader("uploaded"), "image/png")
+	os.WriteFile(filepath.Join(bundled, "logo.png"), []byte("bundled"), 0o644)
+
+	handler := http.StripPrefix("/static/", Handler(uploads, http.Dir(bundled)))
+
+	tests := []struct {
+		path   string
+		status int
+		body   string
+	}{
+		{"/static/avatar_1.png", http.StatusOK, "uploaded"},
+		{"/static/logo.png", http.StatusOK, "bundled"},
+		{"/static/", http.StatusNotFound, ""},
+		{"/static/missing.png", http.StatusNotFound, ""},
+	}
+	for _, tt := range tests {
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, tt.path, nil))
+		if w.Code != tt.status {
+			t.Errorf("%s: expected status %d, got %d", tt.path, tt.status, w.Code)
+		}
+		if tt.body != "" && w.Body.String() != tt.body {
+			t.Errorf("%s: expected body %q, got %q", tt.path, tt.body, w.Body.String())
+		}
+	}
+}
@@ -0,0 +1,69 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"log"
+)
+
+// BundledAssets ship with the image and are always served from the local
+// static directory, so they are never migrated or deleted
+var BundledAssets = map[string]bool{
+	"profiles/default.png":       true,
+	"pictures/forum-logo.png":    true,
+	"pictures/icons8-avatar.gif": true,
+}
+
+// MigrateReport summarises a migration run
+type MigrateReport struct {
+	Copied  int
+	Skipped int
+	Failed  int
+}
+
+// Migrate copies every object from src to dst. For each copied object
+// onMoved is called with the old and new URL so references can be rewritten;
+// the source object is removed afterwards when deleteSource is set.
+func Migrate(ctx context.Context, src, dst Storage, deleteSource bool, onMoved func(oldURL, newURL string) error) (MigrateReport, error) {
+	var report MigrateReport
+
+	objects, err := src.List(ctx, "")
+	if err != nil {
+		return report, fmt.Errorf("failed to list source objects: %w", err)
+	}
+
+	for _, obj := range objects {
+		if BundledAssets[obj.Key] {
+			report.Skipped++
+			continue
+		}
+		if err := copyObject(ctx, src, dst, obj.Key); err != nil {
+			log.Printf("❌ Failed to migrate %s: %v", obj.Key, err)
+			report.Failed++
+			continue
+		}
+		if onMoved != nil {
+			if err := onMoved(src.URL(obj.Key), dst.URL(obj.Key)); err != nil {
+				log.Printf("❌ Failed to update references to %s: %v", obj.Key, err)
+				report.Failed++
+				continue
+			}
+		}
+		if deleteSource {
+			if err := src.Delete(ctx, obj.Key); err != nil {
+				log.Printf("⚠️  Copied %s but failed to delete the source: %v", obj.Key, err)
+			}
+		}
+		report.Copied++
+	}
+	return report, nil
+}
+
+func copyObject(ctx context.Context, src, dst Storage, key string) error {
+	r, err := src.Get(ctx, key)
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+	return dst.Put(ctx, key, r, ContentType(key))
+}
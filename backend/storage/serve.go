@@ -0,0 +1,49 @@
+package storage
+
+import (
+	"errors"
+	"io"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// Handler serves objects from s. Requests must already have URLPrefix
+// stripped. Keys missing from s are looked up in fallback, which holds the
+// assets bundled with the image (default avatar, logo).
+func Handler(s Storage, fallback http.FileSystem) http.Handler {
+	files := http.FileServer(fallback)
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/" || r.URL.Path == "" || strings.HasSuffix(r.URL.Path, "/") {
+			log.Printf("⚠️  Directory listing blocked: /static/%s", r.URL.Path)
+			http.NotFound(w, r)
+			return
+		}
+
+		key, err := CleanKey(r.URL.Path)
+		if err != nil {
+			http.NotFound(w, r)
+			return
+		}
+
+		body, err := s.Get(r.Context(), key)
+		if errors.Is(err, ErrNotFound) {
+			files.ServeHTTP(w, r)
+			return
+		}
+		if err != nil {
+			log.Printf("❌ Failed to read upload %s: %v", key, err)
+			http.Error(w, "Failed to read file", http.StatusInternalServerError)
+			return
+		}
+		defer body.Close()
+
+		w.Header().Set("Content-Type", ContentType(key))
+		if rs, ok := body.(io.ReadSeeker); ok {
+			http.ServeContent(w, r, key, time.Time{}, rs)
+			return
+		}
+		io.Copy(w, body)
+	})
+}
@@ -0,0 +1,83 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"io"
+	"mime"
+	"path"
+	"strings"
+	"time"
+)
+
+// ErrNotFound is returned when a stored object does not exist
+var ErrNotFound = errors.New("storage: object not found")
+
+// Object describes a stored file
+type Object struct {
+	Key     string
+	Size    int64
+	ModTime time.Time
+}
+
+// Storage is implemented by every upload backend (local disk, S3-compatible, ...)
+type Storage interface {
+	// Put stores the contents of r under key, replacing any existing object
+	Put(ctx context.Context, key string, r io.Reader, contentType string) error
+	// Get opens the object stored under key
+	Get(ctx context.Context, key string) (io.ReadCloser, error)
+	// Delete removes the object stored under key. Deleting a missing object is not an error
+	Delete(ctx context.Context, key string) error
+	// List returns all objects whose key starts with prefix
+	List(ctx context.Context, prefix string) ([]Object, error)
+	// URL returns the URL clients should use to fetch key
+	URL(key string) string
+}
+
+// URLPrefix is the path under which uploads are served by the backend
+const URLPrefix = "/static/"
+
+// Uploads is the storage used by the handlers for avatars and post images.
+// It defaults to the local static directory and is replaced from main.
+var Uploads Storage = NewLocal("static", URLPrefix)
+
+// CleanKey normalises a key and rejects keys that would escape the storage root
+func CleanKey(key string) (string, error) {
+	key = strings.TrimPrefix(path.Clean("/"+strings.ReplaceAll(key, "\\", "/")), "/")
+	if key == "" || key == "." || strings.HasPrefix(key, "..") {
+		return "", errors.New("storage: invalid key")
+	}
+	return key, nil
+}
+
+// KeyFromURL extracts the storage key from a URL previously returned by s.URL.
+// It reports false if the URL does not belong to s.
+func KeyFromURL(s Storage, url string) (string, bool) {
+	base := s.URL("")
+	if base == "" || !strings.HasPrefix(url, base) {
+		return "", false
+	}
+	key, err := CleanKey(strings.TrimPrefix(url, base))
+	if err != nil {
+		return "", false
+	}
+	return key, true
+}
+
+// ContentType guesses a content type from the key's extension
+func ContentType(key string) string {
+	if ct := mime.TypeByExtension(path.Ext(key)); ct != "" {
+		return ct
+	}
+	return "application/octet-stream"
+}
+
+// DeleteURL removes the upload a URL points to. URLs outside s and bundled
+// assets are ignored, so it is safe to call with any stored avatar or image URL.
+func DeleteURL(ctx context.Context, s Storage, url string) error {
+	key, ok := KeyFromURL(s, url)
+	if !ok || BundledAssets[key] {
+		return nil
+	}
+	return s.Delete(ctx, key)
+}
@@ -0,0 +1,123 @@
+package totp
+
+import (
+	"encoding/base32"
+	"strings"
+	"testing"
+	"time"
+)
+
+// rfcSecret is the SHA-1 key from the RFC 6238 test vectors ("12345678901234567890")
+var rfcSecret = base32.StdEncoding.EncodeToString([]byte("12345678901234567890"))
+
+func TestCode(t *testing.T) {
+	// RFC 6238 Appendix B, truncated to 6 digits
+	tests := []struct {
+		unix     int64
+		expected string
+	}{
+		{59, "287082"},
+		{1111111109, "081804"},
+		{1111111111, "050471"},
+		{1234567890, "005924"},
+		{2000000000, "279037"},
+	}
+
+	for _, tt := range tests {
+		code, err := Code(rfcSecret, time.Unix(tt.unix, 0))
+		if err != nil {
+			t.Fatalf("Code failed: %v", err)
+		}
+		if code != tt.expected {
+			t.Errorf("At %d: expected %s, got %s", tt.unix, tt.expected, code)
+		}
+	}
+}
+
+func TestValidate(t *testing.T) {
+	now := time.Unix(1111111111, 0)
+	code, _ := Code(rfcSecret, now)
+
+	t.Run("current code", func(t *testing.T) {
+		step, ok := Validate(rfcSecret, code, now, 0)
+		if !ok || step != Step(now) {
+			t.Errorf("Expected code to validate at step %d, got %d (%v)", Step(now), step, ok)
+		}
+	})
+
+	t.Run("clock drift within skew", func(t *testing.T) {
+		if _, ok := Validate(rfcSecret, code, now.Add(Period*time.Second), 0); !ok {
+			t.Error("Expected previous step to be accepted")
+		}
+		if _, ok := Validate(rfcSecret, code, now.Add(-Period*time.Second), 0); !ok {
+			t.Error("Expected next step to be accepted")
+		}
+	})
+
+	t.Run("expired code", func(t *testing.T) {
+		if _, ok := Validate(rfcSecret, code, now.Add(3*Period*time.Second), 0); ok {
+			t.Error("Expected code from three steps ago to be rejected")
+		}
+	})
+
+	t.Run("replay is rejected", func(t *testing.T) {
+		if _, ok := Validate(rfcSecret, code, now, Step(now)); ok {
+			t.Error("Expected an already used step to be rejected")
+		}
+	})
+
+	t.Run("wrong code", func(t *testing.T) {
+		if _, ok := Validate(rfcSecret, "000000", now, 0); ok {
+			t.Error("Expected wrong code to be rejected")
+		}
+		if _, ok := Validate(rfcSecret, "12345", now, 0); ok {
+			t.Error("Expected short code to be rejected")
+		}
+	})
+}
+
+func TestGenerateSecret(t *testing.T) {
+	secret, err := GenerateSecret()
+	if err != nil {
+		t.Fatalf("GenerateSecret failed: %v", err)
+	}
+	if len(secret) != 32 {
+		t.Errorf("Expected 32 base32 characters, got %d", len(secret))
+	}
+	if _, err := Code(secret, time.Now()); err != nil {
+		t.Errorf("Generated secret is not usable: %v", err)
+	}
+}
+
+func TestURI(t *testing.T) {
+	uri := URI("Forum", "alice@example.com", "JBSWY3DPEHPK3PXP")
+	if !strings.HasPrefix(uri, "otpauth://totp/Forum:alice@example.com?") {
+		t.Errorf("Unexpected URI prefix: %s", uri)
+	}
+	for _, part := range []string{"secret=JBSWY3DPEHPK3PXP", "issuer=Forum", "digits=6", "period=30"} {
+		if !strings.Contains(uri, part) {
+			t.Errorf("Expected URI to contain %s: %s", part, uri)
+		}
+	}
+}
+
+func TestRecoveryCodes(t *testing.T) {
+	codes, err := GenerateRecoveryCodes(10)
+	if err != nil {
+		t.Fatalf("GenerateRecoveryCodes failed: %v", err)
+	}
+	seen := map[string]bool{}
+	for _, code := range codes {
+		if len(code) != 11 || code[5] != '-' {
+			t.Errorf("Unexpected code format: %s", code)
+		}
+		seen[code] = true
+	}
+	if len(seen) != 10 {
+		t.Error("Expected recovery codes to be unique")
+	}
+
+	if HashRecoveryCode("ABCDE-FGHIJ") != HashRecoveryCode("abcdefghij") {
+		t.Error("Expected hashing to ignore case and dashes")
+	}
+}
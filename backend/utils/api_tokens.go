@@ -0,0 +1,33 @@
+package utils
+
+import (
+	"context"
+	"net/http"
+	"strings"
+
+	"forum/models"
+)
+
+type apiTokenKey struct{}
+
+// BearerToken returns the token from an "Authorization: Bearer" header
+func BearerToken(r *http.Request) (string, bool) {
+	scheme, token, found := strings.Cut(r.Header.Get("Authorization"), " ")
+	if !found || !strings.EqualFold(scheme, "Bearer") {
+		return "", false
+	}
+	token = strings.TrimSpace(token)
+	return token, token != ""
+}
+
+// WithAPIToken marks a request as authenticated by an API token, so
+// GetUserIDFromSession returns the token's owner
+func WithAPIToken(r *http.Request, token models.APIToken) *http.Request {
+	return r.WithContext(context.WithValue(r.Context(), apiTokenKey{}, token))
+}
+
+// APITokenFromRequest returns the API token a request was authenticated with
+func APITokenFromRequest(r *http.Request) (models.APIToken, bool) {
+	token, ok := r.Context().Value(apiTokenKey{}).(models.APIToken)
+	return token, ok
+}
@@ -12,20 +12,52 @@ import (
 	"time"
 	"unicode/utf8"
 
+	"forum/passhash"
 	"forum/sqlite"
-
-	"golang.org/x/crypto/bcrypt"
 )
 
-// HashPassword hashes a password using bcrypt
+// PasswordParams are the algorithm and cost new password hashes are made
+// with (see passhash.FromEnv); replaced in tests
+var PasswordParams = loadPasswordParams()
+
+func loadPasswordParams() passhash.Params {
+	params, err := passhash.FromEnv()
+	if err != nil {
+		log.Printf("❌ Invalid password hashing settings, using defaults: %v", err)
+	}
+	return params
+}
+
+// HashPassword hashes a password with the configured algorithm
 func HashPassword(password string) (string, error) {
-	hashed, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
-	return string(hashed), err
+	return PasswordParams.Hash(password)
 }
 
 // CheckPasswordHash compares a hashed password with a plain password
 func CheckPasswordHash(password, hash string) bool {
-	return bcrypt.CompareHashAndPassword([]byte(hash), []byte(password)) == nil
+	match, _ := VerifyPassword(password, hash)
+	return match
+}
+
+// VerifyPassword compares a hashed password with a plain password.
+// needsRehash reports that the password matched but the hash was made with
+// other settings, and should be replaced with HashPassword(password).
+func VerifyPassword(password, hash string) (match, needsRehash bool) {
+	match, needsRehash, err := PasswordParams.Verify(password, hash)
+	if err != nil {
+		log.Printf("⚠️  Unreadable password hash: %v", err)
+	}
+	return match, needsRehash
+}
+
+// dummyPasswordHash is compared against when a login names an unknown user,
+// so the response takes as long as for a real account
+var dummyPasswordHash, _ = HashPassword("dummy-password-for-timing")
+
+// CheckDummyPassword spends the same time as CheckPasswordHash and always fails
+func CheckDummyPassword(password string) bool {
+	PasswordParams.Verify(password, dummyPasswordHash)
+	return false
 }
 
 // IsAuthor checks if the given user is the author of a specific comment
@@ -47,7 +79,7 @@ func IsAuthor(db *sql.DB, userID string, id int, isPost bool) (bool, error) {
 
 // IsAuthenticated checks if the user is logged in
 func IsAuthenticated(db *sql.DB, r *http.Request) (bool, error) {
-	sessionCookie, err := r.Cookie("session_id")
+	sessionCookie, err := r.Cookie(SessionCookieName)
 	if err != nil {
 		return false, err // Return error instead of just false
 	}
@@ -60,9 +92,13 @@ func IsAuthenticated(db *sql.DB, r *http.Request) (bool, error) {
 	return valid, nil
 }
 
-// GetUserIDFromSession retrieves the user ID from the session
+// GetUserIDFromSession retrieves the user ID from the session, or from the
+// API token the request was authenticated with
 func GetUserIDFromSession(db *sql.DB, r *http.Request) (string, error) {
-	sessionCookie, err := r.Cookie("session_id")
+	if token, ok := APITokenFromRequest(r); ok {
+		return token.UserID, nil
+	}
+	sessionCookie, err := r.Cookie(SessionCookieName)
 	if err != nil {
 		return "", err
 	}
@@ -117,6 +153,18 @@ func GetPaginationParams(r *http.Request) (int, int) {
 
 // ValidateAndSanitizeString validates and sanitizes string input
 func ValidateAndSanitizeString(input string, maxLength int, fieldName string) (string, error) {
+	input, err := ValidateString(input, maxLength, fieldName)
+	if err != nil {
+		return "", err
+	}
+
+	// HTML escape to prevent XSS
+	return html.EscapeString(input), nil
+}
+
+// ValidateString validates string input and returns it trimmed but not
+// escaped, for Markdown that is sanitized when rendered (see markdown.Render)
+func ValidateString(input string, maxLength int, fieldName string) (string, error) {
 	// Check for null bytes (potential for SQL injection bypass)
 	if strings.Contains(input, "\x00") {
 		return "", fmt.Errorf("%s contains invalid characters", fieldName)
@@ -139,10 +187,7 @@ func ValidateAndSanitizeString(input string, maxLength int, fieldName string) (s
 		return "", fmt.Errorf("%s contains invalid UTF-8 characters", fieldName)
 	}
 
-	// HTML escape to prevent XSS
-	sanitized := html.EscapeString(input)
-
-	return sanitized, nil
+	return input, nil
 }
 
 // ValidateEmail validates email format
@@ -173,14 +218,20 @@ func ValidateUsername(username string) error {
 	return nil
 }
 
-// ValidatePassword validates password strength
-func ValidatePassword(password string) error {
+// ValidatePassword checks a new password for the user with the given
+// username and email against the length and character rules and the
+// password policy. Errors are *PasswordError, carrying a strength estimate.
+func ValidatePassword(password, username, email string) error {
+	refuse := func(message string) error {
+		return &PasswordError{Message: message, Strength: Passwords.Strength(password, username, email)}
+	}
+
 	if len(password) < 8 {
-		return fmt.Errorf("password must be at least 8 characters long")
+		return refuse("password must be at least 8 characters long")
 	}
 
 	if len(password) > 128 {
-		return fmt.Errorf("password must be less than 128 characters")
+		return refuse("password must be less than 128 characters")
 	}
 
 	// Check for at least one letter and one number
@@ -188,10 +239,10 @@ func ValidatePassword(password string) error {
 	hasNumber := regexp.MustCompile(`[0-9]`).MatchString(password)
 
 	if !hasLetter || !hasNumber {
-		return fmt.Errorf("password must contain at least one letter and one number")
+		return refuse("password must contain at least one letter and one number")
 	}
 
-	return nil
+	return Passwords.Check(password, username, email)
 }
 
 // ValidatePostContent validates post title and content
@@ -200,7 +251,7 @@ func ValidatePostContent(title, content string) error {
 		return err
 	}
 
-	if _, err := ValidateAndSanitizeString(content, 10000, "content"); err != nil {
+	if _, err := ValidateString(content, 10000, "content"); err != nil {
 		return err
 	}
 
@@ -209,7 +260,7 @@ func ValidatePostContent(title, content string) error {
 
 // ValidateCommentContent validates comment content
 func ValidateCommentContent(content string) error {
-	if _, err := ValidateAndSanitizeString(content, 2000, "comment"); err != nil {
+	if _, err := ValidateString(content, 2000, "comment"); err != nil {
 		return err
 	}
 
@@ -71,6 +71,24 @@ func TestValidateAndSanitizeString(t *testing.T) {
 	}
 }
 
+func TestValidateString(t *testing.T) {
+	markdown := "  Use `a < b` and **bold** & <kbd>keys</kbd>  "
+	result, err := ValidateString(markdown, 100, "content")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if result != strings.TrimSpace(markdown) {
+		t.Fatalf("Expected Markdown to be kept as written, got %q", result)
+	}
+
+	if _, err := ValidateString("   ", 100, "content"); err == nil {
+		t.Fatal("Expected error for blank content")
+	}
+	if _, err := ValidateString("a\x00b", 100, "content"); err == nil {
+		t.Fatal("Expected error for null byte")
+	}
+}
+
 func TestValidateEmail(t *testing.T) {
 	tests := []struct {
 		email string
@@ -133,8 +151,12 @@ func TestValidatePassword(t *testing.T) {
 		password string
 		valid    bool
 	}{
-		{"password123", true},
-		{"Password1", true},
+		{"correct-h0rse-battery", true},
+		{"Xk7mq2pLw9", true},
+		{"password123", false}, // too common
+		{"Password1", false},   // too common
+		{"P@ssw0rd!1", false},  // common word in disguise
+		{"tester1-x9Qv", false}, // contains the username
 		{"short", false},     // too short
 		{"onlyletters", false}, // no numbers
 		{"12345678", false},  // no letters
@@ -144,7 +166,7 @@ func TestValidatePassword(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.password, func(t *testing.T) {
-			err := ValidatePassword(tt.password)
+			err := ValidatePassword(tt.password, "tester", "tester@example.com")
 			if tt.valid && err != nil {
 				t.Fatalf("Expected valid password but got error: %v", err)
 			}
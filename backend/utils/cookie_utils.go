@@ -0,0 +1,123 @@
+package utils
+
+import (
+	"log"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+)
+
+// SessionCookieName is the cookie holding the session ID
+const SessionCookieName = "session_id"
+
+// SessionDuration is how long a session cookie stays valid
+const SessionDuration = 24 * time.Hour
+
+// sessionCookieSameSite reads SESSION_COOKIE_SAMESITE (lax, strict or none; default lax)
+func sessionCookieSameSite() http.SameSite {
+	switch strings.ToLower(os.Getenv("SESSION_COOKIE_SAMESITE")) {
+	case "", "lax":
+		return http.SameSiteLaxMode
+	case "strict":
+		return http.SameSiteStrictMode
+	case "none":
+		return http.SameSiteNoneMode
+	default:
+		log.Printf("Warning: invalid SESSION_COOKIE_SAMESITE %q, using lax", os.Getenv("SESSION_COOKIE_SAMESITE"))
+		return http.SameSiteLaxMode
+	}
+}
+
+// sessionCookieSecure reads SESSION_COOKIE_SECURE (default false). Browsers
+// require it when SameSite is none.
+func sessionCookieSecure() bool {
+	v := strings.ToLower(os.Getenv("SESSION_COOKIE_SECURE"))
+	return v == "true" || v == "1" || sessionCookieSameSite() == http.SameSiteNoneMode
+}
+
+// SetSessionCookie sends the session cookie with the configured SameSite and Secure attributes
+func SetSessionCookie(w http.ResponseWriter, sessionID string) {
+	http.SetCookie(w, &http.Cookie{
+		Name:     SessionCookieName,
+		Value:    sessionID,
+		Path:     "/",
+		Expires:  time.Now().Add(SessionDuration),
+		HttpOnly: true,
+		SameSite: sessionCookieSameSite(),
+		Secure:   sessionCookieSecure(),
+	})
+}
+
+// ClearSessionCookie removes the session cookie from the browser
+func ClearSessionCookie(w http.ResponseWriter) {
+	http.SetCookie(w, &http.Cookie{
+		Name:     SessionCookieName,
+		Value:    "",
+		Path:     "/",
+		MaxAge:   -1,
+		HttpOnly: true,
+		SameSite: sessionCookieSameSite(),
+		Secure:   sessionCookieSecure(),
+	})
+}
+
+// WebAuthnCookieName is the pre-session cookie tying a passkey challenge to the browser that requested it
+const WebAuthnCookieName = "webauthn_ceremony"
+
+// SetWebAuthnCookie sends the pre-session cookie for a passkey ceremony
+func SetWebAuthnCookie(w http.ResponseWriter, token string, ttl time.Duration) {
+	http.SetCookie(w, &http.Cookie{
+		Name:     WebAuthnCookieName,
+		Value:    token,
+		Path:     "/api/webauthn/",
+		MaxAge:   int(ttl.Seconds()),
+		HttpOnly: true,
+		SameSite: sessionCookieSameSite(),
+		Secure:   sessionCookieSecure(),
+	})
+}
+
+// ClearWebAuthnCookie removes the pre-session cookie once its ceremony is finished
+func ClearWebAuthnCookie(w http.ResponseWriter) {
+	http.SetCookie(w, &http.Cookie{
+		Name:     WebAuthnCookieName,
+		Value:    "",
+		Path:     "/api/webauthn/",
+		MaxAge:   -1,
+		HttpOnly: true,
+		SameSite: sessionCookieSameSite(),
+		Secure:   sessionCookieSecure(),
+	})
+}
+
+// OIDCStateCookieName is the pre-session cookie binding an OpenID Connect login to the browser that started it
+const OIDCStateCookieName = "oidc_state"
+
+// SetOIDCStateCookie sends the state of an OpenID Connect login. It is always
+// SameSite=Lax: the provider redirects back with a cross-site navigation,
+// which a Strict cookie would not survive.
+func SetOIDCStateCookie(w http.ResponseWriter, state string, ttl time.Duration) {
+	http.SetCookie(w, &http.Cookie{
+		Name:     OIDCStateCookieName,
+		Value:    state,
+		Path:     "/api/oidc/",
+		MaxAge:   int(ttl.Seconds()),
+		HttpOnly: true,
+		SameSite: http.SameSiteLaxMode,
+		Secure:   sessionCookieSecure(),
+	})
+}
+
+// ClearOIDCStateCookie removes the state cookie once the provider has redirected back
+func ClearOIDCStateCookie(w http.ResponseWriter) {
+	http.SetCookie(w, &http.Cookie{
+		Name:     OIDCStateCookieName,
+		Value:    "",
+		Path:     "/api/oidc/",
+		MaxAge:   -1,
+		HttpOnly: true,
+		SameSite: http.SameSiteLaxMode,
+		Secure:   sessionCookieSecure(),
+	})
+}
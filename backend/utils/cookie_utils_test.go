@@ -0,0 +1,47 @@
+package utils
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestSetSessionCookie(t *testing.T) {
+	t.Run("defaults", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		SetSessionCookie(w, "abc")
+
+		cookie := w.Result().Cookies()[0]
+		if cookie.Name != SessionCookieName || cookie.Value != "abc" {
+			t.Errorf("Unexpected cookie %s=%s", cookie.Name, cookie.Value)
+		}
+		if !cookie.HttpOnly || cookie.SameSite != http.SameSiteLaxMode || cookie.Secure {
+			t.Errorf("Expected HttpOnly, SameSite=Lax and not Secure, got %+v", cookie)
+		}
+	})
+
+	t.Run("configured attributes", func(t *testing.T) {
+		t.Setenv("SESSION_COOKIE_SAMESITE", "strict")
+		t.Setenv("SESSION_COOKIE_SECURE", "true")
+
+		w := httptest.NewRecorder()
+		SetSessionCookie(w, "abc")
+
+		cookie := w.Result().Cookies()[0]
+		if cookie.SameSite != http.SameSiteStrictMode || !cookie.Secure {
+			t.Errorf("Expected SameSite=Strict and Secure, got %+v", cookie)
+		}
+	})
+
+	t.Run("SameSite none forces Secure", func(t *testing.T) {
+		t.Setenv("SESSION_COOKIE_SAMESITE", "none")
+
+		w := httptest.NewRecorder()
+		ClearSessionCookie(w)
+
+		cookie := w.Result().Cookies()[0]
+		if cookie.SameSite != http.SameSiteNoneMode || !cookie.Secure || cookie.MaxAge >= 0 {
+			t.Errorf("Expected an expired SameSite=None Secure cookie, got %+v", cookie)
+		}
+	})
+}
@@ -0,0 +1,134 @@
+package utils
+
+import (
+	"database/sql"
+	"strings"
+	"time"
+
+	"forum/models"
+	"forum/sqlite"
+)
+
+// LoginThrottle configures exponential backoff and lockout for failed logins
+type LoginThrottle struct {
+	FreeAttempts int           // Failures allowed before backoff starts
+	LockAfter    int           // Failures that trigger a temporary lockout
+	LockDuration time.Duration // How long a lockout lasts
+	MaxBackoff   time.Duration // Upper bound for the delay between attempts
+	ResetAfter   time.Duration // Failures older than this are forgotten
+}
+
+// AccountThrottle applies to a single account, whatever IP the attempts come from
+var AccountThrottle = LoginThrottle{
+	FreeAttempts: 3,
+	LockAfter:    10,
+	LockDuration: 15 * time.Minute,
+	MaxBackoff:   time.Minute,
+	ResetAfter:   24 * time.Hour,
+}
+
+// IPThrottle applies to a single client IP, whatever accounts it tries
+var IPThrottle = LoginThrottle{
+	FreeAttempts: 10,
+	LockAfter:    50,
+	LockDuration: 30 * time.Minute,
+	MaxBackoff:   time.Minute,
+	ResetAfter:   24 * time.Hour,
+}
+
+// loginClock is replaced in tests
+var loginClock = time.Now
+
+// AccountLoginKey identifies an account for login throttling. Unknown
+// accounts are keyed by the submitted login so they are throttled exactly
+// like real ones and can't be told apart.
+func AccountLoginKey(userID, login string) string {
+	if userID != "" {
+		return "account:" + userID
+	}
+	return "account:" + strings.ToLower(login)
+}
+
+// IPLoginKey identifies a client IP for login throttling
+func IPLoginKey(ip string) string {
+	return "ip:" + ip
+}
+
+// retryAfter returns how long a subject must wait before its next attempt
+func (t LoginThrottle) retryAfter(f models.LoginFailure, now time.Time) time.Duration {
+	if now.Before(f.LockedUntil) {
+		return f.LockedUntil.Sub(now)
+	}
+	if f.Failures <= t.FreeAttempts || now.Sub(f.LastFailureAt) > t.ResetAfter {
+		return 0
+	}
+
+	backoff := t.MaxBackoff
+	if shift := f.Failures - t.FreeAttempts - 1; shift < 16 {
+		backoff = min(time.Second<<shift, t.MaxBackoff)
+	}
+	if next := f.LastFailureAt.Add(backoff); now.Before(next) {
+		return next.Sub(now)
+	}
+	return 0
+}
+
+// recordFailure counts a failed attempt and reports whether it triggered a lockout
+func (t LoginThrottle) recordFailure(f models.LoginFailure, now time.Time) (models.LoginFailure, bool) {
+	if now.Sub(f.LastFailureAt) > t.ResetAfter {
+		f.Failures = 0
+	}
+	f.Failures++
+	f.LastFailureAt = now
+
+	if f.Failures >= t.LockAfter {
+		f.Failures = 0
+		f.LockedUntil = now.Add(t.LockDuration)
+		return f, true
+	}
+	return f, false
+}
+
+// LoginRetryAfter returns how long the account and IP must wait before
+// another login attempt is accepted, or 0 if they may try now
+func LoginRetryAfter(db *sql.DB, accountKey, ipKey string) (time.Duration, error) {
+	now := loginClock()
+
+	account, err := sqlite.GetLoginFailure(db, accountKey)
+	if err != nil {
+		return 0, err
+	}
+	ip, err := sqlite.GetLoginFailure(db, ipKey)
+	if err != nil {
+		return 0, err
+	}
+	return max(AccountThrottle.retryAfter(account, now), IPThrottle.retryAfter(ip, now)), nil
+}
+
+// RecordFailedLogin counts a failed attempt against the account and the IP.
+// It reports whether the account has just been locked.
+func RecordFailedLogin(db *sql.DB, accountKey, ipKey string) (bool, error) {
+	now := loginClock()
+
+	account, err := sqlite.GetLoginFailure(db, accountKey)
+	if err != nil {
+		return false, err
+	}
+	account, accountLocked := AccountThrottle.recordFailure(account, now)
+	if err := sqlite.SaveLoginFailure(db, account); err != nil {
+		return false, err
+	}
+
+	ip, err := sqlite.GetLoginFailure(db, ipKey)
+	if err != nil {
+		return accountLocked, err
+	}
+	ip, _ = IPThrottle.recordFailure(ip, now)
+	return accountLocked, sqlite.SaveLoginFailure(db, ip)
+}
+
+// ClearFailedLogins resets the account's failure count after a successful login.
+// The IP count is left to expire so one valid login can't reset a spraying client.
+func ClearFailedLogins(db *sql.DB, accountKey string) error {
+	return sqlite.ClearLoginFailures(db, accountKey)
+}
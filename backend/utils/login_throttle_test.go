@@ -0,0 +1,124 @@
+package utils
+
+import (
+	"database/sql"
+	"testing"
+	"time"
+
+	_ "github.com/mattn/go-sqlite3"
+)
+
+func setupThrottleTestDB(t *testing.T) *sql.DB {
+	db, err := sql.Open("sqlite3", ":memory:")
+	if err != nil {
+		t.Fatalf("Failed to create test database: %v", err)
+	}
+	_, err = db.Exec(`
+	CREATE TABLE login_failures (
+		subject TEXT PRIMARY KEY,
+		failures INTEGER NOT NULL DEFAULT 0,
+		last_failure_at DATETIME,
+		locked_until DATETIME
+	);
+	`)
+	if err != nil {
+		t.Fatalf("Failed to create test schema: %v", err)
+	}
+	return db
+}
+
+func TestLoginThrottle(t *testing.T) {
+	db := setupThrottleTestDB(t)
+	defer db.Close()
+
+	now := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+	loginClock = func() time.Time { return now }
+	defer func() { loginClock = time.Now }()
+
+	account := AccountLoginKey("user-1", "")
+	ip := IPLoginKey("203.0.113.5")
+
+	fail := func() bool {
+		locked, err := RecordFailedLogin(db, account, ip)
+		if err != nil {
+			t.Fatalf("RecordFailedLogin failed: %v", err)
+		}
+		return locked
+	}
+	wait := func() time.Duration {
+		d, err := LoginRetryAfter(db, account, ip)
+		if err != nil {
+			t.Fatalf("LoginRetryAfter failed: %v", err)
+		}
+		return d
+	}
+
+	t.Run("free attempts", func(t *testing.T) {
+		for i := 0; i < AccountThrottle.FreeAttempts; i++ {
+			fail()
+		}
+		if d := wait(); d != 0 {
+			t.Errorf("Expected no delay within free attempts, got %v", d)
+		}
+	})
+
+	t.Run("exponential backoff", func(t *testing.T) {
+		fail()
+		if d := wait(); d != time.Second {
+			t.Errorf("Expected 1s backoff, got %v", d)
+		}
+		now = now.Add(time.Second)
+		fail()
+		if d := wait(); d != 2*time.Second {
+			t.Errorf("Expected 2s backoff, got %v", d)
+		}
+		now = now.Add(2 * time.Second)
+		fail()
+		if d := wait(); d != 4*time.Second {
+			t.Errorf("Expected 4s backoff, got %v", d)
+		}
+	})
+
+	t.Run("lockout", func(t *testing.T) {
+		locked := false
+		for i := 0; i < AccountThrottle.LockAfter && !locked; i++ {
+			now = now.Add(time.Hour)
+			locked = fail()
+		}
+		if !locked {
+			t.Fatal("Expected the account to be locked")
+		}
+		if d := wait(); d != AccountThrottle.LockDuration {
+			t.Errorf("Expected %v lockout, got %v", AccountThrottle.LockDuration, d)
+		}
+
+		now = now.Add(AccountThrottle.LockDuration)
+		if d := wait(); d != 0 {
+			t.Errorf("Expected lockout to expire, got %v", d)
+		}
+	})
+
+	t.Run("success resets the account", func(t *testing.T) {
+		fail()
+		if err := ClearFailedLogins(db, account); err != nil {
+			t.Fatalf("ClearFailedLogins failed: %v", err)
+		}
+		// The IP keeps its history, so check from a fresh address
+		d, err := LoginRetryAfter(db, account, IPLoginKey("198.51.100.1"))
+		if err != nil {
+			t.Fatalf("LoginRetryAfter failed: %v", err)
+		}
+		if d != 0 {
+			t.Errorf("Expected no delay after reset, got %v", d)
+		}
+	})
+}
+
+func TestAccountLoginKey(t *testing.T) {
+	if AccountLoginKey("abc", "Someone") != "account:abc" {
+		t.Error("Known users should be keyed by ID")
+	}
+	if AccountLoginKey("", "Someone@Example.com") != "account:someone@example.com" {
+		t.Error("Unknown users should be keyed by the lower-cased login")
+	}
+}
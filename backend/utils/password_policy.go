@@ -0,0 +1,353 @@
+package utils
+
+import (
+	"bufio"
+	"crypto/sha1"
+	_ "embed"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"math"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+//go:embed common_passwords.txt
+var commonPasswords string
+
+// PasswordPolicy holds the offline checks a new password must pass:
+//
+//	PASSWORD_BLOCKLIST_FILE  extra passwords to reject, one per line
+//	PASSWORD_PWNED_DIR       k-anonymity SHA-1 prefix store: one file per
+//	                         5 hex digit prefix (e.g. 5BAA6.txt) holding
+//	                         SUFFIX:COUNT lines, as written by the Have I
+//	                         Been Pwned downloader
+//	PASSWORD_MIN_STRENGTH    lowest accepted strength estimate, 0-4 (default 2)
+type PasswordPolicy struct {
+	MinStrength int
+	Blocklist   map[string]bool
+	PwnedDir    string
+}
+
+// Passwords is the policy ValidatePassword applies; replaced in tests
+var Passwords = loadPasswordPolicy()
+
+// PasswordError explains why a password was refused, with its estimated strength
+type PasswordError struct {
+	Message  string
+	Strength int
+}
+
+func (e *PasswordError) Error() string {
+	return e.Message
+}
+
+// SendPasswordError responds 400 with the reason a password was refused and
+// its strength estimate
+func SendPasswordError(w http.ResponseWriter, err error) {
+	response := map[string]any{"error": err.Error()}
+	var passwordErr *PasswordError
+	if errors.As(err, &passwordErr) {
+		response["password_strength"] = passwordErr.Strength
+		response["password_strength_max"] = MaxPasswordStrength
+	}
+	SendJSONResponse(w, response, http.StatusBadRequest)
+}
+
+// MaxPasswordStrength is the top of the strength scale
+const MaxPasswordStrength = 4
+
+func loadPasswordPolicy() PasswordPolicy {
+	policy := PasswordPolicy{MinStrength: 2, Blocklist: map[string]bool{}}
+	addBlocklist(policy.Blocklist, strings.NewReader(commonPasswords))
+
+	if path := os.Getenv("PASSWORD_BLOCKLIST_FILE"); path != "" {
+		file, err := os.Open(path)
+		if err != nil {
+			log.Printf("❌ Password blocklist not loaded: %v", err)
+		} else {
+			addBlocklist(policy.Blocklist, file)
+			file.Close()
+		}
+	}
+
+	if dir := os.Getenv("PASSWORD_PWNED_DIR"); dir != "" {
+		if info, err := os.Stat(dir); err != nil || !info.IsDir() {
+			log.Printf("❌ PASSWORD_PWNED_DIR %q is not a directory, breached passwords are not checked", dir)
+		} else {
+			policy.PwnedDir = dir
+		}
+	}
+
+	if value := os.Getenv("PASSWORD_MIN_STRENGTH"); value != "" {
+		if n, err := strconv.Atoi(value); err == nil && n >= 0 && n <= MaxPasswordStrength {
+			policy.MinStrength = n
+		} else {
+			log.Printf("⚠️  Invalid PASSWORD_MIN_STRENGTH %q, using %d", value, policy.MinStrength)
+		}
+	}
+	return policy
+}
+
+func addBlocklist(blocklist map[string]bool, r io.Reader) {
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line != "" && !strings.HasPrefix(line, "#") {
+			blocklist[strings.ToLower(line)] = true
+		}
+	}
+}
+
+// Check applies the policy to a password chosen by the user with the given
+// username and email. Every error is a *PasswordError.
+func (p PasswordPolicy) Check(password, username, email string) error {
+	lower := strings.ToLower(password)
+	strength := p.Strength(password, username, email)
+	refuse := func(message string) error {
+		return &PasswordError{Message: message, Strength: strength}
+	}
+
+	localPart, _, _ := strings.Cut(strings.ToLower(email), "@")
+	for _, personal := range []string{strings.ToLower(username), localPart} {
+		if len(personal) >= 3 && strings.Contains(lower, personal) {
+			return refuse("password must not contain your username or email")
+		}
+	}
+
+	if p.isCommon(password) {
+		return refuse("password is too common, choose another")
+	}
+
+	breached, err := p.isBreached(password)
+	if err != nil {
+		log.Printf("⚠️  Breached password lookup failed: %v", err)
+	}
+	if breached {
+		return refuse("password has appeared in a data breach, choose another")
+	}
+
+	if strength < p.MinStrength {
+		return refuse(fmt.Sprintf("password is too weak (strength %d of %d), try a longer mix of words, numbers and symbols", strength, MaxPasswordStrength))
+	}
+	return nil
+}
+
+// isCommon reports whether a password, or the word it is built on, is in the blocklist
+func (p PasswordPolicy) isCommon(password string) bool {
+	lower := strings.ToLower(password)
+	if p.Blocklist[lower] {
+		return true
+	}
+	base := commonBase(lower)
+	return len(base) >= 4 && p.Blocklist[base]
+}
+
+// commonBase undoes the usual decorations of a weak password: leetspeak
+// substitutions and digits or symbols around it ("P@ssw0rd!1" -> "password")
+func commonBase(lower string) string {
+	trimmed := strings.TrimFunc(lower, func(r rune) bool { return !unicode.IsLetter(r) && !leetLetter(r) })
+	trimmed = strings.TrimRightFunc(trimmed, unicode.IsDigit)
+	return strings.Map(func(r rune) rune {
+		if l, ok := leet[r]; ok {
+			return l
+		}
+		return r
+	}, trimmed)
+}
+
+var leet = map[rune]rune{'@': 'a', '4': 'a', '3': 'e', '1': 'i', '!': 'i', '0': 'o', '$': 's', '5': 's', '7': 't'}
+
+func leetLetter(r rune) bool {
+	_, ok := leet[r]
+	return ok && r != '!'
+}
+
+// isBreached looks the password's SHA-1 up in the prefix store. Only the
+// file for the first 5 hex digits is read.
+func (p PasswordPolicy) isBreached(password string) (bool, error) {
+	if p.PwnedDir == "" {
+		return false, nil
+	}
+	sum := sha1.Sum([]byte(password))
+	hash := strings.ToUpper(hex.EncodeToString(sum[:]))
+	prefix, suffix := hash[:5], hash[5:]
+
+	file, err := os.Open(filepath.Join(p.PwnedDir, prefix+".txt"))
+	if errors.Is(err, os.ErrNotExist) {
+		file, err = os.Open(filepath.Join(p.PwnedDir, prefix))
+	}
+	if errors.Is(err, os.ErrNotExist) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line, count, _ := strings.Cut(strings.TrimSpace(scanner.Text()), ":")
+		if strings.EqualFold(line, suffix) && count != "0" {
+			return true, nil
+		}
+	}
+	return false, scanner.Err()
+}
+
+// Strength estimates how hard a password is to guess, from 0 (trivial) to
+// 4 (strong). It is a rough offline estimate in the spirit of zxcvbn:
+// characters are worth the bits of their character set, but repeats,
+// sequences, keyboard runs, common words and the user's own name count
+// as a single guess each.
+func (p PasswordPolicy) Strength(password, username, email string) int {
+	lower := strings.ToLower(password)
+	if p.isCommon(password) {
+		return 0
+	}
+
+	runes := []rune(lower)
+	original := []rune(password)
+	perChar := math.Log2(float64(charsetSize(password)))
+	covered := make([]bool, len(runes))
+	bits := 0.0
+
+	// cover marks runes[i:j] as one pattern worth log2(guesses) bits
+	cover := func(i, j int, guesses float64) {
+		for k := i; k < j; k++ {
+			if covered[k] {
+				return
+			}
+		}
+		for k := i; k < j; k++ {
+			covered[k] = true
+		}
+		bits += math.Log2(guesses)
+	}
+
+	// Common words and the user's own details anywhere in the password,
+	// longest first
+	localPart, _, _ := strings.Cut(strings.ToLower(email), "@")
+	personal := map[string]bool{strings.ToLower(username): true, localPart: true}
+	for length := len(runes); length >= 4; length-- {
+		for i := 0; i+length <= len(runes); i++ {
+			if word := string(runes[i : i+length]); p.Blocklist[word] || personal[word] {
+				cover(i, i+length, float64(len(p.Blocklist)+1))
+			}
+		}
+	}
+
+	// Runs of the same character, sequences and keyboard rows (3 or more)
+	for i := 0; i < len(runes); {
+		if n := patternLength(runes[i:]); n >= 3 {
+			cover(i, i+n, float64(charsetSize(string(original[i:i+n])))*float64(n))
+			i += n
+			continue
+		}
+		i++
+	}
+
+	for k := range runes {
+		if !covered[k] {
+			bits += perChar
+		}
+	}
+
+	switch {
+	case bits < 28:
+		return 0
+	case bits < 36:
+		return 1
+	case bits < 50:
+		return 2
+	case bits < 64:
+		return 3
+	}
+	return 4
+}
+
+// patternLength returns how many runes at the start of s repeat one
+// character, form a sequence ("abc", "321") or a keyboard run ("qwerty")
+func patternLength(s []rune) int {
+	repeat, sequence := 1, 1
+	for repeat < len(s) && s[repeat] == s[0] {
+		repeat++
+	}
+	if len(s) > 1 && abs(s[1]-s[0]) == 1 {
+		step := s[1] - s[0]
+		for sequence < len(s) && s[sequence]-s[sequence-1] == step {
+			sequence++
+		}
+	}
+	return max(repeat, sequence, keyboardRun(s))
+}
+
+var keyboardRows = []string{"`1234567890-=", "qwertyuiop[]\\", "asdfghjkl;'", "zxcvbnm,./", "1qaz2wsx3edc4rfv5tgb6yhn7ujm8ik9ol0p"}
+
+// keyboardRun returns the length of the longest keyboard run s starts with
+func keyboardRun(s []rune) int {
+	best := 0
+	for _, row := range keyboardRows {
+		for _, r := range []string{row, reverse(row)} {
+			n := 0
+			for n < len(s) && strings.Contains(r, string(s[:n+1])) {
+				n++
+			}
+			best = max(best, n)
+		}
+	}
+	return best
+}
+
+func reverse(s string) string {
+	r := []rune(s)
+	for i, j := 0, len(r)-1; i < j; i, j = i+1, j-1 {
+		r[i], r[j] = r[j], r[i]
+	}
+	return string(r)
+}
+
+func abs(r rune) rune {
+	if r < 0 {
+		return -r
+	}
+	return r
+}
+
+// charsetSize is the size of the character classes a password draws from
+func charsetSize(password string) int {
+	var lower, upper, digit, symbol, other bool
+	for _, r := range password {
+		switch {
+		case r >= 'a' && r <= 'z':
+			lower = true
+		case r >= 'A' && r <= 'Z':
+			upper = true
+		case r >= '0' && r <= '9':
+			digit = true
+		case r < 128:
+			symbol = true
+		default:
+			other = true
+		}
+	}
+	size := 0
+	for _, class := range []struct {
+		used bool
+		size int
+	}{{lower, 26}, {upper, 26}, {digit, 10}, {symbol, 33}, {other, 100}} {
+		if class.used {
+			size += class.size
+		}
+	}
+	if size == 0 {
+		size = 1
+	}
+	return size
+}
@@ -0,0 +1,78 @@
+package utils
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestPasswordStrength(t *testing.T) {
+	policy := loadPasswordPolicy()
+
+	tests := []struct {
+		password string
+		max      int // highest acceptable estimate
+		min      int // lowest acceptable estimate
+	}{
+		{"password1", 0, 0},
+		{"Dr4g0n!!", 0, 0},
+		{"aaaaaaa1", 1, 0},
+		{"abcdefg1", 1, 0},
+		{"qwertyui9", 1, 0},
+		{"alice2024", 1, 0}, // the username
+		{"Xk7mq2pLw9", 4, 2},
+		{"correct-h0rse-battery-staple", 4, 3},
+	}
+	for _, tt := range tests {
+		t.Run(tt.password, func(t *testing.T) {
+			got := policy.Strength(tt.password, "alice", "alice@example.com")
+			if got < tt.min || got > tt.max {
+				t.Errorf("Expected strength in [%d, %d], got %d", tt.min, tt.max, got)
+			}
+		})
+	}
+}
+
+func TestPasswordPolicy(t *testing.T) {
+	dir := t.TempDir()
+
+	blocklist := filepath.Join(dir, "blocklist.txt")
+	os.WriteFile(blocklist, []byte("# team favourites\nForumRocks2025\n"), 0o644)
+	t.Setenv("PASSWORD_BLOCKLIST_FILE", blocklist)
+	t.Setenv("PASSWORD_PWNED_DIR", dir)
+	policy := loadPasswordPolicy()
+
+	// Store a breached password the way the HIBP range files do
+	breached := "zebra-Quartz-71"
+	sum := sha1.Sum([]byte(breached))
+	hash := strings.ToUpper(hex.EncodeToString(sum[:]))
+	os.WriteFile(filepath.Join(dir, hash[:5]+".txt"), []byte("0000000000000000000000000000000000A:3\r\n"+hash[5:]+":12\r\n"), 0o644)
+
+	tests := []struct {
+		name     string
+		password string
+		allowed  bool
+	}{
+		{"strong", "Xk7mq2pLw9-v", true},
+		{"from the blocklist file", "forumrocks2025", false},
+		{"breached", breached, false},
+		{"contains the email", "xx-alice.w-99Q", false},
+		{"too weak", "aaaaaaa1b", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := policy.Check(tt.password, "alice", "alice.w@example.com")
+			if tt.allowed != (err == nil) {
+				t.Fatalf("Expected allowed=%v, got %v", tt.allowed, err)
+			}
+			var passwordErr *PasswordError
+			if err != nil && !errors.As(err, &passwordErr) {
+				t.Errorf("Expected a *PasswordError, got %T", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,45 @@
+package webauthn
+
+import (
+	"os"
+	"strings"
+)
+
+// FromEnv builds the relying party configuration from environment variables:
+//
+//	WEBAUTHN_RP_ID             domain passkeys are bound to (default localhost)
+//	WEBAUTHN_RP_NAME           name shown by the browser (default Forum)
+//	WEBAUTHN_ORIGINS           comma separated origins (default FRONTEND_ORIGIN)
+//	WEBAUTHN_USER_VERIFICATION required, preferred or discouraged (default preferred)
+func FromEnv() Config {
+	c := Config{
+		RPID:             os.Getenv("WEBAUTHN_RP_ID"),
+		RPName:           os.Getenv("WEBAUTHN_RP_NAME"),
+		UserVerification: strings.ToLower(os.Getenv("WEBAUTHN_USER_VERIFICATION")),
+	}
+	if c.RPID == "" {
+		c.RPID = "localhost" // fallback default
+	}
+	if c.RPName == "" {
+		c.RPName = "Forum"
+	}
+	switch c.UserVerification {
+	case "required", "preferred", "discouraged":
+	default:
+		c.UserVerification = "preferred"
+	}
+
+	origins := os.Getenv("WEBAUTHN_ORIGINS")
+	if origins == "" {
+		origins = os.Getenv("FRONTEND_ORIGIN")
+	}
+	if origins == "" {
+		origins = "http://localhost:8000" // fallback default
+	}
+	for _, origin := range strings.Split(origins, ",") {
+		if origin = strings.TrimSpace(origin); origin != "" {
+			c.Origins = append(c.Origins, strings.TrimRight(origin, "/"))
+		}
+	}
+	return c
+}
@@ -0,0 +1,107 @@
+package webauthn
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"math/big"
+)
+
+// COSE algorithm identifiers accepted for credentials
+const (
+	AlgES256 = -7   // ECDSA P-256 with SHA-256
+	AlgEdDSA = -8   // Ed25519
+	AlgRS256 = -257 // RSASSA-PKCS1-v1_5 with SHA-256
+)
+
+// COSE key parameters (RFC 9053)
+const (
+	coseKty    = 1
+	coseAlg    = 3
+	coseCrv    = -1 // also the RSA modulus n
+	coseX      = -2 // also the RSA exponent e
+	coseY      = -3
+	ktyOKP     = 1
+	ktyEC2     = 2
+	ktyRSA     = 3
+	crvP256    = 1
+	crvEd25519 = 6
+)
+
+// SupportedAlgorithms lists the algorithms offered at registration, most preferred first
+var SupportedAlgorithms = []int{AlgES256, AlgEdDSA, AlgRS256}
+
+var errUnsupportedKey = errors.New("webauthn: unsupported public key")
+
+// publicKey is a parsed COSE_Key
+type publicKey struct {
+	alg int64
+	key crypto.PublicKey
+}
+
+// parsePublicKey parses a COSE_Key as stored with a credential
+func parsePublicKey(cose []byte) (publicKey, error) {
+	v, _, err := decodeCBOR(cose)
+	if err != nil {
+		return publicKey{}, err
+	}
+	m, ok := v.(map[any]any)
+	if !ok {
+		return publicKey{}, errors.New("webauthn: COSE key is not a map")
+	}
+	kty, _ := m[int64(coseKty)].(int64)
+	alg, _ := m[int64(coseAlg)].(int64)
+
+	switch {
+	case kty == ktyEC2 && alg == AlgES256:
+		crv, _ := m[int64(coseCrv)].(int64)
+		x, _ := m[int64(coseX)].([]byte)
+		y, _ := m[int64(coseY)].([]byte)
+		if crv != crvP256 || len(x) != 32 || len(y) != 32 {
+			return publicKey{}, errUnsupportedKey
+		}
+		key := &ecdsa.PublicKey{Curve: elliptic.P256(), X: new(big.Int).SetBytes(x), Y: new(big.Int).SetBytes(y)}
+		if !key.Curve.IsOnCurve(key.X, key.Y) {
+			return publicKey{}, errors.New("webauthn: EC point is not on the curve")
+		}
+		return publicKey{alg, key}, nil
+
+	case kty == ktyOKP && alg == AlgEdDSA:
+		crv, _ := m[int64(coseCrv)].(int64)
+		x, _ := m[int64(coseX)].([]byte)
+		if crv != crvEd25519 || len(x) != ed25519.PublicKeySize {
+			return publicKey{}, errUnsupportedKey
+		}
+		return publicKey{alg, ed25519.PublicKey(x)}, nil
+
+	case kty == ktyRSA && alg == AlgRS256:
+		n, _ := m[int64(coseCrv)].([]byte)
+		e, _ := m[int64(coseX)].([]byte)
+		if len(n) < 256 || len(e) == 0 || len(e) > 4 {
+			return publicKey{}, errUnsupportedKey
+		}
+		exp := int(new(big.Int).SetBytes(e).Int64())
+		return publicKey{alg, &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: exp}}, nil
+	}
+	return publicKey{}, fmt.Errorf("%w (kty %d, alg %d)", errUnsupportedKey, kty, alg)
+}
+
+// verify checks a signature over data
+func (k publicKey) verify(data, sig []byte) bool {
+	switch key := k.key.(type) {
+	case *ecdsa.PublicKey:
+		sum := sha256.Sum256(data)
+		return ecdsa.VerifyASN1(key, sum[:], sig)
+	case ed25519.PublicKey:
+		return ed25519.Verify(key, data, sig)
+	case *rsa.PublicKey:
+		sum := sha256.Sum256(data)
+		return rsa.VerifyPKCS1v15(key, crypto.SHA256, sum[:], sig) == nil
+	}
+	return false
+}
@@ -0,0 +1,388 @@
+// Package webauthn implements the relying party side of WebAuthn passkey
+// registration and login.
+//
+// Attestation statements are not verified: the forum doesn't restrict which
+// authenticators may be used, so it asks for "none" attestation and only
+// trusts the credential public key it is given at registration.
+package webauthn
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// Ceremony timeout sent to the browser, in milliseconds
+const Timeout = 5 * 60 * 1000
+
+// Authenticator data flags
+const (
+	FlagUserPresent  = 0x01
+	FlagUserVerified = 0x04
+	FlagAttestedData = 0x40
+	FlagExtensions   = 0x80
+)
+
+var (
+	ErrChallenge  = errors.New("webauthn: challenge mismatch")
+	ErrOrigin     = errors.New("webauthn: origin not allowed")
+	ErrRPID       = errors.New("webauthn: relying party ID mismatch")
+	ErrUser       = errors.New("webauthn: user presence or verification missing")
+	ErrSignature  = errors.New("webauthn: invalid signature")
+	ErrSignCount  = errors.New("webauthn: signature counter did not increase, the authenticator may be cloned")
+	ErrCredential = errors.New("webauthn: credential ID mismatch")
+)
+
+// Config identifies the relying party (this forum)
+type Config struct {
+	RPID             string   // Domain credentials are scoped to, e.g. forum.example.com
+	RPName           string   // Name shown by the browser
+	Origins          []string // Origins allowed to run ceremonies
+	UserVerification string   // "required", "preferred" or "discouraged"
+}
+
+// Bytes is binary data sent as base64url in JSON, as browsers do for WebAuthn
+type Bytes []byte
+
+func (b Bytes) MarshalJSON() ([]byte, error) {
+	return json.Marshal(base64.RawURLEncoding.EncodeToString(b))
+}
+
+func (b *Bytes) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		return err
+	}
+	decoded, err := base64.RawURLEncoding.DecodeString(strings.TrimRight(s, "="))
+	if err != nil {
+		return fmt.Errorf("webauthn: invalid base64url: %w", err)
+	}
+	*b = decoded
+	return nil
+}
+
+// EncodeID formats a credential ID the way it is stored and sent to browsers
+func EncodeID(id []byte) string {
+	return base64.RawURLEncoding.EncodeToString(id)
+}
+
+// DecodeID parses a credential ID as stored by EncodeID
+func DecodeID(id string) ([]byte, error) {
+	return base64.RawURLEncoding.DecodeString(id)
+}
+
+// NewChallenge returns a random challenge, base64url encoded
+func NewChallenge() (string, error) {
+	raw := make([]byte, 32)
+	if _, err := rand.Read(raw); err != nil {
+		return "", err
+	}
+	return EncodeID(raw), nil
+}
+
+// User is the account a credential is registered for. ID is the user handle
+// the authenticator stores and returns at login.
+type User struct {
+	ID          []byte
+	Name        string
+	DisplayName string
+}
+
+// CredentialDescriptor refers to an existing credential
+type CredentialDescriptor struct {
+	Type string `json:"type"`
+	ID   Bytes  `json:"id"`
+}
+
+// CreationOptions are passed to navigator.credentials.create()
+type CreationOptions struct {
+	Challenge string `json:"challenge"`
+	RP        struct {
+		ID   string `json:"id"`
+		Name string `json:"name"`
+	} `json:"rp"`
+	User struct {
+		ID          Bytes  `json:"id"`
+		Name        string `json:"name"`
+		DisplayName string `json:"displayName"`
+	} `json:"user"`
+	PubKeyCredParams []struct {
+		Type string `json:"type"`
+		Alg  int    `json:"alg"`
+	} `json:"pubKeyCredParams"`
+	Timeout                int                    `json:"timeout"`
+	ExcludeCredentials     []CredentialDescriptor `json:"excludeCredentials"`
+	AuthenticatorSelection struct {
+		ResidentKey        string `json:"residentKey"`
+		RequireResidentKey bool   `json:"requireResidentKey"`
+		UserVerification   string `json:"userVerification"`
+	} `json:"authenticatorSelection"`
+	Attestation string `json:"attestation"`
+}
+
+// RequestOptions are passed to navigator.credentials.get()
+type RequestOptions struct {
+	Challenge        string                 `json:"challenge"`
+	Timeout          int                    `json:"timeout"`
+	RPID             string                 `json:"rpId"`
+	AllowCredentials []CredentialDescriptor `json:"allowCredentials"`
+	UserVerification string                 `json:"userVerification"`
+}
+
+// NewCreationOptions builds registration options. Credentials are created as
+// discoverable so the user can later log in without typing a username.
+func (c Config) NewCreationOptions(challenge string, user User, exclude [][]byte) CreationOptions {
+	var o CreationOptions
+	o.Challenge = challenge
+	o.RP.ID = c.RPID
+	o.RP.Name = c.RPName
+	o.User.ID = user.ID
+	o.User.Name = user.Name
+	o.User.DisplayName = user.DisplayName
+	for _, alg := range SupportedAlgorithms {
+		o.PubKeyCredParams = append(o.PubKeyCredParams, struct {
+			Type string `json:"type"`
+			Alg  int    `json:"alg"`
+		}{"public-key", alg})
+	}
+	o.Timeout = Timeout
+	o.ExcludeCredentials = []CredentialDescriptor{}
+	for _, id := range exclude {
+		o.ExcludeCredentials = append(o.ExcludeCredentials, CredentialDescriptor{"public-key", id})
+	}
+	o.AuthenticatorSelection.ResidentKey = "required"
+	o.AuthenticatorSelection.RequireResidentKey = true
+	o.AuthenticatorSelection.UserVerification = c.UserVerification
+	o.Attestation = "none"
+	return o
+}
+
+// NewRequestOptions builds login options. No credentials are listed, so the
+// browser offers any passkey it holds for the relying party.
+func (c Config) NewRequestOptions(challenge string) RequestOptions {
+	return RequestOptions{
+		Challenge:        challenge,
+		Timeout:          Timeout,
+		RPID:             c.RPID,
+		AllowCredentials: []CredentialDescriptor{},
+		UserVerification: c.UserVerification,
+	}
+}
+
+// RegistrationResponse is the PublicKeyCredential returned by navigator.credentials.create()
+type RegistrationResponse struct {
+	ID       string `json:"id"`
+	RawID    Bytes  `json:"rawId"`
+	Type     string `json:"type"`
+	Response struct {
+		ClientDataJSON    Bytes `json:"clientDataJSON"`
+		AttestationObject Bytes `json:"attestationObject"`
+	} `json:"response"`
+}
+
+// AssertionResponse is the PublicKeyCredential returned by navigator.credentials.get()
+type AssertionResponse struct {
+	ID       string `json:"id"`
+	RawID    Bytes  `json:"rawId"`
+	Type     string `json:"type"`
+	Response struct {
+		ClientDataJSON    Bytes `json:"clientDataJSON"`
+		AuthenticatorData Bytes `json:"authenticatorData"`
+		Signature         Bytes `json:"signature"`
+		UserHandle        Bytes `json:"userHandle"`
+	} `json:"response"`
+}
+
+// Credential is a registered public key credential
+type Credential struct {
+	ID        []byte
+	PublicKey []byte // COSE_Key
+	SignCount uint32
+}
+
+// authenticatorData is the parsed authenticator data structure
+type authenticatorData struct {
+	rpIDHash     []byte
+	flags        byte
+	signCount    uint32
+	credentialID []byte
+	publicKey    []byte
+}
+
+func parseAuthenticatorData(data []byte) (authenticatorData, error) {
+	var a authenticatorData
+	if len(data) < 37 {
+		return a, errors.New("webauthn: authenticator data too short")
+	}
+	a.rpIDHash = data[:32]
+	a.flags = data[32]
+	a.signCount = binary.BigEndian.Uint32(data[33:37])
+	rest := data[37:]
+
+	if a.flags&FlagAttestedData != 0 {
+		if len(rest) < 18 {
+			return a, errors.New("webauthn: attested credential data too short")
+		}
+		// 16 byte AAGUID, then a 2 byte length and the credential ID
+		idLen := int(binary.BigEndian.Uint16(rest[16:18]))
+		rest = rest[18:]
+		if idLen > 1023 || len(rest) < idLen {
+			return a, errors.New("webauthn: invalid credential ID length")
+		}
+		a.credentialID = rest[:idLen]
+		rest = rest[idLen:]
+
+		_, after, err := decodeCBOR(rest)
+		if err != nil {
+			return a, fmt.Errorf("webauthn: invalid credential public key: %w", err)
+		}
+		a.publicKey = rest[:len(rest)-len(after)]
+		rest = after
+	}
+	if a.flags&FlagExtensions != 0 {
+		_, after, err := decodeCBOR(rest)
+		if err != nil {
+			return a, fmt.Errorf("webauthn: invalid extensions: %w", err)
+		}
+		rest = after
+	}
+	if len(rest) != 0 {
+		return a, errors.New("webauthn: trailing bytes in authenticator data")
+	}
+	return a, nil
+}
+
+// verifyClientData checks the browser's view of the ceremony
+func (c Config) verifyClientData(clientDataJSON []byte, ceremony, challenge string) error {
+	var cd struct {
+		Type        string `json:"type"`
+		Challenge   string `json:"challenge"`
+		Origin      string `json:"origin"`
+		CrossOrigin bool   `json:"crossOrigin"`
+	}
+	if err := json.Unmarshal(clientDataJSON, &cd); err != nil {
+		return fmt.Errorf("webauthn: invalid client data: %w", err)
+	}
+	if cd.Type != ceremony {
+		return fmt.Errorf("webauthn: unexpected client data type %q", cd.Type)
+	}
+	if challenge == "" || subtle.ConstantTimeCompare([]byte(strings.TrimRight(cd.Challenge, "=")), []byte(challenge)) != 1 {
+		return ErrChallenge
+	}
+	if cd.CrossOrigin {
+		return ErrOrigin
+	}
+	for _, origin := range c.Origins {
+		if cd.Origin == origin {
+			return nil
+		}
+	}
+	return fmt.Errorf("%w: %s", ErrOrigin, cd.Origin)
+}
+
+// verifyAuthenticatorData checks the RP ID hash and the user flags
+func (c Config) verifyAuthenticatorData(a authenticatorData) error {
+	rpIDHash := sha256.Sum256([]byte(c.RPID))
+	if !bytes.Equal(a.rpIDHash, rpIDHash[:]) {
+		return ErrRPID
+	}
+	if a.flags&FlagUserPresent == 0 {
+		return ErrUser
+	}
+	if c.UserVerification == "required" && a.flags&FlagUserVerified == 0 {
+		return ErrUser
+	}
+	return nil
+}
+
+// VerifyRegistration checks a navigator.credentials.create() response against
+// the challenge issued for it and returns the new credential
+func (c Config) VerifyRegistration(challenge string, r RegistrationResponse) (Credential, error) {
+	if r.Type != "public-key" {
+		return Credential{}, fmt.Errorf("webauthn: unexpected credential type %q", r.Type)
+	}
+	if err := c.verifyClientData(r.Response.ClientDataJSON, "webauthn.create", challenge); err != nil {
+		return Credential{}, err
+	}
+
+	v, _, err := decodeCBOR(r.Response.AttestationObject)
+	if err != nil {
+		return Credential{}, fmt.Errorf("webauthn: invalid attestation object: %w", err)
+	}
+	attestation, ok := v.(map[any]any)
+	if !ok {
+		return Credential{}, errors.New("webauthn: attestation object is not a map")
+	}
+	rawAuthData, ok := attestation["authData"].([]byte)
+	if !ok {
+		return Credential{}, errors.New("webauthn: attestation object has no authData")
+	}
+
+	authData, err := parseAuthenticatorData(rawAuthData)
+	if err != nil {
+		return Credential{}, err
+	}
+	if err := c.verifyAuthenticatorData(authData); err != nil {
+		return Credential{}, err
+	}
+	if authData.credentialID == nil {
+		return Credential{}, errors.New("webauthn: no attested credential data")
+	}
+	if !bytes.Equal(authData.credentialID, r.RawID) {
+		return Credential{}, ErrCredential
+	}
+	if _, err := parsePublicKey(authData.publicKey); err != nil {
+		return Credential{}, err
+	}
+
+	return Credential{
+		ID:        bytes.Clone(authData.credentialID),
+		PublicKey: bytes.Clone(authData.publicKey),
+		SignCount: authData.signCount,
+	}, nil
+}
+
+// VerifyAssertion checks a navigator.credentials.get() response for a stored
+// credential and returns the authenticator's new signature counter
+func (c Config) VerifyAssertion(challenge string, cred Credential, r AssertionResponse) (uint32, error) {
+	if r.Type != "public-key" {
+		return 0, fmt.Errorf("webauthn: unexpected credential type %q", r.Type)
+	}
+	if !bytes.Equal(cred.ID, r.RawID) {
+		return 0, ErrCredential
+	}
+	if err := c.verifyClientData(r.Response.ClientDataJSON, "webauthn.get", challenge); err != nil {
+		return 0, err
+	}
+
+	authData, err := parseAuthenticatorData(r.Response.AuthenticatorData)
+	if err != nil {
+		return 0, err
+	}
+	if err := c.verifyAuthenticatorData(authData); err != nil {
+		return 0, err
+	}
+
+	key, err := parsePublicKey(cred.PublicKey)
+	if err != nil {
+		return 0, err
+	}
+	clientDataHash := sha256.Sum256(r.Response.ClientDataJSON)
+	signed := append(bytes.Clone(r.Response.AuthenticatorData), clientDataHash[:]...)
+	if !key.verify(signed, r.Response.Signature) {
+		return 0, ErrSignature
+	}
+
+	// Authenticators that don't count always send 0
+	if (authData.signCount != 0 || cred.SignCount != 0) && authData.signCount <= cred.SignCount {
+		return 0, ErrSignCount
+	}
+	return authData.signCount, nil
+}
@@ -0,0 +1,180 @@
+package webauthn
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"forum/webauthn/webauthntest"
+)
+
+var testConfig = Config{
+	RPID:             "forum.example.com",
+	RPName:           "Forum",
+	Origins:          []string{"https://forum.example.com"},
+	UserVerification: "preferred",
+}
+
+// convert round-trips a software authenticator response through JSON, as the handlers receive it
+func convert[T any](t *testing.T, response map[string]any) T {
+	t.Helper()
+	data, _ := json.Marshal(response)
+	var v T
+	if err := json.Unmarshal(data, &v); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	return v
+}
+
+func register(t *testing.T, a *webauthntest.Authenticator) Credential {
+	t.Helper()
+	challenge, _ := NewChallenge()
+	response, err := a.Create(challenge, []byte("user-1"))
+	if err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+	cred, err := testConfig.VerifyRegistration(challenge, convert[RegistrationResponse](t, response))
+	if err != nil {
+		t.Fatalf("VerifyRegistration failed: %v", err)
+	}
+	return cred
+}
+
+func TestRegistrationAndLogin(t *testing.T) {
+	for _, alg := range []int{webauthntest.AlgES256, webauthntest.AlgEdDSA} {
+		a := webauthntest.New("forum.example.com", "https://forum.example.com")
+		a.Algorithm = alg
+		cred := register(t, a)
+
+		challenge, _ := NewChallenge()
+		response, _ := a.Get(challenge)
+		assertion := convert[AssertionResponse](t, response)
+
+		count, err := testConfig.VerifyAssertion(challenge, cred, assertion)
+		if err != nil {
+			t.Fatalf("Algorithm %d: VerifyAssertion failed: %v", alg, err)
+		}
+		if count != 1 {
+			t.Errorf("Algorithm %d: expected counter 1, got %d", alg, count)
+		}
+		if string(assertion.Response.UserHandle) != "user-1" {
+			t.Errorf("Expected user handle to be returned, got %q", assertion.Response.UserHandle)
+		}
+	}
+}
+
+func TestRegistrationRejected(t *testing.T) {
+	tests := []struct {
+		name     string
+		rpID     string
+		origin   string
+		expected error
+	}{
+		{"wrong origin", "forum.example.com", "https://evil.example.com", ErrOrigin},
+		{"wrong relying party", "evil.example.com", "https://forum.example.com", ErrRPID},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a := webauthntest.New(tt.rpID, tt.origin)
+			challenge, _ := NewChallenge()
+			response, _ := a.Create(challenge, []byte("user-1"))
+			_, err := testConfig.VerifyRegistration(challenge, convert[RegistrationResponse](t, response))
+			if !errors.Is(err, tt.expected) {
+				t.Errorf("Expected %v, got %v", tt.expected, err)
+			}
+		})
+	}
+
+	t.Run("wrong challenge", func(t *testing.T) {
+		a := webauthntest.New("forum.example.com", "https://forum.example.com")
+		response, _ := a.Create("not-the-challenge", []byte("user-1"))
+		challenge, _ := NewChallenge()
+		if _, err := testConfig.VerifyRegistration(challenge, convert[RegistrationResponse](t, response)); !errors.Is(err, ErrChallenge) {
+			t.Errorf("Expected %v, got %v", ErrChallenge, err)
+		}
+	})
+
+	t.Run("user verification required", func(t *testing.T) {
+		a := webauthntest.New("forum.example.com", "https://forum.example.com")
+		a.UserVerified = false
+		challenge, _ := NewChallenge()
+		response, _ := a.Create(challenge, []byte("user-1"))
+
+		config := testConfig
+		config.UserVerification = "required"
+		if _, err := config.VerifyRegistration(challenge, convert[RegistrationResponse](t, response)); !errors.Is(err, ErrUser) {
+			t.Errorf("Expected %v, got %v", ErrUser, err)
+		}
+	})
+}
+
+func TestAssertionRejected(t *testing.T) {
+	a := webauthntest.New("forum.example.com", "https://forum.example.com")
+	cred := register(t, a)
+
+	t.Run("tampered signature", func(t *testing.T) {
+		challenge, _ := NewChallenge()
+		response, _ := a.Get(challenge)
+		assertion := convert[AssertionResponse](t, response)
+		assertion.Response.Signature[len(assertion.Response.Signature)-1] ^= 0xff
+		if _, err := testConfig.VerifyAssertion(challenge, cred, assertion); !errors.Is(err, ErrSignature) {
+			t.Errorf("Expected %v, got %v", ErrSignature, err)
+		}
+	})
+
+	t.Run("replayed assertion", func(t *testing.T) {
+		challenge, _ := NewChallenge()
+		response, _ := a.Get(challenge)
+		next, _ := NewChallenge()
+		if _, err := testConfig.VerifyAssertion(next, cred, convert[AssertionResponse](t, response)); !errors.Is(err, ErrChallenge) {
+			t.Errorf("Expected %v, got %v", ErrChallenge, err)
+		}
+	})
+
+	t.Run("cloned authenticator", func(t *testing.T) {
+		clone := a.Clone()
+
+		challenge, _ := NewChallenge()
+		response, _ := a.Get(challenge)
+		count, err := testConfig.VerifyAssertion(challenge, cred, convert[AssertionResponse](t, response))
+		if err != nil {
+			t.Fatalf("VerifyAssertion failed: %v", err)
+		}
+		cred.SignCount = count
+
+		challenge, _ = NewChallenge()
+		response, _ = clone.Get(challenge)
+		if _, err := testConfig.VerifyAssertion(challenge, cred, convert[AssertionResponse](t, response)); !errors.Is(err, ErrSignCount) {
+			t.Errorf("Expected %v, got %v", ErrSignCount, err)
+		}
+	})
+
+	t.Run("authenticators without a counter", func(t *testing.T) {
+		static := webauthntest.New("forum.example.com", "https://forum.example.com")
+		static.StaticCounter = true
+		cred := register(t, static)
+		for i := 0; i < 2; i++ {
+			challenge, _ := NewChallenge()
+			response, _ := static.Get(challenge)
+			if _, err := testConfig.VerifyAssertion(challenge, cred, convert[AssertionResponse](t, response)); err != nil {
+				t.Errorf("Login %d: expected a zero counter to be accepted, got %v", i+1, err)
+			}
+		}
+	})
+}
+
+func TestDecodeCBORRejectsMalformedInput(t *testing.T) {
+	inputs := map[string][]byte{
+		"truncated":        {0x58, 0x10, 0x01},
+		"huge array":       {0x9b, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff},
+		"indefinite":       {0x5f},
+		"duplicate key":    {0xa2, 0x01, 0x01, 0x01, 0x02},
+		"deeply nested":    append(make([]byte, 0), 0x81, 0x81, 0x81, 0x81, 0x81, 0x81, 0x81, 0x81, 0x81, 0x81, 0x81, 0x81, 0x81, 0x81, 0x81, 0x81, 0x81, 0x81, 0x00),
+		"unsupported type": {0xf9, 0x3c, 0x00},
+	}
+	for name, input := range inputs {
+		if _, _, err := decodeCBOR(input); err == nil {
+			t.Errorf("%s: expected an error", name)
+		}
+	}
+}
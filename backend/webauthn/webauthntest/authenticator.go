@@ -0,0 +1,231 @@
+// Package webauthntest provides a software passkey authenticator so WebAuthn
+// ceremonies can be tested without a browser or security key.
+package webauthntest
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+)
+
+// COSE algorithms the authenticator can create credentials with
+const (
+	AlgES256 = -7
+	AlgEdDSA = -8
+)
+
+// Authenticator behaves like a platform authenticator holding discoverable
+// credentials. Responses are the JSON a browser client sends to the server.
+type Authenticator struct {
+	RPID          string
+	Origin        string
+	Algorithm     int  // AlgES256 (default) or AlgEdDSA
+	UserVerified  bool // Set the UV flag (default true)
+	StaticCounter bool // Never increase the signature counter, like many passkey providers
+
+	credentials []*credential
+}
+
+type credential struct {
+	id         []byte
+	userHandle []byte
+	alg        int
+	signer     crypto.Signer
+	counter    uint32
+}
+
+// New creates an authenticator for a relying party ID and client origin
+func New(rpID, origin string) *Authenticator {
+	return &Authenticator{RPID: rpID, Origin: origin, Algorithm: AlgES256, UserVerified: true}
+}
+
+// Clone copies the authenticator with its keys and counters, as an attacker
+// who extracted the credentials would
+func (a *Authenticator) Clone() *Authenticator {
+	c := *a
+	c.credentials = nil
+	for _, cred := range a.credentials {
+		copied := *cred
+		c.credentials = append(c.credentials, &copied)
+	}
+	return &c
+}
+
+// Create answers navigator.credentials.create() for the given challenge and user handle
+func (a *Authenticator) Create(challenge string, userHandle []byte) (map[string]any, error) {
+	cred := &credential{userHandle: userHandle, alg: a.Algorithm, id: make([]byte, 16)}
+	if _, err := rand.Read(cred.id); err != nil {
+		return nil, err
+	}
+
+	var coseKey []byte
+	switch a.Algorithm {
+	case AlgES256, 0:
+		cred.alg = AlgES256
+		key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+		if err != nil {
+			return nil, err
+		}
+		cred.signer = key
+		x, y := make([]byte, 32), make([]byte, 32)
+		key.X.FillBytes(x)
+		key.Y.FillBytes(y)
+		coseKey = encodeMap(
+			encodeInt(1), encodeInt(2), // kty: EC2
+			encodeInt(3), encodeInt(AlgES256), // alg
+			encodeInt(-1), encodeInt(1), // crv: P-256
+			encodeInt(-2), encodeBytes(x),
+			encodeInt(-3), encodeBytes(y),
+		)
+	case AlgEdDSA:
+		pub, priv, err := ed25519.GenerateKey(rand.Reader)
+		if err != nil {
+			return nil, err
+		}
+		cred.signer = priv
+		coseKey = encodeMap(
+			encodeInt(1), encodeInt(1), // kty: OKP
+			encodeInt(3), encodeInt(AlgEdDSA), // alg
+			encodeInt(-1), encodeInt(6), // crv: Ed25519
+			encodeInt(-2), encodeBytes(pub),
+		)
+	default:
+		return nil, errors.New("webauthntest: unsupported algorithm")
+	}
+
+	attested := make([]byte, 18, 18+len(cred.id)+len(coseKey)) // zero AAGUID
+	binary.BigEndian.PutUint16(attested[16:], uint16(len(cred.id)))
+	attested = append(append(attested, cred.id...), coseKey...)
+	authData := a.authenticatorData(0x40, 0, attested)
+
+	attestationObject := encodeMap(
+		encodeText("fmt"), encodeText("none"),
+		encodeText("attStmt"), encodeMap(),
+		encodeText("authData"), encodeBytes(authData),
+	)
+
+	a.credentials = append(a.credentials, cred)
+	return map[string]any{
+		"id":    b64(cred.id),
+		"rawId": b64(cred.id),
+		"type":  "public-key",
+		"response": map[string]any{
+			"clientDataJSON":    b64(a.clientData("webauthn.create", challenge)),
+			"attestationObject": b64(attestationObject),
+		},
+	}, nil
+}
+
+// Get answers navigator.credentials.get() with the most recently created credential
+func (a *Authenticator) Get(challenge string) (map[string]any, error) {
+	if len(a.credentials) == 0 {
+		return nil, errors.New("webauthntest: no credentials")
+	}
+	cred := a.credentials[len(a.credentials)-1]
+	if !a.StaticCounter {
+		cred.counter++
+	}
+
+	authData := a.authenticatorData(0, cred.counter, nil)
+	clientData := a.clientData("webauthn.get", challenge)
+	clientDataHash := sha256.Sum256(clientData)
+	signed := append(bytes.Clone(authData), clientDataHash[:]...)
+
+	var signature []byte
+	var err error
+	switch key := cred.signer.(type) {
+	case *ecdsa.PrivateKey:
+		digest := sha256.Sum256(signed)
+		signature, err = ecdsa.SignASN1(rand.Reader, key, digest[:])
+	case ed25519.PrivateKey:
+		signature = ed25519.Sign(key, signed)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return map[string]any{
+		"id":    b64(cred.id),
+		"rawId": b64(cred.id),
+		"type":  "public-key",
+		"response": map[string]any{
+			"clientDataJSON":    b64(clientData),
+			"authenticatorData": b64(authData),
+			"signature":         b64(signature),
+			"userHandle":        b64(cred.userHandle),
+		},
+	}, nil
+}
+
+func (a *Authenticator) authenticatorData(flags byte, counter uint32, attested []byte) []byte {
+	flags |= 0x01 // user present
+	if a.UserVerified {
+		flags |= 0x04
+	}
+	rpIDHash := sha256.Sum256([]byte(a.RPID))
+	data := append(rpIDHash[:], flags, 0, 0, 0, 0)
+	binary.BigEndian.PutUint32(data[33:], counter)
+	return append(data, attested...)
+}
+
+func (a *Authenticator) clientData(typ, challenge string) []byte {
+	data, _ := json.Marshal(map[string]any{
+		"type":        typ,
+		"challenge":   challenge,
+		"origin":      a.Origin,
+		"crossOrigin": false,
+	})
+	return data
+}
+
+func b64(b []byte) string {
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+// Minimal CBOR encoding, enough for attestation objects and COSE keys
+
+func encodeHead(major byte, n uint64) []byte {
+	switch {
+	case n < 24:
+		return []byte{major<<5 | byte(n)}
+	case n <= 0xff:
+		return []byte{major<<5 | 24, byte(n)}
+	case n <= 0xffff:
+		return binary.BigEndian.AppendUint16([]byte{major<<5 | 25}, uint16(n))
+	case n <= 0xffffffff:
+		return binary.BigEndian.AppendUint32([]byte{major<<5 | 26}, uint32(n))
+	}
+	return binary.BigEndian.AppendUint64([]byte{major<<5 | 27}, n)
+}
+
+func encodeInt(v int) []byte {
+	if v < 0 {
+		return encodeHead(1, uint64(-1-v))
+	}
+	return encodeHead(0, uint64(v))
+}
+
+func encodeBytes(b []byte) []byte {
+	return append(encodeHead(2, uint64(len(b))), b...)
+}
+
+func encodeText(s string) []byte {
+	return append(encodeHead(3, uint64(len(s))), s...)
+}
+
+// encodeMap encodes alternating keys and values
+func encodeMap(items ...[]byte) []byte {
+	out := encodeHead(5, uint64(len(items)/2))
+	for _, item := range items {
+		out = append(out, item...)
+	}
+	return out
+}
@@ -36,6 +36,11 @@ export class AuthManager {
     async login(email, password) {
         try {
             const result = await ApiUtils.post('/api/login', { email, password }, true);
+
+            // Accounts with two-factor authentication need a code before a session is created
+            if (result && result.two_factor_required && !(await this.completeTwoFactor(result.pending_token))) {
+                return { success: false, error: 'Two-factor code required' };
+            }
             
             // Fetch user data after successful login
             const user = await ApiUtils.get('/api/user', true);
@@ -48,6 +53,150 @@ export class AuthManager {
         }
     }
 
+    /**
+     * Ask for a TOTP or recovery code and finish a login waiting for a second factor
+     * @param {string} pendingToken - Token returned by the first login step
+     * @returns {Promise<boolean>} - Whether a code was submitted
+     */
+    async completeTwoFactor(pendingToken) {
+        const code = window.prompt('Enter the code from your authenticator app, or a recovery code');
+        if (!code) {
+            return false;
+        }
+        const body = /^\d{6}$/.test(code.trim())
+            ? { pending_token: pendingToken, code: code.trim() }
+            : { pending_token: pendingToken, recovery_code: code.trim() };
+        await ApiUtils.post('/api/login/2fa', body, true);
+        return true;
+    }
+
+    /**
+     * Finish a login with an OpenID Connect provider after it redirected back
+     * with an error or a pending two-factor login in the URL
+     * @returns {Promise<Object|null>} - Login result, or null if there was nothing to finish
+     */
+    async completeProviderLogin() {
+        const params = new URLSearchParams(window.location.search);
+        const error = params.get('login_error');
+        const pendingToken = params.get('two_factor');
+        if (!error && !pendingToken) {
+            return null;
+        }
+
+        params.delete('login_error');
+        params.delete('two_factor');
+        const query = params.toString();
+        window.history.replaceState({}, '', window.location.pathname + (query ? `?${query}` : ''));
+
+        if (error) {
+            return { success: false, error };
+        }
+        try {
+            if (!(await this.completeTwoFactor(pendingToken))) {
+                return { success: false, error: 'Two-factor code required' };
+            }
+            const user = await ApiUtils.get('/api/user', true);
+            this.currentUser = user;
+            this.isAuthenticated = true;
+            return { success: true, user };
+        } catch (error) {
+            return { success: false, error: error.message };
+        }
+    }
+
+    /**
+     * Login with a passkey (WebAuthn) instead of a password
+     * @returns {Promise<Object>} - Login result
+     */
+    async loginWithPasskey() {
+        try {
+            if (!window.PublicKeyCredential) {
+                return { success: false, error: 'Passkeys are not supported by this browser' };
+            }
+            const { publicKey } = await ApiUtils.post('/api/webauthn/login/begin', {}, true);
+            const credential = await navigator.credentials.get({
+                publicKey: {
+                    ...publicKey,
+                    challenge: AuthManager.fromBase64Url(publicKey.challenge),
+                    allowCredentials: [],
+                },
+            });
+            await ApiUtils.post('/api/webauthn/login/finish', AuthManager.credentialToJSON(credential), true);
+
+            const user = await ApiUtils.get('/api/user', true);
+            this.currentUser = user;
+            this.isAuthenticated = true;
+
+            return { success: true, user };
+        } catch (error) {
+            return { success: false, error: error.message };
+        }
+    }
+
+    /**
+     * Add a passkey to the logged in account
+     * @param {string} name - Label shown in the passkey list
+     * @returns {Promise<Object>} - Registration result
+     */
+    async registerPasskey(name) {
+        try {
+            const { publicKey } = await ApiUtils.post('/api/webauthn/register/begin', {}, true);
+            const credential = await navigator.credentials.create({
+                publicKey: {
+                    ...publicKey,
+                    challenge: AuthManager.fromBase64Url(publicKey.challenge),
+                    user: { ...publicKey.user, id: AuthManager.fromBase64Url(publicKey.user.id) },
+                    excludeCredentials: publicKey.excludeCredentials.map((c) => ({ ...c, id: AuthManager.fromBase64Url(c.id) })),
+                },
+            });
+            const passkey = await ApiUtils.post('/api/webauthn/register/finish', { ...AuthManager.credentialToJSON(credential), name }, true);
+            return { success: true, passkey };
+        } catch (error) {
+            return { success: false, error: error.message };
+        }
+    }
+
+    /**
+     * Decode base64url into an ArrayBuffer
+     * @param {string} value - base64url string
+     * @returns {ArrayBuffer}
+     */
+    static fromBase64Url(value) {
+        const base64 = value.replace(/-/g, '+').replace(/_/g, '/');
+        const binary = atob(base64 + '='.repeat((4 - base64.length % 4) % 4));
+        return Uint8Array.from(binary, (c) => c.charCodeAt(0)).buffer;
+    }
+
+    /**
+     * Encode an ArrayBuffer as base64url
+     * @param {ArrayBuffer} buffer - Binary data
+     * @returns {string}
+     */
+    static toBase64Url(buffer) {
+        const binary = String.fromCharCode(...new Uint8Array(buffer));
+        return btoa(binary).replace(/\+/g, '-').replace(/\//g, '_').replace(/=+$/, '');
+    }
+
+    /**
+     * Convert a PublicKeyCredential into the JSON the backend expects
+     * @param {PublicKeyCredential} credential - Result of navigator.credentials.create/get
+     * @returns {Object}
+     */
+    static credentialToJSON(credential) {
+        const response = {};
+        for (const key of ['clientDataJSON', 'attestationObject', 'authenticatorData', 'signature', 'userHandle']) {
+            if (credential.response[key]) {
+                response[key] = AuthManager.toBase64Url(credential.response[key]);
+            }
+        }
+        return {
+            id: credential.id,
+            rawId: AuthManager.toBase64Url(credential.rawId),
+            type: credential.type,
+            response,
+        };
+    }
+
     /**
      * Register new user
      * @param {FormData} formData - Registration form data
@@ -55,7 +204,12 @@ export class AuthManager {
      */
     async register(formData) {
         try {
-            await ApiUtils.post('/api/register', formData, true, true);
+            const registration = await ApiUtils.post('/api/register', formData, true, true);
+
+            // Accounts waiting for admin approval can't log in yet
+            if (registration && registration.status === 'pending') {
+                return { success: true, pending: true, message: registration.message };
+            }
             
             // Auto-login after registration
             const email = formData.get('email');
@@ -3,6 +3,8 @@
  */
 
 import { AuthManager } from './AuthManager.mjs';
+import { ApiUtils } from '../utils/ApiUtils.mjs';
+import { ProofOfWork } from '../utils/ProofOfWork.mjs';
 
 export class AuthModal {
     constructor(authManager, onAuthSuccess, notificationManager = null) {
@@ -138,11 +140,51 @@ export class AuthModal {
         }
     }
 
+    /**
+     * Handle passkey login
+     */
+    async handlePasskeyLogin() {
+        const result = await this.authManager.loginWithPasskey();
+
+        if (result.success) {
+            this.showNotification('Login successful! Welcome back!', 'success');
+            this.hideModal();
+            if (this.onAuthSuccess) {
+                this.onAuthSuccess(result.user);
+            }
+        } else {
+            this.showNotification('Passkey sign in failed. Please try again or use your password.', 'error');
+        }
+    }
+
+    /**
+     * Add a "Sign in with ..." button for each configured OpenID Connect provider
+     * @param {HTMLElement} anchor - Element the buttons are inserted after
+     */
+    async renderProviderButtons(anchor) {
+        if (!anchor) {
+            return;
+        }
+        try {
+            const providers = await ApiUtils.get('/api/oidc/providers');
+            for (const provider of providers.slice().reverse()) {
+                const link = document.createElement('a');
+                link.className = 'submit-btn oidc-submit';
+                link.href = `${ApiUtils.BASE_URL}${provider.login_url}`;
+                link.textContent = `Sign In with ${provider.display_name}`;
+                anchor.insertAdjacentElement('afterend', link);
+            }
+        } catch (error) {
+            console.error('Failed to load login providers:', error);
+        }
+    }
+
     /**
      * Setup login form submission
      */
     setupLoginForm() {
         const signInBtn = document.querySelector('.signin-submit');
+        const passkeyBtn = document.querySelector('.passkey-submit');
         const emailInput = document.getElementById('signin-email');
         const passwordInput = document.getElementById('signin-password');
 
@@ -153,6 +195,14 @@ export class AuthModal {
             });
         }
 
+        if (passkeyBtn) {
+            passkeyBtn.addEventListener('click', async () => {
+                await this.handlePasskeyLogin();
+            });
+        }
+
+        this.renderProviderButtons(passkeyBtn || signInBtn);
+
         // Enter key handlers for input fields
         if (emailInput) {
             emailInput.addEventListener('keydown', async (e) => {
@@ -205,11 +255,21 @@ export class AuthModal {
         if (avatarInput.files.length > 0) {
             submitFormData.append("avatar", avatarInput.files[0]);
         }
+        if (formData.inviteCode) {
+            submitFormData.append("invite_code", formData.inviteCode);
+        }
+        if (formData.website) {
+            submitFormData.append("website", formData.website);
+        }
 
         try {
+            await ProofOfWork.appendSolution(submitFormData, 'register');
             const result = await this.authManager.register(submitFormData);
 
-            if (result.success) {
+            if (result.success && result.pending) {
+                this.showNotification(result.message, 'info');
+                this.hideModal();
+            } else if (result.success) {
                 this.showNotification('Registration successful! Welcome to the forum! 🎉', 'success');
                 this.hideModal();
                 if (this.onAuthSuccess) {
@@ -226,9 +286,12 @@ export class AuthModal {
                 } else if (errorMessage.includes('username') && errorMessage.includes('exists')) {
                     errorMessage = 'This username is already taken.';
                     suggestion = 'Please choose a different username.';
+                } else if (errorMessage.toLowerCase().includes('invite')) {
+                    suggestion = 'Ask a member of the forum for an invite code.';
                 } else if (errorMessage.includes('password')) {
+                    // The server explains what is wrong (too common, too weak, ...)
+                    suggestion = errorMessage.charAt(0).toUpperCase() + errorMessage.slice(1) + '.';
                     errorMessage = 'Password does not meet requirements.';
-                    suggestion = 'Password must be at least 8 characters long.';
                 } else if (errorMessage.includes('email') && errorMessage.includes('invalid')) {
                     errorMessage = 'Please enter a valid email address.';
                     suggestion = 'Check the email format and try again.';
@@ -288,7 +351,9 @@ export class AuthModal {
             username: document.getElementById('signup-username').value.trim(),
             email: document.getElementById('signup-email').value.trim(),
             password: document.getElementById('signup-password').value,
-            confirmPassword: document.getElementById('signup-confirm').value
+            confirmPassword: document.getElementById('signup-confirm').value,
+            inviteCode: document.getElementById('signup-invite').value.trim(),
+            website: document.getElementById('signup-website').value
         };
     }
 
@@ -5,6 +5,7 @@
 import { ApiUtils } from '../utils/ApiUtils.mjs';
 import { TimeUtils } from '../utils/TimeUtils.mjs';
 import { PostCard } from '../posts/PostCard.mjs';
+import { BookmarkButton } from '../posts/BookmarkButton.mjs';
 
 export class CommentManager {
     constructor(authModal, reactionManager, notificationManager = null) {
@@ -26,6 +27,9 @@ export class CommentManager {
             commentItem.classList.add('reply-comment');
         }
         commentItem.setAttribute('comment-id', `${comment.id}`);
+        if (comment.accepted) {
+            commentItem.classList.add('accepted-answer');
+        }
 
         // Use the correct field names from the backend
         const username = comment.username || comment.UserName;
@@ -59,6 +63,7 @@ export class CommentManager {
                         <i class="fas fa-reply"></i>
                         <span>Reply</span>
                     </button>
+                    ${BookmarkButton.render('comment', comment.id, comment.is_bookmarked)}
                 </div>
             `;
         }
@@ -71,10 +76,12 @@ export class CommentManager {
                 </div>
                 <div class="comment-details">
                     <div>
-                        <p class="comment-content">
+                        <div class="comment-content">
                             <strong><span class="comment-username">${username}</span>:</strong>
-                            <span class="comment-text">${comment.content}</span>
-                        </p>
+                            <div class="comment-text">${comment.content_html}</div>
+                            ${comment.status === 'pending' ? '<span class="pending-badge">Awaiting review</span>' : ''}
+                            ${comment.accepted ? '<span class="accepted-badge"><i class="fas fa-check"></i> Accepted answer</span>' : ''}
+                        </div>
                     </div>
                     <div class="comment-footer">
                         ${commentActions}
@@ -85,6 +92,11 @@ export class CommentManager {
             ${!isReply ? `<div class="replies-container" data-comment-id="${comment.id}"></div>` : ''}
         `;
 
+        const bookmarkBtn = commentItem.querySelector('.bookmark-btn');
+        if (bookmarkBtn) {
+            BookmarkButton.bind(bookmarkBtn);
+        }
+
         return commentItem;
     }
 
@@ -143,7 +155,7 @@ export class CommentManager {
                         <div class="comment-wrapper">
                             <div class="comment-details">
                                 <p class="comment-content">
-                                    <strong>Error loading reply:</strong> ${childReply.content || 'Content unavailable'}
+                                    <strong>Error loading reply:</strong> ${childReply.content_html || 'Content unavailable'}
                                 </p>
                             </div>
                         </div>
@@ -267,6 +279,9 @@ export class CommentManager {
         try {
             const result = await ApiUtils.post('/api/comments/create', commentData, true);
             console.log(`✅ PARENT comment created successfully:`, result);
+            if (result && result.status === 'pending') {
+                this.showNotification('Your comment will appear once a moderator has reviewed it.', 'info');
+            }
 
             // Clear the textarea
             form.querySelector('textarea').value = '';
@@ -315,6 +330,9 @@ export class CommentManager {
         try {
             const result = await ApiUtils.post('/api/comment/reply/create', replyData, true);
             console.log(`✅ CHILD reply created successfully under PARENT ${parentCommentId}:`, result);
+            if (result && result.status === 'pending') {
+                this.showNotification('Your reply will appear once a moderator has reviewed it.', 'info');
+            }
 
             // Find the post ID from the parent comment context
             const postCommentSection = form.closest('.post-comment');
@@ -13,6 +13,7 @@ import { PostForm } from '../posts/PostForm.mjs';
 import { CommentManager } from '../comments/CommentManager.mjs';
 import { NotificationManager } from '../notifications/NotificationManager.mjs';
 import { ApiUtils } from '../utils/ApiUtils.mjs';
+import { MentionAutocomplete } from '../utils/MentionAutocomplete.mjs';
 
 export class App {
     constructor() {
@@ -25,6 +26,7 @@ export class App {
         this.postManager = null;
         this.postForm = null;
         this.commentManager = null;
+        this.mentionAutocomplete = null;
         this.router = null;
 
         this.init();
@@ -71,6 +73,9 @@ export class App {
                 this.notificationManager
             );
 
+            // Suggest users while @mentions are typed in any composer
+            this.mentionAutocomplete = new MentionAutocomplete();
+
             // Setup router first
             console.log('App: Setting up router');
             this.router = setupRouter(this);
@@ -111,6 +116,12 @@ export class App {
         // Render categories in sidebar
         await this.categoryManager.renderCategories();
 
+        // Finish a "Sign in with ..." login the provider redirected back from
+        const providerLogin = await this.authManager.completeProviderLogin();
+        if (providerLogin && !providerLogin.success) {
+            this.notificationManager.showToast(providerLogin.error, 'error');
+        }
+
         // Setup authentication UI
         await this.navManager.setupAuthButtons();
 
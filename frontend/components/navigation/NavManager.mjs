@@ -210,6 +210,12 @@ export class NavManager {
                 case 'likedposts':
                     this.router.navigate('/likedposts');
                     break;
+                case 'feed':
+                    this.router.navigate('/feed');
+                    break;
+                case 'bookmarks':
+                    this.router.navigate('/bookmarks');
+                    break;
                 default:
                     console.warn(`Unknown view: ${view}`);
             }
@@ -0,0 +1,75 @@
+/**
+ * Bookmark Button - Saves posts and comments to the user's bookmarks
+ */
+
+import { ApiUtils } from '../utils/ApiUtils.mjs';
+
+export class BookmarkButton {
+    /**
+     * HTML of a bookmark button for a post or comment
+     * @param {string} type - 'post' or 'comment'
+     * @param {number} id - Post or comment ID
+     * @param {boolean} saved - Whether the user bookmarked it
+     * @returns {string} - Button HTML
+     */
+    static render(type, id, saved) {
+        return `
+            <button class="bookmark-btn ${saved ? 'saved' : ''}" data-type="${type}" data-id="${Number(id)}" title="${saved ? 'Remove bookmark' : 'Bookmark'}">
+                <i class="${saved ? 'fas' : 'far'} fa-bookmark"></i>
+            </button>`;
+    }
+
+    /**
+     * Show whether a button's content is bookmarked
+     * @param {HTMLElement} btn - Bookmark button
+     * @param {boolean} saved - Whether it is bookmarked
+     */
+    static setState(btn, saved) {
+        btn.classList.toggle('saved', saved);
+        btn.title = saved ? 'Remove bookmark' : 'Bookmark';
+        const icon = btn.querySelector('i');
+        if (icon) {
+            icon.className = `${saved ? 'fas' : 'far'} fa-bookmark`;
+        }
+    }
+
+    /**
+     * Bookmark a button's content, or remove the bookmark. The button
+     * carries data-type ('post' or 'comment') and data-id.
+     * @param {HTMLElement} btn - Bookmark button
+     * @returns {Promise<boolean>} - Whether the content is now bookmarked
+     */
+    static async toggle(btn) {
+        const saved = btn.classList.contains('saved');
+        const key = btn.dataset.type === 'comment' ? 'comment_id' : 'post_id';
+        const target = { [key]: Number(btn.dataset.id) };
+
+        btn.disabled = true;
+        try {
+            if (saved) {
+                await ApiUtils.send('DELETE', '/api/bookmarks/remove', target, true);
+            } else {
+                await ApiUtils.post('/api/bookmarks/add', target, true);
+            }
+            BookmarkButton.setState(btn, !saved);
+            return !saved;
+        } finally {
+            btn.disabled = false;
+        }
+    }
+
+    /**
+     * Toggle the bookmark when a button is clicked
+     * @param {HTMLElement} btn - Bookmark button
+     */
+    static bind(btn) {
+        btn.addEventListener('click', async (e) => {
+            e.stopPropagation();
+            try {
+                await BookmarkButton.toggle(btn);
+            } catch (error) {
+                ApiUtils.handleError(error, 'bookmarking', true);
+            }
+        });
+    }
+}
@@ -0,0 +1,107 @@
+/**
+ * Poll View - Renders the poll of a post and handles voting
+ */
+
+import { ApiUtils } from '../utils/ApiUtils.mjs';
+
+export class PollView {
+    /**
+     * Create the element of a post's poll. Questions and options arrive
+     * HTML escaped like titles.
+     * @param {Object} poll - Poll from the post payload
+     * @returns {HTMLElement} - Poll element
+     */
+    static create(poll) {
+        const pollDiv = document.createElement('div');
+        pollDiv.className = 'post-poll';
+        PollView.render(pollDiv, poll);
+        return pollDiv;
+    }
+
+    /**
+     * Render a poll into its element, as a ballot until the user has voted
+     * and as results afterwards
+     * @param {HTMLElement} pollDiv - Poll element
+     * @param {Object} poll - Poll from the post payload
+     */
+    static render(pollDiv, poll) {
+        const voted = poll.my_votes.length > 0;
+        const showBallot = !poll.closed && (!voted || pollDiv.dataset.changing === 'true');
+        const inputType = poll.multiple ? 'checkbox' : 'radio';
+
+        const options = poll.options.map(option => {
+            const chosen = poll.my_votes.includes(option.id);
+            if (showBallot) {
+                return `
+                    <label class="poll-option">
+                        <input type="${inputType}" name="poll-${poll.id}" value="${option.id}" ${chosen ? 'checked' : ''} />
+                        <span>${option.label}</span>
+                    </label>`;
+            }
+            const percent = poll.results_hidden ? 0 : option.percent;
+            return `
+                <div class="poll-result ${chosen ? 'poll-result-mine' : ''}">
+                    <div class="poll-result-bar" style="width: ${percent}%"></div>
+                    <span class="poll-result-label">${option.label}</span>
+                    ${poll.results_hidden ? '' : `<span class="poll-result-count">${option.percent}% (${option.votes})</span>`}
+                </div>`;
+        }).join('');
+
+        let status = `${poll.voters} ${poll.voters === 1 ? 'voter' : 'voters'}`;
+        if (poll.closed) {
+            status += ' · Closed';
+        } else {
+            status += ` · Closes ${new Date(poll.closes_at).toLocaleString()}`;
+            if (poll.results_hidden) status += ' · Results shown when the poll closes';
+        }
+
+        let actions = '';
+        if (showBallot) {
+            actions = `<button type="button" class="poll-vote-btn">${voted ? 'Change vote' : 'Vote'}</button>`;
+        } else if (!poll.closed) {
+            actions = '<button type="button" class="poll-change-btn">Change vote</button>';
+        }
+
+        pollDiv.innerHTML = `
+            <div class="poll-question">${poll.question}</div>
+            ${poll.multiple && showBallot ? '<div class="poll-hint">Choose one or more</div>' : ''}
+            <div class="poll-options">${options}</div>
+            <div class="poll-footer">
+                <span class="poll-status">${status}</span>
+                ${actions}
+            </div>
+        `;
+
+        pollDiv.querySelector('.poll-vote-btn')?.addEventListener('click', () => PollView.vote(pollDiv, poll, voted));
+        pollDiv.querySelector('.poll-change-btn')?.addEventListener('click', () => {
+            pollDiv.dataset.changing = 'true';
+            PollView.render(pollDiv, poll);
+        });
+    }
+
+    /**
+     * Send the chosen options and show the updated poll
+     * @param {HTMLElement} pollDiv - Poll element
+     * @param {Object} poll - Poll being voted in
+     * @param {boolean} change - Whether an earlier vote is replaced
+     */
+    static async vote(pollDiv, poll, change) {
+        const optionIDs = [...pollDiv.querySelectorAll('.poll-option input:checked')].map(input => Number(input.value));
+        if (optionIDs.length === 0) {
+            ApiUtils.showWarning('Choose an option first.');
+            return;
+        }
+
+        const payload = { poll_id: poll.id, option_ids: optionIDs };
+        try {
+            const result = change
+                ? await ApiUtils.put('/api/polls/vote/change', payload, true)
+                : await ApiUtils.post('/api/polls/vote', payload, true);
+            pollDiv.dataset.changing = 'false';
+            PollView.render(pollDiv, result.data);
+        } catch (error) {
+            const errorInfo = ApiUtils.handleError(error, 'poll vote');
+            ApiUtils.showError(errorInfo.requiresAuth ? 'You need to be logged in to vote.' : errorInfo.message);
+        }
+    }
+}
@@ -3,6 +3,8 @@
  */
 
 import { TimeUtils } from '../utils/TimeUtils.mjs';
+import { PollView } from './PollView.mjs';
+import { BookmarkButton } from './BookmarkButton.mjs';
 
 export class PostCard {
     /**
@@ -30,17 +32,18 @@ export class PostCard {
                 <span class="post-time">${TimeUtils.getTimeAgo(post.created_at)}</span>
             </div>
             <div class="post-content">
-                <div class="post-title">${post.title}</div>
+                <div class="post-title">${post.title}${post.status === 'pending' ? ' <span class="pending-badge">Awaiting review</span>' : ''}${post.answered ? ' <span class="answered-badge">Answered</span>' : ''}</div>
                 ${PostCard.renderCategories(post.category_names || [])}
                 <div class="post-image hidden">
                     <img src="http://localhost:8080${post.image_url || ''}" alt="Post image" onerror="this.parentElement.innerHTML='<div class=\\'image-error\\'>Image unavailable</div>'"/>
                 </div>
-                <div class="post-body">${post.content}</div>
+                <div class="post-body">${post.content_html}</div>
             </div>
             <div class="post-actions">
                 <button class="reaction-btn like-btn" data-id="${post.id}"><i class="fas fa-thumbs-up"></i></button>
                 <button class="reaction-btn dislike-btn" data-id="${post.id}"><i class="fas fa-thumbs-down"></i></button>
                 <button class="reaction-btn comment-btn" data-id="${post.id}"><i class="fas fa-comment"></i></button>
+                ${BookmarkButton.render('post', post.id, post.is_bookmarked)}
             </div>
             <div class="post-comment hidden" data-id="${post.id}">
                 <div class="comments-container">
@@ -55,6 +58,12 @@ export class PostCard {
             imageEl.classList.remove("hidden");
         }
 
+        if (post.poll) {
+            postDiv.querySelector(".post-body").after(PollView.create(post.poll));
+        }
+
+        BookmarkButton.bind(postDiv.querySelector(".post-actions .bookmark-btn"));
+
         return postDiv;
     }
 
@@ -107,7 +116,7 @@ export class PostCard {
         // Add click handler to the post card
         postCard.addEventListener('click', (e) => {
             // Don't expand if clicking on interactive elements
-            if (e.target.closest('.reaction-btn, .comment-btn, .post-actions, button, a')) {
+            if (e.target.closest('.reaction-btn, .comment-btn, .post-actions, .post-poll, button, a')) {
                 return;
             }
 
@@ -260,8 +269,8 @@ export class PostCard {
                     <i class="fas fa-chevron-up"></i> Collapse
                 </button>
                 <div class="post-actions">
-                    <button class="save-post-btn" title="Save Post">
-                        <i class="fas fa-bookmark"></i>
+                    <button class="save-post-btn" data-type="post" data-id="${post.id}" title="Bookmark">
+                        <i class="far fa-bookmark"></i>
                     </button>
                     <button class="share-post-btn" title="Share Post">
                         <i class="fas fa-share"></i>
@@ -276,7 +285,7 @@ export class PostCard {
                         <img src="http://localhost:8080${post.image_url}" alt="Post image" class="post-image-full" onerror="this.parentElement.innerHTML='<div class=\\'image-error\\'>Image unavailable</div>'">
                     </div>
                 ` : ''}
-                <div class="post-full-text">${post.content}</div>
+                <div class="post-full-text">${post.content_html}</div>
             </div>
 
             <div class="post-stats-detailed">
@@ -326,10 +335,8 @@ export class PostCard {
         // Setup save and share buttons
         const saveBtn = container.querySelector('.save-post-btn');
         if (saveBtn) {
-            saveBtn.addEventListener('click', () => {
-                console.log('Save post:', post.id);
-                saveBtn.classList.toggle('saved');
-            });
+            BookmarkButton.setState(saveBtn, post.is_bookmarked);
+            BookmarkButton.bind(saveBtn);
         }
 
         const shareBtn = container.querySelector('.share-post-btn');
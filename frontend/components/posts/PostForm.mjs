@@ -3,6 +3,7 @@
  */
 
 import { ApiUtils } from '../utils/ApiUtils.mjs';
+import { ProofOfWork } from '../utils/ProofOfWork.mjs';
 
 export class PostForm {
     constructor(categoryManager, authModal, onPostCreated, notificationManager = null) {
@@ -26,6 +27,10 @@ export class PostForm {
 
         createPostContainer.innerHTML = `
             <form id="postForm" class="create-post-box" method="post" enctype="multipart/form-data">
+                <!-- Left empty by people, bots fill it in -->
+                <input type="text" id="postWebsite" name="website" tabindex="-1" autocomplete="off" aria-hidden="true"
+                       style="position: absolute; left: -10000px;" />
+
                 <!-- Title Field -->
                 <div class="form-group" style="margin-bottom: 0rem;">
                     <input type="text" id="postTitle" name="title" placeholder="Post title" 
@@ -34,7 +39,7 @@ export class PostForm {
 
                 <!-- Textarea and Post Button Side-by-Side -->
                 <div style="display: flex; gap: 1rem; align-items: flex-start; margin-bottom: 1rem;">
-                    <textarea id="postInput" name="content" placeholder="What's on your mind?" aria-label="Post content"
+                    <textarea id="postInput" name="content" placeholder="What's on your mind? Markdown is supported" aria-label="Post content"
                         style="flex: 1; min-height: 40px;"></textarea>
                     <button type="submit" id="postBtn" class="post-btn" style="height: 40px;">Post</button>
                 </div>
@@ -62,6 +67,18 @@ export class PostForm {
                         </div>
                     </div>
                 </div>
+
+                <!-- Optional Poll -->
+                <details id="pollBuilder" class="poll-builder">
+                    <summary>Add a poll</summary>
+                    <input type="text" id="pollQuestion" maxlength="200" placeholder="Question" aria-label="Poll question" />
+                    <textarea id="pollOptions" placeholder="One option per line (2 to 10)" aria-label="Poll options"></textarea>
+                    <div class="poll-builder-settings">
+                        <label><input type="checkbox" id="pollMultiple" /> Allow several choices</label>
+                        <label><input type="checkbox" id="pollHideResults" /> Hide results until closed</label>
+                        <label>Closes <input type="datetime-local" id="pollClosesAt" /></label>
+                    </div>
+                </details>
             </form>
         `;
 
@@ -97,10 +114,15 @@ export class PostForm {
         const submitFormData = this.buildSubmissionData(formData);
 
         try {
+            await ProofOfWork.appendSolution(submitFormData, 'post');
             const result = await ApiUtils.post('/api/posts/create', submitFormData, true, true);
 
             // Success! Reset form and notify parent
-            this.showNotification('Post created successfully!', 'success');
+            if (result && result.status === 'pending') {
+                this.showNotification('Your post will appear once a moderator has reviewed it.', 'info');
+            } else {
+                this.showNotification('Post created successfully!', 'success');
+            }
             this.resetForm();
 
             if (this.onPostCreated) {
@@ -137,7 +159,31 @@ export class PostForm {
             title: this.form.querySelector('#postTitle').value.trim(),
             content: this.form.querySelector('#postInput').value.trim(),
             imageInput: this.form.querySelector('#postImage'),
-            selectedCategories: this.categoryManager.getSelectedCategories()
+            selectedCategories: this.categoryManager.getSelectedCategories(),
+            poll: this.getPollData()
+        };
+    }
+
+    /**
+     * Get the poll being added to the post, if any
+     * @returns {Object|null} - Poll data, or null without a poll
+     */
+    getPollData() {
+        const builder = this.form.querySelector('#pollBuilder');
+        const question = this.form.querySelector('#pollQuestion').value.trim();
+        const options = this.form.querySelector('#pollOptions').value
+            .split('\n').map(option => option.trim()).filter(Boolean);
+        if (!builder.open || (!question && options.length === 0)) {
+            return null;
+        }
+
+        const closesAt = this.form.querySelector('#pollClosesAt').value;
+        return {
+            question,
+            options,
+            multiple: this.form.querySelector('#pollMultiple').checked,
+            hide_results: this.form.querySelector('#pollHideResults').checked,
+            closes_at: closesAt ? new Date(closesAt).toISOString() : ''
         };
     }
 
@@ -159,6 +205,19 @@ export class PostForm {
             return { valid: false, error: "Please select at least one category." };
         }
 
+        const poll = formData.poll;
+        if (poll) {
+            if (!poll.question) {
+                return { valid: false, error: "Your poll needs a question." };
+            }
+            if (poll.options.length < 2 || poll.options.length > 10) {
+                return { valid: false, error: "A poll needs 2 to 10 options." };
+            }
+            if (!poll.closes_at || new Date(poll.closes_at) <= new Date()) {
+                return { valid: false, error: "Choose when the poll closes." };
+            }
+        }
+
         return { valid: true };
     }
 
@@ -177,10 +236,19 @@ export class PostForm {
             submitFormData.append("image", formData.imageInput.files[0]);
         }
 
+        const honeypot = document.getElementById("postWebsite");
+        if (honeypot && honeypot.value) {
+            submitFormData.append("website", honeypot.value);
+        }
+
         console.log("DEBUG: Selected categories:", formData.selectedCategories);
         submitFormData.append("category_names", JSON.stringify(formData.selectedCategories));
         console.log("DEBUG: Sending category_names as JSON:", JSON.stringify(formData.selectedCategories));
 
+        if (formData.poll) {
+            submitFormData.append("poll", JSON.stringify(formData.poll));
+        }
+
         return submitFormData;
     }
 
@@ -189,6 +257,7 @@ export class PostForm {
      */
     resetForm() {
         this.form.reset();
+        this.form.querySelector('#pollBuilder').open = false;
         this.categoryManager.resetCategoryDropdown();
     }
 
@@ -72,6 +72,20 @@ export class Router {
             requiresAuth: true
         });
         
+        this.routes.set('/feed', {
+            name: 'feed',
+            component: 'FeedView',
+            title: 'Forum - Following',
+            requiresAuth: true
+        });
+
+        this.routes.set('/bookmarks', {
+            name: 'bookmarks',
+            component: 'BookmarksView',
+            title: 'Forum - Bookmarks',
+            requiresAuth: true
+        });
+
         this.routes.set('/post/:id', {
             name: 'post-detail',
             component: 'PostDetailView',
@@ -79,6 +93,13 @@ export class Router {
             requiresAuth: false
         });
         
+        this.routes.set('/user/:username', {
+            name: 'user',
+            component: 'UserView',
+            title: 'Forum - User',
+            requiresAuth: false
+        });
+
         this.routes.set('/category/:id', {
             name: 'category',
             component: 'CategoryView',
@@ -209,8 +230,11 @@ export class Router {
             /^\/trending$/,                  // /trending
             /^\/myposts$/,                   // /myposts
             /^\/likedposts$/,                // /likedposts
+            /^\/feed$/,                      // /feed
+            /^\/bookmarks$/,                 // /bookmarks
             /^\/post\/[^\/]+$/,             // /post/{id}
             /^\/category\/[^\/]+$/,         // /category/{id}
+            /^\/user\/[^\/]+$/,             // /user/{username}
         ];
 
         // Check if pathname matches any valid pattern
@@ -0,0 +1,56 @@
+/**
+ * Follow Button - Follows users and categories, so their posts show up in
+ * the current user's feed
+ */
+
+import { ApiUtils } from '../utils/ApiUtils.mjs';
+
+export class FollowButton {
+    /**
+     * Create a follow button for a user or category
+     * @param {string} type - 'user' or 'category'
+     * @param {string|number} target - Username or category ID
+     * @param {boolean} following - Whether the current user follows it
+     * @param {Function} [onChange] - Called with the new state after a toggle
+     * @returns {HTMLButtonElement} - Follow button
+     */
+    static create(type, target, following, onChange) {
+        const btn = document.createElement('button');
+        btn.className = 'follow-btn';
+        FollowButton.setState(btn, following);
+
+        btn.addEventListener('click', async () => {
+            const isFollowing = btn.classList.contains('following');
+            const collection = type === 'category' ? 'categories' : 'users';
+            const body = type === 'category' ? { category_id: Number(target) } : { username: target };
+
+            btn.disabled = true;
+            try {
+                if (isFollowing) {
+                    await ApiUtils.send('DELETE', `/api/${collection}/unfollow`, body, true);
+                } else {
+                    await ApiUtils.post(`/api/${collection}/follow`, body, true);
+                }
+                FollowButton.setState(btn, !isFollowing);
+                if (onChange) onChange(!isFollowing);
+            } catch (error) {
+                ApiUtils.handleError(error, 'following', true);
+            } finally {
+                btn.disabled = false;
+            }
+        });
+        return btn;
+    }
+
+    /**
+     * Show whether the target is followed
+     * @param {HTMLElement} btn - Follow button
+     * @param {boolean} following - Whether it is followed
+     */
+    static setState(btn, following) {
+        btn.classList.toggle('following', following);
+        btn.innerHTML = following
+            ? '<i class="fas fa-user-check"></i> Following'
+            : '<i class="fas fa-user-plus"></i> Follow';
+    }
+}
@@ -39,6 +39,27 @@ export class ApiUtils {
         return await response.json();
     }
 
+    /**
+     * Fetches the CSRF token bound to the current session cookie
+     * @returns {Promise<string>} - The token, or an empty string without a session
+     */
+    static async getCsrfToken() {
+        try {
+            const response = await fetch(`${this.BASE_URL}/api/csrf-token`, {
+                method: 'GET',
+                credentials: 'include'
+            });
+            if (!response.ok) {
+                return '';
+            }
+            const data = await response.json();
+            return data.csrf_token || '';
+        } catch (error) {
+            console.error('Failed to fetch CSRF token:', error);
+            return '';
+        }
+    }
+
     /**
      * Makes a POST request to the API
      * @param {string} endpoint - API endpoint
@@ -48,8 +69,32 @@ export class ApiUtils {
      * @returns {Promise<any>} - Response data
      */
     static async post(endpoint, data, includeCredentials = false, isFormData = false) {
+        return this.send('POST', endpoint, data, includeCredentials, isFormData);
+    }
+
+    /**
+     * Makes a PUT request to the API
+     * @param {string} endpoint - API endpoint
+     * @param {any} data - Data to send
+     * @param {boolean} includeCredentials - Whether to include credentials
+     * @returns {Promise<any>} - Response data
+     */
+    static async put(endpoint, data, includeCredentials = false) {
+        return this.send('PUT', endpoint, data, includeCredentials);
+    }
+
+    /**
+     * Sends a request with a body to the API
+     * @param {string} method - HTTP method
+     * @param {string} endpoint - API endpoint
+     * @param {any} data - Data to send
+     * @param {boolean} includeCredentials - Whether to include credentials
+     * @param {boolean} isFormData - Whether data is FormData
+     * @returns {Promise<any>} - Response data
+     */
+    static async send(method, endpoint, data, includeCredentials = false, isFormData = false) {
         const options = {
-            method: 'POST',
+            method,
             body: isFormData ? data : JSON.stringify(data)
         };
 
@@ -61,6 +106,12 @@ export class ApiUtils {
 
         if (includeCredentials) {
             options.credentials = 'include';
+
+            // State-changing requests must echo the session's CSRF token
+            const csrfToken = await this.getCsrfToken();
+            if (csrfToken) {
+                options.headers = { ...(options.headers || {}), 'X-CSRF-Token': csrfToken };
+            }
         }
 
         const response = await fetch(`${this.BASE_URL}${endpoint}`, options);
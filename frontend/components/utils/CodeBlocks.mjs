@@ -0,0 +1,33 @@
+/**
+ * Copy buttons for highlighted code blocks in rendered Markdown
+ */
+
+export class CodeBlocks {
+    /**
+     * Add a copy button to every highlighted code block in a container.
+     * The button copies the code as shown, never hidden markup.
+     * @param {HTMLElement} container - Element holding rendered content
+     */
+    static addCopyButtons(container) {
+        container.querySelectorAll('.code-block').forEach(block => {
+            if (block.querySelector('.copy-code-btn')) return;
+
+            const button = document.createElement('button');
+            button.type = 'button';
+            button.className = 'copy-code-btn';
+            button.textContent = 'Copy';
+            button.addEventListener('click', async () => {
+                const code = block.querySelector('pre.highlight code') || block.querySelector('code');
+                try {
+                    await navigator.clipboard.writeText(code.textContent);
+                    button.textContent = 'Copied';
+                } catch (error) {
+                    console.error('Failed to copy code:', error);
+                    button.textContent = 'Copy failed';
+                }
+                setTimeout(() => { button.textContent = 'Copy'; }, 1500);
+            });
+            block.appendChild(button);
+        });
+    }
+}
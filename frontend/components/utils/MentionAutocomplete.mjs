@@ -0,0 +1,156 @@
+/**
+ * @mention suggestions for post, comment and reply composers
+ */
+
+import { ApiUtils } from './ApiUtils.mjs';
+
+export class MentionAutocomplete {
+    /**
+     * Suggest users while an @mention is typed in any textarea of a root
+     * element, including ones added later. Arrow keys move through the
+     * suggestions, Enter or Tab picks one and Escape closes them.
+     * @param {HTMLElement} root - Element whose textareas get suggestions
+     */
+    constructor(root = document.body) {
+        this.textarea = null;
+        this.suggestions = [];
+        this.selected = 0;
+        this.timer = null;
+
+        this.list = document.createElement('ul');
+        this.list.className = 'mention-suggestions';
+        this.list.setAttribute('role', 'listbox');
+        this.list.hidden = true;
+        document.body.appendChild(this.list);
+
+        root.addEventListener('input', (e) => {
+            if (e.target instanceof HTMLTextAreaElement) this.onInput(e.target);
+        });
+        root.addEventListener('keydown', (e) => this.onKeyDown(e), true);
+        root.addEventListener('focusout', () => setTimeout(() => this.close(), 150));
+        this.list.addEventListener('mousedown', (e) => {
+            const item = e.target.closest('li');
+            if (!item) return;
+            e.preventDefault();
+            this.pick(Number(item.dataset.index));
+        });
+    }
+
+    /**
+     * The mention being typed just before the caret, if any
+     * @param {HTMLTextAreaElement} textarea - Composer
+     * @returns {{start: number, query: string}|null}
+     */
+    currentMention(textarea) {
+        const before = textarea.value.slice(0, textarea.selectionStart);
+        const match = before.match(/(^|[^A-Za-z0-9_.+\/@-])@([A-Za-z0-9_-]{1,30})$/);
+        if (!match) return null;
+        return { start: before.length - match[2].length - 1, query: match[2] };
+    }
+
+    onInput(textarea) {
+        clearTimeout(this.timer);
+        const mention = this.currentMention(textarea);
+        if (!mention) {
+            this.close();
+            return;
+        }
+        this.textarea = textarea;
+        this.timer = setTimeout(() => this.fetch(textarea, mention.query), 150);
+    }
+
+    async fetch(textarea, query) {
+        try {
+            const suggestions = await ApiUtils.get(`/api/users/autocomplete?q=${encodeURIComponent(query)}`, true);
+            // Ignore answers to a query that is no longer being typed
+            const current = this.currentMention(textarea);
+            if (!current || current.query !== query) return;
+            this.show(textarea, suggestions || []);
+        } catch (error) {
+            this.close();
+        }
+    }
+
+    show(textarea, suggestions) {
+        this.suggestions = suggestions;
+        this.selected = 0;
+        if (suggestions.length === 0) {
+            this.close();
+            return;
+        }
+
+        this.list.innerHTML = '';
+        suggestions.forEach((user, index) => {
+            const item = document.createElement('li');
+            item.dataset.index = index;
+            item.setAttribute('role', 'option');
+            item.textContent = `@${user.username}`;
+            this.list.appendChild(item);
+        });
+        this.highlight();
+
+        const rect = textarea.getBoundingClientRect();
+        this.list.style.left = `${rect.left + window.scrollX}px`;
+        this.list.style.top = `${rect.bottom + window.scrollY}px`;
+        this.list.hidden = false;
+    }
+
+    highlight() {
+        this.list.querySelectorAll('li').forEach((item, index) => {
+            item.classList.toggle('active', index === this.selected);
+            item.setAttribute('aria-selected', index === this.selected);
+        });
+    }
+
+    onKeyDown(e) {
+        if (this.list.hidden || e.target !== this.textarea) return;
+        switch (e.key) {
+            case 'ArrowDown':
+                this.selected = (this.selected + 1) % this.suggestions.length;
+                this.highlight();
+                break;
+            case 'ArrowUp':
+                this.selected = (this.selected - 1 + this.suggestions.length) % this.suggestions.length;
+                this.highlight();
+                break;
+            case 'Enter':
+            case 'Tab':
+                this.pick(this.selected);
+                break;
+            case 'Escape':
+                this.close();
+                break;
+            default:
+                return;
+        }
+        e.preventDefault();
+        e.stopPropagation();
+    }
+
+    /**
+     * Replace the mention being typed with a suggested username
+     * @param {number} index - Index of the suggestion
+     */
+    pick(index) {
+        const user = this.suggestions[index];
+        const textarea = this.textarea;
+        const mention = textarea && this.currentMention(textarea);
+        if (!user || !mention) {
+            this.close();
+            return;
+        }
+
+        const inserted = `@${user.username} `;
+        const caret = textarea.selectionStart;
+        textarea.value = textarea.value.slice(0, mention.start) + inserted + textarea.value.slice(caret);
+        textarea.selectionStart = textarea.selectionEnd = mention.start + inserted.length;
+        textarea.focus();
+        this.close();
+    }
+
+    close() {
+        clearTimeout(this.timer);
+        this.list.hidden = true;
+        this.suggestions = [];
+    }
+}
@@ -0,0 +1,63 @@
+/**
+ * Solves the backend's proof-of-work challenges (anti-spam, no third-party CAPTCHA)
+ */
+
+import { ApiUtils } from './ApiUtils.mjs';
+
+export class ProofOfWork {
+    /** Hashes computed concurrently per round */
+    static BATCH_SIZE = 256;
+
+    /**
+     * Fetch a challenge for an action and add the solution to a form
+     * @param {FormData} formData - Form about to be submitted
+     * @param {string} action - "register" or "post"
+     * @returns {Promise<void>}
+     */
+    static async appendSolution(formData, action) {
+        const challenge = await ApiUtils.get(`/api/captcha?action=${encodeURIComponent(action)}`, true);
+        if (!challenge.required) {
+            return;
+        }
+        const nonce = await this.solve(challenge.token, challenge.difficulty);
+        formData.append('captcha_token', challenge.token);
+        formData.append('captcha_nonce', nonce);
+    }
+
+    /**
+     * Find a nonce such that SHA-256(token ":" nonce) starts with `difficulty` zero bits
+     * @param {string} token - Challenge token
+     * @param {number} difficulty - Leading zero bits required
+     * @returns {Promise<string>}
+     */
+    static async solve(token, difficulty) {
+        const encoder = new TextEncoder();
+        for (let start = 0; ; start += this.BATCH_SIZE) {
+            const nonces = Array.from({ length: this.BATCH_SIZE }, (_, i) => String(start + i));
+            const digests = await Promise.all(
+                nonces.map((nonce) => crypto.subtle.digest('SHA-256', encoder.encode(`${token}:${nonce}`)))
+            );
+            const index = digests.findIndex((digest) => this.leadingZeroBits(new Uint8Array(digest)) >= difficulty);
+            if (index !== -1) {
+                return nonces[index];
+            }
+        }
+    }
+
+    /**
+     * Count the leading zero bits of a digest
+     * @param {Uint8Array} bytes - Digest
+     * @returns {number}
+     */
+    static leadingZeroBits(bytes) {
+        let bits = 0;
+        for (const byte of bytes) {
+            if (byte === 0) {
+                bits += 8;
+                continue;
+            }
+            return bits + Math.clz32(byte) - 24;
+        }
+        return bits;
+    }
+}
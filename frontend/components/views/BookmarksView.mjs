@@ -0,0 +1,199 @@
+/**
+ * Bookmarks View - The posts and comments the current user bookmarked
+ */
+
+import { BaseView } from './BaseView.mjs';
+import { ApiUtils } from '../utils/ApiUtils.mjs';
+import { TimeUtils } from '../utils/TimeUtils.mjs';
+import { ValidationUtils } from '../../utils/ValidationUtils.mjs';
+
+const PAGE_SIZE = 20;
+
+export class BookmarksView extends BaseView {
+    constructor(app, params, query) {
+        super(app, params, query);
+        this.folder = null; // null for all bookmarks, '' for those in no folder
+        this.page = 1;
+    }
+
+    /**
+     * Render the bookmarks view
+     * @param {HTMLElement} container - Container element
+     */
+    async render(container) {
+        if (!await this.isAuthenticated()) {
+            this.showAuthModal();
+            this.app.router.navigate('/', true);
+            return;
+        }
+
+        container.innerHTML = `
+            <div class="bookmarks-view">
+                <div class="bookmarks-header">
+                    <h1><i class="fas fa-bookmark"></i> Bookmarks</h1>
+                    <p>Posts and comments you saved. Only you can see them.</p>
+                </div>
+                <div class="bookmarks-filters"></div>
+                <ul class="bookmarks-list"></ul>
+                <button class="load-more-btn hidden">Load more</button>
+            </div>
+        `;
+        container.querySelector('.load-more-btn').addEventListener('click', () => this.loadBookmarks(container, false));
+
+        try {
+            await this.renderFolders(container);
+            await this.loadBookmarks(container, true);
+        } catch (error) {
+            console.error('Error rendering bookmarks view:', error);
+            container.innerHTML = '';
+            container.appendChild(this.createErrorElement(
+                'Failed to load bookmarks.',
+                () => this.render(container)
+            ));
+        }
+    }
+
+    /**
+     * Render the folder filter buttons
+     * @param {HTMLElement} container - Container element
+     */
+    async renderFolders(container) {
+        const folders = await ApiUtils.get('/api/bookmarks/folders', true);
+        const filters = container.querySelector('.bookmarks-filters');
+        filters.innerHTML = '';
+
+        const choices = [{ label: 'All', folder: null }, { label: 'Unfiled', folder: '' }]
+            .concat(folders.map(f => ({ label: `${f.name} (${Number(f.count)})`, folder: f.name })));
+        choices.forEach(choice => {
+            const btn = document.createElement('button');
+            btn.className = 'filter-btn';
+            btn.classList.toggle('active', choice.folder === this.folder);
+            btn.textContent = choice.label;
+            btn.addEventListener('click', () => {
+                this.folder = choice.folder;
+                filters.querySelectorAll('.filter-btn').forEach(b => b.classList.toggle('active', b === btn));
+                this.loadBookmarks(container, true);
+            });
+            filters.appendChild(btn);
+        });
+    }
+
+    /**
+     * Load a page of bookmarks in the current folder
+     * @param {HTMLElement} container - Container element
+     * @param {boolean} reset - Start again from the first page
+     */
+    async loadBookmarks(container, reset) {
+        const list = container.querySelector('.bookmarks-list');
+        if (reset) {
+            this.page = 1;
+            list.innerHTML = '';
+        }
+
+        let endpoint = `/api/bookmarks?page=${this.page}&limit=${PAGE_SIZE}`;
+        if (this.folder !== null) {
+            endpoint += `&folder=${encodeURIComponent(this.folder)}`;
+        }
+        const bookmarks = await ApiUtils.get(endpoint, true);
+        bookmarks.forEach(bookmark => list.appendChild(this.createBookmarkElement(container, bookmark)));
+        this.page++;
+
+        container.querySelector('.load-more-btn').classList.toggle('hidden', bookmarks.length < PAGE_SIZE);
+        if (list.children.length === 0) {
+            list.innerHTML = '<li class="bookmarks-empty">No bookmarks here yet. Use the <i class="far fa-bookmark"></i> button on posts and comments to save them.</li>';
+        }
+    }
+
+    /**
+     * Create the element of a bookmark. Titles arrive HTML escaped,
+     * other text is set as text.
+     * @param {HTMLElement} container - Container element
+     * @param {Object} bookmark - Bookmark from the API
+     * @returns {HTMLElement} - Bookmark element
+     */
+    createBookmarkElement(container, bookmark) {
+        const item = document.createElement('li');
+        item.className = 'bookmark-item';
+        const kind = bookmark.content_type === 'comment' ? 'Comment on' : 'Post';
+        item.innerHTML = `
+            <div class="bookmark-meta">
+                <span class="bookmark-kind">${kind}</span>
+                <a href="/post/${Number(bookmark.post_id)}">${bookmark.post_title}</a>
+                <span class="bookmark-author"></span>
+                <span class="bookmark-date">saved ${TimeUtils.getTimeAgo(bookmark.created_at)}</span>
+            </div>
+            <p class="bookmark-excerpt"></p>
+            <p class="bookmark-note hidden"><i class="fas fa-sticky-note"></i> <span></span></p>
+            <div class="bookmark-actions">
+                <span class="bookmark-folder hidden"><i class="fas fa-folder"></i> <span></span></span>
+                <button class="bookmark-edit-btn"><i class="fas fa-pen"></i> Folder &amp; note</button>
+                <button class="bookmark-remove-btn"><i class="fas fa-trash"></i> Remove</button>
+            </div>
+        `;
+        item.querySelector('.bookmark-author').textContent = `by ${bookmark.username}`;
+        const excerpt = bookmark.content.length > 200 ? `${bookmark.content.slice(0, 200)}…` : bookmark.content;
+        item.querySelector('.bookmark-excerpt').textContent = excerpt;
+        this.showDetails(item, bookmark);
+
+        item.querySelector('.bookmark-edit-btn').addEventListener('click', () => this.editBookmark(container, item, bookmark));
+        item.querySelector('.bookmark-remove-btn').addEventListener('click', async () => {
+            try {
+                await ApiUtils.send('DELETE', '/api/bookmarks/remove', this.target(bookmark), true);
+                item.remove();
+                await this.renderFolders(container);
+            } catch (error) {
+                ApiUtils.handleError(error, 'removing bookmark', true);
+            }
+        });
+        return item;
+    }
+
+    /**
+     * Show a bookmark's folder and note
+     * @param {HTMLElement} item - Bookmark element
+     * @param {Object} bookmark - Bookmark from the API
+     */
+    showDetails(item, bookmark) {
+        const folder = item.querySelector('.bookmark-folder');
+        folder.classList.toggle('hidden', !bookmark.folder);
+        folder.querySelector('span').textContent = bookmark.folder;
+        const note = item.querySelector('.bookmark-note');
+        note.classList.toggle('hidden', !bookmark.note);
+        note.querySelector('span').textContent = bookmark.note;
+    }
+
+    /**
+     * Ask for a bookmark's folder and note and save them
+     * @param {HTMLElement} container - Container element
+     * @param {HTMLElement} item - Bookmark element
+     * @param {Object} bookmark - Bookmark from the API
+     */
+    async editBookmark(container, item, bookmark) {
+        // The prompt puts its default value in an attribute unescaped
+        const quoted = text => ValidationUtils.escapeHtml(text).replace(/"/g, '&quot;');
+        const notifications = this.app.getNotificationManager();
+        const folder = await notifications.showPrompt('Folder, or empty for none', 'Bookmark folder', quoted(bookmark.folder));
+        if (folder === null) return;
+        const note = await notifications.showPrompt('Note, or empty for none', 'Bookmark note', quoted(bookmark.note));
+        if (note === null) return;
+
+        try {
+            await ApiUtils.post('/api/bookmarks/add', { ...this.target(bookmark), folder, note }, true);
+            bookmark.folder = folder;
+            bookmark.note = note;
+            this.showDetails(item, bookmark);
+            await this.renderFolders(container);
+        } catch (error) {
+            ApiUtils.handleError(error, 'saving bookmark', true);
+        }
+    }
+
+    /**
+     * The post or comment a bookmark request is about
+     * @param {Object} bookmark - Bookmark from the API
+     * @returns {Object} - post_id or comment_id
+     */
+    target(bookmark) {
+        return bookmark.content_type === 'comment' ? { comment_id: bookmark.comment_id } : { post_id: bookmark.post_id };
+    }
+}
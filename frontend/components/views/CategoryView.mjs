@@ -3,6 +3,8 @@
  */
 
 import { BaseView } from './BaseView.mjs';
+import { ApiUtils } from '../utils/ApiUtils.mjs';
+import { FollowButton } from '../users/FollowButton.mjs';
 
 export class CategoryView extends BaseView {
     constructor(app, params, query) {
@@ -107,6 +109,7 @@ export class CategoryView extends BaseView {
 
         // Setup event listeners
         this.setupEventListeners();
+        await this.renderActions(categoryContent);
 
         // Load category posts
         await this.loadCategoryPosts('recent');
@@ -115,6 +118,68 @@ export class CategoryView extends BaseView {
         this.app.getCategoryManager().updateActiveCategory(parseInt(this.categoryId));
     }
 
+    /**
+     * Add follow and mute buttons for signed in users
+     * @param {HTMLElement} categoryContent - Category element
+     */
+    async renderActions(categoryContent) {
+        if (!await this.isAuthenticated()) {
+            return;
+        }
+
+        try {
+            const [followed, muted] = await Promise.all([
+                ApiUtils.get('/api/categories/following', true),
+                ApiUtils.get('/api/categories/muted', true)
+            ]);
+            const isThis = cat => cat.id.toString() === this.categoryId.toString();
+            const actions = document.createElement('div');
+            actions.className = 'category-actions';
+            actions.appendChild(FollowButton.create('category', this.categoryId, followed.some(isThis)));
+            actions.appendChild(this.createMuteButton(muted.some(isThis)));
+            categoryContent.querySelector('.category-header').appendChild(actions);
+        } catch (error) {
+            console.error('Error loading followed categories:', error);
+        }
+    }
+
+    /**
+     * Create a button muting this category, or lifting the mute. Posts in
+     * muted categories are left out of post lists, this one included.
+     * @param {boolean} muted - Whether the category is muted
+     * @returns {HTMLButtonElement} - Mute button
+     */
+    createMuteButton(muted) {
+        const btn = document.createElement('button');
+        btn.className = 'hide-btn';
+        const setState = on => {
+            btn.classList.toggle('active', on);
+            btn.innerHTML = on ? '<i class="fas fa-volume-up"></i> Unmute' : '<i class="fas fa-volume-mute"></i> Mute';
+        };
+        setState(muted);
+
+        btn.addEventListener('click', async () => {
+            const on = btn.classList.contains('active');
+            const body = { category_id: Number(this.categoryId) };
+            btn.disabled = true;
+            try {
+                if (on) {
+                    await ApiUtils.send('DELETE', '/api/categories/unmute', body, true);
+                } else {
+                    await ApiUtils.post('/api/categories/mute', body, true);
+                }
+                setState(!on);
+                const active = document.querySelector('.category-filters .filter-btn.active');
+                await this.loadCategoryPosts(active ? active.getAttribute('data-filter') : 'recent');
+            } catch (error) {
+                ApiUtils.handleError(error, 'muting category', true);
+            } finally {
+                btn.disabled = false;
+            }
+        });
+        return btn;
+    }
+
     /**
      * Setup event listeners
      */
@@ -0,0 +1,91 @@
+/**
+ * Feed View - Posts by the users and in the categories the current user follows
+ */
+
+import { BaseView } from './BaseView.mjs';
+import { ApiUtils } from '../utils/ApiUtils.mjs';
+import { PostCard } from '../posts/PostCard.mjs';
+
+const PAGE_SIZE = 20;
+
+export class FeedView extends BaseView {
+    constructor(app, params, query) {
+        super(app, params, query);
+        this.cursor = '';
+    }
+
+    /**
+     * Render the feed view
+     * @param {HTMLElement} container - Container element
+     */
+    async render(container) {
+        if (!await this.isAuthenticated()) {
+            this.showAuthModal();
+            this.app.router.navigate('/', true);
+            return;
+        }
+
+        container.innerHTML = `
+            <div class="feed-view">
+                <div class="feed-header">
+                    <h1><i class="fas fa-stream"></i> Following</h1>
+                    <p>The latest posts from people and categories you follow.</p>
+                </div>
+                <div class="feed-posts"></div>
+                <button class="load-more-btn hidden">Load more</button>
+            </div>
+        `;
+        container.querySelector('.load-more-btn').addEventListener('click', async () => {
+            try {
+                await this.loadPosts(container);
+            } catch (error) {
+                ApiUtils.handleError(error, 'loading feed', true);
+            }
+        });
+
+        try {
+            this.cursor = '';
+            await this.loadPosts(container);
+        } catch (error) {
+            console.error('Error rendering feed view:', error);
+            container.innerHTML = '';
+            container.appendChild(this.createErrorElement(
+                'Failed to load your feed.',
+                () => this.render(container)
+            ));
+        }
+    }
+
+    /**
+     * Load the next page of the feed
+     * @param {HTMLElement} container - Container element
+     */
+    async loadPosts(container) {
+        let endpoint = `/api/feed?limit=${PAGE_SIZE}`;
+        if (this.cursor) {
+            endpoint += `&cursor=${encodeURIComponent(this.cursor)}`;
+        }
+        const feed = await ApiUtils.get(endpoint, true);
+        const list = container.querySelector('.feed-posts');
+
+        (feed.posts || []).forEach(post => {
+            const postCard = PostCard.create(post);
+            PostCard.setupCommentToggle(postCard);
+            list.appendChild(postCard);
+        });
+        this.cursor = feed.next_cursor;
+        container.querySelector('.load-more-btn').classList.toggle('hidden', !this.cursor);
+
+        if (list.children.length === 0) {
+            list.innerHTML = `
+                <div class="no-posts">
+                    <div class="no-posts-icon"><i class="fas fa-user-friends"></i></div>
+                    <h3>Nothing here yet</h3>
+                    <p>Follow people from their profile, or categories from their page, to see their posts here.</p>
+                </div>
+            `;
+            return;
+        }
+        await this.app.getReactionManager().loadPostsLikes();
+    }
+}
@@ -3,6 +3,10 @@
  */
 
 import { BaseView } from './BaseView.mjs';
+import { ApiUtils } from '../utils/ApiUtils.mjs';
+import { CodeBlocks } from '../utils/CodeBlocks.mjs';
+import { PollView } from '../posts/PollView.mjs';
+import { BookmarkButton } from '../posts/BookmarkButton.mjs';
 
 export class PostDetailView extends BaseView {
     constructor(app, params, query) {
@@ -100,8 +104,8 @@ export class PostDetailView extends BaseView {
                     <i class="fas fa-arrow-left"></i> Back
                 </button>
                 <div class="post-actions">
-                    <button class="save-post-btn" title="Save Post">
-                        <i class="fas fa-bookmark"></i>
+                    <button class="save-post-btn ${this.post.is_bookmarked ? 'saved' : ''}" data-type="post" data-id="${this.post.id}" title="${this.post.is_bookmarked ? 'Remove bookmark' : 'Bookmark'}">
+                        <i class="${this.post.is_bookmarked ? 'fas' : 'far'} fa-bookmark"></i>
                     </button>
                     <button class="share-post-btn" title="Share Post">
                         <i class="fas fa-share"></i>
@@ -128,7 +132,7 @@ export class PostDetailView extends BaseView {
                 <div class="post-body">
                     <h1 class="post-title">${this.post.title}</h1>
                     ${this.post.image_url ? `<img src="http://localhost:8080${this.post.image_url}" alt="Post image" class="post-image" onerror="this.outerHTML='<div class=\\'image-error\\'>Image unavailable</div>'">` : ''}
-                    <div class="post-content">${this.post.content}</div>
+                    <div class="post-content">${this.post.content_html}</div>
                 </div>
 
                 <div class="post-footer">
@@ -204,6 +208,15 @@ export class PostDetailView extends BaseView {
             });
         }
 
+        // Copy buttons for code in the post
+        const postBody = document.querySelector('.post-detail-view .post-content');
+        if (postBody) {
+            CodeBlocks.addCopyButtons(postBody);
+            if (this.post.poll) {
+                postBody.after(PollView.create(this.post.poll));
+            }
+        }
+
         // Note: Like/dislike buttons are handled by ReactionManager automatically
     }
 
@@ -252,6 +265,8 @@ export class PostDetailView extends BaseView {
                     // Add the complete thread (comment + replies) to the comments container
                     commentsList.appendChild(commentThreadContainer);
                 }
+                CodeBlocks.addCopyButtons(commentsList);
+                this.addAcceptButtons(commentsList);
             }
 
             // Render comment form
@@ -270,6 +285,54 @@ export class PostDetailView extends BaseView {
         }
     }
 
+    /**
+     * Let the post's author and moderators accept a published comment or
+     * reply as the answer, or unmark the accepted one
+     * @param {HTMLElement} commentsList - Element holding the comments
+     */
+    addAcceptButtons(commentsList) {
+        const user = this.getCurrentUser();
+        const canAccept = user && (user.id === this.post.user_id || ['moderator', 'admin'].includes(user.role));
+        if (!canAccept) return;
+
+        commentsList.querySelectorAll('.comment').forEach(commentEl => {
+            if (commentEl.querySelector(':scope > .comment-wrapper .pending-badge')) return;
+            const actions = commentEl.querySelector(':scope > .comment-wrapper .comment-actions');
+            if (!actions) return;
+
+            const accepted = commentEl.classList.contains('accepted-answer');
+            const button = document.createElement('button');
+            button.type = 'button';
+            button.className = 'reaction-btn accept-answer-btn';
+            button.innerHTML = accepted
+                ? '<i class="fas fa-times"></i><span>Unaccept</span>'
+                : '<i class="fas fa-check"></i><span>Accept answer</span>';
+            button.addEventListener('click', () => {
+                const id = Number(commentEl.getAttribute('comment-id'));
+                const isReply = commentEl.classList.contains('reply-comment');
+                this.setAcceptedAnswer(accepted ? null : { [isReply ? 'reply_id' : 'comment_id']: id });
+            });
+            actions.appendChild(button);
+        });
+    }
+
+    /**
+     * Accept an answer, or clear the accepted one, and reload the comments
+     * @param {Object|null} answer - {comment_id} or {reply_id}, null to clear
+     */
+    async setAcceptedAnswer(answer) {
+        try {
+            const result = answer
+                ? await ApiUtils.post('/api/posts/answer/accept', { post_id: this.post.id, ...answer }, true)
+                : await ApiUtils.send('DELETE', '/api/posts/answer/clear', { post_id: this.post.id }, true);
+            this.post = { ...this.post, ...result.data };
+            await this.loadComments();
+        } catch (error) {
+            const errorInfo = ApiUtils.handleError(error, 'accepting answer');
+            this.showNotification(errorInfo.message, 'error');
+        }
+    }
+
     /**
      * Render post categories
      * @returns {string} - HTML for post categories
@@ -341,7 +404,7 @@ export class PostDetailView extends BaseView {
     // Note: Like/dislike functionality is handled by ReactionManager
 
     /**
-     * Toggle save post
+     * Bookmark the post, or remove the bookmark
      */
     async toggleSavePost() {
         try {
@@ -350,21 +413,15 @@ export class PostDetailView extends BaseView {
                 return;
             }
 
-            // This would typically toggle save status via API
-            console.log('Toggling save for post:', this.postId);
-            
             const saveBtn = document.querySelector('.save-post-btn');
             if (saveBtn) {
-                saveBtn.classList.toggle('saved');
-                const icon = saveBtn.querySelector('i');
-                if (icon) {
-                    icon.className = saveBtn.classList.contains('saved') ? 
-                        'fas fa-bookmark' : 'far fa-bookmark';
-                }
+                this.post.is_bookmarked = await BookmarkButton.toggle(saveBtn);
+                this.showNotification(this.post.is_bookmarked ? 'Post bookmarked' : 'Bookmark removed', 'success');
             }
 
         } catch (error) {
             console.error('Error toggling save:', error);
+            this.showNotification('Failed to update bookmark', 'error');
         }
     }
 
@@ -3,6 +3,7 @@
  */
 
 import { BaseView } from './BaseView.mjs';
+import { ApiUtils } from '../utils/ApiUtils.mjs';
 
 export class ProfileView extends BaseView {
     constructor(app, params, query) {
@@ -90,10 +91,107 @@ export class ProfileView extends BaseView {
                         </div>
                     </div>
                 </div>
+
+                <div class="profile-info-section">
+                    <h3><i class="fas fa-bell"></i> Notifications</h3>
+                    <div class="notification-preferences"></div>
+                </div>
+
+                <div class="profile-info-section">
+                    <h3><i class="fas fa-ban"></i> Blocked and Muted</h3>
+                    <div class="hidden-lists"></div>
+                </div>
             </div>
         `;
 
         container.appendChild(profileContent);
+        await Promise.all([
+            this.renderNotificationPreferences(profileContent.querySelector('.notification-preferences')),
+            this.renderHiddenLists(profileContent.querySelector('.hidden-lists'))
+        ]);
+    }
+
+    /**
+     * Render the users the user blocked or muted and the categories they
+     * muted, each with a button lifting it
+     * @param {HTMLElement} lists - Lists element
+     */
+    async renderHiddenLists(lists) {
+        try {
+            const [blocked, muted, categories] = await Promise.all([
+                ApiUtils.get('/api/users/blocked', true),
+                ApiUtils.get('/api/users/muted', true),
+                ApiUtils.get('/api/categories/muted', true)
+            ]);
+            const groups = [
+                { title: 'Blocked users', items: blocked, name: u => u.username, endpoint: '/api/users/unblock', body: u => ({ username: u.username }) },
+                { title: 'Muted users', items: muted, name: u => u.username, endpoint: '/api/users/unmute', body: u => ({ username: u.username }) },
+                { title: 'Muted categories', items: categories, name: c => c.name, endpoint: '/api/categories/unmute', body: c => ({ category_id: c.id }) }
+            ];
+
+            groups.forEach(group => {
+                const heading = document.createElement('h4');
+                heading.textContent = group.title;
+                const list = document.createElement('ul');
+                list.className = 'hidden-list';
+                if (group.items.length === 0) {
+                    list.innerHTML = '<li class="hidden-list-empty">None</li>';
+                }
+
+                group.items.forEach(item => {
+                    const entry = document.createElement('li');
+                    entry.innerHTML = '<span></span> <button class="hide-btn active">Remove</button>';
+                    entry.querySelector('span').textContent = group.name(item);
+                    entry.querySelector('button').addEventListener('click', async () => {
+                        try {
+                            await ApiUtils.send('DELETE', group.endpoint, group.body(item), true);
+                            entry.remove();
+                        } catch (error) {
+                            ApiUtils.handleError(error, 'updating blocked and muted lists', true);
+                        }
+                    });
+                    list.appendChild(entry);
+                });
+                lists.append(heading, list);
+            });
+        } catch (error) {
+            console.error('Error loading blocked and muted lists:', error);
+            lists.textContent = 'Failed to load blocked and muted lists.';
+        }
+    }
+
+    /**
+     * Render a switch for each notification the user can turn off
+     * @param {HTMLElement} list - Preferences element
+     */
+    async renderNotificationPreferences(list) {
+        try {
+            const preferences = await ApiUtils.get('/api/notifications/preferences', true);
+            preferences.forEach(preference => {
+                const label = document.createElement('label');
+                label.className = 'notification-preference';
+                label.innerHTML = '<input type="checkbox"> <span></span>';
+                label.querySelector('span').textContent = preference.description;
+
+                const checkbox = label.querySelector('input');
+                checkbox.checked = preference.enabled;
+                checkbox.addEventListener('change', async () => {
+                    checkbox.disabled = true;
+                    try {
+                        await ApiUtils.put('/api/notifications/preferences/update', { [preference.type]: checkbox.checked }, true);
+                    } catch (error) {
+                        checkbox.checked = !checkbox.checked;
+                        ApiUtils.handleError(error, 'saving notification preferences', true);
+                    } finally {
+                        checkbox.disabled = false;
+                    }
+                });
+                list.appendChild(label);
+            });
+        } catch (error) {
+            console.error('Error loading notification preferences:', error);
+            list.textContent = 'Failed to load notification preferences.';
+        }
     }
 
 
@@ -196,7 +196,7 @@ export class TrendingView extends BaseView {
                                 <img src="http://localhost:8080${post.image_url}" alt="Post image" class="trending-post-image" onerror="this.parentElement.innerHTML='<div class=\\'image-error\\'>Image unavailable</div>'">
                             </div>
                         ` : ''}
-                        <div class="post-snippet">${this.truncateContent(post.content, 150)}</div>
+                        <div class="post-snippet">${this.truncateContent(post.content_html, 150)}</div>
 
                         <!-- Interactive reaction buttons -->
                         <div class="post-actions">
@@ -367,15 +367,24 @@ export class TrendingView extends BaseView {
     }
 
     /**
-     * Truncate content to specified length
-     * @param {string} content - Content to truncate
+     * Truncate rendered content to a plain text snippet
+     * @param {string} contentHTML - Rendered content to truncate
      * @param {number} maxLength - Maximum length
-     * @returns {string} - Truncated content
+     * @returns {string} - Escaped snippet
      */
-    truncateContent(content, maxLength) {
-        if (!content) return '';
-        if (content.length <= maxLength) return content;
-        return content.substring(0, maxLength).trim() + '...';
+    truncateContent(contentHTML, maxLength) {
+        if (!contentHTML) return '';
+        // Snippets are the plain text of the rendered Markdown; a template
+        // parses it without loading images
+        const template = document.createElement('template');
+        template.innerHTML = contentHTML;
+        let text = template.content.textContent.trim();
+        if (text.length > maxLength) {
+            text = text.substring(0, maxLength).trim() + '...';
+        }
+        const escaped = document.createElement('div');
+        escaped.textContent = text;
+        return escaped.innerHTML;
     }
 
     /**
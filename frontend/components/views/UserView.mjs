@@ -0,0 +1,244 @@
+/**
+ * User View - Public profile of any user, linked from @mentions
+ */
+
+import { BaseView } from './BaseView.mjs';
+import { ApiUtils } from '../utils/ApiUtils.mjs';
+import { FollowButton } from '../users/FollowButton.mjs';
+
+export class UserView extends BaseView {
+    constructor(app, params, query) {
+        super(app, params, query);
+        this.profile = null;
+        this.reputation = null;
+        this.badges = [];
+    }
+
+    /**
+     * Render the user view
+     * @param {HTMLElement} container - Container element
+     */
+    async render(container) {
+        container.innerHTML = '';
+        container.appendChild(this.createLoadingElement());
+
+        try {
+            const username = decodeURIComponent(this.params.username || '');
+            [this.profile, this.reputation, this.badges] = await Promise.all([
+                ApiUtils.get(`/api/users/profile?username=${encodeURIComponent(username)}`),
+                ApiUtils.get(`/api/users/reputation?username=${encodeURIComponent(username)}&limit=10`),
+                ApiUtils.get(`/api/users/badges?username=${encodeURIComponent(username)}`)
+            ]);
+            await this.renderProfileContent(container);
+        } catch (error) {
+            console.error('Error rendering user view:', error);
+            container.innerHTML = '';
+            container.appendChild(this.createErrorElement(
+                'User not found.',
+                () => this.render(container)
+            ));
+        }
+    }
+
+    /**
+     * Render the public profile
+     * @param {HTMLElement} container - Container element
+     */
+    async renderProfileContent(container) {
+        container.innerHTML = '';
+
+        const profileContent = document.createElement('div');
+        profileContent.className = 'profile-view';
+        profileContent.innerHTML = `
+            <div class="profile-header">
+                <div class="profile-avatar">
+                    <img class="avatar-large" alt="">
+                </div>
+                <div class="profile-info">
+                    <h1 class="profile-username"></h1>
+                    <p class="profile-joined">Joined: ${this.formatDate(this.profile.created_at)}</p>
+                    <p class="profile-follows">
+                        <span class="follower-count">${Number(this.profile.follower_count)}</span> followers
+                        · ${Number(this.profile.following_count)} following
+                    </p>
+                </div>
+            </div>
+
+            <div class="profile-details">
+                <div class="profile-info-section">
+                    <h3><i class="fas fa-chart-bar"></i> Activity</h3>
+                    <div class="profile-data">
+                        <div class="profile-field">
+                            <label>Posts</label>
+                            <div class="field-value">${Number(this.profile.post_count)}</div>
+                        </div>
+                        <div class="profile-field">
+                            <label>Comments</label>
+                            <div class="field-value">${Number(this.profile.comment_count)}</div>
+                        </div>
+                        <div class="profile-field">
+                            <label>Reputation</label>
+                            <div class="field-value">${Number(this.profile.reputation)}</div>
+                        </div>
+                    </div>
+                </div>
+
+                <div class="profile-info-section">
+                    <h3><i class="fas fa-medal"></i> Badges</h3>
+                    <ul class="badge-list"></ul>
+                </div>
+
+                <div class="profile-info-section">
+                    <h3><i class="fas fa-star"></i> Recent Reputation</h3>
+                    ${this.renderReputationHistory()}
+                </div>
+            </div>
+        `;
+
+        const avatar = profileContent.querySelector('.avatar-large');
+        avatar.src = `http://localhost:8080${this.profile.avatar_url || '/static/pictures/default-avatar.png'}`;
+        avatar.alt = `${this.profile.username}'s avatar`;
+        profileContent.querySelector('.profile-username').textContent = this.profile.username;
+        this.renderBadges(profileContent.querySelector('.badge-list'));
+        await this.renderActions(profileContent);
+
+        container.appendChild(profileContent);
+    }
+
+    /**
+     * Add follow, mute and block buttons for signed in users other than this one
+     * @param {HTMLElement} profileContent - Profile element
+     */
+    async renderActions(profileContent) {
+        const currentUser = this.getCurrentUser();
+        if (!await this.isAuthenticated() || !currentUser || currentUser.username === this.profile.username) {
+            return;
+        }
+
+        const actions = document.createElement('div');
+        actions.className = 'profile-actions';
+        const count = profileContent.querySelector('.follower-count');
+        const followBtn = FollowButton.create('user', this.profile.username, this.profile.is_following, following => {
+            this.profile.follower_count += following ? 1 : -1;
+            count.textContent = Number(this.profile.follower_count);
+        });
+        followBtn.classList.toggle('hidden', this.profile.is_blocked);
+        actions.appendChild(followBtn);
+
+        actions.appendChild(this.createHideButton('mute', this.profile.is_muted));
+        actions.appendChild(this.createHideButton('block', this.profile.is_blocked, blocked => {
+            // Blocking ends follows both ways
+            if (blocked && followBtn.classList.contains('following')) {
+                FollowButton.setState(followBtn, false);
+                this.profile.follower_count -= 1;
+                count.textContent = Number(this.profile.follower_count);
+            }
+            followBtn.classList.toggle('hidden', blocked);
+        }));
+        profileContent.querySelector('.profile-info').appendChild(actions);
+    }
+
+    /**
+     * Create a button muting or blocking this user, or lifting it
+     * @param {string} action - 'mute' or 'block'
+     * @param {boolean} active - Whether the user is muted or blocked
+     * @param {Function} [onChange] - Called with the new state after a toggle
+     * @returns {HTMLButtonElement} - Mute or block button
+     */
+    createHideButton(action, active, onChange) {
+        const labels = {
+            mute: ['<i class="fas fa-volume-mute"></i> Mute', '<i class="fas fa-volume-up"></i> Unmute'],
+            block: ['<i class="fas fa-ban"></i> Block', '<i class="fas fa-ban"></i> Unblock']
+        };
+        const btn = document.createElement('button');
+        btn.className = 'hide-btn';
+        const setState = on => {
+            btn.classList.toggle('active', on);
+            btn.innerHTML = labels[action][on ? 1 : 0];
+        };
+        setState(active);
+
+        btn.addEventListener('click', async () => {
+            const on = btn.classList.contains('active');
+            const body = { username: this.profile.username };
+            btn.disabled = true;
+            try {
+                if (on) {
+                    await ApiUtils.send('DELETE', `/api/users/un${action}`, body, true);
+                } else {
+                    await ApiUtils.post(`/api/users/${action}`, body, true);
+                }
+                setState(!on);
+                if (onChange) onChange(!on);
+            } catch (error) {
+                ApiUtils.handleError(error, `${action === 'mute' ? 'muting' : 'blocking'} user`, true);
+            } finally {
+                btn.disabled = false;
+            }
+        });
+        return btn;
+    }
+
+    /**
+     * Fill the list of badges the user earned
+     * @param {HTMLElement} list - The badge list element
+     */
+    renderBadges(list) {
+        const badges = this.badges || [];
+        if (badges.length === 0) {
+            list.outerHTML = '<p class="badge-empty">No badges yet.</p>';
+            return;
+        }
+
+        badges.forEach(badge => {
+            const item = document.createElement('li');
+            item.className = 'badge';
+            item.title = `${badge.description} (earned ${this.formatDate(badge.awarded_at)}, held by ${Number(badge.holders)})`;
+            item.innerHTML = '<i class="fas fa-medal"></i> <span class="badge-name"></span>';
+            item.querySelector('.badge-name').textContent = badge.name;
+            list.appendChild(item);
+        });
+    }
+
+    /**
+     * Render the latest reputation changes
+     * @returns {string} - HTML for the reputation history
+     */
+    renderReputationHistory() {
+        const history = (this.reputation && this.reputation.history) || [];
+        if (history.length === 0) {
+            return '<p class="reputation-empty">No reputation yet.</p>';
+        }
+
+        const reasons = {
+            like: 'Liked',
+            dislike: 'Disliked',
+            accepted_answer: 'Answer accepted'
+        };
+        const items = history.map(event => `
+            <li class="reputation-event">
+                <span class="reputation-points ${event.points < 0 ? 'negative' : ''}">${event.points > 0 ? '+' : ''}${Number(event.points)}</span>
+                <a href="/post/${Number(event.post_id)}">${reasons[event.reason] || 'Reputation'} (${event.content_type})</a>
+                <span class="reputation-date">${this.formatDate(event.created_at)}</span>
+            </li>
+        `).join('');
+        return `<ul class="reputation-history">${items}</ul>`;
+    }
+
+    /**
+     * Format date for display
+     * @param {string} dateString - Date string to format
+     * @returns {string} - Formatted date
+     */
+    formatDate(dateString) {
+        try {
+            return new Date(dateString).toLocaleDateString('en-US', {
+                year: 'numeric',
+                month: 'long',
+                day: 'numeric'
+            });
+        } catch (error) {
+            return 'Unknown';
+        }
+    }
+}